@@ -0,0 +1,60 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// backup writes the objects of the users, posts and files buckets to a
+// tar.gz archive, for copies outside of MinIO
+func backup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "backup-"+time.Now().UTC().Format("20060102-150405")+".tar.gz", "archive to write, - for stdout")
+	app, err := setup(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	storageService, err := services.NewStorageService(app.cfg, app.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if *output != "-" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	count, err := storageService.Backup(ctx, tw)
+	if err == nil {
+		err = errors.Join(tw.Close(), gz.Close(), out.Close())
+	}
+	if err != nil {
+		if *output != "-" {
+			out.Close()
+			os.Remove(*output)
+		}
+		return fmt.Errorf("backup failed after %d objects: %w", count, err)
+	}
+	fmt.Fprintf(os.Stderr, "Backed up %d objects to %s\n", count, *output)
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// command is a subcommand of the server binary
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists the subcommands; without one the server is started.
+// Commands other than serve log to stderr, keeping stdout for their
+// output.
+func commands() []command {
+	return []command{
+		{"serve", "Run the API server (the default)", serve},
+		{"migrate", "Create the buckets and NATS streams this version needs", migrate},
+		{"reindex", "Count the statistics and storage usage again", reindex},
+		{"backup", "Write every stored object to a tar.gz archive", backup},
+		{"create-admin", "Add an admin account with a generated password", createAdmin},
+		{"seed", "Generate demo users, posts and files", seedData},
+	}
+}
+
+// run starts the subcommand args name with the rest of args
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
+	}
+	name := args[0]
+	if name == "help" || name == "-h" {
+		usage()
+		return nil
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args[1:])
+		}
+	}
+	usage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [-config file] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", filepath.Base(os.Args[0]))
+}
+
+// app is what every command starts from
+type app struct {
+	cfg        *config.Config
+	configFile string
+	logger     *slog.Logger
+	logLevel   *slog.LevelVar
+}
+
+// setup parses args into flags, which the command has defined, and the
+// -config flag all commands share, then loads and validates the
+// configuration and sets up logging to logs
+func setup(flags *flag.FlagSet, args []string, logs io.Writer) (*app, error) {
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON config file; environment variables override it")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	// Load configuration from the environment and an optional config file
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Structured logs; the standard logger writes through them too. The
+	// level follows reloads of the configuration.
+	logLevel := new(slog.LevelVar)
+	logger, err := logging.NewWithLevel(cfg.Log, logLevel, logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up logging: %w", err)
+	}
+	slog.SetDefault(logger)
+
+	// Refuse to start on settings that are out of range or, outside
+	// development, unsafe
+	warnings, err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration for %s:\n%w", cfg.Environment, err)
+	}
+	for _, warning := range warnings {
+		logger.Warn("Unsafe configuration, allowed only in development: "+warning, "environment", cfg.Environment)
+	}
+
+	return &app{cfg: cfg, configFile: *configFile, logger: logger, logLevel: logLevel}, nil
+}
+
+// connectRedis returns a traced client of the configured Redis server
+func connectRedis(ctx context.Context, cfg config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to trace Redis: %w", err), client.Close())
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to connect to Redis: %w", err), client.Close())
+	}
+	return client, nil
+}
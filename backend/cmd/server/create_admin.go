@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// generatedPasswordLength is the length of passwords create-admin makes up
+const generatedPasswordLength = 24
+
+// createAdmin adds an admin account, for the first admin of a new
+// installation or to regain access. Without -password-stdin a password is
+// generated and printed once.
+func createAdmin(args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := flags.String("username", "admin", "username of the admin")
+	email := flags.String("email", "", "email address of the admin (required)")
+	firstName := flags.String("first-name", "", "first name")
+	lastName := flags.String("last-name", "", "last name")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	app, err := setup(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+	ctx := context.Background()
+
+	policy, err := auth.NewPasswordPolicy(app.cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to load password policy: %w", err)
+	}
+	hasher, err := auth.NewPasswordHasher(app.cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("invalid password hashing settings: %w", err)
+	}
+
+	password := ""
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read the password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+		if err := policy.Check(password); err != nil {
+			return err
+		}
+	} else if password, err = auth.GeneratePassword(max(generatedPasswordLength, app.cfg.Auth.PasswordMinLength)); err != nil {
+		return err
+	}
+
+	storageService, err := services.NewStorageService(app.cfg, app.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+	if _, err := storageService.GetUserByEmail(ctx, *email); err == nil {
+		return fmt.Errorf("a user with the email %s exists already", *email)
+	}
+	if _, err := storageService.GetUserByUsername(ctx, *username); err == nil {
+		return fmt.Errorf("the username %s is taken", *username)
+	}
+
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	user := &models.User{
+		Username:        *username,
+		Email:           *email,
+		Password:        hashedPassword,
+		FirstName:       *firstName,
+		LastName:        *lastName,
+		Role:            models.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := storageService.CreateUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to create the admin: %w", err)
+	}
+
+	fmt.Printf("Admin %s created with ID %s\n", user.Username, user.ID)
+	if !*passwordStdin {
+		fmt.Printf("Password: %s\nIt is not shown again; change it after logging in.\n", password)
+	}
+	return nil
+}
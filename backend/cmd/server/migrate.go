@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// migrate prepares MinIO and NATS for this version ahead of a rollout. The
+// server does the same when it starts, so running it is optional.
+func migrate(args []string) error {
+	app, err := setup(flag.NewFlagSet("migrate", flag.ExitOnError), args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	cfg, ctx := app.cfg, context.Background()
+
+	// The storage service creates missing buckets
+	if _, err := services.NewStorageService(cfg, app.logger); err != nil {
+		return fmt.Errorf("failed to prepare buckets: %w", err)
+	}
+	fmt.Println("Buckets ready:", cfg.Database.UsersBucket, cfg.Database.PostsBucket, cfg.Database.FilesBucket)
+
+	messagingClient, err := messaging.NewClient(cfg.NATS)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	defer messagingClient.Close()
+
+	if err := messagingClient.SetupJobStream(ctx); err != nil {
+		return fmt.Errorf("failed to set up the job stream: %w", err)
+	}
+	fmt.Println("Job stream ready")
+	if cfg.NATS.EventsEnabled {
+		if err := messagingClient.SetupEventStream(ctx, time.Duration(cfg.NATS.EventRetention)*time.Hour); err != nil {
+			return fmt.Errorf("failed to set up the event stream: %w", err)
+		}
+		if err := messagingClient.SetupWebhookStream(ctx); err != nil {
+			return fmt.Errorf("failed to set up the webhook stream: %w", err)
+		}
+		fmt.Println("Event and webhook streams ready")
+	}
+	return nil
+}
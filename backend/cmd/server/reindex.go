@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+)
+
+// reindex counts the statistics and the storage used per user again, as
+// the rebuild endpoint and the usage task do, for when they drifted
+func reindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	skipUsage := flags.Bool("skip-usage", false, "only rebuild the statistics counters")
+	app, err := setup(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	storageService, err := services.NewStorageService(app.cfg, app.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+	redisClient, err := connectRedis(ctx, app.cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+	storageService.SetStatsCounter(stats.NewCounter(redisClient))
+
+	started, err := storageService.RebuildStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild statistics: %w", err)
+	}
+	if !started {
+		return fmt.Errorf("statistics are being rebuilt already, try again later")
+	}
+	fmt.Println("Statistics rebuilt")
+
+	if *skipUsage {
+		return nil
+	}
+	report, err := storageService.AggregateUsage(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	fmt.Printf("Usage counted: %d users, %d files, %d bytes, %d posts\n", len(report.Users), report.TotalFiles, report.TotalBytes, report.TotalPosts)
+	return nil
+}
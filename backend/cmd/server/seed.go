@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/seed"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+)
+
+// seedData fills the configured MinIO with made up users, posts and files
+// for demos, load tests and frontend development. All seeded users share
+// one password, which is printed unless -password is given.
+func seedData(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	users := flags.Int("users", 20, "number of users")
+	posts := flags.Int("posts", 100, "number of posts, spread over the users")
+	files := flags.Int("files", 50, "number of files, spread over the users")
+	fileSize := flags.Int("file-size", 16<<10, "approximate size of text files in bytes")
+	password := flags.String("password", "", "password of the seeded users; generated if empty")
+	emailDomain := flags.String("email-domain", "example.com", "domain of the seeded email addresses")
+	randomSeed := flags.Uint64("seed", 0, "seed of the generated data, to repeat a run; random if 0")
+	concurrency := flags.Int("concurrency", 8, "objects written at once")
+	queueJobs := flags.Bool("jobs", true, "queue thumbnail and scan jobs for the files, which needs NATS and Redis")
+	force := flags.Bool("force", false, "seed even when APP_ENV is production")
+	app, err := setup(flags, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if app.cfg.Environment == config.EnvProduction && !*force {
+		return errors.New("refusing to seed a production environment without -force")
+	}
+	if *users < 0 || *posts < 0 || *files < 0 {
+		return errors.New("counts must not be negative")
+	}
+	ctx := context.Background()
+
+	hasher, err := auth.NewPasswordHasher(app.cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("invalid password hashing settings: %w", err)
+	}
+	generated := *password == ""
+	if generated {
+		if *password, err = auth.GeneratePassword(max(generatedPasswordLength, app.cfg.Auth.PasswordMinLength)); err != nil {
+			return err
+		}
+	}
+	// One hash for everyone; hashing per user would dominate large runs
+	passwordHash, err := hasher.Hash(*password)
+	if err != nil {
+		return err
+	}
+	if *randomSeed == 0 {
+		*randomSeed = rand.Uint64()
+	}
+
+	storageService, err := services.NewStorageService(app.cfg, app.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+
+	opts := seed.Options{
+		Users:        *users,
+		Posts:        *posts,
+		Files:        *files,
+		PasswordHash: passwordHash,
+		EmailDomain:  *emailDomain,
+		FileSize:     *fileSize,
+		Seed:         *randomSeed,
+		Concurrency:  *concurrency,
+	}
+	if *queueJobs {
+		// Stats are counted and jobs queued as for uploads through the API
+		redisClient, err := connectRedis(ctx, app.cfg.Redis)
+		if err != nil {
+			return err
+		}
+		defer redisClient.Close()
+		storageService.SetStatsCounter(stats.NewCounter(redisClient))
+
+		messagingClient, err := messaging.NewClient(app.cfg.NATS)
+		if err != nil {
+			return fmt.Errorf("failed to connect to NATS: %w", err)
+		}
+		defer messagingClient.Close()
+		if err := messagingClient.SetupJobStream(ctx); err != nil {
+			return fmt.Errorf("failed to set up the job stream: %w", err)
+		}
+		jobQueue := jobs.NewQueue(messagingClient, redisClient)
+		opts.FileStored = func(ctx context.Context, file *models.File) {
+			jobType, ok := services.NextJob(file)
+			if !ok {
+				return
+			}
+			if _, err := jobQueue.Enqueue(ctx, jobType, messaging.FileJob{FileID: file.ID}); err != nil {
+				app.logger.Error("Failed to enqueue job", "type", jobType, "file", file.ID, "error", err)
+			}
+		}
+	}
+
+	started := time.Now()
+	app.logger.Info("Seeding", "users", *users, "posts", *posts, "files", *files, "seed", *randomSeed)
+	result, err := seed.Run(ctx, storageService, opts)
+	app.logger.Info("Seeded", "users", result.Users, "posts", result.Posts, "files", result.Files,
+		"bytes", result.Bytes, "duration", time.Since(started).Round(time.Millisecond).String())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Seeded %d users, %d posts and %d files (seed %d)\n", result.Users, result.Posts, result.Files, *randomSeed)
+	if generated && result.Users > 0 {
+		fmt.Printf("Password of every seeded user: %s\n", *password)
+	}
+	return nil
+}
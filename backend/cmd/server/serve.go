@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/api"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/https"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/notifications"
+	"github.com/minio-fullstack-storage/backend/internal/outbox"
+	"github.com/minio-fullstack-storage/backend/internal/preview"
+	"github.com/minio-fullstack-storage/backend/internal/scanner"
+	"github.com/minio-fullstack-storage/backend/internal/scheduler"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/minio-fullstack-storage/backend/internal/tracing"
+	"github.com/minio-fullstack-storage/backend/internal/transcode"
+	"github.com/minio-fullstack-storage/backend/internal/webhook"
+	"github.com/minio-fullstack-storage/backend/internal/workers"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// newRouter returns the router of the API. CORS, like the rest of the
+// middleware, is set up by the API, which follows the reloaded origins.
+func newRouter(live *config.Live, storageService *services.StorageService, messagingClient *messaging.Client, jobQueue *jobs.Queue, redisClient *redis.Client, taskScheduler *scheduler.Scheduler, logger *slog.Logger) (*gin.Engine, error) {
+	// Panics are recovered by the API middleware, which reports them
+	router := gin.New()
+	// Client addresses come from X-Forwarded-For only behind these proxies
+	if err := router.SetTrustedProxies(live.Get().Network.TrustedProxies); err != nil {
+		return nil, err
+	}
+	api.SetupRoutes(router, live, storageService, messagingClient, jobQueue, redisClient, taskScheduler, logger)
+	return router, nil
+}
+
+// serve runs the API server until it is interrupted
+func serve(args []string) error {
+	app, err := setup(flag.NewFlagSet("serve", flag.ExitOnError), args, os.Stdout)
+	if err != nil {
+		return err
+	}
+	cfg, logger := app.cfg, app.logger
+
+	// Rate limits, CORS origins, the log level, feature flags and the MinIO
+	// and SMTP credentials are reloaded on SIGHUP, when the config file
+	// changes and when the secrets are refreshed
+	live := config.NewLive(cfg, app.configFile)
+
+	// Tracing is exported when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
+
+	// Initialize storage service
+	storageService, err := services.NewStorageService(cfg, logger)
+	if err != nil {
+		log.Fatal("Failed to initialize storage service:", err)
+	}
+
+	// Connect to NATS for background jobs
+	messagingClient, err := messaging.NewClient(cfg.NATS)
+	if err != nil {
+		log.Fatal("Failed to connect to NATS:", err)
+	}
+	defer messagingClient.Close()
+
+	// Domain events go to a JetStream stream other services consume
+	if cfg.NATS.EventsEnabled {
+		retention := time.Duration(cfg.NATS.EventRetention) * time.Hour
+		if err := messagingClient.SetupEventStream(context.Background(), retention); err != nil {
+			log.Fatal("Failed to set up the event stream (JetStream must be enabled, or set EVENTS_ENABLED=false):", err)
+		}
+
+		// Webhooks are sent from the event stream
+		if err := messagingClient.SetupWebhookStream(context.Background()); err != nil {
+			log.Fatal("Failed to set up the webhook stream:", err)
+		}
+	}
+
+	// Connect to Redis for refresh tokens
+	redisClient, err := connectRedis(context.Background(), cfg.Redis)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer redisClient.Close()
+
+	// Events wait in an outbox in Redis until NATS has them
+	var eventOutbox *outbox.Outbox
+	if cfg.NATS.EventsEnabled {
+		eventOutbox = outbox.New(redisClient, messagingClient, storageService)
+		storageService.SetEventPublisher(eventOutbox)
+	}
+
+	// Statistics are counted as data changes; the first start counts what
+	// is already stored
+	storageService.SetStatsCounter(stats.NewCounter(redisClient))
+	go func() {
+		if err := storageService.EnsureStats(context.Background()); err != nil {
+			logger.Error("Failed to build statistics", "error", err)
+		}
+	}()
+
+	// Background jobs run from a JetStream work queue, with their counters
+	// and dead letters in Redis
+	if err := messagingClient.SetupJobStream(context.Background()); err != nil {
+		log.Fatal("Failed to set up the job stream (JetStream must be enabled on the NATS server):", err)
+	}
+	jobQueue := jobs.NewQueue(messagingClient, redisClient)
+
+	// Start background workers
+	workers.NewEmailWorker(jobQueue, mailer.NewReloadingMailer(func() config.MailConfig { return live.Get().Mail })).Register()
+	workers.NewThumbnailWorker(storageService, jobQueue).Register()
+	if err := workers.NewAccessLogWorker(storageService, messagingClient).Start(); err != nil {
+		log.Fatal("Failed to start access log worker:", err)
+	}
+	if err := workers.NewAuditLogWorker(storageService, messagingClient).Start(); err != nil {
+		log.Fatal("Failed to start audit log worker:", err)
+	}
+	if cfg.Preview.Enabled {
+		renderer := preview.NewRenderer(cfg.Preview.PdftoppmPath, cfg.Preview.LibreOfficePath, cfg.Preview.Size)
+		timeout := time.Duration(cfg.Preview.Timeout) * time.Second
+		workers.NewPreviewWorker(storageService, jobQueue, renderer, timeout).Register()
+	}
+	if cfg.Video.Enabled {
+		transcoder := transcode.NewTranscoder(cfg.Video.FFmpegPath, cfg.Video.FFprobePath, cfg.Video.Renditions)
+		timeout := time.Duration(cfg.Video.Timeout) * time.Minute
+		workers.NewTranscodeWorker(storageService, jobQueue, transcoder, timeout).Register()
+	}
+	if cfg.NATS.EventsEnabled {
+		if err := workers.NewNotificationWorker(notifications.New(redisClient), messagingClient, logger).Start(); err != nil {
+			log.Fatal("Failed to start notification worker:", err)
+		}
+		if err := workers.NewMentionMailer(storageService, messagingClient, mailer.NewQueuedMailer(jobQueue), cfg.Mail.AppURL, logger).Start(); err != nil {
+			log.Fatal("Failed to start mention mailer:", err)
+		}
+		sender := webhook.NewSender(time.Duration(cfg.Webhook.Timeout)*time.Second, cfg.Webhook.AllowPrivate)
+		if err := workers.NewWebhookWorker(storageService, messagingClient, sender, cfg.Webhook.MaxAttempts, logger).Start(); err != nil {
+			log.Fatal("Failed to start webhook worker:", err)
+		}
+	}
+	if cfg.Scan.Enabled {
+		scannerClient := scanner.NewClient(cfg.Scan.ClamdAddress, time.Duration(cfg.Scan.Timeout)*time.Second)
+		workers.NewScanWorker(storageService, jobQueue, scannerClient).Register()
+	}
+	if err := jobQueue.Start(context.Background()); err != nil {
+		log.Fatal("Failed to start background jobs:", err)
+	}
+
+	// Periodic jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+
+	// Objects changed with mc or the console must not leave caches and
+	// statistics stale
+	if cfg.MinIO.Notifications {
+		storageService.ListenForChanges(jobsCtx)
+	}
+
+	if eventOutbox != nil {
+		interval := time.Duration(max(cfg.NATS.OutboxInterval, 1)) * time.Second
+		workers.NewOutboxRelay(eventOutbox, interval, logger).Start(jobsCtx)
+	}
+
+	// Recurring tasks run on one server at a time, the scheduler leader
+	taskScheduler := scheduler.New(redisClient, logger)
+	storageTasks := workers.NewStorageTasks(storageService, logger)
+	digests := workers.NewDigestSender(storageService, mailer.NewQueuedMailer(jobQueue), cfg.Mail.AppURL, logger)
+	for _, t := range []struct {
+		name    string
+		spec    string
+		timeout time.Duration
+		fn      scheduler.Func
+	}{
+		{"expired-files", cfg.Scheduler.ExpiredFiles, 10 * time.Minute, storageTasks.PurgeExpiredFiles},
+		{"expired-shares", cfg.Scheduler.ExpiredShares, 10 * time.Minute, storageTasks.DeleteExpiredShares},
+		{"orphan-scan", cfg.Scheduler.OrphanScan, time.Hour, storageTasks.DeleteOrphans},
+		{"usage", cfg.Scheduler.Usage, time.Hour, storageTasks.AggregateUsage},
+		{"stats-rebuild", cfg.Scheduler.StatsRebuild, time.Hour, storageTasks.RebuildStats},
+		{"digest", cfg.Scheduler.Digest, time.Hour, digests.Send},
+	} {
+		if t.spec == "" || t.spec == "off" {
+			continue
+		}
+		if err := taskScheduler.Add(t.name, t.spec, t.timeout, t.fn); err != nil {
+			log.Fatal("Invalid schedule:", err)
+		}
+	}
+	if cfg.Scheduler.Enabled {
+		taskScheduler.Start(jobsCtx)
+	}
+
+	live.OnReload(func(cfg *config.Config) {
+		if err := logging.SetLevel(app.logLevel, cfg.Log.Level); err != nil {
+			logger.Error("Failed to change the log level", "error", err)
+		}
+		storageService.SetCredentials(cfg.MinIO.AccessKeyID, cfg.MinIO.SecretAccessKey)
+	})
+	reportReload := func(changed []string, err error) {
+		switch {
+		case err != nil:
+			logger.Error("Configuration not reloaded", "error", err)
+		case len(changed) > 0:
+			logger.Info("Configuration reloaded", "changed", changed)
+		default:
+			logger.Info("Configuration reloaded without changes")
+		}
+	}
+	go live.Watch(jobsCtx, time.Duration(cfg.WatchInterval)*time.Second, reportReload)
+	go live.Refresh(jobsCtx, time.Duration(cfg.Secrets.RefreshInterval)*time.Second, reportReload)
+	go func() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		for range hangup {
+			reportReload(live.Reload())
+		}
+	}()
+
+	router, err := newRouter(live, storageService, messagingClient, jobQueue, redisClient, taskScheduler, logger)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
+
+	// Configure server
+	// Reading bodies and writing responses are not limited here, so long
+	// uploads and downloads can finish; the API limits how long requests
+	// take per route instead
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           https.HSTS(router, cfg.TLS.HSTSMaxAge),
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	// HTTPS is served directly when a certificate or ACME domains are set,
+	// with plain HTTP on the redirect port sending clients over
+	tlsServer, err := https.New(cfg.TLS)
+	if err != nil {
+		log.Fatal("Failed to set up TLS:", err)
+	}
+	var redirectSrv *http.Server
+	if tlsServer != nil {
+		srv.TLSConfig = tlsServer.TLSConfig
+		if cfg.TLS.RedirectPort != "" {
+			redirectSrv = &http.Server{
+				Addr:              ":" + cfg.TLS.RedirectPort,
+				Handler:           tlsServer.RedirectHandler(cfg.Port),
+				ReadHeaderTimeout: 15 * time.Second,
+				IdleTimeout:       60 * time.Second,
+			}
+			go func() {
+				log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.TLS.RedirectPort)
+				if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatal("HTTP redirect failed to start:", err)
+				}
+			}()
+		}
+	}
+
+	// Internal services call the gRPC API on its own port, over TLS when
+	// the server has a certificate
+	var grpcSrv *grpc.Server
+	if cfg.GRPC.Port != "" {
+		var opts []grpc.ServerOption
+		if tlsServer != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsServer.TLSConfig)))
+		}
+		grpcSrv, err = api.NewGRPCServer(live, storageService, messagingClient, redisClient, logger, opts...)
+		if err != nil {
+			log.Fatal("Failed to set up the gRPC API:", err)
+		}
+		listener, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatal("gRPC API failed to start:", err)
+		}
+		go func() {
+			log.Printf("gRPC API starting on port %s", cfg.GRPC.Port)
+			if err := grpcSrv.Serve(listener); err != nil {
+				log.Fatal("gRPC API failed:", err)
+			}
+		}()
+	}
+
+	// S3 clients use the gateway on its own port, with the same certificate
+	var s3Srv *http.Server
+	if cfg.S3Gateway.Port != "" {
+		gateway, err := api.NewS3Gateway(live, storageService, messagingClient, jobQueue, redisClient, logger)
+		if err != nil {
+			log.Fatal("Failed to set up the S3 gateway:", err)
+		}
+		s3Srv = &http.Server{
+			Addr:              ":" + cfg.S3Gateway.Port,
+			Handler:           gateway,
+			ReadHeaderTimeout: 15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+		if tlsServer != nil {
+			s3Srv.TLSConfig = tlsServer.TLSConfig
+		}
+		go func() {
+			var err error
+			if tlsServer != nil {
+				log.Printf("S3 gateway starting with HTTPS on port %s", cfg.S3Gateway.Port)
+				err = s3Srv.ListenAndServeTLS("", "")
+			} else {
+				log.Printf("S3 gateway starting on port %s", cfg.S3Gateway.Port)
+				err = s3Srv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatal("S3 gateway failed to start:", err)
+			}
+		}()
+	}
+
+	// Start server in a goroutine
+	go func() {
+		var err error
+		if tlsServer != nil {
+			log.Printf("Server starting with HTTPS on port %s", cfg.Port)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Server starting on port %s", cfg.Port)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed to start:", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+	stopJobs()
+
+	// Give outstanding requests a 30-second deadline to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(ctx)
+	}
+	if grpcSrv != nil {
+		// Downloads in progress are cut off with the HTTP server's deadline
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	if s3Srv != nil {
+		_ = s3Srv.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatal("Server forced to shutdown:", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("Failed to flush traces:", err)
+	}
+
+	log.Println("Server exited")
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/miniotest"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniotest.New(t)
+	settings := "minio:\n  endpoint: " + server.Endpoint() + "\n  access_key: " + miniotest.AccessKey + "\n  secret_key: " + miniotest.SecretKey + "\n"
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(settings), 0o600))
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	live := config.NewLive(cfg, path)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storageService, err := services.NewStorageService(cfg, logger)
+	require.NoError(t, err)
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	router, err := newRouter(live, storageService, nil, nil, redisClient, nil, logger)
+	require.NoError(t, err)
+
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/health", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Every origin is allowed by default, not only the development ones
+	w := request(http.MethodGet, "https://app.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"*"}, w.Header().Values("Access-Control-Allow-Origin"))
+
+	// Origins follow the reloaded configuration
+	require.NoError(t, os.WriteFile(path, []byte(settings+"cors:\n  allowed_origins: [https://app.example.com]\n"), 0o600))
+	changed, err := live.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CORS.AllowedOrigins"}, changed)
+
+	w = request(http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, []string{"https://app.example.com"}, w.Header().Values("Access-Control-Allow-Origin"))
+	w = request(http.MethodGet, "http://localhost:3000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Values("Access-Control-Allow-Origin"))
+}
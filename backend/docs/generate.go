@@ -0,0 +1,6 @@
+package docs
+
+// The Swagger 2.0 document is generated from the annotations of the
+// handlers; internal/openapi turns it into OpenAPI 3 when the server starts.
+//
+//go:generate swag init --dir .. --generalInfo cmd/server/main.go --output .
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -39,10 +40,10 @@ func setupTestRouter(t *testing.T) *gin.Engine {
 		},
 	}
 
-	storageService, err := services.NewStorageService(cfg)
+	storageService, err := services.NewStorageService(cfg, slog.Default())
 	require.NoError(t, err)
 	router := gin.New()
-	SetupRoutes(router, cfg, storageService)
+	SetupRoutes(router, config.NewLive(cfg, ""), storageService, nil, nil, nil, nil, slog.Default())
 
 	return router
 }
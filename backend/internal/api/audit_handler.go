@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// auditWindow is how far back audit queries without a start time reach
+const auditWindow = 24 * time.Hour
+
+// recordAudit publishes event for the audit log, filling in the client
+// details of c. Failures are logged only so that they never block signing
+// in or out.
+func recordAudit(messagingClient *messaging.Client, c *gin.Context, event models.AuditEvent) {
+	if messagingClient == nil {
+		return
+	}
+
+	// Whatever happens during an impersonation was done by the admin
+	if impersonatorID := c.GetString("impersonatorID"); impersonatorID != "" && event.ActorID == "" {
+		event.ActorID = impersonatorID
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	event.OccurredAt = time.Now()
+
+	if err := messagingClient.Publish(c.Request.Context(), messaging.SubjectAuditLog, event); err != nil {
+		requestLogger(c).Error("Failed to record audit event", "type", event.Type, "userId", event.UserID, "error", err)
+	}
+}
+
+// recordAdminAction publishes action, a change made by the admin of c, for
+// the admin audit log. before and after are snapshots of the target, nil
+// when it did not exist before or after; they must not hold secrets.
+// Failures are logged only, as the change was already made.
+func recordAdminAction(messagingClient *messaging.Client, c *gin.Context, action models.AdminAction, before, after any) {
+	if messagingClient == nil {
+		return
+	}
+
+	action.ActorID = c.GetString("userID")
+	if impersonatorID := c.GetString("impersonatorID"); impersonatorID != "" {
+		action.ActorID = impersonatorID
+	}
+	action.IP = c.ClientIP()
+	action.UserAgent = c.Request.UserAgent()
+	publishAdminAction(c.Request.Context(), messagingClient, action, before, after)
+}
+
+// publishAdminAction publishes action with its actor and client details
+// already set, for callers without a gin context such as the gRPC API
+func publishAdminAction(ctx context.Context, messagingClient *messaging.Client, action models.AdminAction, before, after any) {
+	if messagingClient == nil {
+		return
+	}
+
+	action.Before = snapshot(before)
+	action.After = snapshot(after)
+	action.OccurredAt = time.Now()
+
+	if err := messagingClient.Publish(ctx, messaging.SubjectAdminLog, action); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to record admin action", "action", action.Action, "targetId", action.TargetID, "error", err)
+	}
+}
+
+// snapshot marshals the state of the target of an admin action, nil when
+// there is none
+func snapshot(state any) json.RawMessage {
+	data, err := json.Marshal(state)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+type AuditHandler struct {
+	storageService *services.StorageService
+}
+
+func NewAuditHandler(storageService *services.StorageService) *AuditHandler {
+	return &AuditHandler{
+		storageService: storageService,
+	}
+}
+
+// ListAuditEvents godoc
+// @Summary List audit events
+// @Description List security audit events such as logins, failed logins, password and role changes and token revocations, oldest first. Without from the last 24 hours before to are listed. A userId filter matches events about the user and events the user caused.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Event type (login, login_failed, logout, password_change, password_reset, role_change, status_change, tokens_revoked)"
+// @Param userId query string false "User ID"
+// @Param from query string false "RFC 3339 start time"
+// @Param to query string false "RFC 3339 end time, defaults to now"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Success 200 {object} models.ListResponse{data=[]models.AuditEvent} "Audit events retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid time range"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/audit [get]
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+
+	filter := models.AuditFilter{
+		Type:   c.Query("type"),
+		UserID: c.Query("userId"),
+		To:     time.Now(),
+	}
+	var ok bool
+	if filter.To, ok = auditTime(c, "to", filter.To); !ok {
+		return
+	}
+	if filter.From, ok = auditTime(c, "from", filter.To.Add(-auditWindow)); !ok {
+		return
+	}
+	if filter.From.After(filter.To) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "from must not be after to"))
+		return
+	}
+
+	events, total, err := h.storageService.ListAuditEvents(c.Request.Context(), filter, pagination)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list audit events"))
+		return
+	}
+
+	pagination.Total = total
+
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       events,
+		Pagination: pagination,
+	})
+}
+
+// ListAdminActions godoc
+// @Summary List admin actions
+// @Description List the changes admins made, such as role changes, deletions, impersonations and maintenance mode, with snapshots of the target before and after, oldest first. Without from the last 24 hours before to are listed.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param action query string false "Action, e.g. user.update, user.delete, user.impersonate, role.update or maintenance.update"
+// @Param actorId query string false "ID of the admin"
+// @Param targetType query string false "Kind of target (user, invitation, role, service_account, webhook, system)"
+// @Param targetId query string false "ID of the target"
+// @Param from query string false "RFC 3339 start time"
+// @Param to query string false "RFC 3339 end time, defaults to now"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Success 200 {object} models.ListResponse{data=[]models.AdminAction} "Admin actions retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid time range"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/audit-log [get]
+func (h *AuditHandler) ListAdminActions(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+
+	filter := models.AdminActionFilter{
+		Action:     c.Query("action"),
+		ActorID:    c.Query("actorId"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+	}
+	var ok bool
+	if filter.To, ok = auditTime(c, "to", time.Now()); !ok {
+		return
+	}
+	if filter.From, ok = auditTime(c, "from", filter.To.Add(-auditWindow)); !ok {
+		return
+	}
+	if filter.From.After(filter.To) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "from must not be after to"))
+		return
+	}
+
+	actions, total, err := h.storageService.ListAdminActions(c.Request.Context(), filter, pagination)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list admin actions"))
+		return
+	}
+
+	pagination.Total = total
+
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       actions,
+		Pagination: pagination,
+	})
+}
+
+// auditTime parses the RFC 3339 query parameter name, or returns fallback
+// when it is not given
+func auditTime(c *gin.Context, name string, fallback time.Time) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, name+" must be an RFC 3339 time"))
+		return time.Time{}, false
+	}
+	return t, true
+}
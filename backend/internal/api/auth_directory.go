@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/directory"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// directoryActor is the actor ID of audit events caused by directory
+// group changes
+const directoryActor = "ldap"
+
+// directoryUser authenticates loginName against the directory and returns
+// the local user for the entry. A user already linked to the entry or
+// with its verified email address is reused, otherwise one is created
+// without a password. Names, email address and, with group mappings, the role are
+// refreshed from the directory at every login.
+func (h *AuthHandler) directoryUser(ctx context.Context, loginName, password string) (*models.User, string, error) {
+	entry, err := h.directory.Authenticate(loginName, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := h.linkedDirectoryUser(ctx, entry)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if user == nil {
+		username, err := availableUsername(ctx, h.storageService, entry.Username, entry.Email)
+		if err != nil {
+			return nil, "", err
+		}
+		user = &models.User{
+			Username:    username,
+			Email:       entry.Email,
+			FirstName:   entry.FirstName,
+			LastName:    entry.LastName,
+			Role:        h.directory.RoleFor(entry.Groups),
+			DirectoryDN: entry.DN,
+		}
+		if err := h.storageService.CreateUser(ctx, user); err != nil {
+			return nil, "", err
+		}
+		return user, user.Role, nil
+	}
+
+	previousRole := user.Role
+	user.DirectoryDN = entry.DN
+	if entry.Email != "" {
+		user.Email = entry.Email
+	}
+	if entry.FirstName != "" {
+		user.FirstName = entry.FirstName
+	}
+	if entry.LastName != "" {
+		user.LastName = entry.LastName
+	}
+	if h.directory.MapsRoles() {
+		user.Role = h.directory.RoleFor(entry.Groups)
+	}
+	if err := h.storageService.UpdateUser(ctx, user); err != nil {
+		return nil, "", err
+	}
+	return user, previousRole, nil
+}
+
+// linkedDirectoryUser finds the local user for entry, preferring the one
+// linked to its DN over one with the same email address. Like social
+// logins, an entry is only linked to an account that verified the address;
+// an unverified one fails with errEmailUnverified.
+func (h *AuthHandler) linkedDirectoryUser(ctx context.Context, entry *directory.Entry) (*models.User, error) {
+	candidates, err := h.storageService.FindUsers(ctx, func(user *models.User) bool {
+		return strings.EqualFold(user.DirectoryDN, entry.DN) ||
+			entry.Email != "" && strings.EqualFold(user.Email, entry.Email)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var byEmail *models.User
+	for _, user := range candidates {
+		if strings.EqualFold(user.DirectoryDN, entry.DN) {
+			return user, nil
+		}
+		if byEmail == nil && user.DirectoryDN == "" {
+			byEmail = user
+		}
+	}
+	if byEmail != nil && byEmail.EmailVerifiedAt == nil {
+		return nil, errEmailUnverified
+	}
+	return byEmail, nil
+}
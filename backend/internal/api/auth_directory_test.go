@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/directory"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkedDirectoryUser(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	h := &AuthHandler{storageService: api.storage}
+
+	local, _ := api.user("bob", models.RoleUser)
+	entry := &directory.Entry{DN: "uid=bob,ou=people,dc=example,dc=com", Username: "bob", Email: local.Email}
+
+	// An unverified account with the entry's address is not taken over
+	_, err := h.linkedDirectoryUser(ctx, entry)
+	assert.ErrorIs(t, err, errEmailUnverified)
+
+	now := time.Now()
+	local.EmailVerifiedAt = &now
+	require.NoError(t, api.storage.UpdateUser(ctx, local))
+	linked, err := h.linkedDirectoryUser(ctx, entry)
+	require.NoError(t, err)
+	assert.Equal(t, local.ID, linked.ID)
+
+	// Nobody has the address of a new entry
+	linked, err = h.linkedDirectoryUser(ctx, &directory.Entry{DN: "uid=carol", Email: "carol@example.com"})
+	require.NoError(t, err)
+	assert.Nil(t, linked)
+}
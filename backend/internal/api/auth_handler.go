@@ -1,70 +1,244 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
 	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/captcha"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/directory"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
 	"github.com/minio-fullstack-storage/backend/internal/services"
 )
 
+// AuthHandler signs users in and out. The refresh, denylist and reset
+// stores and the login failure counter are nil when Redis is not
+// available; captcha is nil when CAPTCHA verification is off and directory
+// is nil without LDAP.
 type AuthHandler struct {
-	storageService *services.StorageService
-	jwtManager     *auth.JWTManager
+	storageService     *services.StorageService
+	jwtManager         *auth.JWTManager
+	refreshStore       *auth.RefreshStore
+	denylist           *auth.Denylist
+	resetStore         *auth.ResetStore
+	passwordPolicy     *auth.PasswordPolicy
+	passwordHasher     *auth.PasswordHasher
+	inviteSigner       *auth.InviteSigner
+	verificationSigner *auth.VerificationSigner
+	captcha            captcha.Verifier
+	loginFailures      *auth.LoginFailures
+	directory          *directory.Directory
+	mailer             mailer.Mailer
+	messaging          *messaging.Client
+	config             config.AuthConfig
+	live               *config.Live // for the settings that change on reload
 }
 
-func NewAuthHandler(storageService *services.StorageService, jwtManager *auth.JWTManager) *AuthHandler {
+func NewAuthHandler(storageService *services.StorageService, jwtManager *auth.JWTManager, refreshStore *auth.RefreshStore, denylist *auth.Denylist, resetStore *auth.ResetStore, passwordPolicy *auth.PasswordPolicy, passwordHasher *auth.PasswordHasher, inviteSigner *auth.InviteSigner, verificationSigner *auth.VerificationSigner, captchaVerifier captcha.Verifier, loginFailures *auth.LoginFailures, dir *directory.Directory, mail mailer.Mailer, messagingClient *messaging.Client, live *config.Live) *AuthHandler {
 	return &AuthHandler{
-		storageService: storageService,
-		jwtManager:     jwtManager,
+		storageService:     storageService,
+		jwtManager:         jwtManager,
+		refreshStore:       refreshStore,
+		denylist:           denylist,
+		resetStore:         resetStore,
+		passwordPolicy:     passwordPolicy,
+		passwordHasher:     passwordHasher,
+		inviteSigner:       inviteSigner,
+		verificationSigner: verificationSigner,
+		captcha:            captchaVerifier,
+		loginFailures:      loginFailures,
+		directory:          dir,
+		mailer:             mail,
+		messaging:          messagingClient,
+		config:             live.Get().Auth,
+		live:               live,
 	}
 }
 
+// inviteOnly reports whether registration needs an invitation
+func (h *AuthHandler) inviteOnly() bool {
+	return h.live.Get().Auth.InviteOnly
+}
+
+// checkPassword writes a 400 response when password breaks the policy
+func (h *AuthHandler) checkPassword(c *gin.Context, password string) bool {
+	if err := h.passwordPolicy.Check(password); err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.WeakPassword, err.Error()))
+		return false
+	}
+	return true
+}
+
+// verifyCaptcha writes a 400 response when token is not a solved CAPTCHA,
+// or a 502 response when the provider cannot be reached
+func (h *AuthHandler) verifyCaptcha(c *gin.Context, token string) bool {
+	err := h.captcha.Verify(c.Request.Context(), token, c.ClientIP())
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, captcha.ErrFailed):
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.CaptchaRequired, "Solve the CAPTCHA and try again"))
+	default:
+		requestLogger(c).Error("CAPTCHA verification failed", "error", err)
+		respondError(c, apierr.New(http.StatusBadGateway, apierr.UpstreamFailed, "Could not verify the CAPTCHA"))
+	}
+	return false
+}
+
+// loginNeedsCaptcha reports whether recent failures for loginName or the
+// client address call for a CAPTCHA before another attempt
+func (h *AuthHandler) loginNeedsCaptcha(c *gin.Context, loginName string) bool {
+	if h.captcha == nil || h.loginFailures == nil {
+		return false
+	}
+	count, err := h.loginFailures.Count(c.Request.Context(), loginName, c.ClientIP())
+	if err != nil {
+		// Without the counter every login is treated as suspicious
+		requestLogger(c).Error("Failed to load login failures", "error", err)
+		return true
+	}
+	return count >= h.config.CaptchaLoginThreshold
+}
+
+// recordLoginFailure counts a failed login towards the CAPTCHA threshold
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, loginName string) {
+	if h.loginFailures == nil {
+		return
+	}
+	if err := h.loginFailures.RecordFailure(c.Request.Context(), loginName, c.ClientIP()); err != nil {
+		requestLogger(c).Error("Failed to record login failure", "error", err)
+	}
+}
+
+// requireRevocation writes a 503 response when tokens cannot be revoked
+func (h *AuthHandler) requireRevocation(c *gin.Context) bool {
+	if h.denylist == nil || h.refreshStore == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Token revocation is not available"))
+		return false
+	}
+	return true
+}
+
+// refreshSession is the refresh token of a response. A zero token starts a
+// new token family lasting ttl; a refresh passes the token it already
+// rotated to along with its family's lifetime.
+type refreshSession struct {
+	token string
+	ttl   time.Duration
+}
+
+// newSession starts a session that lasts until its refresh token goes
+// unused for the configured session TTL, or for the longest refresh token
+// TTL when the user asked to be remembered
+func (h *AuthHandler) newSession(remember bool) refreshSession {
+	if remember {
+		return refreshSession{}
+	}
+	return refreshSession{ttl: time.Duration(h.config.SessionTTL) * time.Hour}
+}
+
+// issueTokens builds the response for a signed-in user with a new access
+// token, or turns away users that are not active
+func (h *AuthHandler) issueTokens(c *gin.Context, user *models.User, session refreshSession) (*models.AuthResponse, bool) {
+	if status := user.AccountStatus(); status != models.UserStatusActive {
+		inactiveAccountResponse(c, status)
+		return nil, false
+	}
+
+	token, err := h.jwtManager.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to generate token"))
+		return nil, false
+	}
+
+	response := &models.AuthResponse{
+		User:      user.ToUserResponse(),
+		Token:     token,
+		ExpiresIn: int(h.jwtManager.TTL().Seconds()),
+		ExpiresAt: time.Now().Add(h.jwtManager.TTL()),
+	}
+	if h.refreshStore == nil {
+		return response, true
+	}
+
+	if session.token == "" {
+		session.token, err = h.refreshStore.Issue(c.Request.Context(), user.ID, session.ttl)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to generate token"))
+			return nil, false
+		}
+	}
+	if session.ttl <= 0 || session.ttl > h.refreshStore.TTL() {
+		session.ttl = h.refreshStore.TTL()
+	}
+
+	response.RefreshToken = session.token
+	response.RefreshExpiresIn = int(session.ttl.Seconds())
+	return response, true
+}
+
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user account
+// @Description Register a new user account. Usernames cannot contain @, so they are never mistaken for email addresses at sign-in. With an invite token from an invite link the user gets the invited role; when registration is invite-only the token is required. When CAPTCHA verification is enabled a captchaToken is required.
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Param request body models.RegisterRequest true "User registration data"
 // @Success 201 {object} models.AuthResponse "User registered successfully"
-// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or CAPTCHA required"
+// @Failure 403 {object} models.ErrorResponse "Invitation required or not valid"
 // @Failure 409 {object} models.ErrorResponse "User already exists"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 502 {object} models.ErrorResponse "CAPTCHA provider unavailable"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request format",
-			Message: err.Error(),
-		})
+		respondError(c, bindError(err))
+		return
+	}
+	if h.captcha != nil && !h.verifyCaptcha(c, req.CaptchaToken) {
+		return
+	}
+	if !h.checkPassword(c, req.Password) {
 		return
 	}
 
+	var invitation *models.Invitation
+	if h.inviteOnly() || req.InviteToken != "" {
+		var ok bool
+		invitation, ok = invitationFor(c, h.storageService, h.inviteSigner, req.InviteToken, req.Email)
+		if !ok {
+			return
+		}
+	}
+
 	// Check if user already exists (by email)
 	if _, err := h.storageService.GetUserByEmail(c.Request.Context(), req.Email); err == nil {
-		c.JSON(http.StatusConflict, models.ErrorResponse{
-			Error: "User with this email already exists",
-		})
+		respondError(c, apierr.New(http.StatusConflict, apierr.EmailTaken, "User with this email already exists"))
 		return
 	}
 
 	// Check if username already exists
 	if _, err := h.storageService.GetUserByUsername(c.Request.Context(), req.Username); err == nil {
-		c.JSON(http.StatusConflict, models.ErrorResponse{
-			Error: "Username already taken",
-		})
+		respondError(c, apierr.New(http.StatusConflict, apierr.UsernameTaken, "Username already taken"))
 		return
 	}
 
 	// Hash password
-	hashedPassword, err := auth.HashPassword(req.Password)
+	hashedPassword, err := h.passwordHasher.Hash(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to process password",
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process password"))
 		return
 	}
 
@@ -75,92 +249,451 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Password:  hashedPassword,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
-		Role:      "user", // Default role
+		Role:      models.RoleUser,
+	}
+	if invitation != nil {
+		// The invite link was sent to this address
+		now := time.Now()
+		user.Role = invitation.Role
+		user.EmailVerifiedAt = &now
 	}
 
 	if err := h.storageService.CreateUser(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to create user",
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create user"))
 		return
 	}
 
-	// Generate token
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Username, user.Email, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to generate token",
-		})
+	if invitation != nil {
+		now := time.Now()
+		invitation.AcceptedAt = &now
+		invitation.AcceptedBy = user.ID
+		if err := h.storageService.SaveInvitation(c.Request.Context(), invitation); err != nil {
+			requestLogger(c).Error("Failed to mark invitation as accepted", "invitation", invitation.ID, "error", err)
+		}
+	} else {
+		// A failed email only means the user asks for another one
+		h.sendVerification(c.Request.Context(), user)
+	}
+
+	response, ok := h.issueTokens(c, user, h.newSession(false))
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		User:  user.ToUserResponse(),
-		Token: token,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate a user by username or email address and return JWT token. An identifier containing @ is matched against email addresses first. With rememberMe the refresh token stays valid for an extended session instead of a short one. After repeated failed logins a captchaToken is required when CAPTCHA verification is enabled. With LDAP enabled, users without a local account sign in with their directory credentials and get an account on their first login.
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Param request body models.LoginRequest true "User login credentials"
 // @Success 200 {object} models.AuthResponse "Login successful"
-// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or CAPTCHA required"
 // @Failure 401 {object} models.ErrorResponse "Invalid credentials"
+// @Failure 409 {object} models.ErrorResponse "A local account with the directory email address has not verified it"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 502 {object} models.ErrorResponse "CAPTCHA provider or directory unavailable"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid request format",
+		respondError(c, bindError(err))
+		return
+	}
+
+	// Get user by username or email
+	loginName := strings.TrimSpace(req.LoginName())
+	if h.loginNeedsCaptcha(c, loginName) && !h.verifyCaptcha(c, req.CaptchaToken) {
+		return
+	}
+
+	method := "password"
+	user, err := h.storageService.GetUserByLogin(c.Request.Context(), loginName)
+	switch {
+	case h.directory != nil && (err != nil || user.DirectoryDN != ""):
+		// Directory users and unknown login names are checked against the
+		// directory; local accounts keep their local password
+		var knownID, previousRole string
+		if err == nil {
+			knownID = user.ID
+		}
+		method = "ldap"
+		user, previousRole, err = h.directoryUser(c.Request.Context(), loginName, req.Password)
+		if errors.Is(err, directory.ErrInvalidCredentials) {
+			h.loginFailed(c, loginName, knownID, "wrong directory credentials")
+			return
+		}
+		if errors.Is(err, errEmailUnverified) {
+			respondError(c, apierr.New(http.StatusConflict, apierr.EmailTaken, "An account with your directory email address exists; sign in with its password and verify the address first"))
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Directory login failed", "login", loginName, "error", err)
+			respondError(c, apierr.New(http.StatusBadGateway, apierr.UpstreamFailed, "The directory is not available"))
+			return
+		}
+		if previousRole != user.Role && knownID != "" {
+			recordAudit(h.messaging, c, models.AuditEvent{
+				Type:    models.AuditRoleChange,
+				UserID:  user.ID,
+				ActorID: directoryActor,
+				Details: map[string]string{"from": previousRole, "to": user.Role},
+			})
+		}
+	case err != nil:
+		h.loginFailed(c, loginName, "", "unknown user")
+		return
+	default:
+		if err := auth.CheckPassword(req.Password, user.Password); err != nil {
+			h.loginFailed(c, loginName, user.ID, "wrong password")
+			return
+		}
+	}
+
+	response, ok := h.issueTokens(c, user, h.newSession(req.RememberMe))
+	if !ok {
+		recordAudit(h.messaging, c, models.AuditEvent{
+			Type:     models.AuditLoginFailed,
+			UserID:   user.ID,
+			Username: loginName,
+			Details:  map[string]string{"reason": "account " + user.AccountStatus()},
 		})
 		return
 	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditLogin,
+		UserID:  user.ID,
+		Details: map[string]string{"method": method},
+	})
+	if h.loginFailures != nil {
+		if err := h.loginFailures.Clear(c.Request.Context(), loginName); err != nil {
+			requestLogger(c).Error("Failed to clear login failures", "error", err)
+		}
+	}
+	if method == "password" {
+		h.rehashPassword(c.Request.Context(), user, req.Password)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
 
-	// Get user by username
-	user, err := h.storageService.GetUserByUsername(c.Request.Context(), req.Username)
+// loginFailed records a failed login of loginName, and of userID when the
+// user is known, and writes a 401 response
+func (h *AuthHandler) loginFailed(c *gin.Context, loginName, userID, reason string) {
+	h.recordLoginFailure(c, loginName)
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:     models.AuditLoginFailed,
+		UserID:   userID,
+		Username: loginName,
+		Details:  map[string]string{"reason": reason},
+	})
+	respondError(c, apierr.New(http.StatusUnauthorized, apierr.InvalidCredentials, "Invalid credentials"))
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; presenting a used one again signs out every session started from the same login.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.AuthResponse "Tokens refreshed"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Invalid or reused refresh token"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Refresh tokens not available"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	if h.refreshStore == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Refresh tokens are not available"))
+		return
+	}
+
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	userID, refreshToken, ttl, err := h.refreshStore.Rotate(c.Request.Context(), req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-			Error: "Invalid credentials",
-		})
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			requestLogger(c).Warn("Refresh token reuse detected; revoked the token family", "ip", c.ClientIP())
+			fallthrough
+		case errors.Is(err, auth.ErrInvalidRefreshToken):
+			respondError(c, apierr.New(http.StatusUnauthorized, apierr.InvalidRefreshToken, "Invalid refresh token"))
+		default:
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to refresh tokens"))
+		}
+		return
+	}
+
+	// Load the user again so role changes take effect on refresh
+	user, err := h.storageService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusUnauthorized, apierr.InvalidRefreshToken, "Invalid refresh token"))
+		return
+	}
+
+	response, ok := h.issueTokens(c, user, refreshSession{token: refreshToken, ttl: ttl})
+	if !ok {
 		return
 	}
 
-	// Check password
-	if err := auth.CheckPassword(req.Password, user.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-			Error: "Invalid credentials",
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the access token used for this request and, if given, the refresh token of the same session
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} models.SuccessResponse "Logged out"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed for service accounts"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Token revocation not available"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if forbidServiceAccount(c) || !h.requireRevocation(c) {
+		return
+	}
+
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+	}
+
+	claims := c.MustGet("claims").(*auth.Claims)
+	if err := h.denylist.Revoke(c.Request.Context(), claims); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke token"))
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.refreshStore.Revoke(c.Request.Context(), req.RefreshToken); err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke refresh token"))
+			return
+		}
+	}
+
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:   models.AuditLogout,
+		UserID: claims.UserID,
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Logged out successfully",
+	})
+}
+
+// RevokeTokens godoc
+// @Summary Revoke tokens
+// @Description Revoke a single access token, e.g. one reported as stolen, or every access and refresh token of a user (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RevokeTokensRequest true "Token or user to revoke"
+// @Success 200 {object} models.SuccessResponse "Tokens revoked"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or token"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Token revocation not available"
+// @Router /admin/tokens/revoke [post]
+func (h *AuthHandler) RevokeTokens(c *gin.Context) {
+	if !h.requireRevocation(c) {
+		return
+	}
+
+	var req models.RevokeTokensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	if req.Token != "" {
+		claims, err := h.jwtManager.ValidateToken(req.Token)
+		if err != nil {
+			// Expired or forged tokens are rejected anyway
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.TokenInvalid, "Token is not valid and needs no revocation"))
+			return
+		}
+		if err := h.denylist.Revoke(c.Request.Context(), claims); err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke token"))
+			return
+		}
+		recordAudit(h.messaging, c, models.AuditEvent{
+			Type:    models.AuditTokensRevoked,
+			UserID:  claims.UserID,
+			ActorID: c.GetString("userID"),
+			Details: map[string]string{"scope": "token"},
 		})
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminTokensRevoke,
+			TargetType: models.AdminTargetUser,
+			TargetID:   claims.UserID,
+		}, nil, map[string]string{"scope": "token", "tokenId": claims.ID})
+	}
+
+	if req.UserID != "" {
+		err := h.denylist.RevokeUser(c.Request.Context(), req.UserID, time.Now())
+		if err == nil {
+			err = h.refreshStore.RevokeUser(c.Request.Context(), req.UserID)
+		}
+		if err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke user tokens"))
+			return
+		}
+		recordAudit(h.messaging, c, models.AuditEvent{
+			Type:    models.AuditTokensRevoked,
+			UserID:  req.UserID,
+			ActorID: c.GetString("userID"),
+			Details: map[string]string{"scope": "user"},
+		})
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminTokensRevoke,
+			TargetType: models.AdminTargetUser,
+			TargetID:   req.UserID,
+		}, nil, map[string]string{"scope": "user"})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tokens revoked successfully",
+	})
+}
+
+// Impersonate godoc
+// @Summary Impersonate a user
+// @Description Get a short-lived access token to act as a user, e.g. to reproduce a problem they report. The token names the admin in its act claim, cannot be refreshed, and everything done with it is audited as done by the admin. Users that can manage users, or that hold a permission the caller lacks, cannot be impersonated.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path string true "User ID"
+// @Param request body models.ImpersonateRequest false "Reason for the audit log"
+// @Success 200 {object} models.AuthResponse "Impersonation token"
+// @Failure 400 {object} models.ErrorResponse "Invalid request or user not active"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/impersonate/{userId} [post]
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	if forbidImpersonation(c) || forbidServiceAccount(c) {
 		return
 	}
 
-	// Generate token
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	var req models.ImpersonateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+	}
+
+	adminID := c.GetString("userID")
+	if c.Param("userId") == adminID {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Cannot impersonate yourself"))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), c.Param("userId"))
+	if errors.Is(err, services.ErrUserNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to generate token",
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to load user"))
+		return
+	}
+	if status := user.AccountStatus(); status != models.UserStatusActive {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Cannot impersonate a "+status+" account"))
 		return
 	}
 
+	// Acting as another admin, or as anyone holding a permission the
+	// impersonator lacks, would hand over those permissions
+	role, err := h.storageService.CachedRole(c.Request.Context(), user.Role)
+	if err != nil && !errors.Is(err, services.ErrRoleNotFound) {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to load user role"))
+		return
+	}
+	if role != nil && role.Has(models.PermUsersAdmin) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.Forbidden, "Users that manage users cannot be impersonated"))
+		return
+	}
+	if role != nil && !holdsRole(c, role) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.Forbidden, "Cannot impersonate a user holding permissions you lack"))
+		return
+	}
+
+	ttl := min(time.Duration(h.config.ImpersonationTTL)*time.Minute, h.jwtManager.TTL())
+	actor := auth.Actor{UserID: adminID, Username: c.GetString("username")}
+	token, err := h.jwtManager.GenerateImpersonationToken(user.ID, user.Username, user.Email, user.Role, actor, ttl)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to generate token"))
+		return
+	}
+
+	details := map[string]string{}
+	if req.Reason != "" {
+		details["reason"] = req.Reason
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditImpersonation,
+		UserID:  user.ID,
+		ActorID: adminID,
+		Details: details,
+	})
+	details["expiresAt"] = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminImpersonate,
+		TargetType: models.AdminTargetUser,
+		TargetID:   user.ID,
+	}, nil, details)
+
 	c.JSON(http.StatusOK, models.AuthResponse{
-		User:  user.ToUserResponse(),
-		Token: token,
+		User:      user.ToUserResponse(),
+		Token:     token,
+		ExpiresIn: int(ttl.Seconds()),
+		ExpiresAt: time.Now().Add(ttl),
 	})
 }
 
+// JWKS godoc
+// @Summary Get token verification keys
+// @Description Get the public keys access tokens are signed with as a JSON Web Key Set, so that other services can validate tokens without sharing a secret. The set is empty when tokens are signed with a shared secret.
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} auth.JSONWebKeySet "Key set"
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	// Verifiers fetch the set again when they meet an unknown key ID
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
+
 // GetProfile godoc
 // @Summary Get user profile
-// @Description Get current user's profile information
+// @Description Get current user's profile information with their settings
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.SuccessResponse{data=models.User} "Profile retrieved successfully"
+// @Success 200 {object} models.SuccessResponse{data=models.UserResponse} "Profile retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 404 {object} models.ErrorResponse "User not found"
 // @Router /profile [get]
@@ -169,59 +702,174 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 	user, err := h.storageService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error: "User not found",
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+	settings, err := h.storageService.GetUserSettings(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get settings"))
 		return
 	}
 
+	profile := user.ToUserResponse()
+	profile.Settings = settings
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Profile retrieved successfully",
-		Data:    user.ToUserResponse(),
+		Data:    profile,
 	})
 }
 
+// GetLoginHistory godoc
+// @Summary Get login history
+// @Description Get the current user's recent sign-in attempts, successful and failed, newest first
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.LoginRecord} "Login history retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/logins [get]
+func (h *AuthHandler) GetLoginHistory(c *gin.Context) {
+	loginHistoryResponse(c, h.storageService, c.GetString("userID"))
+}
+
+// loginHistoryResponse writes the login history of userID
+func loginHistoryResponse(c *gin.Context, storageService *services.StorageService, userID string) {
+	history, err := storageService.GetLoginHistory(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get login history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Login history retrieved successfully",
+		Data:    history,
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the current user's password. Every session of the account is signed out, including this one, so the client has to log in again.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.SuccessResponse "Password changed"
+// @Failure 400 {object} models.ErrorResponse "Invalid request, wrong current password or weak new password"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed while impersonating"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Token revocation not available"
+// @Router /profile/change-password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	if forbidImpersonation(c) || forbidServiceAccount(c) || !h.requireRevocation(c) {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	// Accounts created through social login have no password to confirm;
+	// they set one with a password reset
+	if user.Password == "" || auth.CheckPassword(req.CurrentPassword, user.Password) != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.PasswordIncorrect, "Current password is incorrect"))
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.PasswordUnchanged, "New password must differ from the current one"))
+		return
+	}
+	if !h.checkPassword(c, req.NewPassword) {
+		return
+	}
+
+	hashedPassword, err := h.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process password"))
+		return
+	}
+	user.Password = hashedPassword
+
+	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to change password"))
+		return
+	}
+
+	h.revokeSessions(c.Request.Context(), user.ID)
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:   models.AuditPasswordChange,
+		UserID: user.ID,
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Password changed successfully; please log in again",
+	})
+}
+
+// rehashPassword replaces the stored hash of user, whose password was just
+// verified, when it was made with other hashing settings. Failures are
+// only logged; the old hash keeps working.
+func (h *AuthHandler) rehashPassword(ctx context.Context, user *models.User, password string) {
+	if !h.passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := h.passwordHasher.Hash(password)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to rehash password", "userId", user.ID, "error", err)
+		return
+	}
+	user.Password = hashedPassword
+	if err := h.storageService.UpdateUser(ctx, user); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to store rehashed password", "userId", user.ID, "error", err)
+	}
+}
+
+// revokeSessions signs out every session of userID after its password
+// changed. Failures are only logged as the password change already took
+// effect.
+func (h *AuthHandler) revokeSessions(ctx context.Context, userID string) {
+	if err := h.denylist.RevokeUser(ctx, userID, time.Now()); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to revoke access tokens", "userId", userID, "error", err)
+	}
+	if err := h.refreshStore.RevokeUser(ctx, userID); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to revoke refresh tokens", "userId", userID, "error", err)
+	}
+}
+
 func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetString("userID")
 
-	var updates models.User
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
 		return
 	}
 
 	// Get existing user
 	user, err := h.storageService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "User not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
 		return
 	}
 
-	// Update allowed fields
-	if updates.FirstName != "" {
-		user.FirstName = updates.FirstName
-	}
-	if updates.LastName != "" {
-		user.LastName = updates.LastName
-	}
-	if updates.Avatar != "" {
-		user.Avatar = updates.Avatar
-	}
+	// The role is not the user's own to change
+	req.Apply(user)
 
 	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to update user",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update user"))
 		return
 	}
 
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonate(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	userPermissions := models.DefaultRoles[models.RoleUser].Permissions
+	for _, role := range []*models.Role{
+		{Name: "support", Permissions: append([]string{models.PermUsersImpersonate}, userPermissions...)},
+		{Name: "auditor", Permissions: append([]string{models.PermAuditRead}, userPermissions...)},
+	} {
+		require.NoError(t, api.storage.SaveRole(ctx, role))
+	}
+	_, supportToken := api.user("support", "support")
+	auditor, _ := api.user("auditor", "auditor")
+	regular, _ := api.user("regular", models.RoleUser)
+
+	// The auditor's audit:read would be handed to support
+	w := api.do(http.MethodPost, "/api/v1/admin/impersonate/"+auditor.ID, supportToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	w = api.do(http.MethodPost, "/api/v1/admin/impersonate/"+regular.ID, supportToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, regular.ID, response.User.ID)
+}
+
+func TestChangePassword(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	const path = "/api/v1/profile/change-password"
+
+	user, token := api.user("alice", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+
+	login := func(password string) *models.AuthResponse {
+		t.Helper()
+		w := api.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"identifier": user.Username, "password": password})
+		if w.Code != http.StatusOK {
+			return nil
+		}
+		var response models.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return &response
+	}
+	// Another session, signed in on another device
+	other := login(testPassword)
+	require.NotNil(t, other)
+
+	change := func(token, current, next string) *httptest.ResponseRecorder {
+		return api.do(http.MethodPost, path, token, map[string]string{"currentPassword": current, "newPassword": next})
+	}
+
+	w := change(token, "Wrong-Horse-9", "Battery-Staple-42")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, string(apierr.PasswordIncorrect), errorCode(t, w))
+
+	w = change(token, testPassword, testPassword)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, string(apierr.PasswordUnchanged), errorCode(t, w))
+
+	w = change(token, testPassword, "short")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, string(apierr.WeakPassword), errorCode(t, w))
+
+	// Admins acting as the user and service accounts cannot change it
+	w = api.do(http.MethodPost, "/api/v1/admin/impersonate/"+user.ID, adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var impersonation models.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &impersonation))
+	w = change(impersonation.Token, testPassword, "Battery-Staple-42")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, string(apierr.ImpersonationForbidden), errorCode(t, w))
+
+	key, err := api.storage.CreateServiceAccount(ctx, &models.ServiceAccount{Name: "backup", Permissions: []string{models.PermFilesRead}})
+	require.NoError(t, err)
+	w = change(key, testPassword, "Battery-Staple-42")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, string(apierr.ServiceAccountDenied), errorCode(t, w))
+
+	// None of the refused attempts changed it
+	require.NotNil(t, login(testPassword))
+
+	w = change(token, testPassword, "Battery-Staple-42")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Every session is signed out, including the one that changed it
+	for _, token := range []string{token, other.Token} {
+		w = api.do(http.MethodGet, "/api/v1/profile", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, string(apierr.TokenRevoked), errorCode(t, w))
+	}
+	w = api.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": other.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Nil(t, login(testPassword))
+	signedIn := login("Battery-Staple-42")
+	require.NotNil(t, signedIn)
+	w = api.do(http.MethodGet, "/api/v1/profile", signedIn.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestEraseAccount(t *testing.T) {
+	api := newTestAPI(t)
+
+	login := func(username string) *models.AuthResponse {
+		t.Helper()
+		w := api.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"identifier": username, "password": testPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response models.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return &response
+	}
+	signedOut := func(session *models.AuthResponse) {
+		t.Helper()
+		w := api.do(http.MethodGet, "/api/v1/profile", session.Token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = api.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": session.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// Users erase themselves with their password
+	alice, _ := api.user("alice", models.RoleUser)
+	session := login(alice.Username)
+	w := api.do(http.MethodPost, "/api/v1/profile/erasure", session.Token, map[string]string{"mode": models.ErasurePurge, "password": "Wrong-Horse-9"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, string(apierr.PasswordIncorrect), errorCode(t, w))
+
+	w = api.do(http.MethodPost, "/api/v1/profile/erasure", session.Token, map[string]string{"mode": models.ErasurePurge, "password": testPassword})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result models.ErasureResult
+	decode(t, w, &result)
+	assert.Equal(t, models.ErasurePurge, result.Mode)
+	signedOut(session)
+	_, err := api.storage.GetUser(context.Background(), alice.ID)
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+
+	// Admins erase others, whose sessions end too
+	bob, _ := api.user("bob", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+	session = login(bob.Username)
+	w = api.do(http.MethodPost, "/api/v1/admin/users/"+bob.ID+"/erasure", session.Token, map[string]string{"mode": models.ErasureAnonymize})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = api.do(http.MethodPost, "/api/v1/admin/users/"+bob.ID+"/erasure", adminToken, map[string]string{"mode": models.ErasureAnonymize})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &result)
+	assert.Equal(t, models.ErasureAnonymize, result.Mode)
+	signedOut(session)
+
+	w = api.do(http.MethodPost, "/api/v1/admin/users/"+bob.ID+"/erasure", adminToken, map[string]string{"mode": models.ErasureAnonymize})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
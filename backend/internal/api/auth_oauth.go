@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/oauth"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthCookiePath  = "/api/v1/auth/oauth"
+	oauthStateMaxAge = 600 // seconds to finish signing in at the provider
+)
+
+// errRegistrationClosed stops social login from creating accounts when
+// registration is invite-only
+var errRegistrationClosed = errors.New("registration is invite-only")
+
+// errEmailUnverified stops an external login from taking over a local
+// account with the same email address before its owner proved they have it:
+// anyone can register an address they do not own
+var errEmailUnverified = errors.New("an account with the email address exists but has not verified it")
+
+// OAuthHandler signs users in with social login providers and issues the
+// same tokens as a password login
+type OAuthHandler struct {
+	authHandler *AuthHandler
+	providers   map[string]*oauth.Provider
+	successURL  string
+}
+
+func NewOAuthHandler(authHandler *AuthHandler, providers map[string]*oauth.Provider, successURL string) *OAuthHandler {
+	return &OAuthHandler{
+		authHandler: authHandler,
+		providers:   providers,
+		successURL:  successURL,
+	}
+}
+
+// provider writes a 404 response when the provider is not configured
+func (h *OAuthHandler) provider(c *gin.Context) (*oauth.Provider, bool) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.ProviderNotFound, "Unknown login provider"))
+		return nil, false
+	}
+	return provider, true
+}
+
+// OAuthLogin godoc
+// @Summary Start social login
+// @Description Redirect the browser to the provider's sign-in page
+// @Tags authentication
+// @Param provider path string true "Login provider (google, github)"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} models.ErrorResponse "Unknown login provider"
+// @Router /auth/oauth/{provider} [get]
+func (h *OAuthHandler) OAuthLogin(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to start login"))
+		return
+	}
+	state := hex.EncodeToString(buf)
+	verifier := oauth2.GenerateVerifier()
+
+	// The state ties the callback to this browser; the PKCE verifier ties
+	// the code to this login
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state+"."+verifier, oauthStateMaxAge, oauthCookiePath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, verifier))
+}
+
+// OAuthCallback godoc
+// @Summary Finish social login
+// @Description Exchange the provider's code, sign in the user with the same verified email or create one, and redirect to the frontend with the tokens in the URL fragment (token, refreshToken, expiresIn). Responds with the tokens as JSON when no frontend URL is configured.
+// @Tags authentication
+// @Produce json
+// @Param provider path string true "Login provider (google, github)"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} models.AuthResponse "Login successful"
+// @Success 302 "Redirect to the frontend"
+// @Failure 400 {object} models.ErrorResponse "Invalid callback"
+// @Failure 403 {object} models.ErrorResponse "Provider account has no verified email, or registration is invite-only"
+// @Failure 404 {object} models.ErrorResponse "Unknown login provider"
+// @Failure 409 {object} models.ErrorResponse "A local account with the email address has not verified it"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 502 {object} models.ErrorResponse "Provider request failed"
+// @Router /auth/oauth/{provider}/callback [get]
+func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	if reason := c.Query("error"); reason != "" {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.LoginFailed, "Login was not completed: "+reason))
+		return
+	}
+
+	cookie, _ := c.Cookie(oauthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, oauthCookiePath, "", c.Request.TLS != nil, true)
+
+	state, verifier, _ := strings.Cut(cookie, ".")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.LoginFailed, "Login state is missing or does not match"))
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), verifier)
+	if errors.Is(err, oauth.ErrEmailNotVerified) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.LoginFailed, "Your "+provider.Name()+" account has no verified email address"))
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("OAuth login failed", "provider", provider.Name(), "error", err)
+		respondError(c, apierr.New(http.StatusBadGateway, apierr.UpstreamFailed, "Failed to complete login with "+provider.Name()))
+		return
+	}
+
+	user, err := h.linkUser(c.Request.Context(), identity)
+	if errors.Is(err, errRegistrationClosed) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.InvitationRequired, "Registration requires an invitation; register with your invite link first"))
+		return
+	}
+	if errors.Is(err, errEmailUnverified) {
+		respondError(c, apierr.New(http.StatusConflict, apierr.EmailTaken, "An account with this email address exists; sign in with its password and verify the address to link "+provider.Name()))
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to sign in OAuth identity", "provider", identity.Provider, "subject", identity.Subject, "error", err)
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to sign in"))
+		return
+	}
+
+	// Social logins are remembered as there is no form to ask on
+	response, ok := h.authHandler.issueTokens(c, user, h.authHandler.newSession(true))
+	if !ok {
+		recordAudit(h.authHandler.messaging, c, models.AuditEvent{
+			Type:    models.AuditLoginFailed,
+			UserID:  user.ID,
+			Details: map[string]string{"reason": "account " + user.AccountStatus(), "method": identity.Provider},
+		})
+		return
+	}
+	recordAudit(h.authHandler.messaging, c, models.AuditEvent{
+		Type:    models.AuditLogin,
+		UserID:  user.ID,
+		Details: map[string]string{"method": identity.Provider},
+	})
+
+	if h.successURL == "" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// The fragment keeps the tokens out of server and proxy logs
+	fragment := url.Values{
+		"token":     {response.Token},
+		"expiresIn": {strconv.Itoa(response.ExpiresIn)},
+	}
+	if response.RefreshToken != "" {
+		fragment.Set("refreshToken", response.RefreshToken)
+	}
+	c.Redirect(http.StatusFound, h.successURL+"#"+fragment.Encode())
+}
+
+// linkUser returns the user with the identity's verified email, recording
+// the link, or creates a new user without a password unless registration
+// is invite-only. A local account is only linked once its owner verified
+// the address; the provider vouching for it says nothing about who
+// registered the local account.
+func (h *OAuthHandler) linkUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	storageService := h.authHandler.storageService
+
+	if user, err := storageService.GetUserByEmail(ctx, identity.Email); err == nil {
+		if user.OAuthIdentities[identity.Provider] == identity.Subject {
+			return user, nil
+		}
+		if user.EmailVerifiedAt == nil {
+			return nil, errEmailUnverified
+		}
+		if user.OAuthIdentities == nil {
+			user.OAuthIdentities = make(map[string]string)
+		}
+		user.OAuthIdentities[identity.Provider] = identity.Subject
+		if err := storageService.UpdateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if h.authHandler.inviteOnly() {
+		return nil, errRegistrationClosed
+	}
+
+	username, err := availableUsername(ctx, storageService, identity.Username, identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:        username,
+		Email:           identity.Email,
+		FirstName:       identity.FirstName,
+		LastName:        identity.LastName,
+		Role:            "user",
+		OAuthIdentities: map[string]string{identity.Provider: identity.Subject},
+		EmailVerifiedAt: &now,
+	}
+	if err := storageService.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// availableUsername derives a username from an external login name or the
+// email address, adding a random suffix while it is taken
+func availableUsername(ctx context.Context, storageService *services.StorageService, login, email string) (string, error) {
+	base := login
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return -1
+	}, base)
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for range 5 {
+		if _, err := storageService.GetUserByUsername(ctx, username); err != nil {
+			return username, nil
+		}
+		buf := make([]byte, 3)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		username = base + "-" + hex.EncodeToString(buf)
+	}
+	return "", errors.New("no free username for " + base)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthLinkUser(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	h := &OAuthHandler{authHandler: &AuthHandler{storageService: api.storage}}
+
+	// Someone registered the victim's address without verifying it
+	squatter, _ := api.user("squatter", models.RoleUser)
+	identity := &oauth.Identity{Provider: "google", Subject: "g-1", Email: squatter.Email}
+	_, err := h.linkUser(ctx, identity)
+	assert.ErrorIs(t, err, errEmailUnverified)
+	stored, err := api.storage.GetUser(ctx, squatter.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.EmailVerifiedAt)
+	assert.Empty(t, stored.OAuthIdentities)
+
+	// A verified account is linked
+	now := time.Now()
+	stored.EmailVerifiedAt = &now
+	require.NoError(t, api.storage.UpdateUser(ctx, stored))
+	linked, err := h.linkUser(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, squatter.ID, linked.ID)
+	assert.Equal(t, "g-1", linked.OAuthIdentities["google"])
+}
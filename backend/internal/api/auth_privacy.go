@@ -0,0 +1,276 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// ExportData godoc
+// @Summary Export personal data
+// @Description Stream a ZIP archive of everything stored about the current user: profile, posts, login history, file metadata and file contents. Files that are quarantined or still being scanned are listed without their content.
+// @Tags authentication
+// @Produce application/zip
+// @Security BearerAuth
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed for service accounts"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/data-export [post]
+func (h *AuthHandler) ExportData(c *gin.Context) {
+	if forbidServiceAccount(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	userID := c.GetString("userID")
+
+	user, err := h.storageService.GetUser(ctx, userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	// Everything but file contents is gathered before the first byte is
+	// written, since errors can no longer be reported once streaming starts
+	posts, err := h.storageService.ListUserPosts(ctx, userID)
+	var logins []*models.LoginRecord
+	if err == nil {
+		logins, err = h.storageService.GetLoginHistory(ctx, userID)
+	}
+	var files []*models.File
+	if err == nil {
+		files, err = h.storageService.ListUserFiles(ctx, userID)
+	}
+	profile := user.ToUserResponse()
+	if err == nil {
+		profile.Settings, err = h.storageService.GetUserSettings(ctx, userID)
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to collect data for export"))
+		return
+	}
+
+	h.writeDataExport(c, user, profile, posts, logins, files)
+}
+
+func (h *AuthHandler) writeDataExport(c *gin.Context, user *models.User, profile *models.UserResponse, posts []*models.Post, logins []*models.LoginRecord, files []*models.File) {
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditDataExport,
+		UserID:  user.ID,
+		Details: map[string]string{"posts": strconv.Itoa(len(posts)), "files": strconv.Itoa(len(files))},
+	})
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": user.Username + "-data.zip"}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	documents := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"logins.json", logins},
+		{"files.json", files},
+	}
+	for _, document := range documents {
+		if err := writeJSONEntry(archive, document.name, document.data); err != nil {
+			requestLogger(c).Error("Failed to add document to data export", "document", document.name, "userId", user.ID, "error", err)
+			c.Abort()
+			return
+		}
+	}
+
+	names := newZipEntryNames()
+	for _, file := range files {
+		if !file.IsDownloadable() {
+			continue
+		}
+		if err := h.writeExportedFile(c, archive, "files/"+names.next(file), file); err != nil {
+			// Headers are already sent; abort so the client sees a truncated archive
+			requestLogger(c).Error("Failed to add file to data export", "file", file.ID, "userId", user.ID, "error", err)
+			c.Abort()
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		requestLogger(c).Error("Failed to finish data export", "userId", user.ID, "error", err)
+	}
+}
+
+func (h *AuthHandler) writeExportedFile(c *gin.Context, archive *zip.Writer, name string, file *models.File) error {
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), file.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+func writeJSONEntry(archive *zip.Writer, name string, data any) error {
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// EraseAccount godoc
+// @Summary Erase own account
+// @Description Delete the current user with their files and login history. With mode purge their posts are deleted too; with anonymize they are kept and attributed to "deleted-user". Users with a password must confirm it. All sessions end.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.EraseAccountRequest true "Erasure mode and password"
+// @Success 200 {object} models.SuccessResponse{data=models.ErasureResult} "Account erased"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or password"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed while impersonating"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Token revocation not available"
+// @Router /profile/erasure [post]
+func (h *AuthHandler) EraseAccount(c *gin.Context) {
+	if forbidImpersonation(c) || forbidServiceAccount(c) || !h.requireRevocation(c) {
+		return
+	}
+
+	var req models.EraseAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	// Accounts from social or directory login have no password; signing
+	// in has to do
+	if user.Password != "" && auth.CheckPassword(req.Password, user.Password) != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.PasswordIncorrect, "Password is incorrect"))
+		return
+	}
+
+	h.eraseUser(c, user.ID, req.Mode)
+}
+
+// EraseUser godoc
+// @Summary Erase user
+// @Description Delete a user with their files and login history on their request (admin only). With mode purge their posts are deleted too; with anonymize they are kept and attributed to "deleted-user". The erasure is recorded in the audit log.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.EraseAccountRequest true "Erasure mode; password is ignored"
+// @Success 200 {object} models.SuccessResponse{data=models.ErasureResult} "Account erased"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Token revocation not available"
+// @Router /admin/users/{id}/erasure [post]
+func (h *AuthHandler) EraseUser(c *gin.Context) {
+	if !h.requireRevocation(c) {
+		return
+	}
+
+	var req models.EraseAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	h.eraseUser(c, user.ID, req.Mode)
+}
+
+// eraseUser ends the sessions of userID, then erases their data
+func (h *AuthHandler) eraseUser(c *gin.Context, userID, mode string) {
+	ctx := c.Request.Context()
+
+	err := h.denylist.RevokeUser(ctx, userID, time.Now())
+	if err == nil {
+		err = h.refreshStore.RevokeUser(ctx, userID)
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to end sessions"))
+		return
+	}
+
+	result, err := h.storageService.EraseUser(ctx, userID, mode)
+	if err != nil {
+		requestLogger(c).Error("Failed to erase user", "userId", userID, "error", err)
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to erase account; it can be retried"))
+		return
+	}
+
+	// Erasures stay in the audit log, which holds no more than IDs
+	actorID := c.GetString("userID")
+	if actorID == userID {
+		actorID = ""
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditErasure,
+		UserID:  userID,
+		ActorID: actorID,
+		Details: map[string]string{
+			"mode":            result.Mode,
+			"postsDeleted":    strconv.Itoa(result.PostsDeleted),
+			"postsAnonymized": strconv.Itoa(result.PostsAnonymized),
+			"filesDeleted":    strconv.Itoa(result.FilesDeleted),
+		},
+	})
+	if actorID != "" {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminUserErase,
+			TargetType: models.AdminTargetUser,
+			TargetID:   userID,
+		}, nil, result)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Account erased",
+		Data:    result,
+	})
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// resetRateWindow is the period ResetRateLimit applies to
+const resetRateWindow = time.Hour
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link to the account with the given address. The response is the same whether or not the address belongs to an account.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} models.SuccessResponse "Reset link sent if the account exists"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 429 {object} models.ErrorResponse "Too many reset requests"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Password reset not available"
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	if !h.requireResets(c) {
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+
+	allowed, err := h.resetStore.Allow(c.Request.Context(), "ip:"+c.ClientIP(), h.config.ResetRateLimit, resetRateWindow)
+	if err != nil {
+		requestLogger(c).Error("Failed to rate limit password reset", "error", err)
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process request"))
+		return
+	}
+	if !allowed {
+		c.Header("Retry-After", "3600")
+		respondError(c, apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Too many password reset requests, try again later"))
+		return
+	}
+
+	// An address over its limit is answered like any other so the limit
+	// does not reveal which addresses have accounts
+	allowed, err = h.resetStore.Allow(c.Request.Context(), "email:"+strings.ToLower(email), h.config.ResetRateLimit, resetRateWindow)
+	if err != nil {
+		requestLogger(c).Error("Failed to rate limit password reset", "error", err)
+	}
+	if err == nil && allowed {
+		// Looking up the account and sending the email happen after the
+		// response, so its timing does not reveal whether the account exists
+		go h.sendPasswordReset(context.WithoutCancel(c.Request.Context()), email)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "If an account with this email exists, a password reset link has been sent",
+	})
+}
+
+// sendPasswordReset emails a reset link to the account with email, if any
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	user, err := h.storageService.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	token, err := h.resetStore.Issue(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to issue password reset token", "userId", user.ID, "error", err)
+		return
+	}
+
+	link := h.config.ResetURL + "?token=" + url.QueryEscape(token)
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, user.Email, mailer.PasswordResetData{
+		Name:     user.FirstName,
+		Username: user.Username,
+		Link:     link,
+		ValidFor: h.resetStore.TTL().String(),
+	})
+	if err == nil {
+		err = h.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to send password reset email", "userId", user.ID, "error", err)
+	}
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from a reset email. The token can be used once, and every existing session of the account is signed out.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} models.SuccessResponse "Password reset"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or token"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Password reset not available"
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	if !h.requireResets(c) {
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	// Checked before the token is used up so the user can try again
+	if !h.checkPassword(c, req.Password) {
+		return
+	}
+
+	userID, err := h.resetStore.Consume(c.Request.Context(), req.Token)
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidResetToken, "Reset token is invalid or has expired"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to reset password"))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidResetToken, "Reset token is invalid or has expired"))
+		return
+	}
+
+	hashedPassword, err := h.passwordHasher.Hash(req.Password)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process password"))
+		return
+	}
+	user.Password = hashedPassword
+
+	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to reset password"))
+		return
+	}
+
+	// Whoever knew the old password may still hold tokens
+	h.revokeSessions(c.Request.Context(), user.ID)
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:   models.AuditPasswordReset,
+		UserID: user.ID,
+	})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Password reset successfully",
+	})
+}
+
+// requireResets writes a 503 response when password resets are unavailable
+func (h *AuthHandler) requireResets(c *gin.Context) bool {
+	if h.resetStore == nil || h.denylist == nil || h.refreshStore == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Password reset is not available"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the email address of an account with the token from a verification email. Tokens stop working when they expire or the address changes.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} models.SuccessResponse "Email address verified"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or token"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	userID, email, err := h.verificationSigner.Verify(req.Token)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidVerifyToken, "Verification token is invalid or has expired"))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), userID)
+	if err != nil || user.Email != email {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidVerifyToken, "Verification token is invalid or has expired"))
+		return
+	}
+
+	// Opening the link twice is not an error
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to verify email address"))
+			return
+		}
+		recordAudit(h.messaging, c, models.AuditEvent{
+			Type:    models.AuditEmailVerified,
+			UserID:  user.ID,
+			Details: map[string]string{"email": user.Email},
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Email address verified",
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Email a new verification link to the current user's address
+// @Tags authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.SuccessResponse "Verification email sent"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 409 {object} models.ErrorResponse "Email address already verified"
+// @Failure 429 {object} models.ErrorResponse "Too many verification emails"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/verify-email [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user, err := h.storageService.GetUser(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, storageError(err, "Failed to load user"))
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.EmailAlreadyVerified, "Email address is already verified"))
+		return
+	}
+
+	// Verification emails share the hourly limit of reset emails
+	if h.resetStore != nil {
+		allowed, err := h.resetStore.Allow(c.Request.Context(), "verify:"+user.ID, h.config.ResetRateLimit, resetRateWindow)
+		if err != nil {
+			requestLogger(c).Error("Failed to rate limit verification email", "error", err)
+		}
+		if err == nil && !allowed {
+			c.Header("Retry-After", "3600")
+			respondError(c, apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Too many verification emails, try again later"))
+			return
+		}
+	}
+
+	if err := h.sendVerification(c.Request.Context(), user); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to send verification email"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Message: "Verification email sent",
+	})
+}
+
+// sendVerification emails user a link confirming their address
+func (h *AuthHandler) sendVerification(ctx context.Context, user *models.User) error {
+	link := h.config.VerifyURL + "?token=" + url.QueryEscape(h.verificationSigner.Sign(user.ID, user.Email))
+	msg, err := mailer.Render(mailer.TemplateEmailVerification, user.Email, mailer.EmailVerificationData{
+		Name:     user.FirstName,
+		Username: user.Username,
+		Email:    user.Email,
+		Link:     link,
+		ValidFor: h.verificationSigner.TTL().String(),
+	})
+	if err == nil {
+		err = h.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to send verification email", "userId", user.ID, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/thumbnail"
+)
+
+// maxAvatarPixels bounds the images decoded for avatars, so a small file
+// cannot unpack into gigabytes
+const maxAvatarPixels = 50_000_000
+
+// AvatarHandler stores profile pictures. Uploads are cropped to a square
+// and scaled to thumbnail.AvatarSizes; everyone can fetch them, so they
+// show up wherever their user does.
+type AvatarHandler struct {
+	storageService *services.StorageService
+	maxSize        int64
+}
+
+func NewAvatarHandler(storageService *services.StorageService, maxSize int64) *AvatarHandler {
+	return &AvatarHandler{
+		storageService: storageService,
+		maxSize:        maxSize,
+	}
+}
+
+// UploadAvatar godoc
+// @Summary Upload an avatar
+// @Description Upload a JPEG, PNG, GIF or WebP image as the caller's avatar. It is center-cropped to a square and stored in every avatar size; the profile's avatar then points to GET /public/avatars/{id}.
+// @Tags profile
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param avatar formData file true "Image"
+// @Success 200 {object} models.SuccessResponse{data=models.UserResponse} "Avatar updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Missing or unreadable image"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 413 {object} models.ErrorResponse "Image exceeds AVATAR_MAX_SIZE"
+// @Failure 415 {object} models.ErrorResponse "Not a supported image type"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/avatar [post]
+func (h *AvatarHandler) UploadAvatar(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if c.Request.ContentLength > h.maxSize+multipartOverhead {
+		tooLargeResponse(c, h.maxSize)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+multipartOverhead)
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			tooLargeResponse(c, h.maxSize)
+			return
+		}
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Image is required"))
+		return
+	}
+	if header.Size > h.maxSize {
+		tooLargeResponse(c, h.maxSize)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to read image"))
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to read image"))
+		return
+	}
+
+	// The content decides the type, not what the client claims
+	if !thumbnail.IsSupported(http.DetectContentType(data)) {
+		respondError(c, apierr.New(http.StatusUnsupportedMediaType, apierr.FileTypeNotAllowed, "Avatars must be JPEG, PNG, GIF or WebP images"))
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to decode image"))
+		return
+	}
+	if config.Width*config.Height > maxAvatarPixels {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Image has more than "+strconv.Itoa(maxAvatarPixels)+" pixels"))
+		return
+	}
+	src, err := thumbnail.Decode(bytes.NewReader(data))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to decode image"))
+		return
+	}
+
+	images := make(map[string][]byte, len(thumbnail.AvatarSizes))
+	for size, edge := range thumbnail.AvatarSizes {
+		if images[size], err = thumbnail.Square(src, edge); err != nil {
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to resize image"))
+			return
+		}
+	}
+
+	userID := c.GetString("userID")
+	user, err := h.storageService.GetUser(ctx, userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+	if err := h.storageService.StoreAvatar(ctx, userID, thumbnail.ContentType, images); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to store avatar"))
+		return
+	}
+
+	// The version makes clients and caches fetch the new images
+	user.Avatar = "/api/v1/public/avatars/" + url.PathEscape(userID) + "?v=" + strconv.FormatInt(time.Now().Unix(), 10)
+	if err := h.storageService.UpdateUser(ctx, user); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Avatar updated successfully",
+		Data:    user.ToUserResponse(),
+	})
+}
+
+// DeleteAvatar godoc
+// @Summary Remove the avatar
+// @Description Remove the caller's uploaded avatar and clear the avatar of their profile
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.UserResponse} "Avatar removed successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/avatar [delete]
+func (h *AvatarHandler) DeleteAvatar(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetString("userID")
+
+	user, err := h.storageService.GetUser(ctx, userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+	if err := h.storageService.DeleteAvatar(ctx, userID); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to remove avatar"))
+		return
+	}
+	user.Avatar = ""
+	if err := h.storageService.UpdateUser(ctx, user); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Avatar removed successfully",
+		Data:    user.ToUserResponse(),
+	})
+}
+
+// GetAvatar godoc
+// @Summary Get an avatar
+// @Description Get the uploaded avatar of a user, without signing in
+// @Tags public
+// @Produce image/jpeg
+// @Param id path string true "User ID"
+// @Param size query string false "Avatar size (small, medium, large)" default(medium)
+// @Success 200 {file} binary "Avatar image"
+// @Success 304 "Not modified"
+// @Failure 400 {object} models.ErrorResponse "Invalid size"
+// @Failure 404 {object} models.ErrorResponse "Avatar not found"
+// @Failure 429 {object} models.ErrorResponse "Too many requests"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /public/avatars/{id} [get]
+func (h *AvatarHandler) GetAvatar(c *gin.Context) {
+	size := c.DefaultQuery("size", "medium")
+	if _, ok := thumbnail.AvatarSizes[size]; !ok {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Size must be one of small, medium or large"))
+		return
+	}
+
+	content, etag, err := h.storageService.GetAvatar(c.Request.Context(), c.Param("id"), size)
+	if errors.Is(err, services.ErrAvatarNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.NotFound, "Avatar not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get avatar"))
+		return
+	}
+	defer content.Close()
+
+	etag = `"` + etag + `"`
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.DataFromReader(http.StatusOK, -1, thumbnail.ContentType, content, nil)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// BookmarkPost godoc
+// @Summary Bookmark a post
+// @Description Bookmark a post the caller can read. Bookmarking a post again keeps the first bookmark.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Bookmark} "Post bookmarked successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the post's organization"
+// @Failure 404 {object} models.ErrorResponse "Post not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/bookmark [post]
+func (h *PostHandler) BookmarkPost(c *gin.Context) {
+	post, err := h.storageService.GetPost(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return
+	}
+	if !canReadPost(c, h.storageService, post) {
+		respondError(c, orgRoleError(models.OrgRoleViewer))
+		return
+	}
+
+	bookmark, err := h.storageService.AddBookmark(c.Request.Context(), c.GetString("userID"), post.ID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to bookmark post"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Post bookmarked successfully",
+		Data:    bookmark,
+	})
+}
+
+// UnbookmarkPost godoc
+// @Summary Remove a bookmark
+// @Description Remove the caller's bookmark of a post, if there is one. Works for posts that are gone or no longer readable too.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} models.SuccessResponse "Bookmark removed successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/bookmark [delete]
+func (h *PostHandler) UnbookmarkPost(c *gin.Context) {
+	if err := h.storageService.RemoveBookmark(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to remove bookmark"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Bookmark removed successfully",
+	})
+}
+
+// ListBookmarks godoc
+// @Summary List bookmarks
+// @Description Get a paginated list of the caller's bookmarks, newest first, each with its post. Posts that are gone or no longer readable are left out of post.
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Success 200 {object} models.ListResponse{data=[]models.Bookmark} "Bookmarks retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/bookmarks [get]
+func (h *PostHandler) ListBookmarks(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+	ctx := c.Request.Context()
+
+	bookmarks, total, err := h.storageService.ListBookmarks(ctx, c.GetString("userID"), pagination)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list bookmarks"))
+		return
+	}
+
+	posts := make([]*models.Post, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		post, err := h.storageService.GetPost(ctx, bookmark.PostID)
+		if err != nil || !canReadPost(c, h.storageService, post) {
+			continue
+		}
+		bookmark.Post = post
+		posts = append(posts, post)
+	}
+	h.storageService.CountBookmarks(ctx, posts...)
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       bookmarks,
+		Pagination: pagination,
+	})
+}
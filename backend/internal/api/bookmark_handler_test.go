@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookmarks(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	author, authorToken := api.user("bob", models.RoleUser)
+	_, alice := api.user("alice", models.RoleUser)
+	_, carol := api.user("carol", models.RoleUser)
+	post := &models.Post{UserID: author.ID, Title: "Hello", Content: "World", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(ctx, post))
+	path := "/api/v1/posts/" + post.ID + "/bookmark"
+
+	bookmarks := func(token string) []*models.Bookmark {
+		t.Helper()
+		w := api.do(http.MethodGet, "/api/v1/profile/bookmarks", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var bookmarks []*models.Bookmark
+		decode(t, w, &bookmarks)
+		return bookmarks
+	}
+	bookmarkCount := func() int64 {
+		t.Helper()
+		w := api.do(http.MethodGet, "/api/v1/posts/"+post.ID, authorToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got models.Post
+		decode(t, w, &got)
+		return got.BookmarkCount
+	}
+
+	// Bookmarking again keeps the first bookmark
+	w := api.do(http.MethodPost, path, alice, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var first models.Bookmark
+	decode(t, w, &first)
+	w = api.do(http.MethodPost, path, alice, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var again models.Bookmark
+	decode(t, w, &again)
+	assert.True(t, first.CreatedAt.Equal(again.CreatedAt))
+	assert.Equal(t, int64(1), bookmarkCount())
+
+	w = api.do(http.MethodPost, path, carol, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, int64(2), bookmarkCount())
+
+	listed := bookmarks(alice)
+	require.Len(t, listed, 1)
+	require.NotNil(t, listed[0].Post)
+	assert.Equal(t, post.ID, listed[0].Post.ID)
+	assert.Equal(t, int64(2), listed[0].Post.BookmarkCount)
+
+	// Removing a bookmark twice is no error
+	for range 2 {
+		w = api.do(http.MethodDelete, path, carol, nil)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.Empty(t, bookmarks(carol))
+	assert.Equal(t, int64(1), bookmarkCount())
+
+	// Posts alice can no longer read are left out of her bookmarks, which
+	// she can still remove
+	post.Status = models.PostStatusPending
+	require.NoError(t, api.storage.UpdatePost(ctx, post))
+	listed = bookmarks(alice)
+	require.Len(t, listed, 1)
+	assert.Nil(t, listed[0].Post)
+	w = api.do(http.MethodPost, path, carol, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = api.do(http.MethodDelete, path, alice, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, bookmarks(alice))
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// CommentHandler manages the comments of posts. Everyone who can read a
+// post can comment on it and reply up to maxDepth levels deep. Authors
+// and post admins delete comments; those who may change the post hide
+// abusive ones.
+type CommentHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+	maxDepth       int
+}
+
+func NewCommentHandler(storageService *services.StorageService, messagingClient *messaging.Client, maxDepth int) *CommentHandler {
+	return &CommentHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+		maxDepth:       maxDepth,
+	}
+}
+
+// ListComments godoc
+// @Summary List comments of a post
+// @Description Get every comment of a post, oldest first. Replies name the comment they answer in parentId, so clients build the threads. Hidden comments are shown without content except to their author and those who may change the post; deleted comments with replies stay without content.
+// @Tags comments
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Comment} "Comments retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the post's organization"
+// @Failure 404 {object} models.ErrorResponse "Post not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/comments [get]
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	post, ok := h.readablePost(c)
+	if !ok {
+		return
+	}
+
+	comments, err := h.storageService.ListComments(c.Request.Context(), post.ID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list comments"))
+		return
+	}
+	moderator := canChangePost(c, h.storageService, post)
+	for i, comment := range comments {
+		comments[i] = commentForViewer(comment, c.GetString("userID"), moderator)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Comments retrieved successfully",
+		Data:    comments,
+	})
+}
+
+// CreateComment godoc
+// @Summary Comment on a post
+// @Description Comment on a post, or reply to the comment parentId of the same post. Replies are limited to COMMENTS_MAX_DEPTH levels below the post; deleted comments cannot be replied to.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param request body models.CreateCommentRequest true "Comment"
+// @Success 201 {object} models.SuccessResponse{data=models.Comment} "Comment created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format, unknown parent or too deep"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the post's organization"
+// @Failure 404 {object} models.ErrorResponse "Post not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/comments [post]
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	var req models.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	post, ok := h.readablePost(c)
+	if !ok {
+		return
+	}
+
+	comment := &models.Comment{
+		PostID:   post.ID,
+		UserID:   c.GetString("userID"),
+		ParentID: req.ParentID,
+		Content:  req.Content,
+	}
+	if req.ParentID != "" {
+		parent, err := h.storageService.GetComment(c.Request.Context(), post.ID, req.ParentID)
+		switch {
+		case errors.Is(err, services.ErrCommentNotFound) || err == nil && parent.Status == models.CommentStatusDeleted:
+			respondError(c, validationError([]models.FieldError{{Name: "parentId", Rule: "exists", Message: "parentId must be a comment of the post"}}))
+			return
+		case err != nil:
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create comment"))
+			return
+		case parent.Depth >= h.maxDepth:
+			respondError(c, validationError([]models.FieldError{{Name: "parentId", Rule: "max_depth", Message: "replies can be at most " + strconv.Itoa(h.maxDepth) + " levels deep"}}))
+			return
+		}
+		comment.Depth = parent.Depth + 1
+	}
+
+	held := false
+	if !hasPermission(c, models.PermPostsAdmin) {
+		var err error
+		if held, err = h.storageService.ReviewComment(c.Request.Context(), comment, spamSender(c)); err != nil {
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to review comment"))
+			return
+		}
+	}
+	if err := h.storageService.CreateComment(c.Request.Context(), comment); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create comment"))
+		return
+	}
+
+	message := "Comment created successfully"
+	if held {
+		message = "Comment created and held for review"
+	}
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: message,
+		Data:    comment,
+	})
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Delete a comment (its author or post admins). A comment with replies is kept without its content so the thread holds together.
+// @Tags comments
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Success 200 {object} models.SuccessResponse "Comment deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Post or comment not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/comments/{commentId} [delete]
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	post, ok := h.readablePost(c)
+	if !ok {
+		return
+	}
+	comment, ok := h.comment(c, post)
+	if !ok {
+		return
+	}
+
+	own := comment.UserID == c.GetString("userID")
+	if !own && !hasPermission(c, models.PermPostsAdmin) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's comment"))
+		return
+	}
+
+	if err := h.storageService.DeleteComment(c.Request.Context(), comment); err != nil {
+		if errors.Is(err, services.ErrCommentNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.CommentNotFound, "Comment not found"))
+			return
+		}
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete comment"))
+		return
+	}
+	if !own {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminCommentDelete,
+			TargetType: models.AdminTargetComment,
+			TargetID:   comment.ID,
+		}, comment, nil)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Comment deleted successfully",
+	})
+}
+
+// ModerateComment godoc
+// @Summary Hide or show a comment
+// @Description Hide an abusive comment, or show it again (those who may change the post: its author, organization admins and post admins). Hidden comments keep their place in the thread without their content.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Param request body models.ModerateCommentRequest true "Moderation state"
+// @Success 200 {object} models.SuccessResponse{data=models.Comment} "Comment moderated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Post or comment not found"
+// @Failure 409 {object} models.ErrorResponse "Comment was deleted"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/comments/{commentId}/moderation [put]
+func (h *CommentHandler) ModerateComment(c *gin.Context) {
+	var req models.ModerateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	post, ok := h.readablePost(c)
+	if !ok {
+		return
+	}
+	if !canChangePost(c, h.storageService, post) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot moderate comments of other user's post"))
+		return
+	}
+	comment, ok := h.comment(c, post)
+	if !ok {
+		return
+	}
+	if comment.Status == models.CommentStatusDeleted {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Comment was deleted"))
+		return
+	}
+
+	before := *comment
+	now := time.Now()
+	comment.Status = req.Status
+	comment.ModeratedBy = c.GetString("userID")
+	comment.ModeratedAt = &now
+	if err := h.storageService.ModerateComment(c.Request.Context(), comment); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to moderate comment"))
+		return
+	}
+	if before.Status == models.CommentStatusPending {
+		// Decided here rather than in the queue
+		if err := h.storageService.RemoveModerationItem(c.Request.Context(), comment.ID); err != nil {
+			requestLogger(c).Warn("Failed to remove comment from the moderation queue", "commentId", comment.ID, "error", err)
+		}
+	}
+	if post.UserID != comment.ModeratedBy {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminCommentModerate,
+			TargetType: models.AdminTargetComment,
+			TargetID:   comment.ID,
+		}, &before, comment)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Comment moderated successfully",
+		Data:    comment,
+	})
+}
+
+// readablePost loads the post of the request, answering with an error
+// when it is missing or the caller may not read it
+func (h *CommentHandler) readablePost(c *gin.Context) (*models.Post, bool) {
+	post, err := h.storageService.GetPost(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return nil, false
+	}
+	if !canReadPost(c, h.storageService, post) {
+		respondError(c, orgRoleError(models.OrgRoleViewer))
+		return nil, false
+	}
+	return post, true
+}
+
+func (h *CommentHandler) comment(c *gin.Context, post *models.Post) (*models.Comment, bool) {
+	comment, err := h.storageService.GetComment(c.Request.Context(), post.ID, c.Param("commentId"))
+	if errors.Is(err, services.ErrCommentNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.CommentNotFound, "Comment not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get comment"))
+		return nil, false
+	}
+	return comment, true
+}
+
+// commentForViewer leaves out the content of a hidden or pending comment
+// unless viewerID wrote it or is a moderator of the post
+func commentForViewer(comment *models.Comment, viewerID string, moderator bool) *models.Comment {
+	withheld := comment.Status == models.CommentStatusHidden || comment.Status == models.CommentStatusPending
+	if !withheld || moderator || comment.UserID == viewerID {
+		return comment
+	}
+	redacted := *comment
+	redacted.Content = ""
+	return &redacted
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentForViewer(t *testing.T) {
+	hidden := &models.Comment{ID: "c1", UserID: "author", Content: "rude", Status: models.CommentStatusHidden}
+
+	assert.Equal(t, "rude", commentForViewer(hidden, "author", false).Content, "authors see their hidden comments")
+	assert.Equal(t, "rude", commentForViewer(hidden, "owner", true).Content, "moderators see hidden comments")
+
+	redacted := commentForViewer(hidden, "reader", false)
+	assert.Empty(t, redacted.Content)
+	assert.Equal(t, models.CommentStatusHidden, redacted.Status)
+	assert.Equal(t, "rude", hidden.Content, "the stored comment is left alone")
+
+	visible := &models.Comment{ID: "c2", UserID: "author", Content: "nice", Status: models.CommentStatusVisible}
+	assert.Same(t, visible, commentForViewer(visible, "reader", false))
+}
+
+// commentPost creates a published post of author and returns the path of
+// its comments
+func commentPost(t *testing.T, api *testAPI, author *models.User) string {
+	t.Helper()
+	post := &models.Post{UserID: author.ID, Title: "Hello", Content: "World", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(context.Background(), post))
+	return "/api/v1/posts/" + post.ID + "/comments"
+}
+
+// comment posts a comment to path, replying to parentID unless it is empty
+func comment(api *testAPI, path, token, parentID, content string) *httptest.ResponseRecorder {
+	api.t.Helper()
+	return api.do(http.MethodPost, path, token, map[string]string{"parentId": parentID, "content": content})
+}
+
+// createdComment posts a comment that must be accepted and returns it
+func createdComment(api *testAPI, path, token, parentID, content string) *models.Comment {
+	api.t.Helper()
+	w := comment(api, path, token, parentID, content)
+	require.Equal(api.t, http.StatusCreated, w.Code, w.Body.String())
+	var created models.Comment
+	decode(api.t, w, &created)
+	return &created
+}
+
+// listComments returns the comments of path as token sees them, by ID
+func listComments(api *testAPI, path, token string) map[string]*models.Comment {
+	api.t.Helper()
+	w := api.do(http.MethodGet, path, token, nil)
+	require.Equal(api.t, http.StatusOK, w.Code, w.Body.String())
+	var comments []*models.Comment
+	decode(api.t, w, &comments)
+	byID := make(map[string]*models.Comment, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+	return byID
+}
+
+// invalidParent checks w refuses the parentId of a comment for rule
+func invalidParent(t *testing.T, w *httptest.ResponseRecorder, rule string) {
+	t.Helper()
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var response models.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, string(apierr.ValidationFailed), response.ErrorCode)
+	require.Len(t, response.Fields, 1)
+	assert.Equal(t, "parentId", response.Fields[0].Name)
+	assert.Equal(t, rule, response.Fields[0].Rule)
+}
+
+func TestCommentDepth(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) { cfg.Comments.MaxDepth = 2 })
+	author, token := api.user("bob", models.RoleUser)
+	path := commentPost(t, api, author)
+
+	top := createdComment(api, path, token, "", "First")
+	assert.Equal(t, 0, top.Depth)
+	reply := createdComment(api, path, token, top.ID, "Second")
+	assert.Equal(t, 1, reply.Depth)
+	deepest := createdComment(api, path, token, reply.ID, "Third")
+	assert.Equal(t, 2, deepest.Depth)
+
+	invalidParent(t, comment(api, path, token, deepest.ID, "Fourth"), "max_depth")
+	// Replying higher up the thread still works
+	assert.Equal(t, 2, createdComment(api, path, token, reply.ID, "Third again").Depth)
+
+	invalidParent(t, comment(api, path, token, "missing", "Lost"), "exists")
+	other := commentPost(t, api, author)
+	invalidParent(t, comment(api, other, token, top.ID, "Elsewhere"), "exists")
+	assert.Len(t, listComments(api, path, token), 4)
+}
+
+func TestDeleteCommentWithReplies(t *testing.T) {
+	api := newTestAPI(t)
+	author, authorToken := api.user("bob", models.RoleUser)
+	_, alice := api.user("alice", models.RoleUser)
+	_, carol := api.user("carol", models.RoleUser)
+	path := commentPost(t, api, author)
+
+	top := createdComment(api, path, alice, "", "Question")
+	reply := createdComment(api, path, carol, top.ID, "Answer")
+	lone := createdComment(api, path, carol, "", "Aside")
+
+	// Only their author and post admins delete comments, not the post's
+	// author
+	w := api.do(http.MethodDelete, path+"/"+top.ID, carol, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = api.do(http.MethodDelete, path+"/"+top.ID, authorToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A comment without replies is gone
+	w = api.do(http.MethodDelete, path+"/"+lone.ID, carol, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, listComments(api, path, alice), lone.ID)
+
+	// One with replies stays without its content, holding the thread
+	w = api.do(http.MethodDelete, path+"/"+top.ID, alice, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	comments := listComments(api, path, carol)
+	require.Contains(t, comments, top.ID)
+	assert.Equal(t, models.CommentStatusDeleted, comments[top.ID].Status)
+	assert.Empty(t, comments[top.ID].Content)
+	assert.Equal(t, 1, comments[top.ID].Replies)
+	require.Contains(t, comments, reply.ID)
+	assert.Equal(t, "Answer", comments[reply.ID].Content)
+	assert.Equal(t, top.ID, comments[reply.ID].ParentID)
+
+	// It cannot be replied to, hidden or deleted again
+	invalidParent(t, comment(api, path, carol, top.ID, "Late"), "exists")
+	w = api.do(http.MethodPut, path+"/"+top.ID+"/moderation", authorToken, map[string]string{"status": models.CommentStatusHidden})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Deleting its last reply takes the placeholder with it
+	w = api.do(http.MethodDelete, path+"/"+reply.ID, carol, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, listComments(api, path, alice))
+	w = api.do(http.MethodDelete, path+"/"+top.ID, alice, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, string(apierr.CommentNotFound), errorCode(t, w))
+}
+
+func TestCommentModeratorVisibility(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) { cfg.Moderation.Keywords = []string{"casino"} })
+	author, authorToken := api.user("bob", models.RoleUser)
+	_, alice := api.user("alice", models.RoleUser)
+	_, carol := api.user("carol", models.RoleUser)
+	_, admin := api.user("root", models.RoleAdmin)
+	path := commentPost(t, api, author)
+
+	rude := createdComment(api, path, alice, "", "Rude")
+	held := createdComment(api, path, alice, "", "Visit my casino")
+	assert.Equal(t, models.CommentStatusPending, held.Status)
+
+	// Only those who may change the post hide comments
+	w := api.do(http.MethodPut, path+"/"+rude.ID+"/moderation", carol, map[string]string{"status": models.CommentStatusHidden})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = api.do(http.MethodPut, path+"/"+rude.ID+"/moderation", authorToken, map[string]string{"status": models.CommentStatusHidden})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Moderators and the comment's author see the content; other readers
+	// see the comment's place in the thread
+	for name, token := range map[string]string{"post author": authorToken, "post admin": admin, "comment author": alice} {
+		comments := listComments(api, path, token)
+		assert.Equal(t, "Rude", comments[rude.ID].Content, name)
+		assert.Equal(t, "Visit my casino", comments[held.ID].Content, name)
+	}
+	comments := listComments(api, path, carol)
+	require.Contains(t, comments, rude.ID)
+	assert.Equal(t, models.CommentStatusHidden, comments[rude.ID].Status)
+	assert.Empty(t, comments[rude.ID].Content)
+	require.Contains(t, comments, held.ID)
+	assert.Equal(t, models.CommentStatusPending, comments[held.ID].Status)
+	assert.Empty(t, comments[held.ID].Content)
+
+	// Shown again, everyone reads it
+	w = api.do(http.MethodPut, path+"/"+rude.ID+"/moderation", authorToken, map[string]string{"status": models.CommentStatusVisible})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Rude", listComments(api, path, carol)[rude.ID].Content)
+}
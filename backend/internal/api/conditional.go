@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notModified sets the validators of a resource and writes 304 if the
+// client's copy, named in If-None-Match or dated by If-Modified-Since, is
+// current. Responses can differ by viewer, so caches must keep them
+// private and revalidate them.
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	if etag == "" {
+		return false
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	current := false
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		current = etagListMatches(header, etag)
+	} else if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !modified.IsZero() {
+		// HTTP dates have whole seconds
+		current = !modified.Truncate(time.Second).After(since)
+	}
+
+	if current {
+		c.Status(http.StatusNotModified)
+	}
+	return current
+}
+
+// weakETag turns a stored object ETag into a weak validator for a
+// representation of the object, which is not byte-for-byte the object
+func weakETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+	return `W/"` + etag + `"`
+}
+
+// etagListMatches compares an If-None-Match header with etag using the
+// weak comparison: W/ prefixes are ignored
+func etagListMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+type ConfigHandler struct {
+	live *config.Live
+}
+
+func NewConfigHandler(live *config.Live) *ConfigHandler {
+	return &ConfigHandler{live: live}
+}
+
+// GetConfig godoc
+// @Summary Get the effective configuration
+// @Description Get every setting the server runs with, after reloads, and whether it came from the environment, the secrets provider, the config file or the default. Secrets and passwords in URLs are masked; values that could not be parsed are listed as invalid next to the default used instead.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.EffectiveConfig} "Configuration retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Router /admin/config [get]
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	cfg := h.live.Get()
+	settings := make([]models.ConfigSetting, 0, len(cfg.Settings()))
+	for _, setting := range cfg.Settings() {
+		settings = append(settings, models.ConfigSetting{
+			Name:    setting.Name,
+			Value:   setting.Value,
+			Source:  setting.Source,
+			Invalid: setting.Invalid,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Configuration retrieved successfully",
+		Data: models.EffectiveConfig{
+			Environment: cfg.Environment,
+			ConfigFile:  h.live.Path(),
+			Settings:    settings,
+		},
+	})
+}
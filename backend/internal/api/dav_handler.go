@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/davfs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"golang.org/x/net/webdav"
+)
+
+// davPrefix is where the WebDAV drive is served
+const davPrefix = "/dav"
+
+// davMethods are the methods WebDAV clients use. All but the reads need
+// files:write.
+var davMethods = map[string]bool{
+	http.MethodOptions: false,
+	http.MethodGet:     false,
+	http.MethodHead:    false,
+	"PROPFIND":         false,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	"MKCOL":            true,
+	"COPY":             true,
+	"MOVE":             true,
+	"PROPPATCH":        true,
+	"LOCK":             true,
+	"UNLOCK":           true,
+}
+
+// DAVHandler serves the files of the signed-in user over WebDAV, so
+// operating systems can mount them as a drive. Folders are directories;
+// writing a file that exists uploads a new version of it.
+type DAVHandler struct {
+	files *FileHandler
+	users sync.Map // user ID -> *davUser
+}
+
+// davUser is what a user's WebDAV clients share between requests. It is
+// kept in memory, so with several API instances locks and new empty
+// folders are only seen by the instance that made them.
+type davUser struct {
+	folders *davfs.Folders
+	locks   webdav.LockSystem
+}
+
+func NewDAVHandler(fileHandler *FileHandler) *DAVHandler {
+	return &DAVHandler{files: fileHandler}
+}
+
+// Serve handles a WebDAV request under /dav
+func (h *DAVHandler) Serve(c *gin.Context) {
+	if forbidServiceAccount(c) {
+		return
+	}
+	if davMethods[c.Request.Method] && !hasPermission(c, models.PermFilesWrite) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission "+models.PermFilesWrite))
+		return
+	}
+
+	userID := c.GetString("userID")
+	role := c.GetString("role")
+	state, _ := h.users.LoadOrStore(userID, &davUser{folders: davfs.NewFolders(), locks: webdav.NewMemLS()})
+	user := state.(*davUser)
+
+	maxSize := h.files.live.Get().Upload.MaxSizeFor(role, "")
+	if c.Request.Method == http.MethodPut && c.Request.ContentLength > maxSize {
+		tooLargeResponse(c, maxSize)
+		return
+	}
+
+	backend := &davBackend{files: h.files, userID: userID, role: role}
+	fsys := davfs.New(backend, user.folders, maxSize)
+
+	// The webdav package answers every failed read with 404, so content
+	// that may not be downloaded is turned away here with the reason
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		file, err := fsys.File(c.Request.Context(), c.Param("path"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list files"))
+			return
+		}
+		if file != nil && !checkDownloadable(c, file) {
+			return
+		}
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: fsys,
+		LockSystem: user.locks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				requestLogger(c).Warn("WebDAV request failed", "method", r.Method, "error", err)
+			}
+		},
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// davBackend stores the files of one user for WebDAV the way the REST
+// routes do
+type davBackend struct {
+	files  *FileHandler
+	userID string
+	role   string
+}
+
+func (b *davBackend) Files(ctx context.Context) ([]*models.File, error) {
+	files, err := b.files.storageService.ListFilesByUsers(ctx, []string{b.userID}, personalItems)
+	if err != nil {
+		return nil, err
+	}
+	return files[b.userID], nil
+}
+
+func (b *davBackend) Open(ctx context.Context, file *models.File) (io.ReadSeekCloser, error) {
+	content, err := b.files.storageService.GetObjectContent(ctx, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	seeker, ok := content.(io.ReadSeekCloser)
+	if !ok {
+		content.Close()
+		return nil, errors.New("file content cannot seek")
+	}
+	return seeker, nil
+}
+
+func (b *davBackend) Store(ctx context.Context, folder, name string, content *os.File) error {
+	info, err := content.Stat()
+	if err != nil {
+		return err
+	}
+	part := uploadPart{
+		source: tempFileSource(content.Name(), info.Size(), ""),
+		name:   name,
+		folder: normalizeFolder(folder),
+	}
+	file, _, uploadErr := b.files.storeUpload(ctx, b.userID, b.role, part, &uploadOptions{stripMetadata: b.files.stripExif()})
+	if uploadErr != nil {
+		return uploadErr
+	}
+	b.files.enqueueJobs(ctx, file)
+	return nil
+}
+
+func (b *davBackend) Update(ctx context.Context, file *models.File) error {
+	file.Folder = normalizeFolder(file.Folder)
+	return b.files.storageService.UpdateFile(ctx, file)
+}
+
+func (b *davBackend) Delete(ctx context.Context, file *models.File) error {
+	return b.files.storageService.DeleteFile(ctx, file.ID)
+}
+
+// davMaxLoginFailures is how many failed sign-ins of a login name or
+// address within the failure window lock out password sign-ins over
+// WebDAV. Clients retry stored passwords on their own, so there is no
+// CAPTCHA to fall back to.
+const davMaxLoginFailures = 10
+
+// davTokenTTL is how long an access token minted for a password is reused.
+// Clients send the password with every request, and checking a password
+// hash each time would make browsing a drive slow.
+const davTokenTTL = time.Minute
+
+// DAVAuthMiddleware lets WebDAV clients, which can only send HTTP basic
+// authentication, sign in. The password can be an access token, a service
+// account key or the password of a local account, which gets an access
+// token for the request. Bearer tokens are passed on as they are; the
+// request continues to AuthMiddleware either way. Directory users sign in
+// with an access token, as their password is only checked on login.
+func DAVAuthMiddleware(storageService *services.StorageService, jwtManager *auth.JWTManager, loginFailures *auth.LoginFailures, messagingClient *messaging.Client) gin.HandlerFunc {
+	var mu sync.Mutex
+	tokens := map[[sha256.Size]byte]davToken{}
+
+	return func(c *gin.Context) {
+		c.Header("WWW-Authenticate", `Basic realm="Files", charset="UTF-8"`)
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Next()
+			return
+		}
+		if strings.HasPrefix(password, models.ServiceAccountKeyPrefix) || isJWT(password) {
+			c.Request.Header.Set("Authorization", "Bearer "+password)
+			c.Next()
+			return
+		}
+
+		key := sha256.Sum256([]byte(username + "\x00" + password))
+		mu.Lock()
+		cached, found := tokens[key]
+		mu.Unlock()
+		if found && time.Now().Before(cached.expires) {
+			c.Request.Header.Set("Authorization", "Bearer "+cached.token)
+			c.Next()
+			return
+		}
+
+		token, ok := davPasswordToken(c, storageService, jwtManager, loginFailures, messagingClient, username, password)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		now := time.Now()
+		mu.Lock()
+		for k, t := range tokens {
+			if now.After(t.expires) {
+				delete(tokens, k)
+			}
+		}
+		tokens[key] = davToken{token: token, expires: now.Add(min(davTokenTTL, jwtManager.TTL()/2))}
+		mu.Unlock()
+
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		c.Next()
+	}
+}
+
+// isJWT reports whether s looks like a JWT: three base64 parts, the first
+// a JSON object
+func isJWT(s string) bool {
+	return strings.HasPrefix(s, "eyJ") && strings.Count(s, ".") == 2
+}
+
+// davToken is an access token minted for a password
+type davToken struct {
+	token   string
+	expires time.Time
+}
+
+// davPasswordToken checks the password of a local account and returns an
+// access token for it, writing the error response if the sign-in fails
+func davPasswordToken(c *gin.Context, storageService *services.StorageService, jwtManager *auth.JWTManager, loginFailures *auth.LoginFailures, messagingClient *messaging.Client, loginName, password string) (string, bool) {
+	loginName = strings.TrimSpace(loginName)
+	if loginFailures != nil {
+		count, err := loginFailures.Count(c.Request.Context(), loginName, c.ClientIP())
+		if err != nil {
+			requestLogger(c).Error("Failed to load login failures", "error", err)
+		}
+		if count >= davMaxLoginFailures {
+			respondError(c, apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Too many failed sign-ins, try again later"))
+			return "", false
+		}
+	}
+
+	failed := func(userID, reason string) (string, bool) {
+		if loginFailures != nil {
+			if err := loginFailures.RecordFailure(c.Request.Context(), loginName, c.ClientIP()); err != nil {
+				requestLogger(c).Error("Failed to record login failure", "error", err)
+			}
+		}
+		recordAudit(messagingClient, c, models.AuditEvent{
+			Type:     models.AuditLoginFailed,
+			UserID:   userID,
+			Username: loginName,
+			Details:  map[string]string{"reason": reason, "method": "webdav"},
+		})
+		respondError(c, apierr.New(http.StatusUnauthorized, apierr.InvalidCredentials, "Invalid credentials"))
+		return "", false
+	}
+
+	user, err := storageService.GetUserByLogin(c.Request.Context(), loginName)
+	switch {
+	case err != nil:
+		return failed("", "unknown user")
+	case user.DirectoryDN != "" || user.Password == "":
+		return failed(user.ID, "password sign-in not available")
+	case auth.CheckPassword(password, user.Password) != nil:
+		return failed(user.ID, "wrong password")
+	}
+	if status := user.AccountStatus(); status != models.UserStatusActive {
+		inactiveAccountResponse(c, status)
+		return "", false
+	}
+
+	token, err := jwtManager.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to generate token"))
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerfiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// DocsHandler serves the OpenAPI document of the API and a Swagger UI
+// showing it
+type DocsHandler struct {
+	document []byte
+	ui       gin.HandlerFunc
+}
+
+// NewDocsHandler serves document, the JSON OpenAPI document that the UI
+// loads from documentURL
+func NewDocsHandler(document []byte, documentURL string) *DocsHandler {
+	return &DocsHandler{
+		document: document,
+		ui:       ginSwagger.WrapHandler(swaggerfiles.Handler, ginSwagger.URL(documentURL), ginSwagger.PersistAuthorization(true)),
+	}
+}
+
+// OpenAPI serves the OpenAPI 3 document
+func (h *DocsHandler) OpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.document)
+}
+
+// UI serves the pages and assets of the Swagger UI, starting at
+// index.html
+func (h *DocsHandler) UI(c *gin.Context) {
+	h.ui(c)
+}
+
+// BasicTokenMiddleware lets browsers pass an access token or service
+// account key as the password of HTTP basic authentication, as pages they
+// navigate to cannot set an Authorization header. Browsers ask for it
+// once and send it with every asset of the page; the user name is
+// ignored.
+func BasicTokenMiddleware(realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if _, token, ok := c.Request.BasicAuth(); ok {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		// Browsers prompt for credentials when a 401 carries the challenge
+		if header == "" || strings.HasPrefix(header, "Basic ") {
+			c.Header("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/i18n"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// storageErrors gives the errors of the storage layer that clients can act
+// on their status and code
+var storageErrors = []struct {
+	err     error
+	status  int
+	code    apierr.Code
+	message string
+}{
+	{services.ErrUserNotFound, http.StatusNotFound, apierr.UserNotFound, "User not found"},
+	{services.ErrVersionNotFound, http.StatusNotFound, apierr.VersionNotFound, "File version not found"},
+	{services.ErrUploadNotFound, http.StatusNotFound, apierr.UploadNotFound, "Uploaded content not found"},
+	{services.ErrShareNotFound, http.StatusNotFound, apierr.ShareNotFound, "Share not found"},
+	{services.ErrRoleNotFound, http.StatusNotFound, apierr.RoleNotFound, "Role not found"},
+	{services.ErrInvitationNotFound, http.StatusNotFound, apierr.InvitationNotFound, "Invitation not found"},
+	{services.ErrServiceAccountNotFound, http.StatusNotFound, apierr.ServiceAccountNotFound, "Service account not found"},
+	{services.ErrWebhookNotFound, http.StatusNotFound, apierr.WebhookNotFound, "Webhook not found"},
+	{services.ErrDeliveryNotFound, http.StatusNotFound, apierr.DeliveryNotFound, "Webhook delivery not found"},
+	{services.ErrNoUsageReport, http.StatusNotFound, apierr.UsageReportNotFound, "No usage report yet, run the usage task first"},
+	{services.ErrRoleInUse, http.StatusConflict, apierr.RoleInUse, "Role is assigned to users; give them another role first"},
+	{services.ErrUploadAlreadyExists, http.StatusConflict, apierr.UploadFinalized, "Upload already finalized"},
+	{services.ErrScanPending, http.StatusConflict, apierr.ScanPending, "File is still being scanned"},
+	{services.ErrUploadTooLarge, http.StatusRequestEntityTooLarge, apierr.FileTooLarge, "File exceeds the maximum upload size"},
+}
+
+// storageError converts an error of the storage layer into a response:
+// known errors get their own status and code, anything else is an
+// internal error with message
+func storageError(err error, message string) *apierr.Error {
+	for _, known := range storageErrors {
+		if errors.Is(err, known.err) {
+			return apierr.Wrap(err, known.status, known.code, known.message)
+		}
+	}
+	return apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, message)
+}
+
+// respondError writes err as the error response. An *apierr.Error is sent
+// as it is; any other error is an internal error. Causes of internal
+// errors are logged, as the response does not reveal them. Internal
+// errors after the request ran out of time, which are usually caused by
+// it, become timeouts.
+func respondError(c *gin.Context, err error) {
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Internal server error")
+	}
+	if apiErr.Status == http.StatusInternalServerError && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		apiErr = timeoutError()
+	}
+	if apiErr.Status >= http.StatusInternalServerError && apiErr.Err != nil {
+		requestLogger(c).Error(apiErr.Message, "code", apiErr.Code, "error", apiErr.Err)
+	}
+
+	response := apiErr.Response()
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	if lang := i18n.Match(c.GetHeader("Accept-Language")); lang != i18n.Default {
+		localize(&response, lang)
+		c.Header("Content-Language", lang)
+	}
+	c.JSON(apiErr.Status, response)
+}
+
+// localize translates the messages of response into lang. Fields are
+// translated by the rule they broke; a message that only repeats the one
+// invalid field follows it.
+func localize(response *models.ErrorResponse, lang string) {
+	fields := make([]models.FieldError, len(response.Fields))
+	for i, field := range response.Fields {
+		translated := ""
+		if field.Key != "" {
+			args := map[string]string{"name": field.Name}
+			maps.Copy(args, field.Args)
+			translated = i18n.Rule(lang, field.Key, args)
+		}
+		if translated == "" {
+			translated = i18n.Message(lang, "", field.Message)
+		}
+		fields[i] = field
+		fields[i].Message = translated
+	}
+
+	if len(fields) == 1 && response.Message == response.Fields[0].Message && fields[0].Message != response.Message {
+		response.Message = fields[0].Message
+	} else {
+		response.Message = i18n.Message(lang, response.ErrorCode, response.Message)
+	}
+	if len(fields) > 0 {
+		response.Fields = fields
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// exportFlushEvery is how many records an export writes between flushes
+const exportFlushEvery = 100
+
+// ExportUsers godoc
+// @Summary Export users
+// @Description Stream every user as newline-delimited JSON, one user per line, while they are read (admin only). Takes the filters of the user list; sorting reads every user before the first line is sent.
+// @Tags admin
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,username"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
+// @Success 200 {object} models.UserResponse "One user per line"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/export/users [get]
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	opts := c.MustGet("query").(models.QueryOptions)
+
+	export := newNDJSONExport(c, "users")
+	err := h.storageService.EachUser(c.Request.Context(), opts, func(user *models.User) error {
+		return export.write(user.ToUserResponse())
+	})
+	export.finish(err, "Failed to export users")
+}
+
+// ExportPosts godoc
+// @Summary Export posts
+// @Description Stream every post, including those shared with organizations, as newline-delimited JSON, one post per line, while they are read (post admins only). Takes the filters of the post list; sorting reads every post before the first line is sent.
+// @Tags admin
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,title"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
+// @Success 200 {object} models.Post "One post per line"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/export/posts [get]
+func (h *PostHandler) ExportPosts(c *gin.Context) {
+	opts := c.MustGet("query").(models.QueryOptions)
+
+	export := newNDJSONExport(c, "posts")
+	err := h.storageService.EachPost(c.Request.Context(), opts, func(post *models.Post) error {
+		return export.write(post)
+	})
+	export.finish(err, "Failed to export posts")
+}
+
+// ExportFiles godoc
+// @Summary Export files
+// @Description Stream the metadata of every file, including those shared with organizations, as newline-delimited JSON, one file per line, while it is read (file admins only). Takes the filters of the file list; sorting reads every file before the first line is sent.
+// @Tags admin
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -size,originalName"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
+// @Success 200 {object} models.File "One file per line"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/export/files [get]
+func (h *FileHandler) ExportFiles(c *gin.Context) {
+	opts := c.MustGet("query").(models.QueryOptions)
+	viewerID := c.GetString("userID")
+
+	export := newNDJSONExport(c, "files")
+	err := h.storageService.EachFile(c.Request.Context(), opts, func(file *models.File) error {
+		return export.write(file.ForViewer(viewerID))
+	})
+	export.finish(err, "Failed to export files")
+}
+
+// ndjsonExport writes records to the client as newline-delimited JSON.
+// The response starts with the first record, so a failure before it still
+// gets an error response.
+type ndjsonExport struct {
+	c       *gin.Context
+	name    string
+	encoder *json.Encoder
+	written int
+}
+
+func newNDJSONExport(c *gin.Context, name string) *ndjsonExport {
+	return &ndjsonExport{c: c, name: name, encoder: json.NewEncoder(c.Writer)}
+}
+
+func (e *ndjsonExport) start() {
+	e.c.Header("Content-Type", "application/x-ndjson")
+	e.c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.name + ".ndjson"}))
+	e.c.Status(http.StatusOK)
+	e.c.Writer.WriteHeaderNow()
+}
+
+func (e *ndjsonExport) write(record any) error {
+	if e.written == 0 {
+		e.start()
+	}
+	if err := e.encoder.Encode(record); err != nil {
+		return err
+	}
+
+	e.written++
+	if e.written%exportFlushEvery == 0 {
+		e.c.Writer.Flush()
+	}
+	return nil
+}
+
+// finish ends the export after err, if any. Once records were sent the
+// status cannot change, so the connection is dropped instead, which tells
+// the client the export is incomplete.
+func (e *ndjsonExport) finish(err error, message string) {
+	switch {
+	case err == nil && e.written == 0:
+		e.start()
+	case err == nil:
+		e.c.Writer.Flush()
+	case e.written == 0:
+		respondError(e.c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, message))
+	default:
+		requestLogger(e.c).Error(message, "records", e.written, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNDJSONExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/records", func(c *gin.Context) {
+		n, _ := strconv.Atoi(c.Query("n"))
+		export := newNDJSONExport(c, "records")
+		for i := range n {
+			if err := export.write(map[string]int{"n": i}); err != nil {
+				export.finish(err, "Failed to export records")
+				return
+			}
+		}
+		export.finish(nil, "Failed to export records")
+	})
+	router.GET("/broken", func(c *gin.Context) {
+		export := newNDJSONExport(c, "records")
+		export.finish(errors.New("listing failed"), "Failed to export records")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/records?n=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=records.ndjson", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "{\"n\":0}\n{\"n\":1}\n", w.Body.String())
+
+	// An empty export is a successful one
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/records?n=0", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Failing before the first record still answers with an error
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to export records")
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// recordDownload publishes an access log entry for a download of file.
+// Failures are logged only; the download itself has already happened.
+func recordDownload(messagingClient *messaging.Client, c *gin.Context, file *models.File, via, shareToken string, bytes int64) {
+	publishDownload(c.Request.Context(), messagingClient, models.AccessLogEntry{
+		FileID:     file.ID,
+		OwnerID:    file.UserID,
+		UserID:     c.GetString("userID"),
+		Via:        via,
+		ShareToken: shareToken,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Bytes:      bytes,
+	})
+}
+
+// publishDownload publishes entry for the access log, for callers without
+// a gin context such as the gRPC API
+func publishDownload(ctx context.Context, messagingClient *messaging.Client, entry models.AccessLogEntry) {
+	if messagingClient == nil {
+		return
+	}
+
+	entry.AccessedAt = time.Now()
+	if err := messagingClient.Publish(ctx, messaging.SubjectAccessLog, entry); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to record download", "file", entry.FileID, "error", err)
+	}
+}
+
+// GetFileStats godoc
+// @Summary Get file download statistics
+// @Description Get download counts and last-access details from a file's access log (users can only view their own files, admins can view any file)
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Success 200 {object} models.SuccessResponse{data=models.FileStats} "File statistics retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/stats [get]
+func (h *FileHandler) GetFileStats(c *gin.Context) {
+	file, ok := h.ownedFile(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.storageService.GetFileStats(c.Request.Context(), file)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get file statistics"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "File statistics retrieved successfully",
+		Data:    stats,
+	})
+}
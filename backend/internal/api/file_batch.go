@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// UploadBatch godoc
+// @Summary Upload several files
+// @Description Upload several files in one multipart request, e.g. a folder selection. Send the files in the "files" field, or send a "manifest" JSON array of {part, path, encryption} entries naming the form field of each file, its path relative to the folder and, for client-encrypted files, its encryption parameters. Files are stored concurrently and each gets its own result; the other fields apply to every file as in a single upload.
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param files formData file false "Files to upload"
+// @Param manifest formData string false "JSON array of models.BatchUploadEntry"
+// @Param visibility formData string false "File visibility (private, public)" default(private)
+// @Param folder formData string false "Virtual folder the paths are relative to"
+// @Param expiresAt formData string false "RFC 3339 time after which the files are deleted automatically"
+// @Param stripMetadata formData boolean false "Strip EXIF and similar metadata from images (defaults to the server setting)"
+// @Param orgId formData string false "Organization to share the files with (members and above)"
+// @Param X-Upload-ID header string false "Client chosen ID to follow progress at /files/uploads/{id}/progress"
+// @Success 201 {object} models.SuccessResponse{data=[]models.BatchUploadResult} "All files uploaded successfully"
+// @Success 207 {object} models.SuccessResponse{data=[]models.BatchUploadResult} "Some files failed; see each result"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 413 {object} models.ErrorResponse "Batch too large"
+// @Router /files/upload/batch [post]
+func (h *FileHandler) UploadBatch(c *gin.Context) {
+	userID := c.GetString("userID")
+	userRole := c.GetString("role")
+
+	// Reject oversized bodies before reading them
+	maxSize := h.upload.BatchMaxSize
+	if c.Request.ContentLength > maxSize+multipartOverhead {
+		batchTooLargeResponse(c, maxSize)
+		return
+	}
+	progress := h.newProgressReporter(c, c.Request.ContentLength)
+	defer progress.fail()
+	c.Request.Body = progress.wrap(http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead))
+
+	if err := c.Request.ParseMultipartForm(h.upload.FormMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			batchTooLargeResponse(c, maxSize)
+			return
+		}
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Failed to parse multipart form"))
+		return
+	}
+
+	opts, ok := h.parseUploadOptions(c)
+	if !ok {
+		return
+	}
+	// Reusing an IV across files would break the encryption, so encrypted
+	// files are described one by one in the manifest
+	if c.Request.FormValue("encryption") != "" {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Give encryption per file in the manifest"))
+		return
+	}
+
+	parts, err := batchParts(c.Request.MultipartForm, c.Request.FormValue("manifest"), opts.folder)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, err.Error()))
+		return
+	}
+	if len(parts) == 0 {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "At least one file is required"))
+		return
+	}
+	if len(parts) > h.upload.BatchMaxFiles {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "A batch may contain at most "+strconv.Itoa(h.upload.BatchMaxFiles)+" files"))
+		return
+	}
+	progress.stage(messaging.UploadStageStoring, "")
+
+	results := make([]models.BatchUploadResult, len(parts))
+	seen := make(map[string]bool, len(parts))
+	sem := make(chan struct{}, max(h.upload.BatchConcurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, part := range parts {
+		results[i].Path = part.path
+
+		// Two parts with the same path would race to create the same file
+		key := part.folder + "/" + part.name
+		if seen[key] {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "Duplicate path in batch"
+			continue
+		}
+		seen[key] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *models.BatchUploadResult, part uploadPart) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			file, isVersion, uploadErr := h.storeUpload(c.Request.Context(), userID, userRole, part, opts)
+			if uploadErr != nil {
+				result.Status = uploadErr.Status
+				result.Error = uploadErr.Message
+				result.ErrorCode = string(uploadErr.Code)
+				return
+			}
+
+			h.enqueueJobs(c.Request.Context(), file)
+			result.Status = http.StatusCreated
+			result.Version = isVersion
+			result.File = file
+		}(&results[i], part)
+	}
+	wg.Wait()
+	progress.complete("")
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	status := http.StatusCreated
+	message := "Files uploaded successfully"
+	if failed > 0 {
+		status = http.StatusMultiStatus
+		message = strconv.Itoa(failed) + " of " + strconv.Itoa(len(results)) + " files failed to upload"
+	}
+	c.JSON(status, models.SuccessResponse{
+		Message: message,
+		Data:    results,
+	})
+}
+
+// batchParts lists the files of a batch upload. With a manifest each entry
+// names the form field of one file and its path below folder; otherwise
+// every file in the "files" field is stored directly in folder.
+func batchParts(form *multipart.Form, manifest, folder string) ([]uploadPart, error) {
+	if manifest == "" {
+		var parts []uploadPart
+		for _, header := range form.File["files"] {
+			parts = append(parts, uploadPart{
+				source: formSource(header),
+				path:   header.Filename,
+				folder: folder,
+				name:   header.Filename,
+			})
+		}
+		return parts, nil
+	}
+
+	var entries []models.BatchUploadEntry
+	if err := json.Unmarshal([]byte(manifest), &entries); err != nil {
+		return nil, errors.New("manifest must be a JSON array of {part, path} entries")
+	}
+
+	parts := make([]uploadPart, 0, len(entries))
+	for _, entry := range entries {
+		headers := form.File[entry.Part]
+		if len(headers) != 1 {
+			return nil, errors.New("manifest part " + strconv.Quote(entry.Part) + " must hold exactly one file")
+		}
+
+		relPath := entry.Path
+		if relPath == "" {
+			relPath = headers[0].Filename
+		}
+		dir, name := path.Split(path.Clean("/" + relPath))
+		if name == "" || strings.HasSuffix(relPath, "/") {
+			return nil, errors.New("manifest path " + strconv.Quote(entry.Path) + " does not name a file")
+		}
+
+		if entry.Encryption != nil {
+			if err := entry.Encryption.Validate(); err != nil {
+				return nil, errors.New("manifest path " + strconv.Quote(relPath) + ": " + err.Error())
+			}
+		}
+
+		parts = append(parts, uploadPart{
+			source:     formSource(headers[0]),
+			path:       relPath,
+			folder:     normalizeFolder(folder + "/" + dir),
+			name:       name,
+			encryption: entry.Encryption,
+		})
+	}
+	return parts, nil
+}
+
+// batchTooLargeResponse reports a batch over the total size limit
+func batchTooLargeResponse(c *gin.Context, limit int64) {
+	respondError(c, apierr.New(http.StatusRequestEntityTooLarge, apierr.BatchTooLarge, "Batch exceeds the maximum size of "+strconv.FormatInt(limit, 10)+" bytes"))
+}
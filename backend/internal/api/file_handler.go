@@ -1,103 +1,782 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/filetype"
+	"github.com/minio-fullstack-storage/backend/internal/imagemeta"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/preview"
 	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/thumbnail"
 )
 
 type FileHandler struct {
 	storageService *services.StorageService
+	messaging      *messaging.Client
+	jobs           *jobs.Queue
+	fileTypes      *filetype.Policy
+	upload         config.UploadConfig
+	download       config.DownloadConfig
+	live           *config.Live // for the settings that change on reload
 }
 
-func NewFileHandler(storageService *services.StorageService) *FileHandler {
+// multipartOverhead is allowed on top of the file size limit for multipart
+// boundaries and the other form fields
+const multipartOverhead = 1 << 20
+
+func NewFileHandler(storageService *services.StorageService, messagingClient *messaging.Client, jobQueue *jobs.Queue, live *config.Live) *FileHandler {
+	uploadConfig := live.Get().Upload
 	return &FileHandler{
 		storageService: storageService,
+		messaging:      messagingClient,
+		jobs:           jobQueue,
+		fileTypes: filetype.NewPolicy(
+			uploadConfig.AllowedTypes,
+			uploadConfig.DeniedTypes,
+			uploadConfig.AllowedExtensions,
+			uploadConfig.DeniedExtensions,
+		),
+		upload:   uploadConfig,
+		download: live.Get().Download,
+		live:     live,
+	}
+}
+
+// stripExif reports whether image metadata is stripped from uploads that
+// do not choose
+func (h *FileHandler) stripExif() bool {
+	return h.live.Get().Upload.StripExif
+}
+
+// reservedUploadFields are upload form fields that are not custom metadata
+var reservedUploadFields = map[string]bool{
+	"file":          true,
+	"visibility":    true,
+	"folder":        true,
+	"stripMetadata": true,
+	"expiresAt":     true,
+	"encryption":    true,
+	"manifest":      true,
+	"orgId":         true,
+}
+
+// validExpiry rejects expiry times that are not in the future, writing a
+// 400 response if so. A nil expiry is valid.
+func validExpiry(c *gin.Context, expiresAt *time.Time) bool {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "expiresAt must be in the future"))
+		return false
+	}
+	return true
+}
+
+// stripMetadataOption resolves the uploader's stripMetadata choice against
+// the server default, writing a 400 response for invalid values
+func (h *FileHandler) stripMetadataOption(c *gin.Context, value string) (bool, bool) {
+	if value == "" {
+		return h.stripExif(), true
+	}
+	strip, err := strconv.ParseBool(value)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "stripMetadata must be true or false"))
+		return false, false
+	}
+	return strip, true
+}
+
+// stripImage removes metadata from an image, writing a 400 response if the
+// image cannot be parsed. Failing closed keeps location data from leaking.
+func stripImage(c *gin.Context, contentType string, data []byte) (*imagemeta.Result, bool) {
+	result, err := imagemeta.Strip(contentType, data)
+	if err != nil {
+		respondError(c, stripError(err))
+		return nil, false
+	}
+	return result, true
+}
+
+func stripError(err error) *apierr.Error {
+	return apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to strip image metadata: "+err.Error())
+}
+
+// keepExifTags replaces the owner-only EXIF entries in metadata with tags
+// from newly stripped content, if keeping them is enabled
+func (h *FileHandler) keepExifTags(metadata map[string]string, tags map[string]string) {
+	for key := range metadata {
+		if strings.HasPrefix(key, models.ExifMetadataPrefix) {
+			delete(metadata, key)
+		}
+	}
+	if !h.upload.KeepExif {
+		return
+	}
+	for key, value := range tags {
+		metadata[models.ExifMetadataPrefix+key] = value
+	}
+}
+
+// tooLargeResponse reports an upload over the caller's size limit
+func tooLargeResponse(c *gin.Context, limit int64) {
+	respondError(c, tooLargeError(limit))
+}
+
+func tooLargeError(limit int64) *apierr.Error {
+	return apierr.New(http.StatusRequestEntityTooLarge, apierr.FileTooLarge, "File exceeds the maximum upload size of "+strconv.FormatInt(limit, 10)+" bytes")
+}
+
+// checkFileType rejects uploads whose content type or extension is not
+// allowed, writing a 415 response if so.
+func (h *FileHandler) checkFileType(c *gin.Context, contentType, filename string) bool {
+	if err := h.fileTypes.Check(contentType, filename); err != nil {
+		respondError(c, apierr.New(http.StatusUnsupportedMediaType, apierr.FileTypeNotAllowed, err.Error()))
+		return false
+	}
+	return true
+}
+
+// enqueueJobs hands new content to the background workers: quarantined
+// uploads go to the virus scanner, clean content to the thumbnail or preview
+// worker. Failures are logged only; the upload itself has already succeeded.
+func (h *FileHandler) enqueueJobs(ctx context.Context, file *models.File) {
+	if h.jobs == nil {
+		return
+	}
+
+	jobType, ok := services.NextJob(file)
+	if !ok {
+		return
+	}
+
+	if _, err := h.jobs.Enqueue(ctx, jobType, messaging.FileJob{FileID: file.ID}); err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to enqueue job", "type", jobType, "file", file.ID, "error", err)
+	}
+}
+
+// checkDownloadable rejects content that has expired or has not passed the
+// virus scan, writing the error response if so.
+func checkDownloadable(c *gin.Context, file *models.File) bool {
+	if err := downloadableError(file); err != nil {
+		respondError(c, err)
+		return false
+	}
+	return true
+}
+
+// downloadableError returns why file cannot be downloaded, nil if it can
+func downloadableError(file *models.File) *apierr.Error {
+	switch {
+	case file.IsExpired(time.Now()):
+		return apierr.New(http.StatusGone, apierr.FileExpired, "File has expired")
+	case file.ScanStatus == models.ScanStatusInfected:
+		return apierr.New(http.StatusForbidden, apierr.FileQuarantined, "File failed the virus scan and is quarantined")
+	case !file.IsDownloadable():
+		return apierr.New(http.StatusConflict, apierr.ScanPending, "File is still being scanned")
 	}
+	return nil
+}
+
+// scanPendingResponse reports that a file cannot be used while its latest
+// upload is still being scanned
+func scanPendingResponse(c *gin.Context) {
+	respondError(c, apierr.New(http.StatusConflict, apierr.ScanPending, "File is still being scanned"))
 }
 
 // UploadFile godoc
 // @Summary Upload a file
-// @Description Upload a file to the storage system
+// @Description Upload a file to the storage system. Uploading a file with the same name into the same folder stores a new version of the existing file.
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
 // @Param file formData file true "File to upload"
+// @Param visibility formData string false "File visibility (private, public)" default(private)
+// @Param folder formData string false "Virtual folder"
+// @Param expiresAt formData string false "RFC 3339 time after which the file is deleted automatically"
+// @Param stripMetadata formData boolean false "Strip EXIF and similar metadata from images (defaults to the server setting)"
+// @Param encryption formData string false "JSON models.FileEncryption for content encrypted by the client"
+// @Param orgId formData string false "Organization to share the file with (members and above)"
+// @Param X-Upload-ID header string false "Client chosen ID to follow progress at /files/uploads/{id}/progress"
 // @Success 201 {object} models.SuccessResponse{data=models.File} "File uploaded successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request format"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 409 {object} models.ErrorResponse "Previous version still being scanned"
+// @Failure 413 {object} models.ErrorResponse "File too large"
+// @Failure 415 {object} models.ErrorResponse "File type not allowed"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /files/upload [post]
 func (h *FileHandler) UploadFile(c *gin.Context) {
 	userID := c.GetString("userID")
+	userRole := c.GetString("role")
+
+	// Reject oversized bodies before reading them
+	maxSize := h.upload.MaxSizeFor(userRole, "")
+	if c.Request.ContentLength > maxSize+multipartOverhead {
+		tooLargeResponse(c, maxSize)
+		return
+	}
+	progress := h.newProgressReporter(c, c.Request.ContentLength)
+	defer progress.fail()
+	c.Request.Body = progress.wrap(http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead))
 
 	// Parse multipart form
-	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "Failed to parse multipart form",
-			Code:    http.StatusBadRequest,
-		})
+	if err := c.Request.ParseMultipartForm(h.upload.FormMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			tooLargeResponse(c, maxSize)
+			return
+		}
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Failed to parse multipart form"))
 		return
 	}
 
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "File is required",
-			Code:    http.StatusBadRequest,
-		})
+	headers := c.Request.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "File is required"))
 		return
 	}
+
+	opts, ok := h.parseUploadOptions(c)
+	if !ok {
+		return
+	}
+	encryption, ok := parseEncryption(c, c.Request.FormValue("encryption"))
+	if !ok {
+		return
+	}
+	progress.stage(messaging.UploadStageStoring, "")
+
+	part := uploadPart{
+		source:     formSource(headers[0]),
+		name:       headers[0].Filename,
+		folder:     opts.folder,
+		encryption: encryption,
+	}
+	fileModel, isVersion, uploadErr := h.storeUpload(c.Request.Context(), userID, userRole, part, opts)
+	if uploadErr != nil {
+		respondError(c, uploadErr)
+		return
+	}
+
+	h.enqueueJobs(c.Request.Context(), fileModel)
+	progress.complete(fileModel.ID)
+
+	message := "File uploaded successfully"
+	if isVersion {
+		message = "File version uploaded successfully"
+	}
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: message,
+		Data:    fileModel,
+	})
+}
+
+// uploadOptions are the form fields that apply to every file of an upload
+type uploadOptions struct {
+	visibility    string
+	folder        string
+	orgID         string // organization to share the files with
+	stripMetadata bool
+	expiresAt     *time.Time
+	metadata      map[string]string
+}
+
+// detectUploadType determines the content type of an uploaded part. The
+// real type is detected from the leading bytes instead of trusting the
+// Content-Type the client sent. Client-encrypted content cannot be sniffed
+// and is always stored as opaque bytes, so a declared type is never trusted.
+func detectUploadType(file multipart.File, declared, name string, encryption *models.FileEncryption) (string, *apierr.Error) {
+	if encryption != nil {
+		return models.EncryptedContentType, nil
+	}
+
+	head := make([]byte, filetype.SniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to read uploaded file")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to read uploaded file")
+	}
+	return filetype.Detect(head[:n], name, declared), nil
+}
+
+// parseUploadOptions reads the shared upload form fields, writing a 400
+// response if any is invalid
+func (h *FileHandler) parseUploadOptions(c *gin.Context) (*uploadOptions, bool) {
+	opts := &uploadOptions{
+		visibility: c.Request.FormValue("visibility"),
+		folder:     normalizeFolder(c.Request.FormValue("folder")),
+		orgID:      c.Request.FormValue("orgId"),
+		metadata:   make(map[string]string),
+	}
+	if opts.visibility != "" && opts.visibility != models.VisibilityPrivate && opts.visibility != models.VisibilityPublic {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Visibility must be private or public"))
+		return nil, false
+	}
+	if opts.orgID != "" && !requireOrgRole(c, h.storageService, opts.orgID, models.OrgRoleMember) {
+		return nil, false
+	}
+
+	stripMetadata, ok := h.stripMetadataOption(c, c.Request.FormValue("stripMetadata"))
+	if !ok {
+		return nil, false
+	}
+	opts.stripMetadata = stripMetadata
+
+	if value := c.Request.FormValue("expiresAt"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "expiresAt must be an RFC 3339 timestamp"))
+			return nil, false
+		}
+		opts.expiresAt = &parsed
+	}
+	if !validExpiry(c, opts.expiresAt) {
+		return nil, false
+	}
+
+	// Collect custom metadata from form
+	for key, values := range c.Request.Form {
+		if !reservedUploadFields[key] && len(values) > 0 {
+			opts.metadata[key] = values[0]
+		}
+	}
+
+	return opts, true
+}
+
+// parseEncryption reads the client-side encryption parameters of an upload,
+// writing a 400 response if they are invalid. An empty value means the
+// content is plaintext.
+func parseEncryption(c *gin.Context, value string) (*models.FileEncryption, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	var encryption models.FileEncryption
+	err := json.Unmarshal([]byte(value), &encryption)
+	if err == nil {
+		err = encryption.Validate()
+	}
+	if err != nil {
+		message := "encryption must be a JSON object with algorithm, keyId and iv"
+		if _, ok := err.(*json.SyntaxError); !ok {
+			message = err.Error()
+		}
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, message))
+		return nil, false
+	}
+	return &encryption, true
+}
+
+// uploadSource is the content of an uploaded file
+type uploadSource struct {
+	open        func() (multipart.File, error)
+	size        int64
+	contentType string // as declared by the client
+}
+
+// formSource is the content of a file of a multipart form
+func formSource(header *multipart.FileHeader) uploadSource {
+	return uploadSource{open: header.Open, size: header.Size, contentType: header.Header.Get("Content-Type")}
+}
+
+// tempFileSource is the content of a body spooled to the file name, opened
+// anew for each read as storeUpload closes what it opens
+func tempFileSource(name string, size int64, contentType string) uploadSource {
+	return uploadSource{open: func() (multipart.File, error) { return os.Open(name) }, size: size, contentType: contentType}
+}
+
+// uploadPart is one uploaded file and where it should be stored
+type uploadPart struct {
+	source     uploadSource
+	path       string // as given by the client, for batch results
+	folder     string
+	name       string
+	encryption *models.FileEncryption
+}
+
+// storeUpload checks one uploaded part and stores it under its name in its
+// folder. A file with the same name in the same folder gets a new version
+// instead of a separate file; the second result reports which happened.
+func (h *FileHandler) storeUpload(ctx context.Context, userID, userRole string, part uploadPart, opts *uploadOptions) (*models.File, bool, *apierr.Error) {
+	name, folder := part.name, part.folder
+	file, err := part.source.open()
+	if err != nil {
+		return nil, false, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to read uploaded file")
+	}
 	defer file.Close()
 
+	contentType, uploadErr := detectUploadType(file, part.source.contentType, name, part.encryption)
+	if uploadErr != nil {
+		return nil, false, uploadErr
+	}
+	if err := h.fileTypes.Check(contentType, name); err != nil {
+		return nil, false, apierr.New(http.StatusUnsupportedMediaType, apierr.FileTypeNotAllowed, err.Error())
+	}
+	if limit := h.upload.MaxSizeFor(userRole, contentType); part.source.size > limit {
+		return nil, false, tooLargeError(limit)
+	}
+
+	var content io.Reader = file
+	size := part.source.size
+	var exifTags map[string]string
+	if opts.stripMetadata && part.encryption == nil && imagemeta.IsSupported(contentType) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, false, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Failed to read uploaded file")
+		}
+		result, err := imagemeta.Strip(contentType, data)
+		if err != nil {
+			return nil, false, stripError(err)
+		}
+		content = bytes.NewReader(result.Data)
+		size = int64(len(result.Data))
+		exifTags = result.Tags
+	}
+
+	// Re-uploading a file with the same name in the same folder stores a new
+	// version of it instead of creating a separate file
+	if existing, err := h.storageService.FindFileByName(ctx, userID, opts.orgID, folder, name); err == nil {
+		if existing.Metadata == nil {
+			existing.Metadata = make(map[string]string)
+		}
+		for key, value := range opts.metadata {
+			existing.Metadata[key] = value
+		}
+		metadata, schemaErr := applyMetadataSchema(ctx, h.storageService, folder, existing.Metadata)
+		if schemaErr != nil {
+			return nil, false, schemaErr
+		}
+		existing.Metadata = metadata
+		if opts.visibility != "" {
+			existing.Visibility = opts.visibility
+		}
+		if opts.stripMetadata {
+			h.keepExifTags(existing.Metadata, exifTags)
+		}
+		if opts.expiresAt != nil {
+			existing.ExpiresAt = opts.expiresAt
+		}
+
+		if err := h.storageService.StoreFileVersion(ctx, existing, contentType, size, content, part.encryption); err != nil {
+			if errors.Is(err, services.ErrScanPending) {
+				return nil, false, apierr.New(http.StatusConflict, apierr.ScanPending, "File is still being scanned")
+			}
+			return nil, false, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to upload file version")
+		}
+		return existing, true, nil
+	}
+
+	visibility := opts.visibility
+	if visibility == "" {
+		visibility = models.VisibilityPrivate
+	}
+	metadata, schemaErr := applyMetadataSchema(ctx, h.storageService, folder, opts.metadata)
+	if schemaErr != nil {
+		return nil, false, schemaErr
+	}
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	h.keepExifTags(metadata, exifTags)
+
 	// Create file metadata
 	fileModel := &models.File{
 		UserID:       userID,
-		OriginalName: header.Filename,
-		ContentType:  header.Header.Get("Content-Type"),
-		Size:         header.Size,
-		Metadata:     make(map[string]string),
+		OriginalName: name,
+		ContentType:  contentType,
+		Size:         size,
+		Folder:       folder,
+		Metadata:     metadata,
+		Visibility:   visibility,
+		OrgID:        opts.orgID,
+		ExpiresAt:    opts.expiresAt,
+		Encryption:   part.encryption,
 	}
 
-	// Add custom metadata from form
-	for key, values := range c.Request.Form {
-		if key != "file" && len(values) > 0 {
-			fileModel.Metadata[key] = values[0]
+	if err := h.storageService.UploadFile(ctx, fileModel, content); err != nil {
+		return nil, false, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to upload file")
+	}
+	return fileModel, false, nil
+}
+
+// PresignUpload godoc
+// @Summary Presign a direct upload
+// @Description Get a presigned POST policy so the browser can upload straight to MinIO
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.PresignUploadRequest true "Upload description"
+// @Success 200 {object} models.SuccessResponse{data=models.PresignedUpload} "Upload policy issued"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 413 {object} models.ErrorResponse "File too large"
+// @Failure 415 {object} models.ErrorResponse "File type not allowed"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/upload/presign [post]
+func (h *FileHandler) PresignUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	userRole := c.GetString("role")
+
+	var req models.PresignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	if !h.checkFileType(c, req.ContentType, req.FileName) {
+		return
+	}
+
+	maxSize := h.upload.MaxSizeFor(userRole, req.ContentType)
+	upload, err := h.storageService.PresignUpload(c.Request.Context(), userID, req, maxSize)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadTooLarge) {
+			tooLargeResponse(c, maxSize)
+			return
+		}
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to presign upload"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Upload policy issued successfully",
+		Data:    upload,
+	})
+}
+
+// FinalizeUpload godoc
+// @Summary Finalize a direct upload
+// @Description Record metadata for a file uploaded with a presigned POST policy
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.FinalizeUploadRequest true "Finalize data"
+// @Success 201 {object} models.SuccessResponse{data=models.File} "File uploaded successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 404 {object} models.ErrorResponse "Upload not found"
+// @Failure 409 {object} models.ErrorResponse "Upload already finalized or file still being scanned"
+// @Failure 415 {object} models.ErrorResponse "File type not allowed"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/upload/finalize [post]
+func (h *FileHandler) FinalizeUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req models.FinalizeUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	name, ok := checkFileName(c, req.OriginalName)
+	if !ok {
+		return
+	}
+	req.OriginalName = name
+
+	if !validExpiry(c, req.ExpiresAt) {
+		return
+	}
+	if req.Encryption != nil {
+		if err := req.Encryption.Validate(); err != nil {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, err.Error()))
+			return
+		}
+	}
+
+	contentType, ok := h.finalizeUploadType(c, userID, req)
+	if !ok {
+		return
+	}
+
+	stripMetadata := h.stripExif()
+	if req.StripMetadata != nil {
+		stripMetadata = *req.StripMetadata
+	}
+	var exifTags map[string]string
+	if stripMetadata && req.Encryption == nil && imagemeta.IsSupported(contentType) {
+		tags, ok := h.stripUpload(c, userID, req.FileID, contentType)
+		if !ok {
+			return
+		}
+		exifTags = tags
+	}
+
+	folder := normalizeFolder(req.Folder)
+
+	var err error
+	var fileModel *models.File
+	if existing, findErr := h.storageService.FindFileByName(c.Request.Context(), userID, "", folder, req.OriginalName); findErr == nil {
+		// Same logical file: the upload becomes its new version
+		if existing.Metadata == nil {
+			existing.Metadata = make(map[string]string)
+		}
+		for key, value := range req.Metadata {
+			existing.Metadata[key] = value
+		}
+		metadata, schemaErr := applyMetadataSchema(c.Request.Context(), h.storageService, folder, existing.Metadata)
+		if schemaErr != nil {
+			respondError(c, schemaErr)
+			return
+		}
+		existing.Metadata = metadata
+		if req.Visibility != "" {
+			existing.Visibility = req.Visibility
 		}
+		if stripMetadata {
+			h.keepExifTags(existing.Metadata, exifTags)
+		}
+		if req.ExpiresAt != nil {
+			existing.ExpiresAt = req.ExpiresAt
+		}
+		fileModel = existing
+		err = h.storageService.PromoteUploadToVersion(c.Request.Context(), fileModel, req.FileID, contentType, req.Encryption)
+	} else {
+		metadata, schemaErr := applyMetadataSchema(c.Request.Context(), h.storageService, folder, req.Metadata)
+		if schemaErr != nil {
+			respondError(c, schemaErr)
+			return
+		}
+		fileModel = &models.File{
+			ID:           req.FileID,
+			UserID:       userID,
+			OriginalName: req.OriginalName,
+			ContentType:  contentType,
+			Folder:       folder,
+			Metadata:     metadata,
+			Visibility:   req.Visibility,
+			ExpiresAt:    req.ExpiresAt,
+			Encryption:   req.Encryption,
+		}
+		if fileModel.Metadata == nil {
+			fileModel.Metadata = make(map[string]string)
+		}
+		h.keepExifTags(fileModel.Metadata, exifTags)
+		if fileModel.Visibility == "" {
+			fileModel.Visibility = models.VisibilityPrivate
+		}
+		err = h.storageService.FinalizeUpload(c.Request.Context(), fileModel)
 	}
 
-	if err := h.storageService.UploadFile(c.Request.Context(), fileModel, file); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to upload file",
-			Code:    http.StatusInternalServerError,
-		})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadNotFound):
+			respondError(c, apierr.New(http.StatusNotFound, apierr.UploadNotFound, "Uploaded content not found"))
+		case errors.Is(err, services.ErrUploadAlreadyExists):
+			respondError(c, apierr.New(http.StatusConflict, apierr.UploadFinalized, "Upload already finalized"))
+		case errors.Is(err, services.ErrScanPending):
+			scanPendingResponse(c)
+		default:
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to finalize upload"))
+		}
 		return
 	}
 
+	h.enqueueJobs(c.Request.Context(), fileModel)
+
 	c.JSON(http.StatusCreated, models.SuccessResponse{
 		Message: "File uploaded successfully",
 		Data:    fileModel,
 	})
 }
 
+// finalizeUploadType returns the content type of a presigned upload, writing
+// the error response and discarding rejected content on failure. The presign
+// policy only pins the declared type, so plaintext is checked again here.
+// Encrypted content cannot be sniffed and is recorded as opaque bytes rather
+// than the type declared when the upload was presigned.
+func (h *FileHandler) finalizeUploadType(c *gin.Context, userID string, req models.FinalizeUploadRequest) (string, bool) {
+	if req.Encryption != nil {
+		return models.EncryptedContentType, true
+	}
+
+	head, err := h.storageService.ReadUploadHead(c.Request.Context(), userID, req.FileID, filetype.SniffLen)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.UploadNotFound, "Uploaded content not found"))
+			return "", false
+		}
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to read uploaded content"))
+		return "", false
+	}
+
+	contentType := filetype.Detect(head, req.OriginalName, "")
+	if !h.checkFileType(c, contentType, req.OriginalName) {
+		if err := h.storageService.DiscardUpload(c.Request.Context(), userID, req.FileID); err != nil {
+			requestLogger(c).Error("Failed to discard rejected upload", "upload", req.FileID, "error", err)
+		}
+		return "", false
+	}
+	return contentType, true
+}
+
+// stripUpload strips metadata from a presigned image upload in place and
+// returns the removed tags, writing the error response on failure
+func (h *FileHandler) stripUpload(c *gin.Context, userID, uploadID, contentType string) (map[string]string, bool) {
+	content, err := h.storageService.ReadUpload(c.Request.Context(), userID, uploadID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to read uploaded content"))
+		return nil, false
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to read uploaded content"))
+		return nil, false
+	}
+
+	result, ok := stripImage(c, contentType, data)
+	if !ok {
+		return nil, false
+	}
+
+	if err := h.storageService.ReplaceUpload(c.Request.Context(), userID, uploadID, contentType, result.Data); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to store stripped content"))
+		return nil, false
+	}
+	return result.Tags, true
+}
+
 // GetFile godoc
 // @Summary Get file metadata
-// @Description Get file metadata by ID
+// @Description Get file metadata by ID; files shared with an organization are only shown to its members
 // @Tags files
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "File ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.SuccessResponse{data=models.File} "File metadata retrieved successfully"
+// @Success 304 "Not modified"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the file's organization"
 // @Failure 404 {object} models.ErrorResponse "File not found"
 // @Router /files/{id} [get]
 func (h *FileHandler) GetFile(c *gin.Context) {
@@ -105,23 +784,26 @@ func (h *FileHandler) GetFile(c *gin.Context) {
 
 	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "File not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+	if file.OrgID != "" && !canReadFile(c, h.storageService, file) {
+		respondError(c, orgRoleError(models.OrgRoleViewer))
 		return
 	}
 
+	if notModified(c, metadataETag(file), file.UpdatedAt) {
+		return
+	}
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "File retrieved successfully",
-		Data:    file,
+		Data:    file.ForViewer(c.GetString("userID")),
 	})
 }
 
 // DownloadFile godoc
 // @Summary Download a file
-// @Description Download a file (users can only download their own files, admins can download any file)
+// @Description Download a file (users can only download their own or public files, admins can download any file)
 // @Tags files
 // @Produce application/octet-stream
 // @Security BearerAuth
@@ -130,62 +812,515 @@ func (h *FileHandler) GetFile(c *gin.Context) {
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 403 {object} models.ErrorResponse "Forbidden"
 // @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 409 {object} models.ErrorResponse "File still being scanned"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /files/{id}/download [get]
 func (h *FileHandler) DownloadFile(c *gin.Context) {
 	fileID := c.Param("id")
-	userID := c.GetString("userID")
-	userRole := c.GetString("role")
 
 	// Get file metadata
 	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "File not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
 		return
 	}
 
 	// Check if user can download this file
-	if file.UserID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot download other user's file",
-			Code:    http.StatusForbidden,
-		})
+	if !canReadFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot download other user's file"))
+		return
+	}
+
+	if !checkDownloadable(c, file) {
 		return
 	}
 
 	// Get file content
 	content, err := h.storageService.GetFileContent(c.Request.Context(), fileID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to get file content",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get file content"))
 		return
 	}
 	defer content.Close()
 
+	written := streamFile(c, file, content)
+	recordDownload(h.messaging, c, file, models.AccessDownload, "", written)
+}
+
+// encryptionHeaders passes the parameters of client-encrypted content along
+// with a download so clients can decrypt without another request
+func encryptionHeaders(c *gin.Context, encryption *models.FileEncryption) {
+	if encryption == nil {
+		return
+	}
+	c.Header("X-Encryption-Algorithm", encryption.Algorithm)
+	c.Header("X-Encryption-Key-Id", encryption.KeyID)
+	c.Header("X-Encryption-IV", encryption.IV)
+}
+
+// streamFile writes download headers for file, copies content to the client
+// and returns the number of bytes sent
+func streamFile(c *gin.Context, file *models.File, content io.Reader) int64 {
 	// Set headers for download
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename="+file.OriginalName)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.OriginalName}))
 	c.Header("Content-Type", file.ContentType)
 	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	encryptionHeaders(c, file.Encryption)
 
 	// Stream file content
-	if _, err := io.Copy(c.Writer, content); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to stream file",
-			Code:    http.StatusInternalServerError,
-		})
+	written, err := io.Copy(c.Writer, content)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to stream file"))
+	}
+	return written
+}
+
+// maxMetadataKeys caps the number of custom metadata entries per file
+const maxMetadataKeys = 50
+
+// UpdateFile godoc
+// @Summary Update file metadata
+// @Description Rename a file, move it to another virtual folder, or edit its description and custom metadata. Metadata changes are merged into the existing metadata; keys set to null are removed. Send the ETag from a previous response in If-Match to avoid overwriting concurrent changes. (users can only update their own files, admins can update any file)
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.UpdateFileRequest true "File changes"
+// @Success 200 {object} models.SuccessResponse{data=models.File} "File updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 412 {object} models.ErrorResponse "File changed since it was read"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id} [patch]
+func (h *FileHandler) UpdateFile(c *gin.Context) {
+	fileID := c.Param("id")
+	userID := c.GetString("userID")
+
+	var req models.UpdateFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot update other user's file"))
+		return
+	}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != metadataETag(file) {
+		respondError(c, apierr.New(http.StatusPreconditionFailed, apierr.ETagMismatch, "File was modified since it was read"))
+		return
+	}
+
+	if req.OriginalName != nil {
+		name, ok := checkFileName(c, *req.OriginalName)
+		if !ok {
+			return
+		}
+		file.OriginalName = name
+	}
+	if req.Folder != nil {
+		file.Folder = normalizeFolder(*req.Folder)
+	}
+	if req.Description != nil {
+		file.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Metadata != nil {
+		file.Metadata = mergeMetadata(file.Metadata, req.Metadata)
+		if len(file.Metadata) > maxMetadataKeys {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Files can have at most "+strconv.Itoa(maxMetadataKeys)+" metadata entries"))
+			return
+		}
+	}
+	if req.Metadata != nil || req.Folder != nil {
+		metadata, schemaErr := applyMetadataSchema(c.Request.Context(), h.storageService, file.Folder, file.Metadata)
+		if schemaErr != nil {
+			respondError(c, schemaErr)
+			return
+		}
+		file.Metadata = metadata
+	}
+
+	if err := h.storageService.UpdateFile(c.Request.Context(), file); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update file"))
 		return
 	}
+
+	c.Header("ETag", metadataETag(file))
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "File updated successfully",
+		Data:    file.ForViewer(userID),
+	})
+}
+
+// filesForViewer applies File.ForViewer to every file in a listing
+func filesForViewer(files []*models.File, viewerID string) []*models.File {
+	visible := make([]*models.File, len(files))
+	for i, file := range files {
+		visible[i] = file.ForViewer(viewerID)
+	}
+	return visible
+}
+
+// metadataETag identifies a revision of a file's metadata. Unlike file.ETag,
+// which is the content checksum, it changes on every update.
+func metadataETag(file *models.File) string {
+	return `W/"` + file.ID + "-" + strconv.FormatInt(file.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// mergeMetadata applies changes to existing: nil values delete their key
+func mergeMetadata(existing map[string]string, changes map[string]*string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(changes))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+	return merged
+}
+
+// CopyFile godoc
+// @Summary Copy a file
+// @Description Duplicate a file server-side, optionally under a new name or folder. The copy is owned by the caller.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.CopyFileRequest false "Copy options"
+// @Success 201 {object} models.SuccessResponse{data=models.File} "File copied successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 409 {object} models.ErrorResponse "File still being scanned"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/copy [post]
+func (h *FileHandler) CopyFile(c *gin.Context) {
+	fileID := c.Param("id")
+	userID := c.GetString("userID")
+
+	var req models.CopyFileRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+	}
+
+	source, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canReadFile(c, h.storageService, source) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot copy other user's file"))
+		return
+	}
+
+	if !checkDownloadable(c, source) {
+		return
+	}
+
+	copied := &models.File{
+		UserID:       userID,
+		FileName:     source.FileName,
+		OriginalName: source.OriginalName,
+		ContentType:  source.ContentType,
+		Size:         source.Size,
+		Folder:       source.Folder,
+		Metadata:     make(map[string]string, len(source.Metadata)),
+		Visibility:   models.VisibilityPrivate,
+		Encryption:   source.Encryption, // the copy is the same ciphertext
+	}
+	for key, value := range source.ForViewer(userID).Metadata {
+		copied.Metadata[key] = value
+	}
+	if req.OriginalName != "" {
+		name, ok := checkFileName(c, req.OriginalName)
+		if !ok {
+			return
+		}
+		copied.OriginalName = name
+	}
+	if req.Folder != nil {
+		copied.Folder = normalizeFolder(*req.Folder)
+	}
+	metadata, schemaErr := applyMetadataSchema(c.Request.Context(), h.storageService, copied.Folder, copied.Metadata)
+	if schemaErr != nil {
+		respondError(c, schemaErr)
+		return
+	}
+	copied.Metadata = metadata
+
+	if err := h.storageService.CopyFile(c.Request.Context(), source, copied); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to copy file"))
+		return
+	}
+
+	h.enqueueJobs(c.Request.Context(), copied)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "File copied successfully",
+		Data:    copied,
+	})
+}
+
+// checkFileName returns name without surrounding spaces, writing a 400
+// response when it is empty, . or .., or holds a path separator; folders
+// are set apart from names
+func checkFileName(c *gin.Context, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "File name must be non-empty and cannot contain slashes"))
+		return "", false
+	}
+	return name, true
+}
+
+// normalizeFolder turns user input into a clean absolute virtual folder path.
+// The root folder is stored as the empty string.
+func normalizeFolder(folder string) string {
+	cleaned := path.Clean("/" + strings.TrimSpace(folder))
+	if cleaned == "/" {
+		return ""
+	}
+	return cleaned
+}
+
+// DownloadPublicFile godoc
+// @Summary Download a public file
+// @Description Download a file marked public without authentication. Responses are cacheable by shared caches, or redirect to a presigned MinIO URL when presigned public downloads are enabled.
+// @Tags files
+// @Produce application/octet-stream
+// @Param id path string true "File ID"
+// @Success 200 {file} binary "File content"
+// @Success 302 "Redirect to presigned URL"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /public/files/{id}/download [get]
+func (h *FileHandler) DownloadPublicFile(c *gin.Context) {
+	fileID := c.Param("id")
+
+	// Private and unscanned files answer 404 rather than 403 so their IDs
+	// cannot be probed
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil || !file.IsPublic() || !file.IsDownloadable() || file.IsExpired(time.Now()) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if h.live.Get().Download.PresignPublic {
+		expiry := time.Duration(h.download.PresignExpiry) * time.Minute
+		presignedURL, err := h.storageService.PresignedDownloadURL(c.Request.Context(), file, expiry)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to presign download"))
+			return
+		}
+		// MinIO serves the content, so the full size is recorded
+		recordDownload(h.messaging, c, file, models.AccessPublic, "", file.Size)
+		encryptionHeaders(c, file.Encryption)
+		c.Redirect(http.StatusFound, presignedURL)
+		return
+	}
+
+	etag := `"` + file.ETag + `"`
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(h.download.PublicCacheMaxAge))
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), file.Path)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get file content"))
+		return
+	}
+	defer content.Close()
+
+	written := streamFile(c, file, content)
+	recordDownload(h.messaging, c, file, models.AccessPublic, "", written)
+}
+
+// UpdateVisibility godoc
+// @Summary Change file visibility
+// @Description Make a file public or private (users can only change their own files, admins can change any file)
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.UpdateVisibilityRequest true "New visibility"
+// @Success 200 {object} models.SuccessResponse{data=models.File} "Visibility updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/visibility [put]
+func (h *FileHandler) UpdateVisibility(c *gin.Context) {
+	fileID := c.Param("id")
+	userID := c.GetString("userID")
+
+	var req models.UpdateVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot update other user's file"))
+		return
+	}
+
+	file.Visibility = req.Visibility
+	if err := h.storageService.UpdateFile(c.Request.Context(), file); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update file"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Visibility updated successfully",
+		Data:    file.ForViewer(userID),
+	})
+}
+
+// GetThumbnail godoc
+// @Summary Get a file thumbnail
+// @Description Get a generated thumbnail for an image file (users can only view their own or public files, admins can view any file)
+// @Tags files
+// @Produce image/jpeg
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param size query string false "Thumbnail size (small, medium, large)" default(medium)
+// @Success 200 {file} binary "Thumbnail image"
+// @Success 304 "Not modified"
+// @Failure 400 {object} models.ErrorResponse "Invalid size"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Thumbnail not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/thumbnail [get]
+func (h *FileHandler) GetThumbnail(c *gin.Context) {
+	fileID := c.Param("id")
+	size := c.DefaultQuery("size", "medium")
+
+	if _, ok := thumbnail.Sizes[size]; !ok {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Size must be one of small, medium or large"))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canReadFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot view other user's file"))
+		return
+	}
+
+	thumbnailPath, ok := file.Thumbnails[size]
+	if !ok {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.ThumbnailNotAvailable, "Thumbnail not available"))
+		return
+	}
+
+	// Thumbnails only change when the content does, so the content ETag
+	// plus the size makes a stable validator
+	etag := `"` + file.ETag + "-" + size + `"`
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), thumbnailPath)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get thumbnail"))
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, -1, thumbnail.ContentType, content, nil)
+}
+
+// GetPreview godoc
+// @Summary Get a document preview
+// @Description Get a PNG rendering of the first page of a PDF or office document (users can only view their own or public files, admins can view any file)
+// @Tags files
+// @Produce image/png
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Success 200 {file} binary "Preview image"
+// @Success 304 "Not modified"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Preview not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/preview [get]
+func (h *FileHandler) GetPreview(c *gin.Context) {
+	fileID := c.Param("id")
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canReadFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot view other user's file"))
+		return
+	}
+
+	if file.Preview == "" {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PreviewNotAvailable, "Preview not available"))
+		return
+	}
+
+	etag := `"` + file.ETag + `-preview"`
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), file.Preview)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get preview"))
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, -1, preview.ContentType, content, nil)
 }
 
 // DeleteFile godoc
@@ -205,37 +1340,31 @@ func (h *FileHandler) DownloadFile(c *gin.Context) {
 func (h *FileHandler) DeleteFile(c *gin.Context) {
 	fileID := c.Param("id")
 	userID := c.GetString("userID")
-	userRole := c.GetString("role")
 
 	// Get existing file
 	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "File not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
 		return
 	}
 
 	// Check if user can delete this file
-	if file.UserID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot delete other user's file",
-			Code:    http.StatusForbidden,
-		})
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's file"))
 		return
 	}
 
 	if err := h.storageService.DeleteFile(c.Request.Context(), fileID); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to delete file",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete file"))
 		return
 	}
+	if file.UserID != userID {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminFileDelete,
+			TargetType: models.AdminTargetFile,
+			TargetID:   file.ID,
+		}, file, nil)
+	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "File deleted successfully",
@@ -243,44 +1372,128 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 	})
 }
 
+// ListQuarantinedFiles godoc
+// @Summary List quarantined files
+// @Description List uploads that failed the virus scan (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.File} "Quarantined files retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/files/quarantine [get]
+func (h *FileHandler) ListQuarantinedFiles(c *gin.Context) {
+	files, err := h.storageService.ListFilesByScanStatus(c.Request.Context(), models.ScanStatusInfected)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list quarantined files"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Quarantined files retrieved successfully",
+		Data:    filesForViewer(files, c.GetString("userID")),
+	})
+}
+
+// defaultExpiringWindow is how far ahead ListExpiringFiles looks by default
+const defaultExpiringWindow = 7 * 24 * time.Hour
+
+// ListExpiringFiles godoc
+// @Summary List expiring files
+// @Description List the current user's files that will be deleted automatically within the given window, soonest first
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param within query string false "Look-ahead window as a Go duration, e.g. 24h (default 168h)"
+// @Success 200 {object} models.SuccessResponse{data=[]models.File} "Expiring files retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid window"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/expiring [get]
+func (h *FileHandler) ListExpiringFiles(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	window := defaultExpiringWindow
+	if value := c.Query("within"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "within must be a positive duration such as 24h"))
+			return
+		}
+		window = parsed
+	}
+
+	files, err := h.storageService.ListExpiringFiles(c.Request.Context(), userID, time.Now().Add(window))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list expiring files"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Expiring files retrieved successfully",
+		Data:    filesForViewer(files, userID),
+	})
+}
+
+// ListFiles godoc
+// @Summary List files
+// @Description Get a paginated list of the caller's files not shared with an organization, or of everyone's for file admins. Filter by userId, orgId, originalName, contentType, folder, visibility, size, createdAt, updatedAt and expiresAt as field=value or field[op]=value.
+// @Tags files
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -size,originalName"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
+// @Success 200 {object} models.ListResponse{data=[]models.File} "Files retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files [get]
 func (h *FileHandler) ListFiles(c *gin.Context) {
 	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+
+	// Only file admins see everyone's files, and files shared with
+	// organizations are listed per organization
+	if !hasPermission(c, models.PermFilesAdmin) {
+		opts.Filters = append(opts.Filters, models.Filter{Field: "userId", Op: models.OpEq, Value: c.GetString("userID")})
+	}
+	ginAccess(c).personalOnly(&opts, models.PermFilesAdmin)
 
-	files, total, err := h.storageService.ListFiles(c.Request.Context(), pagination)
+	files, total, err := h.storageService.ListFiles(c.Request.Context(), pagination, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to list files",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list files"))
 		return
 	}
 
 	pagination.Total = total
 
 	c.JSON(http.StatusOK, models.ListResponse{
-		Data:       files,
+		Data:       filesForViewer(files, c.GetString("userID")),
 		Pagination: pagination,
 	})
 }
 
 func (h *FileHandler) GetUserFiles(c *gin.Context) {
 	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	opts.Filters = append(opts.Filters, models.Filter{Field: "userId", Op: models.OpEq, Value: c.Param("userId")})
 
-	files, total, err := h.storageService.ListFiles(c.Request.Context(), pagination)
+	files, total, err := h.storageService.ListFiles(c.Request.Context(), pagination, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to list user files",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list user files"))
 		return
 	}
 
 	pagination.Total = total
 
 	c.JSON(http.StatusOK, models.ListResponse{
-		Data:       files,
+		Data:       filesForViewer(files, c.GetString("userID")),
 		Pagination: pagination,
 	})
 }
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFolder(t *testing.T) {
+	assert.Equal(t, "", normalizeFolder(""))
+	assert.Equal(t, "", normalizeFolder("/"))
+	assert.Equal(t, "/projects/2024", normalizeFolder("projects//2024/"))
+	assert.Equal(t, "/reports", normalizeFolder("/projects/../reports"))
+	assert.Equal(t, "/escape", normalizeFolder("../../escape"))
+}
+
+func TestZipEntryNames(t *testing.T) {
+	names := newZipEntryNames()
+
+	assert.Equal(t, "report.pdf", names.next(&models.File{OriginalName: "report.pdf"}))
+	assert.Equal(t, "report (1).pdf", names.next(&models.File{OriginalName: "report.pdf"}))
+	assert.Equal(t, "docs/report.pdf", names.next(&models.File{OriginalName: "report.pdf", Folder: "/docs"}))
+	assert.Equal(t, "abc", names.next(&models.File{ID: "abc"}))
+
+	// Names cannot leave the archive's root
+	for name, file := range map[string]*models.File{
+		".._.._.bashrc":   {OriginalName: "../../.bashrc"},
+		"..__etc_passwd":  {OriginalName: "..\\/etc/passwd"},
+		"docs/.._evil.sh": {OriginalName: "../evil.sh", Folder: "/docs"},
+		"etc/cron.d":      {OriginalName: "cron.d", Folder: "/../../etc"},
+		"a/b/_..":         {OriginalName: "..", Folder: "a\\..\\b"},
+		"_.":              {OriginalName: "."},
+		"id-1":            {ID: "id-1", OriginalName: "", Folder: "/.."},
+	} {
+		assert.Equal(t, name, zipEntryName(file))
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	project := "apollo"
+	existing := map[string]string{"project": "gemini", "owner": "ops"}
+
+	merged := mergeMetadata(existing, map[string]*string{
+		"project": &project,
+		"owner":   nil,
+		"missing": nil,
+	})
+
+	assert.Equal(t, map[string]string{"project": "apollo"}, merged)
+	assert.Equal(t, "gemini", existing["project"], "existing metadata must not be modified")
+}
+
+func TestNilProgressReporter(t *testing.T) {
+	var progress *progressReporter
+	body := io.NopCloser(strings.NewReader("data"))
+
+	assert.Equal(t, body, progress.wrap(body))
+	assert.NotPanics(t, func() {
+		progress.stage(messaging.UploadStageStoring, "")
+		progress.complete("file-id")
+		progress.fail()
+	})
+}
+
+func TestUploadIDPattern(t *testing.T) {
+	assert.True(t, uploadIDPattern.MatchString("3f2b8c1e-upload_01"))
+	assert.False(t, uploadIDPattern.MatchString("short"))
+	assert.False(t, uploadIDPattern.MatchString("has.dots.and>wildcards"))
+}
+
+func TestStreamAssetPattern(t *testing.T) {
+	assert.True(t, streamAssetPattern.MatchString("master.m3u8"))
+	assert.True(t, streamAssetPattern.MatchString("720p/index.m3u8"))
+	assert.True(t, streamAssetPattern.MatchString("720p/segment_0004.ts"))
+	assert.False(t, streamAssetPattern.MatchString("../metadata.json"))
+	assert.False(t, streamAssetPattern.MatchString("720p/../../content"))
+}
+
+func TestBatchParts(t *testing.T) {
+	form := &multipart.Form{File: map[string][]*multipart.FileHeader{
+		"files": {{Filename: "a.txt"}, {Filename: "b.txt"}},
+		"p0":    {{Filename: "beach.jpg"}},
+		"p1":    {{Filename: "notes.md"}},
+	}}
+
+	parts, err := batchParts(form, "", "/uploads")
+	assert.NoError(t, err)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, "/uploads", parts[0].folder)
+	assert.Equal(t, "b.txt", parts[1].name)
+
+	parts, err = batchParts(form, `[{"part":"p0","path":"photos/2024/beach.jpg"},{"part":"p1"}]`, "/uploads")
+	assert.NoError(t, err)
+	assert.Equal(t, "/uploads/photos/2024", parts[0].folder)
+	assert.Equal(t, "beach.jpg", parts[0].name)
+	assert.Equal(t, "/uploads", parts[1].folder)
+	assert.Equal(t, "notes.md", parts[1].name)
+
+	_, err = batchParts(form, `[{"part":"missing","path":"x.txt"}]`, "")
+	assert.Error(t, err)
+	_, err = batchParts(form, `[{"part":"files","path":"x.txt"}]`, "")
+	assert.Error(t, err, "parts holding several files are ambiguous")
+	_, err = batchParts(form, `[{"part":"p0","path":"photos/"}]`, "")
+	assert.Error(t, err)
+	_, err = batchParts(form, `{"part":"p0"}`, "")
+	assert.Error(t, err)
+
+	parts, err = batchParts(form, `[{"part":"p0","encryption":{"algorithm":"AES-256-GCM","keyId":"k1","iv":"AAECAwQFBgcICQoL"}}]`, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", parts[0].encryption.KeyID)
+	_, err = batchParts(form, `[{"part":"p0","encryption":{"algorithm":"AES-256-GCM"}}]`, "")
+	assert.Error(t, err)
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	etag := weakETag("abc123")
+
+	check := func(header, value string) (*httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+		if header != "" {
+			c.Request.Header.Set(header, value)
+		}
+		current := notModified(c, etag, modified)
+		c.Writer.WriteHeaderNow()
+		return w, current
+	}
+
+	w, current := check("", "")
+	assert.False(t, current)
+	assert.Equal(t, `W/"abc123"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	w, current = check("If-None-Match", `"other", "abc123"`)
+	assert.True(t, current, "weak comparison ignores W/")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	_, current = check("If-None-Match", `W/"other"`)
+	assert.False(t, current)
+
+	_, current = check("If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT")
+	assert.True(t, current)
+	_, current = check("If-Modified-Since", "Wed, 01 May 2024 11:59:59 GMT")
+	assert.False(t, current)
+}
+
+func TestUploadEncryptedFile(t *testing.T) {
+	a := newTestAPI(t)
+	_, token := a.user("alice", models.RoleUser)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("encryption", `{"algorithm":"AES-256-GCM","keyId":"k1","iv":"AAECAwQFBgcICQoL"}`))
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="page.html"`)
+	header.Set("Content-Type", "text/html")
+	part, err := form.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write([]byte("<script>alert(1)</script>"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The declared type is not trusted for content that cannot be sniffed
+	var file models.File
+	decode(t, w, &file)
+	assert.Equal(t, models.EncryptedContentType, file.ContentType)
+
+	w = a.do(http.MethodGet, "/api/v1/files/"+file.ID+"/download", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.EncryptedContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "k1", w.Header().Get("X-Encryption-Key-Id"))
+}
+
+func TestGetStreamStalled(t *testing.T) {
+	api := newTestAPI(t)
+	user, token := api.user("alice", models.RoleUser)
+	file := api.file(user, "video.mp4", "not really a video")
+	path := "/api/v1/files/" + file.ID + "/stream/master.m3u8"
+
+	started := time.Now()
+	file.Stream = &models.VideoStream{Status: models.StreamStatusProcessing, StartedAt: &started, UpdatedAt: started}
+	require.NoError(t, api.storage.UpdateFile(context.Background(), file))
+	w := api.do(http.MethodGet, path, token, nil)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	// A transcode that ran out of time without recording its result failed
+	started = started.Add(-time.Duration(api.cfg.Video.Timeout+1) * time.Minute)
+	require.NoError(t, api.storage.UpdateFile(context.Background(), file))
+	w = api.do(http.MethodGet, path, token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Equal(t, "STREAM_NOT_AVAILABLE", errorCode(t, w))
+}
+
+func TestDownloadFileName(t *testing.T) {
+	api := newTestAPI(t)
+	user, token := api.user("alice", models.RoleUser)
+
+	for _, name := range []string{`report "final"; v2.txt`, "résumé.txt", "plain.txt"} {
+		t.Run(name, func(t *testing.T) {
+			file := api.file(user, name, "content")
+			w := api.do(http.MethodGet, "/api/v1/files/"+file.ID+"/download", token, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			disposition, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+			require.NoError(t, err)
+			assert.Equal(t, "attachment", disposition)
+			assert.Equal(t, name, params["filename"])
+		})
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	api := newTestAPI(t)
+	user, token := api.user("alice", models.RoleUser)
+	file := api.file(user, "notes.txt", "hello")
+	badNames := []string{"", "  ", ".", "..", "../notes.txt", "a/b.txt", "a\\b.txt", "/etc/passwd"}
+
+	for _, name := range badNames {
+		w := api.do(http.MethodPost, "/api/v1/files/upload/finalize", token, map[string]string{"fileId": "0b6d3b9e-7a56-4a4b-9c55-1f0f4f0a8c11", "originalName": name})
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+
+		w = api.do(http.MethodPatch, "/api/v1/files/"+file.ID, token, map[string]string{"originalName": name})
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+
+		// Copies keep the name of their source when given none
+		if name != "" {
+			w = api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/copy", token, map[string]string{"originalName": name})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+	}
+	files, err := api.storage.ListUserFiles(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	w := api.do(http.MethodPatch, "/api/v1/files/"+file.ID, token, map[string]string{"originalName": "  renamed.txt "})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var renamed models.File
+	decode(t, w, &renamed)
+	assert.Equal(t, "renamed.txt", renamed.OriginalName)
+}
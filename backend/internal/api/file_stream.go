@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/transcode"
+)
+
+// streamAssetPattern matches the files the transcoder writes: the master
+// playlist and each rendition's playlist and segments
+var streamAssetPattern = regexp.MustCompile(`^(master\.m3u8|[0-9a-z]+/(index\.m3u8|segment_[0-9]+\.ts))$`)
+
+// GetStream godoc
+// @Summary Stream a video over HLS
+// @Description Serve the HLS master playlist of a transcoded video, and the rendition playlists and segments it references (users can only stream their own or public files, admins can stream any file)
+// @Tags files
+// @Produce application/vnd.apple.mpegurl
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param asset path string true "Playlist or segment, starting with master.m3u8"
+// @Success 200 {file} binary "Playlist or segment"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Stream not found"
+// @Failure 409 {object} models.ErrorResponse "Video still being transcoded"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/stream/{asset} [get]
+func (h *FileHandler) GetStream(c *gin.Context) {
+	fileID := c.Param("id")
+	asset := strings.TrimPrefix(c.Param("asset"), "/")
+
+	if !streamAssetPattern.MatchString(asset) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.StreamNotAvailable, "Stream asset not found"))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canReadFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot stream other user's file"))
+		return
+	}
+
+	transcodeTimeout := time.Duration(h.live.Get().Video.Timeout) * time.Minute
+	if file.Stream == nil || file.Stream.Status == models.StreamStatusFailed || file.Stream.Stalled(transcodeTimeout) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.StreamNotAvailable, "Stream not available"))
+		return
+	}
+	if file.Stream.Status != models.StreamStatusReady {
+		respondError(c, apierr.New(http.StatusConflict, apierr.TranscodePending, "Video is still being transcoded"))
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), h.storageService.StreamPrefix(file)+asset)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get stream asset"))
+		return
+	}
+	defer content.Close()
+
+	// Segments never change for a given transcode, playlists are tiny
+	if strings.HasSuffix(asset, ".ts") {
+		c.Header("Cache-Control", "private, max-age=86400")
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	c.DataFromReader(http.StatusOK, -1, transcode.ContentType(asset), content, nil)
+}
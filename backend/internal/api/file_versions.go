@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// ListVersions godoc
+// @Summary List file versions
+// @Description List the current and archived versions of a file, newest first
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Success 200 {object} models.SuccessResponse{data=[]models.FileVersion} "Versions retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Router /files/{id}/versions [get]
+func (h *FileHandler) ListVersions(c *gin.Context) {
+	file, ok := h.ownedFile(c)
+	if !ok {
+		return
+	}
+
+	versions := make([]models.FileVersion, 0, len(file.Versions)+1)
+	versions = append(versions, models.FileVersion{
+		Version:     max(file.Version, 1),
+		Path:        file.Path,
+		ContentType: file.ContentType,
+		Size:        file.Size,
+		ETag:        file.ETag,
+		CreatedAt:   file.UpdatedAt,
+	})
+	for i := len(file.Versions) - 1; i >= 0; i-- {
+		versions = append(versions, file.Versions[i])
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Versions retrieved successfully",
+		Data:    versions,
+	})
+}
+
+// DownloadVersion godoc
+// @Summary Download a file version
+// @Description Download the content of an archived file version
+// @Tags files
+// @Produce application/octet-stream
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param version path int true "Version number"
+// @Success 200 {file} binary "File content"
+// @Failure 400 {object} models.ErrorResponse "Invalid version"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Version not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/versions/{version}/download [get]
+func (h *FileHandler) DownloadVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	file, ok := h.ownedFile(c)
+	if !ok {
+		return
+	}
+
+	target := file.FindVersion(version)
+	if target == nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.VersionNotFound, "File version not found"))
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), target.Path)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get file content"))
+		return
+	}
+	defer content.Close()
+
+	versioned := *file
+	versioned.ContentType = target.ContentType
+	versioned.Size = target.Size
+	versioned.Encryption = target.Encryption
+	written := streamFile(c, &versioned, content)
+	recordDownload(h.messaging, c, file, models.AccessVersion, "", written)
+}
+
+// RestoreVersion godoc
+// @Summary Restore a file version
+// @Description Make an archived version the current content. The replaced content is archived as a new version.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} models.SuccessResponse{data=models.File} "Version restored successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid version"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Version not found"
+// @Failure 409 {object} models.ErrorResponse "File still being scanned"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/versions/{version}/restore [post]
+func (h *FileHandler) RestoreVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	file, ok := h.ownedFile(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.RestoreFileVersion(c.Request.Context(), file, version); err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.VersionNotFound, "File version not found"))
+			return
+		}
+		if errors.Is(err, services.ErrScanPending) {
+			scanPendingResponse(c)
+			return
+		}
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to restore file version"))
+		return
+	}
+
+	h.enqueueJobs(c.Request.Context(), file)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Version restored successfully",
+		Data:    file.ForViewer(c.GetString("userID")),
+	})
+}
+
+// ownedFile loads the file named by the id parameter and checks that the
+// caller owns it or is an admin, writing the error response if not.
+func (h *FileHandler) ownedFile(c *gin.Context) (*models.File, bool) {
+	file, err := h.storageService.GetFile(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return nil, false
+	}
+
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot access other user's file"))
+		return nil, false
+	}
+
+	return file, true
+}
+
+func versionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Version must be a positive integer"))
+		return 0, false
+	}
+	return version, true
+}
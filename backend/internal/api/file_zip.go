@@ -0,0 +1,160 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// DownloadZip godoc
+// @Summary Download files as a ZIP archive
+// @Description Stream a ZIP archive of the given files, assembled on the fly from storage (users can only include their own files, admins can include any file)
+// @Tags files
+// @Accept json
+// @Produce application/zip
+// @Security BearerAuth
+// @Param request body models.ZipRequest true "Files to include"
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 409 {object} models.ErrorResponse "File still being scanned"
+// @Router /files/zip [post]
+func (h *FileHandler) DownloadZip(c *gin.Context) {
+	var req models.ZipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	// Resolve and authorize every file before the first byte is written, since
+	// errors can no longer be reported once the archive has started streaming
+	access := ginAccess(c)
+	files := make([]*models.File, 0, len(req.FileIDs))
+	seen := make(map[string]bool, len(req.FileIDs))
+	for _, fileID := range req.FileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+
+		file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found: "+fileID))
+			return
+		}
+
+		if !access.allows(c.Request.Context(), h.storageService, file.UserID, file.OrgID, models.PermFilesAdmin, false) {
+			respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot download other user's file"))
+			return
+		}
+
+		if !checkDownloadable(c, file) {
+			return
+		}
+
+		files = append(files, file)
+	}
+
+	archiveName := strings.TrimSuffix(req.Name, ".zip")
+	if archiveName == "" {
+		archiveName = "files"
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName + ".zip"}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	names := newZipEntryNames()
+	for _, file := range files {
+		if err := h.writeZipEntry(c, archive, names.next(file), file); err != nil {
+			// Headers are already sent; abort so the client sees a truncated archive
+			requestLogger(c).Error("Failed to add file to zip", "file", file.ID, "error", err)
+			c.Abort()
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		requestLogger(c).Error("Failed to finish zip archive", "error", err)
+	}
+}
+
+func (h *FileHandler) writeZipEntry(c *gin.Context, archive *zip.Writer, name string, file *models.File) error {
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), file.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// io.Copy moves data in small chunks straight from MinIO into the
+	// compressor, so whole files are never held in memory
+	written, err := io.Copy(entry, content)
+	recordDownload(h.messaging, c, file, models.AccessZip, "", written)
+	return err
+}
+
+// zipEntryName returns the path of file in an archive: its folder without
+// . or .. segments, and its name with separators replaced, as WebDAV lists
+// it. Names that would still leave the archive's root fall back to the ID.
+func zipEntryName(file *models.File) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.ReplaceAll(file.Folder, "\\", "/"), "/") {
+		if segment != "" && segment != "." && segment != ".." {
+			segments = append(segments, segment)
+		}
+	}
+	base := strings.NewReplacer("/", "_", "\\", "_").Replace(file.OriginalName)
+	if base == "." || base == ".." {
+		base = "_" + base
+	}
+	if base == "" {
+		base = file.ID
+	}
+
+	name := path.Join(append(segments, base)...)
+	if !fs.ValidPath(name) {
+		return file.ID
+	}
+	return name
+}
+
+// zipEntryNames keeps archive entry names unique, preserving virtual folders
+type zipEntryNames map[string]int
+
+func newZipEntryNames() zipEntryNames {
+	return make(zipEntryNames)
+}
+
+func (n zipEntryNames) next(file *models.File) string {
+	name := zipEntryName(file)
+	count := n[name]
+	n[name] = count + 1
+	if count == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), count, ext)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/requestid"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+// graphqlMaxDepth limits how deeply queries nest, which bounds the reads a
+// single query can cause
+const graphqlMaxDepth = 6
+
+// GraphQLHandler serves the GraphQL API, which reads users, posts and
+// files with their authors, owners, comments, posts and files nested
+type GraphQLHandler struct {
+	schema         *graphql.Schema
+	storageService *services.StorageService
+}
+
+func NewGraphQLHandler(storageService *services.StorageService) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema:         graphql.MustParseSchema(graphqlSchema, &graphqlResolver{storageService: storageService}, graphql.MaxDepth(graphqlMaxDepth)),
+		storageService: storageService,
+	}
+}
+
+// graphqlRequest is the body of a GraphQL request
+type graphqlRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Query godoc
+// @Summary Run a GraphQL query
+// @Description Run a query against the GraphQL schema of users, posts and files. Errors of fields are returned next to the data, with the API error code in their extensions.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body graphqlRequest true "GraphQL query"
+// @Success 200 {object} map[string]interface{} "Query result: data and errors"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Router /graphql [post]
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphqlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	role, _ := c.Get("permissions")
+	permissions, _ := role.(*models.Role)
+	ctx := newGraphQLCall(c.Request.Context(), h.storageService, graphqlViewer{
+		userID:           c.GetString("userID"),
+		serviceAccountID: c.GetString("serviceAccountID"),
+		permissions:      permissions,
+	})
+
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	graphqlErrors(ctx, response.Errors)
+	c.JSON(http.StatusOK, response)
+}
+
+// graphqlViewer is who makes a GraphQL request: what the middleware put
+// in the gin context
+type graphqlViewer struct {
+	userID           string
+	serviceAccountID string
+	permissions      *models.Role
+}
+
+func (v graphqlViewer) has(permission string) bool {
+	return v.permissions != nil && v.permissions.Has(permission)
+}
+
+func (v graphqlViewer) access() itemAccess {
+	return itemAccess{userID: v.userID, has: v.has}
+}
+
+// require returns a 403 unless the viewer has permission, like
+// RequirePermission
+func (v graphqlViewer) require(permission string) error {
+	if !v.has(permission) {
+		return apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission "+permission)
+	}
+	return nil
+}
+
+// graphqlCall is the state of one GraphQL request: the viewer and the
+// loaders its resolvers share
+type graphqlCall struct {
+	viewer         graphqlViewer
+	storageService *services.StorageService
+	users          *graphqlLoader[string, *models.User]
+	posts          *graphqlLoader[string, []*models.Post]
+	files          *graphqlLoader[string, []*models.File]
+	comments       *graphqlLoader[string, []*models.Comment]
+}
+
+type graphqlCallKey struct{}
+
+// newGraphQLCall returns ctx carrying the state of a request by viewer
+func newGraphQLCall(ctx context.Context, storageService *services.StorageService, viewer graphqlViewer) context.Context {
+	newestFirst := models.QueryOptions{Sort: []models.SortField{{Field: "createdAt", Desc: true}}}
+	postOpts, fileOpts := newestFirst, newestFirst
+	viewer.access().personalOnly(&postOpts, models.PermPostsAdmin)
+	viewer.access().reviewedOnly(&postOpts, "")
+	viewer.access().personalOnly(&fileOpts, models.PermFilesAdmin)
+	call := &graphqlCall{
+		viewer:         viewer,
+		storageService: storageService,
+		users:          newGraphQLLoader(ctx, graphqlBatchWait, storageService.GetUsers),
+		posts: newGraphQLLoader(ctx, graphqlBatchWait, func(ctx context.Context, userIDs []string) (map[string][]*models.Post, error) {
+			return storageService.ListPostsByUsers(ctx, userIDs, postOpts)
+		}),
+		files: newGraphQLLoader(ctx, graphqlBatchWait, func(ctx context.Context, userIDs []string) (map[string][]*models.File, error) {
+			return storageService.ListFilesByUsers(ctx, userIDs, fileOpts)
+		}),
+		// Comments are stored by post, so each post is one listing
+		comments: newGraphQLLoader(ctx, graphqlBatchWait, func(ctx context.Context, postIDs []string) (map[string][]*models.Comment, error) {
+			byPost := make(map[string][]*models.Comment, len(postIDs))
+			for _, postID := range postIDs {
+				comments, err := storageService.ListComments(ctx, postID)
+				if err != nil {
+					return nil, err
+				}
+				byPost[postID] = comments
+			}
+			return byPost, nil
+		}),
+	}
+	return context.WithValue(ctx, graphqlCallKey{}, call)
+}
+
+func graphqlCallFrom(ctx context.Context) *graphqlCall {
+	call, _ := ctx.Value(graphqlCallKey{}).(*graphqlCall)
+	if call == nil {
+		return &graphqlCall{}
+	}
+	return call
+}
+
+// graphqlErrors gives the errors of resolvers the code of their API error
+// in the extensions and hides the causes of internal errors, as
+// respondError does for REST
+func graphqlErrors(ctx context.Context, errs []*gqlerrors.QueryError) {
+	for _, err := range errs {
+		if err.ResolverError == nil {
+			continue
+		}
+		var apiErr *apierr.Error
+		if !errors.As(err.ResolverError, &apiErr) {
+			apiErr = apierr.Wrap(err.ResolverError, http.StatusInternalServerError, apierr.Internal, "Internal server error")
+		}
+		if apiErr.Status == http.StatusInternalServerError && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			apiErr = timeoutError()
+		}
+		if apiErr.Status >= http.StatusInternalServerError && apiErr.Err != nil {
+			logging.FromContext(ctx, slog.Default()).Error(apiErr.Message, "code", apiErr.Code, "error", apiErr.Err)
+		}
+
+		err.Message = apiErr.Message
+		err.Extensions = map[string]any{"code": apiErr.Code, "status": apiErr.Status}
+		if len(apiErr.Fields) > 0 {
+			err.Extensions["fields"] = apiErr.Fields
+		}
+		if id := requestid.FromContext(ctx); id != "" {
+			err.Extensions["requestId"] = id
+		}
+	}
+}
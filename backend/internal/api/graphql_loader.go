@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// graphqlBatchWait is how long a loader collects keys before fetching them.
+// GraphQL resolves the items of a list concurrently, so their lookups all
+// arrive within it.
+const graphqlBatchWait = 2 * time.Millisecond
+
+// graphqlLoader batches the lookups the resolvers of one GraphQL request
+// make at about the same time: keys asked for while a batch collects are
+// fetched together, and each key only once per request. Listing posts with
+// their authors then reads every author once, not once per post.
+type graphqlLoader[K comparable, V any] struct {
+	ctx   context.Context
+	wait  time.Duration
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	results map[K]*loaderResult[V]
+	pending []K
+}
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// newGraphQLLoader returns a loader for the request of ctx. fetch leaves
+// keys it finds nothing for out of its map; they load as the zero value.
+func newGraphQLLoader[K comparable, V any](ctx context.Context, wait time.Duration, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *graphqlLoader[K, V] {
+	return &graphqlLoader[K, V]{ctx: ctx, wait: wait, fetch: fetch, results: map[K]*loaderResult[V]{}}
+}
+
+// load returns the value of key, fetched with the other keys of its batch
+func (l *graphqlLoader[K, V]) load(key K) (V, error) {
+	l.mu.Lock()
+	result, ok := l.results[key]
+	if !ok {
+		result = &loaderResult[V]{done: make(chan struct{})}
+		l.results[key] = result
+		if len(l.pending) == 0 {
+			time.AfterFunc(l.wait, l.dispatch)
+		}
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.err
+	case <-l.ctx.Done():
+		var zero V
+		return zero, l.ctx.Err()
+	}
+}
+
+// dispatch fetches the pending batch
+func (l *graphqlLoader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	values, err := l.fetch(l.ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		result := l.results[key]
+		result.value, result.err = values[key], err
+		close(result.done)
+	}
+}
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// graphqlResolver resolves the queries of schema.graphql like the read
+// routes of the REST API, with the same permissions
+type graphqlResolver struct {
+	storageService *services.StorageService
+}
+
+// graphqlListArgs are the arguments of the list queries
+type graphqlListArgs struct {
+	Page     *int32
+	PageSize *int32
+	Sort     *string
+	Filters  *[]graphqlFilter
+}
+
+type graphqlFilter struct {
+	Field string
+	Op    *string
+	Value string
+}
+
+// options parses the arguments like QueryMiddleware parses a query string,
+// rejecting fields spec does not filter by
+func (a graphqlListArgs) options(spec QuerySpec) (models.Pagination, models.QueryOptions, error) {
+	values := url.Values{}
+	if a.Sort != nil {
+		values.Set("sort", *a.Sort)
+	}
+	var fields []models.FieldError
+	if a.Filters != nil {
+		for i, filter := range *a.Filters {
+			if _, ok := spec.Filters[filter.Field]; !ok {
+				name := fmt.Sprintf("filters[%d].field", i)
+				fields = append(fields, models.FieldError{Name: name, Rule: "oneof",
+					Message: name + " must be one of " + strings.Join(slices.Sorted(maps.Keys(spec.Filters)), ", ")})
+				continue
+			}
+			key := filter.Field
+			if filter.Op != nil && *filter.Op != "" {
+				key += "[" + *filter.Op + "]"
+			}
+			values.Set(key, filter.Value)
+		}
+	}
+	opts, invalid := parseQuery(values, spec)
+	if fields = append(fields, invalid...); len(fields) > 0 {
+		return models.Pagination{}, opts, validationError(fields)
+	}
+
+	var page, pageSize int
+	if a.Page != nil {
+		page = int(*a.Page)
+	}
+	if a.PageSize != nil {
+		pageSize = int(*a.PageSize)
+	}
+	return newPagination(page, pageSize), opts, nil
+}
+
+func (r *graphqlResolver) Me(ctx context.Context) (*graphqlUser, error) {
+	viewer := graphqlCallFrom(ctx).viewer
+	if viewer.serviceAccountID != "" {
+		return nil, apierr.New(http.StatusForbidden, apierr.ServiceAccountDenied, "Not allowed for service accounts")
+	}
+
+	user, err := r.storageService.GetUser(ctx, viewer.userID)
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found")
+	}
+	return &graphqlUser{user.ToUserResponse()}, nil
+}
+
+func (r *graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*graphqlUser, error) {
+	if err := graphqlCallFrom(ctx).viewer.require(models.PermUsersRead); err != nil {
+		return nil, err
+	}
+
+	user, err := r.storageService.GetUser(ctx, string(args.ID))
+	if errors.Is(err, services.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get user")
+	}
+	return &graphqlUser{user.ToUserResponse()}, nil
+}
+
+func (r *graphqlResolver) Users(ctx context.Context, args graphqlListArgs) (*graphqlUserPage, error) {
+	if err := graphqlCallFrom(ctx).viewer.require(models.PermUsersRead); err != nil {
+		return nil, err
+	}
+	pagination, opts, err := args.options(userQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := r.storageService.ListUsers(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list users")
+	}
+
+	page := &graphqlUserPage{items: make([]*graphqlUser, len(users))}
+	for i, user := range users {
+		page.items[i] = &graphqlUser{user.ToUserResponse()}
+	}
+	pagination.Total = total
+	page.pagination = graphqlPagination{pagination}
+	return page, nil
+}
+
+func (r *graphqlResolver) Post(ctx context.Context, args struct{ ID graphql.ID }) (*graphqlPost, error) {
+	viewer := graphqlCallFrom(ctx).viewer
+	if err := viewer.require(models.PermPostsRead); err != nil {
+		return nil, err
+	}
+
+	// GetPost fails only when no post has the ID. Posts of organizations
+	// the viewer is not in do not exist for them.
+	post, err := r.storageService.GetPost(ctx, string(args.ID))
+	if err != nil || !viewer.access().canReadPost(ctx, r.storageService, post) {
+		return nil, nil
+	}
+	return &graphqlPost{post}, nil
+}
+
+func (r *graphqlResolver) Posts(ctx context.Context, args graphqlListArgs) (*graphqlPostPage, error) {
+	viewer := graphqlCallFrom(ctx).viewer
+	if err := viewer.require(models.PermPostsRead); err != nil {
+		return nil, err
+	}
+	pagination, opts, err := args.options(postQuery)
+	if err != nil {
+		return nil, err
+	}
+	viewer.access().personalOnly(&opts, models.PermPostsAdmin)
+	viewer.access().reviewedOnly(&opts, "")
+
+	posts, total, err := r.storageService.ListPosts(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list posts")
+	}
+
+	pagination.Total = total
+	return &graphqlPostPage{items: graphqlPosts(posts), pagination: graphqlPagination{pagination}}, nil
+}
+
+func (r *graphqlResolver) File(ctx context.Context, args struct{ ID graphql.ID }) (*graphqlFile, error) {
+	viewer := graphqlCallFrom(ctx).viewer
+	if err := viewer.require(models.PermFilesRead); err != nil {
+		return nil, err
+	}
+
+	file, err := r.storageService.GetFile(ctx, string(args.ID))
+	if errors.Is(err, services.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get file")
+	}
+	if file.OrgID != "" && !viewer.access().canReadFile(ctx, r.storageService, file) {
+		return nil, nil
+	}
+	return &graphqlFile{file.ForViewer(viewer.userID)}, nil
+}
+
+func (r *graphqlResolver) Files(ctx context.Context, args graphqlListArgs) (*graphqlFilePage, error) {
+	viewer := graphqlCallFrom(ctx).viewer
+	if err := viewer.require(models.PermFilesRead); err != nil {
+		return nil, err
+	}
+	pagination, opts, err := args.options(fileQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only file admins see everyone's files
+	if !viewer.has(models.PermFilesAdmin) {
+		opts.Filters = append(opts.Filters, models.Filter{Field: "userId", Op: models.OpEq, Value: viewer.userID})
+	}
+	viewer.access().personalOnly(&opts, models.PermFilesAdmin)
+
+	files, total, err := r.storageService.ListFiles(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list files")
+	}
+
+	pagination.Total = total
+	return &graphqlFilePage{items: graphqlFiles(files, viewer.userID), pagination: graphqlPagination{pagination}}, nil
+}
+
+// loadUser resolves a nested user through the request's user loader
+func loadUser(ctx context.Context, userID string) (*graphqlUser, error) {
+	call := graphqlCallFrom(ctx)
+	if err := call.viewer.require(models.PermUsersRead); err != nil {
+		return nil, err
+	}
+
+	user, err := call.users.load(userID)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get user")
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &graphqlUser{user.ToUserResponse()}, nil
+}
+
+type graphqlPagination struct {
+	pagination models.Pagination
+}
+
+func (p graphqlPagination) Page() int32     { return int32(p.pagination.Page) }
+func (p graphqlPagination) PageSize() int32 { return int32(p.pagination.PageSize) }
+func (p graphqlPagination) Total() int32    { return int32(p.pagination.Total) }
+
+type graphqlUser struct {
+	user *models.UserResponse
+}
+
+func (u *graphqlUser) ID() graphql.ID          { return graphql.ID(u.user.ID) }
+func (u *graphqlUser) Username() string        { return u.user.Username }
+func (u *graphqlUser) Email() string           { return u.user.Email }
+func (u *graphqlUser) FirstName() string       { return u.user.FirstName }
+func (u *graphqlUser) LastName() string        { return u.user.LastName }
+func (u *graphqlUser) Role() string            { return u.user.Role }
+func (u *graphqlUser) Status() string          { return u.user.Status }
+func (u *graphqlUser) EmailVerified() bool     { return u.user.EmailVerified }
+func (u *graphqlUser) CreatedAt() graphql.Time { return graphql.Time{Time: u.user.CreatedAt} }
+func (u *graphqlUser) UpdatedAt() graphql.Time { return graphql.Time{Time: u.user.UpdatedAt} }
+func (u *graphqlUser) LastLoginAt() *graphql.Time {
+	return graphqlTime(u.user.LastLoginAt)
+}
+
+// Posts resolves the user's posts through the request's post loader, so
+// the posts of every user of a list come from one listing
+func (u *graphqlUser) Posts(ctx context.Context, args struct{ First int32 }) ([]*graphqlPost, error) {
+	call := graphqlCallFrom(ctx)
+	if err := call.viewer.require(models.PermPostsRead); err != nil {
+		return nil, err
+	}
+
+	posts, err := call.posts.load(u.user.ID)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list user posts")
+	}
+	return graphqlPosts(posts[:min(max(int(args.First), 0), len(posts))]), nil
+}
+
+// Files resolves the user's files through the request's file loader.
+// Like the REST API, only file admins see other users' files.
+func (u *graphqlUser) Files(ctx context.Context, args struct{ First int32 }) ([]*graphqlFile, error) {
+	call := graphqlCallFrom(ctx)
+	if err := call.viewer.require(models.PermFilesRead); err != nil {
+		return nil, err
+	}
+	if u.user.ID != call.viewer.userID && !call.viewer.has(models.PermFilesAdmin) {
+		return nil, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot list other user's files")
+	}
+
+	files, err := call.files.load(u.user.ID)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list user files")
+	}
+	return graphqlFiles(files[:min(max(int(args.First), 0), len(files))], call.viewer.userID), nil
+}
+
+type graphqlUserPage struct {
+	items      []*graphqlUser
+	pagination graphqlPagination
+}
+
+func (p *graphqlUserPage) Items() []*graphqlUser         { return p.items }
+func (p *graphqlUserPage) Pagination() graphqlPagination { return p.pagination }
+
+type graphqlPost struct {
+	post *models.Post
+}
+
+func graphqlPosts(posts []*models.Post) []*graphqlPost {
+	resolvers := make([]*graphqlPost, len(posts))
+	for i, post := range posts {
+		resolvers[i] = &graphqlPost{post}
+	}
+	return resolvers
+}
+
+func (p *graphqlPost) ID() graphql.ID             { return graphql.ID(p.post.ID) }
+func (p *graphqlPost) Title() string              { return p.post.Title }
+func (p *graphqlPost) Content() string            { return p.post.Content }
+func (p *graphqlPost) Summary() string            { return p.post.Summary }
+func (p *graphqlPost) Tags() []string             { return append([]string{}, p.post.Tags...) }
+func (p *graphqlPost) Status() string             { return p.post.Status }
+func (p *graphqlPost) CreatedAt() graphql.Time    { return graphql.Time{Time: p.post.CreatedAt} }
+func (p *graphqlPost) UpdatedAt() graphql.Time    { return graphql.Time{Time: p.post.UpdatedAt} }
+func (p *graphqlPost) PublishedAt() *graphql.Time { return graphqlTime(p.post.PublishedAt) }
+
+func (p *graphqlPost) Author(ctx context.Context) (*graphqlUser, error) {
+	return loadUser(ctx, p.post.UserID)
+}
+
+// Comments resolves the comments of the post through the request's
+// comment loader, withheld ones redacted like ListComments does
+func (p *graphqlPost) Comments(ctx context.Context) ([]*graphqlComment, error) {
+	call := graphqlCallFrom(ctx)
+	comments, err := call.comments.load(p.post.ID)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list comments")
+	}
+
+	moderator := call.viewer.access().canChangePost(ctx, call.storageService, p.post)
+	resolvers := make([]*graphqlComment, len(comments))
+	for i, comment := range comments {
+		resolvers[i] = &graphqlComment{commentForViewer(comment, call.viewer.userID, moderator)}
+	}
+	return resolvers, nil
+}
+
+type graphqlPostPage struct {
+	items      []*graphqlPost
+	pagination graphqlPagination
+}
+
+func (p *graphqlPostPage) Items() []*graphqlPost         { return p.items }
+func (p *graphqlPostPage) Pagination() graphqlPagination { return p.pagination }
+
+type graphqlComment struct {
+	comment *models.Comment
+}
+
+func (c *graphqlComment) ID() graphql.ID          { return graphql.ID(c.comment.ID) }
+func (c *graphqlComment) Depth() int32            { return int32(c.comment.Depth) }
+func (c *graphqlComment) Content() string         { return c.comment.Content }
+func (c *graphqlComment) Status() string          { return c.comment.Status }
+func (c *graphqlComment) Replies() int32          { return int32(c.comment.Replies) }
+func (c *graphqlComment) CreatedAt() graphql.Time { return graphql.Time{Time: c.comment.CreatedAt} }
+func (c *graphqlComment) UpdatedAt() graphql.Time { return graphql.Time{Time: c.comment.UpdatedAt} }
+
+func (c *graphqlComment) ParentID() *graphql.ID {
+	if c.comment.ParentID == "" {
+		return nil
+	}
+	id := graphql.ID(c.comment.ParentID)
+	return &id
+}
+
+func (c *graphqlComment) Author(ctx context.Context) (*graphqlUser, error) {
+	return loadUser(ctx, c.comment.UserID)
+}
+
+type graphqlFile struct {
+	file *models.File
+}
+
+func graphqlFiles(files []*models.File, viewerID string) []*graphqlFile {
+	resolvers := make([]*graphqlFile, len(files))
+	for i, file := range files {
+		resolvers[i] = &graphqlFile{file.ForViewer(viewerID)}
+	}
+	return resolvers
+}
+
+func (f *graphqlFile) ID() graphql.ID           { return graphql.ID(f.file.ID) }
+func (f *graphqlFile) OriginalName() string     { return f.file.OriginalName }
+func (f *graphqlFile) ContentType() string      { return f.file.ContentType }
+func (f *graphqlFile) Size() float64            { return float64(f.file.Size) }
+func (f *graphqlFile) Folder() string           { return f.file.Folder }
+func (f *graphqlFile) Description() string      { return f.file.Description }
+func (f *graphqlFile) Visibility() string       { return f.file.Visibility }
+func (f *graphqlFile) ScanStatus() string       { return f.file.ScanStatus }
+func (f *graphqlFile) Version() int32           { return int32(f.file.Version) }
+func (f *graphqlFile) ExpiresAt() *graphql.Time { return graphqlTime(f.file.ExpiresAt) }
+func (f *graphqlFile) CreatedAt() graphql.Time  { return graphql.Time{Time: f.file.CreatedAt} }
+func (f *graphqlFile) UpdatedAt() graphql.Time  { return graphql.Time{Time: f.file.UpdatedAt} }
+
+func (f *graphqlFile) Owner(ctx context.Context) (*graphqlUser, error) {
+	return loadUser(ctx, f.file.UserID)
+}
+
+type graphqlFilePage struct {
+	items      []*graphqlFile
+	pagination graphqlPagination
+}
+
+func (p *graphqlFilePage) Items() []*graphqlFile         { return p.items }
+func (p *graphqlFilePage) Pagination() graphqlPagination { return p.pagination }
+
+func graphqlTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLLoader(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	loader := newGraphQLLoader(context.Background(), 20*time.Millisecond, func(_ context.Context, keys []string) (map[string]string, error) {
+		mu.Lock()
+		batches = append(batches, slices.Sorted(slices.Values(keys)))
+		mu.Unlock()
+		values := map[string]string{}
+		for _, key := range keys {
+			if key != "missing" {
+				values[key] = strings.ToUpper(key)
+			}
+		}
+		return values, nil
+	})
+
+	// Lookups made together are fetched in one batch, each key once
+	keys := []string{"a", "b", "a", "missing", "b", "c"}
+	values := make([]string, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := loader.load(key)
+			assert.NoError(t, err)
+			values[i] = value
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"A", "B", "A", "", "B", "C"}, values)
+	assert.Equal(t, [][]string{{"a", "b", "c", "missing"}}, batches)
+
+	// Loaded keys are not fetched again
+	value, err := loader.load("c")
+	require.NoError(t, err)
+	assert.Equal(t, "C", value)
+	_, err = loader.load("d")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b", "c", "missing"}, {"d"}}, batches)
+
+	// A failed fetch fails every key of its batch
+	failing := newGraphQLLoader(context.Background(), time.Millisecond, func(context.Context, []string) (map[string]string, error) {
+		return nil, errors.New("bucket gone")
+	})
+	_, err = failing.load("a")
+	assert.EqualError(t, err, "bucket gone")
+}
+
+func TestGraphQLPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewGraphQLHandler(nil)
+	query := func(permissions []string, body string) map[string]any {
+		router := gin.New()
+		router.POST("/graphql", func(c *gin.Context) {
+			c.Set("userID", "user-1")
+			c.Set("permissions", &models.Role{Permissions: permissions})
+		}, handler.Query)
+
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	firstError := func(response map[string]any) map[string]any {
+		errs, _ := response["errors"].([]any)
+		require.NotEmpty(t, errs, response)
+		return errs[0].(map[string]any)
+	}
+
+	// Fields need the permissions of their REST routes
+	err := firstError(query(nil, `{"query": "{ users { items { id } } }"}`))
+	assert.Equal(t, "Missing permission users:read", err["message"])
+	assert.Equal(t, "PERMISSION_DENIED", err["extensions"].(map[string]any)["code"])
+
+	// Filters are checked like query strings
+	err = firstError(query([]string{models.PermPostsRead}, `{"query": "{ posts(filters: [{field: \"password\", value: \"x\"}]) { items { id } } }"}`))
+	extensions := err["extensions"].(map[string]any)
+	assert.Equal(t, "VALIDATION_FAILED", extensions["code"])
+	fields := extensions["fields"].([]any)
+	require.Len(t, fields, 1)
+	assert.Equal(t, "filters[0].field", fields[0].(map[string]any)["name"])
+
+	// Queries nest no deeper than the limit
+	err = firstError(query([]string{models.PermPostsRead}, `{"query": "{ posts { items { author { posts { author { posts { author { id } } } } } } } }"}`))
+	assert.Contains(t, err["message"], "exceeds max depth")
+}
+
+func TestGraphQLComments(t *testing.T) {
+	api := newTestAPI(t)
+	author, authorToken := api.user("bob", models.RoleUser)
+	_, readerToken := api.user("alice", models.RoleUser)
+	path := commentPost(t, api, author)
+	postID := strings.Split(path, "/")[4]
+
+	first := createdComment(api, path, readerToken, "", "First")
+	reply := createdComment(api, path, authorToken, first.ID, "Thanks")
+	rude := createdComment(api, path, readerToken, "", "Rude")
+	w := api.do(http.MethodPut, path+"/"+rude.ID+"/moderation", authorToken, map[string]string{"status": models.CommentStatusHidden})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	type comment struct {
+		ID       string
+		ParentID *string
+		Depth    int
+		Content  string
+		Status   string
+		Replies  int
+		Author   struct{ Username string }
+	}
+	comments := func(token string) []comment {
+		t.Helper()
+		query := `{ post(id: "` + postID + `") { comments { id parentId depth content status replies author { username } } } }`
+		w := api.do(http.MethodPost, "/api/v1/graphql", token, map[string]string{"query": query})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var data struct{ Post struct{ Comments []comment } }
+		decode(t, w, &data)
+		return data.Post.Comments
+	}
+
+	list := comments(readerToken)
+	require.Len(t, list, 3)
+	assert.Equal(t, first.ID, list[0].ID)
+	assert.Nil(t, list[0].ParentID)
+	assert.Equal(t, 1, list[0].Replies)
+	assert.Equal(t, "alice", list[0].Author.Username)
+	assert.Equal(t, reply.ID, list[1].ID)
+	require.NotNil(t, list[1].ParentID)
+	assert.Equal(t, first.ID, *list[1].ParentID)
+	assert.Equal(t, 1, list[1].Depth)
+
+	// Hidden comments keep their content for their author and the
+	// moderators of the post only
+	assert.Equal(t, "Rude", list[2].Content)
+	assert.Equal(t, "Rude", comments(authorToken)[2].Content)
+	_, otherToken := api.user("carol", models.RoleUser)
+	hidden := comments(otherToken)[2]
+	assert.Equal(t, models.CommentStatusHidden, hidden.Status)
+	assert.Empty(t, hidden.Content)
+}
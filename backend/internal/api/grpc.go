@@ -0,0 +1,490 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/ratelimit"
+	"github.com/minio-fullstack-storage/backend/internal/requestid"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/spam"
+	storagev1 "github.com/minio-fullstack-storage/backend/proto/storage/v1"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// grpcErrorDomain is the domain of the ErrorInfo details of gRPC errors
+const grpcErrorDomain = "minio-storage-system"
+
+// grpcMethod is what a method of the gRPC API needs from its caller, like
+// the RequirePermission and MaintenanceMiddleware of its REST route
+type grpcMethod struct {
+	permissions []string
+	write       bool // refused during maintenance
+}
+
+var grpcMethods = map[string]grpcMethod{
+	storagev1.UserService_GetCurrentUser_FullMethodName: {},
+	storagev1.UserService_GetUser_FullMethodName:        {permissions: []string{models.PermUsersRead}},
+	storagev1.UserService_ListUsers_FullMethodName:      {permissions: []string{models.PermUsersRead}},
+	storagev1.PostService_CreatePost_FullMethodName:     {permissions: []string{models.PermPostsRead, models.PermPostsWrite}, write: true},
+	storagev1.PostService_GetPost_FullMethodName:        {permissions: []string{models.PermPostsRead}},
+	storagev1.PostService_ListPosts_FullMethodName:      {permissions: []string{models.PermPostsRead}},
+	storagev1.PostService_UpdatePost_FullMethodName:     {permissions: []string{models.PermPostsRead, models.PermPostsWrite}, write: true},
+	storagev1.PostService_DeletePost_FullMethodName:     {permissions: []string{models.PermPostsRead, models.PermPostsWrite}, write: true},
+	storagev1.FileService_GetFile_FullMethodName:        {permissions: []string{models.PermFilesRead}},
+	storagev1.FileService_ListFiles_FullMethodName:      {permissions: []string{models.PermFilesRead}},
+	storagev1.FileService_DownloadFile_FullMethodName:   {permissions: []string{models.PermFilesRead}},
+	storagev1.FileService_DeleteFile_FullMethodName:     {permissions: []string{models.PermFilesRead, models.PermFilesWrite}, write: true},
+}
+
+// grpcPublicServices describe the server rather than its data, so their
+// methods need no account
+var grpcPublicServices = []string{
+	grpc_reflection_v1.ServerReflection_ServiceDesc.ServiceName,
+	grpc_reflection_v1alpha.ServerReflection_ServiceDesc.ServiceName,
+	grpc_health_v1.Health_ServiceDesc.ServiceName,
+}
+
+// grpcCaller is who makes a gRPC call: what AuthMiddleware,
+// ActiveUserMiddleware and PermissionMiddleware put in the gin context of
+// REST requests
+type grpcCaller struct {
+	userID           string
+	username         string
+	role             string
+	impersonatorID   string
+	serviceAccountID string
+	permissions      *models.Role
+	ip               string
+	userAgent        string
+}
+
+type grpcCallerKey struct{}
+
+// grpcCallerFrom returns the caller authenticated for the call of ctx
+func grpcCallerFrom(ctx context.Context) *grpcCaller {
+	if caller, ok := ctx.Value(grpcCallerKey{}).(*grpcCaller); ok {
+		return caller
+	}
+	return &grpcCaller{}
+}
+
+// has reports whether the caller's role grants permission
+func (c *grpcCaller) has(permission string) bool {
+	return c.permissions != nil && c.permissions.Has(permission)
+}
+
+func (c *grpcCaller) access() itemAccess {
+	return itemAccess{userID: c.userID, has: c.has}
+}
+
+// spamSender describes the caller to the spam checker, as spamSender does
+// for REST requests
+func (c *grpcCaller) spamSender() spam.Sender {
+	return spam.Sender{IP: c.ip, UserAgent: c.userAgent}
+}
+
+// adminAction fills in the actor and client details of action, as
+// recordAdminAction does for REST requests
+func (c *grpcCaller) adminAction(action models.AdminAction) models.AdminAction {
+	action.ActorID = c.userID
+	if c.impersonatorID != "" {
+		action.ActorID = c.impersonatorID
+	}
+	action.IP = c.ip
+	action.UserAgent = c.userAgent
+	return action
+}
+
+// grpcInterceptor runs the checks of the protected REST routes for every
+// gRPC call: the token or service account key, the rate limit, the
+// account status, the permissions and maintenance mode. It also gives each
+// call a request ID and logger, recovers panics and converts errors to
+// gRPC statuses.
+type grpcInterceptor struct {
+	jwtManager     *auth.JWTManager
+	denylist       *auth.Denylist
+	limiter        *ratelimit.Limiter
+	storageService *services.StorageService
+	live           *config.Live
+	logger         *slog.Logger
+}
+
+// NewGRPCServer returns the gRPC API, the user, post and file services of
+// proto/storage/v1 over the same storage service and rules as the REST
+// API. opts are added to the server's, such as its TLS credentials.
+// Without Redis tokens are not checked for revocation and calls are not
+// rate limited.
+func NewGRPCServer(live *config.Live, storageService *services.StorageService, messagingClient *messaging.Client, redisClient *redis.Client, logger *slog.Logger, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	cfg := live.Get()
+
+	jwtManager, err := newJWTManager(cfg.JWT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+	}
+	interceptor := &grpcInterceptor{
+		jwtManager:     jwtManager,
+		storageService: storageService,
+		live:           live,
+		logger:         logger,
+	}
+	if redisClient != nil {
+		interceptor.denylist = newDenylist(redisClient, jwtManager.TTL())
+		interceptor.limiter = ratelimit.NewLimiter(redisClient)
+	}
+
+	opts = append(opts, grpc.ChainUnaryInterceptor(interceptor.unary), grpc.ChainStreamInterceptor(interceptor.stream))
+	server := grpc.NewServer(opts...)
+	storagev1.RegisterUserServiceServer(server, &grpcUserService{storageService: storageService})
+	storagev1.RegisterPostServiceServer(server, &grpcPostService{storageService: storageService, messaging: messagingClient})
+	storagev1.RegisterFileServiceServer(server, &grpcFileService{storageService: storageService, messaging: messagingClient})
+	if cfg.GRPC.Reflection {
+		reflection.Register(server)
+	}
+	return server, nil
+}
+
+func (i *grpcInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var resp any
+	err := i.intercept(ctx, info.FullMethod, func(ctx context.Context) error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (i *grpcInterceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return i.intercept(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+		return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+	})
+}
+
+// grpcServerStream passes the context of the interceptor to the handler
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// intercept runs call for fullMethod once its caller is authenticated, and
+// logs it like AccessLogMiddleware logs requests
+func (i *grpcInterceptor) intercept(ctx context.Context, fullMethod string, call func(ctx context.Context) error) (err error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstValue(md, strings.ToLower(requestid.Header))
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestid.Header), id))
+	logger := i.logger.With("requestId", id)
+	ctx = logging.NewContext(requestid.NewContext(ctx, id), logger)
+
+	caller := &grpcCaller{ip: peerIP(ctx), userAgent: firstValue(md, "user-agent")}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("Panic while handling gRPC call", "panic", fmt.Sprint(recovered), "method", fullMethod, "stack", string(debug.Stack()))
+			err = apierr.New(http.StatusInternalServerError, apierr.Internal, "Internal server error")
+		}
+		err = grpcError(ctx, err)
+		i.log(ctx, logger, fullMethod, caller, status.Code(err), time.Since(start))
+	}()
+
+	callCtx, err := i.authenticate(ctx, fullMethod, md, caller)
+	if err != nil {
+		return err
+	}
+	return call(callCtx)
+}
+
+// log writes the line of a call, sampled like the access log of requests
+func (i *grpcInterceptor) log(ctx context.Context, logger *slog.Logger, fullMethod string, caller *grpcCaller, code codes.Code, latency time.Duration) {
+	cfg := i.live.Get().Log
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+		if latency < time.Duration(cfg.SlowRequest)*time.Millisecond && rand.Float64() >= cfg.AccessSampleRate {
+			return
+		}
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		level = slog.LevelError
+	}
+	logger.LogAttrs(ctx, level, "grpc call",
+		slog.String("method", fullMethod),
+		slog.String("code", code.String()),
+		slog.Float64("latencyMs", float64(latency.Microseconds())/1000),
+		slog.String("userId", caller.userID),
+		slog.String("ip", caller.ip),
+		slog.String("userAgent", caller.userAgent),
+	)
+}
+
+// authenticate checks the caller of fullMethod and returns ctx carrying it.
+// Methods of grpcPublicServices need no account; other methods missing from
+// grpcMethods are refused, so that one added without its rules is not open.
+func (i *grpcInterceptor) authenticate(ctx context.Context, fullMethod string, md metadata.MD, caller *grpcCaller) (context.Context, error) {
+	method, ok := grpcMethods[fullMethod]
+	if !ok {
+		service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+		if slices.Contains(grpcPublicServices, service) {
+			return ctx, nil
+		}
+		return nil, apierr.New(http.StatusNotImplemented, apierr.NotFound, "Unknown method")
+	}
+
+	authorization := firstValue(md, "authorization")
+	if authorization == "" {
+		return nil, apierr.New(http.StatusUnauthorized, apierr.TokenMissing, "Authorization metadata required")
+	}
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || scheme != "Bearer" || token == "" {
+		return nil, apierr.New(http.StatusUnauthorized, apierr.TokenInvalid, "Invalid authorization metadata format")
+	}
+
+	if strings.HasPrefix(token, models.ServiceAccountKeyPrefix) {
+		account, err := i.storageService.AuthenticateServiceAccount(ctx, token, caller.ip)
+		if errors.Is(err, services.ErrInvalidServiceKey) {
+			return nil, apierr.New(http.StatusUnauthorized, apierr.InvalidServiceKey, "Invalid service account key")
+		}
+		if err != nil {
+			return nil, apierr.Wrap(err, http.StatusServiceUnavailable, apierr.Unavailable, "Unable to verify service account key")
+		}
+		caller.userID = account.ID
+		caller.username = account.Name
+		caller.serviceAccountID = account.ID
+		caller.permissions = account.Role()
+	} else {
+		claims, err := i.jwtManager.ValidateToken(token)
+		if err != nil {
+			return nil, apierr.New(http.StatusUnauthorized, apierr.TokenInvalid, "Invalid token")
+		}
+		if i.denylist != nil {
+			// Fail closed, as AuthMiddleware does
+			revoked, err := i.denylist.IsRevoked(ctx, claims)
+			if err != nil {
+				return nil, apierr.Wrap(err, http.StatusServiceUnavailable, apierr.Unavailable, "Unable to verify token")
+			}
+			if revoked {
+				return nil, apierr.New(http.StatusUnauthorized, apierr.TokenRevoked, "Token has been revoked")
+			}
+		}
+		caller.userID = claims.UserID
+		caller.username = claims.Username
+		caller.role = claims.Role
+		if claims.Act != nil {
+			caller.impersonatorID = claims.Act.UserID
+		}
+	}
+
+	if err := i.takeRateLimit(ctx, caller); err != nil {
+		return nil, err
+	}
+	if caller.serviceAccountID == "" {
+		if err := i.checkAccounts(ctx, caller); err != nil {
+			return nil, err
+		}
+		if err := i.loadPermissions(ctx, caller); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, permission := range method.permissions {
+		if !caller.has(permission) {
+			return nil, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission "+permission)
+		}
+	}
+	if method.write {
+		if err := i.checkMaintenance(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return context.WithValue(ctx, grpcCallerKey{}, caller), nil
+}
+
+// takeRateLimit counts the call against the caller's bucket of the api
+// group, which REST requests share
+func (i *grpcInterceptor) takeRateLimit(ctx context.Context, caller *grpcCaller) error {
+	limit, enabled := rateLimit(i.live, "api")()
+	if i.limiter == nil || !enabled {
+		return nil
+	}
+
+	result, err := i.limiter.Take(ctx, "api:user:"+caller.userID, limit)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Rate limiting failed", "error", err)
+		return nil
+	}
+	if !result.Allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ceilSeconds(result.RetryAfter))))
+		return apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Rate limit exceeded, try again later")
+	}
+	return nil
+}
+
+// checkAccounts rejects callers whose account, or whose impersonating
+// admin's account, is no longer active
+func (i *grpcInterceptor) checkAccounts(ctx context.Context, caller *grpcCaller) error {
+	users := []string{caller.userID}
+	if caller.impersonatorID != "" {
+		users = append(users, caller.impersonatorID)
+	}
+
+	for _, userID := range users {
+		status, err := i.storageService.CachedUserStatus(ctx, userID)
+		if errors.Is(err, services.ErrUserNotFound) {
+			return apierr.New(http.StatusUnauthorized, apierr.AccountDeleted, "Account no longer exists")
+		}
+		if err != nil {
+			return apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to verify account")
+		}
+		if status != models.UserStatusActive {
+			return apierr.New(http.StatusForbidden, apierr.AccountInactive, "This account has been "+status+"; contact an administrator")
+		}
+	}
+	return nil
+}
+
+// loadPermissions loads the caller's role; users whose role was deleted
+// keep no permissions
+func (i *grpcInterceptor) loadPermissions(ctx context.Context, caller *grpcCaller) error {
+	role, err := i.storageService.CachedRole(ctx, caller.role)
+	if errors.Is(err, services.ErrRoleNotFound) {
+		role = &models.Role{Name: caller.role}
+	} else if err != nil {
+		return apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to load permissions")
+	}
+	caller.permissions = role
+	return nil
+}
+
+// checkMaintenance refuses changes while maintenance mode is on, letting
+// them through when the mode cannot be read
+func (i *grpcInterceptor) checkMaintenance(ctx context.Context) error {
+	maintenance, err := currentMaintenance(ctx, i.storageService, i.live.Get().Maintenance)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to check maintenance mode", "error", err)
+		return nil
+	}
+	if !maintenance.Enabled {
+		return nil
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(maintenance.RetryAfter)))
+	return apierr.New(http.StatusServiceUnavailable, apierr.Maintenance, maintenance.Message)
+}
+
+// grpcCodes gives the statuses of API errors their gRPC codes
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.FailedPrecondition,
+	http.StatusGone:                  codes.NotFound,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcError converts err into a gRPC status as respondError converts it
+// into a response. The API error code is the reason of an ErrorInfo
+// detail, next to the request ID, and invalid fields are the violations
+// of a BadRequest detail. Statuses pass through unchanged.
+func grpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Internal server error")
+	}
+	if apiErr.Status == http.StatusInternalServerError && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		apiErr = timeoutError()
+	}
+	if apiErr.Status >= http.StatusInternalServerError && apiErr.Err != nil {
+		logging.FromContext(ctx, slog.Default()).Error(apiErr.Message, "code", apiErr.Code, "error", apiErr.Err)
+	}
+
+	code, ok := grpcCodes[apiErr.Status]
+	switch {
+	case ok:
+	case apiErr.Status < http.StatusInternalServerError:
+		code = codes.FailedPrecondition
+	default:
+		code = codes.Internal
+	}
+
+	st := status.New(code, apiErr.Message)
+	info := &errdetails.ErrorInfo{Reason: string(apiErr.Code), Domain: grpcErrorDomain}
+	if id := requestid.FromContext(ctx); id != "" {
+		info.Metadata = map[string]string{"requestId": id}
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(apiErr.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(apiErr.Fields))
+		for i, field := range apiErr.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: field.Name, Description: field.Message}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// firstValue returns the first value of key in md, "" if there is none
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP returns the address of the client of the call of ctx
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
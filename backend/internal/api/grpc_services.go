@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	storagev1 "github.com/minio-fullstack-storage/backend/proto/storage/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcChunkSize is the size of the content chunks of gRPC downloads
+const grpcChunkSize = 64 << 10
+
+// grpcUserService implements UserService like the user routes of the REST
+// API
+type grpcUserService struct {
+	storagev1.UnimplementedUserServiceServer
+	storageService *services.StorageService
+}
+
+func (s *grpcUserService) GetCurrentUser(ctx context.Context, _ *storagev1.GetCurrentUserRequest) (*storagev1.User, error) {
+	caller := grpcCallerFrom(ctx)
+	if caller.serviceAccountID != "" {
+		return nil, apierr.New(http.StatusForbidden, apierr.ServiceAccountDenied, "Not allowed for service accounts")
+	}
+
+	user, err := s.storageService.GetUser(ctx, caller.userID)
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found")
+	}
+	return userMessage(user.ToUserResponse()), nil
+}
+
+func (s *grpcUserService) GetUser(ctx context.Context, req *storagev1.GetUserRequest) (*storagev1.User, error) {
+	user, err := s.storageService.GetUser(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found")
+	}
+	return userMessage(user.ToUserResponse()), nil
+}
+
+func (s *grpcUserService) ListUsers(ctx context.Context, req *storagev1.ListUsersRequest) (*storagev1.ListUsersResponse, error) {
+	pagination, opts, err := grpcListOptions(req.GetOptions(), userQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.storageService.ListUsers(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list users")
+	}
+
+	resp := &storagev1.ListUsersResponse{Users: make([]*storagev1.User, len(users))}
+	for i, user := range users {
+		resp.Users[i] = userMessage(user.ToUserResponse())
+	}
+	pagination.Total = total
+	resp.Pagination = paginationMessage(pagination)
+	return resp, nil
+}
+
+// grpcPostService implements PostService like the post routes of the REST
+// API
+type grpcPostService struct {
+	storagev1.UnimplementedPostServiceServer
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func (s *grpcPostService) CreatePost(ctx context.Context, req *storagev1.CreatePostRequest) (*storagev1.Post, error) {
+	create := models.CreatePostRequest{
+		Title:   req.GetTitle(),
+		Content: req.GetContent(),
+		Summary: req.GetSummary(),
+		Tags:    req.GetTags(),
+		Status:  req.GetStatus(),
+	}
+	if err := binding.Validator.ValidateStruct(&create); err != nil {
+		return nil, bindError(err)
+	}
+
+	applyDefaultPostStatus(ctx, s.storageService, grpcCallerFrom(ctx).userID, &create)
+	post := create.Post(grpcCallerFrom(ctx).userID)
+	if _, err := reviewPost(ctx, grpcCallerFrom(ctx).access(), s.storageService, post, "", grpcCallerFrom(ctx).spamSender()); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to review post")
+	}
+	if err := s.storageService.CreatePost(ctx, post); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create post")
+	}
+	return postMessage(post), nil
+}
+
+func (s *grpcPostService) GetPost(ctx context.Context, req *storagev1.GetPostRequest) (*storagev1.Post, error) {
+	post, err := s.storageService.GetPost(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found")
+	}
+	if !grpcCallerFrom(ctx).access().canReadPost(ctx, s.storageService, post) {
+		return nil, orgRoleError(models.OrgRoleViewer)
+	}
+	return postMessage(post), nil
+}
+
+func (s *grpcPostService) ListPosts(ctx context.Context, req *storagev1.ListPostsRequest) (*storagev1.ListPostsResponse, error) {
+	pagination, opts, err := grpcListOptions(req.GetOptions(), postQuery)
+	if err != nil {
+		return nil, err
+	}
+	grpcCallerFrom(ctx).access().personalOnly(&opts, models.PermPostsAdmin)
+	grpcCallerFrom(ctx).access().reviewedOnly(&opts, "")
+
+	posts, total, err := s.storageService.ListPosts(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list posts")
+	}
+
+	resp := &storagev1.ListPostsResponse{Posts: make([]*storagev1.Post, len(posts))}
+	for i, post := range posts {
+		resp.Posts[i] = postMessage(post)
+	}
+	pagination.Total = total
+	resp.Pagination = paginationMessage(pagination)
+	return resp, nil
+}
+
+func (s *grpcPostService) UpdatePost(ctx context.Context, req *storagev1.UpdatePostRequest) (*storagev1.Post, error) {
+	caller := grpcCallerFrom(ctx)
+
+	post, err := s.storageService.GetPost(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found")
+	}
+	if !caller.access().canChangePost(ctx, s.storageService, post) {
+		return nil, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot update other user's post")
+	}
+
+	update := models.UpdatePostRequest{
+		Title:   req.Title,
+		Content: req.Content,
+		Summary: req.Summary,
+		Status:  req.Status,
+	}
+	if req.Tags != nil {
+		// Apply only sees a nil list as unchanged
+		update.Tags = append([]string{}, req.Tags.GetTags()...)
+	}
+	if err := binding.Validator.ValidateStruct(&update); err != nil {
+		return nil, bindError(err)
+	}
+	previous := post.Status
+	update.Apply(post)
+	if _, err := reviewPost(ctx, caller.access(), s.storageService, post, previous, caller.spamSender()); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to review post")
+	}
+
+	if err := s.storageService.UpdatePost(ctx, post); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update post")
+	}
+	return postMessage(post), nil
+}
+
+func (s *grpcPostService) DeletePost(ctx context.Context, req *storagev1.DeletePostRequest) (*storagev1.DeletePostResponse, error) {
+	caller := grpcCallerFrom(ctx)
+
+	post, err := s.storageService.GetPost(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found")
+	}
+	if !caller.access().canChangePost(ctx, s.storageService, post) {
+		return nil, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's post")
+	}
+
+	if err := s.storageService.DeletePost(ctx, post.ID); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete post")
+	}
+	if post.UserID != caller.userID {
+		publishAdminAction(ctx, s.messaging, caller.adminAction(models.AdminAction{
+			Action:     models.AdminPostDelete,
+			TargetType: models.AdminTargetPost,
+			TargetID:   post.ID,
+		}), post, nil)
+	}
+	return &storagev1.DeletePostResponse{}, nil
+}
+
+// grpcFileService implements FileService like the file routes of the REST
+// API. Uploads are left to the REST API, which scans, versions and
+// post-processes them.
+type grpcFileService struct {
+	storagev1.UnimplementedFileServiceServer
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func (s *grpcFileService) GetFile(ctx context.Context, req *storagev1.GetFileRequest) (*storagev1.File, error) {
+	file, err := s.storageService.GetFile(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found")
+	}
+	caller := grpcCallerFrom(ctx)
+	if file.OrgID != "" && !caller.access().canReadFile(ctx, s.storageService, file) {
+		return nil, orgRoleError(models.OrgRoleViewer)
+	}
+	return fileMessage(file.ForViewer(caller.userID)), nil
+}
+
+func (s *grpcFileService) ListFiles(ctx context.Context, req *storagev1.ListFilesRequest) (*storagev1.ListFilesResponse, error) {
+	caller := grpcCallerFrom(ctx)
+	pagination, opts, err := grpcListOptions(req.GetOptions(), fileQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only file admins see everyone's files
+	if !caller.has(models.PermFilesAdmin) {
+		opts.Filters = append(opts.Filters, models.Filter{Field: "userId", Op: models.OpEq, Value: caller.userID})
+	}
+	caller.access().personalOnly(&opts, models.PermFilesAdmin)
+
+	files, total, err := s.storageService.ListFiles(ctx, pagination, opts)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list files")
+	}
+
+	resp := &storagev1.ListFilesResponse{Files: make([]*storagev1.File, len(files))}
+	for i, file := range files {
+		resp.Files[i] = fileMessage(file.ForViewer(caller.userID))
+	}
+	pagination.Total = total
+	resp.Pagination = paginationMessage(pagination)
+	return resp, nil
+}
+
+func (s *grpcFileService) DownloadFile(req *storagev1.DownloadFileRequest, stream grpc.ServerStreamingServer[storagev1.DownloadFileResponse]) error {
+	ctx := stream.Context()
+	caller := grpcCallerFrom(ctx)
+
+	file, err := s.storageService.GetFile(ctx, req.GetId())
+	if err != nil {
+		return apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found")
+	}
+	if !caller.access().canReadFile(ctx, s.storageService, file) {
+		return apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot download other user's file")
+	}
+	if err := downloadableError(file); err != nil {
+		return err
+	}
+
+	content, err := s.storageService.GetFileContent(ctx, file.ID)
+	if err != nil {
+		return apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get file content")
+	}
+	defer content.Close()
+
+	if err := stream.Send(&storagev1.DownloadFileResponse{Part: &storagev1.DownloadFileResponse_File{File: fileMessage(file.ForViewer(caller.userID))}}); err != nil {
+		return err
+	}
+	var written int64
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&storagev1.DownloadFileResponse{Part: &storagev1.DownloadFileResponse_Chunk{Chunk: buf[:n]}}); err != nil {
+				return err
+			}
+			written += int64(n)
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return apierr.Wrap(readErr, http.StatusInternalServerError, apierr.Internal, "Failed to stream file")
+		}
+	}
+
+	publishDownload(ctx, s.messaging, models.AccessLogEntry{
+		FileID:    file.ID,
+		OwnerID:   file.UserID,
+		UserID:    caller.userID,
+		Via:       models.AccessDownload,
+		IP:        caller.ip,
+		UserAgent: caller.userAgent,
+		Bytes:     written,
+	})
+	return nil
+}
+
+func (s *grpcFileService) DeleteFile(ctx context.Context, req *storagev1.DeleteFileRequest) (*storagev1.DeleteFileResponse, error) {
+	caller := grpcCallerFrom(ctx)
+
+	file, err := s.storageService.GetFile(ctx, req.GetId())
+	if err != nil {
+		return nil, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found")
+	}
+	if !caller.access().canChangeFile(ctx, s.storageService, file) {
+		return nil, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's file")
+	}
+
+	if err := s.storageService.DeleteFile(ctx, file.ID); err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete file")
+	}
+	if file.UserID != caller.userID {
+		publishAdminAction(ctx, s.messaging, caller.adminAction(models.AdminAction{
+			Action:     models.AdminFileDelete,
+			TargetType: models.AdminTargetFile,
+			TargetID:   file.ID,
+		}), file, nil)
+	}
+	return &storagev1.DeleteFileResponse{}, nil
+}
+
+// grpcListOptions turns the options of a list call into the pagination
+// and query of the REST list, with the same rules
+func grpcListOptions(options *storagev1.ListOptions, spec QuerySpec) (models.Pagination, models.QueryOptions, error) {
+	values := url.Values{}
+	for key, value := range options.GetQuery() {
+		values.Set(key, value)
+	}
+	opts, fields := parseQuery(values, spec)
+	if len(fields) > 0 {
+		return models.Pagination{}, opts, validationError(fields)
+	}
+	return newPagination(int(options.GetPage()), int(options.GetPageSize())), opts, nil
+}
+
+func paginationMessage(pagination models.Pagination) *storagev1.Pagination {
+	return &storagev1.Pagination{
+		Page:     int32(pagination.Page),
+		PageSize: int32(pagination.PageSize),
+		Total:    pagination.Total,
+	}
+}
+
+func userMessage(user *models.UserResponse) *storagev1.User {
+	return &storagev1.User{
+		Id:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Role:          user.Role,
+		Status:        user.Status,
+		EmailVerified: user.EmailVerified,
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+		LastLoginAt:   optionalTimestamp(user.LastLoginAt),
+	}
+}
+
+func postMessage(post *models.Post) *storagev1.Post {
+	return &storagev1.Post{
+		Id:          post.ID,
+		UserId:      post.UserID,
+		Title:       post.Title,
+		Content:     post.Content,
+		Summary:     post.Summary,
+		Tags:        post.Tags,
+		Status:      post.Status,
+		CreatedAt:   timestamppb.New(post.CreatedAt),
+		UpdatedAt:   timestamppb.New(post.UpdatedAt),
+		PublishedAt: optionalTimestamp(post.PublishedAt),
+	}
+}
+
+func fileMessage(file *models.File) *storagev1.File {
+	return &storagev1.File{
+		Id:           file.ID,
+		UserId:       file.UserID,
+		OriginalName: file.OriginalName,
+		ContentType:  file.ContentType,
+		Size:         file.Size,
+		Folder:       file.Folder,
+		Description:  file.Description,
+		Metadata:     file.Metadata,
+		Visibility:   file.Visibility,
+		ScanStatus:   file.ScanStatus,
+		Version:      int32(file.Version),
+		ExpiresAt:    optionalTimestamp(file.ExpiresAt),
+		CreatedAt:    timestamppb.New(file.CreatedAt),
+		UpdatedAt:    timestamppb.New(file.UpdatedAt),
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
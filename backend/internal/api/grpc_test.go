@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/requestid"
+	storagev1 "github.com/minio-fullstack-storage/backend/proto/storage/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// errorInfo returns the ErrorInfo and BadRequest details of err
+func errorInfo(t *testing.T, err error) (*errdetails.ErrorInfo, *errdetails.BadRequest) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status: %v", err)
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.BadRequest:
+			badRequest = detail
+		}
+	}
+	require.NotNil(t, info)
+	return info, badRequest
+}
+
+func TestGRPCError(t *testing.T) {
+	ctx := requestid.NewContext(context.Background(), "req-1")
+
+	err := grpcError(ctx, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "Post not found", status.Convert(err).Message())
+	info, badRequest := errorInfo(t, err)
+	assert.Equal(t, string(apierr.PostNotFound), info.Reason)
+	assert.Equal(t, "req-1", info.Metadata["requestId"])
+	assert.Nil(t, badRequest)
+
+	err = grpcError(ctx, validationError([]models.FieldError{
+		{Name: "title", Rule: "required", Message: "title is required"},
+		{Name: "tags[0]", Rule: "min", Message: "tags[0] must be at least 1 character long"},
+	}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	info, badRequest = errorInfo(t, err)
+	assert.Equal(t, string(apierr.ValidationFailed), info.Reason)
+	require.NotNil(t, badRequest)
+	require.Len(t, badRequest.FieldViolations, 2)
+	assert.Equal(t, "title", badRequest.FieldViolations[0].Field)
+	assert.Equal(t, "title is required", badRequest.FieldViolations[0].Description)
+
+	// Other errors are internal, without revealing their cause
+	err = grpcError(ctx, errors.New("bucket gone"))
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "Internal server error", status.Convert(err).Message())
+
+	for httpStatus, code := range map[int]codes.Code{
+		http.StatusUnauthorized:       codes.Unauthenticated,
+		http.StatusForbidden:          codes.PermissionDenied,
+		http.StatusConflict:           codes.FailedPrecondition,
+		http.StatusGone:               codes.NotFound,
+		http.StatusTooManyRequests:    codes.ResourceExhausted,
+		http.StatusServiceUnavailable: codes.Unavailable,
+		http.StatusTeapot:             codes.FailedPrecondition,
+	} {
+		assert.Equal(t, code, status.Code(grpcError(ctx, apierr.New(httpStatus, apierr.BadRequest, "failed"))), httpStatus)
+	}
+
+	// Statuses pass through
+	err = status.Error(codes.Canceled, "client went away")
+	assert.Equal(t, err, grpcError(ctx, err))
+	assert.NoError(t, grpcError(ctx, nil))
+}
+
+// dialGRPC serves the gRPC API with cfg in memory and connects to it
+func dialGRPC(t *testing.T, cfg *config.Config) *grpc.ClientConn {
+	t.Helper()
+	server, err := NewGRPCServer(config.NewLive(cfg, ""), nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "grpc-secret", AccessTokenTTL: 15}}
+	conn := dialGRPC(t, cfg)
+	posts := storagev1.NewPostServiceClient(conn)
+
+	// A token signed with another secret
+	otherToken, err := auth.NewJWTManager("other-secret", 15).GenerateToken("user-1", "alice", "alice@example.com", models.RoleUser)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		reason        apierr.Code
+	}{
+		{"missing", "", apierr.TokenMissing},
+		{"malformed", "Token abc", apierr.TokenInvalid},
+		{"invalid", "Bearer " + otherToken, apierr.TokenInvalid},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "grpc-"+tc.name)
+			if tc.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.authorization)
+			}
+			var header metadata.MD
+			_, err := posts.GetPost(ctx, &storagev1.GetPostRequest{Id: "post-1"}, grpc.Header(&header))
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			info, _ := errorInfo(t, err)
+			assert.Equal(t, string(tc.reason), info.Reason)
+			assert.Equal(t, "grpc-"+tc.name, info.Metadata["requestId"])
+			assert.Equal(t, []string{"grpc-" + tc.name}, header.Get("x-request-id"))
+		})
+	}
+
+	// Streams are checked the same way
+	stream, err := storagev1.NewFileServiceClient(conn).DownloadFile(context.Background(), &storagev1.DownloadFileRequest{Id: "file-1"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCUnknownMethods(t *testing.T) {
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "grpc-secret", AccessTokenTTL: 15}, GRPC: config.GRPCConfig{Reflection: true}}
+	conn := dialGRPC(t, cfg)
+
+	// Reflection needs no account
+	reflection, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, reflection.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+	}))
+	response, err := reflection.Recv()
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.GetListServicesResponse().GetService())
+
+	// A method served without rules is refused rather than left open
+	method := storagev1.PostService_GetPost_FullMethodName
+	rules := grpcMethods[method]
+	delete(grpcMethods, method)
+	t.Cleanup(func() { grpcMethods[method] = rules })
+
+	_, err = storagev1.NewPostServiceClient(conn).GetPost(context.Background(), &storagev1.GetPostRequest{Id: "post-1"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	info, _ := errorInfo(t, err)
+	assert.Equal(t, string(apierr.NotFound), info.Reason)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/miniotest"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "Correct-Horse-9"
+
+// testAPI is the whole router backed by in-memory MinIO and Redis
+type testAPI struct {
+	t       *testing.T
+	cfg     *config.Config
+	router  *gin.Engine
+	storage *services.StorageService
+	minio   *miniotest.Server
+	redis   *miniredis.Miniredis
+	jwt     *auth.JWTManager
+}
+
+func newTestAPI(t *testing.T, configure ...func(cfg *config.Config)) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	server := miniotest.New(t)
+	cfg.MinIO = config.MinIOConfig{
+		Endpoint:        server.Endpoint(),
+		AccessKeyID:     miniotest.AccessKey,
+		SecretAccessKey: miniotest.SecretKey,
+		Region:          "us-east-1",
+	}
+	cfg.Database = config.DatabaseConfig{UsersBucket: "users", PostsBucket: "posts", FilesBucket: "files"}
+	cfg.JWT.Secret = "test-secret-that-is-long-enough-for-hs256"
+	for _, f := range configure {
+		f(cfg)
+	}
+
+	storage, err := services.NewStorageService(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	router := gin.New()
+	SetupRoutes(router, config.NewLive(cfg, ""), storage, nil, nil, redisClient, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	jwtManager, err := newJWTManager(cfg.JWT)
+	require.NoError(t, err)
+	return &testAPI{t: t, cfg: cfg, router: router, storage: storage, minio: server, redis: redisServer, jwt: jwtManager}
+}
+
+// user creates an active user with role and returns it with an access token
+func (a *testAPI) user(username, role string) (*models.User, string) {
+	a.t.Helper()
+	hash, err := auth.NewPasswordHasher(a.cfg.Auth)
+	require.NoError(a.t, err)
+	password, err := hash.Hash(testPassword)
+	require.NoError(a.t, err)
+
+	user := &models.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: password,
+		Role:     role,
+	}
+	require.NoError(a.t, a.storage.CreateUser(context.Background(), user))
+	return user, a.token(user)
+}
+
+// file stores a plain text file of user with content
+func (a *testAPI) file(user *models.User, name, content string) *models.File {
+	a.t.Helper()
+	file := &models.File{
+		UserID:       user.ID,
+		OriginalName: name,
+		FileName:     name,
+		ContentType:  "text/plain",
+		Size:         int64(len(content)),
+	}
+	require.NoError(a.t, a.storage.StoreFile(context.Background(), file, strings.NewReader(content)))
+	return file
+}
+
+// token signs an access token for user
+func (a *testAPI) token(user *models.User) string {
+	a.t.Helper()
+	token, err := a.jwt.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	require.NoError(a.t, err)
+	return token
+}
+
+// do sends a request with body encoded as JSON, authenticated with token
+// unless it is empty
+func (a *testAPI) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(a.t, err)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode reads the data of a success response into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	require.NoError(t, json.Unmarshal(response.Data, v), w.Body.String())
+}
+
+// errorCode returns the code of an error response
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var response models.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	return response.ErrorCode
+}
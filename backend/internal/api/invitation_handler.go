@@ -0,0 +1,200 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+type InvitationHandler struct {
+	storageService *services.StorageService
+	signer         *auth.InviteSigner
+	mailer         mailer.Mailer
+	messaging      *messaging.Client
+	config         config.AuthConfig
+}
+
+func NewInvitationHandler(storageService *services.StorageService, signer *auth.InviteSigner, mail mailer.Mailer, messagingClient *messaging.Client, authConfig config.AuthConfig) *InvitationHandler {
+	return &InvitationHandler{
+		storageService: storageService,
+		signer:         signer,
+		mailer:         mail,
+		messaging:      messagingClient,
+		config:         authConfig,
+	}
+}
+
+// CreateInvitation godoc
+// @Summary Invite a user
+// @Description Email an invite link that registers the recipient with the given role (admin only). The link is also returned in case the email cannot be delivered.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateInvitationRequest true "Invitation"
+// @Success 201 {object} models.SuccessResponse{data=models.CreatedInvitation} "Invitation created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or unknown role"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 409 {object} models.ErrorResponse "User already exists"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/invitations [post]
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if _, err := h.storageService.GetRole(c.Request.Context(), req.Role); err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Unknown role "+req.Role))
+		return
+	}
+
+	if _, err := h.storageService.GetUserByEmail(c.Request.Context(), req.Email); err == nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.EmailTaken, "A user with this email already exists"))
+		return
+	}
+
+	expiresInHours := req.ExpiresInHours
+	if expiresInHours == 0 {
+		expiresInHours = h.config.InviteTTL
+	}
+
+	invitation := &models.Invitation{
+		Email:     req.Email,
+		Role:      req.Role,
+		InvitedBy: c.GetString("userID"),
+		ExpiresAt: time.Now().Add(time.Duration(expiresInHours) * time.Hour),
+	}
+	if err := h.storageService.CreateInvitation(c.Request.Context(), invitation); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create invitation"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminInvitationCreate,
+		TargetType: models.AdminTargetInvitation,
+		TargetID:   invitation.ID,
+	}, nil, invitation)
+
+	link := h.config.InviteURL + "?invite=" + url.QueryEscape(h.signer.Sign(invitation.ID))
+	msg, err := mailer.Render(mailer.TemplateInvitation, invitation.Email, mailer.InvitationData{
+		InvitedBy: c.GetString("username"),
+		Link:      link,
+		ExpiresAt: invitation.ExpiresAt.UTC().Format(time.RFC1123),
+	})
+	if err == nil {
+		err = h.mailer.Send(c.Request.Context(), msg)
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to send invitation", "invitation", invitation.ID, "error", err)
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Invitation created successfully",
+		Data: models.CreatedInvitation{
+			Invitation: *invitation,
+			Link:       link,
+			EmailSent:  err == nil,
+		},
+	})
+}
+
+// ListInvitations godoc
+// @Summary List invitations
+// @Description List all invitations, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.Invitation} "Invitations retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/invitations [get]
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.storageService.ListInvitations(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list invitations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invitations retrieved successfully",
+		Data:    invitations,
+	})
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke invitation
+// @Description Delete an invitation so its link stops working (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.SuccessResponse "Invitation revoked successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Invitation not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/invitations/{id} [delete]
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id := c.Param("id")
+
+	invitation, err := h.storageService.GetInvitation(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, storageError(err, "Failed to revoke invitation"))
+		return
+	}
+
+	if err := h.storageService.DeleteInvitation(c.Request.Context(), id); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke invitation"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminInvitationRevoke,
+		TargetType: models.AdminTargetInvitation,
+		TargetID:   id,
+	}, invitation, nil)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invitation revoked successfully",
+	})
+}
+
+// invitationFor checks an invite token presented at registration by email
+// and writes a 403 response when it cannot be used
+func invitationFor(c *gin.Context, storageService *services.StorageService, signer *auth.InviteSigner, token, email string) (*models.Invitation, bool) {
+	reject := func(code apierr.Code, message string) (*models.Invitation, bool) {
+		respondError(c, apierr.New(http.StatusForbidden, code, message))
+		return nil, false
+	}
+
+	if token == "" {
+		return reject(apierr.InvitationRequired, "Registration requires an invitation")
+	}
+	id, err := signer.Verify(token)
+	if err != nil {
+		return reject(apierr.InvitationInvalid, "Invitation is invalid")
+	}
+	invitation, err := storageService.GetInvitation(c.Request.Context(), id)
+	if err != nil || !invitation.Usable(time.Now()) {
+		return reject(apierr.InvitationInvalid, "Invitation is invalid, used or expired")
+	}
+	if !strings.EqualFold(invitation.Email, email) {
+		return reject(apierr.InvitationInvalid, "Invitation was sent to a different email address")
+	}
+	return invitation, true
+}
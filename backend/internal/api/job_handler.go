@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// maxDeadJobsListed bounds a page of dead jobs
+const maxDeadJobsListed = 100
+
+type JobHandler struct {
+	queue     *jobs.Queue
+	messaging *messaging.Client
+}
+
+func NewJobHandler(queue *jobs.Queue, messagingClient *messaging.Client) *JobHandler {
+	return &JobHandler{
+		queue:     queue,
+		messaging: messagingClient,
+	}
+}
+
+// ListJobs godoc
+// @Summary Get background job status
+// @Description Get, for every type of background job, how many jobs are queued and running, how many were enqueued, succeeded, failed and retried, and how many were given up on and kept as dead letters
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.JobStatus} "Job status retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Background jobs are not available"
+// @Router /admin/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	statuses, err := h.queue.Status(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get job status"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Job status retrieved successfully",
+		Data:    statuses,
+	})
+}
+
+// ListDeadJobs godoc
+// @Summary List dead jobs
+// @Description List the jobs of a type that were given up on, newest first, with the error of their last attempt. The last 1000 are kept.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param type path string true "Job type, e.g. scan, thumbnail, preview or transcode"
+// @Param limit query int false "Jobs to list, up to 100" default(20)
+// @Success 200 {object} models.SuccessResponse{data=[]models.DeadJob} "Dead jobs retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Background jobs are not available"
+// @Router /admin/jobs/{type}/dead [get]
+func (h *JobHandler) ListDeadJobs(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxDeadJobsListed {
+		respondError(c, validationError([]models.FieldError{{Name: "limit", Rule: "range", Message: "limit must be between 1 and " + strconv.Itoa(maxDeadJobsListed)}}))
+		return
+	}
+
+	deadJobs, err := h.queue.DeadJobs(c.Request.Context(), c.Param("type"), limit)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list dead jobs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Dead jobs retrieved successfully",
+		Data:    deadJobs,
+	})
+}
+
+// RetryDeadJobs godoc
+// @Summary Retry dead jobs
+// @Description Queue every dead job of a type again with fresh attempts, e.g. once the service they failed on is back
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param type path string true "Job type"
+// @Success 202 {object} models.SuccessResponse{data=map[string]int} "Dead jobs queued again"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Background jobs are not available"
+// @Router /admin/jobs/{type}/dead/retry [post]
+func (h *JobHandler) RetryDeadJobs(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	jobType := c.Param("type")
+	retried, err := h.queue.RetryDead(c.Request.Context(), jobType)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to retry dead jobs"))
+		return
+	}
+
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminJobsRetry,
+		TargetType: models.AdminTargetSystem,
+		TargetID:   "jobs/" + jobType,
+	}, nil, map[string]int{"retried": retried})
+	requestLogger(c).Info("Dead jobs queued again", "type", jobType, "count", retried, "actorId", c.GetString("userID"))
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Message: "Dead jobs queued again",
+		Data:    map[string]int{"retried": retried},
+	})
+}
+
+func (h *JobHandler) available(c *gin.Context) bool {
+	if h.queue == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Background jobs are not available"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+type MaintenanceHandler struct {
+	storageService  *services.StorageService
+	messagingClient *messaging.Client
+	cfg             config.MaintenanceConfig
+}
+
+func NewMaintenanceHandler(storageService *services.StorageService, messagingClient *messaging.Client, cfg config.MaintenanceConfig) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		storageService:  storageService,
+		messagingClient: messagingClient,
+		cfg:             cfg,
+	}
+}
+
+// currentMaintenance returns the maintenance mode in effect: forced on by
+// cfg, or as set through the API with cfg's message and retry time as
+// defaults
+func currentMaintenance(ctx context.Context, storageService *services.StorageService, cfg config.MaintenanceConfig) (*models.Maintenance, error) {
+	if cfg.Enabled {
+		return &models.Maintenance{Enabled: true, Message: cfg.Message, RetryAfter: cfg.RetryAfter, Forced: true}, nil
+	}
+
+	stored, err := storageService.CachedMaintenance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maintenance := *stored
+	if maintenance.Message == "" {
+		maintenance.Message = cfg.Message
+	}
+	if maintenance.RetryAfter == 0 {
+		maintenance.RetryAfter = cfg.RetryAfter
+	}
+	return &maintenance, nil
+}
+
+// GetMaintenance godoc
+// @Summary Get maintenance mode
+// @Description Get whether the server is in read-only maintenance mode, in which requests that change data are rejected with 503
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.Maintenance} "Maintenance mode retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/maintenance [get]
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	maintenance, err := currentMaintenance(c.Request.Context(), h.storageService, h.cfg)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get maintenance mode"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Maintenance mode retrieved successfully",
+		Data:    maintenance,
+	})
+}
+
+// UpdateMaintenance godoc
+// @Summary Turn maintenance mode on or off
+// @Description Turn read-only maintenance mode on or off for every server. Other servers pick up the change within 30 seconds. Without a message or retryAfter the configured defaults are used. The mode cannot be turned off while the configuration forces it on.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MaintenanceRequest true "Maintenance mode"
+// @Success 200 {object} models.SuccessResponse{data=models.Maintenance} "Maintenance mode updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 409 {object} models.ErrorResponse "Maintenance mode is forced on by the configuration"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/maintenance [put]
+func (h *MaintenanceHandler) UpdateMaintenance(c *gin.Context) {
+	var req models.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if h.cfg.Enabled {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Maintenance mode is forced on by the configuration"))
+		return
+	}
+
+	before, err := currentMaintenance(c.Request.Context(), h.storageService, h.cfg)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get maintenance mode"))
+		return
+	}
+
+	userID := c.GetString("userID")
+	stored := &models.Maintenance{
+		Enabled:    req.Enabled,
+		Message:    req.Message,
+		RetryAfter: req.RetryAfter,
+		UpdatedBy:  userID,
+	}
+	if err := h.storageService.SaveMaintenance(c.Request.Context(), stored); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update maintenance mode"))
+		return
+	}
+
+	recordAudit(h.messagingClient, c, models.AuditEvent{
+		Type:    models.AuditMaintenance,
+		ActorID: userID,
+		Details: map[string]string{"enabled": strconv.FormatBool(req.Enabled)},
+	})
+	recordAdminAction(h.messagingClient, c, models.AdminAction{
+		Action:     models.AdminMaintenance,
+		TargetType: models.AdminTargetSystem,
+		TargetID:   "maintenance",
+	}, before, stored)
+	requestLogger(c).Info("Maintenance mode changed", "enabled", req.Enabled, "actorId", userID)
+
+	maintenance, err := currentMaintenance(c.Request.Context(), h.storageService, h.cfg)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get maintenance mode"))
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Maintenance mode updated successfully",
+		Data:    maintenance,
+	})
+}
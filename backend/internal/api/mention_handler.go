@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// ListMentions godoc
+// @Summary List mentions
+// @Description Get a paginated list of the posts and comments that mention the caller as @username, newest first, each with its post. Posts that are gone or no longer readable are left out of post.
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Success 200 {object} models.ListResponse{data=[]models.Mention} "Mentions retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/mentions [get]
+func (h *PostHandler) ListMentions(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+	ctx := c.Request.Context()
+
+	mentions, total, err := h.storageService.ListMentions(ctx, c.GetString("userID"), pagination)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list mentions"))
+		return
+	}
+
+	posts := make([]*models.Post, 0, len(mentions))
+	for _, mention := range mentions {
+		post, err := h.storageService.GetPost(ctx, mention.PostID)
+		if err != nil || !canReadPost(c, h.storageService, post) {
+			continue
+		}
+		mention.Post = post
+		posts = append(posts, post)
+	}
+	h.storageService.CountBookmarks(ctx, posts...)
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       mentions,
+		Pagination: pagination,
+	})
+}
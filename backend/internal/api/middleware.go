@@ -1,109 +1,687 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
 	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/ipfilter"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/ratelimit"
+	"github.com/minio-fullstack-storage/backend/internal/reporting"
+	"github.com/minio-fullstack-storage/backend/internal/requestid"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthMiddleware(jwtManager *auth.JWTManager) gin.HandlerFunc {
+// AuthMiddleware accepts requests with a valid bearer token that has not
+// been revoked, or with the key of an enabled service account. A nil
+// denylist skips the revocation check.
+func AuthMiddleware(jwtManager *auth.JWTManager, denylist *auth.Denylist, storageService *services.StorageService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Authorization header required",
-			})
+			respondError(c, apierr.New(http.StatusUnauthorized, apierr.TokenMissing, "Authorization header required"))
 			c.Abort()
 			return
 		}
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid authorization header format",
-			})
+			respondError(c, apierr.New(http.StatusUnauthorized, apierr.TokenInvalid, "Invalid authorization header format"))
 			c.Abort()
 			return
 		}
 
+		if strings.HasPrefix(bearerToken[1], models.ServiceAccountKeyPrefix) {
+			authenticateServiceAccount(c, storageService, bearerToken[1])
+			return
+		}
+
 		claims, err := jwtManager.ValidateToken(bearerToken[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "Invalid token",
-			})
+			respondError(c, apierr.New(http.StatusUnauthorized, apierr.TokenInvalid, "Invalid token"))
 			c.Abort()
 			return
 		}
 
+		if denylist != nil {
+			// Fail closed: a revoked token must not slip through while
+			// Redis is unreachable
+			revoked, err := denylist.IsRevoked(c.Request.Context(), claims)
+			if err != nil {
+				requestLogger(c).Error("Failed to check token revocation", "error", err)
+				respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.Unavailable, "Unable to verify token"))
+				c.Abort()
+				return
+			}
+			if revoked {
+				respondError(c, apierr.New(http.StatusUnauthorized, apierr.TokenRevoked, "Token has been revoked"))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("claims", claims)
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		if claims.Act != nil {
+			c.Set("impersonatorID", claims.Act.UserID)
+		}
 
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// authenticateServiceAccount accepts a request made with a service account
+// key. The account takes the place of the user, with its own permissions
+// instead of a role.
+func authenticateServiceAccount(c *gin.Context, storageService *services.StorageService, key string) {
+	account, err := storageService.AuthenticateServiceAccount(c.Request.Context(), key, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidServiceKey) {
+		respondError(c, apierr.New(http.StatusUnauthorized, apierr.InvalidServiceKey, "Invalid service account key"))
+		c.Abort()
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to verify service account key", "error", err)
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.Unavailable, "Unable to verify service account key"))
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", account.ID)
+	c.Set("username", account.Name)
+	c.Set("serviceAccountID", account.ID)
+	c.Set("permissions", account.Role())
+
+	c.Next()
+}
+
+// forbidServiceAccount writes a 403 response when the request is made with
+// a service account key, for actions that need a person's account
+func forbidServiceAccount(c *gin.Context) bool {
+	if c.GetString("serviceAccountID") == "" {
+		return false
+	}
+	respondError(c, apierr.New(http.StatusForbidden, apierr.ServiceAccountDenied, "Not allowed for service accounts"))
+	return true
+}
+
+// ActiveUserMiddleware rejects tokens of users that were suspended,
+// deactivated or deleted after the token was issued. Impersonation tokens
+// also need the impersonating admin to be active.
+func ActiveUserMiddleware(storageService *services.StorageService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role := c.GetString("role")
-		if role != "admin" {
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error: "Admin access required",
-			})
+		// Service accounts were checked when their key was verified
+		if c.GetString("serviceAccountID") != "" {
+			c.Next()
+			return
+		}
+
+		users := []string{c.GetString("userID")}
+		if impersonatorID := c.GetString("impersonatorID"); impersonatorID != "" {
+			users = append(users, impersonatorID)
+		}
+
+		for _, userID := range users {
+			status, err := storageService.CachedUserStatus(c.Request.Context(), userID)
+			if errors.Is(err, services.ErrUserNotFound) {
+				respondError(c, apierr.New(http.StatusUnauthorized, apierr.AccountDeleted, "Account no longer exists"))
+				c.Abort()
+				return
+			}
+			if err != nil {
+				requestLogger(c).Error("Failed to load account status", "error", err)
+				respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to verify account"))
+				c.Abort()
+				return
+			}
+
+			if status != models.UserStatusActive {
+				inactiveAccountResponse(c, status)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// forbidImpersonation writes a 403 response when the request is made with
+// an impersonation token, for actions only the user themselves may take
+func forbidImpersonation(c *gin.Context) bool {
+	if c.GetString("impersonatorID") == "" {
+		return false
+	}
+	respondError(c, apierr.New(http.StatusForbidden, apierr.ImpersonationForbidden, "Not allowed while impersonating a user"))
+	return true
+}
+
+// inactiveAccountResponse tells a suspended or deactivated user why they
+// are turned away
+func inactiveAccountResponse(c *gin.Context, status string) {
+	respondError(c, apierr.New(http.StatusForbidden, apierr.AccountInactive, "This account has been "+status+"; contact an administrator"))
+}
+
+// PermissionMiddleware loads the role of the authenticated user for
+// RequirePermission and hasPermission. Users whose role was deleted keep
+// no permissions.
+func PermissionMiddleware(storageService *services.StorageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Service accounts bring their own permissions
+		if _, ok := c.Get("permissions"); ok {
+			c.Next()
+			return
+		}
+
+		roleName := c.GetString("role")
+		role, err := storageService.CachedRole(c.Request.Context(), roleName)
+		if errors.Is(err, services.ErrRoleNotFound) {
+			role = &models.Role{Name: roleName}
+		} else if err != nil {
+			requestLogger(c).Error("Failed to load role", "role", roleName, "error", err)
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to load permissions"))
 			c.Abort()
 			return
 		}
+
+		c.Set("permissions", role)
 		c.Next()
 	}
 }
 
-func CORSMiddleware() gin.HandlerFunc {
+// RequirePermission rejects requests whose role lacks any of permissions
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		for _, permission := range permissions {
+			if !hasPermission(c, permission) {
+				respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission "+permission))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+// hasPermission reports whether the caller's role grants permission
+func hasPermission(c *gin.Context, permission string) bool {
+	value, _ := c.Get("permissions")
+	role, ok := value.(*models.Role)
+	return ok && role.Has(permission)
+}
+
+// RequestIDMiddleware gives every request an ID: the client's X-Request-ID
+// when it is usable, a new one otherwise. The ID is returned in the
+// response header and in JSON error bodies, and travels in the request
+// context.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Set("requestID", id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+		c.Writer = &requestIDWriter{ResponseWriter: c.Writer, id: id}
+		c.Next()
+	}
+}
+
+// BodyLimitMiddleware rejects request bodies larger than limit with 413.
+// Routes in streamed, keyed by their full path, get their own limit and
+// read their body as a stream; their handlers must answer 413 when
+// reading fails with *http.MaxBytesError. Other bodies of unknown length
+// are read in full up front, so handlers see the whole body or none of it.
+// A limit of 0 turns the check off.
+func BodyLimitMiddleware(limit int64, streamed map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := limit
+		routeLimit, stream := streamed[c.FullPath()]
+		if stream {
+			limit = routeLimit
+		}
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			bodyTooLargeResponse(c, limit)
+			c.Abort()
 			return
 		}
 
+		switch {
+		case stream:
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		case c.Request.ContentLength < 0:
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			c.Request.Body.Close()
+			if err != nil {
+				respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Failed to read request body"))
+				c.Abort()
+				return
+			}
+			if int64(len(body)) > limit {
+				bodyTooLargeResponse(c, limit)
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
 		c.Next()
 	}
 }
 
-func RateLimitMiddleware() gin.HandlerFunc {
+// bodyTooLargeResponse reports a request body over limit. The connection
+// is closed, as the rest of the body is not read.
+func bodyTooLargeResponse(c *gin.Context, limit int64) {
+	c.Header("Connection", "close")
+	respondError(c, apierr.New(http.StatusRequestEntityTooLarge, apierr.RequestTooLarge, "Request body exceeds the maximum size of "+strconv.FormatInt(limit, 10)+" bytes"))
+}
+
+// TimeoutMiddleware gives each request a deadline after which its context
+// is cancelled, so storage calls stop and the client gets a 504. Routes
+// are matched by their full path against routes: a key ending in / covers
+// every route under it, other keys one route, and the longest match wins.
+// Requests of other routes get timeout. A timeout of 0 sets no deadline.
+func TimeoutMiddleware(timeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Simple rate limiting implementation
-		// In production, use a proper rate limiter like go-redis rate limiter
+		timeout := routeTimeout(c.FullPath(), timeout, routes)
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next()
+
+		// Handlers usually answer a failed storage call themselves; this
+		// covers those that return without a response
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			respondError(c, timeoutError())
+		}
 	}
 }
 
-func PaginationMiddleware() gin.HandlerFunc {
+func routeTimeout(route string, timeout time.Duration, routes map[string]time.Duration) time.Duration {
+	match := ""
+	for key, routeTimeout := range routes {
+		if (key == route || strings.HasSuffix(key, "/") && strings.HasPrefix(route, key)) && len(key) > len(match) {
+			match, timeout = key, routeTimeout
+		}
+	}
+	return timeout
+}
+
+func timeoutError() *apierr.Error {
+	return apierr.New(http.StatusGatewayTimeout, apierr.Timeout, "Request took too long")
+}
+
+// IPFilterMiddleware rejects clients whose address filter does not allow
+// with 403. The address is the one gin resolves from X-Forwarded-For
+// through the trusted proxies. A nil filter lets everyone through.
+func IPFilterMiddleware(filter *ipfilter.Filter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+		if !filter.Allowed(c.ClientIP()) {
+			requestLogger(c).Warn("Request from a denied address", "ip", c.ClientIP())
+			respondError(c, apierr.New(http.StatusForbidden, apierr.AddressDenied, "Access from this address is not allowed"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
-		if page < 1 {
-			page = 1
+// MaintenanceMiddleware rejects requests that can change data with 503
+// and a Retry-After while maintenance mode is on. Reads and the routes in
+// exempt, keyed by their full path, keep working. When the mode cannot be
+// read requests are let through rather than taking the API down with it.
+func MaintenanceMiddleware(storageService *services.StorageService, cfg config.MaintenanceConfig, exempt map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+			c.Next()
+			return
 		}
-		if pageSize < 1 || pageSize > 100 {
-			pageSize = 10
+		if exempt[c.FullPath()] {
+			c.Next()
+			return
 		}
 
-		pagination := models.Pagination{
-			Page:     page,
-			PageSize: pageSize,
-			Offset:   (page - 1) * pageSize,
+		maintenance, err := currentMaintenance(c.Request.Context(), storageService, cfg)
+		if err != nil {
+			requestLogger(c).Error("Failed to check maintenance mode", "error", err)
+			c.Next()
+			return
+		}
+		if !maintenance.Enabled {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(maintenance.RetryAfter))
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.Maintenance, maintenance.Message))
+		c.Abort()
+	}
+}
+
+// TracingMiddleware records each request as a server span, continuing the
+// trace of the caller when the request carries one. Handlers and the
+// storage layer create their spans under it through the request context.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		name := c.Request.Method
+		if route := c.FullPath(); route != "" {
+			name += " " + route
+		}
+		ctx, span := tracing.Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(c.FullPath()),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+				attribute.String("request.id", c.GetString("requestID")),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if userID := c.GetString("userID"); userID != "" {
+			span.SetAttributes(semconv.EnduserID(userID))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// AccessLogMiddleware gives each request a logger carrying its ID, for
+// handlers and the storage layer, and writes one line per request once it
+// is done. Successful requests are sampled at cfg.AccessSampleRate; failed
+// and slow ones are always logged. It must run after RequestIDMiddleware
+// and TracingMiddleware.
+func AccessLogMiddleware(logger *slog.Logger, cfg config.LogConfig) gin.HandlerFunc {
+	slow := time.Duration(cfg.SlowRequest) * time.Millisecond
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestLogger := logger.With("requestId", c.GetString("requestID"))
+		// Lines of sampled traces can be looked up from the trace
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsSampled() {
+			requestLogger = requestLogger.With("traceId", spanContext.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest && latency < slow && rand.Float64() >= cfg.AccessSampleRate {
+			return
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		// The path is logged without its query, which can hold tokens
+		requestLogger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latencyMs", float64(latency.Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("userId", c.GetString("userID")),
+			slog.String("ip", c.ClientIP()),
+			slog.String("userAgent", c.Request.UserAgent()),
+		)
+	}
+}
+
+// RecoveryMiddleware turns a panic in a later handler into a 500 error
+// response carrying the request ID, logs it with its stack and sends a
+// report to sink, if set, in the background. Panics from clients that went
+// away are only logged. It must run after AccessLogMiddleware so the
+// failed request is logged and traced.
+func RecoveryMiddleware(sink reporting.Sink) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			if err, ok := recovered.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				requestLogger(c).Warn("Client connection lost", "error", err)
+				c.Abort()
+				return
+			}
+
+			// Skip the deferred function and runtime.gopanic
+			report := reporting.NewReport(fmt.Sprint(recovered), 2)
+			report.RequestID = c.GetString("requestID")
+			report.Method = c.Request.Method
+			report.Path = c.Request.URL.Path
+			report.UserID = c.GetString("userID")
+			if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+				report.TraceID = spanContext.TraceID().String()
+				report.SpanID = spanContext.SpanID().String()
+			}
+			requestLogger(c).Error("Panic while handling request", "panic", report.Message, "reportId", report.ID, "stack", report.Stack())
+
+			if sink != nil {
+				go func() {
+					ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), 30*time.Second)
+					defer cancel()
+					if err := sink.Report(ctx, report); err != nil {
+						logging.FromContext(ctx, slog.Default()).Error("Failed to report panic", "reportId", report.ID, "error", err)
+					}
+				}()
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Internal server error"))
+			c.Abort()
+		}()
+
+		c.Next()
+	}
+}
+
+// requestLogger returns the logger of the request, which carries its ID
+func requestLogger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context(), slog.Default())
+}
+
+// requestIDWriter fills the requestId field of JSON error bodies, so
+// handlers need not set ErrorResponse.RequestID themselves
+type requestIDWriter struct {
+	gin.ResponseWriter
+	id      string
+	started bool
+}
+
+func (w *requestIDWriter) Write(data []byte) (int, error) {
+	first := !w.started
+	w.started = true
+	if !first || w.Status() < http.StatusBadRequest ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") ||
+		len(data) < 2 || data[0] != '{' || bytes.Contains(data, []byte(`"requestId"`)) {
+		return w.ResponseWriter.Write(data)
+	}
+
+	field, _ := json.Marshal(w.id)
+	prefix := `{"requestId":` + string(field)
+	if data[1] != '}' {
+		prefix += ","
+	}
+	if _, err := w.ResponseWriter.WriteString(prefix); err != nil {
+		return 0, err
+	}
+	n, err := w.ResponseWriter.Write(data[1:])
+	return n + 1, err
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *requestIDWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CORSMiddleware lets the browser apps at the origins returned by origins
+// call the API; "*" allows every origin. Origins are looked up on every
+// request, so reloading the configuration changes them.
+func CORSMiddleware(origins func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if origin := allowedOrigin(origins(), c.GetHeader("Origin")); origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, If-Match, If-None-Match, If-Modified-Since, X-Upload-ID, X-Share-Password, X-Request-ID, traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, ETag, Last-Modified, X-Encryption-Algorithm, X-Encryption-Key-Id, X-Encryption-IV, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, X-Request-ID")
+
+		// Other OPTIONS requests go to their route, as WebDAV clients
+		// discover the server with them
+		if c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for a
+// request from origin, "" when the origin is not allowed
+func allowedOrigin(allowed []string, origin string) string {
+	for _, a := range allowed {
+		if a == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(a, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// RateLimitMiddleware takes each request from a token bucket of group,
+// one per user when the request is authenticated and per client address
+// otherwise, and rejects it with 429 when the bucket is empty. The limit
+// is looked up on every request, so reloading the configuration changes
+// it; rate limiting is off while limit reports it disabled or without a
+// limiter. When Redis fails requests are let through rather than taking
+// the API down with it.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, group string, limit func() (ratelimit.Limit, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, enabled := limit()
+		if limiter == nil || !enabled {
+			c.Next()
+			return
+		}
+
+		key := group + ":ip:" + c.ClientIP()
+		if userID := c.GetString("userID"); userID != "" {
+			key = group + ":user:" + userID
+		}
+
+		result, err := limiter.Take(c.Request.Context(), key, current)
+		if err != nil {
+			requestLogger(c).Error("Rate limiting failed", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			respondError(c, apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Rate limit exceeded, try again later"))
+			c.Abort()
+			return
 		}
 
-		c.Set("pagination", pagination)
 		c.Next()
 	}
 }
+
+// ceilSeconds rounds d up to whole seconds for the rate limit headers
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func PaginationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+
+		c.Set("pagination", newPagination(page, pageSize))
+		c.Next()
+	}
+}
+
+// newPagination pages a list, falling back to the first page and 10 items
+// per page for values out of range
+func newPagination(page, pageSize int) models.Pagination {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	return models.Pagination{
+		Page:     page,
+		PageSize: pageSize,
+		Offset:   (page - 1) * pageSize,
+	}
+}
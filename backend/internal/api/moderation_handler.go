@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/spam"
+)
+
+// ModerationHandler lets post admins work through the posts and comments
+// held for review by the MODERATION_* rules. Every decision goes to the
+// admin audit log.
+type ModerationHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewModerationHandler(storageService *services.StorageService, messagingClient *messaging.Client) *ModerationHandler {
+	return &ModerationHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// reviewPost runs the moderation rules and the spam checker on a post its
+// author saves, given the status it had before; content of post admins is
+// not held
+func reviewPost(ctx context.Context, access itemAccess, storageService *services.StorageService, post *models.Post, previous string, sender spam.Sender) (bool, error) {
+	if access.has(models.PermPostsAdmin) {
+		return false, nil
+	}
+	return storageService.ReviewPost(ctx, post, previous, sender)
+}
+
+// spamSender describes the client of a request to the spam checker
+func spamSender(c *gin.Context) spam.Sender {
+	return spam.Sender{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// ListModerationQueue godoc
+// @Summary List the moderation queue
+// @Description Get a paginated list of the posts and comments held for review, oldest first, each with its content and the rules it broke. Filter by kind (post or comment) and postId.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt"
+// @Param kind query string false "post or comment"
+// @Success 200 {object} models.ListResponse{data=[]models.ModerationItem} "Moderation queue retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/moderation [get]
+func (h *ModerationHandler) ListModerationQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	if len(opts.Sort) == 0 {
+		opts.Sort = []models.SortField{{Field: "createdAt"}}
+	}
+
+	items, total, err := h.storageService.ListModerationItems(ctx, pagination, opts)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list moderation queue"))
+		return
+	}
+	for _, item := range items {
+		if item.Kind == models.ModerationComment {
+			item.Comment, _ = h.storageService.GetComment(ctx, item.PostID, item.CommentID)
+			continue
+		}
+		item.Post, _ = h.storageService.GetPost(ctx, item.PostID)
+	}
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       items,
+		Pagination: pagination,
+	})
+}
+
+// ApproveModeration godoc
+// @Summary Approve held content
+// @Description Approve a post or comment held for review: the post is published, the comment shown
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post or comment ID"
+// @Success 200 {object} models.SuccessResponse "Content approved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Not in the moderation queue"
+// @Failure 409 {object} models.ErrorResponse "Content is gone or no longer pending review"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/moderation/{id}/approve [post]
+func (h *ModerationHandler) ApproveModeration(c *gin.Context) {
+	h.decide(c, true)
+}
+
+// RejectModeration godoc
+// @Summary Reject held content
+// @Description Reject a post or comment held for review: the post gets the rejected status, which only its author and post admins see, and the comment is hidden. Authors can publish a rejected post again, which holds it for review again.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post or comment ID"
+// @Success 200 {object} models.SuccessResponse "Content rejected successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Not in the moderation queue"
+// @Failure 409 {object} models.ErrorResponse "Content is gone or no longer pending review"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/moderation/{id}/reject [post]
+func (h *ModerationHandler) RejectModeration(c *gin.Context) {
+	h.decide(c, false)
+}
+
+func (h *ModerationHandler) decide(c *gin.Context, approve bool) {
+	item, err := h.storageService.GetModerationItem(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrModerationItemNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.NotFound, "Not in the moderation queue"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get moderation item"))
+		return
+	}
+
+	content, err := h.storageService.DecideModeration(c.Request.Context(), item, approve)
+	if errors.Is(err, services.ErrNotPending) {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Content is gone or no longer pending review"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to moderate content"))
+		return
+	}
+
+	action, message := models.AdminModerationReject, "Content rejected successfully"
+	if approve {
+		action, message = models.AdminModerationApprove, "Content approved successfully"
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     action,
+		TargetType: item.Kind, // post or comment, as the admin targets
+		TargetID:   item.ID,
+	}, item, content)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: message,
+		Data:    content,
+	})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/notifications"
+)
+
+type NotificationHandler struct {
+	notifications *notifications.Store
+}
+
+func NewNotificationHandler(store *notifications.Store) *NotificationHandler {
+	return &NotificationHandler{
+		notifications: store,
+	}
+}
+
+// ListNotifications godoc
+// @Summary List notifications
+// @Description List the notifications of the current user, newest first. The last 200 are kept for 90 days.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Param unread query bool false "List only unread notifications"
+// @Success 200 {object} models.ListResponse{data=[]models.Notification} "Notifications retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Notifications are not available"
+// @Router /notifications [get]
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	pagination := c.MustGet("pagination").(models.Pagination)
+	unreadOnly := c.Query("unread") == "true"
+
+	list, total, err := h.notifications.List(c.Request.Context(), c.GetString("userID"), unreadOnly, pagination.Offset, pagination.PageSize)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list notifications"))
+		return
+	}
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       list,
+		Pagination: pagination,
+	})
+}
+
+// GetUnreadCount godoc
+// @Summary Count unread notifications
+// @Description Get how many notifications of the current user are unread, for a badge
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.UnreadCount} "Unread notifications counted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Notifications are not available"
+// @Router /notifications/unread-count [get]
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	unread, err := h.notifications.UnreadCount(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to count unread notifications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Unread notifications counted successfully",
+		Data:    models.UnreadCount{Unread: unread},
+	})
+}
+
+// MarkRead godoc
+// @Summary Mark a notification read
+// @Description Mark a notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Notification ID"
+// @Success 200 {object} models.SuccessResponse{data=models.UnreadCount} "Notification marked read"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 404 {object} models.ErrorResponse "Notification not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Notifications are not available"
+// @Router /notifications/{id}/read [post]
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	if err := h.notifications.MarkRead(c.Request.Context(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, notifications.ErrNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.NotificationNotFound, "Notification not found"))
+			return
+		}
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to mark notification read"))
+		return
+	}
+
+	h.respondUnread(c, userID, "Notification marked read")
+}
+
+// MarkAllRead godoc
+// @Summary Mark all notifications read
+// @Description Mark every notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.UnreadCount} "Notifications marked read"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Notifications are not available"
+// @Router /notifications/read-all [post]
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	if _, err := h.notifications.MarkAllRead(c.Request.Context(), userID); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to mark notifications read"))
+		return
+	}
+
+	h.respondUnread(c, userID, "Notifications marked read")
+}
+
+// respondUnread answers with the unread count of userID, so the badge is
+// updated without another request
+func (h *NotificationHandler) respondUnread(c *gin.Context, userID, message string) {
+	unread, err := h.notifications.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to count unread notifications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: message,
+		Data:    models.UnreadCount{Unread: unread},
+	})
+}
+
+func (h *NotificationHandler) available(c *gin.Context) bool {
+	if h.notifications == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Notifications are not available"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// Access to posts and files
+//
+// A post or file without an organization belongs to its author alone. One
+// shared with an organization can be read by every member, changed by its
+// author while they are at least a member, and changed by the
+// organization's admins and owners. Holders of posts:admin or files:admin
+// may do anything, and public files can be read by everyone. Posts
+// withheld by moderation are seen by their author and post admins alone.
+
+// personalItems selects the posts or files not shared with an
+// organization, which are all that the WebDAV and S3 views of a user's
+// files show
+var personalItems = models.QueryOptions{Filters: []models.Filter{{Field: "orgId", Op: models.OpEq, Value: ""}}}
+
+// reviewedPosts selects the posts not withheld by moderation
+var reviewedPosts = models.QueryOptions{Filters: []models.Filter{
+	{Field: "status", Op: models.OpNe, Value: models.PostStatusPending},
+	{Field: "status", Op: models.OpNe, Value: models.PostStatusRejected},
+}}
+
+// itemAccess is who asks for a post or file, whether through the REST,
+// GraphQL or gRPC API
+type itemAccess struct {
+	userID string
+	has    func(permission string) bool // whether their role grants permission
+}
+
+// ginAccess is the caller of a REST request
+func ginAccess(c *gin.Context) itemAccess {
+	return itemAccess{
+		userID: c.GetString("userID"),
+		has:    func(permission string) bool { return hasPermission(c, permission) },
+	}
+}
+
+// allows reports whether the caller may read an item of ownerID shared
+// with orgID, if set, or change it when write is set
+func (a itemAccess) allows(ctx context.Context, storageService *services.StorageService, ownerID, orgID, adminPermission string, write bool) bool {
+	if a.has(adminPermission) {
+		return true
+	}
+	if orgID == "" {
+		return ownerID == a.userID
+	}
+
+	role := orgRole(ctx, storageService, orgID, a.userID)
+	switch {
+	case !write:
+		return role != ""
+	case ownerID == a.userID:
+		return models.OrgRoleAtLeast(role, models.OrgRoleMember)
+	default:
+		return models.OrgRoleAtLeast(role, models.OrgRoleAdmin)
+	}
+}
+
+func (a itemAccess) canReadFile(ctx context.Context, storageService *services.StorageService, file *models.File) bool {
+	return file.IsPublic() || a.allows(ctx, storageService, file.UserID, file.OrgID, models.PermFilesAdmin, false)
+}
+
+func (a itemAccess) canChangeFile(ctx context.Context, storageService *services.StorageService, file *models.File) bool {
+	return a.allows(ctx, storageService, file.UserID, file.OrgID, models.PermFilesAdmin, true)
+}
+
+// canReadPost reports whether the caller may read post; posts of users
+// are read by everyone with posts:read
+func (a itemAccess) canReadPost(ctx context.Context, storageService *services.StorageService, post *models.Post) bool {
+	if post.Withheld() {
+		return post.UserID == a.userID || a.has(models.PermPostsAdmin)
+	}
+	return post.OrgID == "" || a.allows(ctx, storageService, post.UserID, post.OrgID, models.PermPostsAdmin, false)
+}
+
+// reviewedOnly leaves posts withheld by moderation out of a listing,
+// unless the caller holds posts:admin or the listing is of their own posts
+func (a itemAccess) reviewedOnly(opts *models.QueryOptions, ownerID string) {
+	if ownerID != a.userID && !a.has(models.PermPostsAdmin) {
+		opts.Filters = append(opts.Filters, reviewedPosts.Filters...)
+	}
+}
+
+func (a itemAccess) canChangePost(ctx context.Context, storageService *services.StorageService, post *models.Post) bool {
+	return a.allows(ctx, storageService, post.UserID, post.OrgID, models.PermPostsAdmin, true)
+}
+
+// personalOnly restricts a listing to items not shared with an
+// organization, unless the caller holds adminPermission; shared items are
+// listed per organization
+func (a itemAccess) personalOnly(opts *models.QueryOptions, adminPermission string) {
+	if !a.has(adminPermission) {
+		opts.Filters = append(opts.Filters, personalItems.Filters...)
+	}
+}
+
+func canReadFile(c *gin.Context, storageService *services.StorageService, file *models.File) bool {
+	return ginAccess(c).canReadFile(c.Request.Context(), storageService, file)
+}
+
+func canChangeFile(c *gin.Context, storageService *services.StorageService, file *models.File) bool {
+	return ginAccess(c).canChangeFile(c.Request.Context(), storageService, file)
+}
+
+func canReadPost(c *gin.Context, storageService *services.StorageService, post *models.Post) bool {
+	return ginAccess(c).canReadPost(c.Request.Context(), storageService, post)
+}
+
+func canChangePost(c *gin.Context, storageService *services.StorageService, post *models.Post) bool {
+	return ginAccess(c).canChangePost(c.Request.Context(), storageService, post)
+}
+
+// orgRole returns the role of userID in the organization orgID, or ""
+// when they are not a member; lookup failures count as no role
+func orgRole(ctx context.Context, storageService *services.StorageService, orgID, userID string) string {
+	role, err := storageService.CachedOrgRole(ctx, orgID, userID)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Error("Failed to look up organization role", "orgId", orgID, "error", err)
+		return ""
+	}
+	return role
+}
+
+// orgRoleError is the response to a caller without min in an organization
+func orgRoleError(min string) *apierr.Error {
+	return apierr.New(http.StatusForbidden, apierr.OrgRoleRequired, "Requires the "+min+" role in the organization")
+}
+
+// requireOrgRole checks that the caller has at least min in the
+// organization orgID, writing a 403 response if not
+func requireOrgRole(c *gin.Context, storageService *services.StorageService, orgID, min string) bool {
+	if models.OrgRoleAtLeast(orgRole(c.Request.Context(), storageService, orgID, c.GetString("userID")), min) {
+		return true
+	}
+	respondError(c, orgRoleError(min))
+	return false
+}
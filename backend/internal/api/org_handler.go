@@ -0,0 +1,528 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// OrgHandler manages organizations, their members and what is shared with
+// them. Members are given one of the organization roles; user admins may
+// manage every organization.
+type OrgHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewOrgHandler(storageService *services.StorageService, messagingClient *messaging.Client) *OrgHandler {
+	return &OrgHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// CreateOrganization godoc
+// @Summary Create organization
+// @Description Create an organization with the caller as its owner
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateOrganizationRequest true "Organization"
+// @Success 201 {object} models.SuccessResponse{data=models.Organization} "Organization created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed for service accounts"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations [post]
+func (h *OrgHandler) CreateOrganization(c *gin.Context) {
+	if forbidServiceAccount(c) {
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	org := &models.Organization{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedBy:   c.GetString("userID"),
+	}
+	if err := h.storageService.CreateOrganization(c.Request.Context(), org); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create organization"))
+		return
+	}
+	h.recordChange(c, org.ID, org.CreatedBy, "created", models.OrgRoleOwner)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Organization created successfully",
+		Data:    org,
+	})
+}
+
+// ListOrganizations godoc
+// @Summary List organizations
+// @Description List the organizations the caller is a member of, by name; user admins see every organization
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.Organization} "Organizations retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations [get]
+func (h *OrgHandler) ListOrganizations(c *gin.Context) {
+	var ids []string
+	if !hasPermission(c, models.PermUsersAdmin) {
+		memberships, err := h.storageService.ListUserMemberships(c.Request.Context(), c.GetString("userID"))
+		if err != nil {
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list organizations"))
+			return
+		}
+		ids = []string{}
+		for _, membership := range memberships {
+			ids = append(ids, membership.OrgID)
+		}
+	}
+
+	orgs, err := h.storageService.ListOrganizations(c.Request.Context(), ids)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list organizations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Organizations retrieved successfully",
+		Data:    orgs,
+	})
+}
+
+// GetOrganization godoc
+// @Summary Get organization
+// @Description Get an organization the caller is a member of
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Organization} "Organization retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Router /organizations/{id} [get]
+func (h *OrgHandler) GetOrganization(c *gin.Context) {
+	org, _, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Organization retrieved successfully",
+		Data:    org,
+	})
+}
+
+// UpdateOrganization godoc
+// @Summary Update organization
+// @Description Change the name or description of an organization (organization admins and owners)
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Param request body models.UpdateOrganizationRequest true "Fields to change"
+// @Success 200 {object} models.SuccessResponse{data=models.Organization} "Organization updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id} [patch]
+func (h *OrgHandler) UpdateOrganization(c *gin.Context) {
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	org, _, ok := h.loadOrganization(c, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		org.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		org.Description = *req.Description
+	}
+	if err := h.storageService.SaveOrganization(c.Request.Context(), org); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update organization"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Organization updated successfully",
+		Data:    org,
+	})
+}
+
+// DeleteOrganization godoc
+// @Summary Delete organization
+// @Description Delete an organization and its memberships (owners only). Posts and files shared with it must be deleted first.
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse "Organization deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Failure 409 {object} models.ErrorResponse "Posts or files are still shared with the organization"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id} [delete]
+func (h *OrgHandler) DeleteOrganization(c *gin.Context) {
+	org, _, ok := h.loadOrganization(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	shared := models.QueryOptions{Filters: []models.Filter{{Field: "orgId", Op: models.OpEq, Value: org.ID}}}
+	_, posts, err := h.storageService.ListPosts(ctx, models.Pagination{PageSize: 1}, shared)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete organization"))
+		return
+	}
+	_, files, err := h.storageService.ListFiles(ctx, models.Pagination{PageSize: 1}, shared)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete organization"))
+		return
+	}
+	if posts+files > 0 {
+		respondError(c, apierr.New(http.StatusConflict, apierr.OrganizationNotEmpty, "Posts or files are still shared with the organization"))
+		return
+	}
+
+	if err := h.storageService.DeleteOrganization(ctx, org.ID); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete organization"))
+		return
+	}
+	h.recordChange(c, org.ID, c.GetString("userID"), "deleted", "")
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Organization deleted successfully",
+	})
+}
+
+// ListMembers godoc
+// @Summary List organization members
+// @Description List the members of an organization and their roles, oldest first
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Membership} "Members retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id}/members [get]
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	org, _, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := h.storageService.ListMembers(c.Request.Context(), org.ID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list members"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Members retrieved successfully",
+		Data:    members,
+	})
+}
+
+// SetMember godoc
+// @Summary Add or change a member
+// @Description Add a user to an organization or change their role (organization admins and owners). Only owners make or unmake owners, and the last owner cannot step down.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID"
+// @Param request body models.SetMemberRequest true "Role"
+// @Success 200 {object} models.SuccessResponse{data=models.Membership} "Member saved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 404 {object} models.ErrorResponse "Organization or user not found"
+// @Failure 409 {object} models.ErrorResponse "The organization would have no owner"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id}/members/{userId} [put]
+func (h *OrgHandler) SetMember(c *gin.Context) {
+	if forbidServiceAccount(c) {
+		return
+	}
+
+	var req models.SetMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	org, role, ok := h.loadOrganization(c, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.Param("userId")
+	if _, err := h.storageService.GetUser(ctx, userID); err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	membership, err := h.storageService.GetMembership(ctx, org.ID, userID)
+	if errors.Is(err, services.ErrMemberNotFound) {
+		membership = &models.Membership{OrgID: org.ID, UserID: userID, AddedBy: c.GetString("userID")}
+	} else if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to save member"))
+		return
+	}
+
+	if (req.Role == models.OrgRoleOwner || membership.Role == models.OrgRoleOwner) && !h.canManageOwners(c, role) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.OrgRoleRequired, "Only owners can make or unmake owners"))
+		return
+	}
+	if membership.Role == models.OrgRoleOwner && req.Role != models.OrgRoleOwner && !h.keepsOwner(c, org.ID, userID) {
+		return
+	}
+
+	membership.Role = req.Role
+	if err := h.storageService.SaveMembership(ctx, membership); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to save member"))
+		return
+	}
+	h.recordChange(c, org.ID, userID, "member_saved", req.Role)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Member saved successfully",
+		Data:    membership,
+	})
+}
+
+// RemoveMember godoc
+// @Summary Remove a member
+// @Description Remove a user from an organization (organization admins and owners, or members leaving). Only owners remove owners, and the last owner cannot leave.
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.SuccessResponse "Member removed successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
+// @Failure 404 {object} models.ErrorResponse "Organization or member not found"
+// @Failure 409 {object} models.ErrorResponse "The organization would have no owner"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id}/members/{userId} [delete]
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	if forbidServiceAccount(c) {
+		return
+	}
+
+	userID := c.Param("userId")
+	leaving := userID == c.GetString("userID")
+	minRole := models.OrgRoleAdmin
+	if leaving {
+		minRole = models.OrgRoleViewer
+	}
+	org, role, ok := h.loadOrganization(c, minRole)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	membership, err := h.storageService.GetMembership(ctx, org.ID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrMemberNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.MemberNotFound, "Member not found"))
+			return
+		}
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to remove member"))
+		return
+	}
+
+	if membership.Role == models.OrgRoleOwner {
+		if !leaving && !h.canManageOwners(c, role) {
+			respondError(c, apierr.New(http.StatusForbidden, apierr.OrgRoleRequired, "Only owners can remove owners"))
+			return
+		}
+		if !h.keepsOwner(c, org.ID, userID) {
+			return
+		}
+	}
+
+	if err := h.storageService.DeleteMembership(ctx, org.ID, userID); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to remove member"))
+		return
+	}
+	h.recordChange(c, org.ID, userID, "member_removed", membership.Role)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Member removed successfully",
+	})
+}
+
+// ListPosts godoc
+// @Summary List an organization's posts
+// @Description List the posts shared with an organization, with the sorting and filtering of GET /posts
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Param sort query string false "Comma-separated fields to sort by, descending with a leading -"
+// @Success 200 {object} models.ListResponse{data=[]models.Post} "Posts retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id}/posts [get]
+func (h *OrgHandler) ListPosts(c *gin.Context) {
+	org, _, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	opts.Filters = append(opts.Filters, models.Filter{Field: "orgId", Op: models.OpEq, Value: org.ID})
+	ginAccess(c).reviewedOnly(&opts, "")
+
+	posts, total, err := h.storageService.ListPosts(c.Request.Context(), pagination, opts)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list posts"))
+		return
+	}
+
+	pagination.Total = total
+
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       posts,
+		Pagination: pagination,
+	})
+}
+
+// ListFiles godoc
+// @Summary List an organization's files
+// @Description List the files shared with an organization, with the sorting and filtering of GET /files
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Param sort query string false "Comma-separated fields to sort by, descending with a leading -"
+// @Success 200 {object} models.ListResponse{data=[]models.File} "Files retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member"
+// @Failure 404 {object} models.ErrorResponse "Organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /organizations/{id}/files [get]
+func (h *OrgHandler) ListFiles(c *gin.Context) {
+	org, _, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	opts.Filters = append(opts.Filters, models.Filter{Field: "orgId", Op: models.OpEq, Value: org.ID})
+
+	files, total, err := h.storageService.ListFiles(c.Request.Context(), pagination, opts)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list files"))
+		return
+	}
+
+	pagination.Total = total
+
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       filesForViewer(files, c.GetString("userID")),
+		Pagination: pagination,
+	})
+}
+
+// loadOrganization loads the organization named by the id parameter and
+// checks that the caller has at least min there, or is a user admin,
+// writing the error response if not. It also returns the caller's role.
+func (h *OrgHandler) loadOrganization(c *gin.Context, min string) (*models.Organization, string, bool) {
+	org, err := h.storageService.GetOrganization(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrOrganizationNotFound) {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.OrganizationNotFound, "Organization not found"))
+			return nil, "", false
+		}
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get organization"))
+		return nil, "", false
+	}
+
+	role := orgRole(c.Request.Context(), h.storageService, org.ID, c.GetString("userID"))
+	if !models.OrgRoleAtLeast(role, min) && !hasPermission(c, models.PermUsersAdmin) {
+		respondError(c, orgRoleError(min))
+		return nil, "", false
+	}
+	return org, role, true
+}
+
+// canManageOwners reports whether the caller may make or unmake owners
+func (h *OrgHandler) canManageOwners(c *gin.Context, role string) bool {
+	return role == models.OrgRoleOwner || hasPermission(c, models.PermUsersAdmin)
+}
+
+// keepsOwner checks that an organization has an owner other than userID,
+// writing a 409 response if not
+func (h *OrgHandler) keepsOwner(c *gin.Context, orgID, userID string) bool {
+	members, err := h.storageService.ListMembers(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list members"))
+		return false
+	}
+	for _, member := range members {
+		if member.Role == models.OrgRoleOwner && member.UserID != userID {
+			return true
+		}
+	}
+	respondError(c, apierr.New(http.StatusConflict, apierr.LastOwner, "An organization must keep an owner"))
+	return false
+}
+
+// recordChange publishes a change to an organization's membership for the
+// audit log of the member it concerns
+func (h *OrgHandler) recordChange(c *gin.Context, orgID, userID, action, role string) {
+	details := map[string]string{"orgId": orgID, "action": action}
+	if role != "" {
+		details["role"] = role
+	}
+	event := models.AuditEvent{Type: models.AuditOrganization, UserID: userID, Details: details}
+	if actorID := c.GetString("userID"); actorID != userID {
+		event.ActorID = actorID
+	}
+	recordAudit(h.messaging, c, event)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrgRoles(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	owner, ownerToken := api.user("owner", models.RoleUser)
+	admin, adminToken := api.user("admin", models.RoleUser)
+	viewer, viewerToken := api.user("viewer", models.RoleUser)
+
+	org := &models.Organization{Name: "Team", CreatedBy: owner.ID}
+	require.NoError(t, api.storage.CreateOrganization(ctx, org))
+	members := "/api/v1/organizations/" + org.ID + "/members/"
+	for user, role := range map[*models.User]string{admin: models.OrgRoleAdmin, viewer: models.OrgRoleViewer} {
+		w := api.do(http.MethodPut, members+user.ID, ownerToken, models.SetMemberRequest{Role: role})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Viewers read but do not write
+	w := api.do(http.MethodPost, "/api/v1/posts/", viewerToken, models.CreatePostRequest{Title: "Mine", Content: "Text", OrgID: org.ID})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Equal(t, string(apierr.OrgRoleRequired), errorCode(t, w))
+	post := &models.Post{UserID: owner.ID, OrgID: org.ID, Title: "Plans", Content: "Text", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(ctx, post))
+	w = api.do(http.MethodGet, "/api/v1/posts/"+post.ID, viewerToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodPut, "/api/v1/posts/"+post.ID, viewerToken, map[string]string{"title": "Changed"})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	// Admins manage members but not owners
+	w = api.do(http.MethodPut, members+viewer.ID, adminToken, models.SetMemberRequest{Role: models.OrgRoleMember})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodPut, members+admin.ID, adminToken, models.SetMemberRequest{Role: models.OrgRoleOwner})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Equal(t, string(apierr.OrgRoleRequired), errorCode(t, w))
+	w = api.do(http.MethodDelete, members+owner.ID, adminToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	// The last owner cannot leave or step down, until there is another
+	w = api.do(http.MethodDelete, members+owner.ID, ownerToken, nil)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, string(apierr.LastOwner), errorCode(t, w))
+	w = api.do(http.MethodPut, members+owner.ID, ownerToken, models.SetMemberRequest{Role: models.OrgRoleAdmin})
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = api.do(http.MethodPut, members+admin.ID, ownerToken, models.SetMemberRequest{Role: models.OrgRoleOwner})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodDelete, members+owner.ID, ownerToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -2,78 +2,89 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
 	"github.com/minio-fullstack-storage/backend/internal/services"
 )
 
 type PostHandler struct {
 	storageService *services.StorageService
+	messaging      *messaging.Client
 }
 
-func NewPostHandler(storageService *services.StorageService) *PostHandler {
+func NewPostHandler(storageService *services.StorageService, messagingClient *messaging.Client) *PostHandler {
 	return &PostHandler{
 		storageService: storageService,
+		messaging:      messagingClient,
 	}
 }
 
 // CreatePost godoc
 // @Summary Create a new post
-// @Description Create a new post for the authenticated user
+// @Description Create a new post for the authenticated user, shared with the organization orgId if set (members and above)
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body models.Post true "Post data"
+// @Param request body models.CreatePostRequest true "Post data"
 // @Success 201 {object} models.SuccessResponse{data=models.Post} "Post created successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request format"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Organization role required"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /posts [post]
 func (h *PostHandler) CreatePost(c *gin.Context) {
 	userID := c.GetString("userID")
 
-	var post models.Post
-	if err := c.ShouldBindJSON(&post); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+	var req models.CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
 		return
 	}
 
-	post.UserID = userID
-	if post.Status == "" {
-		post.Status = "draft"
+	if req.OrgID != "" && !requireOrgRole(c, h.storageService, req.OrgID, models.OrgRoleMember) {
+		return
 	}
 
-	if err := h.storageService.CreatePost(c.Request.Context(), &post); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to create post",
-			Code:    http.StatusInternalServerError,
-		})
+	applyDefaultPostStatus(c.Request.Context(), h.storageService, userID, &req)
+	post := req.Post(userID)
+	held, err := reviewPost(c.Request.Context(), ginAccess(c), h.storageService, post, "", spamSender(c))
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to review post"))
+		return
+	}
+	if err := h.storageService.CreatePost(c.Request.Context(), post); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create post"))
 		return
 	}
 
+	message := "Post created successfully"
+	if held {
+		message = "Post created and held for review"
+	}
 	c.JSON(http.StatusCreated, models.SuccessResponse{
-		Message: "Post created successfully",
+		Message: message,
 		Data:    post,
 	})
 }
 
 // GetPost godoc
 // @Summary Get a post by ID
-// @Description Get a specific post by its ID
+// @Description Get a specific post by its ID; posts shared with an organization are only shown to its members
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Post ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.SuccessResponse{data=models.Post} "Post retrieved successfully"
+// @Success 304 "Not modified"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the post's organization"
 // @Failure 404 {object} models.ErrorResponse "Post not found"
 // @Router /posts/{id} [get]
 func (h *PostHandler) GetPost(c *gin.Context) {
@@ -81,14 +92,24 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 
 	post, err := h.storageService.GetPost(c.Request.Context(), postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "Post not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return
+	}
+	if !canReadPost(c, h.storageService, post) {
+		respondError(c, orgRoleError(models.OrgRoleViewer))
 		return
 	}
 
+	setSeriesNavigation(c, h.storageService, post)
+	modified := post.UpdatedAt
+	if post.Series != nil {
+		// Its series changes without the post, so only the ETag tells
+		modified = time.Time{}
+	}
+	if notModified(c, weakETag(seriesETag(post.ETag, post.Series)), modified) {
+		return
+	}
+	h.storageService.CountBookmarks(c.Request.Context(), post)
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Post retrieved successfully",
 		Data:    post,
@@ -97,13 +118,13 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 
 // UpdatePost godoc
 // @Summary Update a post
-// @Description Update a post (users can only update their own posts, admins can update any post)
+// @Description Update a post (users can only update their own posts, admins can update any post; in organizations, admins and owners can update every shared post)
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Post ID"
-// @Param request body models.Post true "Post update data"
+// @Param request body models.UpdatePostRequest true "Post update data"
 // @Success 200 {object} models.SuccessResponse{data=models.Post} "Post updated successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request format"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
@@ -113,75 +134,53 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 // @Router /posts/{id} [put]
 func (h *PostHandler) UpdatePost(c *gin.Context) {
 	postID := c.Param("id")
-	userID := c.GetString("userID")
-	userRole := c.GetString("role")
 
 	// Get existing post
 	post, err := h.storageService.GetPost(c.Request.Context(), postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "Post not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
 		return
 	}
 
 	// Check if user can update this post
-	if post.UserID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot update other user's post",
-			Code:    http.StatusForbidden,
-		})
+	if !canChangePost(c, h.storageService, post) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot update other user's post"))
 		return
 	}
 
-	var updates models.Post
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+	var req models.UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
 		return
 	}
-
-	// Update allowed fields
-	if updates.Title != "" {
-		post.Title = updates.Title
-	}
-	if updates.Content != "" {
-		post.Content = updates.Content
-	}
-	if updates.Summary != "" {
-		post.Summary = updates.Summary
-	}
-	if len(updates.Tags) > 0 {
-		post.Tags = updates.Tags
-	}
-	if updates.Status != "" {
-		post.Status = updates.Status
+	previous := post.Status
+	req.Apply(post)
+	held, err := reviewPost(c.Request.Context(), ginAccess(c), h.storageService, post, previous, spamSender(c))
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to review post"))
+		return
 	}
 
 	if err := h.storageService.UpdatePost(c.Request.Context(), post); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to update post",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update post"))
 		return
 	}
+	h.storageService.CountBookmarks(c.Request.Context(), post)
+	setSeriesNavigation(c, h.storageService, post)
 
+	message := "Post updated successfully"
+	if held {
+		message = "Post updated and held for review"
+	}
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Post updated successfully",
+		Message: message,
 		Data:    post,
 	})
 }
 
 // DeletePost godoc
 // @Summary Delete a post
-// @Description Delete a post (users can only delete their own posts, admins can delete any post)
+// @Description Delete a post (users can only delete their own posts, admins can delete any post; in organizations, admins and owners can delete every shared post)
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -196,37 +195,31 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 func (h *PostHandler) DeletePost(c *gin.Context) {
 	postID := c.Param("id")
 	userID := c.GetString("userID")
-	userRole := c.GetString("role")
 
 	// Get existing post
 	post, err := h.storageService.GetPost(c.Request.Context(), postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "Post not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
 		return
 	}
 
 	// Check if user can delete this post
-	if post.UserID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot delete other user's post",
-			Code:    http.StatusForbidden,
-		})
+	if !canChangePost(c, h.storageService, post) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's post"))
 		return
 	}
 
 	if err := h.storageService.DeletePost(c.Request.Context(), postID); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to delete post",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete post"))
 		return
 	}
+	if post.UserID != userID {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminPostDelete,
+			TargetType: models.AdminTargetPost,
+			TargetID:   post.ID,
+		}, post, nil)
+	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Post deleted successfully",
@@ -236,29 +229,33 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 
 // ListPosts godoc
 // @Summary List all posts
-// @Description Get a paginated list of all posts
+// @Description Get a paginated list of all posts not shared with an organization; post admins see every post. Filter by userId, orgId, title, tags, status, createdAt and updatedAt as field=value or field[op]=value.
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,title"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
 // @Success 200 {object} models.ListResponse{data=[]models.Post} "Posts retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /posts [get]
 func (h *PostHandler) ListPosts(c *gin.Context) {
 	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	ginAccess(c).personalOnly(&opts, models.PermPostsAdmin)
+	ginAccess(c).reviewedOnly(&opts, "")
 
-	posts, total, err := h.storageService.ListPosts(c.Request.Context(), pagination)
+	posts, total, err := h.storageService.ListPosts(c.Request.Context(), pagination, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to list posts",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list posts"))
 		return
 	}
+	h.storageService.CountBookmarks(c.Request.Context(), posts...)
 
 	pagination.Total = total
 
@@ -270,7 +267,7 @@ func (h *PostHandler) ListPosts(c *gin.Context) {
 
 // GetUserPosts godoc
 // @Summary Get posts by user ID
-// @Description Get a paginated list of posts by a specific user
+// @Description Get a paginated list of posts by a specific user, without those shared with an organization unless the caller is a post admin
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -278,22 +275,25 @@ func (h *PostHandler) ListPosts(c *gin.Context) {
 // @Param userId path string true "User ID"
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,title"
 // @Success 200 {object} models.ListResponse{data=[]models.Post} "User posts retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /posts/user/{userId} [get]
 func (h *PostHandler) GetUserPosts(c *gin.Context) {
 	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	opts.Filters = append(opts.Filters, models.Filter{Field: "userId", Op: models.OpEq, Value: c.Param("userId")})
+	ginAccess(c).personalOnly(&opts, models.PermPostsAdmin)
+	ginAccess(c).reviewedOnly(&opts, c.Param("userId"))
 
-	posts, total, err := h.storageService.ListPosts(c.Request.Context(), pagination)
+	posts, total, err := h.storageService.ListPosts(c.Request.Context(), pagination, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to list user posts",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list user posts"))
 		return
 	}
+	h.storageService.CountBookmarks(c.Request.Context(), posts...)
 
 	pagination.Total = total
 
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// GetPublicProfile godoc
+// @Summary Get a public profile
+// @Description Get the public profile of a user, without signing in: username, avatar, bio and, as their privacy settings allow, their name and latest published posts. Users whose profile is private look like users that do not exist.
+// @Tags public
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} models.SuccessResponse{data=models.PublicProfile} "Profile retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 429 {object} models.ErrorResponse "Too many requests"
+// @Router /public/users/{username} [get]
+func (h *UserHandler) GetPublicProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.storageService.GetUserByUsername(ctx, c.Param("username"))
+	if err != nil || user.PublicProfile(nil) == nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	var posts []*models.Post
+	if user.Privacy.ShowPosts {
+		posts, err = h.storageService.PublicPosts(ctx, user.ID)
+		if err != nil {
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get profile"))
+			return
+		}
+	}
+	profile := user.PublicProfile(posts)
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Profile retrieved successfully",
+		Data:    profile,
+	})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Kinds of filterable fields, which decide the operators they take
+const (
+	fieldString = "string"
+	fieldList   = "list" // a list of strings, such as tags
+	fieldNumber = "number"
+	fieldTime   = "time"
+)
+
+var fieldOperators = map[string][]string{
+	fieldString: {models.OpEq, models.OpNe, models.OpContains, models.OpIn},
+	fieldList:   {models.OpEq, models.OpNe, models.OpContains, models.OpIn},
+	fieldNumber: {models.OpEq, models.OpNe, models.OpGt, models.OpGte, models.OpLt, models.OpLte},
+	fieldTime:   {models.OpEq, models.OpNe, models.OpGt, models.OpGte, models.OpLt, models.OpLte},
+}
+
+// QuerySpec lists what a list endpoint can be sorted and filtered by
+type QuerySpec struct {
+	Sort    []string          // sortable fields
+	Filters map[string]string // filterable field -> kind
+	Dates   string            // time field that from and to restrict
+}
+
+var (
+	userQuery = QuerySpec{
+		Sort: []string{"username", "email", "firstName", "lastName", "role", "createdAt", "updatedAt", "lastLoginAt"},
+		Filters: map[string]string{
+			"username": fieldString, "email": fieldString, "firstName": fieldString, "lastName": fieldString,
+			"role": fieldString, "status": fieldString, "createdAt": fieldTime, "lastLoginAt": fieldTime,
+		},
+		Dates: "createdAt",
+	}
+	postQuery = QuerySpec{
+		Sort: []string{"title", "status", "createdAt", "updatedAt"},
+		Filters: map[string]string{
+			"userId": fieldString, "orgId": fieldString, "title": fieldString, "tags": fieldList,
+			"status": fieldString, "createdAt": fieldTime, "updatedAt": fieldTime,
+		},
+		Dates: "createdAt",
+	}
+	seriesQuery = QuerySpec{
+		Sort: []string{"title", "createdAt", "updatedAt"},
+		Filters: map[string]string{
+			"userId": fieldString, "title": fieldString, "createdAt": fieldTime, "updatedAt": fieldTime,
+		},
+		Dates: "createdAt",
+	}
+	moderationQuery = QuerySpec{
+		Sort:    []string{"createdAt"},
+		Filters: map[string]string{"kind": fieldString, "postId": fieldString, "createdAt": fieldTime},
+		Dates:   "createdAt",
+	}
+	reportQuery = QuerySpec{
+		Sort: []string{"status", "reason", "createdAt"},
+		Filters: map[string]string{
+			"targetType": fieldString, "targetId": fieldString, "reporterId": fieldString, "reason": fieldString,
+			"status": fieldString, "createdAt": fieldTime,
+		},
+		Dates: "createdAt",
+	}
+	fileQuery = QuerySpec{
+		Sort: []string{"originalName", "contentType", "size", "folder", "createdAt", "updatedAt", "expiresAt"},
+		Filters: map[string]string{
+			"userId": fieldString, "orgId": fieldString, "originalName": fieldString, "contentType": fieldString, "folder": fieldString,
+			"visibility": fieldString, "size": fieldNumber, "createdAt": fieldTime, "updatedAt": fieldTime,
+			"expiresAt": fieldTime,
+		},
+		Dates: "createdAt",
+	}
+)
+
+// QueryMiddleware parses the sorting and filtering of a list into
+// models.QueryOptions, next to the pagination:
+//
+//	sort=-createdAt,title   sort by fields, descending with a leading -
+//	status=published        filter a field by equality
+//	size[gte]=1048576       filter with an operator: eq, ne, contains, in,
+//	                        gt, gte, lt or lte; in takes a comma-separated list
+//	from=...&to=...         RFC 3339 bounds of the spec's date field
+//
+// Other query parameters are left alone. Fields and operators the spec
+// does not allow are rejected with a 400.
+func QueryMiddleware(spec QuerySpec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, fields := parseQuery(c.Request.URL.Query(), spec)
+		if len(fields) > 0 {
+			respondError(c, validationError(fields))
+			c.Abort()
+			return
+		}
+
+		c.Set("query", opts)
+		c.Next()
+	}
+}
+
+func parseQuery(values url.Values, spec QuerySpec) (models.QueryOptions, []models.FieldError) {
+	var opts models.QueryOptions
+	var fields []models.FieldError
+	invalid := func(name, rule, message string) {
+		fields = append(fields, models.FieldError{Name: name, Rule: rule, Message: name + " " + message})
+	}
+
+	if sort := strings.TrimSpace(values.Get("sort")); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			field = strings.TrimSpace(field)
+			name, desc := strings.CutPrefix(field, "-")
+			if !slices.Contains(spec.Sort, name) {
+				invalid("sort", "oneof", "must name fields from "+strings.Join(spec.Sort, ", "))
+				continue
+			}
+			opts.Sort = append(opts.Sort, models.SortField{Field: name, Desc: desc})
+		}
+	}
+
+	if spec.Dates != "" {
+		for _, bound := range []struct{ key, op string }{{"from", models.OpGte}, {"to", models.OpLte}} {
+			if value := values.Get(bound.key); value != "" {
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					invalid(bound.key, "datetime", "must be an RFC 3339 time")
+					continue
+				}
+				opts.Filters = append(opts.Filters, models.Filter{Field: spec.Dates, Op: bound.op, Value: t})
+			}
+		}
+	}
+
+	// Sorted keys give filters and errors a stable order
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		name, op := key, models.OpEq
+		if open := strings.IndexByte(key, '['); open > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:open], key[open+1:len(key)-1]
+		}
+		kind, ok := spec.Filters[name]
+		if !ok {
+			continue
+		}
+		if !slices.Contains(fieldOperators[kind], op) {
+			invalid(key, "op", "takes the operators "+strings.Join(fieldOperators[kind], ", "))
+			continue
+		}
+
+		value, err := filterValue(kind, op, values.Get(key))
+		if err != "" {
+			invalid(key, "type", err)
+			continue
+		}
+		opts.Filters = append(opts.Filters, models.Filter{Field: name, Op: op, Value: value})
+	}
+
+	return opts, fields
+}
+
+// filterValue parses the value of a filter, or says what it must be
+func filterValue(kind, op, value string) (any, string) {
+	switch {
+	case op == models.OpIn:
+		return strings.Split(value, ","), ""
+	case kind == fieldNumber:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, "must be an integer"
+		}
+		return n, ""
+	case kind == fieldTime:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, "must be an RFC 3339 time"
+		}
+		return t, ""
+	}
+	return value, ""
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	values, _ := url.ParseQuery("sort=-size,originalName&contentType[in]=image/png,image/jpeg&size[gte]=1024&visibility=public&from=2024-01-01T00:00:00Z&page=2")
+	opts, fields := parseQuery(values, fileQuery)
+
+	assert.Empty(t, fields)
+	assert.Equal(t, []models.SortField{{Field: "size", Desc: true}, {Field: "originalName"}}, opts.Sort)
+	assert.Equal(t, []models.Filter{
+		{Field: "createdAt", Op: models.OpGte, Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Field: "contentType", Op: models.OpIn, Value: []string{"image/png", "image/jpeg"}},
+		{Field: "size", Op: models.OpGte, Value: int64(1024)},
+		{Field: "visibility", Op: models.OpEq, Value: "public"},
+	}, opts.Filters)
+
+	values, _ = url.ParseQuery("sort=password&size[contains]=1&createdAt[lt]=yesterday&to=now")
+	_, fields = parseQuery(values, fileQuery)
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	assert.Equal(t, []string{"sort", "to", "createdAt[lt]", "size[contains]"}, names)
+
+	opts, fields = parseQuery(url.Values{}, postQuery)
+	assert.Empty(t, fields)
+	assert.True(t, opts.IsZero())
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/realtime"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxClientMessage bounds the messages clients send, which are small
+	maxClientMessage = 4 << 10
+	// writeWait is how long writing a message to a client may take
+	writeWait = 10 * time.Second
+)
+
+type RealtimeHandler struct {
+	storageService *services.StorageService
+	hub            *realtime.Hub
+	pingInterval   time.Duration
+}
+
+func NewRealtimeHandler(storageService *services.StorageService, hub *realtime.Hub, pingInterval time.Duration) *RealtimeHandler {
+	return &RealtimeHandler{
+		storageService: storageService,
+		hub:            hub,
+		pingInterval:   pingInterval,
+	}
+}
+
+// WebSocketTokenMiddleware lets WebSocket clients pass their access token
+// as the access_token query parameter, as browsers cannot set headers on
+// the upgrade request. A header sent anyway takes precedence.
+func WebSocketTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// Connect godoc
+// @Summary Receive live updates
+// @Description Upgrade to a WebSocket receiving events as JSON messages. Every connection gets the events of the current user's account, posts, comments and files and of comments on their posts; send {"type":"subscribe","room":"post:<id>"} to follow a post and its comments as well, and {"type":"unsubscribe","room":"post:<id>"} to stop. The server sends {"type":"ping"} periodically and closes connections that answer nothing, not even {"type":"pong"}, for two intervals. Connections close when the access token expires. Browsers pass the token as the access_token query parameter.
+// @Tags realtime
+// @Produce json
+// @Security BearerAuth
+// @Param access_token query string false "Access token, for clients that cannot set the Authorization header"
+// @Success 101 {object} realtime.Message "Switching to the WebSocket protocol"
+// @Failure 400 {object} models.ErrorResponse "Not a WebSocket upgrade request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 429 {object} models.ErrorResponse "Too many connections for this user"
+// @Failure 503 {object} models.ErrorResponse "Live updates are not available or the server is at its connection limit"
+// @Router /ws [get]
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	if h.hub == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Live updates are not available"))
+		return
+	}
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Expected a WebSocket upgrade request"))
+		return
+	}
+
+	client, err := h.hub.Connect(c.GetString("userID"))
+	switch {
+	case errors.Is(err, realtime.ErrUserConnections):
+		respondError(c, apierr.New(http.StatusTooManyRequests, apierr.RateLimited, "Too many connections for this user"))
+		return
+	case err != nil:
+		c.Header("Retry-After", "30")
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.Unavailable, "Too many connections, try again later"))
+		return
+	}
+	defer h.hub.Disconnect(client)
+
+	// Clients authenticate with a token rather than cookies, so the
+	// origin needs no check
+	server := websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			h.serve(c, conn, client)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serve writes the client's messages and pings to conn while reading its
+// messages in the background, until either side goes away
+func (h *RealtimeHandler) serve(c *gin.Context, conn *websocket.Conn, client *realtime.Client) {
+	conn.MaxPayloadBytes = maxClientMessage
+	// The server's deadlines stay on the connection after the upgrade
+	conn.SetDeadline(time.Time{})
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		h.read(c, conn, client)
+	}()
+	// The reader uses the request, so it must be done before returning
+	defer func() {
+		conn.Close()
+		<-readDone
+	}()
+
+	// Sessions end with their token, like any other request would
+	var expired <-chan time.Time
+	if claims, ok := c.Get("claims"); ok {
+		if expiry := claims.(*auth.Claims).ExpiresAt; expiry != nil {
+			timer := time.NewTimer(time.Until(expiry.Time))
+			defer timer.Stop()
+			expired = timer.C
+		}
+	}
+
+	ticker := time.NewTicker(h.pingInterval)
+	defer ticker.Stop()
+	ping, _ := json.Marshal(realtime.Message{Type: realtime.TypePing})
+
+	for {
+		var data []byte
+		select {
+		case data = <-client.Messages():
+		case <-ticker.C:
+			data = ping
+		case <-client.Done():
+			return
+		case <-readDone:
+			return
+		case <-expired:
+			return
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := websocket.Message.Send(conn, string(data)); err != nil {
+			return
+		}
+	}
+}
+
+// read handles the messages of the client. Any message, pongs included,
+// shows the client is still there.
+func (h *RealtimeHandler) read(c *gin.Context, conn *websocket.Conn, client *realtime.Client) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return
+		}
+
+		var msg realtime.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			h.hub.Send(client, realtime.Message{Type: realtime.TypeError, Error: "Invalid message"})
+			continue
+		}
+
+		switch msg.Type {
+		case realtime.TypeSubscribe:
+			if reason := h.subscribe(c, client, msg.Room); reason != "" {
+				h.hub.Send(client, realtime.Message{Type: realtime.TypeError, Room: msg.Room, Error: reason})
+				continue
+			}
+			h.hub.Send(client, realtime.Message{Type: realtime.TypeSubscribed, Room: msg.Room})
+		case realtime.TypeUnsubscribe:
+			h.hub.Leave(client, msg.Room)
+			h.hub.Send(client, realtime.Message{Type: realtime.TypeUnsubscribed, Room: msg.Room})
+		case realtime.TypePong:
+		default:
+			h.hub.Send(client, realtime.Message{Type: realtime.TypeError, Error: "Unknown message type"})
+		}
+	}
+}
+
+// subscribe joins client to room if the user may read what it is about,
+// returning why not otherwise. Posts are checked as GetPost checks them.
+func (h *RealtimeHandler) subscribe(c *gin.Context, client *realtime.Client, room string) string {
+	_, postID, err := realtime.ParseRoom(room)
+	if err != nil {
+		return "Unknown room"
+	}
+	if !hasPermission(c, models.PermPostsRead) {
+		return "Not allowed to read posts"
+	}
+	post, err := h.storageService.GetPost(c.Request.Context(), postID)
+	if err != nil {
+		return "Post not found"
+	}
+	if !canReadPost(c, h.storageService, post) {
+		return "Not allowed to read the post"
+	}
+	if err := h.hub.Join(client, room); err != nil {
+		return "Too many rooms"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/realtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	author, _ := api.user("author", models.RoleUser)
+	member, _ := api.user("member", models.RoleUser)
+	outsider, _ := api.user("outsider", models.RoleUser)
+
+	org := &models.Organization{Name: "Team", CreatedBy: author.ID}
+	require.NoError(t, api.storage.CreateOrganization(ctx, org))
+	require.NoError(t, api.storage.SaveMembership(ctx, &models.Membership{OrgID: org.ID, UserID: member.ID, Role: models.OrgRoleViewer}))
+	post := &models.Post{UserID: author.ID, OrgID: org.ID, Title: "Plans", Content: "Internal", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(ctx, post))
+
+	h := NewRealtimeHandler(api.storage, realtime.NewHub(10, 10), 0)
+	subscribe := func(user *models.User, post *models.Post) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/ws", nil)
+		c.Set("userID", user.ID)
+		c.Set("permissions", models.DefaultRoles[user.Role])
+		client, err := h.hub.Connect(user.ID)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.hub.Disconnect(client) })
+		return h.subscribe(c, client, realtime.PostRoom(post.ID))
+	}
+
+	assert.Empty(t, subscribe(member, post))
+	assert.Equal(t, "Not allowed to read the post", subscribe(outsider, post))
+
+	// Posts withheld by moderation are followed by their author and post
+	// admins alone
+	admin, _ := api.user("admin", models.RoleAdmin)
+	withheld := &models.Post{UserID: author.ID, Title: "Spam?", Content: "Buy now", Status: models.PostStatusPending}
+	require.NoError(t, api.storage.CreatePost(ctx, withheld))
+	assert.Equal(t, "Not allowed to read the post", subscribe(outsider, withheld))
+	assert.Empty(t, subscribe(author, withheld))
+	assert.Empty(t, subscribe(admin, withheld))
+}
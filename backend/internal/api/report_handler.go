@@ -0,0 +1,244 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// ReportHandler lets users flag posts and files to the admins, and post
+// and file admins work through the reports of their kind of content
+type ReportHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewReportHandler(storageService *services.StorageService, messagingClient *messaging.Client) *ReportHandler {
+	return &ReportHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// ReportPost godoc
+// @Summary Report a post
+// @Description Flag a post you can read to the post admins. You have one open report per post; once MODERATION_REPORT_THRESHOLD users reported it, the reports are escalated and the post is held for review.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param request body models.CreateReportRequest true "Reason and details"
+// @Success 201 {object} models.SuccessResponse{data=models.Report} "Report submitted successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request or own post"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not a member of the post's organization"
+// @Failure 404 {object} models.ErrorResponse "Post not found"
+// @Failure 409 {object} models.ErrorResponse "Already reported"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/report [post]
+func (h *ReportHandler) ReportPost(c *gin.Context) {
+	post, err := h.storageService.GetPost(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return
+	}
+	if !canReadPost(c, h.storageService, post) {
+		respondError(c, orgRoleError(models.OrgRoleViewer))
+		return
+	}
+	if post.UserID == c.GetString("userID") {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Cannot report your own post"))
+		return
+	}
+
+	h.report(c, models.ReportTargetPost, post.ID)
+}
+
+// ReportFile godoc
+// @Summary Report a file
+// @Description Flag a file you can read to the file admins. You have one open report per file; once MODERATION_REPORT_THRESHOLD users reported it, the reports are escalated.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.CreateReportRequest true "Reason and details"
+// @Success 201 {object} models.SuccessResponse{data=models.Report} "Report submitted successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request or own file"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 409 {object} models.ErrorResponse "Already reported"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/report [post]
+func (h *ReportHandler) ReportFile(c *gin.Context) {
+	file, err := h.storageService.GetFile(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+	if !canReadFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot report other user's private file"))
+		return
+	}
+	if file.UserID == c.GetString("userID") {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Cannot report your own file"))
+		return
+	}
+
+	h.report(c, models.ReportTargetFile, file.ID)
+}
+
+func (h *ReportHandler) report(c *gin.Context, targetType, targetID string) {
+	var req models.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	report := &models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReporterID: c.GetString("userID"),
+		Reason:     req.Reason,
+		Details:    req.Details,
+	}
+	err := h.storageService.CreateReport(c.Request.Context(), report)
+	if errors.Is(err, services.ErrAlreadyReported) {
+		respondError(c, apierr.New(http.StatusConflict, apierr.AlreadyReported, "You already reported this "+targetType))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to submit report"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Report submitted successfully",
+		Data:    report,
+	})
+}
+
+// ListReports godoc
+// @Summary List reports
+// @Description Get a paginated list of reports, oldest first. Post admins see the reports of posts and file admins those of files. Filter by status (open, escalated, resolved or dismissed), targetType, targetId, reporterId and reason.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt"
+// @Param status query string false "open, escalated, resolved or dismissed"
+// @Param targetType query string false "post or file"
+// @Success 200 {object} models.ListResponse{data=[]models.Report} "Reports retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/reports [get]
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+	if len(opts.Sort) == 0 {
+		opts.Sort = []models.SortField{{Field: "createdAt"}}
+	}
+
+	postsAdmin, filesAdmin := hasPermission(c, models.PermPostsAdmin), hasPermission(c, models.PermFilesAdmin)
+	switch {
+	case !postsAdmin && !filesAdmin:
+		respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission "+models.PermPostsAdmin+" or "+models.PermFilesAdmin))
+		return
+	case !filesAdmin:
+		opts.Filters = append(opts.Filters, models.Filter{Field: "targetType", Op: models.OpEq, Value: models.ReportTargetPost})
+	case !postsAdmin:
+		opts.Filters = append(opts.Filters, models.Filter{Field: "targetType", Op: models.OpEq, Value: models.ReportTargetFile})
+	}
+
+	reports, total, err := h.storageService.ListReports(c.Request.Context(), pagination, opts)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list reports"))
+		return
+	}
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       reports,
+		Pagination: pagination,
+	})
+}
+
+// ResolvePostReports godoc
+// @Summary Resolve the reports of a post
+// @Description Close every open report of a post: resolved when you acted on it, dismissed when not. Holding or deleting the post is done separately.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param request body models.ResolveReportsRequest true "Outcome and note"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Report} "Reports resolved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "No open reports"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/reports/posts/{id}/resolve [post]
+func (h *ReportHandler) ResolvePostReports(c *gin.Context) {
+	h.resolve(c, models.ReportTargetPost, models.AdminTargetPost)
+}
+
+// ResolveFileReports godoc
+// @Summary Resolve the reports of a file
+// @Description Close every open report of a file: resolved when you acted on it, dismissed when not. Deleting the file is done separately.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.ResolveReportsRequest true "Outcome and note"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Report} "Reports resolved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "No open reports"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/reports/files/{id}/resolve [post]
+func (h *ReportHandler) ResolveFileReports(c *gin.Context) {
+	h.resolve(c, models.ReportTargetFile, models.AdminTargetFile)
+}
+
+func (h *ReportHandler) resolve(c *gin.Context, targetType, adminTarget string) {
+	var req models.ResolveReportsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	targetID := c.Param("id")
+	reports, err := h.storageService.ResolveReports(c.Request.Context(), targetType, targetID, req.Status, req.Resolution, c.GetString("userID"))
+	if errors.Is(err, services.ErrReportNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.ReportNotFound, "No open reports of this "+targetType))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to resolve reports"))
+		return
+	}
+
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminReportResolve,
+		TargetType: adminTarget,
+		TargetID:   targetID,
+	}, nil, reports)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Reports resolved successfully",
+		Data:    reports,
+	})
+}
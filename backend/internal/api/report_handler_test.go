@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListReportsPermissions(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	userPermissions := models.DefaultRoles[models.RoleUser].Permissions
+	for _, role := range []*models.Role{
+		{Name: "post-moderator", Permissions: append([]string{models.PermPostsAdmin}, userPermissions...)},
+		{Name: "file-moderator", Permissions: append([]string{models.PermFilesAdmin}, userPermissions...)},
+	} {
+		require.NoError(t, api.storage.SaveRole(ctx, role))
+	}
+	_, postModerator := api.user("posts", "post-moderator")
+	_, fileModerator := api.user("files", "file-moderator")
+	_, admin := api.user("root", models.RoleAdmin)
+	_, user := api.user("alice", models.RoleUser)
+
+	for _, report := range []*models.Report{
+		{TargetType: models.ReportTargetPost, TargetID: "post-1", ReporterID: "alice", Reason: "spam"},
+		{TargetType: models.ReportTargetFile, TargetID: "file-1", ReporterID: "alice", Reason: "copyright"},
+	} {
+		require.NoError(t, api.storage.CreateReport(ctx, report))
+	}
+
+	targets := func(token string) []string {
+		t.Helper()
+		w := api.do(http.MethodGet, "/api/v1/admin/reports", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var reports []*models.Report
+		decode(t, w, &reports)
+		var targets []string
+		for _, report := range reports {
+			targets = append(targets, report.TargetType)
+		}
+		return targets
+	}
+	assert.Equal(t, []string{models.ReportTargetPost}, targets(postModerator))
+	assert.Equal(t, []string{models.ReportTargetFile}, targets(fileModerator))
+	assert.ElementsMatch(t, []string{models.ReportTargetPost, models.ReportTargetFile}, targets(admin))
+
+	// Asking for the other kind does not get around the filter
+	w := api.do(http.MethodGet, "/api/v1/admin/reports?targetType=file", postModerator, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var reports []*models.Report
+	decode(t, w, &reports)
+	assert.Empty(t, reports)
+
+	w = api.do(http.MethodGet, "/api/v1/admin/reports", user, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, string(apierr.PermissionDenied), errorCode(t, w))
+}
+
+func TestReportPostOnce(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	author, _ := api.user("bob", models.RoleUser)
+	_, reporter := api.user("alice", models.RoleUser)
+	_, admin := api.user("root", models.RoleAdmin)
+	post := &models.Post{UserID: author.ID, Title: "Hello", Content: "World", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(ctx, post))
+	path := "/api/v1/posts/" + post.ID + "/report"
+
+	w := api.do(http.MethodPost, path, reporter, map[string]string{"reason": "spam"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// A second report while the first is open is refused
+	w = api.do(http.MethodPost, path, reporter, map[string]string{"reason": "harassment"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, string(apierr.AlreadyReported), errorCode(t, w))
+
+	// Once it is closed the content can be reported again
+	w = api.do(http.MethodPost, "/api/v1/admin/reports/posts/"+post.ID+"/resolve", admin, map[string]string{"status": models.ReportStatusDismissed})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodPost, path, reporter, map[string]string{"reason": "harassment"})
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Authors cannot report their own posts
+	w = api.do(http.MethodPost, path, api.token(author), map[string]string{"reason": "spam"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReportEscalation(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) { cfg.Moderation.ReportThreshold = 2 })
+	ctx := context.Background()
+
+	author, _ := api.user("bob", models.RoleUser)
+	post := &models.Post{UserID: author.ID, Title: "Hello", Content: "World", Status: models.PostStatusPublished}
+	require.NoError(t, api.storage.CreatePost(ctx, post))
+	path := "/api/v1/posts/" + post.ID + "/report"
+
+	_, first := api.user("alice", models.RoleUser)
+	w := api.do(http.MethodPost, path, first, map[string]string{"reason": "spam"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var report models.Report
+	decode(t, w, &report)
+	assert.Equal(t, models.ReportStatusOpen, report.Status)
+	stored, err := api.storage.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusPublished, stored.Status)
+
+	// The report reaching the threshold escalates all of them and holds the post
+	_, second := api.user("carol", models.RoleUser)
+	w = api.do(http.MethodPost, path, second, map[string]string{"reason": "spam"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	decode(t, w, &report)
+	assert.Equal(t, models.ReportStatusEscalated, report.Status)
+
+	reports, _, err := api.storage.ListReports(ctx, models.Pagination{Page: 1, PageSize: 10}, models.QueryOptions{})
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	for _, report := range reports {
+		assert.Equal(t, models.ReportStatusEscalated, report.Status)
+	}
+	stored, err = api.storage.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusPending, stored.Status)
+	item, err := api.storage.GetModerationItem(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports:2"}, item.Reasons)
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,63}$`)
+
+type RoleHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewRoleHandler(storageService *services.StorageService, messagingClient *messaging.Client) *RoleHandler {
+	return &RoleHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// ListPermissions godoc
+// @Summary List permissions
+// @Description List the permissions roles can grant. Besides these, "*" grants everything and "files:*" grants every files permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]string} "Permissions retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Router /admin/permissions [get]
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Permissions retrieved successfully",
+		Data:    models.Permissions,
+	})
+}
+
+// ListRoles godoc
+// @Summary List roles
+// @Description List the built-in and custom roles with their permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.Role} "Roles retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.storageService.ListRoles(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list roles"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Roles retrieved successfully",
+		Data:    roles,
+	})
+}
+
+// GetRole godoc
+// @Summary Get role
+// @Description Get a role with its permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} models.SuccessResponse{data=models.Role} "Role retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Role not found"
+// @Router /admin/roles/{name} [get]
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, ok := h.role(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Role retrieved successfully",
+		Data:    role,
+	})
+}
+
+// CreateRole godoc
+// @Summary Create role
+// @Description Create a custom role. Names are 2-64 lowercase letters, digits, dashes or underscores.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateRoleRequest true "Role definition"
+// @Success 201 {object} models.SuccessResponse{data=models.Role} "Role created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 409 {object} models.ErrorResponse "Role already exists"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Role name must be 2-64 lowercase letters, digits, dashes or underscores"))
+		return
+	}
+	if !validPermissions(c, req.Permissions) {
+		return
+	}
+
+	_, err := h.storageService.GetRole(c.Request.Context(), req.Name)
+	if err == nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.RoleExists, "Role already exists"))
+		return
+	}
+	if !errors.Is(err, services.ErrRoleNotFound) {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create role"))
+		return
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := h.storageService.SaveRole(c.Request.Context(), role); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create role"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminRoleCreate,
+		TargetType: models.AdminTargetRole,
+		TargetID:   role.Name,
+	}, nil, role)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Role created successfully",
+		Data:    role,
+	})
+}
+
+// UpdateRole godoc
+// @Summary Update role
+// @Description Change a role's description or replace its permissions. The admin role always keeps every permission. Changes reach all servers within 30 seconds.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param request body models.UpdateRoleRequest true "Fields to change"
+// @Success 200 {object} models.SuccessResponse{data=models.Role} "Role updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Role not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/roles/{name} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	role, ok := h.role(c)
+	if !ok {
+		return
+	}
+	before := *role
+
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		if role.Name == models.RoleAdmin {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.RoleProtected, "The admin role always has every permission"))
+			return
+		}
+		if !validPermissions(c, req.Permissions) {
+			return
+		}
+		role.Permissions = req.Permissions
+	}
+
+	if err := h.storageService.SaveRole(c.Request.Context(), role); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update role"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminRoleUpdate,
+		TargetType: models.AdminTargetRole,
+		TargetID:   role.Name,
+	}, &before, role)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Role updated successfully",
+		Data:    role,
+	})
+}
+
+// DeleteRole godoc
+// @Summary Delete role
+// @Description Delete a custom role that is not assigned to any user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} models.SuccessResponse "Role deleted successfully"
+// @Failure 400 {object} models.ErrorResponse "Built-in role"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Role not found"
+// @Failure 409 {object} models.ErrorResponse "Role is assigned to users"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.role(c)
+	if !ok {
+		return
+	}
+	if role.BuiltIn {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.RoleProtected, "Built-in roles cannot be deleted"))
+		return
+	}
+
+	err := h.storageService.DeleteRole(c.Request.Context(), role.Name)
+	if errors.Is(err, services.ErrRoleInUse) {
+		respondError(c, apierr.New(http.StatusConflict, apierr.RoleInUse, "Role is assigned to users; give them another role first"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete role"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminRoleDelete,
+		TargetType: models.AdminTargetRole,
+		TargetID:   role.Name,
+	}, role, nil)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Role deleted successfully",
+	})
+}
+
+// role loads the role named in the path, writing a 404 response when
+// there is none
+func (h *RoleHandler) role(c *gin.Context) (*models.Role, bool) {
+	role, err := h.storageService.GetRole(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, services.ErrRoleNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.RoleNotFound, "Role not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get role"))
+		return nil, false
+	}
+	return role, true
+}
+
+// validPermissions writes a 400 response naming the first unknown permission
+func validPermissions(c *gin.Context, permissions []string) bool {
+	for _, permission := range permissions {
+		if !models.ValidPermission(permission) {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Unknown permission "+permission))
+			return false
+		}
+	}
+	return true
+}
@@ -1,26 +1,196 @@
 package api
 
 import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/captcha"
 	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/directory"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/ipfilter"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/notifications"
+	"github.com/minio-fullstack-storage/backend/internal/oauth"
+	"github.com/minio-fullstack-storage/backend/internal/openapi"
+	"github.com/minio-fullstack-storage/backend/internal/ratelimit"
+	"github.com/minio-fullstack-storage/backend/internal/realtime"
+	"github.com/minio-fullstack-storage/backend/internal/reporting"
+	"github.com/minio-fullstack-storage/backend/internal/scheduler"
 	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/redis/go-redis/v9"
 )
 
-func SetupRoutes(router *gin.Engine, cfg *config.Config, storageService *services.StorageService) {
-	// Services are passed in from main
+func SetupRoutes(router *gin.Engine, live *config.Live, storageService *services.StorageService, messagingClient *messaging.Client, jobQueue *jobs.Queue, redisClient *redis.Client, taskScheduler *scheduler.Scheduler, logger *slog.Logger) {
+	// Services are passed in from main. Settings are read from cfg once;
+	// the ones that change on reload are read from live when used.
+	cfg := live.Get()
+
+	jwtManager, err := newJWTManager(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	var refreshStore *auth.RefreshStore
+	var denylist *auth.Denylist
+	var resetStore *auth.ResetStore
+	var loginFailures *auth.LoginFailures
+	var limiter *ratelimit.Limiter
+	var notificationStore *notifications.Store
+	var setupStore *auth.SetupStore
+	var shareDownloads *auth.ShareDownloads
+	if redisClient != nil {
+		refreshStore = auth.NewRefreshStore(redisClient, time.Duration(cfg.JWT.RefreshTokenTTL)*time.Hour)
+		denylist = newDenylist(redisClient, jwtManager.TTL())
+		resetStore = auth.NewResetStore(redisClient, time.Duration(cfg.Auth.ResetTokenTTL)*time.Minute)
+		loginFailures = auth.NewLoginFailures(redisClient, 15*time.Minute)
+		notificationStore = notifications.New(redisClient)
+		setupStore = auth.NewSetupStore(redisClient, setupTokenTTL)
+		shareDownloads = auth.NewShareDownloads(redisClient)
+		limiter = ratelimit.NewLimiter(redisClient)
+	}
 
-	jwtManager := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWT.Expiration)
+	passwordPolicy, err := auth.NewPasswordPolicy(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	passwordHasher, err := auth.NewPasswordHasher(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+
+	// Emails are sent in the background when the job queue is available
+	var mail mailer.Mailer = mailer.NewReloadingMailer(func() config.MailConfig { return live.Get().Mail })
+	if jobQueue != nil {
+		mail = mailer.NewQueuedMailer(jobQueue)
+	}
+
+	inviteSigner := auth.NewInviteSigner(cfg.Auth.SigningSecret)
+	verificationSigner := auth.NewVerificationSigner(cfg.Auth.SigningSecret, time.Duration(cfg.Auth.VerifyTTL)*time.Hour)
+
+	dir, err := newDirectory(cfg.LDAP)
+	if err != nil {
+		log.Fatalf("Invalid LDAP settings: %v", err)
+	}
+
+	var captchaVerifier captcha.Verifier
+	if cfg.Auth.CaptchaProvider != "" {
+		verifier, err := captcha.New(cfg.Auth.CaptchaProvider, cfg.Auth.CaptchaSecret)
+		if err != nil {
+			log.Fatalf("Invalid CAPTCHA settings: %v", err)
+		}
+		captchaVerifier = verifier
+	}
+
+	ipFilter, err := ipfilter.New(cfg.Network.AllowedIPs, cfg.Network.DeniedIPs)
+	if err != nil {
+		log.Fatalf("Invalid IP allow or deny list: %v", err)
+	}
+	adminIPFilter, err := ipfilter.New(cfg.Network.AdminAllowedIPs, cfg.Network.AdminDeniedIPs)
+	if err != nil {
+		log.Fatalf("Invalid admin IP allow or deny list: %v", err)
+	}
+
+	errorSink, err := newErrorSink(cfg.Reporting)
+	if err != nil {
+		log.Fatalf("Invalid error reporting settings: %v", err)
+	}
+
+	// Live updates relay the domain events every server publishes
+	var hub *realtime.Hub
+	if messagingClient != nil && cfg.NATS.EventsEnabled {
+		hub = realtime.NewHub(cfg.WebSocket.MaxConnections, cfg.WebSocket.MaxPerUser)
+		if _, err := messagingClient.Subscribe(events.StreamSubjects, hub.HandleEvent); err != nil {
+			log.Fatalf("Failed to subscribe to events for live updates: %v", err)
+		}
+	}
+
+	apiDocs, err := openapi.Document()
+	if err != nil {
+		log.Fatalf("Failed to build the OpenAPI document: %v", err)
+	}
 
 	// Initialize handlers
-	authHandler := NewAuthHandler(storageService, jwtManager)
-	userHandler := NewUserHandler(storageService)
-	postHandler := NewPostHandler(storageService)
-	fileHandler := NewFileHandler(storageService)
+	authHandler := NewAuthHandler(storageService, jwtManager, refreshStore, denylist, resetStore, passwordPolicy, passwordHasher, inviteSigner, verificationSigner, captchaVerifier, loginFailures, dir, mail, messagingClient, live)
+	oauthHandler := NewOAuthHandler(authHandler, oauthProviders(cfg.OAuth), cfg.OAuth.SuccessURL)
+	userHandler := NewUserHandler(storageService, messagingClient)
+	roleHandler := NewRoleHandler(storageService, messagingClient)
+	invitationHandler := NewInvitationHandler(storageService, inviteSigner, mail, messagingClient, cfg.Auth)
+	auditHandler := NewAuditHandler(storageService)
+	postHandler := NewPostHandler(storageService, messagingClient)
+	fileHandler := NewFileHandler(storageService, messagingClient, jobQueue, live)
+	shareHandler := NewShareHandler(storageService, messagingClient, passwordHasher, mail, shareDownloads, cfg.Mail.ShareURL)
+	scimHandler := NewSCIMHandler(storageService, messagingClient)
+	serviceAccountHandler := NewServiceAccountHandler(storageService, messagingClient)
+	maintenanceHandler := NewMaintenanceHandler(storageService, messagingClient, cfg.Maintenance)
+	statsHandler := NewStatsHandler(storageService, redisClient, messagingClient)
+	configHandler := NewConfigHandler(live)
+	jobHandler := NewJobHandler(jobQueue, messagingClient)
+	schedulerHandler := NewSchedulerHandler(taskScheduler, storageService, messagingClient)
+	notificationHandler := NewNotificationHandler(notificationStore)
+	orgHandler := NewOrgHandler(storageService, messagingClient)
+	webhookHandler := NewWebhookHandler(storageService, messagingClient, cfg.NATS.EventsEnabled, cfg.Webhook.AllowPrivate)
+	graphqlHandler := NewGraphQLHandler(storageService)
+	docsHandler := NewDocsHandler(apiDocs, "/openapi.json")
+	davHandler := NewDAVHandler(fileHandler)
+	s3Handler := NewS3Handler(fileHandler, auth.NewS3KeySigner(cfg.Auth.SigningSecret), messagingClient)
+	setupHandler := NewSetupHandler(storageService, setupStore, passwordPolicy, passwordHasher)
+	go setupHandler.OfferSetup(context.Background(), logger)
+	runtimeHandler := NewRuntimeHandler(storageService)
+	commentHandler := NewCommentHandler(storageService, messagingClient, cfg.Comments.MaxDepth)
+	moderationHandler := NewModerationHandler(storageService, messagingClient)
+	reportHandler := NewReportHandler(storageService, messagingClient)
+	schemaHandler := NewSchemaHandler(storageService, messagingClient)
+	avatarHandler := NewAvatarHandler(storageService, cfg.Upload.AvatarMaxSize)
+	realtimeHandler := NewRealtimeHandler(storageService, hub, time.Duration(cfg.WebSocket.PingInterval)*time.Second)
 
 	// Apply global middleware
-	router.Use(CORSMiddleware())
-	router.Use(RateLimitMiddleware())
+	router.Use(RequestIDMiddleware(), TracingMiddleware(), AccessLogMiddleware(logger, cfg.Log), RecoveryMiddleware(errorSink), CORSMiddleware(func() []string { return live.Get().CORS.AllowedOrigins }), IPFilterMiddleware(ipFilter))
+	// Uploads stream their body and check the upload limits themselves
+	router.Use(BodyLimitMiddleware(cfg.Request.MaxBodySize, map[string]int64{
+		"/api/v1/files/upload":       cfg.Request.MaxUploadBodySize,
+		"/api/v1/files/upload/batch": cfg.Request.MaxUploadBodySize,
+		davPrefix + "/*path":         cfg.Request.MaxUploadBodySize,
+		"/api/v1/profile/avatar":     cfg.Upload.AvatarMaxSize + multipartOverhead,
+	}))
+	// Transfers last as long as the client takes to send or receive them
+	adminTimeout := time.Duration(cfg.Request.AdminTimeout) * time.Second
+	transferTimeout := time.Duration(cfg.Request.TransferTimeout) * time.Second
+	router.Use(TimeoutMiddleware(time.Duration(cfg.Request.Timeout)*time.Second, map[string]time.Duration{
+		"/api/v1/admin/":                               adminTimeout,
+		"/debug/pprof/*profile":                        adminTimeout,
+		"/api/v1/files/upload":                         transferTimeout,
+		"/api/v1/files/upload/batch":                   transferTimeout,
+		"/api/v1/files/uploads/:id/progress":           transferTimeout,
+		"/api/v1/files/zip":                            transferTimeout,
+		"/api/v1/files/:id/download":                   transferTimeout,
+		"/api/v1/files/:id/stream/*asset":              transferTimeout,
+		"/api/v1/files/:id/versions/:version/download": transferTimeout,
+		"/api/v1/public/files/:id/download":            transferTimeout,
+		"/api/v1/shares/:token":                        transferTimeout,
+		"/api/v1/profile/data-export":                  transferTimeout,
+		"/api/v1/admin/export/":                        transferTimeout,
+		"/api/v1/ws":                                   0,
+		davPrefix + "/*path":                           transferTimeout,
+	}))
+	// Signing in and out, building a zip and GraphQL queries change nothing
+	// that matters, and admins need to be able to end maintenance
+	router.Use(MaintenanceMiddleware(storageService, cfg.Maintenance, map[string]bool{
+		"/api/v1/auth/login":        true,
+		"/api/v1/auth/refresh":      true,
+		"/api/v1/auth/logout":       true,
+		"/api/v1/files/zip":         true,
+		"/api/v1/graphql":           true,
+		"/api/v1/admin/maintenance": true,
+		"/debug/pprof/*profile":     true,
+	}))
 
 	// Health check
 	// @Summary Health check
@@ -37,61 +207,391 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, storageService *service
 		})
 	})
 
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Profiles for go tool pprof, authenticated like the admin routes.
+	// The tool sends the token as a basic auth password in the URL, e.g.
+	// https://:TOKEN@host/debug/pprof/heap; symbol lookups are POSTed.
+	pprofChain := []gin.HandlerFunc{IPFilterMiddleware(adminIPFilter), BasicTokenMiddleware("pprof"), AuthMiddleware(jwtManager, denylist, storageService), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService), RequirePermission(models.PermSystemAdmin), runtimeHandler.Pprof}
+	router.GET("/debug/pprof/*profile", pprofChain...)
+	router.POST("/debug/pprof/*profile", pprofChain...)
+
+	// OpenAPI description of the API; its UI is an admin route
+	router.GET("/openapi.json", docsHandler.OpenAPI)
+
+	// The signed-in user's files as a WebDAV drive
+	davChain := []gin.HandlerFunc{DAVAuthMiddleware(storageService, jwtManager, loginFailures, messagingClient), AuthMiddleware(jwtManager, denylist, storageService), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService), RequirePermission(models.PermFilesRead), davHandler.Serve}
+	for method := range davMethods {
+		router.Handle(method, davPrefix+"/*path", davChain...)
+	}
+
+	// SCIM provisioning for identity providers
+	if cfg.SCIM.Token != "" {
+		scimRoutes := router.Group("/scim/v2")
+		scimRoutes.Use(SCIMAuthMiddleware(cfg.SCIM.Token), RateLimitMiddleware(limiter, "scim", rateLimit(live, "api")))
+		{
+			scimRoutes.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+			scimRoutes.GET("/Users", scimHandler.ListUsers)
+			scimRoutes.POST("/Users", scimHandler.CreateUser)
+			scimRoutes.GET("/Users/:id", scimHandler.GetUser)
+			scimRoutes.PUT("/Users/:id", scimHandler.ReplaceUser)
+			scimRoutes.PATCH("/Users/:id", scimHandler.PatchUser)
+			scimRoutes.DELETE("/Users/:id", scimHandler.DeactivateUser)
+		}
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
 		// Public routes
 		auth := v1.Group("/auth")
+		auth.Use(RateLimitMiddleware(limiter, "auth", rateLimit(live, "auth")))
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/forgot-password", authHandler.ForgotPassword)
+			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.GET("/oauth/:provider", oauthHandler.OAuthLogin)
+			auth.GET("/oauth/:provider/callback", oauthHandler.OAuthCallback)
+		}
+
+		publicLimit := RateLimitMiddleware(limiter, "public", rateLimit(live, "public"))
+
+		// First admin of a new installation
+		v1.GET("/setup", publicLimit, setupHandler.GetSetup)
+		v1.POST("/setup", RateLimitMiddleware(limiter, "auth", rateLimit(live, "auth")), setupHandler.CompleteSetup)
+
+		// Public share links
+		v1.GET("/shares/:token", publicLimit, shareHandler.DownloadShare)
+
+		// Public files and profiles
+		public := v1.Group("/public")
+		public.Use(publicLimit)
+		{
+			public.GET("/files/:id/download", fileHandler.DownloadPublicFile)
+			public.GET("/users/:username", userHandler.GetPublicProfile)
+			public.GET("/avatars/:id", avatarHandler.GetAvatar)
 		}
 
+		// Live updates, authenticated like the protected routes
+		v1.GET("/ws", WebSocketTokenMiddleware(), AuthMiddleware(jwtManager, denylist, storageService), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService), realtimeHandler.Connect)
+
+		// Swagger UI for API consumers, authenticated like the admin
+		// routes; browsers send the token as a basic auth password
+		v1.GET("/admin/docs/*any", IPFilterMiddleware(adminIPFilter), BasicTokenMiddleware("API docs"), AuthMiddleware(jwtManager, denylist, storageService), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService), RequirePermission(models.PermSystemAdmin), docsHandler.UI)
+
 		// Protected routes
 		protected := v1.Group("/")
-		protected.Use(AuthMiddleware(jwtManager))
+		protected.Use(AuthMiddleware(jwtManager, denylist, storageService), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService))
 		{
+			protected.POST("/auth/logout", authHandler.Logout)
+
 			// Profile routes
 			protected.GET("/profile", authHandler.GetProfile)
+			protected.POST("/profile/change-password", authHandler.ChangePassword)
+			protected.POST("/profile/verify-email", authHandler.ResendVerification)
+			protected.GET("/profile/logins", authHandler.GetLoginHistory)
+			protected.GET("/profile/settings", authHandler.GetSettings)
+			protected.PUT("/profile/settings", authHandler.UpdateSettings)
+			protected.POST("/profile/avatar", avatarHandler.UploadAvatar)
+			protected.DELETE("/profile/avatar", avatarHandler.DeleteAvatar)
+			protected.GET("/profile/bookmarks", RequirePermission(models.PermPostsRead), PaginationMiddleware(), postHandler.ListBookmarks)
+			protected.GET("/profile/mentions", RequirePermission(models.PermPostsRead), PaginationMiddleware(), postHandler.ListMentions)
+			protected.GET("/tags", RequirePermission(models.PermPostsRead), postHandler.ListTags)
+			protected.POST("/profile/data-export", authHandler.ExportData)
+			protected.POST("/profile/erasure", authHandler.EraseAccount)
 
 			// User routes
 			users := protected.Group("/users")
 			users.Use(PaginationMiddleware())
 			{
-				users.GET("/", userHandler.ListUsers)
-				users.GET("/:id", userHandler.GetUser)
+				users.GET("/", RequirePermission(models.PermUsersRead), QueryMiddleware(userQuery), userHandler.ListUsers)
+				users.GET("/:id", RequirePermission(models.PermUsersRead), userHandler.GetUser)
 				users.PUT("/:id", userHandler.UpdateUser)
 				users.DELETE("/:id", userHandler.DeleteUser)
 			}
 
 			// Post routes
 			posts := protected.Group("/posts")
-			posts.Use(PaginationMiddleware())
+			posts.Use(PaginationMiddleware(), RequirePermission(models.PermPostsRead))
 			{
-				posts.POST("/", postHandler.CreatePost)
-				posts.GET("/", postHandler.ListPosts)
+				writePosts := RequirePermission(models.PermPostsWrite)
+				posts.POST("/", writePosts, postHandler.CreatePost)
+				posts.GET("/", QueryMiddleware(postQuery), postHandler.ListPosts)
 				posts.GET("/:id", postHandler.GetPost)
-				posts.PUT("/:id", postHandler.UpdatePost)
-				posts.DELETE("/:id", postHandler.DeletePost)
-				posts.GET("/user/:userId", postHandler.GetUserPosts)
+				posts.PUT("/:id", writePosts, postHandler.UpdatePost)
+				posts.DELETE("/:id", writePosts, postHandler.DeletePost)
+				posts.POST("/:id/transfer", writePosts, postHandler.TransferPost)
+				posts.POST("/:id/bookmark", postHandler.BookmarkPost)
+				posts.POST("/:id/report", reportHandler.ReportPost)
+				posts.DELETE("/:id/bookmark", postHandler.UnbookmarkPost)
+				posts.GET("/:id/comments", commentHandler.ListComments)
+				posts.POST("/:id/comments", writePosts, commentHandler.CreateComment)
+				posts.DELETE("/:id/comments/:commentId", writePosts, commentHandler.DeleteComment)
+				posts.PUT("/:id/comments/:commentId/moderation", writePosts, commentHandler.ModerateComment)
+				posts.GET("/user/:userId", QueryMiddleware(postQuery), postHandler.GetUserPosts)
+			}
+
+			// Series routes
+			series := protected.Group("/series")
+			series.Use(PaginationMiddleware(), RequirePermission(models.PermPostsRead))
+			{
+				writePosts := RequirePermission(models.PermPostsWrite)
+				series.POST("/", writePosts, postHandler.CreateSeries)
+				series.GET("/", QueryMiddleware(seriesQuery), postHandler.ListSeries)
+				series.GET("/:id", postHandler.GetSeries)
+				series.PUT("/:id", writePosts, postHandler.UpdateSeries)
+				series.DELETE("/:id", writePosts, postHandler.DeleteSeries)
+				series.POST("/:id/posts", writePosts, postHandler.AddSeriesPost)
+				series.PUT("/:id/posts", writePosts, postHandler.ReorderSeries)
+				series.DELETE("/:id/posts/:postId", writePosts, postHandler.RemoveSeriesPost)
 			}
 
 			// File routes
 			files := protected.Group("/files")
+			files.Use(RequirePermission(models.PermFilesRead))
 			{
-				files.POST("/upload", fileHandler.UploadFile)
+				writeFiles := RequirePermission(models.PermFilesWrite)
+				files.POST("/upload", writeFiles, fileHandler.UploadFile)
+				files.POST("/upload/batch", writeFiles, fileHandler.UploadBatch)
+				files.POST("/upload/presign", writeFiles, fileHandler.PresignUpload)
+				files.POST("/upload/finalize", writeFiles, fileHandler.FinalizeUpload)
+				files.POST("/zip", fileHandler.DownloadZip)
+				files.GET("/", PaginationMiddleware(), QueryMiddleware(fileQuery), fileHandler.ListFiles)
+				files.GET("/expiring", fileHandler.ListExpiringFiles)
+				files.GET("/metadata-schema", schemaHandler.GetFolderSchema)
+				files.GET("/uploads/:id/progress", fileHandler.UploadProgress)
 				files.GET("/:id", fileHandler.GetFile)
+				files.PATCH("/:id", writeFiles, fileHandler.UpdateFile)
+				files.POST("/:id/copy", writeFiles, fileHandler.CopyFile)
+				files.POST("/:id/transfer", writeFiles, fileHandler.TransferFile)
 				files.GET("/:id/download", fileHandler.DownloadFile)
-				files.DELETE("/:id", fileHandler.DeleteFile)
+				files.GET("/:id/thumbnail", fileHandler.GetThumbnail)
+				files.GET("/:id/preview", fileHandler.GetPreview)
+				files.GET("/:id/stream/*asset", fileHandler.GetStream)
+				files.PUT("/:id/visibility", writeFiles, fileHandler.UpdateVisibility)
+				files.GET("/:id/stats", fileHandler.GetFileStats)
+				files.POST("/:id/report", reportHandler.ReportFile)
+				files.GET("/:id/versions", fileHandler.ListVersions)
+				files.GET("/:id/versions/:version/download", fileHandler.DownloadVersion)
+				files.POST("/:id/versions/:version/restore", writeFiles, fileHandler.RestoreVersion)
+				files.POST("/:id/shares", writeFiles, shareHandler.CreateShare)
+				files.GET("/:id/shares", shareHandler.ListShares)
+				files.DELETE("/:id/shares/:token", writeFiles, shareHandler.RevokeShare)
+				files.DELETE("/:id", writeFiles, fileHandler.DeleteFile)
+			}
+
+			// Organization routes; roles within an organization are checked
+			// by the handlers
+			orgs := protected.Group("/organizations")
+			{
+				orgs.POST("/", orgHandler.CreateOrganization)
+				orgs.GET("/", orgHandler.ListOrganizations)
+				orgs.GET("/:id", orgHandler.GetOrganization)
+				orgs.PATCH("/:id", orgHandler.UpdateOrganization)
+				orgs.DELETE("/:id", orgHandler.DeleteOrganization)
+				orgs.GET("/:id/members", orgHandler.ListMembers)
+				orgs.PUT("/:id/members/:userId", orgHandler.SetMember)
+				orgs.DELETE("/:id/members/:userId", orgHandler.RemoveMember)
+				orgs.GET("/:id/posts", RequirePermission(models.PermPostsRead), PaginationMiddleware(), QueryMiddleware(postQuery), orgHandler.ListPosts)
+				orgs.GET("/:id/files", RequirePermission(models.PermFilesRead), PaginationMiddleware(), QueryMiddleware(fileQuery), orgHandler.ListFiles)
 			}
 
+			// GraphQL reads users, posts and files, checking permissions
+			// per field
+			protected.POST("/graphql", graphqlHandler.Query)
+
+			// Credentials for the S3 gateway, which serves on its own port
+			protected.POST("/s3/credentials", RequirePermission(models.PermFilesRead), s3Handler.CreateCredentials)
+
+			// Notification routes
+			protected.GET("/notifications", PaginationMiddleware(), notificationHandler.ListNotifications)
+			protected.GET("/notifications/unread-count", notificationHandler.GetUnreadCount)
+			protected.POST("/notifications/read-all", notificationHandler.MarkAllRead)
+			protected.POST("/notifications/:id/read", notificationHandler.MarkRead)
+
 			// Admin routes
 			admin := protected.Group("/admin")
-			admin.Use(AdminMiddleware())
+			admin.Use(IPFilterMiddleware(adminIPFilter))
 			{
-				admin.GET("/users", userHandler.ListUsers)
-				admin.DELETE("/users/:id", userHandler.DeleteUser)
+				manageUsers := RequirePermission(models.PermUsersAdmin)
+				manageRoles := RequirePermission(models.PermRolesAdmin)
+				admin.GET("/users", manageUsers, PaginationMiddleware(), QueryMiddleware(userQuery), userHandler.ListUsers)
+				admin.DELETE("/users/:id", manageUsers, userHandler.DeleteUser)
+				admin.PUT("/users/:id/status", manageUsers, userHandler.UpdateUserStatus)
+				admin.GET("/users/:id/logins", manageUsers, userHandler.GetUserLogins)
+				admin.POST("/users/:id/transfer", manageUsers, userHandler.TransferContent)
+				admin.POST("/users/:id/erasure", manageUsers, authHandler.EraseUser)
+				admin.GET("/invitations", manageUsers, invitationHandler.ListInvitations)
+				admin.POST("/invitations", manageUsers, invitationHandler.CreateInvitation)
+				admin.DELETE("/invitations/:id", manageUsers, invitationHandler.RevokeInvitation)
+				admin.GET("/files/quarantine", RequirePermission(models.PermFilesAdmin), fileHandler.ListQuarantinedFiles)
+				admin.GET("/export/users", manageUsers, QueryMiddleware(userQuery), userHandler.ExportUsers)
+				admin.GET("/export/posts", RequirePermission(models.PermPostsAdmin), QueryMiddleware(postQuery), postHandler.ExportPosts)
+				moderatePosts := RequirePermission(models.PermPostsAdmin)
+				admin.GET("/moderation", moderatePosts, PaginationMiddleware(), QueryMiddleware(moderationQuery), moderationHandler.ListModerationQueue)
+				admin.POST("/moderation/:id/approve", moderatePosts, moderationHandler.ApproveModeration)
+				admin.POST("/moderation/:id/reject", moderatePosts, moderationHandler.RejectModeration)
+				admin.GET("/reports", PaginationMiddleware(), QueryMiddleware(reportQuery), reportHandler.ListReports)
+				admin.POST("/reports/posts/:id/resolve", moderatePosts, reportHandler.ResolvePostReports)
+				admin.POST("/reports/files/:id/resolve", RequirePermission(models.PermFilesAdmin), reportHandler.ResolveFileReports)
+				admin.GET("/export/files", RequirePermission(models.PermFilesAdmin), QueryMiddleware(fileQuery), fileHandler.ExportFiles)
+				manageSchemas := RequirePermission(models.PermFilesAdmin)
+				admin.GET("/metadata-schemas", manageSchemas, schemaHandler.ListSchemas)
+				admin.POST("/metadata-schemas", manageSchemas, schemaHandler.CreateSchema)
+				admin.GET("/metadata-schemas/:name", manageSchemas, schemaHandler.GetSchema)
+				admin.PUT("/metadata-schemas/:name", manageSchemas, schemaHandler.UpdateSchema)
+				admin.DELETE("/metadata-schemas/:name", manageSchemas, schemaHandler.DeleteSchema)
+				admin.POST("/tokens/revoke", manageUsers, authHandler.RevokeTokens)
+				admin.POST("/impersonate/:userId", RequirePermission(models.PermUsersImpersonate), authHandler.Impersonate)
+				admin.GET("/audit", RequirePermission(models.PermAuditRead), PaginationMiddleware(), auditHandler.ListAuditEvents)
+				admin.GET("/audit-log", RequirePermission(models.PermAuditRead), PaginationMiddleware(), auditHandler.ListAdminActions)
+				admin.GET("/permissions", manageRoles, roleHandler.ListPermissions)
+				admin.GET("/roles", manageRoles, roleHandler.ListRoles)
+				admin.POST("/roles", manageRoles, roleHandler.CreateRole)
+				admin.GET("/roles/:name", manageRoles, roleHandler.GetRole)
+				admin.PUT("/roles/:name", manageRoles, roleHandler.UpdateRole)
+				admin.DELETE("/roles/:name", manageRoles, roleHandler.DeleteRole)
+				manageServiceAccounts := RequirePermission(models.PermServiceAccountsAdmin)
+				admin.GET("/service-accounts", manageServiceAccounts, serviceAccountHandler.ListServiceAccounts)
+				admin.POST("/service-accounts", manageServiceAccounts, serviceAccountHandler.CreateServiceAccount)
+				admin.GET("/service-accounts/:id", manageServiceAccounts, serviceAccountHandler.GetServiceAccount)
+				admin.PATCH("/service-accounts/:id", manageServiceAccounts, serviceAccountHandler.UpdateServiceAccount)
+				admin.DELETE("/service-accounts/:id", manageServiceAccounts, serviceAccountHandler.DeleteServiceAccount)
+				admin.POST("/service-accounts/:id/keys", manageServiceAccounts, serviceAccountHandler.RotateServiceAccountKey)
+				admin.DELETE("/service-accounts/:id/keys/:keyId", manageServiceAccounts, serviceAccountHandler.RevokeServiceAccountKey)
+				manageSystem := RequirePermission(models.PermSystemAdmin)
+				admin.GET("/maintenance", manageSystem, maintenanceHandler.GetMaintenance)
+				admin.PUT("/maintenance", manageSystem, maintenanceHandler.UpdateMaintenance)
+				admin.GET("/stats", manageSystem, statsHandler.GetStats)
+				admin.GET("/runtime", manageSystem, runtimeHandler.GetRuntime)
+				admin.GET("/config", manageSystem, configHandler.GetConfig)
+				admin.POST("/stats/rebuild", manageSystem, statsHandler.RebuildStats)
+				admin.GET("/jobs", manageSystem, jobHandler.ListJobs)
+				admin.GET("/jobs/:type/dead", manageSystem, jobHandler.ListDeadJobs)
+				admin.POST("/jobs/:type/dead/retry", manageSystem, jobHandler.RetryDeadJobs)
+				admin.GET("/schedule", manageSystem, schedulerHandler.GetSchedule)
+				admin.POST("/schedule/:task/run", manageSystem, schedulerHandler.RunTask)
+				admin.GET("/usage", manageSystem, schedulerHandler.GetUsage)
+				manageWebhooks := RequirePermission(models.PermWebhooksAdmin)
+				admin.GET("/webhooks", manageWebhooks, webhookHandler.ListWebhooks)
+				admin.POST("/webhooks", manageWebhooks, webhookHandler.CreateWebhook)
+				admin.GET("/webhooks/:id", manageWebhooks, webhookHandler.GetWebhook)
+				admin.PATCH("/webhooks/:id", manageWebhooks, webhookHandler.UpdateWebhook)
+				admin.DELETE("/webhooks/:id", manageWebhooks, webhookHandler.DeleteWebhook)
+				admin.GET("/webhooks/:id/deliveries", manageWebhooks, PaginationMiddleware(), webhookHandler.ListDeliveries)
+				admin.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", manageWebhooks, webhookHandler.RedeliverDelivery)
 			}
 		}
 	}
 }
+
+// oauthProviders lists the social login providers that have a client ID
+func oauthProviders(cfg config.OAuthConfig) map[string]*oauth.Provider {
+	providers := make(map[string]*oauth.Provider)
+	if cfg.GoogleClientID != "" {
+		providers["google"] = oauth.NewGoogle(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.CallbackURL+"/google/callback")
+	}
+	if cfg.GitHubClientID != "" {
+		providers["github"] = oauth.NewGitHub(cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.CallbackURL+"/github/callback")
+	}
+	return providers
+}
+
+// newDirectory returns the LDAP directory users can sign in with, or nil
+// when LDAP is not configured
+func newDirectory(cfg config.LDAPConfig) (*directory.Directory, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+
+	groupRoles, err := directory.ParseGroupRoles(cfg.GroupRoles)
+	if err != nil {
+		return nil, err
+	}
+	return directory.New(directory.Config{
+		URL:                cfg.URL,
+		StartTLS:           cfg.StartTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Timeout:            time.Duration(cfg.Timeout) * time.Second,
+		BindDN:             cfg.BindDN,
+		BindPassword:       cfg.BindPassword,
+		BaseDN:             cfg.BaseDN,
+		UserFilter:         cfg.UserFilter,
+		UsernameAttribute:  cfg.UsernameAttribute,
+		EmailAttribute:     cfg.EmailAttribute,
+		FirstNameAttribute: cfg.FirstNameAttribute,
+		LastNameAttribute:  cfg.LastNameAttribute,
+		GroupAttribute:     cfg.GroupAttribute,
+		GroupRoles:         groupRoles,
+		DefaultRole:        cfg.DefaultRole,
+	})
+}
+
+// newErrorSink returns where panics are reported, or nil when they are
+// only logged
+func newErrorSink(cfg config.ReportingConfig) (reporting.Sink, error) {
+	var sinks reporting.Multi
+	if cfg.SentryDSN != "" {
+		sentry, err := reporting.NewSentry(cfg.SentryDSN, cfg.Environment)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sentry)
+	}
+	if cfg.WebhookURL != "" {
+		sinks = append(sinks, reporting.NewWebhook(cfg.WebhookURL))
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
+
+// newJWTManager signs tokens with the configured key pair, or with the
+// shared secret when there is no signing key
+func newJWTManager(cfg config.JWTConfig) (*auth.JWTManager, error) {
+	signingKey, err := keyMaterial(cfg.SigningKey, cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(signingKey) == 0 {
+		return auth.NewJWTManager(cfg.Secret, cfg.AccessTokenTTL), nil
+	}
+
+	verifyKeys, err := keyMaterial(cfg.VerifyKeys, cfg.VerifyKeysFile)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewKeyPairJWTManager(signingKey, verifyKeys, cfg.AccessTokenTTL)
+}
+
+// keyMaterial returns value, or the contents of the file at path
+func keyMaterial(value, path string) ([]byte, error) {
+	if value != "" || path == "" {
+		return []byte(value), nil
+	}
+	return os.ReadFile(path)
+}
+
+// rateLimit returns the current limit of the auth, public or api route
+// group and whether rate limiting is on
+func rateLimit(live *config.Live, group string) func() (ratelimit.Limit, bool) {
+	return func() (ratelimit.Limit, bool) {
+		limits := live.Get().RateLimit
+		limit := limits.API
+		switch group {
+		case "auth":
+			limit = limits.Auth
+		case "public":
+			limit = limits.Public
+		}
+		return ratelimit.Limit(limit), limits.Enabled
+	}
+}
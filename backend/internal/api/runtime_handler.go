@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// RuntimeHandler reports the Go runtime of this server and serves its
+// pprof profiles, for diagnosing memory spikes and goroutine leaks
+type RuntimeHandler struct {
+	storageService *services.StorageService
+	startedAt      time.Time
+}
+
+func NewRuntimeHandler(storageService *services.StorageService) *RuntimeHandler {
+	return &RuntimeHandler{
+		storageService: storageService,
+		startedAt:      time.Now(),
+	}
+}
+
+// GetRuntime godoc
+// @Summary Get runtime statistics
+// @Description Get the goroutines, heap, garbage collection and open MinIO connections of the server that answers. Behind a load balancer each server reports only itself.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.RuntimeStats} "Runtime statistics retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Router /admin/runtime [get]
+func (h *RuntimeHandler) GetRuntime(c *gin.Context) {
+	// ReadMemStats stops the world briefly, which is fine for an admin
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	now := time.Now()
+	result := models.RuntimeStats{
+		GoVersion:     runtime.Version(),
+		StartedAt:     h.startedAt,
+		UptimeSeconds: int64(now.Sub(h.startedAt).Seconds()),
+		CPUs:          runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		Memory: models.MemoryStats{
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			HeapIdle:     mem.HeapIdle,
+			HeapReleased: mem.HeapReleased,
+			HeapObjects:  mem.HeapObjects,
+			StackInuse:   mem.StackInuse,
+			Sys:          mem.Sys,
+			TotalAlloc:   mem.TotalAlloc,
+		},
+		GC: models.GCStats{
+			Cycles:       mem.NumGC,
+			PauseTotalMs: float64(mem.PauseTotalNs) / float64(time.Millisecond),
+			CPUFraction:  mem.GCCPUFraction,
+			NextHeapGoal: mem.NextGC,
+		},
+		GeneratedAt: now,
+	}
+	if mem.NumGC > 0 {
+		lastAt := time.Unix(0, int64(mem.LastGC))
+		result.GC.LastAt = &lastAt
+		result.GC.LastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / float64(time.Millisecond)
+	}
+	result.MinIOConnections.Open, result.MinIOConnections.Dialed = h.storageService.MinIOConnections()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Runtime statistics retrieved successfully",
+		Data:    result,
+	})
+}
+
+// Pprof serves the profiles of net/http/pprof under /debug/pprof/, where
+// go tool pprof expects them. The index lists the named profiles, such as
+// heap, goroutine and allocs.
+func (h *RuntimeHandler) Pprof(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeRequiresSystemAdmin(t *testing.T) {
+	api := newTestAPI(t)
+
+	// An operator holding every other admin permission
+	var permissions []string
+	for _, permission := range models.Permissions {
+		if permission != models.PermSystemAdmin {
+			permissions = append(permissions, permission)
+		}
+	}
+	require.NoError(t, api.storage.SaveRole(context.Background(), &models.Role{Name: "operator", Permissions: permissions}))
+	_, operator := api.user("operator", "operator")
+	_, user := api.user("alice", models.RoleUser)
+	_, admin := api.user("root", models.RoleAdmin)
+
+	for _, path := range []string{"/api/v1/admin/runtime", "/debug/pprof/", "/debug/pprof/cmdline"} {
+		t.Run(path, func(t *testing.T) {
+			w := api.do(http.MethodGet, path, "", nil)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			for _, token := range []string{user, operator} {
+				w = api.do(http.MethodGet, path, token, nil)
+				assert.Equal(t, http.StatusForbidden, w.Code)
+				assert.Equal(t, string(apierr.PermissionDenied), errorCode(t, w))
+			}
+
+			w = api.do(http.MethodGet, path, admin, nil)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+
+	// Profiles also take the token as the password of basic authentication
+	for token, status := range map[string]int{operator: http.StatusForbidden, admin: http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil)
+		req.SetBasicAuth("pprof", token)
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,764 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/ipfilter"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/ratelimit"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/sigv4"
+	"github.com/redis/go-redis/v9"
+)
+
+// s3Bucket is the only bucket of the S3 gateway. It holds the files of
+// whoever the credentials were issued to, with folders as key prefixes.
+const s3Bucket = "files"
+
+// s3CredentialsTTL is how long S3 credentials last unless asked otherwise,
+// and s3CredentialsMaxTTL the longest S3CredentialsRequest allows
+const (
+	s3CredentialsTTL    = 7 * 24 * time.Hour
+	s3CredentialsMaxTTL = 2160 * time.Hour
+)
+
+// newDenylist keeps revocations of a user's tokens for as long as their
+// access tokens or S3 credentials can last
+func newDenylist(redisClient *redis.Client, tokenTTL time.Duration) *auth.Denylist {
+	return auth.NewDenylist(redisClient, max(tokenTTL, s3CredentialsMaxTTL))
+}
+
+// s3UserTTL is how long the gateway reuses the name and role of a user.
+// Clients sign every request, and loading the account each time would make
+// listing a bucket slow.
+const s3UserTTL = time.Minute
+
+// s3MaxKeys caps the objects and prefixes of one listing page
+const s3MaxKeys = 1000
+
+// s3EmptyETag is the ETag of empty content, answered for folder markers
+const s3EmptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// s3Unsupported are the subresources of buckets and objects the gateway
+// does not implement, such as multipart uploads, ACLs and versioning
+var s3Unsupported = []string{"acl", "cors", "delete", "encryption", "lifecycle", "logging", "notification", "object-lock", "policy", "replication", "tagging", "uploadId", "uploads", "versioning", "versions", "website"}
+
+// S3Handler serves the files of the signed-in user to S3 clients, with
+// credentials made by CreateCredentials. Only what tools need to copy files
+// in and out is implemented: listing, downloads, single-part uploads and
+// deletes. Uploads go through the same checks and limits as the REST API.
+type S3Handler struct {
+	files     *FileHandler
+	signer    *auth.S3KeySigner
+	messaging *messaging.Client
+}
+
+func NewS3Handler(fileHandler *FileHandler, signer *auth.S3KeySigner, messagingClient *messaging.Client) *S3Handler {
+	return &S3Handler{files: fileHandler, signer: signer, messaging: messagingClient}
+}
+
+// CreateCredentials godoc
+// @Summary Create S3 credentials
+// @Description Get an access key and secret key for the S3 gateway, whose single bucket holds the caller's files. Credentials are not stored and cannot be revoked one by one: they stop working when they expire, when the user's tokens are revoked, when the account is suspended or the service account disabled, when every key the service account had is rotated out or revoked, or when SIGNING_SECRET changes. The secret key cannot be shown again.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.S3CredentialsRequest false "Hours the credentials last, a week when zero"
+// @Success 201 {object} models.SuccessResponse{data=models.S3Credentials} "Credentials created"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Not allowed while impersonating"
+// @Router /s3/credentials [post]
+func (h *S3Handler) CreateCredentials(c *gin.Context) {
+	if forbidImpersonation(c) {
+		return
+	}
+
+	var req models.S3CredentialsRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+	}
+
+	ttl := s3CredentialsTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Hour
+	}
+	subject := auth.S3Subject{
+		ID:             c.GetString("userID"),
+		ServiceAccount: c.GetString("serviceAccountID") != "",
+		IssuedAt:       time.Now(),
+		ExpiresAt:      time.Now().Add(ttl).Truncate(time.Second),
+	}
+	accessKey, secretKey := h.signer.Issue(subject)
+
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditS3Credentials,
+		UserID:  subject.ID,
+		Details: map[string]string{"accessKeyId": accessKey, "expiresAt": subject.ExpiresAt.UTC().Format(time.RFC3339)},
+	})
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "S3 credentials created successfully",
+		Data: models.S3Credentials{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretKey,
+			Bucket:          s3Bucket,
+			ExpiresAt:       subject.ExpiresAt,
+		},
+	})
+}
+
+// NewS3Gateway returns the S3 gateway, served on a port of its own as S3
+// clients expect buckets at the root of the endpoint. Requests go through
+// the same address filters, limits and account checks as the REST API;
+// those checks answer in JSON, everything else in S3's XML.
+func NewS3Gateway(live *config.Live, storageService *services.StorageService, messagingClient *messaging.Client, jobQueue *jobs.Queue, redisClient *redis.Client, logger *slog.Logger) (http.Handler, error) {
+	cfg := live.Get()
+
+	ipFilter, err := ipfilter.New(cfg.Network.AllowedIPs, cfg.Network.DeniedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP allow or deny list: %w", err)
+	}
+	errorSink, err := newErrorSink(cfg.Reporting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error reporting settings: %w", err)
+	}
+	var limiter *ratelimit.Limiter
+	var denylist *auth.Denylist
+	if redisClient != nil {
+		limiter = ratelimit.NewLimiter(redisClient)
+		denylist = newDenylist(redisClient, time.Duration(cfg.JWT.AccessTokenTTL)*time.Minute)
+	}
+
+	signer := auth.NewS3KeySigner(cfg.Auth.SigningSecret)
+	handler := NewS3Handler(NewFileHandler(storageService, messagingClient, jobQueue, live), signer, messagingClient)
+	transferTimeout := time.Duration(cfg.Request.TransferTimeout) * time.Second
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), TracingMiddleware(), AccessLogMiddleware(logger, cfg.Log), RecoveryMiddleware(errorSink), IPFilterMiddleware(ipFilter))
+	router.Use(BodyLimitMiddleware(cfg.Request.MaxBodySize, map[string]int64{"/*path": cfg.Request.MaxUploadBodySize}))
+	router.Use(TimeoutMiddleware(transferTimeout, nil))
+	router.Use(MaintenanceMiddleware(storageService, cfg.Maintenance, nil))
+	router.Use(S3AuthMiddleware(storageService, signer, denylist), RateLimitMiddleware(limiter, "api", rateLimit(live, "api")), ActiveUserMiddleware(storageService), PermissionMiddleware(storageService))
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete} {
+		router.Handle(method, "/*path", handler.Serve)
+	}
+	return router, nil
+}
+
+// s3User is what the gateway keeps of a user between requests
+type s3User struct {
+	username string
+	email    string
+	role     string
+	expires  time.Time
+}
+
+// S3AuthMiddleware checks the signature of S3 requests against credentials
+// made by CreateCredentials and signs the request in as whoever they were
+// issued to, like AuthMiddleware does for tokens. Credentials of a user
+// are rejected once the user's tokens are revoked after they were issued,
+// and those of a service account once no key it had then is active. The
+// body is replaced by one that fails when the payload does not match what
+// was signed.
+func S3AuthMiddleware(storageService *services.StorageService, signer *auth.S3KeySigner, denylist *auth.Denylist) gin.HandlerFunc {
+	var mu sync.Mutex
+	users := map[string]s3User{}
+
+	return func(c *gin.Context) {
+		sig, err := sigv4.Parse(c.Request)
+		if err != nil {
+			s3SignatureError(c, err, false)
+			c.Abort()
+			return
+		}
+		subject, secretKey, err := signer.Secret(sig.AccessKey)
+		if err != nil {
+			s3Error(c, http.StatusForbidden, "InvalidAccessKeyId", "The access key does not exist or has expired")
+			c.Abort()
+			return
+		}
+		if err := sig.Verify(c.Request, secretKey, time.Now()); err != nil {
+			s3SignatureError(c, err, sig.Presigned)
+			c.Abort()
+			return
+		}
+		body, err := sig.Body(c.Request, secretKey)
+		if err != nil {
+			s3SignatureError(c, err, false)
+			c.Abort()
+			return
+		}
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, c.Request.Body}
+
+		if subject.ServiceAccount {
+			account, err := storageService.CachedServiceAccount(c.Request.Context(), subject.ID)
+			if errors.Is(err, services.ErrServiceAccountNotFound) || err == nil && (account.Disabled || !heldKeySince(account, subject.IssuedAt)) {
+				s3Error(c, http.StatusForbidden, "InvalidAccessKeyId", "The service account no longer exists, is disabled or had its keys rotated")
+				c.Abort()
+				return
+			}
+			if err != nil {
+				requestLogger(c).Error("Failed to load service account", "error", err)
+				s3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", "Unable to verify the access key")
+				c.Abort()
+				return
+			}
+			c.Set("userID", account.ID)
+			c.Set("username", account.Name)
+			c.Set("serviceAccountID", account.ID)
+			c.Set("permissions", account.Role())
+			c.Next()
+			return
+		}
+
+		if denylist != nil {
+			revoked, err := denylist.IsUserRevoked(c.Request.Context(), subject.ID, subject.IssuedAt)
+			if err != nil {
+				requestLogger(c).Error("Failed to check credential revocation", "error", err)
+				s3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", "Unable to verify the access key")
+				c.Abort()
+				return
+			}
+			if revoked {
+				s3Error(c, http.StatusForbidden, "InvalidAccessKeyId", "The access key has been revoked")
+				c.Abort()
+				return
+			}
+		}
+
+		mu.Lock()
+		user, found := users[subject.ID]
+		mu.Unlock()
+		if !found || time.Now().After(user.expires) {
+			account, err := storageService.GetUser(c.Request.Context(), subject.ID)
+			if errors.Is(err, services.ErrUserNotFound) {
+				s3Error(c, http.StatusForbidden, "InvalidAccessKeyId", "The account no longer exists")
+				c.Abort()
+				return
+			}
+			if err != nil {
+				requestLogger(c).Error("Failed to load user", "error", err)
+				s3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", "Unable to verify the access key")
+				c.Abort()
+				return
+			}
+
+			now := time.Now()
+			user = s3User{username: account.Username, email: account.Email, role: account.Role, expires: now.Add(s3UserTTL)}
+			mu.Lock()
+			for id, u := range users {
+				if now.After(u.expires) {
+					delete(users, id)
+				}
+			}
+			users[subject.ID] = user
+			mu.Unlock()
+		}
+		c.Set("userID", subject.ID)
+		c.Set("username", user.username)
+		c.Set("email", user.email)
+		c.Set("role", user.role)
+		c.Next()
+	}
+}
+
+// heldKeySince reports whether a key account had at issuedAt is still
+// active, so that rotating or revoking a leaked key also ends the S3
+// credentials issued with it
+func heldKeySince(account *models.ServiceAccount, issuedAt time.Time) bool {
+	for _, key := range account.ActiveKeys(time.Now()) {
+		// Credentials carry their issue time to the millisecond
+		if key.CreatedAt.UnixMilli() <= issuedAt.UnixMilli() {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve handles an S3 request. The path is /<bucket>/<key>; virtual-hosted
+// buckets are not supported.
+func (h *S3Handler) Serve(c *gin.Context) {
+	method := c.Request.Method
+	permission := models.PermFilesRead
+	if method != http.MethodGet && method != http.MethodHead {
+		permission = models.PermFilesWrite
+	}
+	if !hasPermission(c, permission) {
+		s3Error(c, http.StatusForbidden, "AccessDenied", "Missing permission "+permission)
+		return
+	}
+	query := c.Request.URL.Query()
+	for _, name := range s3Unsupported {
+		if query.Has(name) {
+			s3Error(c, http.StatusNotImplemented, "NotImplemented", "The "+name+" subresource is not supported")
+			return
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	switch {
+	case bucket == "" && method == http.MethodGet:
+		h.listBuckets(c)
+	case bucket == "":
+		s3Error(c, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method is not allowed on the service")
+	case bucket != s3Bucket:
+		s3Error(c, http.StatusNotFound, "NoSuchBucket", "The only bucket is "+s3Bucket)
+	case key == "" && method == http.MethodGet && query.Has("location"):
+		c.XML(http.StatusOK, s3LocationConstraint{})
+	case key == "" && method == http.MethodGet:
+		h.listObjects(c)
+	case key == "" && method == http.MethodHead:
+		c.Status(http.StatusOK)
+	case key == "":
+		s3Error(c, http.StatusMethodNotAllowed, "MethodNotAllowed", "Buckets cannot be created or deleted")
+	case method == http.MethodGet || method == http.MethodHead:
+		h.getObject(c, key)
+	case method == http.MethodPut:
+		h.putObject(c, key)
+	case method == http.MethodDelete:
+		h.deleteObject(c, key)
+	default:
+		s3Error(c, http.StatusNotImplemented, "NotImplemented", "The request is not supported")
+	}
+}
+
+func (h *S3Handler) listBuckets(c *gin.Context) {
+	result := s3ListBucketsResult{Owner: s3Owner{ID: c.GetString("userID"), DisplayName: c.GetString("username")}}
+	result.Buckets = []s3BucketEntry{{Name: s3Bucket, CreationDate: s3Time(time.Unix(0, 0))}}
+	c.XML(http.StatusOK, result)
+}
+
+// listObjects answers ListObjects and ListObjectsV2. Continuation tokens
+// are the last key of the previous page.
+func (h *S3Handler) listObjects(c *gin.Context) {
+	query := c.Request.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	result := s3ListBucketResult{
+		Name:      s3Bucket,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		MaxKeys:   s3MaxKeys,
+	}
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, err := strconv.Atoi(value)
+		if err != nil || maxKeys < 0 {
+			s3Error(c, http.StatusBadRequest, "InvalidArgument", "max-keys must be a number of keys")
+			return
+		}
+		result.MaxKeys = min(maxKeys, s3MaxKeys)
+	}
+	encoding := query.Get("encoding-type")
+	if encoding != "" && encoding != "url" {
+		s3Error(c, http.StatusBadRequest, "InvalidArgument", "encoding-type must be url")
+		return
+	}
+
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		result.StartAfter = after
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				s3Error(c, http.StatusBadRequest, "InvalidArgument", "The continuation token is invalid")
+				return
+			}
+			after = string(decoded)
+			result.ContinuationToken = token
+		}
+	} else {
+		result.Marker = after
+	}
+
+	files, err := h.files.storageService.ListFilesByUsers(c.Request.Context(), []string{c.GetString("userID")}, personalItems)
+	if err != nil {
+		s3APIError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list files"))
+		return
+	}
+
+	last, count := result.page(files[c.GetString("userID")], after)
+	if result.IsTruncated {
+		if v2 {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		} else {
+			result.NextMarker = last
+		}
+	}
+	if v2 {
+		keyCount := count
+		result.KeyCount = &keyCount
+	}
+	if encoding == "url" {
+		result.encodeKeys()
+	}
+	c.XML(http.StatusOK, result)
+}
+
+func (h *S3Handler) getObject(c *gin.Context, key string) {
+	file := h.findObject(c, key)
+	if file == nil {
+		s3Error(c, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+		return
+	}
+	if err := downloadableError(file); err != nil {
+		s3APIError(c, err)
+		return
+	}
+
+	content, err := h.files.storageService.GetObjectContent(c.Request.Context(), file.Path)
+	if err != nil {
+		s3APIError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get file content"))
+		return
+	}
+	defer content.Close()
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok {
+		s3APIError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "File content cannot seek"))
+		return
+	}
+
+	c.Header("ETag", `"`+file.ETag+`"`)
+	c.Header("Content-Type", file.ContentType)
+	encryptionHeaders(c, file.Encryption)
+	http.ServeContent(c.Writer, c.Request, file.OriginalName, file.UpdatedAt, seeker)
+	if c.Request.Method == http.MethodGet && c.Writer.Status() < http.StatusMultipleChoices {
+		recordDownload(h.messaging, c, file, models.AccessDownload, "", int64(c.Writer.Size()))
+	}
+}
+
+// putObject stores the body as a file, or as a new version of the file
+// with that key. Keys ending in a slash are folder markers, which are
+// accepted but not stored: folders exist while they hold files.
+func (h *S3Handler) putObject(c *gin.Context, key string) {
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		s3Error(c, http.StatusNotImplemented, "NotImplemented", "Copying objects is not supported")
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		c.Header("ETag", s3EmptyETag)
+		c.Status(http.StatusOK)
+		return
+	}
+	folder, name := path.Split(key)
+	if name == "." || name == ".." {
+		s3Error(c, http.StatusBadRequest, "InvalidArgument", "The key is not a valid file name")
+		return
+	}
+
+	maxSize := h.files.live.Get().Upload.MaxSizeFor(c.GetString("role"), "")
+	size := sigv4.DecodedLength(c.Request)
+	if size < 0 {
+		s3Error(c, http.StatusLengthRequired, "MissingContentLength", "The content length is required")
+		return
+	}
+	if size > maxSize {
+		s3APIError(c, tooLargeError(maxSize))
+		return
+	}
+
+	spool, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		s3APIError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to buffer upload"))
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	digest := md5.New()
+	written, err := io.Copy(io.MultiWriter(spool, digest), io.LimitReader(c.Request.Body, size+1))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.Header("Connection", "close")
+		s3APIError(c, tooLargeError(tooLarge.Limit))
+		return
+	}
+	if err != nil {
+		if errors.Is(err, sigv4.ErrPayloadMismatch) || errors.Is(err, sigv4.ErrSignatureMismatch) || errors.Is(err, sigv4.ErrMalformed) {
+			s3SignatureError(c, err, false)
+			return
+		}
+		s3Error(c, http.StatusBadRequest, "IncompleteBody", "Failed to read the request body")
+		return
+	}
+	if written != size {
+		s3Error(c, http.StatusBadRequest, "IncompleteBody", "The body does not match the content length")
+		return
+	}
+	if want := c.GetHeader("Content-MD5"); want != "" && want != base64.StdEncoding.EncodeToString(digest.Sum(nil)) {
+		s3Error(c, http.StatusBadRequest, "BadDigest", "The Content-MD5 does not match the body")
+		return
+	}
+
+	part := uploadPart{
+		source: tempFileSource(spool.Name(), written, c.GetHeader("Content-Type")),
+		name:   name,
+		folder: normalizeFolder(folder),
+	}
+	file, _, uploadErr := h.files.storeUpload(c.Request.Context(), c.GetString("userID"), c.GetString("role"), part, &uploadOptions{stripMetadata: h.files.stripExif()})
+	if uploadErr != nil {
+		s3APIError(c, uploadErr)
+		return
+	}
+	h.files.enqueueJobs(c.Request.Context(), file)
+
+	c.Header("ETag", `"`+file.ETag+`"`)
+	c.Status(http.StatusOK)
+}
+
+// deleteObject deletes the file with key. Like S3, deleting a key that
+// does not exist succeeds.
+func (h *S3Handler) deleteObject(c *gin.Context, key string) {
+	if file := h.findObject(c, key); file != nil {
+		if err := h.files.storageService.DeleteFile(c.Request.Context(), file.ID); err != nil {
+			s3APIError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete file"))
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// findObject returns the signed-in user's file with key, nil if there is
+// none
+func (h *S3Handler) findObject(c *gin.Context, key string) *models.File {
+	folder, name := path.Split(key)
+	if name == "" {
+		return nil
+	}
+	file, err := h.files.storageService.FindFileByName(c.Request.Context(), c.GetString("userID"), "", normalizeFolder(folder), name)
+	if err != nil {
+		return nil
+	}
+	return file
+}
+
+// s3Key is the object key of file: its folder without the leading slash,
+// then its name
+func s3Key(file *models.File) string {
+	return strings.TrimPrefix(file.Folder+"/", "/") + file.OriginalName
+}
+
+// s3Files returns files sorted by key, keeping the newest of files that
+// share one
+func s3Files(files []*models.File) []*models.File {
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b *models.File) int {
+		if order := strings.Compare(s3Key(a), s3Key(b)); order != 0 {
+			return order
+		}
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return slices.CompactFunc(files, func(a, b *models.File) bool {
+		return s3Key(a) == s3Key(b)
+	})
+}
+
+func s3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// s3SignatureError answers a request whose signature or payload failed the
+// checks of sigv4
+func s3SignatureError(c *gin.Context, err error, presigned bool) {
+	switch {
+	case errors.Is(err, sigv4.ErrMissing):
+		s3Error(c, http.StatusForbidden, "AccessDenied", "Anonymous access is not allowed")
+	case errors.Is(err, sigv4.ErrExpired) && presigned:
+		s3Error(c, http.StatusForbidden, "AccessDenied", "Request has expired")
+	case errors.Is(err, sigv4.ErrExpired):
+		s3Error(c, http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	case errors.Is(err, sigv4.ErrSignatureMismatch):
+		s3Error(c, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature does not match the signature calculated with the secret key")
+	case errors.Is(err, sigv4.ErrPayloadMismatch):
+		s3Error(c, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The content SHA-256 does not match the body")
+	case errors.Is(err, sigv4.ErrUnsupported):
+		s3Error(c, http.StatusBadRequest, "InvalidRequest", "Only AWS Signature Version 4 is supported")
+	default:
+		s3Error(c, http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header or query is malformed")
+	}
+}
+
+// s3APIError answers err, an error of the REST API's checks, with the
+// closest S3 error code
+func s3APIError(c *gin.Context, err *apierr.Error) {
+	if err.Status >= http.StatusInternalServerError && err.Err != nil {
+		requestLogger(c).Error(err.Message, "code", err.Code, "error", err.Err)
+	}
+	code := string(err.Code)
+	switch err.Status {
+	case http.StatusForbidden:
+		code = "AccessDenied"
+	case http.StatusNotFound:
+		code = "NoSuchKey"
+	case http.StatusRequestEntityTooLarge:
+		code = "EntityTooLarge"
+	case http.StatusInternalServerError:
+		code = "InternalError"
+	case http.StatusServiceUnavailable:
+		code = "ServiceUnavailable"
+	}
+	s3Error(c, err.Status, code, err.Message)
+}
+
+// s3Error writes an S3 error document; HEAD responses have no body
+func s3Error(c *gin.Context, status int, code, message string) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+	c.XML(status, s3ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Resource:  c.Request.URL.Path,
+		RequestID: c.GetString("requestID"),
+	})
+}
+
+type s3ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3BucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListBucketsResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   s3Owner         `xml:"Owner"`
+	Buckets []s3BucketEntry `xml:"Buckets>Bucket"`
+}
+
+type s3LocationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// s3ListBucketResult is the result of both versions of ListObjects; the
+// fields of the other version are left out
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	Marker                string           `xml:"Marker,omitempty"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int             `xml:"KeyCount,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// page fills r with the keys of files after the key after, up to MaxKeys
+// of them, with keys under the same prefix up to the delimiter rolled up
+// into one common prefix. It returns the last key or prefix listed and how
+// many were.
+func (r *s3ListBucketResult) page(files []*models.File, after string) (string, int) {
+	var last, lastPrefix string
+	count := 0
+	for _, file := range s3Files(files) {
+		key := s3Key(file)
+		if !strings.HasPrefix(key, r.Prefix) || key <= after {
+			continue
+		}
+		if r.Delimiter != "" {
+			if i := strings.Index(key[len(r.Prefix):], r.Delimiter); i >= 0 {
+				prefix := key[:len(r.Prefix)+i+len(r.Delimiter)]
+				// The prefix was on an earlier page or is already listed
+				if strings.HasPrefix(after, prefix) || prefix == lastPrefix {
+					continue
+				}
+				if count == r.MaxKeys {
+					r.IsTruncated = true
+					break
+				}
+				r.CommonPrefixes = append(r.CommonPrefixes, s3CommonPrefix{Prefix: prefix})
+				last, lastPrefix = prefix, prefix
+				count++
+				continue
+			}
+		}
+		if count == r.MaxKeys {
+			r.IsTruncated = true
+			break
+		}
+		r.Contents = append(r.Contents, s3Object{
+			Key:          key,
+			LastModified: s3Time(file.UpdatedAt),
+			ETag:         `"` + file.ETag + `"`,
+			Size:         file.Size,
+			StorageClass: "STANDARD",
+		})
+		last = key
+		count++
+	}
+	return last, count
+}
+
+// encodeKeys URL-encodes the keys and prefixes, for clients that asked
+// for encoding-type=url
+func (r *s3ListBucketResult) encodeKeys() {
+	r.EncodingType = "url"
+	for _, field := range []*string{&r.Prefix, &r.Delimiter, &r.Marker, &r.NextMarker, &r.StartAfter} {
+		*field = url.QueryEscape(*field)
+	}
+	for i := range r.Contents {
+		r.Contents[i].Key = url.QueryEscape(r.Contents[i].Key)
+	}
+	for i := range r.CommonPrefixes {
+		r.CommonPrefixes[i].Prefix = url.QueryEscape(r.CommonPrefixes[i].Prefix)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/sigv4"
+	s3signer "github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3ListPage(t *testing.T) {
+	now := time.Now()
+	files := []*models.File{
+		{OriginalName: "b.txt", UpdatedAt: now},
+		{OriginalName: "a.txt", UpdatedAt: now},
+		{OriginalName: "q1.pdf", Folder: "/reports/2024", UpdatedAt: now},
+		{OriginalName: "q2.pdf", Folder: "/reports/2024", UpdatedAt: now},
+		{OriginalName: "summary.pdf", Folder: "/reports", UpdatedAt: now, ETag: "newer"},
+		{OriginalName: "summary.pdf", Folder: "/reports", UpdatedAt: now.Add(-time.Hour), ETag: "older"},
+	}
+	keys := func(r s3ListBucketResult) (keys []string) {
+		for _, object := range r.Contents {
+			keys = append(keys, object.Key)
+		}
+		for _, prefix := range r.CommonPrefixes {
+			keys = append(keys, prefix.Prefix)
+		}
+		return keys
+	}
+
+	// Keys are sorted, with only the newest of files sharing one
+	all := s3ListBucketResult{MaxKeys: s3MaxKeys}
+	last, count := all.page(files, "")
+	assert.Equal(t, []string{"a.txt", "b.txt", "reports/2024/q1.pdf", "reports/2024/q2.pdf", "reports/summary.pdf"}, keys(all))
+	assert.Equal(t, "reports/summary.pdf", last)
+	assert.Equal(t, 5, count)
+	assert.Equal(t, `"newer"`, all.Contents[4].ETag)
+	assert.False(t, all.IsTruncated)
+
+	// Folders are rolled up into prefixes, and pages resume after them
+	first := s3ListBucketResult{Prefix: "reports/", Delimiter: "/", MaxKeys: 1}
+	last, _ = first.page(files, "")
+	assert.Equal(t, []string{"reports/2024/"}, keys(first))
+	assert.True(t, first.IsTruncated)
+	second := s3ListBucketResult{Prefix: "reports/", Delimiter: "/", MaxKeys: 1}
+	second.page(files, last)
+	assert.Equal(t, []string{"reports/summary.pdf"}, keys(second))
+	assert.False(t, second.IsTruncated)
+
+	second.encodeKeys()
+	body, err := xml.Marshal(second)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<Key>reports%2Fsummary.pdf</Key>")
+	assert.Contains(t, string(body), "<EncodingType>url</EncodingType>")
+}
+
+func TestS3CredentialsRevoked(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	redisClient := redis.NewClient(&redis.Options{Addr: api.redis.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	gateway, err := NewS3Gateway(config.NewLive(api.cfg, ""), api.storage, nil, nil, redisClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	credentials := func(token string) models.S3Credentials {
+		t.Helper()
+		w := api.do(http.MethodPost, "/api/v1/s3/credentials", token, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var creds models.S3Credentials
+		decode(t, w, &creds)
+		return creds
+	}
+	list := func(creds models.S3Credentials) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Amz-Content-Sha256", sigv4.UnsignedPayload)
+		req = s3signer.SignV4(*req, creds.AccessKeyID, creds.SecretAccessKey, "", "us-east-1")
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Revoking a user's tokens ends the credentials issued before
+	user, token := api.user("alice", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+	creds := credentials(token)
+	assert.Equal(t, http.StatusOK, list(creds))
+	w := api.do(http.MethodPost, "/api/v1/admin/tokens/revoke", adminToken, models.RevokeTokensRequest{UserID: user.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, list(creds))
+	// Credentials issued afterwards work, even within the same second
+	assert.Equal(t, http.StatusOK, list(credentials(api.token(user))))
+
+	// So does rotating out every key a service account had
+	account := &models.ServiceAccount{Name: "backup", Permissions: []string{models.PermFilesRead}}
+	key, err := api.storage.CreateServiceAccount(ctx, account)
+	require.NoError(t, err)
+	creds = credentials(key)
+	assert.Equal(t, http.StatusOK, list(creds))
+	// Keys are compared with the issue time to the millisecond
+	time.Sleep(2 * time.Millisecond)
+	_, _, err = api.storage.RotateServiceAccountKey(ctx, account, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, list(creds), "the old key is still in its grace period")
+	_, _, err = api.storage.RotateServiceAccountKey(ctx, account, 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, list(creds))
+}
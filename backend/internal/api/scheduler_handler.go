@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/scheduler"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+type SchedulerHandler struct {
+	scheduler      *scheduler.Scheduler
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewSchedulerHandler(taskScheduler *scheduler.Scheduler, storageService *services.StorageService, messagingClient *messaging.Client) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler:      taskScheduler,
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// GetSchedule godoc
+// @Summary Get scheduled tasks
+// @Description Get the recurring tasks with their cron schedules, next and last runs, and the server currently running them
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.SchedulerStatus} "Scheduled tasks retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Scheduled tasks are not available"
+// @Router /admin/schedule [get]
+func (h *SchedulerHandler) GetSchedule(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	status, err := h.scheduler.Status(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get scheduled tasks"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Scheduled tasks retrieved successfully",
+		Data:    status,
+	})
+}
+
+// RunTask godoc
+// @Summary Run a scheduled task now
+// @Description Start a scheduled task on this server right away, outside its schedule. The run goes on in the background; its outcome shows in the scheduled tasks.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param task path string true "Task name, e.g. expired-files, expired-shares, orphan-scan, usage or digest"
+// @Success 202 {object} models.SuccessResponse "Task started"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Task not found"
+// @Failure 409 {object} models.ErrorResponse "Task is already running"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Scheduled tasks are not available"
+// @Router /admin/schedule/{task}/run [post]
+func (h *SchedulerHandler) RunTask(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	name := c.Param("task")
+	if err := h.scheduler.RunNow(c.Request.Context(), name); err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownTask):
+			respondError(c, apierr.New(http.StatusNotFound, apierr.TaskNotFound, "Task not found"))
+		case errors.Is(err, scheduler.ErrTaskRunning):
+			respondError(c, apierr.New(http.StatusConflict, apierr.TaskRunning, "Task is already running"))
+		default:
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to start task"))
+		}
+		return
+	}
+
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminTaskRun,
+		TargetType: models.AdminTargetSystem,
+		TargetID:   "tasks/" + name,
+	}, nil, nil)
+	requestLogger(c).Info("Scheduled task started by hand", "task", name, "actorId", c.GetString("userID"))
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Message: "Task started",
+	})
+}
+
+// GetUsage godoc
+// @Summary Get storage usage
+// @Description Get the files, bytes and posts of every user, largest first, as added up by the last run of the usage task
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.UsageReport} "Usage report retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "No usage report yet"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/usage [get]
+func (h *SchedulerHandler) GetUsage(c *gin.Context) {
+	report, err := h.storageService.GetUsageReport(c.Request.Context())
+	if err != nil {
+		respondError(c, storageError(err, "Failed to get usage report"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Usage report retrieved successfully",
+		Data:    report,
+	})
+}
+
+func (h *SchedulerHandler) available(c *gin.Context) bool {
+	if h.scheduler == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Scheduled tasks are not available"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+var schemaNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,63}$`)
+
+// SchemaHandler lets file admins define what the custom metadata of files
+// in a folder must look like
+type SchemaHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewSchemaHandler(storageService *services.StorageService, messagingClient *messaging.Client) *SchemaHandler {
+	return &SchemaHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// GetFolderSchema godoc
+// @Summary Get the metadata schema of a folder
+// @Description Get the schema custom metadata of files uploaded to a folder must match: the schema of the folder or the nearest folder above it
+// @Tags files
+// @Produce json
+// @Security BearerAuth
+// @Param folder query string false "Virtual folder; the root if omitted"
+// @Success 200 {object} models.SuccessResponse{data=models.MetadataSchema} "Metadata schema retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 404 {object} models.ErrorResponse "No schema applies to the folder"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/metadata-schema [get]
+func (h *SchemaHandler) GetFolderSchema(c *gin.Context) {
+	schema, err := h.storageService.MetadataSchemaFor(c.Request.Context(), normalizeFolder(c.Query("folder")))
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get metadata schema"))
+		return
+	}
+	if schema == nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.MetadataSchemaNotFound, "No metadata schema applies to this folder"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Metadata schema retrieved successfully",
+		Data:    schema,
+	})
+}
+
+// ListSchemas godoc
+// @Summary List metadata schemas
+// @Description List the metadata schemas ordered by folder
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.MetadataSchema} "Metadata schemas retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/metadata-schemas [get]
+func (h *SchemaHandler) ListSchemas(c *gin.Context) {
+	schemas, err := h.storageService.ListMetadataSchemas(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list metadata schemas"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Metadata schemas retrieved successfully",
+		Data:    schemas,
+	})
+}
+
+// GetSchema godoc
+// @Summary Get metadata schema
+// @Description Get a metadata schema with its fields
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Schema name"
+// @Success 200 {object} models.SuccessResponse{data=models.MetadataSchema} "Metadata schema retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Metadata schema not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/metadata-schemas/{name} [get]
+func (h *SchemaHandler) GetSchema(c *gin.Context) {
+	schema, ok := h.schema(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Metadata schema retrieved successfully",
+		Data:    schema,
+	})
+}
+
+// CreateSchema godoc
+// @Summary Create metadata schema
+// @Description Define the custom metadata of files uploaded to a folder and the folders below it: the keys, whether they are required, their type (string, integer, number, boolean or date) and allowed values. In reject mode uploads that do not match fail; in coerce mode values are normalized where possible, such as "Yes" into true, and unknown keys are dropped. Names are 2-64 lowercase letters, digits, dashes or underscores; each folder has at most one schema.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateMetadataSchemaRequest true "Schema definition"
+// @Success 201 {object} models.SuccessResponse{data=models.MetadataSchema} "Metadata schema created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 409 {object} models.ErrorResponse "Name taken or folder already has a schema"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/metadata-schemas [post]
+func (h *SchemaHandler) CreateSchema(c *gin.Context) {
+	var req models.CreateMetadataSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if !schemaNamePattern.MatchString(req.Name) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Schema name must be 2-64 lowercase letters, digits, dashes or underscores"))
+		return
+	}
+
+	_, err := h.storageService.GetMetadataSchema(c.Request.Context(), req.Name)
+	if err == nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.MetadataSchemaExists, "Metadata schema already exists"))
+		return
+	}
+	if !errors.Is(err, services.ErrMetadataSchemaNotFound) {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create metadata schema"))
+		return
+	}
+
+	schema := &models.MetadataSchema{
+		Name:         req.Name,
+		Description:  req.Description,
+		Folder:       normalizeFolder(req.Folder),
+		Fields:       req.Fields,
+		AllowUnknown: req.AllowUnknown,
+		Mode:         req.Mode,
+		CreatedBy:    c.GetString("userID"),
+	}
+	if !h.save(c, schema, "Failed to create metadata schema") {
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminMetadataSchemaCreate,
+		TargetType: models.AdminTargetMetadataSchema,
+		TargetID:   schema.Name,
+	}, nil, schema)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Metadata schema created successfully",
+		Data:    schema,
+	})
+}
+
+// UpdateSchema godoc
+// @Summary Update metadata schema
+// @Description Replace the definition of a metadata schema. Files stored before keep their metadata; it is checked again when changed. Changes reach all servers within 30 seconds.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Schema name"
+// @Param request body models.UpdateMetadataSchemaRequest true "Schema definition"
+// @Success 200 {object} models.SuccessResponse{data=models.MetadataSchema} "Metadata schema updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Metadata schema not found"
+// @Failure 409 {object} models.ErrorResponse "Folder already has a schema"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/metadata-schemas/{name} [put]
+func (h *SchemaHandler) UpdateSchema(c *gin.Context) {
+	var req models.UpdateMetadataSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	schema, ok := h.schema(c)
+	if !ok {
+		return
+	}
+	before := *schema
+
+	schema.Description = req.Description
+	schema.Folder = normalizeFolder(req.Folder)
+	schema.Fields = req.Fields
+	schema.AllowUnknown = req.AllowUnknown
+	schema.Mode = req.Mode
+	if !h.save(c, schema, "Failed to update metadata schema") {
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminMetadataSchemaUpdate,
+		TargetType: models.AdminTargetMetadataSchema,
+		TargetID:   schema.Name,
+	}, &before, schema)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Metadata schema updated successfully",
+		Data:    schema,
+	})
+}
+
+// DeleteSchema godoc
+// @Summary Delete metadata schema
+// @Description Delete a metadata schema. Files stored under it keep their metadata.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Schema name"
+// @Success 200 {object} models.SuccessResponse "Metadata schema deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Metadata schema not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/metadata-schemas/{name} [delete]
+func (h *SchemaHandler) DeleteSchema(c *gin.Context) {
+	schema, ok := h.schema(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.DeleteMetadataSchema(c.Request.Context(), schema.Name); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete metadata schema"))
+		return
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminMetadataSchemaDelete,
+		TargetType: models.AdminTargetMetadataSchema,
+		TargetID:   schema.Name,
+	}, schema, nil)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Metadata schema deleted successfully",
+	})
+}
+
+// schema loads the schema named in the path, writing a 404 response when
+// there is none
+func (h *SchemaHandler) schema(c *gin.Context) (*models.MetadataSchema, bool) {
+	schema, err := h.storageService.GetMetadataSchema(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, services.ErrMetadataSchemaNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.MetadataSchemaNotFound, "Metadata schema not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get metadata schema"))
+		return nil, false
+	}
+	return schema, true
+}
+
+// save checks and stores a schema, writing the error response on failure
+func (h *SchemaHandler) save(c *gin.Context, schema *models.MetadataSchema, failure string) bool {
+	if schema.Mode == "" {
+		schema.Mode = models.MetadataModeReject
+	}
+	if err := schema.Validate(); err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, err.Error()))
+		return false
+	}
+
+	schemas, err := h.storageService.ListMetadataSchemas(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, failure))
+		return false
+	}
+	for _, other := range schemas {
+		if other.Name != schema.Name && other.Folder == schema.Folder {
+			respondError(c, apierr.New(http.StatusConflict, apierr.MetadataSchemaExists, "Metadata schema "+other.Name+" already covers this folder"))
+			return false
+		}
+	}
+
+	if err := h.storageService.SaveMetadataSchema(c.Request.Context(), schema); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, failure))
+		return false
+	}
+	return true
+}
+
+// applyMetadataSchema checks the custom metadata of a file in folder
+// against the folder's schema and returns it as it is to be stored, or a
+// validation error naming each broken field
+func applyMetadataSchema(ctx context.Context, storageService *services.StorageService, folder string, metadata map[string]string) (map[string]string, *apierr.Error) {
+	schema, err := storageService.MetadataSchemaFor(ctx, folder)
+	if err != nil {
+		return nil, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to check metadata")
+	}
+	if schema == nil {
+		return metadata, nil
+	}
+
+	applied, fields := schema.Apply(metadata)
+	if len(fields) > 0 {
+		return nil, validationError(fields)
+	}
+	return applied, nil
+}
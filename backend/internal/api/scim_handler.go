@@ -0,0 +1,359 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/scim"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// scimActor is the actor ID of audit events caused by provisioning
+const scimActor = "scim"
+
+// SCIMHandler lets identity providers provision users over SCIM 2.0.
+// Deprovisioning deactivates a user rather than deleting their data.
+type SCIMHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewSCIMHandler(storageService *services.StorageService, messagingClient *messaging.Client) *SCIMHandler {
+	return &SCIMHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// SCIMAuthMiddleware accepts requests carrying the provisioning token as a
+// bearer token
+func SCIMAuthMiddleware(token string) gin.HandlerFunc {
+	expected := sha256.Sum256([]byte(token))
+	return func(c *gin.Context) {
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		actual := sha256.Sum256([]byte(given))
+		if !ok || subtle.ConstantTimeCompare(actual[:], expected[:]) != 1 {
+			scimErrorResponse(c, scim.NewError(http.StatusUnauthorized, "", "Invalid provisioning token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ServiceProviderConfig godoc
+// @Summary Get SCIM service provider configuration
+// @Description Describe the SCIM features this server supports
+// @Tags scim
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Service provider configuration"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, scim.ServiceProviderConfig())
+}
+
+// ListUsers godoc
+// @Summary List users over SCIM
+// @Description List users, optionally narrowed by a filter of eq, ne, co, sw, ew and pr comparisons joined by "and" on id, externalId, userName, emails.value, name.givenName, name.familyName and active
+// @Tags scim
+// @Produce json
+// @Security BearerAuth
+// @Param filter query string false "Filter, e.g. userName eq \"alice\""
+// @Param startIndex query int false "1-based index of the first result" default(1)
+// @Param count query int false "Page size" default(100)
+// @Success 200 {object} scim.ListResponse "Users"
+// @Failure 400 {object} scim.Error "Invalid filter"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 500 {object} scim.Error "Internal server error"
+// @Router /scim/v2/Users [get]
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	filter, scimErr := scim.ParseFilter(c.Query("filter"))
+	if scimErr != nil {
+		scimErrorResponse(c, scimErr)
+		return
+	}
+
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+	count = min(count, scim.MaxResults)
+
+	users, err := h.storageService.FindUsers(c.Request.Context(), filter.Matches)
+	if err != nil {
+		requestLogger(c).Error("SCIM: failed to list users", "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to list users"))
+		return
+	}
+
+	page := users[min(startIndex-1, len(users)):]
+	page = page[:min(count, len(page))]
+
+	resources := make([]*scim.User, 0, len(page))
+	for _, user := range page {
+		resources = append(resources, scim.FromUser(user, scimLocation(c, user.ID)))
+	}
+
+	scimJSON(c, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: len(users),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetUser godoc
+// @Summary Get a user over SCIM
+// @Tags scim
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} scim.User "User"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 404 {object} scim.Error "User not found"
+// @Router /scim/v2/Users/{id} [get]
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	scimJSON(c, http.StatusOK, scim.FromUser(user, scimLocation(c, user.ID)))
+}
+
+// CreateUser godoc
+// @Summary Provision a user over SCIM
+// @Description Create a user with the default role. Provisioned users have no password; they sign in through social login or set one with a password reset.
+// @Tags scim
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body scim.User true "User"
+// @Success 201 {object} scim.User "User created"
+// @Failure 400 {object} scim.Error "Invalid user"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 409 {object} scim.Error "userName or email address taken"
+// @Failure 500 {object} scim.Error "Internal server error"
+// @Router /scim/v2/Users [post]
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var req scim.User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", "%s", err.Error()))
+		return
+	}
+
+	user := &models.User{Role: models.RoleUser}
+	if scimErr := req.ApplyTo(user); scimErr != nil {
+		scimErrorResponse(c, scimErr)
+		return
+	}
+	if !h.checkUnique(c, user) {
+		return
+	}
+
+	if err := h.storageService.CreateUser(c.Request.Context(), user); err != nil {
+		requestLogger(c).Error("SCIM: failed to create user", "username", user.Username, "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to create user"))
+		return
+	}
+
+	location := scimLocation(c, user.ID)
+	c.Header("Location", location)
+	scimJSON(c, http.StatusCreated, scim.FromUser(user, location))
+}
+
+// ReplaceUser godoc
+// @Summary Replace a user over SCIM
+// @Description Replace the attributes of a user; name and externalId are cleared when missing
+// @Tags scim
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body scim.User true "User"
+// @Success 200 {object} scim.User "User replaced"
+// @Failure 400 {object} scim.Error "Invalid user"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 404 {object} scim.Error "User not found"
+// @Failure 409 {object} scim.Error "userName or email address taken"
+// @Failure 500 {object} scim.Error "Internal server error"
+// @Router /scim/v2/Users/{id} [put]
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var req scim.User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", "%s", err.Error()))
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+	previousStatus := user.AccountStatus()
+
+	if scimErr := req.ApplyTo(user); scimErr != nil {
+		scimErrorResponse(c, scimErr)
+		return
+	}
+	h.saveUser(c, user, previousStatus)
+}
+
+// PatchUser godoc
+// @Summary Modify a user over SCIM
+// @Description Add, replace or remove attributes of a user. Setting active to false deactivates the user and ends their sessions; setting it to true reactivates them.
+// @Tags scim
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body scim.PatchRequest true "Patch operations"
+// @Success 200 {object} scim.User "User modified"
+// @Failure 400 {object} scim.Error "Invalid operation"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 404 {object} scim.Error "User not found"
+// @Failure 409 {object} scim.Error "userName or email address taken"
+// @Failure 500 {object} scim.Error "Internal server error"
+// @Router /scim/v2/Users/{id} [patch]
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var req scim.PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", "%s", err.Error()))
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+	previousStatus := user.AccountStatus()
+
+	if scimErr := scim.ApplyPatch(user, req.Operations); scimErr != nil {
+		scimErrorResponse(c, scimErr)
+		return
+	}
+	h.saveUser(c, user, previousStatus)
+}
+
+// DeactivateUser godoc
+// @Summary Deprovision a user over SCIM
+// @Description Deactivate a user. Their data is kept and an admin or the identity provider can reactivate them.
+// @Tags scim
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "User deactivated"
+// @Failure 401 {object} scim.Error "Invalid provisioning token"
+// @Failure 404 {object} scim.Error "User not found"
+// @Failure 500 {object} scim.Error "Internal server error"
+// @Router /scim/v2/Users/{id} [delete]
+func (h *SCIMHandler) DeactivateUser(c *gin.Context) {
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+	previousStatus := user.AccountStatus()
+
+	user.Status = models.UserStatusDeactivated
+	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+		requestLogger(c).Error("SCIM: failed to deactivate user", "userId", user.ID, "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to deactivate user"))
+		return
+	}
+	h.recordStatusChange(c, user, previousStatus)
+
+	c.Status(http.StatusNoContent)
+}
+
+// loadUser loads the user named by the id parameter, writing an error
+// response when that fails
+func (h *SCIMHandler) loadUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.storageService.GetUser(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrUserNotFound) {
+		scimErrorResponse(c, scim.NewError(http.StatusNotFound, "", "User %s not found", c.Param("id")))
+		return nil, false
+	}
+	if err != nil {
+		requestLogger(c).Error("SCIM: failed to load user", "userId", c.Param("id"), "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to load user"))
+		return nil, false
+	}
+	return user, true
+}
+
+// saveUser stores a modified user and responds with it
+func (h *SCIMHandler) saveUser(c *gin.Context, user *models.User, previousStatus string) {
+	if !h.checkUnique(c, user) {
+		return
+	}
+
+	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+		requestLogger(c).Error("SCIM: failed to update user", "userId", user.ID, "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to update user"))
+		return
+	}
+	h.recordStatusChange(c, user, previousStatus)
+
+	scimJSON(c, http.StatusOK, scim.FromUser(user, scimLocation(c, user.ID)))
+}
+
+// checkUnique writes a 409 response when another user has the username
+// or email address of user
+func (h *SCIMHandler) checkUnique(c *gin.Context, user *models.User) bool {
+	taken, err := h.storageService.FindUsers(c.Request.Context(), func(other *models.User) bool {
+		return other.ID != user.ID && (other.Username == user.Username || strings.EqualFold(other.Email, user.Email))
+	})
+	if err != nil {
+		requestLogger(c).Error("SCIM: failed to check for duplicate users", "error", err)
+		scimErrorResponse(c, scim.NewError(http.StatusInternalServerError, "", "Failed to check for duplicate users"))
+		return false
+	}
+	if len(taken) > 0 {
+		scimErrorResponse(c, scim.NewError(http.StatusConflict, "uniqueness", "userName or email address is already in use"))
+		return false
+	}
+	return true
+}
+
+func (h *SCIMHandler) recordStatusChange(c *gin.Context, user *models.User, previousStatus string) {
+	if user.AccountStatus() == previousStatus {
+		return
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditStatusChange,
+		UserID:  user.ID,
+		ActorID: scimActor,
+		Details: map[string]string{"from": previousStatus, "to": user.AccountStatus()},
+	})
+}
+
+// scimLocation returns the absolute URL of the user resource id
+func scimLocation(c *gin.Context, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2/Users/" + id
+}
+
+// scimJSON writes body with the SCIM media type
+func scimJSON(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+func scimErrorResponse(c *gin.Context, err *scim.Error) {
+	scimJSON(c, err.StatusCode(), err)
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// Series group posts of one author in reading order. Everyone who can
+// read posts can read series, but sees only the posts in them they can
+// read; the author and post admins change them.
+
+// CreateSeries godoc
+// @Summary Create a series
+// @Description Create an empty series of the caller's posts
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateSeriesRequest true "Series data"
+// @Success 201 {object} models.SuccessResponse{data=models.Series} "Series created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series [post]
+func (h *PostHandler) CreateSeries(c *gin.Context) {
+	var req models.CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	series := &models.Series{UserID: c.GetString("userID"), Title: req.Title, Description: req.Description}
+	if err := h.storageService.CreateSeries(c.Request.Context(), series); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to create series"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Series created successfully",
+		Data:    series,
+	})
+}
+
+// ListSeries godoc
+// @Summary List series
+// @Description Get a paginated list of series, without their posts. Filter by userId, title, createdAt and updatedAt as field=value or field[op]=value.
+// @Tags series
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of items per page" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,title"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
+// @Success 200 {object} models.ListResponse{data=[]models.Series} "Series retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series [get]
+func (h *PostHandler) ListSeries(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
+
+	series, total, err := h.storageService.ListSeries(c.Request.Context(), pagination, opts)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list series"))
+		return
+	}
+
+	pagination.Total = total
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       series,
+		Pagination: pagination,
+	})
+}
+
+// GetSeries godoc
+// @Summary Get a series
+// @Description Get a series with the posts in it the caller can read, in reading order
+// @Tags series
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Series} "Series retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 404 {object} models.ErrorResponse "Series not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id} [get]
+func (h *PostHandler) GetSeries(c *gin.Context) {
+	series, ok := h.series(c, false)
+	if !ok {
+		return
+	}
+	h.seriesResponse(c, "Series retrieved successfully", series)
+}
+
+// UpdateSeries godoc
+// @Summary Update a series
+// @Description Change the title or description of a series (its author or post admins)
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Param request body models.UpdateSeriesRequest true "Series update data"
+// @Success 200 {object} models.SuccessResponse{data=models.Series} "Series updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Series of another user"
+// @Failure 404 {object} models.ErrorResponse "Series not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id} [put]
+func (h *PostHandler) UpdateSeries(c *gin.Context) {
+	var req models.UpdateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	series, ok := h.series(c, true)
+	if !ok {
+		return
+	}
+
+	req.Apply(series)
+	if err := h.storageService.SaveSeries(c.Request.Context(), series); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update series"))
+		return
+	}
+	h.seriesResponse(c, "Series updated successfully", series)
+}
+
+// DeleteSeries godoc
+// @Summary Delete a series
+// @Description Delete a series (its author or post admins). Its posts are kept.
+// @Tags series
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Success 200 {object} models.SuccessResponse "Series deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Series of another user"
+// @Failure 404 {object} models.ErrorResponse "Series not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id} [delete]
+func (h *PostHandler) DeleteSeries(c *gin.Context) {
+	series, ok := h.series(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.DeleteSeries(c.Request.Context(), series); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to delete series"))
+		return
+	}
+	if series.UserID != c.GetString("userID") {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminSeriesDelete,
+			TargetType: models.AdminTargetSeries,
+			TargetID:   series.ID,
+		}, series, nil)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Series deleted successfully",
+	})
+}
+
+// AddSeriesPost godoc
+// @Summary Add a post to a series
+// @Description Add a post of the series' author at a position, counting from 1; without a position or past the end it is appended. Adding a post of the series again moves it. A post is part of at most one series.
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Param request body models.AddSeriesPostRequest true "Post to add"
+// @Success 200 {object} models.SuccessResponse{data=models.Series} "Post added to series successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or post of another author"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Series of another user"
+// @Failure 404 {object} models.ErrorResponse "Series or post not found"
+// @Failure 409 {object} models.ErrorResponse "Post is part of another series"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id}/posts [post]
+func (h *PostHandler) AddSeriesPost(c *gin.Context) {
+	var req models.AddSeriesPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	series, ok := h.series(c, true)
+	if !ok {
+		return
+	}
+
+	post, err := h.storageService.GetPost(c.Request.Context(), req.PostID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return
+	}
+	if post.UserID != series.UserID {
+		respondError(c, validationError([]models.FieldError{{Name: "postId", Rule: "author", Message: "postId must be a post of the series' author"}}))
+		return
+	}
+
+	err = h.storageService.AddSeriesPost(c.Request.Context(), series, post.ID, req.Position)
+	if errors.Is(err, services.ErrSeriesPostTaken) {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Post is part of another series"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to add post to series"))
+		return
+	}
+	h.seriesResponse(c, "Post added to series successfully", series)
+}
+
+// RemoveSeriesPost godoc
+// @Summary Remove a post from a series
+// @Description Take a post out of a series, if it is part of it. The post is kept.
+// @Tags series
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Param postId path string true "Post ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Series} "Post removed from series successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Series of another user"
+// @Failure 404 {object} models.ErrorResponse "Series not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id}/posts/{postId} [delete]
+func (h *PostHandler) RemoveSeriesPost(c *gin.Context) {
+	series, ok := h.series(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.RemoveSeriesPost(c.Request.Context(), series, c.Param("postId")); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to remove post from series"))
+		return
+	}
+	h.seriesResponse(c, "Post removed from series successfully", series)
+}
+
+// ReorderSeries godoc
+// @Summary Reorder a series
+// @Description Put the posts of a series in a new order, listing each of them once
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Param request body models.ReorderSeriesRequest true "Post IDs in reading order"
+// @Success 200 {object} models.SuccessResponse{data=models.Series} "Series reordered successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or order"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Series of another user"
+// @Failure 404 {object} models.ErrorResponse "Series not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /series/{id}/posts [put]
+func (h *PostHandler) ReorderSeries(c *gin.Context) {
+	var req models.ReorderSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	series, ok := h.series(c, true)
+	if !ok {
+		return
+	}
+
+	err := h.storageService.ReorderSeries(c.Request.Context(), series, req.PostIDs)
+	if errors.Is(err, services.ErrSeriesOrder) {
+		respondError(c, validationError([]models.FieldError{{Name: "postIds", Rule: "order", Message: "postIds must list every post of the series once"}}))
+		return
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to reorder series"))
+		return
+	}
+	h.seriesResponse(c, "Series reordered successfully", series)
+}
+
+// series loads the series of the request, checking the caller may change
+// it when write is set; it writes the error response if not
+func (h *PostHandler) series(c *gin.Context, write bool) (*models.Series, bool) {
+	series, err := h.storageService.GetSeries(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrSeriesNotFound) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.SeriesNotFound, "Series not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get series"))
+		return nil, false
+	}
+	if write && !ginAccess(c).allows(c.Request.Context(), h.storageService, series.UserID, "", models.PermPostsAdmin, true) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot change other user's series"))
+		return nil, false
+	}
+	return series, true
+}
+
+func (h *PostHandler) seriesResponse(c *gin.Context, message string, series *models.Series) {
+	series.Posts = readableSeriesPosts(c, h.storageService, series)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: message,
+		Data:    series,
+	})
+}
+
+// readableSeriesPosts returns the posts of series the caller can read, in
+// reading order
+func readableSeriesPosts(c *gin.Context, storageService *services.StorageService, series *models.Series) []*models.Post {
+	posts := make([]*models.Post, 0, len(series.PostIDs))
+	for _, postID := range series.PostIDs {
+		post, err := storageService.GetPost(c.Request.Context(), postID)
+		if err != nil || !canReadPost(c, storageService, post) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// setSeriesNavigation sets the Series of post when it is part of one,
+// counting only the posts of the series the caller can read. Lookup
+// failures leave it unset.
+func setSeriesNavigation(c *gin.Context, storageService *services.StorageService, post *models.Post) {
+	series, err := storageService.GetPostSeries(c.Request.Context(), post.ID)
+	if err != nil {
+		logging.FromContext(c.Request.Context(), slog.Default()).Warn("Failed to look up series of post", "postId", post.ID, "error", err)
+		return
+	}
+	if series == nil {
+		return
+	}
+
+	readable := *series
+	readable.PostIDs = nil
+	titles := make(map[string]string)
+	for _, other := range readableSeriesPosts(c, storageService, series) {
+		readable.PostIDs = append(readable.PostIDs, other.ID)
+		titles[other.ID] = other.Title
+	}
+	post.Series = readable.Navigation(post.ID, titles)
+}
+
+// seriesETag extends the ETag of a post with its series navigation, so
+// cached copies go stale when the series changes around it
+func seriesETag(etag string, nav *models.SeriesNavigation) string {
+	if etag == "" || nav == nil {
+		return etag
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(nav.ID + "\x00" + nav.Title + "\x00" + strconv.Itoa(nav.Position) + "/" + strconv.Itoa(nav.Total)))
+	for _, ref := range []*models.SeriesPostRef{nav.Previous, nav.Next} {
+		if ref != nil {
+			hash.Write([]byte("\x00" + ref.ID + "\x00" + ref.Title))
+		}
+	}
+	return etag + "-" + strconv.FormatUint(hash.Sum64(), 36)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seriesFixture is a series of bob's with posts a, b and c in that order
+type seriesFixture struct {
+	api        *testAPI
+	author     *models.User
+	token      string
+	series     *models.Series
+	a, b, c    *models.Post
+	seriesPath string
+}
+
+func newSeriesFixture(t *testing.T) *seriesFixture {
+	t.Helper()
+	api := newTestAPI(t)
+	author, token := api.user("bob", models.RoleUser)
+	f := &seriesFixture{api: api, author: author, token: token}
+
+	w := api.do(http.MethodPost, "/api/v1/series/", token, map[string]string{"title": "Go"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	f.series = &models.Series{}
+	decode(t, w, f.series)
+	f.seriesPath = "/api/v1/series/" + f.series.ID
+
+	f.a, f.b, f.c = f.post("Part 1"), f.post("Part 2"), f.post("Part 3")
+	for _, post := range []*models.Post{f.a, f.b, f.c} {
+		w = api.do(http.MethodPost, f.seriesPath+"/posts", token, map[string]any{"postId": post.ID})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	return f
+}
+
+// post creates a published post of the author
+func (f *seriesFixture) post(title string) *models.Post {
+	f.api.t.Helper()
+	post := &models.Post{UserID: f.author.ID, Title: title, Content: "...", Status: models.PostStatusPublished}
+	require.NoError(f.api.t, f.api.storage.CreatePost(context.Background(), post))
+	return post
+}
+
+// postIDs returns the posts of the series in reading order
+func (f *seriesFixture) postIDs() []string {
+	f.api.t.Helper()
+	series, err := f.api.storage.GetSeries(context.Background(), f.series.ID)
+	require.NoError(f.api.t, err)
+	return series.PostIDs
+}
+
+// navigation returns where post stands in its series as token sees it
+func (f *seriesFixture) navigation(token string, post *models.Post) *models.SeriesNavigation {
+	f.api.t.Helper()
+	w := f.api.do(http.MethodGet, "/api/v1/posts/"+post.ID, token, nil)
+	require.Equal(f.api.t, http.StatusOK, w.Code, w.Body.String())
+	var got models.Post
+	decode(f.api.t, w, &got)
+	return got.Series
+}
+
+func TestReorderSeries(t *testing.T) {
+	f := newSeriesFixture(t)
+	api := f.api
+	_, other := api.user("alice", models.RoleUser)
+	stranger := f.post("Not in it")
+
+	for name, order := range map[string][]string{
+		"missing":   {f.a.ID, f.b.ID},
+		"duplicate": {f.a.ID, f.b.ID, f.b.ID},
+		"foreign":   {f.a.ID, f.b.ID, stranger.ID},
+		"extra":     {f.a.ID, f.b.ID, f.c.ID, stranger.ID},
+	} {
+		w := api.do(http.MethodPut, f.seriesPath+"/posts", f.token, map[string]any{"postIds": order})
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		assert.Equal(t, string(apierr.ValidationFailed), errorCode(t, w), name)
+	}
+	assert.Equal(t, []string{f.a.ID, f.b.ID, f.c.ID}, f.postIDs())
+
+	w := api.do(http.MethodPut, f.seriesPath+"/posts", other, map[string]any{"postIds": []string{f.c.ID, f.b.ID, f.a.ID}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, []string{f.a.ID, f.b.ID, f.c.ID}, f.postIDs())
+
+	w = api.do(http.MethodPut, f.seriesPath+"/posts", f.token, map[string]any{"postIds": []string{f.c.ID, f.a.ID, f.b.ID}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var series models.Series
+	decode(t, w, &series)
+	assert.Equal(t, []string{f.c.ID, f.a.ID, f.b.ID}, series.PostIDs)
+	require.Len(t, series.Posts, 3)
+	assert.Equal(t, "Part 3", series.Posts[0].Title)
+	assert.Equal(t, []string{f.c.ID, f.a.ID, f.b.ID}, f.postIDs())
+
+	// Adding a post of the series again moves it
+	w = api.do(http.MethodPost, f.seriesPath+"/posts", f.token, map[string]any{"postId": f.b.ID, "position": 1})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{f.b.ID, f.c.ID, f.a.ID}, f.postIDs())
+}
+
+func TestSeriesNavigation(t *testing.T) {
+	f := newSeriesFixture(t)
+	_, reader := f.api.user("alice", models.RoleUser)
+
+	first := f.navigation(reader, f.a)
+	require.NotNil(t, first)
+	assert.Equal(t, f.series.ID, first.ID)
+	assert.Equal(t, "Go", first.Title)
+	assert.Equal(t, 1, first.Position)
+	assert.Equal(t, 3, first.Total)
+	assert.Nil(t, first.Previous)
+	assert.Equal(t, &models.SeriesPostRef{ID: f.b.ID, Title: "Part 2"}, first.Next)
+
+	middle := f.navigation(reader, f.b)
+	require.NotNil(t, middle)
+	assert.Equal(t, 2, middle.Position)
+	assert.Equal(t, &models.SeriesPostRef{ID: f.a.ID, Title: "Part 1"}, middle.Previous)
+	assert.Equal(t, &models.SeriesPostRef{ID: f.c.ID, Title: "Part 3"}, middle.Next)
+
+	last := f.navigation(reader, f.c)
+	require.NotNil(t, last)
+	assert.Equal(t, 3, last.Position)
+	assert.Equal(t, &models.SeriesPostRef{ID: f.b.ID, Title: "Part 2"}, last.Previous)
+	assert.Nil(t, last.Next)
+
+	// Posts the reader cannot see are skipped; the author still sees them
+	f.b.Status = models.PostStatusPending
+	require.NoError(t, f.api.storage.UpdatePost(context.Background(), f.b))
+	skipped := f.navigation(reader, f.a)
+	require.NotNil(t, skipped)
+	assert.Equal(t, 2, skipped.Total)
+	assert.Equal(t, &models.SeriesPostRef{ID: f.c.ID, Title: "Part 3"}, skipped.Next)
+	assert.Equal(t, f.b.ID, f.navigation(f.token, f.a).Next.ID)
+
+	w := f.api.do(http.MethodGet, f.seriesPath, reader, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var series models.Series
+	decode(t, w, &series)
+	assert.Len(t, series.PostIDs, 3)
+	assert.Len(t, series.Posts, 2)
+
+	// Posts outside a series have no navigation
+	assert.Nil(t, f.navigation(reader, f.post("Alone")))
+}
+
+func TestRemoveSeriesPost(t *testing.T) {
+	f := newSeriesFixture(t)
+	api := f.api
+	_, other := api.user("alice", models.RoleUser)
+
+	w := api.do(http.MethodDelete, f.seriesPath+"/posts/"+f.b.ID, other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = api.do(http.MethodDelete, f.seriesPath+"/posts/"+f.b.ID, f.token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{f.a.ID, f.c.ID}, f.postIDs())
+	assert.Nil(t, f.navigation(f.token, f.b))
+	assert.Equal(t, f.c.ID, f.navigation(f.token, f.a).Next.ID)
+
+	// Removing it again changes nothing
+	w = api.do(http.MethodDelete, f.seriesPath+"/posts/"+f.b.ID, f.token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{f.a.ID, f.c.ID}, f.postIDs())
+
+	// The post is free to join another series
+	w = api.do(http.MethodPost, "/api/v1/series/", f.token, map[string]string{"title": "Other"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var next models.Series
+	decode(t, w, &next)
+	w = api.do(http.MethodPost, "/api/v1/series/"+next.ID+"/posts", f.token, map[string]any{"postId": f.b.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodPost, "/api/v1/series/"+next.ID+"/posts", f.token, map[string]any{"postId": f.a.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Deleting a post takes it out of its series
+	w = api.do(http.MethodDelete, "/api/v1/posts/"+f.a.ID, f.token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{f.c.ID}, f.postIDs())
+}
+
+func TestDeleteSeries(t *testing.T) {
+	f := newSeriesFixture(t)
+	api := f.api
+	_, other := api.user("alice", models.RoleUser)
+	_, admin := api.user("root", models.RoleAdmin)
+
+	w := api.do(http.MethodDelete, f.seriesPath, other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = api.do(http.MethodDelete, f.seriesPath, admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodGet, f.seriesPath, f.token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, string(apierr.SeriesNotFound), errorCode(t, w))
+
+	// Its posts stay, in no series
+	for _, post := range []*models.Post{f.a, f.b, f.c} {
+		assert.Nil(t, f.navigation(f.token, post))
+	}
+	w = api.do(http.MethodPost, "/api/v1/series/", f.token, map[string]string{"title": "Again"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var next models.Series
+	decode(t, w, &next)
+	w = api.do(http.MethodPost, "/api/v1/series/"+next.ID+"/posts", f.token, map[string]any{"postId": f.a.ID})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = api.do(http.MethodDelete, f.seriesPath, f.token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,376 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// ServiceAccountHandler manages service accounts for backend integrations
+type ServiceAccountHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewServiceAccountHandler(storageService *services.StorageService, messagingClient *messaging.Client) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// CreateServiceAccount godoc
+// @Summary Create service account
+// @Description Create a service account with the given permissions and return its first key, which is not shown again (admin only). Permissions must be ones the admin holds; "*" and users:impersonate cannot be granted. The key is sent as a bearer token.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateServiceAccountRequest true "Service account"
+// @Success 201 {object} models.SuccessResponse{data=models.ServiceAccountKeyResponse} "Service account created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or permission"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts [post]
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if !checkServiceAccountPermissions(c, req.Permissions) {
+		return
+	}
+
+	account := &models.ServiceAccount{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+		CreatedBy:   c.GetString("userID"),
+	}
+	token, err := h.storageService.CreateServiceAccount(c.Request.Context(), account)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create service account"))
+		return
+	}
+	h.recordChange(c, nil, account, "created", models.AdminServiceAccountCreate)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Service account created successfully",
+		Data: models.ServiceAccountKeyResponse{
+			Account: account.ForResponse(),
+			KeyID:   account.Keys[0].ID,
+			Key:     token,
+		},
+	})
+}
+
+// ListServiceAccounts godoc
+// @Summary List service accounts
+// @Description List all service accounts with their keys and usage, ordered by name (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.ServiceAccount} "Service accounts retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts [get]
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.storageService.ListServiceAccounts(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list service accounts"))
+		return
+	}
+
+	for i, account := range accounts {
+		accounts[i] = account.ForResponse()
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Service accounts retrieved successfully",
+		Data:    accounts,
+	})
+}
+
+// GetServiceAccount godoc
+// @Summary Get service account
+// @Description Get a service account with its keys and usage (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ServiceAccount} "Service account retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Service account not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts/{id} [get]
+func (h *ServiceAccountHandler) GetServiceAccount(c *gin.Context) {
+	account, ok := h.loadServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Service account retrieved successfully",
+		Data:    account.ForResponse(),
+	})
+}
+
+// UpdateServiceAccount godoc
+// @Summary Update service account
+// @Description Change the description or permissions of a service account, or disable it so its keys stop working (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account ID"
+// @Param request body models.UpdateServiceAccountRequest true "Fields to change"
+// @Success 200 {object} models.SuccessResponse{data=models.ServiceAccount} "Service account updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or permission"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Service account not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts/{id} [patch]
+func (h *ServiceAccountHandler) UpdateServiceAccount(c *gin.Context) {
+	var req models.UpdateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if req.Permissions != nil && !checkServiceAccountPermissions(c, req.Permissions) {
+		return
+	}
+
+	account, ok := h.loadServiceAccount(c)
+	if !ok {
+		return
+	}
+	before := account.ForResponse()
+
+	if req.Description != nil {
+		account.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		account.Permissions = req.Permissions
+	}
+	if req.Disabled != nil {
+		account.Disabled = *req.Disabled
+	}
+
+	if err := h.storageService.SaveServiceAccount(c.Request.Context(), account); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update service account"))
+		return
+	}
+	h.recordChange(c, before, account, "updated", models.AdminServiceAccountUpdate)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Service account updated successfully",
+		Data:    account.ForResponse(),
+	})
+}
+
+// DeleteServiceAccount godoc
+// @Summary Delete service account
+// @Description Delete a service account; its keys stop working at once on this server and within 30 seconds on others (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account ID"
+// @Success 200 {object} models.SuccessResponse "Service account deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Service account not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts/{id} [delete]
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	account, ok := h.loadServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.DeleteServiceAccount(c.Request.Context(), account.ID); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete service account"))
+		return
+	}
+	h.recordChange(c, account, nil, "deleted", models.AdminServiceAccountDelete)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Service account deleted successfully",
+	})
+}
+
+// RotateServiceAccountKey godoc
+// @Summary Rotate service account key
+// @Description Add a new key to a service account and let the existing keys expire after expireOldKeysIn minutes, at once when zero, so integrations can switch over without downtime (admin only). The new key is not shown again.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account ID"
+// @Param request body models.RotateServiceAccountKeyRequest false "Grace period for the old keys"
+// @Success 201 {object} models.SuccessResponse{data=models.ServiceAccountKeyResponse} "Key created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Service account not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts/{id}/keys [post]
+func (h *ServiceAccountHandler) RotateServiceAccountKey(c *gin.Context) {
+	var req models.RotateServiceAccountKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+	}
+
+	account, ok := h.loadServiceAccount(c)
+	if !ok {
+		return
+	}
+	before := account.ForResponse()
+
+	grace := time.Duration(req.ExpireOldKeysIn) * time.Minute
+	key, token, err := h.storageService.RotateServiceAccountKey(c.Request.Context(), account, grace)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to rotate key"))
+		return
+	}
+	h.recordChange(c, before, account, "key rotated", models.AdminServiceAccountKeyRotate)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Key created successfully",
+		Data: models.ServiceAccountKeyResponse{
+			Account: account.ForResponse(),
+			KeyID:   key.ID,
+			Key:     token,
+		},
+	})
+}
+
+// RevokeServiceAccountKey godoc
+// @Summary Revoke service account key
+// @Description Expire one key of a service account at once (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account ID"
+// @Param keyId path string true "Key ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ServiceAccount} "Key revoked successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Service account or key not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/service-accounts/{id}/keys/{keyId} [delete]
+func (h *ServiceAccountHandler) RevokeServiceAccountKey(c *gin.Context) {
+	account, ok := h.loadServiceAccount(c)
+	if !ok {
+		return
+	}
+	before := account.ForResponse()
+
+	now := time.Now()
+	found := false
+	for _, key := range account.ActiveKeys(now) {
+		if key.ID == c.Param("keyId") {
+			key.ExpiresAt = &now
+			found = true
+		}
+	}
+	if !found {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.KeyNotFound, "Key not found"))
+		return
+	}
+
+	if err := h.storageService.SaveServiceAccount(c.Request.Context(), account); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke key"))
+		return
+	}
+	h.recordChange(c, before, account, "key revoked", models.AdminServiceAccountKeyRevoke)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Key revoked successfully",
+		Data:    account.ForResponse(),
+	})
+}
+
+// loadServiceAccount loads the service account named by the id parameter,
+// writing an error response when that fails
+func (h *ServiceAccountHandler) loadServiceAccount(c *gin.Context) (*models.ServiceAccount, bool) {
+	account, err := h.storageService.GetServiceAccount(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, storageError(err, "Failed to load service account"))
+		return nil, false
+	}
+	return account, true
+}
+
+// recordChange records a change of a service account, which is before
+// and after the change, nil when it was created or deleted. Snapshots
+// leave out the key hashes.
+func (h *ServiceAccountHandler) recordChange(c *gin.Context, before, after *models.ServiceAccount, action, adminAction string) {
+	account := after
+	if account == nil {
+		account = before
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditServiceAccount,
+		ActorID: c.GetString("userID"),
+		Details: map[string]string{"serviceAccount": account.ID, "name": account.Name, "action": action},
+	})
+
+	var beforeSnapshot, afterSnapshot any
+	if before != nil {
+		beforeSnapshot = before.ForResponse()
+	}
+	if after != nil {
+		afterSnapshot = after.ForResponse()
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     adminAction,
+		TargetType: models.AdminTargetServiceAccount,
+		TargetID:   account.ID,
+	}, beforeSnapshot, afterSnapshot)
+}
+
+// checkServiceAccountPermissions writes a 400 response unless every
+// permission is valid, narrower than "*", and held by the admin granting
+// it. Impersonation stays with people.
+func checkServiceAccountPermissions(c *gin.Context, permissions []string) bool {
+	for _, permission := range permissions {
+		message := ""
+		switch {
+		case !models.ValidPermission(permission):
+			message = "Unknown permission " + permission
+		case permission == models.PermAll || permission == models.PermUsersImpersonate:
+			message = "Service accounts cannot be granted " + permission
+		case !grantable(c, permission):
+			message = "You cannot grant " + permission + " without holding it"
+		}
+		if message != "" {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, message))
+			return false
+		}
+	}
+	return true
+}
+
+// grantable reports whether the caller holds permission, or for a wildcard
+// like "files:*" every permission it covers
+func grantable(c *gin.Context, permission string) bool {
+	wildcard := &models.Role{Permissions: []string{permission}}
+	for _, covered := range models.Permissions {
+		if wildcard.Has(covered) && !hasPermission(c, covered) {
+			return false
+		}
+	}
+	return true
+}
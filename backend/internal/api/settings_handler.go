@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// GetSettings godoc
+// @Summary Get settings
+// @Description Get the caller's preferences: theme, locale, the status of new posts and which emails they get. Users who never saved theirs get the defaults.
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.UserSettings} "Settings retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/settings [get]
+func (h *AuthHandler) GetSettings(c *gin.Context) {
+	settings, err := h.storageService.GetUserSettings(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get settings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Settings retrieved successfully",
+		Data:    settings,
+	})
+}
+
+// UpdateSettings godoc
+// @Summary Update settings
+// @Description Change the caller's preferences. Omitted fields are unchanged.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UpdateUserSettingsRequest true "Settings to change"
+// @Success 200 {object} models.SuccessResponse{data=models.UserSettings} "Settings updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /profile/settings [put]
+func (h *AuthHandler) UpdateSettings(c *gin.Context) {
+	var req models.UpdateUserSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.GetString("userID")
+	settings, err := h.storageService.GetUserSettings(ctx, userID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update settings"))
+		return
+	}
+	req.Apply(settings)
+	if err := h.storageService.SaveUserSettings(ctx, userID, settings); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to update settings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Settings updated successfully",
+		Data:    settings,
+	})
+}
+
+// applyDefaultPostStatus gives a new post without a status the default
+// status of its author's settings. Without readable settings the post
+// stays a draft.
+func applyDefaultPostStatus(ctx context.Context, storageService *services.StorageService, userID string, req *models.CreatePostRequest) {
+	if req.Status != "" {
+		return
+	}
+	settings, err := storageService.GetUserSettings(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx, slog.Default()).Warn("Failed to read settings for the default post status", "userId", userID, "error", err)
+		return
+	}
+	req.Status = settings.DefaultPostStatus
+}
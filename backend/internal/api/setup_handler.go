@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// setupTokenTTL is how long a setup token is valid; a server started after
+// it expired issues a new one
+const setupTokenTTL = 24 * time.Hour
+
+// SetupHandler creates the first admin of a new installation. While there
+// is no admin the server logs a one-time setup token at startup, which
+// POST /setup takes with the admin's account details.
+type SetupHandler struct {
+	storageService *services.StorageService
+	setupStore     *auth.SetupStore
+	passwordPolicy *auth.PasswordPolicy
+	passwordHasher *auth.PasswordHasher
+}
+
+func NewSetupHandler(storageService *services.StorageService, setupStore *auth.SetupStore, passwordPolicy *auth.PasswordPolicy, passwordHasher *auth.PasswordHasher) *SetupHandler {
+	return &SetupHandler{
+		storageService: storageService,
+		setupStore:     setupStore,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+	}
+}
+
+// OfferSetup logs a setup token when there is no admin yet and no other
+// server issued one
+func (h *SetupHandler) OfferSetup(ctx context.Context, logger *slog.Logger) {
+	if h.setupStore == nil {
+		return
+	}
+	hasAdmin, err := h.storageService.HasAdmin(ctx)
+	if err != nil {
+		logger.Error("Failed to look for an admin", "error", err)
+		return
+	}
+	if hasAdmin {
+		return
+	}
+
+	token, err := h.setupStore.Issue(ctx)
+	switch {
+	case err != nil:
+		logger.Error("Failed to issue a setup token", "error", err)
+	case token == "":
+		logger.Warn("No admin yet; another server logged the setup token, or run the create-admin command")
+	default:
+		logger.Warn("No admin yet; create one with POST /api/v1/setup and this one-time token, or run the create-admin command",
+			"setup_token", token)
+	}
+}
+
+// GetSetup godoc
+// @Summary Get setup status
+// @Description Get whether the installation still needs its first admin, for the frontend to offer the setup page
+// @Tags setup
+// @Produce json
+// @Success 200 {object} models.SuccessResponse{data=models.SetupStatus} "Setup status retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /setup [get]
+func (h *SetupHandler) GetSetup(c *gin.Context) {
+	hasAdmin, err := h.storageService.HasAdmin(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get setup status"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Setup status retrieved successfully",
+		Data:    models.SetupStatus{Required: !hasAdmin},
+	})
+}
+
+// CompleteSetup godoc
+// @Summary Create the first admin
+// @Description Create the first admin account with the one-time setup token the server logged at startup. Only possible while there is no admin; the token is used up by the first valid request.
+// @Tags setup
+// @Accept json
+// @Produce json
+// @Param request body models.SetupRequest true "Setup token and admin account"
+// @Success 201 {object} models.SuccessResponse{data=models.UserResponse} "Admin created"
+// @Failure 400 {object} models.ErrorResponse "Invalid request or weak password"
+// @Failure 403 {object} models.ErrorResponse "Invalid or expired setup token"
+// @Failure 409 {object} models.ErrorResponse "Setup is done, or the email or username is taken"
+// @Failure 503 {object} models.ErrorResponse "Setup is unavailable without Redis"
+// @Router /setup [post]
+func (h *SetupHandler) CompleteSetup(c *gin.Context) {
+	if h.setupStore == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Setup is unavailable, use the create-admin command"))
+		return
+	}
+
+	var req models.SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	hasAdmin, err := h.storageService.HasAdmin(ctx)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get setup status"))
+		return
+	}
+	if hasAdmin {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Setup is done"))
+		return
+	}
+	if err := h.passwordPolicy.Check(req.Password); err != nil {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.WeakPassword, err.Error()))
+		return
+	}
+	if _, err := h.storageService.GetUserByEmail(ctx, req.Email); err == nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.EmailTaken, "User with this email already exists"))
+		return
+	}
+	if _, err := h.storageService.GetUserByUsername(ctx, req.Username); err == nil {
+		respondError(c, apierr.New(http.StatusConflict, apierr.UsernameTaken, "Username already taken"))
+		return
+	}
+
+	// The token is used up last, so mistakes in the details do not cost it
+	if err := h.setupStore.Consume(ctx, req.Token); err != nil {
+		if errors.Is(err, auth.ErrInvalidSetupToken) {
+			respondError(c, apierr.New(http.StatusForbidden, apierr.TokenInvalid, "Invalid or expired setup token"))
+			return
+		}
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to check setup token"))
+		return
+	}
+
+	hashedPassword, err := h.passwordHasher.Hash(req.Password)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process password"))
+		return
+	}
+	now := time.Now()
+	user := &models.User{
+		Username:        req.Username,
+		Email:           req.Email,
+		Password:        hashedPassword,
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		Role:            models.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := h.storageService.CreateUser(ctx, user); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create user"))
+		return
+	}
+	requestLogger(c).Info("First admin created through setup", "user", user.ID)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Admin created",
+		Data:    user.ToUserResponse(),
+	})
+}
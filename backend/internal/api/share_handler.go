@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/auth"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+const defaultShareExpiryHours = 7 * 24
+
+type ShareHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+	passwordHasher *auth.PasswordHasher
+	mailer         mailer.Mailer
+	downloads      *auth.ShareDownloads // nil without Redis
+	shareURL       string               // frontend page share tokens are appended to
+}
+
+func NewShareHandler(storageService *services.StorageService, messagingClient *messaging.Client, passwordHasher *auth.PasswordHasher, mail mailer.Mailer, downloads *auth.ShareDownloads, shareURL string) *ShareHandler {
+	return &ShareHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+		passwordHasher: passwordHasher,
+		mailer:         mail,
+		downloads:      downloads,
+		shareURL:       shareURL,
+	}
+}
+
+// CreateShare godoc
+// @Summary Create a share link
+// @Description Create a share link for a file with an expiry, optional download limit and optional password. The link is emailed, with the note, to notifyEmails; the password is not.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.CreateShareRequest true "Share options"
+// @Success 201 {object} models.SuccessResponse{data=models.ShareResponse} "Share created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/shares [post]
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	fileID := c.Param("id")
+
+	var req models.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot share other user's file"))
+		return
+	}
+
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultShareExpiryHours
+	}
+
+	share := &models.Share{
+		FileID:       file.ID,
+		UserID:       file.UserID,
+		CreatedBy:    c.GetString("userID"),
+		ExpiresAt:    time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour),
+		MaxDownloads: req.MaxDownloads,
+	}
+
+	if req.Password != "" {
+		hashedPassword, err := h.passwordHasher.Hash(req.Password)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to process password"))
+			return
+		}
+		share.PasswordHash = hashedPassword
+	}
+
+	if err := h.storageService.CreateShare(c.Request.Context(), share); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create share"))
+		return
+	}
+	h.notifyRecipients(c, file, share, req)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Share created successfully",
+		Data:    share.ToShareResponse(),
+	})
+}
+
+// ListShares godoc
+// @Summary List share links
+// @Description List the share links of a file
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Success 200 {object} models.SuccessResponse{data=[]models.ShareResponse} "Shares retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/shares [get]
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	fileID := c.Param("id")
+
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot view other user's shares"))
+		return
+	}
+
+	shares, err := h.storageService.ListShares(c.Request.Context(), file.ID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list shares"))
+		return
+	}
+
+	shareResponses := make([]*models.ShareResponse, len(shares))
+	for i, share := range shares {
+		shareResponses[i] = share.ToShareResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Shares retrieved successfully",
+		Data:    shareResponses,
+	})
+}
+
+// RevokeShare godoc
+// @Summary Revoke a share link
+// @Description Revoke a share link so it can no longer be used (whoever may share the file: its owner, organization admins and file admins)
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param token path string true "Share token"
+// @Success 200 {object} models.SuccessResponse "Share revoked successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File or share not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/shares/{token} [delete]
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	fileID := c.Param("id")
+	token := c.Param("token")
+
+	share, err := h.storageService.GetShare(c.Request.Context(), token)
+	if err != nil || share.FileID != fileID {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.ShareNotFound, "Share not found"))
+		return
+	}
+
+	// Shares are revoked by whoever may create and list them
+	file, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot revoke other user's share"))
+		return
+	}
+
+	if err := h.storageService.DeleteShare(c.Request.Context(), token); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to revoke share"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Share revoked successfully",
+		Data:    nil,
+	})
+}
+
+// DownloadShare godoc
+// @Summary Download a shared file
+// @Description Download a file through a share link. Password protected shares take the password in the X-Share-Password header, never in the URL, where it would end up in logs and browser history. Downloads are counted atomically, so concurrent ones cannot go past the limit.
+// @Tags shares
+// @Produce application/octet-stream
+// @Param token path string true "Share token"
+// @Param X-Share-Password header string false "Share password"
+// @Success 200 {file} binary "File content"
+// @Failure 401 {object} models.ErrorResponse "Password required or invalid"
+// @Failure 403 {object} models.ErrorResponse "File quarantined"
+// @Failure 404 {object} models.ErrorResponse "Share not found"
+// @Failure 409 {object} models.ErrorResponse "File still being scanned"
+// @Failure 410 {object} models.ErrorResponse "Share expired or exhausted"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /shares/{token} [get]
+func (h *ShareHandler) DownloadShare(c *gin.Context) {
+	token := c.Param("token")
+
+	share, err := h.storageService.GetShare(c.Request.Context(), token)
+	if err != nil {
+		respondError(c, storageError(err, "Failed to load share"))
+		return
+	}
+
+	if time.Now().After(share.ExpiresAt) || (share.MaxDownloads > 0 && share.DownloadCount >= share.MaxDownloads) {
+		respondError(c, apierr.New(http.StatusGone, apierr.ShareExpired, "Share link has expired"))
+		return
+	}
+
+	if share.PasswordHash != "" {
+		password := c.GetHeader("X-Share-Password")
+		if password == "" || auth.CheckPassword(password, share.PasswordHash) != nil {
+			respondError(c, apierr.New(http.StatusUnauthorized, apierr.SharePassword, "Valid share password required"))
+			return
+		}
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), share.FileID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+
+	if !checkDownloadable(c, file) {
+		return
+	}
+
+	content, err := h.storageService.GetObjectContent(c.Request.Context(), file.Path)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get file content"))
+		return
+	}
+	defer content.Close()
+
+	count := share.DownloadCount + 1
+	if h.downloads != nil {
+		count, err = h.downloads.Take(c.Request.Context(), share.Token, share.DownloadCount, share.MaxDownloads, share.ExpiresAt)
+		if errors.Is(err, auth.ErrShareExhausted) {
+			respondError(c, apierr.New(http.StatusGone, apierr.ShareExpired, "Share link has expired"))
+			return
+		}
+		if err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to record download"))
+			return
+		}
+	}
+
+	now := time.Now()
+	share.DownloadCount = count
+	share.LastAccessAt = &now
+	if err := h.storageService.UpdateShare(c.Request.Context(), share); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to record download"))
+		return
+	}
+
+	written := streamFile(c, file, content)
+	recordDownload(h.messaging, c, file, models.AccessShare, share.Token, written)
+}
+
+// notifyRecipients emails the link of a new share to the addresses in the
+// request. Failures are only logged; the owner still has the link.
+func (h *ShareHandler) notifyRecipients(c *gin.Context, file *models.File, share *models.Share, req models.CreateShareRequest) {
+	if len(req.NotifyEmails) == 0 {
+		return
+	}
+
+	sharedBy := c.GetString("username")
+	data := mailer.ShareData{
+		SharedBy:    sharedBy,
+		FileName:    file.OriginalName,
+		Note:        req.Note,
+		Link:        h.shareURL + "/" + url.PathEscape(share.Token),
+		ExpiresAt:   share.ExpiresAt.UTC().Format(time.RFC1123),
+		HasPassword: share.PasswordHash != "",
+	}
+	for _, email := range slices.Compact(slices.Sorted(slices.Values(req.NotifyEmails))) {
+		msg, err := mailer.Render(mailer.TemplateShare, email, data)
+		if err == nil {
+			err = h.mailer.Send(c.Request.Context(), msg)
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to send share notification", "file", file.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadShare(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := api.user("owner", models.RoleUser)
+	file := api.file(owner, "notes.txt", "hello")
+
+	share := func(req models.CreateShareRequest) string {
+		t.Helper()
+		w := api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/shares", token, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response models.ShareResponse
+		decode(t, w, &response)
+		return response.Token
+	}
+	download := func(shareToken, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/shares/"+shareToken, nil)
+		if password != "" {
+			req.Header.Set("X-Share-Password", password)
+		}
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The password is taken from the header only
+	protected := share(models.CreateShareRequest{Password: "open-sesame"})
+	w := download(protected+"?password=open-sesame", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, string(apierr.SharePassword), errorCode(t, w))
+	assert.Equal(t, http.StatusUnauthorized, download(protected, "wrong").Code)
+	w = download(protected, "open-sesame")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "hello", w.Body.String())
+
+	// Concurrent downloads cannot go past the limit
+	limited := share(models.CreateShareRequest{MaxDownloads: 3})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := download(limited, "").Code
+			mu.Lock()
+			codes[code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[int]int{http.StatusOK: 3, http.StatusGone: 7}, codes)
+	assert.Equal(t, http.StatusGone, download(limited, "").Code)
+}
+
+func TestRevokeShare(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	owner, ownerToken := api.user("owner", models.RoleUser)
+	admin, adminToken := api.user("admin", models.RoleUser)
+	member, memberToken := api.user("member", models.RoleUser)
+
+	org := &models.Organization{Name: "Team", CreatedBy: owner.ID}
+	require.NoError(t, api.storage.CreateOrganization(ctx, org))
+	require.NoError(t, api.storage.SaveMembership(ctx, &models.Membership{OrgID: org.ID, UserID: admin.ID, Role: models.OrgRoleAdmin}))
+	require.NoError(t, api.storage.SaveMembership(ctx, &models.Membership{OrgID: org.ID, UserID: member.ID, Role: models.OrgRoleMember}))
+	file := api.file(owner, "plans.txt", "secret")
+	file.OrgID = org.ID
+	require.NoError(t, api.storage.UpdateFile(ctx, file))
+	shares := "/api/v1/files/" + file.ID + "/shares"
+
+	share := func(token string) *models.ShareResponse {
+		t.Helper()
+		w := api.do(http.MethodPost, shares, token, models.CreateShareRequest{})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response models.ShareResponse
+		decode(t, w, &response)
+		return &response
+	}
+	byOwner := share(ownerToken)
+	assert.Equal(t, owner.ID, byOwner.CreatedBy)
+	byAdmin := share(adminToken)
+	assert.Equal(t, admin.ID, byAdmin.CreatedBy)
+
+	// Organization admins revoke the shares they may create and list,
+	// members only their own files' shares
+	w := api.do(http.MethodDelete, shares+"/"+byOwner.Token, memberToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = api.do(http.MethodDelete, shares+"/"+byOwner.Token, adminToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = api.do(http.MethodDelete, shares+"/"+byAdmin.Token, ownerToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = api.do(http.MethodGet, shares, adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var remaining []*models.ShareResponse
+	decode(t, w, &remaining)
+	assert.Empty(t, remaining)
+	w = api.do(http.MethodDelete, shares+"/"+byAdmin.Token, ownerToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxStatsDays bounds the daily series of the statistics, which are kept
+// for a little over a year
+const maxStatsDays = 365
+
+type StatsHandler struct {
+	storageService *services.StorageService
+	redisClient    *redis.Client
+	messaging      *messaging.Client
+}
+
+func NewStatsHandler(storageService *services.StorageService, redisClient *redis.Client, messagingClient *messaging.Client) *StatsHandler {
+	return &StatsHandler{
+		storageService: storageService,
+		redisClient:    redisClient,
+		messaging:      messagingClient,
+	}
+}
+
+// GetStats godoc
+// @Summary Get system statistics
+// @Description Get the number of users, posts by status, files and their bytes, signups and uploads per day, and the health of MinIO, Redis and the in-memory caches. Counts are kept as data changes; complete is false until they were first built from the stored data.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param days query int false "Days of signups and uploads, up to 365" default(30)
+// @Success 200 {object} models.SuccessResponse{data=models.SystemStats} "Statistics retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Statistics are not available"
+// @Router /admin/stats [get]
+func (h *StatsHandler) GetStats(c *gin.Context) {
+	counter := h.storageService.StatsCounter()
+	if counter == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Statistics are not available"))
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxStatsDays {
+		respondError(c, validationError([]models.FieldError{{Name: "days", Rule: "range", Message: "days must be between 1 and " + strconv.Itoa(maxStatsDays)}}))
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	result := models.SystemStats{GeneratedAt: now}
+	if result.Complete, err = counter.Ready(ctx); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get statistics"))
+		return
+	}
+
+	users, err := counter.Summary(ctx, stats.Users)
+	if err == nil {
+		result.Users.Total = users.Count
+		result.Users.SignupsPerDay, err = counter.Daily(ctx, stats.Users, now, days)
+	}
+	if err == nil {
+		var posts *stats.Summary
+		if posts, err = counter.Summary(ctx, stats.Posts); err == nil {
+			result.Posts = models.PostTotals{Total: posts.Count, ByStatus: posts.Groups}
+		}
+	}
+	if err == nil {
+		var files *stats.Summary
+		if files, err = counter.Summary(ctx, stats.Files); err == nil {
+			result.Files.Total, result.Files.Bytes = files.Count, files.Bytes
+			result.Files.UploadsPerDay, err = counter.Daily(ctx, stats.Files, now, days)
+		}
+	}
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to get statistics"))
+		return
+	}
+
+	result.Health = models.SystemHealth{
+		MinIO:  checkHealth(ctx, h.storageService.PingMinIO),
+		Redis:  checkHealth(ctx, func(ctx context.Context) error { return h.redisClient.Ping(ctx).Err() }),
+		Caches: h.storageService.CacheSizes(),
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Statistics retrieved successfully",
+		Data:    result,
+	})
+}
+
+// RebuildStats godoc
+// @Summary Rebuild system statistics
+// @Description Count every stored user, post and file again in the background, correcting counts that missed changes, e.g. while Redis was down. This scans all buckets.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.SuccessResponse "Statistics rebuild started"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 503 {object} models.ErrorResponse "Statistics are not available"
+// @Router /admin/stats/rebuild [post]
+func (h *StatsHandler) RebuildStats(c *gin.Context) {
+	if h.storageService.StatsCounter() == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Statistics are not available"))
+		return
+	}
+
+	logger := requestLogger(c)
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		started, err := h.storageService.RebuildStats(ctx)
+		switch {
+		case err != nil:
+			logger.Error("Failed to rebuild statistics", "error", err)
+		case !started:
+			logger.Info("Statistics are already being rebuilt")
+		}
+	}()
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminStatsRebuild,
+		TargetType: models.AdminTargetSystem,
+		TargetID:   "stats",
+	}, nil, nil)
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Message: "Statistics rebuild started",
+	})
+}
+
+// checkHealth times check, which reports whether a service is up
+func checkHealth(ctx context.Context, check func(ctx context.Context) error) models.ServiceHealth {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	health := models.ServiceHealth{Status: "up", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		health.Status = "down"
+		health.Error = err.Error()
+	}
+	return health
+}
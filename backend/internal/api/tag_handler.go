@@ -0,0 +1,69 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// maxTagsListed bounds GET /tags
+const maxTagsListed = 1000
+
+// tagOrders sort the tag directory; ties go by name
+var tagOrders = map[string]func(a, b *models.TagCount) int{
+	"posts":    func(a, b *models.TagCount) int { return cmp.Compare(b.Posts, a.Posts) },
+	"trending": func(a, b *models.TagCount) int { return cmp.Compare(b.Trend, a.Trend) },
+	"name":     func(a, b *models.TagCount) int { return 0 },
+}
+
+// ListTags godoc
+// @Summary List tags
+// @Description Get the tags of published posts that are not shared with an organization, with how many posts carry each and how often they were added lately. Filter by prefix for autocompletion.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param prefix query string false "Only tags starting with this, case-insensitive"
+// @Param sort query string false "Order: posts, trending or name" default(posts)
+// @Param limit query int false "Tags to list, up to 1000" default(100)
+// @Success 200 {object} models.SuccessResponse{data=[]models.TagCount} "Tags retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or limit"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /tags [get]
+func (h *PostHandler) ListTags(c *gin.Context) {
+	order, ok := tagOrders[c.DefaultQuery("sort", "posts")]
+	if !ok {
+		respondError(c, validationError([]models.FieldError{{Name: "sort", Rule: "oneof", Message: "sort must be one of posts, trending, name"}}))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxTagsListed {
+		respondError(c, validationError([]models.FieldError{{Name: "limit", Rule: "range", Message: "limit must be between 1 and " + strconv.Itoa(maxTagsListed)}}))
+		return
+	}
+
+	tags, err := h.storageService.ListTags(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to list tags"))
+		return
+	}
+
+	prefix := strings.ToLower(c.Query("prefix"))
+	tags = slices.DeleteFunc(tags, func(tag *models.TagCount) bool { return !strings.HasPrefix(tag.Tag, prefix) })
+	slices.SortFunc(tags, func(a, b *models.TagCount) int {
+		return cmp.Or(order(a, b), strings.Compare(a.Tag, b.Tag))
+	})
+	tags = tags[:min(len(tags), limit)]
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Tags retrieved successfully",
+		Data:    tags,
+	})
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// TransferPost godoc
+// @Summary Transfer a post
+// @Description Give a post to another user, share it with an organization, or both. The caller must be able to change the post and, to share it, be a member of the organization; the new owner must be active and a member of the post's organization. Only post admins can give a post that is not shared with an organization to another user.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param request body models.TransferRequest true "New owner and organization"
+// @Success 200 {object} models.SuccessResponse{data=models.Post} "Post transferred successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or new owner"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Post, user or organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /posts/{id}/transfer [post]
+func (h *PostHandler) TransferPost(c *gin.Context) {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	post, err := h.storageService.GetPost(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+		return
+	}
+	if !canChangePost(c, h.storageService, post) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot transfer other user's post"))
+		return
+	}
+
+	previousOwner := post.UserID
+	userID, orgID, ok := transferTarget(c, h.storageService, req, post.UserID, post.OrgID, models.PermPostsAdmin)
+	if !ok {
+		return
+	}
+	if err := h.storageService.TransferPost(c.Request.Context(), post, userID, orgID); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to transfer post"))
+		return
+	}
+	recordTransfer(h.messaging, c, previousOwner, "post", post.ID, userID, orgID)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Post transferred successfully",
+		Data:    post,
+	})
+}
+
+// TransferFile godoc
+// @Summary Transfer a file
+// @Description Give a file, with its versions, renditions, access log and share links, to another user, share it with an organization, or both. The caller must be able to change the file and, to share it, be a member of the organization; the new owner must be active and a member of the file's organization. Only file admins can give a file that is not shared with an organization to another user. A file that cannot be moved whole stays with its owner.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param request body models.TransferRequest true "New owner and organization"
+// @Success 200 {object} models.SuccessResponse{data=models.File} "File transferred successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or new owner"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "File, user or organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /files/{id}/transfer [post]
+func (h *FileHandler) TransferFile(c *gin.Context) {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	file, err := h.storageService.GetFile(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.FileNotFound, "File not found"))
+		return
+	}
+	if !canChangeFile(c, h.storageService, file) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot transfer other user's file"))
+		return
+	}
+
+	previousOwner := file.UserID
+	userID, orgID, ok := transferTarget(c, h.storageService, req, file.UserID, file.OrgID, models.PermFilesAdmin)
+	if !ok {
+		return
+	}
+	if err := h.storageService.TransferFile(c.Request.Context(), file, userID, orgID); err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to transfer file"))
+		return
+	}
+	recordTransfer(h.messaging, c, previousOwner, "file", file.ID, userID, orgID)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "File transferred successfully",
+		Data:    file.ForViewer(c.GetString("userID")),
+	})
+}
+
+// TransferContent godoc
+// @Summary Transfer a user's content
+// @Description Give every post and file of a user to another active user, for example when an employee leaves (admin only). Items keep their organization unless orgId is given, which shares all of them with that organization; the new owner must be a member of it. On failure the items moved so far stay moved.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.TransferRequest true "New owner and organization"
+// @Success 200 {object} models.SuccessResponse{data=models.TransferResult} "Content transferred successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format or new owner"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "User or organization not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/transfer [post]
+func (h *UserHandler) TransferContent(c *gin.Context) {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	fromUserID := c.Param("id")
+	if _, err := h.storageService.GetUser(c.Request.Context(), fromUserID); err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+	if req.UserID == "" || req.UserID == fromUserID {
+		respondError(c, validationError([]models.FieldError{{Name: "userId", Rule: "ne", Message: "userId must name another user"}}))
+		return
+	}
+
+	userID, orgID, ok := transferTarget(c, h.storageService, req, fromUserID, "", models.PermUsersAdmin)
+	if !ok {
+		return
+	}
+	result, err := h.storageService.TransferUserContent(c.Request.Context(), fromUserID, userID, req.OrgID)
+	if err != nil {
+		respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to transfer content"))
+		return
+	}
+	recordTransfer(h.messaging, c, fromUserID, "all", fromUserID, userID, orgID)
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminUserTransfer,
+		TargetType: models.AdminTargetUser,
+		TargetID:   fromUserID,
+	}, nil, map[string]any{"toUserId": userID, "toOrgId": orgID, "result": result})
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Content transferred successfully",
+		Data:    result,
+	})
+}
+
+// transferTarget works out who owns an item of ownerID in orgID after the
+// transfer req, writing the error response if the transfer is not
+// allowed: the new owner must be an active user and a member of the
+// organization the item ends up in, and sharing with another organization
+// needs the caller to be a member of it unless they hold adminPermission.
+// Without it, items can only be given to another user within an
+// organization, so that nobody is pushed content they did not ask for.
+func transferTarget(c *gin.Context, storageService *services.StorageService, req models.TransferRequest, ownerID, orgID, adminPermission string) (string, string, bool) {
+	ctx := c.Request.Context()
+
+	userID := ownerID
+	if req.UserID != "" {
+		user, err := storageService.GetUser(ctx, req.UserID)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+			return "", "", false
+		}
+		if user.AccountStatus() != models.UserStatusActive {
+			respondError(c, validationError([]models.FieldError{{Name: "userId", Rule: "active", Message: "userId must name an active user"}}))
+			return "", "", false
+		}
+		userID = user.ID
+	}
+
+	if req.OrgID != "" {
+		if _, err := storageService.GetOrganization(ctx, req.OrgID); err != nil {
+			if errors.Is(err, services.ErrOrganizationNotFound) {
+				respondError(c, apierr.New(http.StatusNotFound, apierr.OrganizationNotFound, "Organization not found"))
+				return "", "", false
+			}
+			respondError(c, apierr.Wrap(err, http.StatusInternalServerError, apierr.Internal, "Failed to get organization"))
+			return "", "", false
+		}
+		if !hasPermission(c, adminPermission) && !requireOrgRole(c, storageService, req.OrgID, models.OrgRoleMember) {
+			return "", "", false
+		}
+		orgID = req.OrgID
+	}
+
+	if userID != ownerID && orgID == "" && !hasPermission(c, adminPermission) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Only admins can give items to another user outside an organization"))
+		return "", "", false
+	}
+	if orgID != "" && !models.OrgRoleAtLeast(orgRole(ctx, storageService, orgID, userID), models.OrgRoleMember) {
+		respondError(c, validationError([]models.FieldError{{Name: "userId", Rule: "member", Message: "userId must name a member of the organization"}}))
+		return "", "", false
+	}
+	return userID, orgID, true
+}
+
+// recordTransfer publishes a transfer of the content of previousOwner for
+// their audit log; id is the post or file, or the user for all content
+func recordTransfer(messagingClient *messaging.Client, c *gin.Context, previousOwner, kind, id, userID, orgID string) {
+	event := models.AuditEvent{
+		Type:    models.AuditTransfer,
+		UserID:  previousOwner,
+		Details: map[string]string{"kind": kind, "id": id, "toUserId": userID},
+	}
+	if orgID != "" {
+		event.Details["toOrgId"] = orgID
+	}
+	if actorID := c.GetString("userID"); actorID != previousOwner {
+		event.ActorID = actorID
+	}
+	recordAudit(messagingClient, c, event)
+}
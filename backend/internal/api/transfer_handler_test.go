@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferFileAuthorization(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	alice, aliceToken := api.user("alice", models.RoleUser)
+	bob, _ := api.user("bob", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+
+	// Nobody is pushed files they did not ask for
+	file := api.file(alice, "notes.txt", "hello")
+	w := api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/transfer", aliceToken, models.TransferRequest{UserID: bob.ID})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	// Within an organization both belong to, files can be handed over
+	org := &models.Organization{Name: "Team", CreatedBy: alice.ID}
+	require.NoError(t, api.storage.CreateOrganization(ctx, org))
+	require.NoError(t, api.storage.SaveMembership(ctx, &models.Membership{OrgID: org.ID, UserID: bob.ID, Role: models.OrgRoleMember}))
+	w = api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/transfer", aliceToken, models.TransferRequest{UserID: bob.ID, OrgID: org.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// File admins can give files to anyone
+	other := api.file(alice, "other.txt", "hello")
+	w = api.do(http.MethodPost, "/api/v1/files/"+other.ID+"/transfer", adminToken, models.TransferRequest{UserID: bob.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestTransferFileMovesObjects(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	alice, aliceToken := api.user("alice", models.RoleUser)
+	bob, _ := api.user("bob", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+
+	file := api.file(alice, "notes.txt", "one")
+	require.NoError(t, api.storage.StoreFileVersion(ctx, file, "text/plain", 3, strings.NewReader("two"), nil))
+	w := api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/shares", aliceToken, models.CreateShareRequest{})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var share models.ShareResponse
+	decode(t, w, &share)
+
+	w = api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/transfer", adminToken, models.TransferRequest{UserID: bob.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Empty(t, api.minio.Keys("files", "files/"+alice.ID+"/"))
+	prefix := "files/" + bob.ID + "/" + file.ID + "/"
+	assert.Equal(t, []string{prefix + "content", prefix + "metadata.json", prefix + "versions/1"}, api.minio.Keys("files", prefix))
+	moved, err := api.storage.GetFile(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, moved.UserID)
+	assert.Equal(t, prefix+"versions/1", moved.Versions[0].Path)
+
+	// The share link is handed over and keeps working
+	shares, err := api.storage.ListShares(ctx, file.ID)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, bob.ID, shares[0].UserID)
+	w = api.do(http.MethodGet, "/api/v1/shares/"+share.Token, "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "two", w.Body.String())
+}
+
+func TestTransferFileFailure(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	alice, _ := api.user("alice", models.RoleUser)
+	bob, _ := api.user("bob", models.RoleUser)
+	_, adminToken := api.user("root", models.RoleAdmin)
+
+	file := api.file(alice, "notes.txt", "one")
+	require.NoError(t, api.storage.StoreFileVersion(ctx, file, "text/plain", 3, strings.NewReader("two"), nil))
+	before := api.minio.Keys("files", "files/"+alice.ID+"/")
+
+	// The version cannot be copied after the content was
+	api.minio.FailPuts(func(bucket, key string) bool { return strings.HasSuffix(key, "/versions/1") })
+	w := api.do(http.MethodPost, "/api/v1/files/"+file.ID+"/transfer", adminToken, models.TransferRequest{UserID: bob.ID})
+	assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+	api.minio.FailPuts(nil)
+
+	assert.Empty(t, api.minio.Keys("files", "files/"+bob.ID+"/"))
+	assert.Equal(t, before, api.minio.Keys("files", "files/"+alice.ID+"/"))
+	stored, err := api.storage.GetFile(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, stored.UserID)
+}
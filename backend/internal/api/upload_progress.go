@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+const (
+	// progressInterval throttles how often progress events are published
+	progressInterval = 250 * time.Millisecond
+	// progressHeartbeat keeps idle SSE connections open through proxies
+	progressHeartbeat = 15 * time.Second
+)
+
+// uploadIDPattern restricts client chosen upload IDs to safe subject tokens
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// progressReporter publishes upload progress events for one upload. A nil
+// reporter is valid and does nothing, so callers need not check whether
+// progress was requested.
+type progressReporter struct {
+	messaging *messaging.Client
+	logger    *slog.Logger
+	event     messaging.UploadProgress
+	last      time.Time
+	completed bool
+}
+
+// newProgressReporter returns a reporter when the client asked for progress
+// with an upload ID and messaging is available, nil otherwise
+func (h *FileHandler) newProgressReporter(c *gin.Context, total int64) *progressReporter {
+	uploadID := c.GetHeader("X-Upload-ID")
+	if uploadID == "" {
+		uploadID = c.Query("uploadId")
+	}
+	if h.messaging == nil || !uploadIDPattern.MatchString(uploadID) {
+		return nil
+	}
+
+	return &progressReporter{
+		messaging: h.messaging,
+		logger:    requestLogger(c),
+		event: messaging.UploadProgress{
+			UploadID: uploadID,
+			UserID:   c.GetString("userID"),
+			Stage:    messaging.UploadStageReceiving,
+			Total:    total,
+		},
+	}
+}
+
+// wrap counts bytes read through r
+func (p *progressReporter) wrap(r io.ReadCloser) io.ReadCloser {
+	if p == nil {
+		return r
+	}
+	return &progressReader{ReadCloser: r, reporter: p}
+}
+
+func (p *progressReporter) add(n int) {
+	p.event.Bytes += int64(n)
+	if time.Since(p.last) >= progressInterval {
+		p.publish()
+	}
+}
+
+// stage moves the upload to a new stage and publishes it immediately
+func (p *progressReporter) stage(stage, fileID string) {
+	if p == nil {
+		return
+	}
+	p.event.Stage = stage
+	p.event.FileID = fileID
+	p.publish()
+}
+
+// complete reports the stored file. Call it once the upload succeeded.
+func (p *progressReporter) complete(fileID string) {
+	if p == nil {
+		return
+	}
+	p.completed = true
+	p.stage(messaging.UploadStageComplete, fileID)
+}
+
+// fail reports a failed upload unless it completed; defer it right after
+// creating the reporter.
+func (p *progressReporter) fail() {
+	if p == nil || p.completed {
+		return
+	}
+	p.stage(messaging.UploadStageFailed, "")
+}
+
+func (p *progressReporter) publish() {
+	p.last = time.Now()
+	subject := messaging.UploadProgressSubject(p.event.UploadID)
+	if err := p.messaging.Publish(context.Background(), subject, p.event); err != nil {
+		p.logger.Error("Failed to publish upload progress", "upload", p.event.UploadID, "error", err)
+	}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	reporter *progressReporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.reporter.add(n)
+	}
+	return n, err
+}
+
+// UploadProgress godoc
+// @Summary Stream upload progress
+// @Description Server-Sent Events stream of progress for an upload started with the same X-Upload-ID header (or uploadId query parameter). Each "progress" event carries the stage (receiving, storing, complete, failed) and byte counts; the stream ends after complete or failed.
+// @Tags files
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path string true "Upload ID chosen by the client"
+// @Success 200 {object} messaging.UploadProgress "Progress events"
+// @Failure 400 {object} models.ErrorResponse "Invalid upload ID"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 503 {object} models.ErrorResponse "Progress events unavailable"
+// @Router /files/uploads/{id}/progress [get]
+func (h *FileHandler) UploadProgress(c *gin.Context) {
+	uploadID := c.Param("id")
+	userID := c.GetString("userID")
+	canWatchAll := hasPermission(c, models.PermFilesAdmin)
+
+	if !uploadIDPattern.MatchString(uploadID) {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Upload ID must be 8-64 letters, digits, dashes or underscores"))
+		return
+	}
+
+	if h.messaging == nil {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Upload progress is not available"))
+		return
+	}
+
+	events := make(chan messaging.UploadProgress, 16)
+	sub, err := h.messaging.Subscribe(messaging.UploadProgressSubject(uploadID), func(data []byte) {
+		var event messaging.UploadProgress
+		if err := json.Unmarshal(data, &event); err != nil {
+			return
+		}
+		// Upload IDs are chosen by clients, so only the uploader may watch
+		if event.UserID != userID && !canWatchAll {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			// Drop intermediate events for slow readers rather than block NATS
+		}
+	})
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to subscribe to upload progress"))
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		requestLogger(c).Error("Failed to clear write deadline for upload progress", "error", err)
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"uploadId": uploadID})
+			return true
+		case event := <-events:
+			c.SSEvent("progress", event)
+			return event.Stage != messaging.UploadStageComplete && event.Stage != messaging.UploadStageFailed
+		}
+	})
+}
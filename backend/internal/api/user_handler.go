@@ -4,43 +4,48 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
 	"github.com/minio-fullstack-storage/backend/internal/services"
 )
 
 type UserHandler struct {
 	storageService *services.StorageService
+	messaging      *messaging.Client
 }
 
-func NewUserHandler(storageService *services.StorageService) *UserHandler {
+func NewUserHandler(storageService *services.StorageService, messagingClient *messaging.Client) *UserHandler {
 	return &UserHandler{
 		storageService: storageService,
+		messaging:      messagingClient,
 	}
 }
 
 // ListUsers godoc
 // @Summary List users
-// @Description Get a list of users with pagination
+// @Description Get a list of users with pagination, sorting and filtering. Filter by username, email, firstName, lastName, role, status, createdAt and lastLoginAt as field=value or field[op]=value.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Page size" default(10)
+// @Param sort query string false "Comma-separated fields, - for descending, e.g. -createdAt,username"
+// @Param from query string false "RFC 3339 earliest creation time"
+// @Param to query string false "RFC 3339 latest creation time"
 // @Success 200 {object} models.ListResponse{data=[]models.UserResponse} "Users retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid sort or filter"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /users [get]
 func (h *UserHandler) ListUsers(c *gin.Context) {
 	pagination := c.MustGet("pagination").(models.Pagination)
+	opts := c.MustGet("query").(models.QueryOptions)
 
-	users, total, err := h.storageService.ListUsers(c.Request.Context(), pagination)
+	users, total, err := h.storageService.ListUsers(c.Request.Context(), pagination, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to list users",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list users"))
 		return
 	}
 
@@ -66,7 +71,9 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "User ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.SuccessResponse{data=models.UserResponse} "User retrieved successfully"
+// @Success 304 "Not modified"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 404 {object} models.ErrorResponse "User not found"
 // @Router /users/{id} [get]
@@ -75,14 +82,13 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 
 	user, err := h.storageService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "User not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
 		return
 	}
 
+	if notModified(c, weakETag(user.ETag), user.UpdatedAt) {
+		return
+	}
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "User retrieved successfully",
 		Data:    user.ToUserResponse(),
@@ -97,7 +103,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "User ID"
-// @Param request body models.User true "User update data"
+// @Param request body models.UpdateUserRequest true "User update data"
 // @Success 200 {object} models.SuccessResponse{data=models.User} "User updated successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request format"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
@@ -108,63 +114,64 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	userID := c.Param("id")
 	currentUserID := c.GetString("userID")
-	currentUserRole := c.GetString("role")
 
 	// Check if user can update this profile
-	if userID != currentUserID && currentUserRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot update other user's profile",
-			Code:    http.StatusForbidden,
-		})
+	if userID != currentUserID && !hasPermission(c, models.PermUsersAdmin) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot update other user's profile"))
 		return
 	}
 
-	var updates models.User
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
 		return
 	}
 
 	// Get existing user
 	user, err := h.storageService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "User not found",
-			Code:    http.StatusNotFound,
-		})
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
 		return
 	}
 
-	// Update allowed fields
-	if updates.FirstName != "" {
-		user.FirstName = updates.FirstName
-	}
-	if updates.LastName != "" {
-		user.LastName = updates.LastName
-	}
-	if updates.Avatar != "" {
-		user.Avatar = updates.Avatar
-	}
+	before := user.ToUserResponse()
+	req.Apply(user)
 
-	// Only admin can update role
-	if currentUserRole == "admin" && updates.Role != "" {
-		user.Role = updates.Role
+	// Only user admins can change roles, only to roles that exist, and
+	// only to roles granting nothing the caller lacks
+	previousRole := user.Role
+	if req.Role != nil && *req.Role != user.Role && hasPermission(c, models.PermUsersAdmin) {
+		role, err := h.storageService.GetRole(c.Request.Context(), *req.Role)
+		if err != nil {
+			respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Unknown role "+*req.Role))
+			return
+		}
+		if !assignable(c, role) {
+			respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "You cannot assign role "+role.Name+" without holding its permissions"))
+			return
+		}
+		user.Role = *req.Role
 	}
 
 	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to update user",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update user"))
 		return
 	}
+	if user.Role != previousRole {
+		recordAudit(h.messaging, c, models.AuditEvent{
+			Type:    models.AuditRoleChange,
+			UserID:  user.ID,
+			ActorID: currentUserID,
+			Details: map[string]string{"from": previousRole, "to": user.Role},
+		})
+	}
+	if userID != currentUserID || user.Role != previousRole {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminUserUpdate,
+			TargetType: models.AdminTargetUser,
+			TargetID:   user.ID,
+		}, before, user.ToUserResponse())
+	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "User updated successfully",
@@ -172,6 +179,22 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	})
 }
 
+// assignable reports whether the caller may give a user role: role admins
+// may assign any role, others only one whose permissions they all hold
+func assignable(c *gin.Context, role *models.Role) bool {
+	return hasPermission(c, models.PermRolesAdmin) || holdsRole(c, role)
+}
+
+// holdsRole reports whether the caller holds every permission role grants
+func holdsRole(c *gin.Context, role *models.Role) bool {
+	for _, permission := range role.Permissions {
+		if !grantable(c, permission) {
+			return false
+		}
+	}
+	return true
+}
+
 // DeleteUser godoc
 // @Summary Delete user
 // @Description Delete a user (admin only)
@@ -189,29 +212,122 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 	currentUserID := c.GetString("userID")
-	currentUserRole := c.GetString("role")
 
 	// Check if user can delete this profile
-	if userID != currentUserID && currentUserRole != "admin" {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error:   "Forbidden",
-			Message: "Cannot delete other user's profile",
-			Code:    http.StatusForbidden,
-		})
+	if userID != currentUserID && !hasPermission(c, models.PermUsersAdmin) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.NotOwner, "Cannot delete other user's profile"))
 		return
 	}
 
+	// Deleting someone else's account is recorded with what it was
+	var before *models.UserResponse
+	if userID != currentUserID {
+		if user, err := h.storageService.GetUser(c.Request.Context(), userID); err == nil {
+			before = user.ToUserResponse()
+		}
+	}
+
 	if err := h.storageService.DeleteUser(c.Request.Context(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to delete user",
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete user"))
 		return
 	}
+	if userID != currentUserID {
+		recordAdminAction(h.messaging, c, models.AdminAction{
+			Action:     models.AdminUserDelete,
+			TargetType: models.AdminTargetUser,
+			TargetID:   userID,
+		}, before, nil)
+	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "User deleted successfully",
 		Data:    nil,
 	})
 }
+
+// UpdateUserStatus godoc
+// @Summary Change account status
+// @Description Suspend, deactivate or reactivate a user (admin only). Inactive users keep their data but cannot sign in, and their existing tokens are rejected within 30 seconds.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.UpdateUserStatusRequest true "New status"
+// @Success 200 {object} models.SuccessResponse{data=models.UserResponse} "Status updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/status [put]
+func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req models.UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	// Locking yourself out leaves nobody to undo it
+	if userID == c.GetString("userID") {
+		respondError(c, apierr.New(http.StatusBadRequest, apierr.BadRequest, "Cannot change the status of your own account"))
+		return
+	}
+
+	user, err := h.storageService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	before := user.ToUserResponse()
+	previousStatus := user.AccountStatus()
+	user.Status = req.Status
+	if err := h.storageService.UpdateUser(c.Request.Context(), user); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update user status"))
+		return
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditStatusChange,
+		UserID:  user.ID,
+		ActorID: c.GetString("userID"),
+		Details: map[string]string{"from": previousStatus, "to": user.Status},
+	})
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     models.AdminUserStatus,
+		TargetType: models.AdminTargetUser,
+		TargetID:   user.ID,
+	}, before, user.ToUserResponse())
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "User status updated successfully",
+		Data:    user.ToUserResponse(),
+	})
+}
+
+// GetUserLogins godoc
+// @Summary Get a user's login history
+// @Description Get the recent sign-in attempts of a user, successful and failed, newest first (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse{data=[]models.LoginRecord} "Login history retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "User not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/logins [get]
+func (h *UserHandler) GetUserLogins(c *gin.Context) {
+	userID := c.Param("id")
+
+	if _, err := h.storageService.GetUser(c.Request.Context(), userID); err != nil {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.UserNotFound, "User not found"))
+		return
+	}
+
+	loginHistoryResponse(c, h.storageService, userID)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUserRole(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	// A help desk role manages users but holds nothing else beyond a user's
+	helpdesk := &models.Role{
+		Name:        "helpdesk",
+		Permissions: append([]string{models.PermUsersAdmin}, models.DefaultRoles[models.RoleUser].Permissions...),
+	}
+	require.NoError(t, api.storage.SaveRole(ctx, helpdesk))
+	_, deskToken := api.user("desk", helpdesk.Name)
+	target, _ := api.user("target", models.RoleUser)
+	path := "/api/v1/users/" + target.ID
+
+	w := api.do(http.MethodPut, path, deskToken, map[string]string{"role": models.RoleAdmin})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, string(apierr.PermissionDenied), errorCode(t, w))
+	stored, err := api.storage.GetUser(ctx, target.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, stored.Role)
+
+	// A role granting nothing the caller lacks can be assigned
+	w = api.do(http.MethodPut, path, deskToken, map[string]string{"role": helpdesk.Name})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Role admins assign any role
+	_, adminToken := api.user("root", models.RoleAdmin)
+	w = api.do(http.MethodPut, path, adminToken, map[string]string{"role": models.RoleAdmin})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
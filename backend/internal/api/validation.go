@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Validation errors name fields as clients send them, by their JSON names
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(jsonName)
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// bindError converts an error from binding a request into a response.
+// Broken rules and values of the wrong type are listed per field; a body
+// that cannot be parsed at all is reported as a whole.
+func bindError(err error) *apierr.Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]models.FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, fieldError(fieldErr))
+		}
+		return validationError(fields)
+	case errors.As(err, &typeErr):
+		name := typeErr.Field
+		if name == "" {
+			return apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Request body must be a JSON "+typeErr.Type.Kind().String())
+		}
+		return validationError([]models.FieldError{{
+			Name:    name,
+			Rule:    "type",
+			Message: name + " must be " + typeName(typeErr.Type),
+			Key:     "type",
+			Args:    map[string]string{"type": typeKey(typeErr.Type)},
+		}})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return apierr.New(http.StatusBadRequest, apierr.InvalidRequest, "Request body is required")
+	}
+	return apierr.New(http.StatusBadRequest, apierr.InvalidRequest, err.Error())
+}
+
+func validationError(fields []models.FieldError) *apierr.Error {
+	message := fields[0].Message
+	if len(fields) > 1 {
+		message = "Request has invalid fields"
+	}
+	e := apierr.New(http.StatusBadRequest, apierr.ValidationFailed, message)
+	e.Fields = fields
+	return e
+}
+
+// fieldError describes a broken binding rule
+func fieldError(fieldErr validator.FieldError) models.FieldError {
+	// The namespace starts with the type of the bound struct
+	_, name, _ := strings.Cut(fieldErr.Namespace(), ".")
+	if name == "" {
+		name = fieldErr.Field()
+	}
+	key, args := ruleKey(fieldErr)
+	return models.FieldError{
+		Name:    name,
+		Rule:    fieldErr.Tag(),
+		Message: name + " " + ruleMessage(fieldErr),
+		Key:     key,
+		Args:    args,
+	}
+}
+
+// ruleMessage says what a rule demands, such as "must be at most 200
+// characters"
+func ruleMessage(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "required_without", "required_without_all":
+		return "is required unless " + fieldNames(param, " or ") + " is given"
+	case "email":
+		return "must be an email address"
+	case "uuid":
+		return "must be a UUID"
+	case "url":
+		return "must be a URL"
+	case "printascii":
+		return "must contain only printable ASCII characters"
+	case "bcp47_language_tag":
+		return "must be a language tag such as en or pt-BR"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "excludes":
+		return "must not contain " + param
+	case "min", "gte":
+		return "must be at least " + param + unit(fieldErr.Kind(), param)
+	case "max", "lte":
+		return "must be at most " + param + unit(fieldErr.Kind(), param)
+	case "len":
+		return "must be exactly " + param + unit(fieldErr.Kind(), param)
+	}
+	return "does not satisfy " + fieldErr.Tag()
+}
+
+// ruleKey names the translation of what a rule demands, as ruleMessage
+// says it in English, and the values it fills in
+func ruleKey(fieldErr validator.FieldError) (string, map[string]string) {
+	param := fieldErr.Param()
+	switch fieldErr.Tag() {
+	case "required", "email", "uuid", "url", "printascii", "bcp47_language_tag":
+		return fieldErr.Tag(), nil
+	case "required_without", "required_without_all":
+		return "required_without", map[string]string{"param": fieldNames(param, ", ")}
+	case "oneof":
+		return "oneof", map[string]string{"param": strings.Join(strings.Fields(param), ", ")}
+	case "excludes":
+		return "excludes", map[string]string{"param": param}
+	case "min", "gte":
+		return "min", limitArgs(fieldErr.Kind(), param)
+	case "max", "lte":
+		return "max", limitArgs(fieldErr.Kind(), param)
+	case "len":
+		return "len", limitArgs(fieldErr.Kind(), param)
+	}
+	return "invalid", map[string]string{"param": fieldErr.Tag()}
+}
+
+func limitArgs(kind reflect.Kind, param string) map[string]string {
+	return map[string]string{"param": param, "unit": strings.TrimSpace(unit(kind, param))}
+}
+
+// unit names what a length limit counts for values of kind
+func unit(kind reflect.Kind, param string) string {
+	plural := "s"
+	if param == "1" {
+		plural = ""
+	}
+	switch kind {
+	case reflect.String:
+		return " character" + plural
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " item" + plural
+	}
+	return ""
+}
+
+// fieldNames turns the Go field names of a rule parameter into JSON-style
+// names joined by sep
+func fieldNames(param, sep string) string {
+	names := strings.Fields(param)
+	for i, name := range names {
+		names[i] = strings.ToLower(name[:1]) + name[1:]
+	}
+	return strings.Join(names, sep)
+}
+
+// typeKey names the kind of JSON value of type t for translation, as
+// typeName describes it in English
+func typeKey(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
+
+// typeName describes a JSON value of type t
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/register", func(c *gin.Context) {
+		var req models.RegisterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/posts", func(c *gin.Context) {
+		var req models.CreatePostRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/settings", func(c *gin.Context) {
+		var req models.UpdateUserSettingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	post := func(path, body string) (int, models.ErrorResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var response models.ErrorResponse
+		if w.Code != http.StatusNoContent {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	status, response := post("/register", `{"username":"ab","email":"not-an-email","password":"secret"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, string(apierr.ValidationFailed), response.ErrorCode)
+	assert.Equal(t, []models.FieldError{
+		{Name: "username", Rule: "min", Message: "username must be at least 3 characters"},
+		{Name: "email", Rule: "email", Message: "email must be an email address"},
+		{Name: "firstName", Rule: "required", Message: "firstName is required"},
+		{Name: "lastName", Rule: "required", Message: "lastName is required"},
+	}, response.Fields)
+
+	// Usernames cannot be mistaken for email addresses at sign-in
+	status, response = post("/register", `{"username":"bob@example.com","email":"bob@example.com","password":"secret","firstName":"Bob","lastName":"Smith"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []models.FieldError{
+		{Name: "username", Rule: "excludes", Message: "username must not contain @"},
+	}, response.Fields)
+
+	status, response = post("/posts", `{"title":"Hello","content":"Hi","status":"hidden","tags":["go",""]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []models.FieldError{
+		{Name: "tags[1]", Rule: "min", Message: "tags[1] must be at least 1 character"},
+		{Name: "status", Rule: "oneof", Message: "status must be one of draft, published, archived"},
+	}, response.Fields)
+
+	// A single broken field is also the message
+	status, response = post("/posts", `{"title":42}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "title must be a string", response.Message)
+	assert.Equal(t, "type", response.Fields[0].Rule)
+
+	status, response = post("/posts", `{"title":`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, string(apierr.InvalidRequest), response.ErrorCode)
+	assert.Empty(t, response.Fields)
+
+	status, _ = post("/posts", `{"title":"Hello","content":"Hi","status":"draft","tags":["go"]}`)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, response = post("/settings", `{"theme":"neon","locale":"not a locale"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []models.FieldError{
+		{Name: "theme", Rule: "oneof", Message: "theme must be one of system, light, dark"},
+		{Name: "locale", Rule: "bcp47_language_tag", Message: "locale must be a language tag such as en or pt-BR"},
+	}, response.Fields)
+
+	status, _ = post("/settings", `{"locale":"pt-BR","notifications":{"digest":false}}`)
+	assert.Equal(t, http.StatusNoContent, status)
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/posts", func(c *gin.Context) {
+		var req models.CreatePostRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, bindError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/posts/:id", func(c *gin.Context) {
+		respondError(c, apierr.New(http.StatusNotFound, apierr.PostNotFound, "Post not found"))
+	})
+	router.GET("/files/:id", func(c *gin.Context) {
+		respondError(c, apierr.New(http.StatusForbidden, apierr.PermissionDenied, "Missing permission files:admin"))
+	})
+
+	send := func(method, path, body, acceptLanguage string) (*httptest.ResponseRecorder, models.ErrorResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Accept-Language", acceptLanguage)
+		router.ServeHTTP(w, req)
+		var response models.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w, response
+	}
+
+	w, response := send(http.MethodGet, "/posts/1", "", "es-MX,es;q=0.9,en;q=0.5")
+	assert.Equal(t, "Publicación no encontrada", response.Message)
+	assert.Equal(t, string(apierr.PostNotFound), response.ErrorCode, "codes stay as they are")
+	assert.Equal(t, "es", w.Header().Get("Content-Language"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+
+	// Messages without a translation get the general one of their code
+	_, response = send(http.MethodGet, "/files/1", "", "fr")
+	assert.Equal(t, "Vous n'avez pas l'autorisation de faire cela", response.Message)
+
+	_, response = send(http.MethodPost, "/posts", `{"title":"Hello","content":"Hi","status":"hidden","tags":["go",""]}`, "fr-CA")
+	assert.Equal(t, "La requête contient des champs non valides", response.Message)
+	assert.Equal(t, []models.FieldError{
+		{Name: "tags[1]", Rule: "min", Message: "tags[1] doit être d'au moins 1 caractère"},
+		{Name: "status", Rule: "oneof", Message: "status doit être l'une des valeurs draft, published, archived"},
+	}, response.Fields)
+
+	_, response = send(http.MethodPost, "/posts", `{"title":42}`, "es")
+	assert.Equal(t, "title debe ser una cadena", response.Message)
+
+	// Unsupported languages and English get the messages as written
+	w, response = send(http.MethodGet, "/posts/1", "", "de-DE,en;q=0.8")
+	assert.Equal(t, "Post not found", response.Message)
+	assert.Empty(t, w.Header().Get("Content-Language"))
+	_, response = send(http.MethodGet, "/posts/1", "", "de-DE,es;q=0.8")
+	assert.Equal(t, "Publicación no encontrada", response.Message)
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/webhook"
+)
+
+// WebhookHandler manages the webhooks domain events are sent to
+type WebhookHandler struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+	enabled        bool // the event stream webhooks are sent from is set up
+	allowPrivate   bool // receivers may be on addresses that are not public
+}
+
+func NewWebhookHandler(storageService *services.StorageService, messagingClient *messaging.Client, enabled, allowPrivate bool) *WebhookHandler {
+	return &WebhookHandler{
+		storageService: storageService,
+		messaging:      messagingClient,
+		enabled:        enabled,
+		allowPrivate:   allowPrivate,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary Create webhook
+// @Description Register a URL that domain events are posted to (admin only). events takes event types such as post.published, every type of a kind such as file.*, or * for all. Deliveries are signed with HMAC-SHA256 using secret, which is generated when not given and not shown again. Receivers on loopback, private and link-local addresses are refused unless WEBHOOK_ALLOW_PRIVATE is set.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateWebhookRequest true "Webhook"
+// @Success 201 {object} models.SuccessResponse{data=models.WebhookSecretResponse} "Webhook created successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format, URL or event type"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+	if !h.checkWebhook(c, req.URL, req.Events) {
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create webhook"))
+			return
+		}
+	}
+
+	hook := &models.Webhook{
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+		Secret:      secret,
+		CreatedBy:   c.GetString("userID"),
+	}
+	if err := h.storageService.CreateWebhook(c.Request.Context(), hook); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to create webhook"))
+		return
+	}
+	h.recordChange(c, nil, hook, "created", models.AdminWebhookCreate)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Webhook created successfully",
+		Data: models.WebhookSecretResponse{
+			Webhook: hook.ForResponse(),
+			Secret:  secret,
+		},
+	})
+}
+
+// ListWebhooks godoc
+// @Summary List webhooks
+// @Description List all webhooks, oldest first, without their secrets (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.Webhook} "Webhooks retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	webhooks, err := h.storageService.ListWebhooks(c.Request.Context())
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list webhooks"))
+		return
+	}
+
+	for i, hook := range webhooks {
+		webhooks[i] = hook.ForResponse()
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhooks retrieved successfully",
+		Data:    webhooks,
+	})
+}
+
+// GetWebhook godoc
+// @Summary Get webhook
+// @Description Get a webhook without its secret (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Webhook} "Webhook retrieved successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook retrieved successfully",
+		Data:    hook.ForResponse(),
+	})
+}
+
+// UpdateWebhook godoc
+// @Summary Update webhook
+// @Description Change the URL, events, description or secret of a webhook, or disable it so no more events are sent to it (admin only). Deliveries already queued for a disabled webhook fail.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Param request body models.UpdateWebhookRequest true "Fields to change"
+// @Success 200 {object} models.SuccessResponse{data=models.Webhook} "Webhook updated successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format, URL or event type"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	before := hook.ForResponse()
+
+	if req.URL != nil {
+		hook.URL = *req.URL
+	}
+	if req.Events != nil {
+		hook.Events = req.Events
+	}
+	if !h.checkWebhook(c, hook.URL, hook.Events) {
+		return
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.Disabled != nil {
+		hook.Disabled = *req.Disabled
+	}
+
+	if err := h.storageService.SaveWebhook(c.Request.Context(), hook); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to update webhook"))
+		return
+	}
+	h.recordChange(c, before, hook, "updated", models.AdminWebhookUpdate)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook updated successfully",
+		Data:    hook.ForResponse(),
+	})
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Delete a webhook and its delivery log; queued deliveries are dropped (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.SuccessResponse "Webhook deleted successfully"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.DeleteWebhook(c.Request.Context(), hook.ID); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to delete webhook"))
+		return
+	}
+	h.recordChange(c, hook, nil, "deleted", models.AdminWebhookDelete)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description List the deliveries of a webhook, newest first, with the outcome of their last attempt (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Param status query string false "Delivery status (pending, retrying, succeeded, failed)"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(10)
+// @Success 200 {object} models.ListResponse{data=[]models.WebhookDelivery} "Deliveries retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid status"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	pagination := c.MustGet("pagination").(models.Pagination)
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryRetrying, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		respondError(c, validationError([]models.FieldError{{Name: "status", Rule: "oneof", Message: "status must be one of pending, retrying, succeeded, failed"}}))
+		return
+	}
+
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveries, total, err := h.storageService.ListWebhookDeliveries(c.Request.Context(), hook.ID, status, pagination)
+	if err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to list deliveries"))
+		return
+	}
+
+	pagination.Total = total
+
+	c.JSON(http.StatusOK, models.ListResponse{
+		Data:       deliveries,
+		Pagination: pagination,
+	})
+}
+
+// RedeliverDelivery godoc
+// @Summary Redeliver webhook delivery
+// @Description Send the event of a delivery to its webhook again as a new delivery, with its own attempts and the same event ID, so receivers can tell it is a repeat (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} models.SuccessResponse{data=models.WebhookDelivery} "Redelivery queued"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Forbidden"
+// @Failure 404 {object} models.ErrorResponse "Webhook or delivery not found"
+// @Failure 409 {object} models.ErrorResponse "Webhook is disabled"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "Webhooks are not available"
+// @Router /admin/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	if hook.Disabled {
+		respondError(c, apierr.New(http.StatusConflict, apierr.Conflict, "Webhook is disabled"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	original, err := h.storageService.GetWebhookDelivery(ctx, hook.ID, c.Param("deliveryId"))
+	if err != nil {
+		respondError(c, storageError(err, "Failed to load delivery"))
+		return
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:           services.NewDeliveryID(now, uuid.New().String()),
+		WebhookID:    hook.ID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		Payload:      original.Payload,
+		Status:       models.DeliveryPending,
+		RedeliveryOf: original.ID,
+		CreatedAt:    now,
+	}
+	if err := h.storageService.QueueWebhookDelivery(ctx, h.messaging, delivery); err != nil {
+		respondError(c, apierr.New(http.StatusInternalServerError, apierr.Internal, "Failed to queue redelivery"))
+		return
+	}
+	h.recordChange(c, hook, hook, "redelivered", models.AdminWebhookRedeliver)
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Message: "Redelivery queued",
+		Data:    delivery,
+	})
+}
+
+// available writes a 503 response when webhooks cannot be sent because
+// the event stream is off
+func (h *WebhookHandler) available(c *gin.Context) bool {
+	if !h.enabled {
+		respondError(c, apierr.New(http.StatusServiceUnavailable, apierr.FeatureUnavailable, "Webhooks are not available"))
+		return false
+	}
+	return true
+}
+
+// loadWebhook loads the webhook named by the id parameter, writing an
+// error response when that fails
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	if !h.available(c) {
+		return nil, false
+	}
+
+	hook, err := h.storageService.GetWebhook(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, storageError(err, "Failed to load webhook"))
+		return nil, false
+	}
+	return hook, true
+}
+
+// recordChange records a change of a webhook, which is before and after
+// the change, nil when it was created or deleted. Snapshots leave out the
+// secret.
+func (h *WebhookHandler) recordChange(c *gin.Context, before, after *models.Webhook, action, adminAction string) {
+	hook := after
+	if hook == nil {
+		hook = before
+	}
+	recordAudit(h.messaging, c, models.AuditEvent{
+		Type:    models.AuditWebhook,
+		ActorID: c.GetString("userID"),
+		Details: map[string]string{"webhook": hook.ID, "url": hook.URL, "action": action},
+	})
+
+	var beforeSnapshot, afterSnapshot any
+	if before != nil {
+		beforeSnapshot = before.ForResponse()
+	}
+	if after != nil {
+		afterSnapshot = after.ForResponse()
+	}
+	recordAdminAction(h.messaging, c, models.AdminAction{
+		Action:     adminAction,
+		TargetType: models.AdminTargetWebhook,
+		TargetID:   hook.ID,
+	}, beforeSnapshot, afterSnapshot)
+}
+
+// checkWebhook writes a 400 response unless rawURL is an http or https URL
+// and every event pattern names known event types. Unless private
+// receivers are allowed, URLs naming localhost or an address that is not
+// public are refused too; other names are checked when sending.
+func (h *WebhookHandler) checkWebhook(c *gin.Context, rawURL string, events []string) bool {
+	var fields []models.FieldError
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fields = append(fields, models.FieldError{Name: "url", Rule: "url", Message: "url must be an http or https URL"})
+	} else if !h.allowPrivate && privateHost(u.Hostname()) {
+		fields = append(fields, models.FieldError{Name: "url", Rule: "public", Message: "url must not point to a loopback, private or link-local address"})
+	}
+	for _, pattern := range events {
+		if !webhook.ValidPattern(pattern) {
+			fields = append(fields, models.FieldError{Name: "events", Rule: "event", Message: "Unknown event type " + pattern})
+			break
+		}
+	}
+	if len(fields) > 0 {
+		respondError(c, validationError(fields))
+		return false
+	}
+	return true
+}
+
+// privateHost reports whether host is localhost or an address that may
+// not receive webhooks
+func privateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && !webhook.PublicAddress(ip)
+}
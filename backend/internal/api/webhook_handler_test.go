@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/apierr"
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookPrivateURLs(t *testing.T) {
+	api := newTestAPI(t)
+	_, admin := api.user("root", models.RoleAdmin)
+	create := func(url string) int {
+		t.Helper()
+		w := api.do(http.MethodPost, "/api/v1/admin/webhooks", admin, map[string]any{"url": url, "events": []string{"*"}})
+		if w.Code == http.StatusBadRequest {
+			assert.Equal(t, string(apierr.ValidationFailed), errorCode(t, w), url)
+		}
+		return w.Code
+	}
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		assert.Equal(t, http.StatusBadRequest, create(url), url)
+	}
+	assert.Equal(t, http.StatusCreated, create("https://hooks.example.com/receive"))
+	assert.Equal(t, http.StatusCreated, create("http://203.0.113.7/receive"))
+
+	// Changing the URL of a webhook is checked the same way
+	w := api.do(http.MethodGet, "/api/v1/admin/webhooks", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var hooks []models.Webhook
+	decode(t, w, &hooks)
+	require.NotEmpty(t, hooks)
+	w = api.do(http.MethodPatch, "/api/v1/admin/webhooks/"+hooks[0].ID, admin, map[string]string{"url": "http://127.0.0.1/hook"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Unless receivers inside the network are allowed
+	api = newTestAPI(t, func(cfg *config.Config) { cfg.Webhook.AllowPrivate = true })
+	_, admin = api.user("root", models.RoleAdmin)
+	assert.Equal(t, http.StatusCreated, create("http://127.0.0.1:8080/hook"))
+}
@@ -0,0 +1,172 @@
+// Package apierr defines the errors the API responds with. Each carries a
+// stable, machine-readable code, so clients can tell errors apart without
+// matching their messages, which may change.
+package apierr
+
+import (
+	"net/http"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Code identifies the kind of an error. Codes are part of the API: once
+// published they keep their meaning.
+type Code string
+
+// Generic codes, used when nothing more specific applies
+const (
+	BadRequest           Code = "BAD_REQUEST"
+	InvalidRequest       Code = "INVALID_REQUEST"   // the body or query could not be parsed
+	ValidationFailed     Code = "VALIDATION_FAILED" // fields broke their rules; see the fields of the response
+	Unauthorized         Code = "UNAUTHORIZED"
+	Forbidden            Code = "FORBIDDEN"
+	NotFound             Code = "NOT_FOUND"
+	Conflict             Code = "CONFLICT"
+	Gone                 Code = "GONE"
+	PreconditionFailed   Code = "PRECONDITION_FAILED"
+	PayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	RateLimited          Code = "RATE_LIMITED"
+	Internal             Code = "INTERNAL"
+	UpstreamFailed       Code = "UPSTREAM_FAILED" // a provider, directory or CAPTCHA service failed
+	Unavailable          Code = "UNAVAILABLE"     // a dependency is down; retrying later can help
+	Timeout              Code = "TIMEOUT"         // the request ran out of time
+	Maintenance          Code = "MAINTENANCE"     // the server is read-only for maintenance
+	FeatureUnavailable   Code = "FEATURE_UNAVAILABLE"
+)
+
+// Authentication and accounts
+const (
+	TokenMissing           Code = "TOKEN_MISSING"
+	TokenInvalid           Code = "TOKEN_INVALID"
+	TokenRevoked           Code = "TOKEN_REVOKED"
+	InvalidCredentials     Code = "INVALID_CREDENTIALS"
+	InvalidRefreshToken    Code = "INVALID_REFRESH_TOKEN"
+	InvalidServiceKey      Code = "INVALID_SERVICE_KEY"
+	InvalidResetToken      Code = "INVALID_RESET_TOKEN"
+	InvalidVerifyToken     Code = "INVALID_VERIFICATION_TOKEN"
+	EmailAlreadyVerified   Code = "EMAIL_ALREADY_VERIFIED"
+	AccountDeleted         Code = "ACCOUNT_DELETED"
+	AccountInactive        Code = "ACCOUNT_INACTIVE" // suspended or deactivated
+	PasswordIncorrect      Code = "PASSWORD_INCORRECT"
+	PasswordUnchanged      Code = "PASSWORD_UNCHANGED"
+	WeakPassword           Code = "WEAK_PASSWORD"
+	CaptchaRequired        Code = "CAPTCHA_REQUIRED"
+	InvitationRequired     Code = "INVITATION_REQUIRED"
+	InvitationInvalid      Code = "INVITATION_INVALID" // unknown, used, expired or for another email
+	EmailTaken             Code = "EMAIL_TAKEN"
+	UsernameTaken          Code = "USERNAME_TAKEN"
+	LoginFailed            Code = "LOGIN_FAILED" // a social login could not be completed
+	PermissionDenied       Code = "PERMISSION_DENIED"
+	NotOwner               Code = "NOT_OWNER"         // the resource belongs to another user
+	OrgRoleRequired        Code = "ORG_ROLE_REQUIRED" // the caller's role in the organization does not allow it
+	ImpersonationForbidden Code = "IMPERSONATION_FORBIDDEN"
+	ServiceAccountDenied   Code = "SERVICE_ACCOUNT_DENIED"
+	AddressDenied          Code = "ADDRESS_DENIED" // the client's IP address may not use the route
+)
+
+// Missing resources
+const (
+	UserNotFound           Code = "USER_NOT_FOUND"
+	PostNotFound           Code = "POST_NOT_FOUND"
+	FileNotFound           Code = "FILE_NOT_FOUND"
+	VersionNotFound        Code = "VERSION_NOT_FOUND"
+	UploadNotFound         Code = "UPLOAD_NOT_FOUND"
+	ShareNotFound          Code = "SHARE_NOT_FOUND"
+	RoleNotFound           Code = "ROLE_NOT_FOUND"
+	InvitationNotFound     Code = "INVITATION_NOT_FOUND"
+	ServiceAccountNotFound Code = "SERVICE_ACCOUNT_NOT_FOUND"
+	KeyNotFound            Code = "KEY_NOT_FOUND"
+	WebhookNotFound        Code = "WEBHOOK_NOT_FOUND"
+	DeliveryNotFound       Code = "DELIVERY_NOT_FOUND"
+	ProviderNotFound       Code = "PROVIDER_NOT_FOUND"
+	ThumbnailNotAvailable  Code = "THUMBNAIL_NOT_AVAILABLE"
+	PreviewNotAvailable    Code = "PREVIEW_NOT_AVAILABLE"
+	StreamNotAvailable     Code = "STREAM_NOT_AVAILABLE"
+	TaskNotFound           Code = "TASK_NOT_FOUND"
+	NotificationNotFound   Code = "NOTIFICATION_NOT_FOUND"
+	UsageReportNotFound    Code = "USAGE_REPORT_NOT_FOUND" // no usage report was made yet
+	OrganizationNotFound   Code = "ORGANIZATION_NOT_FOUND"
+	MemberNotFound         Code = "MEMBER_NOT_FOUND"
+	CommentNotFound        Code = "COMMENT_NOT_FOUND"
+	SeriesNotFound         Code = "SERIES_NOT_FOUND"
+	ReportNotFound         Code = "REPORT_NOT_FOUND"
+	MetadataSchemaNotFound Code = "METADATA_SCHEMA_NOT_FOUND"
+)
+
+// Files, shares and roles
+const (
+	FileTooLarge         Code = "FILE_TOO_LARGE"
+	BatchTooLarge        Code = "BATCH_TOO_LARGE"
+	RequestTooLarge      Code = "REQUEST_TOO_LARGE"
+	FileTypeNotAllowed   Code = "FILE_TYPE_NOT_ALLOWED"
+	ScanPending          Code = "SCAN_PENDING"
+	FileQuarantined      Code = "FILE_QUARANTINED"
+	FileExpired          Code = "FILE_EXPIRED"
+	TranscodePending     Code = "TRANSCODE_PENDING"
+	UploadFinalized      Code = "UPLOAD_FINALIZED"
+	ETagMismatch         Code = "ETAG_MISMATCH"
+	ShareExpired         Code = "SHARE_EXPIRED"
+	SharePassword        Code = "SHARE_PASSWORD_REQUIRED"
+	RoleExists           Code = "ROLE_EXISTS"
+	RoleInUse            Code = "ROLE_IN_USE"
+	RoleProtected        Code = "ROLE_PROTECTED"         // built-in roles and the admin role's permissions
+	MetadataSchemaExists Code = "METADATA_SCHEMA_EXISTS" // by name, or another schema covers the same folder
+)
+
+// Reports
+const (
+	AlreadyReported Code = "ALREADY_REPORTED" // the caller's report of the content is still open
+)
+
+// Scheduled tasks
+const (
+	TaskRunning Code = "TASK_RUNNING"
+)
+
+// Organizations
+const (
+	LastOwner            Code = "LAST_OWNER"             // an organization must keep an owner
+	OrganizationNotEmpty Code = "ORGANIZATION_NOT_EMPTY" // it still has shared posts or files
+)
+
+// Error is an error response: an HTTP status, a code and a message for
+// people. The cause, if any, is for logs and never sent.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  []models.FieldError
+	Err     error
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an error response caused by err
+func Wrap(err error, status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Response returns the body sent for e
+func (e *Error) Response() models.ErrorResponse {
+	return models.ErrorResponse{
+		Error:     http.StatusText(e.Status),
+		Message:   e.Message,
+		Code:      e.Status,
+		ErrorCode: string(e.Code),
+		Fields:    e.Fields,
+	}
+}
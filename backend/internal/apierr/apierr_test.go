@@ -0,0 +1,27 @@
+package apierr
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("bucket unreachable")
+	err := Wrap(cause, http.StatusInternalServerError, Internal, "Failed to load user")
+
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "Failed to load user: bucket unreachable", err.Error())
+
+	response := err.Response()
+	assert.Equal(t, "Internal Server Error", response.Error)
+	assert.Equal(t, "Failed to load user", response.Message)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, "INTERNAL", response.ErrorCode)
+
+	var target *Error
+	assert.True(t, errors.As(error(New(http.StatusNotFound, UserNotFound, "User not found")), &target))
+	assert.Equal(t, UserNotFound, target.Code)
+}
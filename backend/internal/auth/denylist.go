@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Denylist records revoked access tokens in Redis until they would have
+// expired anyway. Single tokens are revoked by their jti claim; all tokens
+// of a user are revoked by remembering a cut-off before which tokens are
+// no longer accepted, which also applies to S3 credentials.
+type Denylist struct {
+	client *redis.Client
+	ttl    time.Duration // longest lifetime of the credentials checked
+}
+
+func NewDenylist(client *redis.Client, ttl time.Duration) *Denylist {
+	return &Denylist{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Revoke rejects the token with the given claims from now on
+func (d *Denylist) Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return errors.New("token has no jti or expiry")
+	}
+
+	remaining := time.Until(claims.ExpiresAt.Time)
+	if remaining <= 0 {
+		return nil
+	}
+
+	if err := d.client.Set(ctx, revokedTokenKey(claims.ID), 1, remaining).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser rejects every token of userID issued up to and including the
+// millisecond of at. It returns once that millisecond has passed, so tokens
+// issued afterwards, such as those of the next login, are accepted.
+func (d *Denylist) RevokeUser(ctx context.Context, userID string, at time.Time) error {
+	cutoff := at.Truncate(time.Millisecond).Add(time.Millisecond)
+	err := d.client.Set(ctx, revokedUserKey(userID), cutoff.UnixMilli(), d.ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	time.Sleep(time.Until(cutoff))
+	return nil
+}
+
+// IsRevoked reports whether the token with the given claims was revoked.
+// Revoking the tokens of an admin also revokes their impersonation tokens.
+func (d *Denylist) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	users := []string{claims.UserID}
+	if claims.Act != nil {
+		users = append(users, claims.Act.UserID)
+	}
+
+	keys := []string{revokedTokenKey(claims.ID)}
+	for _, userID := range users {
+		keys = append(keys, revokedUserKey(userID))
+	}
+	values, err := d.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	if claims.ID != "" && values[0] != nil {
+		return true, nil
+	}
+
+	for i, userID := range users {
+		if claims.IssuedAt == nil {
+			continue
+		}
+		revoked, err := revokedAt(values[i+1], userID, claims.Issued())
+		if revoked || err != nil {
+			return revoked, err
+		}
+	}
+
+	return false, nil
+}
+
+// IsUserRevoked reports whether credentials of userID issued at issuedAt
+// were revoked with RevokeUser
+func (d *Denylist) IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	cutoff, err := d.client.Get(ctx, revokedUserKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check credential revocation: %w", err)
+	}
+	return revokedAt(cutoff, userID, issuedAt)
+}
+
+// revokedAt reports whether credentials issued at issuedAt are revoked by
+// the cut-off stored for userID, if any: the first millisecond from which
+// credentials are accepted again
+func revokedAt(cutoff any, userID string, issuedAt time.Time) (bool, error) {
+	value, ok := cutoff.(string)
+	if !ok {
+		return false, nil
+	}
+	before, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid revocation cut-off for user %s: %w", userID, err)
+	}
+	// Cut-offs stored before they were kept to the millisecond are the last
+	// revoked second, which has ten digits until 2286
+	if len(value) <= 10 {
+		return issuedAt.Unix() <= before, nil
+	}
+	return issuedAt.UnixMilli() < before, nil
+}
+
+func revokedTokenKey(jti string) string {
+	return "jwt:revoked:" + jti
+}
+
+func revokedUserKey(userID string) string {
+	return "jwt:revoked-before:" + userID
+}
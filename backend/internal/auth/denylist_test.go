@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylist(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	denylist := NewDenylist(client, 15*time.Minute)
+	jwtManager := NewJWTManager("test-secret", 15)
+	ctx := context.Background()
+
+	issue := func(userID string) *Claims {
+		token, err := jwtManager.GenerateToken(userID, "name", "mail@example.com", "user")
+		require.NoError(t, err)
+		claims, err := jwtManager.ValidateToken(token)
+		require.NoError(t, err)
+		return claims
+	}
+
+	first := issue("user-1")
+	second := issue("user-1")
+	require.NotEqual(t, first.ID, second.ID)
+
+	require.NoError(t, denylist.Revoke(ctx, first))
+	revoked, err := denylist.IsRevoked(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = denylist.IsRevoked(ctx, second)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, denylist.RevokeUser(ctx, "user-1", time.Now()))
+	revoked, err = denylist.IsRevoked(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = denylist.IsRevoked(ctx, issue("user-2"))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	// Signing in again right away works, even within the same second
+	revoked, err = denylist.IsRevoked(ctx, issue("user-1"))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// S3 credentials are checked by their issue time
+	revoked, err = denylist.IsUserRevoked(ctx, "user-1", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = denylist.IsUserRevoked(ctx, "user-1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = denylist.IsUserRevoked(ctx, "user-2", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Cut-offs stored in seconds still revoke the whole second
+	require.NoError(t, server.Set(revokedUserKey("user-3"), strconv.FormatInt(time.Now().Unix(), 10)))
+	server.SetTTL(revokedUserKey("user-3"), 15*time.Minute)
+	revoked, err = denylist.IsRevoked(ctx, issue("user-3"))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Entries disappear once the tokens would have expired anyway
+	server.FastForward(16 * time.Minute)
+	assert.Empty(t, server.Keys())
+}
+
+func TestDenylistImpersonation(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	denylist := NewDenylist(client, 15*time.Minute)
+	jwtManager := NewJWTManager("test-secret", 15)
+	ctx := context.Background()
+
+	token, err := jwtManager.GenerateImpersonationToken("user-1", "name", "mail@example.com", "user", Actor{UserID: "admin-1", Username: "admin"}, time.Hour)
+	require.NoError(t, err)
+	claims, err := jwtManager.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "admin-1", claims.Act.UserID)
+	assert.Equal(t, 15*time.Minute, claims.ExpiresAt.Sub(claims.IssuedAt.Time), "bounded by the token TTL")
+
+	revoked, err := denylist.IsRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Signing the admin out ends the impersonation too
+	require.NoError(t, denylist.RevokeUser(ctx, "admin-1", time.Now()))
+	revoked, err = denylist.IsRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginFailures counts failed logins in Redis per login name and per client
+// address. The counters expire window after the first failure, so a burst
+// of failures is forgotten once the window has passed.
+type LoginFailures struct {
+	client *redis.Client
+	window time.Duration
+}
+
+func NewLoginFailures(client *redis.Client, window time.Duration) *LoginFailures {
+	return &LoginFailures{
+		client: client,
+		window: window,
+	}
+}
+
+// Count returns the larger of the failure counts of loginName and ip
+func (f *LoginFailures) Count(ctx context.Context, loginName, ip string) (int, error) {
+	counts, err := f.client.MGet(ctx, failureNameKey(loginName), failureIPKey(ip)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load login failures: %w", err)
+	}
+
+	highest := 0
+	for _, count := range counts {
+		value, ok := count.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest, nil
+}
+
+// RecordFailure counts a failed login for loginName from ip
+func (f *LoginFailures) RecordFailure(ctx context.Context, loginName, ip string) error {
+	pipe := f.client.TxPipeline()
+	for _, key := range []string{failureNameKey(loginName), failureIPKey(ip)} {
+		pipe.Incr(ctx, key)
+		pipe.ExpireNX(ctx, key, f.window)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count login failure: %w", err)
+	}
+	return nil
+}
+
+// Clear forgets the failures of loginName after a successful login. The
+// count for the address is kept, as one address guessing many accounts is
+// still suspicious.
+func (f *LoginFailures) Clear(ctx context.Context, loginName string) error {
+	if err := f.client.Del(ctx, failureNameKey(loginName)).Err(); err != nil {
+		return fmt.Errorf("failed to clear login failures: %w", err)
+	}
+	return nil
+}
+
+func failureNameKey(loginName string) string {
+	return "login:failures:name:" + strings.ToLower(loginName)
+}
+
+func failureIPKey(ip string) string {
+	return "login:failures:ip:" + ip
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginFailures(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	failures := NewLoginFailures(client, 15*time.Minute)
+	ctx := context.Background()
+
+	count, err := failures.Count(ctx, "alice", "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	require.NoError(t, failures.RecordFailure(ctx, "Alice", "203.0.113.7"))
+	require.NoError(t, failures.RecordFailure(ctx, "bob", "203.0.113.7"))
+
+	// Login names are counted case-insensitively, addresses across names
+	count, err = failures.Count(ctx, "alice", "198.51.100.1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = failures.Count(ctx, "carol", "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, failures.Clear(ctx, "alice"))
+	count, err = failures.Count(ctx, "alice", "198.51.100.1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	server.FastForward(16 * time.Minute)
+	count, err = failures.Count(ctx, "carol", "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteSigner(t *testing.T) {
+	signer := NewInviteSigner("secret")
+
+	token := signer.Sign("invite-1")
+	id, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "invite-1", id)
+
+	for _, forged := range []string{
+		"invite-2" + token[len("invite-1"):],
+		token + "x",
+		"invite-1",
+		"",
+		NewInviteSigner("other-secret").Sign("invite-1"),
+	} {
+		_, err := signer.Verify(forged)
+		assert.ErrorIs(t, err, ErrInvalidInviteToken, forged)
+	}
+}
@@ -1,16 +1,29 @@
 package auth
 
 import (
+	"crypto"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 )
 
+// JWTManager signs and validates access tokens, either with a shared
+// secret (HS256) or with a key pair (RS256 or ES256). Key pair tokens carry
+// the ID of their key so that tokens signed with earlier keys stay valid
+// while those keys are still configured for verification.
 type JWTManager struct {
 	secretKey  string
-	expiration int
+	expiration int // minutes
+
+	method     jwt.SigningMethod
+	signingKey any
+	keyID      string
+	publicKeys map[string]crypto.PublicKey // by key ID; nil with a shared secret
 }
 
 type Claims struct {
@@ -18,38 +31,179 @@ type Claims struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Role     string `json:"role"`
+	Act      *Actor `json:"act,omitempty"` // set when an admin impersonates the user
+	// IssuedAtMillis is iat to the millisecond, to compare with revocations
+	IssuedAtMillis int64 `json:"iatMs,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Issued returns when the token was issued, to the millisecond unless it
+// predates the iatMs claim
+func (c *Claims) Issued() time.Time {
+	if c.IssuedAtMillis != 0 {
+		return time.UnixMilli(c.IssuedAtMillis)
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// Actor is the admin acting as the user of an impersonation token, in the
+// style of the RFC 8693 act claim
+type Actor struct {
+	UserID   string `json:"sub"`
+	Username string `json:"username"`
+}
+
 func NewJWTManager(secretKey string, expiration int) *JWTManager {
 	return &JWTManager{
 		secretKey:  secretKey,
 		expiration: expiration,
+		method:     jwt.SigningMethodHS256,
+		signingKey: []byte(secretKey),
+	}
+}
+
+// NewKeyPairJWTManager signs tokens with the PEM encoded private key
+// signingKey. verifyKeys holds any number of PEM encoded public or private
+// keys that were used for signing before and whose tokens are still
+// accepted.
+func NewKeyPairJWTManager(signingKey, verifyKeys []byte, expiration int) (*JWTManager, error) {
+	keys, err := parsePEMKeys(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	signer, ok := keys[0].(crypto.Signer)
+	if len(keys) != 1 || !ok {
+		return nil, errors.New("signing key must be a single private key")
+	}
+
+	j := &JWTManager{
+		expiration: expiration,
+		signingKey: signer,
+		publicKeys: map[string]crypto.PublicKey{},
+	}
+	if err := j.addPublicKey(signer.Public()); err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	j.keyID = keyID(signer.Public())
+	j.method = jwt.GetSigningMethod(mustAlgorithm(signer.Public()))
+
+	if len(verifyKeys) > 0 {
+		keys, err := parsePEMKeys(verifyKeys)
+		if err != nil {
+			return nil, fmt.Errorf("verification keys: %w", err)
+		}
+		for _, key := range keys {
+			public, err := publicKey(key)
+			if err == nil {
+				err = j.addPublicKey(public)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("verification keys: %w", err)
+			}
+		}
+	}
+
+	return j, nil
+}
+
+// JWKS returns the verification keys, the signing key first. It is empty
+// when tokens are signed with a shared secret, which must not be published.
+func (j *JWTManager) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []map[string]string{}}
+	kids := slices.Sorted(maps.Keys(j.publicKeys))
+	if i := slices.Index(kids, j.keyID); i > 0 {
+		kids = append([]string{j.keyID}, slices.Delete(kids, i, i+1)...)
 	}
+
+	for _, kid := range kids {
+		key := j.publicKeys[kid]
+		jwk := publicJWK(key)
+		jwk["kid"] = kid
+		jwk["alg"] = mustAlgorithm(key)
+		jwk["use"] = "sig"
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (j *JWTManager) addPublicKey(key crypto.PublicKey) error {
+	if _, err := signingAlgorithm(key); err != nil {
+		return err
+	}
+	j.publicKeys[keyID(key)] = key
+	return nil
+}
+
+// mustAlgorithm is signingAlgorithm for keys that were already accepted
+func mustAlgorithm(key crypto.PublicKey) string {
+	alg, _ := signingAlgorithm(key)
+	return alg
+}
+
+// TTL is how long generated access tokens are valid
+func (j *JWTManager) TTL() time.Duration {
+	return time.Duration(j.expiration) * time.Minute
 }
 
 func (j *JWTManager) GenerateToken(userID, username, email, role string) (string, error) {
-	claims := &Claims{
+	return j.sign(&Claims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
 		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(j.expiration) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	}, j.TTL())
+}
+
+// GenerateImpersonationToken issues a token for the user that also names
+// actor, the admin acting as them. It is valid for ttl, at most the TTL of
+// ordinary tokens.
+func (j *JWTManager) GenerateImpersonationToken(userID, username, email, role string, actor Actor, ttl time.Duration) (string, error) {
+	return j.sign(&Claims{
+		UserID:   userID,
+		Username: username,
+		Email:    email,
+		Role:     role,
+		Act:      &actor,
+	}, min(ttl, j.TTL()))
+}
+
+func (j *JWTManager) sign(claims *Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAtMillis = now.UnixMilli()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(), // jti, used to revoke single tokens
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	token := jwt.NewWithClaims(j.method, claims)
+	if j.keyID != "" {
+		token.Header["kid"] = j.keyID
+	}
+	return token.SignedString(j.signingKey)
 }
 
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if j.publicKeys == nil {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return []byte(j.secretKey), nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.publicKeys[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != mustAlgorithm(key) {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(j.secretKey), nil
+		return key, nil
 	})
 
 	if err != nil {
@@ -62,12 +216,3 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
-
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
-
-func CheckPassword(password, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
@@ -1,8 +1,15 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -64,27 +71,109 @@ func TestJWTManager_ValidateInvalidToken(t *testing.T) {
 	assert.Error(t, err)
 }
 
-func TestHashPassword(t *testing.T) {
-	password := "testpassword123"
+func testKeyPEM(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
 
-	hashedPassword, err := HashPassword(password)
+func TestKeyPairJWTManager(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	require.NoError(t, err)
-	assert.NotEmpty(t, hashedPassword)
-	assert.NotEqual(t, password, hashedPassword)
+
+	for _, key := range []any{rsaKey, ecKey} {
+		jwtManager, err := NewKeyPairJWTManager(testKeyPEM(t, key), nil, 24)
+		require.NoError(t, err)
+
+		token, err := jwtManager.GenerateToken("123", "testuser", "test@example.com", "user")
+		require.NoError(t, err)
+
+		claims, err := jwtManager.ValidateToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, "123", claims.UserID)
+	}
 }
 
-func TestCheckPassword(t *testing.T) {
-	password := "testpassword123"
+func TestKeyPairJWTManagerRotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	oldManager, err := NewKeyPairJWTManager(testKeyPEM(t, oldKey), nil, 24)
+	require.NoError(t, err)
+	oldToken, err := oldManager.GenerateToken("123", "testuser", "test@example.com", "user")
+	require.NoError(t, err)
 
-	// Hash the password
-	hashedPassword, err := HashPassword(password)
+	// Only the public half of the old key is needed to verify its tokens
+	publicDER, err := x509.MarshalPKIXPublicKey(&oldKey.PublicKey)
 	require.NoError(t, err)
+	oldPublic := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
 
-	// Check correct password
-	err = CheckPassword(password, hashedPassword)
+	rotated, err := NewKeyPairJWTManager(testKeyPEM(t, newKey), oldPublic, 24)
+	require.NoError(t, err)
+	_, err = rotated.ValidateToken(oldToken)
 	assert.NoError(t, err)
 
-	// Check wrong password
-	err = CheckPassword("wrongpassword", hashedPassword)
+	retired, err := NewKeyPairJWTManager(testKeyPEM(t, newKey), nil, 24)
+	require.NoError(t, err)
+	_, err = retired.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestKeyPairJWTManagerRejectsSharedSecretTokens(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwtManager, err := NewKeyPairJWTManager(testKeyPEM(t, key), nil, 24)
+	require.NoError(t, err)
+
+	token, err := NewJWTManager("test-secret", 24).GenerateToken("123", "testuser", "test@example.com", "admin")
+	require.NoError(t, err)
+
+	_, err = jwtManager.ValidateToken(token)
 	assert.Error(t, err)
 }
+
+func TestNewKeyPairJWTManagerInvalidKeys(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = NewKeyPairJWTManager(testKeyPEM(t, smallKey), nil, 24)
+	assert.Error(t, err)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewKeyPairJWTManager(testKeyPEM(t, p384Key), nil, 24)
+	assert.Error(t, err)
+
+	_, err = NewKeyPairJWTManager([]byte("not a key"), nil, 24)
+	assert.Error(t, err)
+}
+
+func TestJWTManagerJWKS(t *testing.T) {
+	assert.Empty(t, NewJWTManager("test-secret", 24).JWKS().Keys, "secrets are never published")
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwtManager, err := NewKeyPairJWTManager(testKeyPEM(t, newKey), testKeyPEM(t, oldKey), 24)
+	require.NoError(t, err)
+
+	token, err := jwtManager.GenerateToken("123", "testuser", "test@example.com", "user")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+
+	keys := jwtManager.JWKS().Keys
+	require.Len(t, keys, 2)
+	assert.Equal(t, parsed.Header["kid"], keys[0]["kid"])
+	assert.Equal(t, "ES256", keys[0]["alg"])
+	assert.Equal(t, "P-256", keys[0]["crv"])
+	assert.Equal(t, "RS256", keys[1]["alg"])
+	assert.Equal(t, "AQAB", keys[1]["e"])
+	assert.NotContains(t, keys[1], "d", "private parts are never published")
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// parsePEMKeys parses every key block in data. Private keys may be PKCS#8,
+// PKCS#1 or SEC 1; public keys PKIX or PKCS#1.
+func parsePEMKeys(data []byte) ([]any, error) {
+	var keys []any
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key any
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	return keys, nil
+}
+
+// publicKey returns the public half of key
+func publicKey(key any) (crypto.PublicKey, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey, nil
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// signingAlgorithm is the JWT algorithm used with key: RS256 for RSA keys
+// and ES256 for P-256 keys
+func signingAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return "", errors.New("RSA keys must have at least 2048 bits")
+		}
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("ECDSA keys must use the P-256 curve")
+		}
+		return "ES256", nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// publicJWK returns the members of the JSON Web Key for key (RFC 7517)
+// that identify it, without kid, alg and use
+func publicJWK(key crypto.PublicKey) map[string]string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"crv": k.Curve.Params().Name,
+			"x":   base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			"y":   base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	return nil
+}
+
+// keyID derives the kid of key from its JWK thumbprint (RFC 7638), so the
+// same key always has the same ID wherever it is configured
+func keyID(key crypto.PublicKey) string {
+	// json.Marshal sorts map keys, giving the canonical member order
+	data, _ := json.Marshal(publicJWK(key))
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JSONWebKeySet lists the public keys access tokens are verified with
+// (RFC 7517), so that other services can validate tokens themselves
+type JSONWebKeySet struct {
+	Keys []map[string]string `json:"keys"`
+}
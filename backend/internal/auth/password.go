@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// PasswordHasher hashes passwords with the configured algorithm. Hashes of
+// either algorithm can be checked whatever the configuration, so stored
+// hashes keep working when it changes.
+type PasswordHasher struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// NewPasswordHasher returns the hasher cfg configures
+func NewPasswordHasher(cfg config.AuthConfig) (*PasswordHasher, error) {
+	hasher := &PasswordHasher{
+		Algorithm:         cfg.PasswordHashAlgorithm,
+		BcryptCost:        cfg.BcryptCost,
+		Argon2Memory:      uint32(cfg.Argon2Memory),
+		Argon2Iterations:  uint32(cfg.Argon2Iterations),
+		Argon2Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+	if err := hasher.Validate(); err != nil {
+		return nil, err
+	}
+	return hasher, nil
+}
+
+// Validate reports settings that cannot produce a hash
+func (h *PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case HashBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		if h.Argon2Memory < 8*uint32(h.Argon2Parallelism) || h.Argon2Iterations < 1 || h.Argon2Parallelism < 1 {
+			return errors.New("argon2id needs at least one iteration and thread and 8 KiB of memory per thread")
+		}
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q", h.Algorithm)
+	}
+	return nil
+}
+
+// Hash hashes password. Argon2id hashes use the PHC string format, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+	if h.Algorithm != HashArgon2id {
+		return "", fmt.Errorf("unknown password hashing algorithm %q", h.Algorithm)
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2Iterations, h.Argon2Memory, h.Argon2Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Argon2Memory, h.Argon2Iterations, h.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or
+// other parameters than the configured ones
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, _, _, err := parseArgon2Hash(hash)
+		return err != nil || h.Algorithm != HashArgon2id ||
+			params.memory != h.Argon2Memory || params.iterations != h.Argon2Iterations || params.parallelism != h.Argon2Parallelism
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || h.Algorithm != HashBcrypt || cost != h.BcryptCost
+}
+
+// CheckPassword returns nil when password matches hash, which may be a
+// bcrypt or an argon2id hash
+func CheckPassword(password, hash string) error {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}
+
+	params, salt, key, err := parseArgon2Hash(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func parseArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errMalformedHash
+	}
+
+	return params, salt, key, nil
+}
+
+// generatedAlphabet leaves out characters that are easily confused
+const generatedAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.!@#%+="
+
+// GeneratePassword returns a random password of length characters with an
+// uppercase and a lowercase letter, a digit and a symbol, so it passes
+// any policy of at most that length
+func GeneratePassword(length int) (string, error) {
+	if length < 4 {
+		return "", errors.New("generated passwords need at least 4 characters")
+	}
+	// Bytes past the last multiple of the alphabet size are skipped, so
+	// every character is equally likely
+	limit := byte(256 - 256%len(generatedAlphabet))
+	random := make([]byte, 1)
+	buf := make([]byte, length)
+	for {
+		var upper, lower, digit, symbol bool
+		for i := range buf {
+			for {
+				if _, err := rand.Read(random); err != nil {
+					return "", err
+				}
+				if random[0] < limit {
+					break
+				}
+			}
+			c := generatedAlphabet[int(random[0])%len(generatedAlphabet)]
+			buf[i] = c
+			switch {
+			case c >= 'A' && c <= 'Z':
+				upper = true
+			case c >= 'a' && c <= 'z':
+				lower = true
+			case c >= '0' && c <= '9':
+				digit = true
+			default:
+				symbol = true
+			}
+		}
+		if upper && lower && digit && symbol {
+			return string(buf), nil
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testArgon2 = &PasswordHasher{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1}
+	testBcrypt = &PasswordHasher{Algorithm: HashBcrypt, BcryptCost: 4}
+)
+
+func TestHashPassword(t *testing.T) {
+	password := "testpassword123"
+
+	for _, hasher := range []*PasswordHasher{testArgon2, testBcrypt} {
+		hashedPassword, err := hasher.Hash(password)
+		require.NoError(t, err)
+		assert.NotEmpty(t, hashedPassword)
+		assert.NotEqual(t, password, hashedPassword)
+	}
+
+	hashedPassword, err := testArgon2.Hash(password)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashedPassword, "$argon2id$v=19$m=64,t=1,p=1$"))
+}
+
+func TestCheckPassword(t *testing.T) {
+	password := "testpassword123"
+
+	for _, hasher := range []*PasswordHasher{testArgon2, testBcrypt} {
+		// Hash the password
+		hashedPassword, err := hasher.Hash(password)
+		require.NoError(t, err)
+
+		// Check correct password
+		err = CheckPassword(password, hashedPassword)
+		assert.NoError(t, err)
+
+		// Check wrong password
+		err = CheckPassword("wrongpassword", hashedPassword)
+		assert.Error(t, err)
+	}
+
+	assert.Error(t, CheckPassword(password, "$argon2id$v=19$m=64$bad"))
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	argon2Hash, err := testArgon2.Hash("secret")
+	require.NoError(t, err)
+	bcryptHash, err := testBcrypt.Hash("secret")
+	require.NoError(t, err)
+
+	assert.False(t, testArgon2.NeedsRehash(argon2Hash))
+	assert.True(t, testArgon2.NeedsRehash(bcryptHash))
+	assert.False(t, testBcrypt.NeedsRehash(bcryptHash))
+	assert.True(t, testBcrypt.NeedsRehash(argon2Hash))
+
+	stronger := *testArgon2
+	stronger.Argon2Iterations = 2
+	assert.True(t, stronger.NeedsRehash(argon2Hash))
+
+	costlier := *testBcrypt
+	costlier.BcryptCost = 5
+	assert.True(t, costlier.NeedsRehash(bcryptHash))
+}
+
+func TestPasswordHasherValidate(t *testing.T) {
+	assert.NoError(t, testArgon2.Validate())
+	assert.NoError(t, testBcrypt.Validate())
+	assert.Error(t, (&PasswordHasher{Algorithm: "md5"}).Validate())
+	assert.Error(t, (&PasswordHasher{Algorithm: HashBcrypt, BcryptCost: 40}).Validate())
+	assert.Error(t, (&PasswordHasher{Algorithm: HashArgon2id, Argon2Memory: 64}).Validate())
+}
+
+func TestGeneratePassword(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 24, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	seen := map[string]bool{}
+	for range 50 {
+		password, err := GeneratePassword(24)
+		require.NoError(t, err)
+		assert.Len(t, password, 24)
+		assert.NoError(t, policy.Check(password))
+		assert.False(t, seen[password])
+		seen[password] = true
+	}
+
+	_, err := GeneratePassword(3)
+	assert.Error(t, err)
+}
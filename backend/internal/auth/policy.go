@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+)
+
+// maxPasswordLength is where bcrypt stops reading; longer passwords would
+// silently be cut
+const maxPasswordLength = 72
+
+// PolicyError lists the rules a password breaks
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password " + strings.Join(e.Problems, ", ")
+}
+
+// PasswordPolicy decides which passwords users may choose
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	breached map[[sha1.Size]byte]struct{}
+}
+
+// NewPasswordPolicy returns the policy cfg configures, with its breached
+// list loaded
+func NewPasswordPolicy(cfg config.AuthConfig) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+	}
+	if cfg.PasswordBreachedList != "" {
+		if err := policy.LoadBreachedList(cfg.PasswordBreachedList); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// LoadBreachedList reads SHA-1 hashes of known breached passwords, one per
+// line in the Have I Been Pwned format ("HASH" or "HASH:COUNT"). Meant for
+// a subset such as the most common passwords, as the list is kept in memory.
+func (p *PasswordPolicy) LoadBreachedList(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	breached := make(map[[sha1.Size]byte]struct{})
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text, _, _ = strings.Cut(text, ":")
+
+		var hash [sha1.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(text)); err != nil || n != sha1.Size {
+			return fmt.Errorf("breached password list line %d: not a SHA-1 hash", line)
+		}
+		breached[hash] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	p.breached = breached
+	return nil
+}
+
+// Check returns a *PolicyError when password breaks any rule
+func (p *PasswordPolicy) Check(password string) error {
+	var problems []string
+
+	if length := len([]rune(password)); length < p.MinLength {
+		problems = append(problems, "must be at least "+strconv.Itoa(p.MinLength)+" characters long")
+	}
+	if len(password) > maxPasswordLength {
+		problems = append(problems, "must be at most "+strconv.Itoa(maxPasswordLength)+" bytes long")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	if _, ok := p.breached[sha1.Sum([]byte(password))]; ok {
+		problems = append(problems, "has appeared in a data breach")
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+	return nil
+}
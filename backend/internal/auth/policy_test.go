@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, RequireUpper: true, RequireDigit: true}
+
+	assert.NoError(t, policy.Check("Correct1horse"))
+
+	err := policy.Check("short")
+	var policyErr *PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, []string{
+		"must be at least 8 characters long",
+		"must contain an uppercase letter",
+		"must contain a digit",
+	}, policyErr.Problems)
+
+	err = policy.Check("A1" + string(make([]byte, 80)))
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, []string{"must be at most 72 bytes long"}, policyErr.Problems)
+}
+
+func TestPasswordPolicy_Breached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// SHA-1 of "password" and "Password1", with and without counts
+	list := "# common passwords\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n70ccd9007338d6d81dd3b6271621b9cf9a97ea00\n"
+	require.NoError(t, os.WriteFile(path, []byte(list), 0o600))
+
+	policy := &PasswordPolicy{MinLength: 6}
+	require.NoError(t, policy.LoadBreachedList(path))
+
+	assert.EqualError(t, policy.Check("password"), "password has appeared in a data breach")
+	assert.Error(t, policy.Check("Password1"))
+	assert.NoError(t, policy.Check("not-in-the-list"))
+
+	require.NoError(t, os.WriteFile(path, []byte("not a hash\n"), 0o600))
+	assert.Error(t, policy.LoadBreachedList(path))
+}
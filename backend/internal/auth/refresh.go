@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshStore keeps refresh tokens in Redis. Only a SHA-256 hash of each
+// token is stored. The tokens issued from one login form a family: every
+// refresh rotates to a new token, and presenting a token that was already
+// rotated revokes the whole family, since it means the token was copied.
+// Each family keeps the lifetime it was issued with, bounded by the
+// store's TTL.
+type RefreshStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+type refreshRecord struct {
+	UserID   string `json:"userId"`
+	FamilyID string `json:"familyId"`
+	TTL      int64  `json:"ttl,omitempty"` // seconds; the store's TTL when zero
+}
+
+func NewRefreshStore(client *redis.Client, ttl time.Duration) *RefreshStore {
+	return &RefreshStore{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// TTL is the longest a refresh token stays valid without being used
+func (s *RefreshStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue starts a new token family for userID whose tokens stay valid for
+// ttl without being used, and returns its first token. A ttl of zero or
+// above the store's TTL is the store's TTL.
+func (s *RefreshStore) Issue(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	record := refreshRecord{UserID: userID, FamilyID: uuid.NewString()}
+	if ttl > 0 && ttl < s.ttl {
+		record.TTL = int64(ttl / time.Second)
+	}
+	return s.issue(ctx, record)
+}
+
+// Rotate exchanges token for a new one in the same family and returns the
+// user it belongs to, the new token and the lifetime of the family
+func (s *RefreshStore) Rotate(ctx context.Context, token string) (userID, next string, ttl time.Duration, err error) {
+	hash := hashToken(token)
+
+	data, err := s.client.Get(ctx, refreshTokenKey(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return "", "", 0, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	var record refreshRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", "", 0, fmt.Errorf("failed to unmarshal refresh token: %w", err)
+	}
+
+	active, err := s.client.Exists(ctx, refreshFamilyKey(record.FamilyID)).Result()
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to load refresh token family: %w", err)
+	}
+	if active == 0 {
+		return "", "", 0, ErrInvalidRefreshToken
+	}
+
+	// Only the first presentation of a token may claim it
+	claimed, err := s.client.SetNX(ctx, refreshUsedKey(hash), 1, s.ttl).Result()
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to claim refresh token: %w", err)
+	}
+	if !claimed {
+		if err := s.client.Del(ctx, refreshFamilyKey(record.FamilyID)).Err(); err != nil {
+			return "", "", 0, fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+		return "", "", 0, ErrRefreshTokenReused
+	}
+
+	next, err = s.issue(ctx, record)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return record.UserID, next, s.recordTTL(record), nil
+}
+
+// Revoke ends the family token belongs to, e.g. on logout. Unknown tokens
+// are ignored.
+func (s *RefreshStore) Revoke(ctx context.Context, token string) error {
+	data, err := s.client.Get(ctx, refreshTokenKey(hashToken(token))).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	var record refreshRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("failed to unmarshal refresh token: %w", err)
+	}
+
+	if err := s.client.Del(ctx, refreshFamilyKey(record.FamilyID)).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser ends every token family of userID
+func (s *RefreshStore) RevokeUser(ctx context.Context, userID string) error {
+	families, err := s.client.SMembers(ctx, refreshUserKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list refresh token families: %w", err)
+	}
+
+	keys := []string{refreshUserKey(userID)}
+	for _, familyID := range families {
+		keys = append(keys, refreshFamilyKey(familyID))
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh token families: %w", err)
+	}
+	return nil
+}
+
+func (s *RefreshStore) issue(ctx context.Context, record refreshRecord) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal refresh token: %w", err)
+	}
+
+	// Using the family keeps it alive for as long as its newest token. The
+	// user's index of families lets all of them be revoked at once.
+	ttl := s.recordTTL(record)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, refreshTokenKey(hashToken(token)), data, ttl)
+	pipe.Set(ctx, refreshFamilyKey(record.FamilyID), record.UserID, ttl)
+	pipe.SAdd(ctx, refreshUserKey(record.UserID), record.FamilyID)
+	pipe.Expire(ctx, refreshUserKey(record.UserID), s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *RefreshStore) recordTTL(record refreshRecord) time.Duration {
+	if record.TTL == 0 {
+		return s.ttl
+	}
+	return time.Duration(record.TTL) * time.Second
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshTokenKey(hash string) string {
+	return "refresh:token:" + hash
+}
+
+func refreshUsedKey(hash string) string {
+	return "refresh:used:" + hash
+}
+
+func refreshFamilyKey(familyID string) string {
+	return "refresh:family:" + familyID
+}
+
+func refreshUserKey(userID string) string {
+	return "refresh:user:" + userID
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRefreshStore(t *testing.T) (*RefreshStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRefreshStore(client, time.Hour), server
+}
+
+func TestRefreshStore_Rotate(t *testing.T) {
+	store, _ := newTestRefreshStore(t)
+	ctx := context.Background()
+
+	token, err := store.Issue(ctx, "user-1", 0)
+	require.NoError(t, err)
+
+	userID, next, _, err := store.Rotate(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	assert.NotEqual(t, token, next)
+
+	userID, _, _, err = store.Rotate(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+}
+
+func TestRefreshStore_ReuseRevokesFamily(t *testing.T) {
+	store, _ := newTestRefreshStore(t)
+	ctx := context.Background()
+
+	token, err := store.Issue(ctx, "user-1", 0)
+	require.NoError(t, err)
+	_, next, _, err := store.Rotate(ctx, token)
+	require.NoError(t, err)
+
+	_, _, _, err = store.Rotate(ctx, token)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// The legitimate holder's newer token died with the family
+	_, _, _, err = store.Rotate(ctx, next)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestRefreshStore_Expiry(t *testing.T) {
+	store, server := newTestRefreshStore(t)
+	ctx := context.Background()
+
+	token, err := store.Issue(ctx, "user-1", 0)
+	require.NoError(t, err)
+
+	server.FastForward(2 * time.Hour)
+
+	_, _, _, err = store.Rotate(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, _, _, err = store.Rotate(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestRefreshStore_SessionTTL(t *testing.T) {
+	store, server := newTestRefreshStore(t)
+	ctx := context.Background()
+
+	short, err := store.Issue(ctx, "user-1", 10*time.Minute)
+	require.NoError(t, err)
+	long, err := store.Issue(ctx, "user-1", 48*time.Hour)
+	require.NoError(t, err)
+
+	// Rotated tokens keep the lifetime of their family
+	_, short, ttl, err := store.Rotate(ctx, short)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, ttl)
+	_, long, ttl, err = store.Rotate(ctx, long)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl, "bounded by the store's TTL")
+
+	server.FastForward(30 * time.Minute)
+
+	_, _, _, err = store.Rotate(ctx, short)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, _, _, err = store.Rotate(ctx, long)
+	assert.NoError(t, err)
+}
+
+func TestRefreshStore_Revoke(t *testing.T) {
+	store, _ := newTestRefreshStore(t)
+	ctx := context.Background()
+
+	first, err := store.Issue(ctx, "user-1", 0)
+	require.NoError(t, err)
+	second, err := store.Issue(ctx, "user-1", 0)
+	require.NoError(t, err)
+	other, err := store.Issue(ctx, "user-2", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(ctx, first))
+	_, _, _, err = store.Rotate(ctx, first)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	require.NoError(t, store.Revoke(ctx, "unknown"))
+
+	require.NoError(t, store.RevokeUser(ctx, "user-1"))
+	_, _, _, err = store.Rotate(ctx, second)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, _, _, err = store.Rotate(ctx, other)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// ResetStore keeps one-time password reset tokens in Redis. Only a hash of
+// each token is stored, a user has at most one outstanding token, and a
+// token is deleted as soon as it is used.
+type ResetStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewResetStore(client *redis.Client, ttl time.Duration) *ResetStore {
+	return &ResetStore{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// TTL is how long a reset token stays valid
+func (s *ResetStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue creates a reset token for userID, replacing any earlier one
+func (s *ResetStore) Issue(ctx context.Context, userID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	hash := hashToken(token)
+
+	previous, err := s.client.GetSet(ctx, resetUserKey(userID), hash).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, resetTokenKey(previous))
+	}
+	pipe.Expire(ctx, resetUserKey(userID), s.ttl)
+	pipe.Set(ctx, resetTokenKey(hash), userID, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Consume uses up token and returns the user it was issued to
+func (s *ResetStore) Consume(ctx context.Context, token string) (string, error) {
+	userID, err := s.client.GetDel(ctx, resetTokenKey(hashToken(token))).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load reset token: %w", err)
+	}
+
+	if err := s.client.Del(ctx, resetUserKey(userID)).Err(); err != nil {
+		return "", fmt.Errorf("failed to clear reset token: %w", err)
+	}
+	return userID, nil
+}
+
+// Allow counts an attempt against key and reports whether it stays within
+// limit attempts per window. Reset requests are limited per address and
+// per client so they cannot be used to flood inboxes or probe accounts.
+func (s *ResetStore) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	pipe := s.client.TxPipeline()
+	count := pipe.Incr(ctx, resetLimitKey(key))
+	pipe.ExpireNX(ctx, resetLimitKey(key), window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to count reset attempt: %w", err)
+	}
+	return count.Val() <= int64(limit), nil
+}
+
+func resetTokenKey(hash string) string {
+	return "reset:token:" + hash
+}
+
+func resetUserKey(userID string) string {
+	return "reset:user:" + userID
+}
+
+func resetLimitKey(key string) string {
+	return "reset:limit:" + key
+}
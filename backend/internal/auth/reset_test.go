@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResetStore(t *testing.T) (*ResetStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewResetStore(client, 30*time.Minute), server
+}
+
+func TestResetStore_Consume(t *testing.T) {
+	store, server := newTestResetStore(t)
+	ctx := context.Background()
+
+	first, err := store.Issue(ctx, "user-1")
+	require.NoError(t, err)
+	second, err := store.Issue(ctx, "user-1")
+	require.NoError(t, err)
+
+	// A new token replaces the earlier one
+	_, err = store.Consume(ctx, first)
+	assert.ErrorIs(t, err, ErrInvalidResetToken)
+
+	userID, err := store.Consume(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	// Tokens are single-use
+	_, err = store.Consume(ctx, second)
+	assert.ErrorIs(t, err, ErrInvalidResetToken)
+
+	expired, err := store.Issue(ctx, "user-1")
+	require.NoError(t, err)
+	server.FastForward(31 * time.Minute)
+	_, err = store.Consume(ctx, expired)
+	assert.ErrorIs(t, err, ErrInvalidResetToken)
+}
+
+func TestResetStore_Allow(t *testing.T) {
+	store, server := newTestResetStore(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := store.Allow(ctx, "email:a@example.com", 3, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, err := store.Allow(ctx, "email:a@example.com", 3, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Other keys have their own budget
+	allowed, err = store.Allow(ctx, "email:b@example.com", 3, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	server.FastForward(time.Hour)
+	allowed, err = store.Allow(ctx, "email:a@example.com", 3, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
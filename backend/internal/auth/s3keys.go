@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAccessKey = errors.New("invalid or expired access key")
+
+// s3AccessKeyPrefix starts every S3 access key ID
+const s3AccessKeyPrefix = "MFS"
+
+var s3KeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// S3Subject is who S3 credentials were issued to
+type S3Subject struct {
+	ID             string // user or service account ID
+	ServiceAccount bool
+	IssuedAt       time.Time // to the millisecond, to compare with revocations
+	ExpiresAt      time.Time
+}
+
+// S3KeySigner issues credentials for the S3 gateway. The access key ID
+// names the subject and when the credentials were issued and expire, and
+// the secret key is derived from it, so credentials need no storage: the
+// gateway recomputes the secret to check request signatures. The key is
+// derived from the JWT secret like InviteSigner's.
+type S3KeySigner struct {
+	key []byte
+}
+
+func NewS3KeySigner(secret string) *S3KeySigner {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("s3-credentials"))
+	return &S3KeySigner{key: mac.Sum(nil)}
+}
+
+// Issue returns the access key ID and secret key of credentials for subject
+func (s *S3KeySigner) Issue(subject S3Subject) (accessKey, secretKey string) {
+	kind := "u"
+	if subject.ServiceAccount {
+		kind = "s"
+	}
+	claims := kind + ":" + subject.ID + ":" + strconv.FormatInt(subject.IssuedAt.UnixMilli(), 10) + ":" + strconv.FormatInt(subject.ExpiresAt.Unix(), 10)
+	accessKey = s3AccessKeyPrefix + s3KeyEncoding.EncodeToString([]byte(claims))
+	return accessKey, s.secret(accessKey)
+}
+
+// Secret returns the subject and secret key of an unexpired access key
+// made by Issue
+func (s *S3KeySigner) Secret(accessKey string) (S3Subject, string, error) {
+	encoded, ok := strings.CutPrefix(accessKey, s3AccessKeyPrefix)
+	if !ok {
+		return S3Subject{}, "", ErrInvalidAccessKey
+	}
+	claims, err := s3KeyEncoding.DecodeString(encoded)
+	if err != nil {
+		return S3Subject{}, "", ErrInvalidAccessKey
+	}
+
+	parts := strings.Split(string(claims), ":")
+	if len(parts) != 4 || parts[0] != "u" && parts[0] != "s" || parts[1] == "" {
+		return S3Subject{}, "", ErrInvalidAccessKey
+	}
+	issued, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return S3Subject{}, "", ErrInvalidAccessKey
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return S3Subject{}, "", ErrInvalidAccessKey
+	}
+
+	subject := S3Subject{ID: parts[1], ServiceAccount: parts[0] == "s", IssuedAt: time.UnixMilli(issued), ExpiresAt: time.Unix(expires, 0)}
+	return subject, s.secret(accessKey), nil
+}
+
+func (s *S3KeySigner) secret(accessKey string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(accessKey))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
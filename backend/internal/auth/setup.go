@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidSetupToken = errors.New("invalid or expired setup token")
+
+const setupTokenKey = "setup:token"
+
+// consumeSetupScript deletes the setup token when its hash matches
+var consumeSetupScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// SetupStore keeps the one-time token that creates the first admin of a
+// new installation. Only its hash is stored, and servers sharing Redis
+// share the token.
+type SetupStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewSetupStore(client *redis.Client, ttl time.Duration) *SetupStore {
+	return &SetupStore{client: client, ttl: ttl}
+}
+
+// Issue creates a setup token unless one is pending, in which case it
+// returns ""
+func (s *SetupStore) Issue(ctx context.Context) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate setup token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	issued, err := s.client.SetNX(ctx, setupTokenKey, hashToken(token), s.ttl).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to store setup token: %w", err)
+	}
+	if !issued {
+		return "", nil
+	}
+	return token, nil
+}
+
+// Consume uses up token
+func (s *SetupStore) Consume(ctx context.Context, token string) error {
+	deleted, err := consumeSetupScript.Run(ctx, s.client, []string{setupTokenKey}, hashToken(token)).Int()
+	if err != nil {
+		return fmt.Errorf("failed to check setup token: %w", err)
+	}
+	if deleted == 0 {
+		return ErrInvalidSetupToken
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewSetupStore(client, time.Hour)
+	ctx := context.Background()
+
+	token, err := store.Issue(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	// Other servers find the token pending
+	again, err := store.Issue(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	assert.ErrorIs(t, store.Consume(ctx, "wrong"), ErrInvalidSetupToken)
+	require.NoError(t, store.Consume(ctx, token), "a wrong guess does not use up the token")
+	assert.ErrorIs(t, store.Consume(ctx, token), ErrInvalidSetupToken)
+
+	// Expired tokens are replaced by the next server to start
+	token, err = store.Issue(ctx)
+	require.NoError(t, err)
+	server.FastForward(2 * time.Hour)
+	assert.ErrorIs(t, store.Consume(ctx, token), ErrInvalidSetupToken)
+	token, err = store.Issue(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
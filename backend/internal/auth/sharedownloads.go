@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrShareExhausted is returned when a share link has no downloads left
+var ErrShareExhausted = errors.New("share link has no downloads left")
+
+// takeDownloadScript counts a download unless the limit in ARGV[2] was
+// reached, starting from the count in ARGV[1] when there is no counter,
+// and keeps the counter until the time in ARGV[3], in milliseconds
+var takeDownloadScript = redis.NewScript(`
+local count = tonumber(redis.call("GET", KEYS[1]) or ARGV[1])
+local limit = tonumber(ARGV[2])
+if limit > 0 and count >= limit then
+	return -1
+end
+count = count + 1
+redis.call("SET", KEYS[1], count)
+redis.call("PEXPIREAT", KEYS[1], ARGV[3])
+return count
+`)
+
+// ShareDownloads counts the downloads through share links in Redis, so
+// that concurrent downloads cannot go past a share's limit. The count kept
+// in the share itself is only for display and starts the counter.
+type ShareDownloads struct {
+	client *redis.Client
+}
+
+func NewShareDownloads(client *redis.Client) *ShareDownloads {
+	return &ShareDownloads{client: client}
+}
+
+// Take counts a download through the share token unless limit downloads,
+// 0 for unlimited, were counted already. stored is the count recorded in
+// the share, used when Redis has none. It returns the new count.
+func (d *ShareDownloads) Take(ctx context.Context, token string, stored, limit int, expiresAt time.Time) (int, error) {
+	count, err := takeDownloadScript.Run(ctx, d.client, []string{shareDownloadsKey(token)}, stored, limit, expiresAt.UnixMilli()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count share download: %w", err)
+	}
+	if count < 0 {
+		return 0, ErrShareExhausted
+	}
+	return count, nil
+}
+
+func shareDownloadsKey(token string) string {
+	return "share:downloads:" + token
+}
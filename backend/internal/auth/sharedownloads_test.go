@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareDownloads(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	downloads := NewShareDownloads(client)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+
+	// Concurrent downloads get exactly the limit between them
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := downloads.Take(ctx, "token", 0, 5, expires); err == nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrShareExhausted)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, taken)
+
+	// The count stored in the share starts the counter
+	count, err := downloads.Take(ctx, "older", 2, 3, expires)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	_, err = downloads.Take(ctx, "older", 0, 3, expires)
+	assert.ErrorIs(t, err, ErrShareExhausted)
+
+	// Unlimited shares are only counted
+	for want := 1; want <= 3; want++ {
+		count, err := downloads.Take(ctx, "unlimited", 0, 0, expires)
+		require.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	// Counters go with their share
+	server.FastForward(2 * time.Hour)
+	assert.Empty(t, server.Keys())
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationSigner(t *testing.T) {
+	signer := NewVerificationSigner("secret", time.Hour)
+
+	token := signer.Sign("user-1", "ada@example.com")
+	userID, email, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	assert.Equal(t, "ada@example.com", email)
+
+	for _, forged := range []string{
+		token + "x",
+		"x" + token,
+		token[:len(token)/2],
+		"",
+		NewVerificationSigner("other-secret", time.Hour).Sign("user-1", "ada@example.com"),
+		NewInviteSigner("secret").Sign("user-1"),
+		NewVerificationSigner("secret", -time.Minute).Sign("user-1", "ada@example.com"),
+	} {
+		_, _, err := signer.Verify(forged)
+		assert.ErrorIs(t, err, ErrInvalidVerificationToken, forged)
+	}
+}
@@ -0,0 +1,55 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestVerifier points a verifier at a fake siteverify endpoint that
+// accepts the token "valid"
+func newTestVerifier(t *testing.T) *SiteVerifier {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret-1", r.Form.Get("secret"))
+		assert.Equal(t, "203.0.113.7", r.Form.Get("remoteip"))
+
+		if r.Form.Get("response") == "valid" {
+			json.NewEncoder(w).Encode(map[string]any{"success": true})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "error-codes": []string{"invalid-input-response"}})
+	}))
+	t.Cleanup(server.Close)
+
+	v, err := New("turnstile", "secret-1")
+	require.NoError(t, err)
+	v.url = server.URL
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := newTestVerifier(t)
+	ctx := context.Background()
+
+	assert.NoError(t, v.Verify(ctx, "valid", "203.0.113.7"))
+	assert.ErrorIs(t, v.Verify(ctx, "forged", "203.0.113.7"), ErrFailed)
+	assert.ErrorIs(t, v.Verify(ctx, "", "203.0.113.7"), ErrFailed)
+}
+
+func TestNew(t *testing.T) {
+	for _, provider := range []string{"hcaptcha", "Turnstile", "recaptcha"} {
+		_, err := New(provider, "secret")
+		assert.NoError(t, err, provider)
+	}
+
+	_, err := New("captchaville", "secret")
+	assert.Error(t, err)
+	_, err = New("hcaptcha", "")
+	assert.Error(t, err)
+}
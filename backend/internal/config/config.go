@@ -3,15 +3,53 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Port     string
-	MinIO    MinIOConfig
-	Redis    RedisConfig
-	NATS     NATSConfig
-	JWT      JWTConfig
-	Database DatabaseConfig
+	// Environment is development, staging or production. Outside
+	// development unsafe settings such as default secrets stop the server.
+	Environment string
+	Port        string
+	// WatchInterval is the seconds between checks of the config file for
+	// changes, which are reloaded; 0 leaves reloading to SIGHUP
+	WatchInterval int
+	CORS          CORSConfig
+	TLS           TLSConfig
+	GRPC          GRPCConfig
+	S3Gateway     S3GatewayConfig
+	Secrets       SecretsConfig
+	MinIO         MinIOConfig
+	Redis         RedisConfig
+	Cache         CacheConfig
+	NATS          NATSConfig
+	JWT           JWTConfig
+	Auth          AuthConfig
+	OAuth         OAuthConfig
+	SCIM          SCIMConfig
+	LDAP          LDAPConfig
+	Mail          MailConfig
+	Database      DatabaseConfig
+	Comments      CommentsConfig
+	Moderation    ModerationConfig
+	Upload        UploadConfig
+	Download      DownloadConfig
+	Request       RequestConfig
+	Network       NetworkConfig
+	Scan          ScanConfig
+	Preview       PreviewConfig
+	Video         VideoConfig
+	RateLimit     RateLimitConfig
+	Log           LogConfig
+	Tracing       TracingConfig
+	Reporting     ReportingConfig
+	Maintenance   MaintenanceConfig
+	Webhook       WebhookConfig
+	WebSocket     WebSocketConfig
+	Scheduler     SchedulerConfig
+
+	// settings is where the values came from, for Settings
+	settings []Setting
 }
 
 type MinIOConfig struct {
@@ -20,21 +58,172 @@ type MinIOConfig struct {
 	SecretAccessKey string
 	UseSSL          bool
 	Region          string
+	// Notifications follows bucket notifications to notice objects changed
+	// outside the API; it needs a MinIO server, not another S3 service
+	Notifications bool
+
+	// Transport of the MinIO client. Timeouts are in seconds; 0 for
+	// MaxConnsPerHost means no limit.
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       int
+	DialTimeout           int
+	ResponseHeaderTimeout int
+	// CAFile holds PEM certificates trusted besides the system ones, for
+	// MinIO behind a private certificate authority
+	CAFile string
+	// Trace logs the headers of every request to MinIO and its response,
+	// with signatures redacted
+	Trace bool
 }
 
 type RedisConfig struct {
-	URL      string
+	Addr     string
 	Password string
 	DB       int
 }
 
+// CacheConfig sizes the in-memory cache of users, posts, files and public
+// profile posts each server keeps in front of MinIO. Size is the number of
+// entries per kind; 0 disables the cache.
+type CacheConfig struct {
+	Size int
+	TTL  int // seconds an entry is served before it is read again
+}
+
 type NATSConfig struct {
 	URL string
+
+	// Domain events are published to a JetStream stream kept for
+	// EventRetention hours. Events that fail to publish wait in an outbox
+	// retried every OutboxInterval seconds.
+	EventsEnabled  bool
+	EventRetention int
+	OutboxInterval int
 }
 
 type JWTConfig struct {
-	Secret     string
-	Expiration int // hours
+	Secret          string
+	AccessTokenTTL  int // minutes
+	RefreshTokenTTL int // hours a refresh token stays valid unused, for remembered logins
+
+	// With a signing key access tokens are signed with RS256 or ES256
+	// instead of the secret. Keys are PEM encoded, given directly or as a
+	// file; the verification keys are earlier signing keys whose tokens are
+	// still accepted.
+	SigningKey     string
+	SigningKeyFile string
+	VerifyKeys     string
+	VerifyKeysFile string
+}
+
+type AuthConfig struct {
+	ResetTokenTTL  int    // minutes a password reset link stays valid
+	ResetURL       string // frontend page the reset token is appended to
+	ResetRateLimit int    // reset requests per hour per email address and per client
+
+	// SessionTTL is the hours a refresh token stays valid unused for logins
+	// without remember me; remembered logins get JWTConfig.RefreshTokenTTL
+	SessionTTL int
+
+	// ImpersonationTTL is the minutes an admin's token for acting as
+	// another user is valid, at most the access token TTL
+	ImpersonationTTL int
+
+	// InviteOnly closes registration to people with an invitation
+	InviteOnly bool
+	InviteTTL  int    // default hours an invitation stays valid
+	InviteURL  string // frontend registration page the invite token is appended to
+
+	VerifyTTL int    // hours an email verification link stays valid
+	VerifyURL string // frontend page the verification token is appended to
+
+	// SigningSecret signs invitation and email verification links and
+	// derives S3 gateway credentials. Unlike the JWT secret it is needed
+	// when access tokens are signed with a key too.
+	SigningSecret string
+
+	// CAPTCHA verification is off without a provider (hcaptcha, turnstile
+	// or recaptcha). When on, registration always needs a CAPTCHA and login
+	// needs one after CaptchaLoginThreshold failures within 15 minutes.
+	CaptchaProvider       string
+	CaptchaSecret         string
+	CaptchaLoginThreshold int
+
+	// Password policy; the breached list holds SHA-1 hashes in the Have I
+	// Been Pwned format
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordBreachedList  string
+
+	// Password hashing; hashes made with other settings are replaced at
+	// the next login
+	PasswordHashAlgorithm string // argon2id or bcrypt
+	BcryptCost            int
+	Argon2Memory          int // KiB
+	Argon2Iterations      int
+	Argon2Parallelism     int
+}
+
+// OAuthConfig holds the social login providers. A provider without a
+// client ID is disabled.
+type OAuthConfig struct {
+	GoogleClientID     string
+	GoogleClientSecret string
+	GitHubClientID     string
+	GitHubClientSecret string
+	CallbackURL        string // public URL of /api/v1/auth/oauth; the provider's callback path is appended
+	SuccessURL         string // frontend page the tokens are sent to in the URL fragment
+}
+
+// SCIMConfig enables the SCIM provisioning API at /scim/v2 for identity
+// providers holding Token. Without a token the API is disabled.
+type SCIMConfig struct {
+	Token string
+}
+
+// LDAPConfig enables signing in with directory credentials. Users are
+// looked up with the bind account, authenticated by binding as them and
+// get a local account with the mapped attributes on their first login.
+// Without a URL LDAP is disabled.
+type LDAPConfig struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            int // seconds
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // %s is replaced by the login name
+
+	UsernameAttribute  string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupAttribute     string
+
+	// GroupRoles holds "role:groupDN" pairs separated by semicolons; the
+	// first group a user is in decides their role at each login. Without
+	// mappings new users get DefaultRole and roles are managed locally.
+	GroupRoles  string
+	DefaultRole string
+}
+
+// MailConfig selects the SMTP server for outgoing email. Without a host,
+// or in dev mode, emails are only logged.
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	DevMode      bool
+	ShareURL     string // frontend page share tokens are appended to in share notifications
+	AppURL       string // frontend address links in digest emails start with
 }
 
 type DatabaseConfig struct {
@@ -43,57 +232,716 @@ type DatabaseConfig struct {
 	FilesBucket string
 }
 
+// CommentsConfig limits the threads of comments on posts. Comments on the
+// post have depth 0 and each reply one more than its parent; with
+// MaxDepth 0 comments cannot be replied to.
+type CommentsConfig struct {
+	MaxDepth int
+}
+
+// ModerationConfig holds posts and comments for review by post admins
+// before others see them. Content is held when it contains one of
+// Keywords, more than MaxLinks links or is written by an account younger
+// than NewAccountHours; zero values turn a rule off. Reports of a post or
+// file are escalated once ReportThreshold users have one open, and a
+// reported post is held; 0 never escalates.
+type ModerationConfig struct {
+	Keywords        []string
+	MaxLinks        int
+	NewAccountHours int
+	ReportThreshold int
+
+	// SpamChecker holds posts and comments it takes for spam: heuristic
+	// uses built-in rules, http asks an Akismet-style service at
+	// SpamCheckURL with SpamCheckKey, off checks nothing
+	SpamChecker  string
+	SpamCheckURL string
+	SpamCheckKey string
+}
+
+type UploadConfig struct {
+	MaxFileSize   int64 // bytes
+	PresignExpiry int   // minutes
+	MaxVersions   int   // previous versions kept per file
+	FormMemory    int64 // bytes of a multipart form kept in memory
+	AvatarMaxSize int64 // bytes of an uploaded avatar image
+
+	// StripExif removes EXIF and similar metadata from images unless the
+	// uploader opts out; KeepExif saves selected tags for the owner.
+	StripExif bool
+	KeepExif  bool
+
+	// Batch uploads: total request size, files per request and how many
+	// files are stored at once
+	BatchMaxSize     int64 // bytes
+	BatchMaxFiles    int
+	BatchConcurrency int
+
+	// Size limits in bytes keyed by role and by content type pattern.
+	// A role limit replaces MaxFileSize; a type limit can only lower it.
+	RoleMaxFileSize map[string]int64
+	TypeMaxFileSize map[string]int64
+
+	// Content type patterns ("image/*", "application/pdf") and extensions
+	// (".exe"). Empty allow lists accept everything not denied.
+	AllowedTypes      []string
+	DeniedTypes       []string
+	AllowedExtensions []string
+	DeniedExtensions  []string
+}
+
+type ScanConfig struct {
+	Enabled      bool
+	ClamdAddress string
+	Timeout      int // seconds
+}
+
+type PreviewConfig struct {
+	Enabled         bool
+	PdftoppmPath    string
+	LibreOfficePath string
+	Size            int // longest edge in pixels
+	Timeout         int // seconds
+}
+
+type VideoConfig struct {
+	Enabled     bool
+	FFmpegPath  string
+	FFprobePath string
+	Renditions  []string // names from the transcode ladder, e.g. 720p
+	Timeout     int      // minutes
+}
+
+type DownloadConfig struct {
+	PresignPublic     bool // redirect public downloads to presigned MinIO URLs
+	PresignExpiry     int  // minutes
+	PublicCacheMaxAge int  // seconds
+}
+
+// RequestConfig limits the size of request bodies and how long requests
+// may take. Upload routes have a body limit of their own, on top of which
+// the upload size limits apply. Timeouts are in seconds; 0 means none.
+type RequestConfig struct {
+	MaxBodySize       int64 // bytes
+	MaxUploadBodySize int64 // bytes
+
+	Timeout         int // API requests
+	AdminTimeout    int // admin routes, which often scan whole buckets
+	TransferTimeout int // uploads, downloads and streams, which last as long as the transfer
+}
+
+// NetworkConfig decides which client addresses may use the API. Entries
+// are CIDR networks or single addresses. Denied networks win; empty allow
+// lists allow everything not denied. The admin lists apply to /admin
+// routes on top of the global ones.
+//
+// The client address is taken from X-Forwarded-For only when the request
+// comes from one of the trusted proxies, so clients cannot forge it.
+type NetworkConfig struct {
+	TrustedProxies  []string
+	AllowedIPs      []string
+	DeniedIPs       []string
+	AdminAllowedIPs []string
+	AdminDeniedIPs  []string
+}
+
+// CORSConfig lists the origins of browser apps that may call the API, such
+// as https://app.example.com; "*" allows every origin
+type CORSConfig struct {
+	AllowedOrigins []string
+}
+
+// TLSConfig serves HTTPS on PORT directly, for deployments without a
+// proxy terminating TLS, with a certificate and key from files or from an
+// ACME CA such as Let's Encrypt for the allowed domains. Without either
+// the server speaks plain HTTP.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	AutocertDomains      []string
+	AutocertEmail        string
+	AutocertCacheDir     string
+	AutocertDirectoryURL string // of the ACME CA, Let's Encrypt when empty
+
+	// RedirectPort serves plain HTTP redirecting to HTTPS, and answering
+	// ACME challenges; empty leaves it off
+	RedirectPort string
+	MinVersion   string // 1.2 or 1.3
+	HSTSMaxAge   int    // seconds of Strict-Transport-Security, 0 for none
+}
+
+// Enabled reports whether the server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || len(t.AutocertDomains) > 0
+}
+
+// GRPCConfig serves the gRPC API for internal services on a port of its
+// own, with the certificate of TLSConfig when HTTPS is enabled
+type GRPCConfig struct {
+	Port string // empty leaves the gRPC API off
+	// Reflection lets clients such as grpcurl list the services
+	Reflection bool
+}
+
+// S3GatewayConfig serves users' files to S3 clients on a port of its own,
+// as S3 clients expect buckets at the root of the endpoint
+type S3GatewayConfig struct {
+	Port string // empty leaves the gateway off
+}
+
+// SecretsConfig selects a secrets provider, vault or aws, whose secret
+// holds settings such as MINIO_SECRET_KEY, JWT_SECRET or SMTP_PASSWORD by
+// name. Without a provider settings only come from the environment and
+// the config file.
+type SecretsConfig struct {
+	Provider string
+	// RefreshInterval is the seconds between fetches of the secrets, which
+	// are reloaded like the config file; 0 fetches them only at startup
+	RefreshInterval int
+
+	VaultAddr  string
+	VaultToken string
+	VaultMount string
+	VaultPath  string
+
+	AWSRegion          string
+	AWSSecretID        string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	AWSEndpoint        string
+}
+
+// RateLimitConfig holds the token bucket limits of each route group.
+// Authenticated requests are counted per user, others per client address.
+type RateLimitConfig struct {
+	Enabled bool
+	Auth    RateLimit // sign-in, registration and password reset
+	Public  RateLimit // share links and public downloads
+	API     RateLimit // everything that needs a token
+}
+
+// RateLimit allows Burst requests at once, refilled at PerMinute requests
+// per minute
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// LogConfig controls the structured logs
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+	// AccessSampleRate is the share of successful requests written to the
+	// access log, from 0 to 1; failed and slow requests are always logged
+	AccessSampleRate float64
+	SlowRequest      int // milliseconds
+}
+
+// TracingConfig controls OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP when Endpoint is set.
+type TracingConfig struct {
+	Endpoint    string // e.g. http://otel-collector:4318
+	ServiceName string
+	// SampleRatio is the share of traces started by the server that are
+	// recorded, from 0 to 1
+	SampleRatio float64
+}
+
+// ReportingConfig sends reports of panics to Sentry, to a webhook that
+// takes them as JSON, or to both. Without either they are only logged.
+type ReportingConfig struct {
+	SentryDSN   string
+	Environment string // Sentry environment, e.g. production
+	WebhookURL  string
+}
+
+// MaintenanceConfig forces maintenance mode on, which admins can otherwise
+// turn on and off through the API. RetryAfter and Message apply when the
+// mode is on without its own.
+type MaintenanceConfig struct {
+	Enabled    bool
+	Message    string
+	RetryAfter int // seconds
+}
+
+// WebhookConfig controls how events are sent to webhooks, which need the
+// event stream
+type WebhookConfig struct {
+	Timeout     int // seconds a receiver has to answer
+	MaxAttempts int // before a delivery fails
+	// AllowPrivate lets receivers be on loopback, private and link-local
+	// addresses, which are refused otherwise
+	AllowPrivate bool
+}
+
+// WebSocketConfig limits the live update connections of each server
+type WebSocketConfig struct {
+	MaxConnections int
+	MaxPerUser     int
+	PingInterval   int // seconds between pings; clients that miss two are dropped
+}
+
+// SchedulerConfig holds the cron schedules of the recurring tasks, in UTC.
+// An empty schedule disables its task.
+type SchedulerConfig struct {
+	Enabled       bool
+	ExpiredFiles  string // deletes files past their expiry
+	ExpiredShares string // deletes share links that expired or ran out of downloads
+	OrphanScan    string // deletes objects and shares left without a file
+	Usage         string // adds up the storage used per user
+	StatsRebuild  string // counts users, posts and files again
+	Digest        string // emails users what is new
+}
+
+// MaxSizeFor returns the upload limit for a user with role. Passing a
+// content type also applies any matching per-type limit.
+func (u UploadConfig) MaxSizeFor(role, contentType string) int64 {
+	limit := u.MaxFileSize
+	if roleLimit, ok := u.RoleMaxFileSize[role]; ok {
+		limit = roleLimit
+	}
+
+	if contentType != "" {
+		media, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+		media = strings.TrimSpace(media)
+		for pattern, typeLimit := range u.TypeMaxFileSize {
+			prefix, wildcard := strings.CutSuffix(pattern, "/*")
+			if (pattern == media || wildcard && strings.HasPrefix(media, prefix+"/")) && typeLimit < limit {
+				limit = typeLimit
+			}
+		}
+	}
+
+	return limit
+}
+
+// Load reads the configuration from the environment and, when CONFIG_FILE
+// names one, a config file
 func Load() (*Config, error) {
-	return &Config{
-		Port: getEnv("PORT", "8080"),
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile reads the configuration from the environment and the config file
+// at path, if any, and fetches the secrets of the secrets provider they
+// select. Variables set in the environment win over the secrets, which win
+// over the file, which wins over the defaults. Settings in the file or the
+// secrets that are not known are an error.
+func LoadFile(path string) (*Config, error) {
+	var file map[string]fileValue
+	if path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	e := newEnv(file)
+
+	secrets := SecretsConfig{
+		Provider:           e.getEnv("SECRETS_PROVIDER", ""),
+		RefreshInterval:    e.getEnvInt("SECRETS_REFRESH_INTERVAL", 0),
+		VaultAddr:          e.getEnv("VAULT_ADDR", ""),
+		VaultToken:         e.getEnv("VAULT_TOKEN", ""),
+		VaultMount:         e.getEnv("VAULT_MOUNT", "secret"),
+		VaultPath:          e.getEnv("VAULT_SECRET_PATH", ""),
+		AWSRegion:          e.getEnv("AWS_REGION", ""),
+		AWSSecretID:        e.getEnv("AWS_SECRET_ID", ""),
+		AWSAccessKeyID:     e.getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey: e.getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		AWSSessionToken:    e.getEnv("AWS_SESSION_TOKEN", ""),
+		AWSEndpoint:        e.getEnv("AWS_SECRETS_ENDPOINT", ""),
+	}
+	if secrets.Provider != "" {
+		if err := e.fetchSecrets(secrets); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{
+		Environment:   e.getEnv("APP_ENV", EnvDevelopment),
+		Port:          e.getEnv("PORT", "8080"),
+		WatchInterval: e.getEnvInt("CONFIG_WATCH_INTERVAL", 10),
+		CORS: CORSConfig{
+			AllowedOrigins: e.getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		},
+		TLS: TLSConfig{
+			CertFile:             e.getEnv("TLS_CERT_FILE", ""),
+			KeyFile:              e.getEnv("TLS_KEY_FILE", ""),
+			AutocertDomains:      e.getEnvList("TLS_AUTOCERT_DOMAINS", nil),
+			AutocertEmail:        e.getEnv("TLS_AUTOCERT_EMAIL", ""),
+			AutocertCacheDir:     e.getEnv("TLS_AUTOCERT_CACHE_DIR", "autocert"),
+			AutocertDirectoryURL: e.getEnv("TLS_AUTOCERT_DIRECTORY_URL", ""),
+			RedirectPort:         e.getEnv("TLS_REDIRECT_PORT", ""),
+			MinVersion:           e.getEnv("TLS_MIN_VERSION", "1.2"),
+			HSTSMaxAge:           e.getEnvInt("TLS_HSTS_MAX_AGE", 0),
+		},
+		GRPC: GRPCConfig{
+			Port:       e.getEnv("GRPC_PORT", ""),
+			Reflection: e.getEnvBool("GRPC_REFLECTION", false),
+		},
+		S3Gateway: S3GatewayConfig{
+			Port: e.getEnv("S3_GATEWAY_PORT", ""),
+		},
+		Secrets: secrets,
 		MinIO: MinIOConfig{
-			Endpoint:        getEnv("MINIO_ENDPOINT", "localhost:9000"),
-			AccessKeyID:     getEnv("MINIO_ACCESS_KEY", "minioadmin"),
-			SecretAccessKey: getEnv("MINIO_SECRET_KEY", "minioadmin123"),
-			UseSSL:          getEnvBool("MINIO_USE_SSL", false),
-			Region:          getEnv("MINIO_REGION", "us-east-1"),
+			Endpoint:        e.getEnv("MINIO_ENDPOINT", "localhost:9000"),
+			AccessKeyID:     e.getEnv("MINIO_ACCESS_KEY", defaultMinIOAccessKey),
+			SecretAccessKey: e.getEnv("MINIO_SECRET_KEY", defaultMinIOSecretKey),
+			UseSSL:          e.getEnvBool("MINIO_USE_SSL", false),
+			Region:          e.getEnv("MINIO_REGION", "us-east-1"),
+			Notifications:   e.getEnvBool("MINIO_NOTIFICATIONS", true),
+
+			MaxIdleConns:          e.getEnvInt("MINIO_MAX_IDLE_CONNS", 256),
+			MaxIdleConnsPerHost:   e.getEnvInt("MINIO_MAX_IDLE_CONNS_PER_HOST", 16),
+			MaxConnsPerHost:       e.getEnvInt("MINIO_MAX_CONNS_PER_HOST", 0),
+			IdleConnTimeout:       e.getEnvInt("MINIO_IDLE_CONN_TIMEOUT", 60),
+			DialTimeout:           e.getEnvInt("MINIO_DIAL_TIMEOUT", 30),
+			ResponseHeaderTimeout: e.getEnvInt("MINIO_RESPONSE_HEADER_TIMEOUT", 60),
+			CAFile:                e.getEnv("MINIO_CA_FILE", ""),
+			Trace:                 e.getEnvBool("MINIO_TRACE", false),
 		},
 		Redis: RedisConfig{
-			URL:      getEnv("REDIS_URL", "localhost:6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvInt("REDIS_DB", 0),
+			Addr:     e.getEnv("REDIS_ADDR", "localhost:6379"),
+			Password: e.getEnv("REDIS_PASSWORD", ""),
+			DB:       e.getEnvInt("REDIS_DB", 0),
+		},
+		Cache: CacheConfig{
+			Size: e.getEnvInt("CACHE_SIZE", 1000),
+			TTL:  e.getEnvInt("CACHE_TTL", 30),
 		},
 		NATS: NATSConfig{
-			URL: getEnv("NATS_URL", "localhost:4222"),
+			URL:            e.getEnv("NATS_URL", "localhost:4222"),
+			EventsEnabled:  e.getEnvBool("EVENTS_ENABLED", true),
+			EventRetention: e.getEnvInt("EVENTS_RETENTION", 168),
+			OutboxInterval: e.getEnvInt("EVENTS_OUTBOX_INTERVAL", 10),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			Expiration: getEnvInt("JWT_EXPIRATION", 24),
+			Secret:          e.getEnv("JWT_SECRET", defaultJWTSecret),
+			AccessTokenTTL:  e.getEnvInt("JWT_ACCESS_TOKEN_TTL", 15),
+			RefreshTokenTTL: e.getEnvInt("JWT_REFRESH_TOKEN_TTL", 720),
+			SigningKey:      e.getEnv("JWT_SIGNING_KEY", ""),
+			SigningKeyFile:  e.getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerifyKeys:      e.getEnv("JWT_VERIFY_KEYS", ""),
+			VerifyKeysFile:  e.getEnv("JWT_VERIFY_KEYS_FILE", ""),
+		},
+		Auth: AuthConfig{
+			ResetTokenTTL:  e.getEnvInt("PASSWORD_RESET_TTL", 30),
+			ResetURL:       e.getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+			ResetRateLimit: e.getEnvInt("PASSWORD_RESET_RATE_LIMIT", 5),
+
+			SessionTTL:       e.getEnvInt("SESSION_TTL", 24),
+			ImpersonationTTL: e.getEnvInt("IMPERSONATION_TTL", 10),
+
+			InviteOnly: e.getEnvBool("REGISTRATION_INVITE_ONLY", false),
+			InviteTTL:  e.getEnvInt("INVITE_TTL", 168),
+			InviteURL:  e.getEnv("INVITE_URL", "http://localhost:3000/auth/register"),
+
+			VerifyTTL: e.getEnvInt("EMAIL_VERIFICATION_TTL", 48),
+			VerifyURL: e.getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+
+			SigningSecret: e.getEnv("SIGNING_SECRET", defaultSigningSecret),
+
+			CaptchaProvider:       e.getEnv("CAPTCHA_PROVIDER", ""),
+			CaptchaSecret:         e.getEnv("CAPTCHA_SECRET", ""),
+			CaptchaLoginThreshold: e.getEnvInt("CAPTCHA_LOGIN_THRESHOLD", 3),
+
+			PasswordMinLength:     e.getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			PasswordRequireUpper:  e.getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			PasswordRequireLower:  e.getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			PasswordRequireDigit:  e.getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+			PasswordRequireSymbol: e.getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			PasswordBreachedList:  e.getEnv("PASSWORD_BREACHED_LIST", ""),
+
+			PasswordHashAlgorithm: e.getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			BcryptCost:            e.getEnvInt("PASSWORD_BCRYPT_COST", 12),
+			Argon2Memory:          e.getEnvInt("PASSWORD_ARGON2_MEMORY", 19456),
+			Argon2Iterations:      e.getEnvInt("PASSWORD_ARGON2_ITERATIONS", 2),
+			Argon2Parallelism:     e.getEnvInt("PASSWORD_ARGON2_PARALLELISM", 1),
+		},
+		LDAP: LDAPConfig{
+			URL:                e.getEnv("LDAP_URL", ""),
+			StartTLS:           e.getEnvBool("LDAP_START_TLS", false),
+			InsecureSkipVerify: e.getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+			Timeout:            e.getEnvInt("LDAP_TIMEOUT", 10),
+			BindDN:             e.getEnv("LDAP_BIND_DN", ""),
+			BindPassword:       e.getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:             e.getEnv("LDAP_BASE_DN", ""),
+			UserFilter:         e.getEnv("LDAP_USER_FILTER", "(|(uid=%s)(sAMAccountName=%s)(mail=%s))"),
+			UsernameAttribute:  e.getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+			EmailAttribute:     e.getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			FirstNameAttribute: e.getEnv("LDAP_FIRST_NAME_ATTRIBUTE", "givenName"),
+			LastNameAttribute:  e.getEnv("LDAP_LAST_NAME_ATTRIBUTE", "sn"),
+			GroupAttribute:     e.getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+			GroupRoles:         e.getEnv("LDAP_GROUP_ROLES", ""),
+			DefaultRole:        e.getEnv("LDAP_DEFAULT_ROLE", "user"),
+		},
+		SCIM: SCIMConfig{
+			Token: e.getEnv("SCIM_TOKEN", ""),
+		},
+		OAuth: OAuthConfig{
+			GoogleClientID:     e.getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
+			GoogleClientSecret: e.getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+			GitHubClientID:     e.getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
+			GitHubClientSecret: e.getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
+			CallbackURL:        e.getEnv("OAUTH_CALLBACK_URL", "http://localhost:8080/api/v1/auth/oauth"),
+			SuccessURL:         e.getEnv("OAUTH_SUCCESS_URL", "http://localhost:3000/auth/callback"),
+		},
+		Mail: MailConfig{
+			SMTPHost:     e.getEnv("SMTP_HOST", ""),
+			SMTPPort:     e.getEnvInt("SMTP_PORT", 587),
+			SMTPUsername: e.getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: e.getEnv("SMTP_PASSWORD", ""),
+			From:         e.getEnv("MAIL_FROM", "MinIO Storage <noreply@localhost>"),
+			DevMode:      e.getEnvBool("MAIL_DEV_MODE", false),
+			ShareURL:     e.getEnv("SHARE_URL", "http://localhost:3000/shares"),
+			AppURL:       e.getEnv("APP_URL", "http://localhost:3000"),
 		},
 		Database: DatabaseConfig{
-			UsersBucket: getEnv("USERS_BUCKET", "users"),
-			PostsBucket: getEnv("POSTS_BUCKET", "posts"),
-			FilesBucket: getEnv("FILES_BUCKET", "files"),
+			UsersBucket: e.getEnv("USERS_BUCKET", "users"),
+			PostsBucket: e.getEnv("POSTS_BUCKET", "posts"),
+			FilesBucket: e.getEnv("FILES_BUCKET", "files"),
+		},
+		Comments: CommentsConfig{
+			MaxDepth: e.getEnvInt("COMMENTS_MAX_DEPTH", 5),
+		},
+		Moderation: ModerationConfig{
+			Keywords:        e.getEnvList("MODERATION_KEYWORDS", nil),
+			MaxLinks:        e.getEnvInt("MODERATION_MAX_LINKS", 0),
+			NewAccountHours: e.getEnvInt("MODERATION_NEW_ACCOUNT_HOURS", 0),
+			ReportThreshold: e.getEnvInt("MODERATION_REPORT_THRESHOLD", 5),
+			SpamChecker:     e.getEnv("SPAM_CHECKER", "heuristic"),
+			SpamCheckURL:    e.getEnv("SPAM_CHECK_URL", ""),
+			SpamCheckKey:    e.getEnv("SPAM_CHECK_KEY", ""),
+		},
+		Upload: UploadConfig{
+			MaxFileSize:   e.getEnvSize("MAX_FILE_SIZE", 100<<20),
+			PresignExpiry: e.getEnvInt("UPLOAD_PRESIGN_EXPIRY", 15),
+			MaxVersions:   e.getEnvInt("MAX_FILE_VERSIONS", 10),
+			FormMemory:    e.getEnvSize("UPLOAD_FORM_MEMORY", 32<<20),
+			AvatarMaxSize: e.getEnvSize("AVATAR_MAX_SIZE", 5<<20),
+			StripExif:     e.getEnvBool("UPLOAD_STRIP_EXIF", false),
+			KeepExif:      e.getEnvBool("UPLOAD_KEEP_EXIF", false),
+
+			BatchMaxSize:     e.getEnvSize("UPLOAD_BATCH_MAX_SIZE", 1<<30),
+			BatchMaxFiles:    e.getEnvInt("UPLOAD_BATCH_MAX_FILES", 100),
+			BatchConcurrency: e.getEnvInt("UPLOAD_BATCH_CONCURRENCY", 4),
+
+			RoleMaxFileSize: e.getEnvSizeMap("MAX_FILE_SIZE_BY_ROLE"),
+			TypeMaxFileSize: e.getEnvSizeMap("MAX_FILE_SIZE_BY_TYPE"),
+
+			AllowedTypes:      e.getEnvList("UPLOAD_ALLOWED_TYPES", nil),
+			DeniedTypes:       e.getEnvList("UPLOAD_DENIED_TYPES", nil),
+			AllowedExtensions: e.getEnvList("UPLOAD_ALLOWED_EXTENSIONS", nil),
+			DeniedExtensions:  e.getEnvList("UPLOAD_DENIED_EXTENSIONS", nil),
+		},
+		Scan: ScanConfig{
+			Enabled:      e.getEnvBool("SCAN_ENABLED", false),
+			ClamdAddress: e.getEnv("CLAMD_ADDRESS", "localhost:3310"),
+			Timeout:      e.getEnvInt("SCAN_TIMEOUT", 120),
+		},
+		Preview: PreviewConfig{
+			Enabled:         e.getEnvBool("PREVIEW_ENABLED", false),
+			PdftoppmPath:    e.getEnv("PREVIEW_PDFTOPPM_PATH", "pdftoppm"),
+			LibreOfficePath: e.getEnv("PREVIEW_LIBREOFFICE_PATH", "soffice"),
+			Size:            e.getEnvInt("PREVIEW_SIZE", 1024),
+			Timeout:         e.getEnvInt("PREVIEW_TIMEOUT", 120),
+		},
+		Video: VideoConfig{
+			Enabled:     e.getEnvBool("VIDEO_TRANSCODE_ENABLED", false),
+			FFmpegPath:  e.getEnv("VIDEO_FFMPEG_PATH", "ffmpeg"),
+			FFprobePath: e.getEnv("VIDEO_FFPROBE_PATH", "ffprobe"),
+			Renditions:  e.getEnvList("VIDEO_RENDITIONS", []string{"720p", "480p", "360p"}),
+			Timeout:     e.getEnvInt("VIDEO_TRANSCODE_TIMEOUT", 60),
+		},
+		Download: DownloadConfig{
+			PresignPublic:     e.getEnvBool("DOWNLOAD_PRESIGN_PUBLIC", false),
+			PresignExpiry:     e.getEnvInt("DOWNLOAD_PRESIGN_EXPIRY", 60),
+			PublicCacheMaxAge: e.getEnvInt("DOWNLOAD_PUBLIC_CACHE_MAX_AGE", 3600),
+		},
+		Request: RequestConfig{
+			MaxBodySize:       e.getEnvSize("MAX_REQUEST_BODY_SIZE", 1<<20),
+			MaxUploadBodySize: e.getEnvSize("MAX_UPLOAD_REQUEST_SIZE", 2<<30),
+			Timeout:           e.getEnvInt("REQUEST_TIMEOUT", 30),
+			AdminTimeout:      e.getEnvInt("REQUEST_TIMEOUT_ADMIN", 120),
+			TransferTimeout:   e.getEnvInt("REQUEST_TIMEOUT_TRANSFER", 0),
 		},
-	}, nil
+		Network: NetworkConfig{
+			// Loopback and private networks, where reverse proxies usually run
+			TrustedProxies:  e.getEnvList("TRUSTED_PROXIES", []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}),
+			AllowedIPs:      e.getEnvList("IP_ALLOWLIST", nil),
+			DeniedIPs:       e.getEnvList("IP_DENYLIST", nil),
+			AdminAllowedIPs: e.getEnvList("ADMIN_IP_ALLOWLIST", nil),
+			AdminDeniedIPs:  e.getEnvList("ADMIN_IP_DENYLIST", nil),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: e.getEnvBool("RATE_LIMIT_ENABLED", true),
+			Auth:    e.getEnvRateLimit("RATE_LIMIT_AUTH", 10, 20),
+			Public:  e.getEnvRateLimit("RATE_LIMIT_PUBLIC", 60, 60),
+			API:     e.getEnvRateLimit("RATE_LIMIT_API", 300, 100),
+		},
+		Log: LogConfig{
+			Level:            e.getEnv("LOG_LEVEL", "info"),
+			Format:           e.getEnv("LOG_FORMAT", "json"),
+			AccessSampleRate: e.getEnvFloat("LOG_ACCESS_SAMPLE_RATE", 1),
+			SlowRequest:      e.getEnvInt("LOG_SLOW_REQUEST", 1000),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    e.getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			ServiceName: e.getEnv("OTEL_SERVICE_NAME", "minio-storage-backend"),
+			SampleRatio: e.getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1),
+		},
+		Reporting: ReportingConfig{
+			SentryDSN:   e.getEnv("SENTRY_DSN", ""),
+			Environment: e.getEnv("SENTRY_ENVIRONMENT", ""),
+			WebhookURL:  e.getEnv("ERROR_WEBHOOK_URL", ""),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:    e.getEnvBool("MAINTENANCE_MODE", false),
+			Message:    e.getEnv("MAINTENANCE_MESSAGE", "The service is in maintenance and read-only, try again later"),
+			RetryAfter: e.getEnvInt("MAINTENANCE_RETRY_AFTER", 300),
+		},
+		Webhook: WebhookConfig{
+			Timeout:      e.getEnvInt("WEBHOOK_TIMEOUT", 10),
+			MaxAttempts:  e.getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			AllowPrivate: e.getEnvBool("WEBHOOK_ALLOW_PRIVATE", false),
+		},
+		WebSocket: WebSocketConfig{
+			MaxConnections: e.getEnvInt("WS_MAX_CONNECTIONS", 1000),
+			MaxPerUser:     e.getEnvInt("WS_MAX_CONNECTIONS_PER_USER", 5),
+			PingInterval:   e.getEnvInt("WS_PING_INTERVAL", 30),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:       e.getEnvBool("SCHEDULER_ENABLED", true),
+			ExpiredFiles:  e.getEnv("SCHEDULE_EXPIRED_FILES", "*/5 * * * *"),
+			ExpiredShares: e.getEnv("SCHEDULE_EXPIRED_SHARES", "0 * * * *"),
+			OrphanScan:    e.getEnv("SCHEDULE_ORPHAN_SCAN", "30 3 * * *"),
+			Usage:         e.getEnv("SCHEDULE_USAGE", "0 2 * * *"),
+			StatsRebuild:  e.getEnv("SCHEDULE_STATS_REBUILD", "0 4 * * *"),
+			Digest:        e.getEnv("SCHEDULE_DIGEST", ""),
+		},
+	}
+
+	if err := e.unknown(); err != nil {
+		return nil, err
+	}
+	cfg.settings = e.resolved()
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+func (e *env) getEnv(key, defaultValue string) string {
+	if value := e.lookup(key); value != "" {
 		return value
 	}
+	e.useDefault(key, defaultValue)
 	return defaultValue
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+func (e *env) getEnvInt(key string, defaultValue int) int {
+	if value := e.lookup(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
 			return intValue
 		}
 	}
+	e.useDefault(key, strconv.Itoa(defaultValue))
+	return defaultValue
+}
+
+func (e *env) getEnvFloat(key string, defaultValue float64) float64 {
+	if value := e.lookup(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	e.useDefault(key, strconv.FormatFloat(defaultValue, 'g', -1, 64))
 	return defaultValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
+// getEnvRateLimit reads the limit named prefix from prefix_PER_MINUTE and
+// prefix_BURST
+func (e *env) getEnvRateLimit(prefix string, perMinute, burst int) RateLimit {
+	return RateLimit{
+		PerMinute: e.getEnvInt(prefix+"_PER_MINUTE", perMinute),
+		Burst:     e.getEnvInt(prefix+"_BURST", burst),
+	}
+}
+
+func (e *env) getEnvBool(key string, defaultValue bool) bool {
+	if value := e.lookup(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
 			return boolValue
 		}
 	}
+	e.useDefault(key, strconv.FormatBool(defaultValue))
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated value, dropping empty entries.
+func (e *env) getEnvList(key string, defaultValue []string) []string {
+	value := e.lookup(key)
+	if value == "" {
+		e.useDefault(key, strings.Join(defaultValue, ","))
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvSizeMap parses "key=size" pairs such as "user=50MB,admin=1GB".
+// Keys are lower-cased; malformed pairs are ignored.
+func (e *env) getEnvSizeMap(key string) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, pair := range e.getEnvList(key, nil) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if size, ok := parseSize(value); ok {
+			sizes[strings.ToLower(strings.TrimSpace(name))] = size
+		}
+	}
+	return sizes
+}
+
+// getEnvSize parses byte sizes such as "512", "64KB", "100MB" or "2GB".
+func (e *env) getEnvSize(key string, defaultValue int64) int64 {
+	if value := e.lookup(key); value != "" {
+		if size, ok := parseSize(value); ok {
+			return size
+		}
+	}
+	e.useDefault(key, strconv.FormatInt(defaultValue, 10))
 	return defaultValue
 }
+
+func parseSize(value string) (int64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.factor
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size * multiplier, true
+}
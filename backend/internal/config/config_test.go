@@ -65,3 +65,63 @@ func TestLoadMissingJWTSecret(t *testing.T) {
 	assert.NotNil(t, cfg)
 	assert.Equal(t, "your-super-secret-jwt-key", cfg.JWT.Secret) // Default value
 }
+
+func TestLoadUploadSize(t *testing.T) {
+	os.Setenv("MAX_FILE_SIZE", "25MB")
+	defer os.Unsetenv("MAX_FILE_SIZE")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25<<20), cfg.Upload.MaxFileSize)
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"64KB":  64 << 10,
+		"100mb": 100 << 20,
+		"2 GB":  2 << 30,
+	}
+	for input, expected := range cases {
+		size, ok := parseSize(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	_, ok := parseSize("lots")
+	assert.False(t, ok)
+}
+
+func TestLoadUploadTypeLists(t *testing.T) {
+	os.Setenv("UPLOAD_DENIED_EXTENSIONS", ".exe, .bat,,")
+	defer os.Unsetenv("UPLOAD_DENIED_EXTENSIONS")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".exe", ".bat"}, cfg.Upload.DeniedExtensions)
+	assert.Nil(t, cfg.Upload.AllowedTypes)
+}
+
+func TestUploadMaxSizeFor(t *testing.T) {
+	os.Setenv("MAX_FILE_SIZE", "100MB")
+	os.Setenv("MAX_FILE_SIZE_BY_ROLE", "admin=1GB, guest=10MB, broken")
+	os.Setenv("MAX_FILE_SIZE_BY_TYPE", "image/*=20MB,application/pdf=5GB")
+	defer func() {
+		os.Unsetenv("MAX_FILE_SIZE")
+		os.Unsetenv("MAX_FILE_SIZE_BY_ROLE")
+		os.Unsetenv("MAX_FILE_SIZE_BY_TYPE")
+	}()
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+
+	upload := cfg.Upload
+	assert.Equal(t, int64(100<<20), upload.MaxSizeFor("user", ""))
+	assert.Equal(t, int64(1<<30), upload.MaxSizeFor("admin", ""))
+	assert.Equal(t, int64(10<<20), upload.MaxSizeFor("guest", ""))
+	assert.Equal(t, int64(20<<20), upload.MaxSizeFor("admin", "image/png"))
+	assert.Equal(t, int64(10<<20), upload.MaxSizeFor("guest", "image/png"))
+	// Type limits only ever lower the role limit
+	assert.Equal(t, int64(100<<20), upload.MaxSizeFor("user", "application/pdf"))
+	assert.Equal(t, int64(100<<20), upload.MaxSizeFor("user", "video/mp4"))
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A config file holds the same settings as the environment, in YAML or
+// JSON. Nested sections join their keys with underscores into the
+// variable names, so these set MINIO_ENDPOINT and JWT_ACCESS_TOKEN_TTL:
+//
+//	minio:
+//	  endpoint: minio:9000
+//	jwt:
+//	  access_token_ttl: 15
+//
+// Keys are case-insensitive and may use dashes. Lists become
+// comma-separated values and mappings such as max_file_size_by_role
+// become key=value pairs. Variables set in the environment win over the
+// file, which wins over the defaults.
+
+// fileValue is one setting of a config file
+type fileValue struct {
+	path   string // as written in the file, for messages
+	value  string
+	parent string // variable of the enclosing mapping, which may be read whole
+	// mapping marks the key=value pairs of a mapping, which is a section
+	// unless read as a whole
+	mapping bool
+}
+
+// readFile reads the settings of the config file at path, keyed by their
+// variable names
+func readFile(path string) (map[string]fileValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]fileValue{}
+	if len(root.Content) == 0 {
+		return values, nil
+	}
+	if root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s must hold a mapping of settings", path)
+	}
+	if err := flatten(root.Content[0], "", "", values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten adds the settings of mapping node under the variable prefix to
+// values
+func flatten(node *yaml.Node, prefix, path string, values map[string]fileValue) error {
+	var pairs []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		keyPath := key
+		if prefix != "" {
+			name = prefix + "_" + name
+			keyPath = path + "." + key
+		}
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("%s is set twice", keyPath)
+		}
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			if err := flatten(value, name, keyPath, values); err != nil {
+				return err
+			}
+			continue
+		case yaml.SequenceNode:
+			items := make([]string, len(value.Content))
+			for j, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("%s must be a list of values", keyPath)
+				}
+				items[j] = item.Value
+			}
+			values[name] = fileValue{path: keyPath, value: strings.Join(items, ","), parent: prefix}
+		case yaml.ScalarNode:
+			values[name] = fileValue{path: keyPath, value: value.Value, parent: prefix}
+			pairs = append(pairs, key+"="+value.Value)
+		default:
+			return fmt.Errorf("%s has an unsupported value", keyPath)
+		}
+	}
+
+	// A mapping of values also stands for the variable of its own name,
+	// for settings that are maps themselves
+	if prefix != "" && len(pairs) > 0 {
+		if _, ok := values[prefix]; !ok {
+			values[prefix] = fileValue{path: path, value: strings.Join(pairs, ","), mapping: true}
+		}
+	}
+	return nil
+}
+
+// env looks settings up in the environment, then in the secrets fetched
+// from a secrets provider, then in the config file
+type env struct {
+	file     map[string]fileValue
+	secrets  map[string]string
+	settings map[string]Setting // of the variables read so far
+}
+
+func newEnv(file map[string]fileValue) *env {
+	return &env{file: file, settings: map[string]Setting{}}
+}
+
+// lookup returns the value of variable key, "" when it is not set
+func (e *env) lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		e.set(key, value, SourceEnv)
+		return value
+	}
+	if value := e.secrets[key]; value != "" {
+		e.set(key, value, SourceSecrets)
+		return value
+	}
+	if value := e.file[key].value; value != "" {
+		e.set(key, value, SourceFile)
+		return value
+	}
+	e.set(key, "", SourceDefault)
+	return ""
+}
+
+// read tells whether variable key was looked up
+func (e *env) read(key string) bool {
+	_, ok := e.settings[key]
+	return ok
+}
+
+// unknown returns an error naming the settings of the config file and
+// the secrets that were never looked up, which are most likely misspelled
+func (e *env) unknown() error {
+	var paths, secrets []string
+	for name, v := range e.file {
+		if !v.mapping && !e.read(name) && !e.read(v.parent) {
+			paths = append(paths, v.path)
+		}
+	}
+	for name := range e.secrets {
+		if !e.read(name) {
+			secrets = append(secrets, name)
+		}
+	}
+
+	var errs []error
+	if len(paths) > 0 {
+		sort.Strings(paths)
+		errs = append(errs, fmt.Errorf("unknown settings in config file: %s", strings.Join(paths, ", ")))
+	}
+	if len(secrets) > 0 {
+		sort.Strings(secrets)
+		errs = append(errs, fmt.Errorf("unknown settings in secrets: %s", strings.Join(secrets, ", ")))
+	}
+	return errors.Join(errs...)
+}
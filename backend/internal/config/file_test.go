@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadFileYAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+port: 9090
+minio:
+  endpoint: minio:9000
+  use-ssl: true
+jwt:
+  access_token_ttl: 5
+rate_limit:
+  api:
+    burst: 7
+upload:
+  denied_extensions: [.exe, .bat]
+max_file_size_by_role:
+  admin: 1GB
+  guest: 10MB
+schedule:
+  digest: "0 8 * * 1"
+`)
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.Port)
+	assert.Equal(t, "minio:9000", cfg.MinIO.Endpoint)
+	assert.True(t, cfg.MinIO.UseSSL)
+	assert.Equal(t, 5, cfg.JWT.AccessTokenTTL)
+	assert.Equal(t, 7, cfg.RateLimit.API.Burst)
+	assert.Equal(t, 300, cfg.RateLimit.API.PerMinute, "defaults fill the rest")
+	assert.Equal(t, []string{".exe", ".bat"}, cfg.Upload.DeniedExtensions)
+	assert.Equal(t, map[string]int64{"admin": 1 << 30, "guest": 10 << 20}, cfg.Upload.RoleMaxFileSize)
+	assert.Equal(t, "0 8 * * 1", cfg.Scheduler.Digest)
+}
+
+func TestLoadFileJSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"redis": {"addr": "redis:6379", "db": 2}, "LOG_LEVEL": "debug"}`)
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:6379", cfg.Redis.Addr)
+	assert.Equal(t, 2, cfg.Redis.DB)
+	assert.Equal(t, "debug", cfg.Log.Level)
+}
+
+func TestLoadFileEnvironmentWins(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "port: 9090\njwt:\n  secret: from-file\n")
+	t.Setenv("PORT", "7070")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "7070", cfg.Port)
+	assert.Equal(t, "from-file", cfg.JWT.Secret)
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+
+	_, err = LoadFile(writeConfigFile(t, "typo.yaml", "minio:\n  endpont: minio:9000\n  region: eu\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "minio.endpont")
+	assert.NotContains(t, err.Error(), "region")
+
+	_, err = LoadFile(writeConfigFile(t, "twice.yaml", "minio_endpoint: a\nminio:\n  endpoint: b\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set twice")
+
+	_, err = LoadFile(writeConfigFile(t, "list.yaml", "- port: 8080\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigFileVariable(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "port: 6060\n"))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "6060", cfg.Port)
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotReloadable is returned by Reload when settings changed that only
+// take effect after a restart
+var ErrNotReloadable = errors.New("settings changed that need a restart")
+
+// Live holds the configuration of the running server. Reload reads it
+// again and swaps it in when only settings that can change at runtime
+// differ: rate limits, CORS origins, the log level, the feature flags
+// REGISTRATION_INVITE_ONLY, UPLOAD_STRIP_EXIF and DOWNLOAD_PRESIGN_PUBLIC,
+// and the MinIO and SMTP credentials, which rotate in a secrets provider.
+// Code that should follow these reads them through Get on every use.
+type Live struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu      sync.Mutex // serializes reloads
+	hooks   []func(cfg *Config)
+	version string // of the config file last read
+}
+
+// NewLive returns cfg, loaded from the config file at path if any, as the
+// live configuration
+func NewLive(cfg *Config, path string) *Live {
+	l := &Live{path: path, version: fileVersion(path)}
+	l.current.Store(cfg)
+	return l
+}
+
+// Path returns the config file the configuration is loaded from, "" for
+// none
+func (l *Live) Path() string {
+	return l.path
+}
+
+// Get returns the current configuration, which must not be modified
+func (l *Live) Get() *Config {
+	return l.current.Load()
+}
+
+// OnReload registers hook to be called with the new configuration after
+// each successful reload, for settings held outside of Live
+func (l *Live) OnReload(hook func(cfg *Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Reload reads the environment, config file and secrets again. When the new
+// configuration is valid and only reloadable settings changed it becomes
+// current and the names of the changed settings are returned. Otherwise
+// the current configuration stays, and changes that need a restart are
+// reported with ErrNotReloadable.
+func (l *Live) Reload() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A file that fails to load is not tried again until it changes
+	l.version = fileVersion(l.path)
+	next, err := LoadFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	current := l.Get()
+	if fixed := diff(withReloadable(current, next), next); len(fixed) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotReloadable, strings.Join(fixed, ", "))
+	}
+	changed := diff(current, next)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	l.current.Store(next)
+	for _, hook := range l.hooks {
+		hook(next)
+	}
+	return changed, nil
+}
+
+// Watch reloads the configuration whenever the config file changes, checking
+// every interval until ctx is done, and passes each outcome to report.
+// Without a config file there is nothing to watch.
+func (l *Live) Watch(ctx context.Context, interval time.Duration, report func(changed []string, err error)) {
+	if l.path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		unchanged := fileVersion(l.path) == l.version
+		l.mu.Unlock()
+		if unchanged {
+			continue
+		}
+		changed, err := l.Reload()
+		report(changed, err)
+	}
+}
+
+// Refresh reloads the configuration every interval until ctx is done, to
+// pick up secrets rotated in the secrets provider, and passes outcomes
+// with changes or errors to report
+func (l *Live) Refresh(ctx context.Context, interval time.Duration, report func(changed []string, err error)) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if changed, err := l.Reload(); err != nil || len(changed) > 0 {
+			report(changed, err)
+		}
+	}
+}
+
+// fileVersion tells versions of the file at path apart, "" when it cannot
+// be read
+func fileVersion(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+}
+
+// withReloadable returns a copy of cfg with the reloadable settings of next
+func withReloadable(cfg, next *Config) *Config {
+	merged := *cfg
+	merged.RateLimit = next.RateLimit
+	merged.CORS = next.CORS
+	merged.Log.Level = next.Log.Level
+	merged.Auth.InviteOnly = next.Auth.InviteOnly
+	merged.Upload.StripExif = next.Upload.StripExif
+	merged.Download.PresignPublic = next.Download.PresignPublic
+	merged.MinIO.AccessKeyID = next.MinIO.AccessKeyID
+	merged.MinIO.SecretAccessKey = next.MinIO.SecretAccessKey
+	merged.Mail.SMTPUsername = next.Mail.SMTPUsername
+	merged.Mail.SMTPPassword = next.Mail.SMTPPassword
+	return &merged
+}
+
+// diff returns the names of the settings that differ between a and b, as
+// Section.Field. Values are left out as they may be secrets.
+func diff(a, b *Config) []string {
+	var names []string
+	va, vb := reflect.ValueOf(*a), reflect.ValueOf(*b)
+	for i := 0; i < va.NumField(); i++ {
+		section := va.Type().Field(i)
+		if !section.IsExported() {
+			continue
+		}
+		fa, fb := va.Field(i), vb.Field(i)
+		if reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			continue
+		}
+		if section.Type.Kind() != reflect.Struct {
+			names = append(names, section.Name)
+			continue
+		}
+		for j := 0; j < fa.NumField(); j++ {
+			if !reflect.DeepEqual(fa.Field(j).Interface(), fb.Field(j).Interface()) {
+				names = append(names, section.Name+"."+section.Type.Field(j).Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLive(t *testing.T, content string) (*Live, string) {
+	t.Helper()
+	path := writeConfigFile(t, "config.yaml", content)
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	return NewLive(cfg, path), path
+}
+
+func TestReload(t *testing.T) {
+	live, path := newTestLive(t, "rate_limit:\n  api:\n    burst: 10\nlog:\n  level: info\n")
+	var hooked *Config
+	live.OnReload(func(cfg *Config) { hooked = cfg })
+
+	changed, err := live.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.Nil(t, hooked, "hooks run only on changes")
+
+	require.NoError(t, os.WriteFile(path, []byte("rate_limit:\n  api:\n    burst: 20\nlog:\n  level: debug\ncors:\n  allowed_origins: [https://app.example.com]\nregistration:\n  invite_only: true\n"), 0o600))
+	changed, err = live.Reload()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"RateLimit.API", "Log.Level", "CORS.AllowedOrigins", "Auth.InviteOnly"}, changed)
+	assert.Equal(t, 20, live.Get().RateLimit.API.Burst)
+	assert.Equal(t, "debug", live.Get().Log.Level)
+	assert.True(t, live.Get().Auth.InviteOnly)
+	assert.Same(t, live.Get(), hooked)
+}
+
+func TestReloadRejectsRestartSettings(t *testing.T) {
+	live, path := newTestLive(t, "port: 8080\nrate_limit:\n  api:\n    burst: 10\n")
+	before := live.Get()
+
+	require.NoError(t, os.WriteFile(path, []byte("port: 9090\njwt:\n  secret: another-secret\nrate_limit:\n  api:\n    burst: 20\n"), 0o600))
+	_, err := live.Reload()
+	require.ErrorIs(t, err, ErrNotReloadable)
+	assert.Contains(t, err.Error(), "Port")
+	assert.Contains(t, err.Error(), "JWT.Secret")
+	assert.NotContains(t, err.Error(), "another-secret", "values are left out")
+	assert.Same(t, before, live.Get(), "nothing is applied")
+
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: loud\n"), 0o600))
+	_, err = live.Reload()
+	assert.ErrorContains(t, err, "LOG_LEVEL")
+	assert.Same(t, before, live.Get())
+}
+
+func TestWatch(t *testing.T) {
+	live, path := newTestLive(t, "log:\n  level: info\n")
+	reloaded := make(chan []string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go live.Watch(ctx, 10*time.Millisecond, func(changed []string, err error) {
+		assert.NoError(t, err)
+		reloaded <- changed
+	})
+
+	// A newer modification time, as coarse file systems might not see one
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: warn\n"), 0o600))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	select {
+	case changed := <-reloaded:
+		assert.Equal(t, []string{"Log.Level"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config file change not reloaded")
+	}
+	assert.Equal(t, "warn", live.Get().Log.Level)
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/secrets"
+)
+
+// fetchTimeout bounds fetching the secrets, which blocks loading
+const fetchTimeout = 15 * time.Second
+
+// fetchSecrets fetches the secrets of the provider cfg selects for lookup.
+// Their names are matched like config file keys, so jwt-secret sets
+// JWT_SECRET.
+func (e *env) fetchSecrets(cfg SecretsConfig) error {
+	provider, err := secrets.New(secrets.Options{
+		Provider:           cfg.Provider,
+		VaultAddr:          cfg.VaultAddr,
+		VaultToken:         cfg.VaultToken,
+		VaultMount:         cfg.VaultMount,
+		VaultPath:          cfg.VaultPath,
+		AWSRegion:          cfg.AWSRegion,
+		AWSSecretID:        cfg.AWSSecretID,
+		AWSAccessKeyID:     cfg.AWSAccessKeyID,
+		AWSSecretAccessKey: cfg.AWSSecretAccessKey,
+		AWSSessionToken:    cfg.AWSSessionToken,
+		AWSEndpoint:        cfg.AWSEndpoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	values, err := provider.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch secrets: %w", err)
+	}
+
+	e.secrets = make(map[string]string, len(values))
+	for name, value := range values {
+		e.secrets[strings.ToUpper(strings.ReplaceAll(name, "-", "_"))] = value
+	}
+	return nil
+}
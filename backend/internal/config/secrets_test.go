@@ -0,0 +1,57 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func vaultServer(t *testing.T, secret *string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"data": ` + *secret + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("SECRETS_PROVIDER", "vault")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+	t.Setenv("VAULT_SECRET_PATH", "minio-storage")
+}
+
+func TestLoadSecrets(t *testing.T) {
+	secret := `{"jwt-secret": "from-vault", "MINIO_SECRET_KEY": "from-vault", "SMTP_PASSWORD": "from-vault"}`
+	vaultServer(t, &secret)
+	t.Setenv("SMTP_PASSWORD", "from-env")
+	path := writeConfigFile(t, "config.yaml", "minio:\n  secret_key: from-file\n")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-vault", cfg.JWT.Secret)
+	assert.Equal(t, "from-vault", cfg.MinIO.SecretAccessKey, "secrets win over the file")
+	assert.Equal(t, "from-env", cfg.Mail.SMTPPassword, "the environment wins over secrets")
+
+	secret = `{"JWT_SECRTE": "typo"}`
+	_, err = LoadFile("")
+	assert.ErrorContains(t, err, "unknown settings in secrets: JWT_SECRTE")
+}
+
+func TestReloadRotatedSecrets(t *testing.T) {
+	secret := `{"MINIO_SECRET_KEY": "first", "JWT_SECRET": "first"}`
+	vaultServer(t, &secret)
+	cfg, err := LoadFile("")
+	require.NoError(t, err)
+	live := NewLive(cfg, "")
+
+	secret = `{"MINIO_SECRET_KEY": "second", "JWT_SECRET": "first"}`
+	changed, err := live.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"MinIO.SecretAccessKey"}, changed)
+	assert.Equal(t, "second", live.Get().MinIO.SecretAccessKey)
+
+	secret = `{"MINIO_SECRET_KEY": "second", "JWT_SECRET": "second"}`
+	_, err = live.Reload()
+	assert.ErrorIs(t, err, ErrNotReloadable)
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Sources of a setting, in the order they win
+const (
+	SourceEnv     = "env"
+	SourceSecrets = "secrets"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// maskedValue stands in for the values of secret settings
+const maskedValue = "********"
+
+// secretSettings are masked wherever they come from; everything fetched
+// from a secrets provider is masked as well
+var secretSettings = map[string]bool{
+	"VAULT_TOKEN":                true,
+	"AWS_SECRET_ACCESS_KEY":      true,
+	"AWS_SESSION_TOKEN":          true,
+	"MINIO_SECRET_KEY":           true,
+	"REDIS_PASSWORD":             true,
+	"JWT_SECRET":                 true,
+	"JWT_SIGNING_KEY":            true,
+	"SIGNING_SECRET":             true,
+	"CAPTCHA_SECRET":             true,
+	"SPAM_CHECK_KEY":             true,
+	"LDAP_BIND_PASSWORD":         true,
+	"SCIM_TOKEN":                 true,
+	"OAUTH_GOOGLE_CLIENT_SECRET": true,
+	"OAUTH_GITHUB_CLIENT_SECRET": true,
+	"SMTP_PASSWORD":              true,
+	"SENTRY_DSN":                 true,
+	"ERROR_WEBHOOK_URL":          true,
+}
+
+// Setting is a variable of the configuration as it was resolved
+type Setting struct {
+	Name   string
+	Value  string // masked for secrets
+	Source string // env, secrets, file or default
+	// Invalid is a value that was set but could not be parsed, so the
+	// default applies
+	Invalid string
+}
+
+// Settings returns every variable the configuration was loaded from,
+// sorted by name, with secrets masked; nil for a Config not loaded by
+// LoadFile
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// set records that key resolved to value from source; lookup records
+// every variable as it is read, so only defaults need recording after
+func (e *env) set(key, value, source string) {
+	e.settings[key] = Setting{Name: key, Value: value, Source: source}
+}
+
+// useDefault records that key resolved to defaultValue, keeping a value
+// that was set but not understood as invalid
+func (e *env) useDefault(key, defaultValue string) {
+	setting := e.settings[key]
+	setting.Name = key
+	if setting.Source != SourceDefault && setting.Value != "" {
+		setting.Invalid = setting.Value
+	}
+	setting.Value, setting.Source = defaultValue, SourceDefault
+	e.settings[key] = setting
+}
+
+// resolved returns the recorded settings, sorted and masked
+func (e *env) resolved() []Setting {
+	settings := make([]Setting, 0, len(e.settings))
+	for _, setting := range e.settings {
+		// A value from the secrets may have been invalid and replaced
+		if secretSettings[setting.Name] || setting.Source == SourceSecrets || e.secrets[setting.Name] != "" {
+			setting.Value, setting.Invalid = mask(setting.Value), mask(setting.Invalid)
+		} else {
+			setting.Value, setting.Invalid = redactURL(setting.Value), redactURL(setting.Invalid)
+		}
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// mask hides value, leaving empty values empty so unset secrets show
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+// redactURL hides the password of a URL with credentials, such as a NATS
+// server's
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
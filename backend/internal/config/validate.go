@@ -0,0 +1,338 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Environments the server runs in
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Defaults that must not be used outside development: the secrets shipped
+// in Load and in the development compose file
+const (
+	defaultJWTSecret      = "your-super-secret-jwt-key"
+	defaultSigningSecret  = "your-super-secret-signing-key"
+	defaultMinIOAccessKey = "minioadmin"
+	defaultMinIOSecretKey = "minioadmin123"
+)
+
+// minJWTSecretLength is the shortest JWT_SECRET or SIGNING_SECRET that is
+// safe to use
+const minJWTSecretLength = 32
+
+var weakSecrets = map[string]bool{
+	defaultJWTSecret:                          true,
+	defaultSigningSecret:                      true,
+	"dev-jwt-secret-key-for-development-only": true,
+	"dev-signing-secret-for-development-only": true,
+	defaultMinIOAccessKey:                     true,
+	defaultMinIOSecretKey:                     true,
+	"secret":                                  true,
+	"password":                                true,
+}
+
+// weakSecret reports whether value is a default or a placeholder copied
+// from an environment template, such as your-secret-key-here
+func weakSecret(value string) bool {
+	lower := strings.ToLower(value)
+	return weakSecrets[value] || strings.HasPrefix(lower, "your-") || strings.Contains(lower, "change-this") || strings.Contains(lower, "changeme")
+}
+
+// problems collects what is wrong with a configuration
+type problems struct {
+	errors   []error
+	warnings []string
+}
+
+func (p *problems) fail(format string, args ...any) {
+	p.errors = append(p.errors, fmt.Errorf(format, args...))
+}
+
+// unsafe reports a setting that is insecure rather than unusable; it only
+// stops the server outside development
+func (p *problems) unsafe(dev bool, format string, args ...any) {
+	if dev {
+		p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+		return
+	}
+	p.fail(format, args...)
+}
+
+func (p *problems) atLeast(name string, value, minimum int) {
+	if value < minimum {
+		p.fail("%s must be at least %d, got %d", name, minimum, value)
+	}
+}
+
+func (p *problems) between(name string, value, minimum, maximum int) {
+	if value < minimum || value > maximum {
+		p.fail("%s must be between %d and %d, got %d", name, minimum, maximum, value)
+	}
+}
+
+func (p *problems) ratio(name string, value float64) {
+	if value < 0 || value > 1 {
+		p.fail("%s must be between 0 and 1, got %g", name, value)
+	}
+}
+
+func (p *problems) required(name, value string) {
+	if strings.TrimSpace(value) == "" {
+		p.fail("%s must be set", name)
+	}
+}
+
+func (p *problems) oneOf(name, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	p.fail("%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value)
+}
+
+// Validate checks the configuration before the server starts. It returns
+// an error listing every setting that is out of range, missing or, outside
+// development, unsafe, such as the default JWT secret or MinIO credentials.
+// In development unsafe settings are returned as warnings instead, so a
+// local setup still starts.
+func (c *Config) Validate() (warnings []string, err error) {
+	var p problems
+	p.oneOf("APP_ENV", c.Environment, EnvDevelopment, EnvStaging, EnvProduction)
+	dev := c.Environment == EnvDevelopment
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		p.fail("PORT must be a port number, got %q", c.Port)
+	}
+
+	p.atLeast("CONFIG_WATCH_INTERVAL", c.WatchInterval, 0)
+	for _, origin := range c.CORS.AllowedOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "" || u.Path != "") {
+			p.fail("CORS_ALLOWED_ORIGINS must hold * or origins such as https://app.example.com, got %q", origin)
+		}
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		p.fail("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLS.CertFile != "" && len(c.TLS.AutocertDomains) > 0 {
+		p.fail("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS cannot both be set")
+	}
+	if len(c.TLS.AutocertDomains) > 0 {
+		p.required("TLS_AUTOCERT_CACHE_DIR", c.TLS.AutocertCacheDir)
+		if c.Port != "443" && c.TLS.RedirectPort != "80" {
+			p.unsafe(dev, "ACME certificates need PORT=443 or TLS_REDIRECT_PORT=80 to answer the CA's challenges")
+		}
+	}
+	if c.TLS.RedirectPort != "" {
+		if port, err := strconv.Atoi(c.TLS.RedirectPort); err != nil || port < 1 || port > 65535 {
+			p.fail("TLS_REDIRECT_PORT must be a port number, got %q", c.TLS.RedirectPort)
+		}
+		if !c.TLS.Enabled() {
+			p.fail("TLS_REDIRECT_PORT needs TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS")
+		}
+	}
+	if c.GRPC.Port != "" {
+		if port, err := strconv.Atoi(c.GRPC.Port); err != nil || port < 1 || port > 65535 {
+			p.fail("GRPC_PORT must be a port number, got %q", c.GRPC.Port)
+		}
+		if c.GRPC.Port == c.Port || c.GRPC.Port == c.TLS.RedirectPort {
+			p.fail("GRPC_PORT must differ from PORT and TLS_REDIRECT_PORT, got %q", c.GRPC.Port)
+		}
+	}
+	if c.S3Gateway.Port != "" {
+		if port, err := strconv.Atoi(c.S3Gateway.Port); err != nil || port < 1 || port > 65535 {
+			p.fail("S3_GATEWAY_PORT must be a port number, got %q", c.S3Gateway.Port)
+		}
+		if c.S3Gateway.Port == c.Port || c.S3Gateway.Port == c.TLS.RedirectPort || c.S3Gateway.Port == c.GRPC.Port {
+			p.fail("S3_GATEWAY_PORT must differ from PORT, TLS_REDIRECT_PORT and GRPC_PORT, got %q", c.S3Gateway.Port)
+		}
+	}
+	p.oneOf("TLS_MIN_VERSION", c.TLS.MinVersion, "1.2", "1.3")
+	p.atLeast("TLS_HSTS_MAX_AGE", c.TLS.HSTSMaxAge, 0)
+
+	switch c.Secrets.Provider {
+	case "":
+	case "vault":
+		p.required("VAULT_ADDR", c.Secrets.VaultAddr)
+		p.required("VAULT_SECRET_PATH", c.Secrets.VaultPath)
+	case "aws":
+		p.required("AWS_REGION", c.Secrets.AWSRegion)
+		p.required("AWS_SECRET_ID", c.Secrets.AWSSecretID)
+	default:
+		p.oneOf("SECRETS_PROVIDER", c.Secrets.Provider, "vault", "aws")
+	}
+	p.atLeast("SECRETS_REFRESH_INTERVAL", c.Secrets.RefreshInterval, 0)
+
+	// Endpoints
+	p.required("MINIO_ENDPOINT", c.MinIO.Endpoint)
+	if strings.Contains(c.MinIO.Endpoint, "://") {
+		p.fail("MINIO_ENDPOINT must be host:port without a scheme, got %q; use MINIO_USE_SSL for https", c.MinIO.Endpoint)
+	}
+	p.required("REDIS_ADDR", c.Redis.Addr)
+	p.required("NATS_URL", c.NATS.URL)
+	if c.Scan.Enabled {
+		p.required("CLAMD_ADDRESS", c.Scan.ClamdAddress)
+	}
+	if c.LDAP.URL != "" {
+		if u, err := url.Parse(c.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+			p.fail("LDAP_URL must be an ldap:// or ldaps:// URL, got %q", c.LDAP.URL)
+		}
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Host == "" {
+			p.fail("OTEL_EXPORTER_OTLP_ENDPOINT must be a URL, got %q", c.Tracing.Endpoint)
+		}
+	}
+
+	// Secrets
+	if c.JWT.SigningKey == "" && c.JWT.SigningKeyFile == "" {
+		switch {
+		case c.JWT.Secret == "":
+			p.fail("JWT_SECRET must be set unless JWT_SIGNING_KEY or JWT_SIGNING_KEY_FILE is")
+		case weakSecret(c.JWT.Secret):
+			p.unsafe(dev, "JWT_SECRET is a default or placeholder; anyone could sign tokens")
+		case len(c.JWT.Secret) < minJWTSecretLength:
+			p.unsafe(dev, "JWT_SECRET should be at least %d characters long", minJWTSecretLength)
+		}
+	}
+	// Needed with every kind of token signing: it makes S3 credentials and
+	// invitation links
+	switch {
+	case c.Auth.SigningSecret == "":
+		p.fail("SIGNING_SECRET must be set")
+	case weakSecret(c.Auth.SigningSecret):
+		p.unsafe(dev, "SIGNING_SECRET is a default or placeholder; anyone could make S3 credentials and invitations")
+	case c.Auth.SigningSecret == c.JWT.Secret:
+		p.unsafe(dev, "SIGNING_SECRET must differ from JWT_SECRET")
+	case len(c.Auth.SigningSecret) < minJWTSecretLength:
+		p.unsafe(dev, "SIGNING_SECRET should be at least %d characters long", minJWTSecretLength)
+	}
+	if weakSecret(c.MinIO.AccessKeyID) || weakSecret(c.MinIO.SecretAccessKey) {
+		p.unsafe(dev, "MINIO_ACCESS_KEY or MINIO_SECRET_KEY is a default or placeholder")
+	}
+	if c.Redis.Password == "" {
+		p.unsafe(dev, "REDIS_PASSWORD is empty")
+	} else if weakSecret(c.Redis.Password) {
+		p.unsafe(dev, "REDIS_PASSWORD is a placeholder")
+	}
+	if c.LDAP.URL != "" && c.LDAP.InsecureSkipVerify {
+		p.unsafe(dev, "LDAP_INSECURE_SKIP_VERIFY turns off certificate checks")
+	}
+	if c.Auth.CaptchaProvider != "" {
+		p.oneOf("CAPTCHA_PROVIDER", c.Auth.CaptchaProvider, "hcaptcha", "turnstile", "recaptcha")
+		p.required("CAPTCHA_SECRET", c.Auth.CaptchaSecret)
+	}
+
+	// Tokens and passwords
+	p.atLeast("JWT_ACCESS_TOKEN_TTL", c.JWT.AccessTokenTTL, 1)
+	p.atLeast("JWT_REFRESH_TOKEN_TTL", c.JWT.RefreshTokenTTL, 1)
+	p.atLeast("SESSION_TTL", c.Auth.SessionTTL, 1)
+	p.atLeast("IMPERSONATION_TTL", c.Auth.ImpersonationTTL, 1)
+	p.atLeast("PASSWORD_RESET_TTL", c.Auth.ResetTokenTTL, 1)
+	p.atLeast("PASSWORD_RESET_RATE_LIMIT", c.Auth.ResetRateLimit, 1)
+	p.atLeast("INVITE_TTL", c.Auth.InviteTTL, 1)
+	p.atLeast("EMAIL_VERIFICATION_TTL", c.Auth.VerifyTTL, 1)
+	p.atLeast("CAPTCHA_LOGIN_THRESHOLD", c.Auth.CaptchaLoginThreshold, 0)
+	p.atLeast("PASSWORD_MIN_LENGTH", c.Auth.PasswordMinLength, 1)
+	p.oneOf("PASSWORD_HASH_ALGORITHM", c.Auth.PasswordHashAlgorithm, "argon2id", "bcrypt")
+	p.between("PASSWORD_BCRYPT_COST", c.Auth.BcryptCost, 4, 31)
+	p.atLeast("PASSWORD_ARGON2_MEMORY", c.Auth.Argon2Memory, 8*c.Auth.Argon2Parallelism)
+	p.atLeast("PASSWORD_ARGON2_ITERATIONS", c.Auth.Argon2Iterations, 1)
+	p.between("PASSWORD_ARGON2_PARALLELISM", c.Auth.Argon2Parallelism, 1, 255)
+
+	// Services
+	p.atLeast("REDIS_DB", c.Redis.DB, 0)
+	p.atLeast("MINIO_MAX_IDLE_CONNS", c.MinIO.MaxIdleConns, 0)
+	p.atLeast("MINIO_MAX_IDLE_CONNS_PER_HOST", c.MinIO.MaxIdleConnsPerHost, 1)
+	p.atLeast("MINIO_MAX_CONNS_PER_HOST", c.MinIO.MaxConnsPerHost, 0)
+	p.atLeast("MINIO_IDLE_CONN_TIMEOUT", c.MinIO.IdleConnTimeout, 1)
+	p.atLeast("MINIO_DIAL_TIMEOUT", c.MinIO.DialTimeout, 1)
+	p.atLeast("MINIO_RESPONSE_HEADER_TIMEOUT", c.MinIO.ResponseHeaderTimeout, 1)
+	if c.MinIO.CAFile != "" && !c.MinIO.UseSSL {
+		p.fail("MINIO_CA_FILE is only used with MINIO_USE_SSL=true")
+	}
+	p.atLeast("CACHE_SIZE", c.Cache.Size, 0)
+	p.atLeast("COMMENTS_MAX_DEPTH", c.Comments.MaxDepth, 0)
+	p.atLeast("MODERATION_MAX_LINKS", c.Moderation.MaxLinks, 0)
+	p.atLeast("MODERATION_NEW_ACCOUNT_HOURS", c.Moderation.NewAccountHours, 0)
+	p.atLeast("MODERATION_REPORT_THRESHOLD", c.Moderation.ReportThreshold, 0)
+	p.oneOf("SPAM_CHECKER", c.Moderation.SpamChecker, "heuristic", "http", "off")
+	if c.Moderation.SpamChecker == "http" {
+		p.required("SPAM_CHECK_URL", c.Moderation.SpamCheckURL)
+	}
+	p.atLeast("CACHE_TTL", c.Cache.TTL, 1)
+	p.atLeast("EVENTS_RETENTION", c.NATS.EventRetention, 1)
+	p.atLeast("EVENTS_OUTBOX_INTERVAL", c.NATS.OutboxInterval, 1)
+	p.between("SMTP_PORT", c.Mail.SMTPPort, 1, 65535)
+	p.atLeast("LDAP_TIMEOUT", c.LDAP.Timeout, 1)
+	p.atLeast("SCAN_TIMEOUT", c.Scan.Timeout, 1)
+	p.atLeast("PREVIEW_SIZE", c.Preview.Size, 1)
+	p.atLeast("PREVIEW_TIMEOUT", c.Preview.Timeout, 1)
+	p.atLeast("VIDEO_TRANSCODE_TIMEOUT", c.Video.Timeout, 1)
+	p.atLeast("WEBHOOK_TIMEOUT", c.Webhook.Timeout, 1)
+	p.atLeast("WEBHOOK_MAX_ATTEMPTS", c.Webhook.MaxAttempts, 1)
+	p.atLeast("WS_MAX_CONNECTIONS", c.WebSocket.MaxConnections, 1)
+	p.atLeast("WS_MAX_CONNECTIONS_PER_USER", c.WebSocket.MaxPerUser, 1)
+	p.atLeast("WS_PING_INTERVAL", c.WebSocket.PingInterval, 1)
+	p.atLeast("MAINTENANCE_RETRY_AFTER", c.Maintenance.RetryAfter, 0)
+
+	// Uploads, downloads and requests
+	if c.Upload.MaxFileSize < 1 {
+		p.fail("MAX_FILE_SIZE must be at least 1 byte")
+	}
+	if c.Request.MaxUploadBodySize < c.Upload.MaxFileSize {
+		p.fail("MAX_UPLOAD_REQUEST_SIZE must be at least MAX_FILE_SIZE")
+	}
+	if c.Request.MaxBodySize < 1 {
+		p.fail("MAX_REQUEST_BODY_SIZE must be at least 1 byte")
+	}
+	if c.Upload.AvatarMaxSize < 1 {
+		p.fail("AVATAR_MAX_SIZE must be at least 1 byte")
+	}
+	p.between("UPLOAD_PRESIGN_EXPIRY", c.Upload.PresignExpiry, 1, 7*24*60)
+	p.between("DOWNLOAD_PRESIGN_EXPIRY", c.Download.PresignExpiry, 1, 7*24*60)
+	p.atLeast("DOWNLOAD_PUBLIC_CACHE_MAX_AGE", c.Download.PublicCacheMaxAge, 0)
+	p.atLeast("MAX_FILE_VERSIONS", c.Upload.MaxVersions, 0)
+	p.atLeast("UPLOAD_BATCH_MAX_FILES", c.Upload.BatchMaxFiles, 1)
+	p.atLeast("UPLOAD_BATCH_CONCURRENCY", c.Upload.BatchConcurrency, 1)
+	p.atLeast("REQUEST_TIMEOUT", c.Request.Timeout, 0)
+	p.atLeast("REQUEST_TIMEOUT_ADMIN", c.Request.AdminTimeout, 0)
+	p.atLeast("REQUEST_TIMEOUT_TRANSFER", c.Request.TransferTimeout, 0)
+
+	// Rate limits
+	if c.RateLimit.Enabled {
+		for _, limit := range []struct {
+			prefix string
+			RateLimit
+		}{
+			{"RATE_LIMIT_AUTH", c.RateLimit.Auth},
+			{"RATE_LIMIT_PUBLIC", c.RateLimit.Public},
+			{"RATE_LIMIT_API", c.RateLimit.API},
+		} {
+			p.atLeast(limit.prefix+"_PER_MINUTE", limit.PerMinute, 1)
+			p.atLeast(limit.prefix+"_BURST", limit.Burst, 1)
+		}
+	}
+
+	// Observability
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		p.fail("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	p.oneOf("LOG_FORMAT", strings.ToLower(c.Log.Format), "json", "text")
+	p.ratio("LOG_ACCESS_SAMPLE_RATE", c.Log.AccessSampleRate)
+	p.atLeast("LOG_SLOW_REQUEST", c.Log.SlowRequest, 0)
+	p.ratio("OTEL_TRACES_SAMPLER_ARG", c.Tracing.SampleRatio)
+
+	return p.warnings, errors.Join(p.errors...)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// safeConfig returns the defaults with the secrets of a real deployment
+func safeConfig(t *testing.T, environment string) *Config {
+	t.Helper()
+	cfg, err := Load()
+	require.NoError(t, err)
+	cfg.Environment = environment
+	cfg.JWT.Secret = "k3Jd9sQ0vW7pLx2mZr8tYb4nHc6fGa1e"
+	cfg.Auth.SigningSecret = "Rw5tLq8zXc2vBn7mKj4hGf9dSa3pYe6u"
+	cfg.MinIO.AccessKeyID = "storage-backend"
+	cfg.MinIO.SecretAccessKey = "Vq7sN2kLp9xRt4mW"
+	cfg.Redis.Password = "Hx8cT3vQz6bNw1sY"
+	return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	cfg.Environment = EnvDevelopment
+	warnings, err := cfg.Validate()
+	assert.NoError(t, err, "the defaults start in development")
+	assert.Len(t, warnings, 4)
+
+	cfg.Environment = EnvProduction
+	warnings, err = cfg.Validate()
+	assert.Empty(t, warnings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET")
+	assert.Contains(t, err.Error(), "SIGNING_SECRET")
+	assert.Contains(t, err.Error(), "MINIO_ACCESS_KEY")
+	assert.Contains(t, err.Error(), "REDIS_PASSWORD")
+}
+
+func TestValidateSafe(t *testing.T) {
+	warnings, err := safeConfig(t, EnvProduction).Validate()
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	cfg := safeConfig(t, EnvStaging)
+	cfg.JWT.Secret = "your-super-secure-jwt-secret-key-change-this-in-production"
+	cfg.MinIO.SecretAccessKey = "your-secret-key-here"
+
+	_, err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET")
+	assert.Contains(t, err.Error(), "MINIO_SECRET_KEY")
+
+	// A signing key replaces the secret
+	cfg = safeConfig(t, EnvProduction)
+	cfg.JWT.Secret = ""
+	cfg.JWT.SigningKeyFile = "/run/secrets/jwt.pem"
+	_, err = cfg.Validate()
+	assert.NoError(t, err)
+
+	// but not the signing secret, which S3 credentials and invitations
+	// are made with
+	for name, secret := range map[string]string{
+		"default": defaultSigningSecret,
+		"empty":   "",
+		"short":   "Rw5tLq8zXc2vBn7m",
+		"reused":  "k3Jd9sQ0vW7pLx2mZr8tYb4nHc6fGa1e",
+	} {
+		cfg = safeConfig(t, EnvProduction)
+		cfg.JWT.SigningKeyFile = "/run/secrets/jwt.pem"
+		cfg.Auth.SigningSecret = secret
+		_, err = cfg.Validate()
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), "SIGNING_SECRET", name)
+	}
+}
+
+func TestValidateRanges(t *testing.T) {
+	cases := map[string]func(cfg *Config){
+		"APP_ENV":                  func(cfg *Config) { cfg.Environment = "prod" },
+		"PORT":                     func(cfg *Config) { cfg.Port = "http" },
+		"MINIO_ENDPOINT":           func(cfg *Config) { cfg.MinIO.Endpoint = "https://minio:9000" },
+		"MINIO_DIAL_TIMEOUT":       func(cfg *Config) { cfg.MinIO.DialTimeout = 0 },
+		"MINIO_CA_FILE":            func(cfg *Config) { cfg.MinIO.CAFile = "/etc/ssl/minio-ca.pem" },
+		"NATS_URL":                 func(cfg *Config) { cfg.NATS.URL = "" },
+		"JWT_ACCESS_TOKEN_TTL":     func(cfg *Config) { cfg.JWT.AccessTokenTTL = 0 },
+		"PASSWORD_BCRYPT_COST":     func(cfg *Config) { cfg.Auth.BcryptCost = 40 },
+		"PASSWORD_HASH_ALGORITHM":  func(cfg *Config) { cfg.Auth.PasswordHashAlgorithm = "md5" },
+		"MAX_UPLOAD_REQUEST_SIZE":  func(cfg *Config) { cfg.Request.MaxUploadBodySize = 1 << 20 },
+		"RATE_LIMIT_API_BURST":     func(cfg *Config) { cfg.RateLimit.API.Burst = 0 },
+		"LOG_ACCESS_SAMPLE_RATE":   func(cfg *Config) { cfg.Log.AccessSampleRate = 1.5 },
+		"UPLOAD_PRESIGN_EXPIRY":    func(cfg *Config) { cfg.Upload.PresignExpiry = 8 * 24 * 60 },
+		"CAPTCHA_SECRET":           func(cfg *Config) { cfg.Auth.CaptchaProvider = "hcaptcha" },
+		"LDAP_URL":                 func(cfg *Config) { cfg.LDAP.URL = "directory:389" },
+		"UPLOAD_BATCH_CONCURRENCY": func(cfg *Config) { cfg.Upload.BatchConcurrency = 0 },
+		"TLS_KEY_FILE":             func(cfg *Config) { cfg.TLS.CertFile = "/etc/tls/cert.pem" },
+		"TLS_MIN_VERSION":          func(cfg *Config) { cfg.TLS.MinVersion = "1.0" },
+		"TLS_REDIRECT_PORT":        func(cfg *Config) { cfg.TLS.RedirectPort = "80" },
+		"SECRETS_PROVIDER":         func(cfg *Config) { cfg.Secrets.Provider = "keychain" },
+		"GRPC_PORT":                func(cfg *Config) { cfg.GRPC.Port = cfg.Port },
+		"S3_GATEWAY_PORT":          func(cfg *Config) { cfg.S3Gateway.Port = "s3" },
+		"SPAM_CHECK_URL":           func(cfg *Config) { cfg.Moderation.SpamChecker = "http" },
+	}
+	for name, change := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Ranges are checked in development too
+			cfg := safeConfig(t, EnvDevelopment)
+			change(cfg)
+			_, err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+		})
+	}
+}
@@ -0,0 +1,536 @@
+// Package davfs presents the files of a user as a file system for WebDAV.
+// Virtual folders are directories and files appear under their original
+// names, so a mounted drive shows what the web app shows.
+package davfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"golang.org/x/net/webdav"
+)
+
+// ErrTooLarge is returned by writes past the size limit
+var ErrTooLarge = errors.New("file exceeds the maximum upload size")
+
+// Backend stores the files of one user
+type Backend interface {
+	// Files returns every file of the user
+	Files(ctx context.Context) ([]*models.File, error)
+	// Open returns the content of file. WebDAV opens files to list their
+	// properties too, so it should not read anything before the first Read
+	// or Seek.
+	Open(ctx context.Context, file *models.File) (io.ReadSeekCloser, error)
+	// Store stores content as name in folder. A file of that name already
+	// there gets the content as a new version.
+	Store(ctx context.Context, folder, name string, content *os.File) error
+	// Update saves file after it was moved or renamed
+	Update(ctx context.Context, file *models.File) error
+	// Delete deletes file
+	Delete(ctx context.Context, file *models.File) error
+}
+
+// Folders remembers the folders created over WebDAV. Folders otherwise
+// exist only while files are in them, so a new folder would vanish before
+// anything could be put into it. Keep one per user across requests.
+type Folders struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func NewFolders() *Folders {
+	return &Folders{paths: map[string]bool{}}
+}
+
+func (f *Folders) add(folder string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths[folder] = true
+}
+
+// remove forgets folder and the folders below it, returning them
+func (f *Folders) remove(folder string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var removed []string
+	for p := range f.paths {
+		if within(p, folder) {
+			delete(f.paths, p)
+			removed = append(removed, p)
+		}
+	}
+	return removed
+}
+
+func (f *Folders) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := make([]string, 0, len(f.paths))
+	for p := range f.paths {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// FS is the file system of one WebDAV request. It lists the user's files
+// when first needed and works from that listing, which changes made through
+// it refresh.
+type FS struct {
+	backend Backend
+	folders *Folders
+	maxSize int64
+
+	mu   sync.Mutex
+	root *node
+}
+
+// New returns the file system of backend's files. Files written through it
+// may be at most maxSize bytes.
+func New(backend Backend, folders *Folders, maxSize int64) *FS {
+	return &FS{backend: backend, folders: folders, maxSize: maxSize}
+}
+
+var _ webdav.FileSystem = (*FS)(nil)
+
+// node is a directory or a file of the tree
+type node struct {
+	name     string
+	folder   string // the folder a directory stands for, "" for the root
+	file     *models.File
+	children map[string]*node
+	modTime  time.Time
+}
+
+func (n *node) isDir() bool { return n.file == nil }
+
+// tree returns the root of the user's files, listing them if needed
+func (fsys *FS) tree(ctx context.Context) (*node, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if fsys.root != nil {
+		return fsys.root, nil
+	}
+
+	files, err := fsys.backend.Files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root := &node{children: map[string]*node{}}
+	for _, folder := range fsys.folders.list() {
+		directory(root, folder)
+	}
+	// The oldest file of a name keeps it; later ones get their ID added
+	files = slices.Clone(files)
+	slices.SortStableFunc(files, func(a, b *models.File) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, file := range files {
+		dir := directory(root, file.Folder)
+		name := entryName(file.OriginalName)
+		if _, taken := dir.children[name]; taken {
+			ext := path.Ext(name)
+			name = strings.TrimSuffix(name, ext) + " (" + file.ID + ")" + ext
+		}
+		dir.children[name] = &node{name: name, file: file, modTime: file.UpdatedAt}
+		for n := dir; n != nil && n.modTime.Before(file.UpdatedAt); n = parent(root, n) {
+			n.modTime = file.UpdatedAt
+		}
+	}
+	fsys.root = root
+	return root, nil
+}
+
+// refresh drops the listing after a change
+func (fsys *FS) refresh() {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fsys.root = nil
+}
+
+// directory returns the directory of folder, creating it and its parents
+func directory(root *node, folder string) *node {
+	dir := root
+	for _, name := range segments(folder) {
+		child, ok := dir.children[name]
+		if !ok || !child.isDir() {
+			child = &node{name: name, folder: path.Join("/", dir.folder, name), children: map[string]*node{}}
+			dir.children[name] = child
+		}
+		dir = child
+	}
+	return dir
+}
+
+// parent returns the directory holding dir, nil for the root
+func parent(root, dir *node) *node {
+	if dir == root {
+		return nil
+	}
+	return directory(root, path.Dir(dir.folder))
+}
+
+// lookup returns the node at name, a slash separated path
+func lookup(root *node, name string) (*node, error) {
+	n := root
+	for _, segment := range segments(name) {
+		if !n.isDir() {
+			return nil, os.ErrNotExist
+		}
+		child, ok := n.children[segment]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// segments splits a slash separated path into its names
+func segments(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// within reports whether folder is dir or below it
+func within(folder, dir string) bool {
+	return folder == dir || strings.HasPrefix(folder, dir+"/")
+}
+
+// entryName makes a file name usable as a path segment
+func entryName(name string) string {
+	name = strings.ReplaceAll(name, "/", "_")
+	if name == "" || name == "." || name == ".." {
+		return "_" + name
+	}
+	return name
+}
+
+// split returns the folder and name of a file path
+func split(name string) (string, string) {
+	folder, base := path.Split(path.Clean("/" + name))
+	folder = path.Clean(folder)
+	if folder == "/" {
+		folder = ""
+	}
+	return folder, base
+}
+
+func (fsys *FS) Mkdir(ctx context.Context, name string, _ os.FileMode) error {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := lookup(root, name); err == nil {
+		return os.ErrExist
+	}
+	folder, _ := split(name)
+	if dir, err := lookup(root, folder); err != nil || !dir.isDir() {
+		return os.ErrNotExist
+	}
+
+	fsys.folders.add(path.Clean("/" + name))
+	fsys.refresh()
+	return nil
+}
+
+func (fsys *FS) OpenFile(ctx context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, lookupErr := lookup(root, name)
+
+	// Writes replace the whole content, as PUT and COPY do
+	create := flag&os.O_CREATE != 0 && lookupErr != nil
+	if flag&os.O_TRUNC != 0 || create {
+		if lookupErr == nil && n.isDir() || len(segments(name)) == 0 {
+			return nil, os.ErrExist
+		}
+		folder, base := split(name)
+		if dir, err := lookup(root, folder); err != nil || !dir.isDir() {
+			return nil, os.ErrNotExist
+		}
+		spool, err := os.CreateTemp("", "davfs-*")
+		if err != nil {
+			return nil, err
+		}
+		return &writeFile{ctx: ctx, fs: fsys, folder: folder, name: base, spool: spool}, nil
+	}
+
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if n.isDir() {
+		return &dirFile{node: n}, nil
+	}
+	content, err := fsys.backend.Open(ctx, n.file)
+	if err != nil {
+		return nil, err
+	}
+	return &readFile{node: n, content: content}, nil
+}
+
+func (fsys *FS) RemoveAll(ctx context.Context, name string) error {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := lookup(root, name)
+	if err != nil {
+		return nil
+	}
+	if n == root {
+		return os.ErrPermission
+	}
+	defer fsys.refresh()
+
+	if !n.isDir() {
+		return fsys.backend.Delete(ctx, n.file)
+	}
+	fsys.folders.remove(n.folder)
+	for _, file := range filesBelow(n) {
+		if err := fsys.backend.Delete(ctx, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fsys *FS) Rename(ctx context.Context, oldName, newName string) error {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := lookup(root, oldName)
+	if err != nil {
+		return err
+	}
+	if n == root || len(segments(newName)) == 0 {
+		return os.ErrPermission
+	}
+	if _, err := lookup(root, newName); err == nil {
+		return os.ErrExist
+	}
+	folder, base := split(newName)
+	if dir, err := lookup(root, folder); err != nil || !dir.isDir() {
+		return os.ErrNotExist
+	}
+	defer fsys.refresh()
+
+	if !n.isDir() {
+		n.file.Folder, n.file.OriginalName = folder, base
+		return fsys.backend.Update(ctx, n.file)
+	}
+
+	target := path.Clean("/" + newName)
+	if within(target, n.folder) {
+		return os.ErrPermission
+	}
+	for _, moved := range fsys.folders.remove(n.folder) {
+		fsys.folders.add(target + strings.TrimPrefix(moved, n.folder))
+	}
+	for _, file := range filesBelow(n) {
+		file.Folder = target + strings.TrimPrefix(file.Folder, n.folder)
+		if err := fsys.backend.Update(ctx, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fsys *FS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := lookup(root, name)
+	if err != nil {
+		return nil, err
+	}
+	return info(n), nil
+}
+
+// File returns the file at name, or nil when name is a directory
+func (fsys *FS) File(ctx context.Context, name string) (*models.File, error) {
+	root, err := fsys.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := lookup(root, name)
+	if err != nil {
+		return nil, err
+	}
+	return n.file, nil
+}
+
+// filesBelow returns the files in dir and its subdirectories
+func filesBelow(dir *node) []*models.File {
+	var files []*models.File
+	for _, child := range dir.children {
+		if child.isDir() {
+			files = append(files, filesBelow(child)...)
+		} else {
+			files = append(files, child.file)
+		}
+	}
+	return files
+}
+
+// fileInfo describes a node. Files answer their content type and ETag
+// from their metadata, so listing a directory reads no content.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	file    *models.File
+}
+
+func info(n *node) *fileInfo {
+	fi := &fileInfo{name: n.name, modTime: n.modTime, file: n.file}
+	if n.file != nil {
+		fi.size = n.file.Size
+	}
+	if fi.name == "" {
+		fi.name = "/"
+	}
+	return fi
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.file == nil }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.IsDir() {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (fi *fileInfo) ContentType(context.Context) (string, error) {
+	if fi.file == nil || fi.file.ContentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return fi.file.ContentType, nil
+}
+
+func (fi *fileInfo) ETag(context.Context) (string, error) {
+	if fi.file == nil || fi.file.ETag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + strings.Trim(fi.file.ETag, `"`) + `"`, nil
+}
+
+// dirFile is an open directory
+type dirFile struct {
+	node *node
+	read int
+}
+
+func (d *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	names := make([]string, 0, len(d.node.children))
+	for name := range d.node.children {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	names = names[d.read:]
+	if count > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		names = names[:min(count, len(names))]
+	}
+	infos := make([]fs.FileInfo, len(names))
+	for i, name := range names {
+		infos[i] = info(d.node.children[name])
+	}
+	d.read += len(names)
+	return infos, nil
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error)     { return info(d.node), nil }
+func (d *dirFile) Read([]byte) (int, error)       { return 0, os.ErrInvalid }
+func (d *dirFile) Seek(int64, int) (int64, error) { return 0, os.ErrInvalid }
+func (d *dirFile) Write([]byte) (int, error)      { return 0, os.ErrPermission }
+func (d *dirFile) Close() error                   { return nil }
+
+// readFile is an open file being read
+type readFile struct {
+	node    *node
+	content io.ReadSeekCloser
+}
+
+func (f *readFile) Read(p []byte) (int, error) { return f.content.Read(p) }
+
+// Seek works out offsets from the end with the size in the metadata, which
+// saves a request for the object's size
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekEnd {
+		offset, whence = f.node.file.Size+offset, io.SeekStart
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *readFile) Readdir(int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *readFile) Stat() (fs.FileInfo, error)         { return info(f.node), nil }
+func (f *readFile) Write([]byte) (int, error)          { return 0, os.ErrPermission }
+func (f *readFile) Close() error                       { return f.content.Close() }
+
+// writeFile spools written content to a temporary file and stores it when
+// closed
+type writeFile struct {
+	ctx    context.Context
+	fs     *FS
+	folder string
+	name   string
+	spool  *os.File
+	size   int64
+	failed bool
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	if f.size+int64(len(p)) > f.fs.maxSize {
+		f.failed = true
+		return 0, ErrTooLarge
+	}
+	n, err := f.spool.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		f.failed = true
+	}
+	return n, err
+}
+
+func (f *writeFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: f.name, size: f.size, modTime: time.Now(), file: &models.File{}}, nil
+}
+
+// Close stores the content unless writing it failed
+func (f *writeFile) Close() error {
+	defer os.Remove(f.spool.Name())
+	defer f.spool.Close()
+	if f.failed {
+		return nil
+	}
+	if _, err := f.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	defer f.fs.refresh()
+	return f.fs.backend.Store(f.ctx, f.folder, f.name, f.spool)
+}
+
+func (f *writeFile) Read([]byte) (int, error)           { return 0, os.ErrPermission }
+func (f *writeFile) Seek(int64, int) (int64, error)     { return 0, os.ErrInvalid }
+func (f *writeFile) Readdir(int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
@@ -0,0 +1,160 @@
+package davfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// memBackend keeps files and their content in memory
+type memBackend struct {
+	files   []*models.File
+	content map[string]string
+}
+
+func (b *memBackend) Files(context.Context) ([]*models.File, error) {
+	return b.files, nil
+}
+
+func (b *memBackend) Open(_ context.Context, file *models.File) (io.ReadSeekCloser, error) {
+	return nopCloser{strings.NewReader(b.content[file.ID])}, nil
+}
+
+func (b *memBackend) Store(_ context.Context, folder, name string, content *os.File) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	for _, file := range b.files {
+		if file.Folder == folder && file.OriginalName == name {
+			file.Size, file.Version = int64(len(data)), file.Version+1
+			b.content[file.ID] = string(data)
+			return nil
+		}
+	}
+	id := "f" + string(rune('a'+len(b.files)))
+	b.files = append(b.files, &models.File{ID: id, Folder: folder, OriginalName: name, Size: int64(len(data)), Version: 1, CreatedAt: time.Now()})
+	b.content[id] = string(data)
+	return nil
+}
+
+func (b *memBackend) Update(context.Context, *models.File) error { return nil }
+
+func (b *memBackend) Delete(_ context.Context, file *models.File) error {
+	for i, f := range b.files {
+		if f.ID == file.ID {
+			b.files = append(b.files[:i], b.files[i+1:]...)
+		}
+	}
+	return nil
+}
+
+type nopCloser struct{ io.ReadSeeker }
+
+func (nopCloser) Close() error { return nil }
+
+func TestFS(t *testing.T) {
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	backend := &memBackend{
+		files: []*models.File{
+			{ID: "f1", OriginalName: "notes.txt", ContentType: "text/plain", Size: 5, Version: 1, CreatedAt: created, UpdatedAt: created, ETag: "abc"},
+			{ID: "f2", Folder: "/projects/2024", OriginalName: "plan.txt", Size: 4, CreatedAt: created, UpdatedAt: created},
+			{ID: "f3", Folder: "/projects/2024", OriginalName: "plan.txt", Size: 3, CreatedAt: created.Add(time.Hour), UpdatedAt: created},
+			{ID: "f4", Folder: "/keep", OriginalName: "log.txt", Size: 0, CreatedAt: created, UpdatedAt: created},
+		},
+		content: map[string]string{"f1": "hello", "f2": "plan", "f3": "new"},
+	}
+	folders := NewFolders()
+	handler := func() http.Handler {
+		return &webdav.Handler{FileSystem: New(backend, folders, 10), LockSystem: webdav.NewMemLS()}
+	}
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler().ServeHTTP(w, req)
+		return w
+	}
+
+	// Folders are directories; a second file of a name gets its ID added
+	w := do("PROPFIND", "/projects/2024/", "", "Depth", "1")
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "<D:href>/projects/2024/plan.txt</D:href>")
+	assert.Contains(t, w.Body.String(), "<D:href>/projects/2024/plan%20%28f3%29.txt</D:href>")
+
+	w = do(http.MethodGet, "/notes.txt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+
+	file, err := New(backend, folders, 10).File(context.Background(), "/keep/log.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "f4", file.ID)
+
+	// Writes store new files and new versions of existing ones
+	w = do(http.MethodPut, "/projects/2024/todo.txt", "buy")
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = do(http.MethodPut, "/notes.txt", "hi")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "hi", backend.content["f1"])
+	assert.Equal(t, 2, backend.files[0].Version)
+	w = do(http.MethodPut, "/missing/todo.txt", "buy")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Content over the limit is not stored
+	w = do(http.MethodPut, "/big.bin", "0123456789ab")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Len(t, backend.files, 5)
+
+	// New folders stay until removed, and can be written to
+	w = do("MKCOL", "/archive", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = do("PROPFIND", "/archive", "", "Depth", "0")
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	// Moving a folder moves the files in it
+	w = do("MOVE", "/projects", "", "Destination", "/archive/projects")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/archive/projects/2024", backend.files[1].Folder)
+	w = do(http.MethodGet, "/archive/projects/2024/plan.txt", "")
+	assert.Equal(t, "plan", w.Body.String())
+
+	w = do("MOVE", "/notes.txt", "", "Destination", "/archive/readme.txt")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/archive", backend.files[0].Folder)
+	assert.Equal(t, "readme.txt", backend.files[0].OriginalName)
+
+	// Deleting a folder deletes the files in it
+	w = do(http.MethodDelete, "/archive", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Len(t, backend.files, 1)
+	assert.Equal(t, "f4", backend.files[0].ID)
+	w = do("PROPFIND", "/archive", "", "Depth", "0")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReadFileSeekEnd(t *testing.T) {
+	f := &readFile{
+		node:    &node{file: &models.File{Size: 5}},
+		content: nopCloser{bytes.NewReader([]byte("hello"))},
+	}
+	offset, err := f.Seek(-2, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), offset)
+	rest, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "lo", string(rest))
+}
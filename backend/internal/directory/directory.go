@@ -0,0 +1,231 @@
+// Package directory authenticates users against an LDAP server such as
+// OpenLDAP or Active Directory.
+package directory
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrInvalidCredentials is returned when the directory has no such user or
+// rejects the password
+var ErrInvalidCredentials = errors.New("invalid directory credentials")
+
+// Config describes how to find users in the directory and read their
+// attributes
+type Config struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+
+	// BindDN and BindPassword are the account users are searched with;
+	// an empty BindDN searches anonymously
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// UserFilter finds a user by login name; every %s is replaced by the
+	// escaped login name
+	UserFilter string
+
+	UsernameAttribute  string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupAttribute     string
+
+	// GroupRoles maps group DNs to roles; the first group the user is a
+	// member of decides the role, otherwise DefaultRole applies
+	GroupRoles  []GroupRole
+	DefaultRole string
+}
+
+// GroupRole gives members of the group with DN Group the role Role
+type GroupRole struct {
+	Role  string
+	Group string
+}
+
+// ParseGroupRoles parses "role:groupDN" pairs separated by semicolons,
+// e.g. "admin:cn=admins,ou=groups,dc=example,dc=com;user:cn=staff,..."
+func ParseGroupRoles(value string) ([]GroupRole, error) {
+	var mappings []GroupRole
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		role, group, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(role) == "" || strings.TrimSpace(group) == "" {
+			return nil, fmt.Errorf("invalid group role mapping %q, expected role:groupDN", pair)
+		}
+		mappings = append(mappings, GroupRole{Role: strings.TrimSpace(role), Group: strings.TrimSpace(group)})
+	}
+	return mappings, nil
+}
+
+// Entry is a user found in the directory
+type Entry struct {
+	DN        string
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Groups    []string
+}
+
+// Directory authenticates users with an LDAP bind
+type Directory struct {
+	config Config
+}
+
+func New(config Config) (*Directory, error) {
+	if config.URL == "" {
+		return nil, errors.New("directory URL is required")
+	}
+	if config.BaseDN == "" {
+		return nil, errors.New("directory base DN is required")
+	}
+	if !strings.Contains(config.UserFilter, "%s") {
+		return nil, fmt.Errorf("user filter %q must contain %%s for the login name", config.UserFilter)
+	}
+	return &Directory{config: config}, nil
+}
+
+// MapsRoles reports whether roles are assigned from group memberships
+func (d *Directory) MapsRoles() bool {
+	return len(d.config.GroupRoles) > 0
+}
+
+// RoleFor returns the role for a member of groups
+func (d *Directory) RoleFor(groups []string) string {
+	for _, mapping := range d.config.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(normalizeDN(group), normalizeDN(mapping.Group)) {
+				return mapping.Role
+			}
+		}
+	}
+	return d.config.DefaultRole
+}
+
+// Authenticate finds the user with loginName and binds as them with
+// password. Errors other than ErrInvalidCredentials mean the directory
+// could not be asked.
+func (d *Directory) Authenticate(loginName, password string) (*Entry, error) {
+	// An empty password would be an unauthenticated bind, which servers
+	// accept for any DN
+	if loginName == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if d.config.BindDN != "" {
+		err = conn.Bind(d.config.BindDN, d.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to directory: %w", err)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.config.Timeout.Seconds()), false,
+		d.userFilter(loginName),
+		d.attributes(),
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search directory: %w", err)
+	}
+	// Several matches means the filter is too loose to tell who signs in
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind as user: %w", err)
+	}
+
+	entry := &Entry{
+		DN:        found.DN,
+		Username:  found.GetAttributeValue(d.config.UsernameAttribute),
+		Email:     found.GetAttributeValue(d.config.EmailAttribute),
+		FirstName: found.GetAttributeValue(d.config.FirstNameAttribute),
+		LastName:  found.GetAttributeValue(d.config.LastNameAttribute),
+		Groups:    found.GetAttributeValues(d.config.GroupAttribute),
+	}
+	if entry.Username == "" {
+		entry.Username = loginName
+	}
+	return entry, nil
+}
+
+func (d *Directory) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: d.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(d.config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.config.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	conn.SetTimeout(d.config.Timeout)
+
+	if d.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS with directory: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// userFilter fills the login name into the user filter
+func (d *Directory) userFilter(loginName string) string {
+	return strings.ReplaceAll(d.config.UserFilter, "%s", ldap.EscapeFilter(loginName))
+}
+
+func (d *Directory) attributes() []string {
+	var attributes []string
+	for _, attribute := range []string{
+		d.config.UsernameAttribute,
+		d.config.EmailAttribute,
+		d.config.FirstNameAttribute,
+		d.config.LastNameAttribute,
+		d.config.GroupAttribute,
+	} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// normalizeDN drops the spaces directories allow after commas, so
+// "cn=a, dc=b" and "cn=a,dc=b" compare equal
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,57 @@
+package directory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroupRoles(t *testing.T) {
+	mappings, err := ParseGroupRoles("admin:cn=admins,ou=groups,dc=example,dc=com; moderator:cn=mods,dc=example,dc=com;")
+	require.NoError(t, err)
+	assert.Equal(t, []GroupRole{
+		{Role: "admin", Group: "cn=admins,ou=groups,dc=example,dc=com"},
+		{Role: "moderator", Group: "cn=mods,dc=example,dc=com"},
+	}, mappings)
+
+	_, err = ParseGroupRoles("cn=admins,dc=example,dc=com")
+	assert.Error(t, err)
+}
+
+func TestRoleFor(t *testing.T) {
+	d, err := New(Config{
+		URL:        "ldap://localhost",
+		BaseDN:     "dc=example,dc=com",
+		UserFilter: "(uid=%s)",
+		GroupRoles: []GroupRole{
+			{Role: "admin", Group: "cn=admins,dc=example,dc=com"},
+			{Role: "moderator", Group: "cn=mods,dc=example,dc=com"},
+		},
+		DefaultRole: "user",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "admin", d.RoleFor([]string{"cn=mods,dc=example,dc=com", "CN=Admins, DC=example, DC=com"}), "first mapping wins")
+	assert.Equal(t, "moderator", d.RoleFor([]string{"cn=mods,dc=example,dc=com"}))
+	assert.Equal(t, "user", d.RoleFor(nil))
+}
+
+func TestUserFilter(t *testing.T) {
+	d, err := New(Config{URL: "ldap://localhost", BaseDN: "dc=example,dc=com", UserFilter: "(|(uid=%s)(mail=%s))"})
+	require.NoError(t, err)
+
+	assert.Equal(t, `(|(uid=alice)(mail=alice))`, d.userFilter("alice"))
+	assert.Equal(t, `(|(uid=\2a\29\28uid=\2a)(mail=\2a\29\28uid=\2a))`, d.userFilter("*)(uid=*"))
+
+	_, err = New(Config{URL: "ldap://localhost", BaseDN: "dc=example,dc=com", UserFilter: "(uid=alice)"})
+	assert.Error(t, err)
+}
+
+func TestAuthenticateEmptyPassword(t *testing.T) {
+	d, err := New(Config{URL: "ldap://localhost:1", BaseDN: "dc=example,dc=com", UserFilter: "(uid=%s)"})
+	require.NoError(t, err)
+
+	_, err = d.Authenticate("alice", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
@@ -0,0 +1,195 @@
+// Package events defines the domain events published to NATS JetStream
+// whenever users, posts, comments or files change, for other services to consume.
+//
+// Each event is published on "events.<type>", e.g. events.post.published,
+// into the EVENTS stream, so consumers can subscribe to events.> or to a
+// single type and replay what they missed. The message body is an Event
+// whose data is the payload of its type; the NATS message ID is the event
+// ID, so a retried publish is stored once.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Stream holds every domain event
+const (
+	Stream         = "EVENTS"
+	SubjectPrefix  = "events."
+	StreamSubjects = SubjectPrefix + ">"
+)
+
+// Version is the schema version of the event payloads. Fields may be
+// added without changing it; removing or changing one bumps it.
+const Version = 1
+
+// Event types, by the kind of payload they carry
+const (
+	// User payload
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted" // only the ID is set
+
+	// Post payload
+	PostCreated   = "post.created"
+	PostUpdated   = "post.updated"
+	PostPublished = "post.published" // the first time a post is published, after post.created or post.updated
+	PostDeleted   = "post.deleted"   // only the IDs of the post and its author are set
+
+	// Comment payload
+	CommentCreated   = "comment.created"
+	CommentModerated = "comment.moderated" // hidden, shown again, or approved or rejected after review
+	CommentDeleted   = "comment.deleted"   // only the IDs of the comment, its post and its author are set
+
+	// File payload
+	FileUploaded = "file.uploaded" // a new file was stored, by upload or copy
+	FileUpdated  = "file.updated"
+	FileDeleted  = "file.deleted" // only the IDs of the file and its owner are set
+
+	// Mention payload
+	MentionCreated = "mention.created" // a user was mentioned in a post or comment for the first time
+)
+
+// Types lists every event type
+var Types = []string{
+	UserCreated, UserUpdated, UserDeleted,
+	PostCreated, PostUpdated, PostPublished, PostDeleted,
+	CommentCreated, CommentModerated, CommentDeleted,
+	FileUploaded, FileUpdated, FileDeleted,
+	MentionCreated,
+}
+
+// Event is the envelope of every domain event
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"` // User, Post, Comment, File or Mention
+}
+
+// User is the payload of user events. Credentials and contact details
+// are left out.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// Post is the payload of post events
+type Post struct {
+	ID     string   `json:"id"`
+	UserID string   `json:"userId,omitempty"`
+	Title  string   `json:"title,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Status string   `json:"status,omitempty"`
+}
+
+// Comment is the payload of comment events. The content is left out, as
+// it may be withheld from readers of the post; clients read it from the
+// API.
+type Comment struct {
+	ID         string `json:"id"`
+	PostID     string `json:"postId"`
+	PostUserID string `json:"postUserId,omitempty"` // the author of the post
+	UserID     string `json:"userId,omitempty"`
+	ParentID   string `json:"parentId,omitempty"`
+	Depth      int    `json:"depth,omitempty"`
+	Status     string `json:"status,omitempty"`
+}
+
+// File is the payload of file events
+type File struct {
+	ID           string `json:"id"`
+	UserID       string `json:"userId,omitempty"`
+	OriginalName string `json:"originalName,omitempty"`
+	ContentType  string `json:"contentType,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	Folder       string `json:"folder,omitempty"`
+	Visibility   string `json:"visibility,omitempty"`
+	ScanStatus   string `json:"scanStatus,omitempty"`
+	Version      int    `json:"version,omitempty"`
+}
+
+// Mention is the payload of mention events
+type Mention struct {
+	UserID    string `json:"userId"`   // who was mentioned
+	AuthorID  string `json:"authorId"` // who mentioned them
+	PostID    string `json:"postId"`
+	CommentID string `json:"commentId,omitempty"` // set for mentions in comments
+}
+
+// Publisher publishes events
+type Publisher interface {
+	PublishEvent(ctx context.Context, event *Event) error
+}
+
+// Preparer is a Publisher that also stores events before the writes they
+// report, so they are not lost when a server stops between a write and
+// publishing its event. PublishEvent is called once the write succeeded
+// and DiscardEvent if it failed.
+type Preparer interface {
+	Publisher
+	PrepareEvent(ctx context.Context, event *Event) error
+	DiscardEvent(ctx context.Context, id string)
+}
+
+// Subject returns the subject events of eventType are published on
+func Subject(eventType string) string {
+	return SubjectPrefix + eventType
+}
+
+// New returns an event of eventType carrying data
+func New(eventType string, data any) *Event {
+	return &Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Version:    Version,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+func NewUser(user *models.User) User {
+	return User{ID: user.ID, Username: user.Username, Role: user.Role, Status: user.AccountStatus()}
+}
+
+func NewPost(post *models.Post) Post {
+	return Post{ID: post.ID, UserID: post.UserID, Title: post.Title, Tags: post.Tags, Status: post.Status}
+}
+
+// NewComment returns the payload of comment on a post by postUserID
+func NewComment(comment *models.Comment, postUserID string) Comment {
+	return Comment{
+		ID:         comment.ID,
+		PostID:     comment.PostID,
+		PostUserID: postUserID,
+		UserID:     comment.UserID,
+		ParentID:   comment.ParentID,
+		Depth:      comment.Depth,
+		Status:     comment.Status,
+	}
+}
+
+func NewMention(mention *models.Mention) Mention {
+	return Mention{UserID: mention.UserID, AuthorID: mention.AuthorID, PostID: mention.PostID, CommentID: mention.CommentID}
+}
+
+func NewFile(file *models.File) File {
+	return File{
+		ID:           file.ID,
+		UserID:       file.UserID,
+		OriginalName: file.OriginalName,
+		ContentType:  file.ContentType,
+		Size:         file.Size,
+		Folder:       file.Folder,
+		Visibility:   file.Visibility,
+		ScanStatus:   file.ScanStatus,
+		Version:      file.Version,
+	}
+}
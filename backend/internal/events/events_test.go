@@ -0,0 +1,37 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubject(t *testing.T) {
+	assert.Equal(t, "events.post.published", Subject(PostPublished))
+	assert.Equal(t, "events.>", StreamSubjects)
+}
+
+func TestNew(t *testing.T) {
+	user := &models.User{
+		ID:       "u1",
+		Username: "alice",
+		Email:    "alice@example.com",
+		Password: "hash",
+		Role:     models.RoleUser,
+	}
+	event := New(UserCreated, NewUser(user))
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, Version, event.Version)
+	assert.NotEqual(t, New(UserCreated, nil).ID, event.ID)
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "user.created", decoded["type"])
+	// Contact details and credentials stay out of events
+	assert.Equal(t, map[string]any{"id": "u1", "username": "alice", "role": "user", "status": "active"}, decoded["data"])
+}
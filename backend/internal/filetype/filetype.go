@@ -0,0 +1,166 @@
+package filetype
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SniffLen is the number of leading bytes Detect looks at
+const SniffLen = 512
+
+// ErrNotAllowed is returned by Policy.Check for rejected uploads
+var ErrNotAllowed = errors.New("file type not allowed")
+
+// sniffable lists type families whose magic bytes http.DetectContentType
+// recognises. A client claiming one of these for content that does not
+// sniff as such is not believed.
+var sniffable = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/",
+	"application/pdf",
+	"application/zip",
+	"application/x-gzip",
+	"application/x-rar-compressed",
+	"application/wasm",
+}
+
+// zipContainers are formats built on ZIP that sniff as application/zip
+var zipContainers = []string{
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+	"application/epub+zip",
+	"application/java-archive",
+	"application/vnd.android.package-archive",
+}
+
+// Detect works out the content type of an upload from its first bytes,
+// falling back to the declared type or the file extension only when the
+// content itself is not recognisable.
+func Detect(head []byte, filename, declared string) string {
+	sniffed := http.DetectContentType(head)
+	claimed := mediaType(declared)
+	if claimed == "" || claimed == "application/octet-stream" {
+		claimed = mediaType(mime.TypeByExtension(Extension(filename)))
+	}
+
+	if sniffed == "application/zip" && hasPrefix(claimed, zipContainers) {
+		return claimed
+	}
+	if !isGeneric(sniffed) {
+		return sniffed
+	}
+	if claimed == "" || hasPrefix(claimed, sniffable) {
+		return sniffed
+	}
+	return claimed
+}
+
+// Extension returns the lower-cased extension of filename, including the dot
+func Extension(filename string) string {
+	return strings.ToLower(path.Ext(filename))
+}
+
+// Policy decides which content types and extensions may be uploaded.
+// Deny rules win; when an allow list is set, uploads must match it.
+type Policy struct {
+	allowTypes []string
+	denyTypes  []string
+	allowExts  []string
+	denyExts   []string
+}
+
+// NewPolicy builds a policy from type patterns such as "image/*" or
+// "application/pdf" and extensions such as ".exe"
+func NewPolicy(allowTypes, denyTypes, allowExts, denyExts []string) *Policy {
+	return &Policy{
+		allowTypes: normalize(allowTypes, false),
+		denyTypes:  normalize(denyTypes, false),
+		allowExts:  normalize(allowExts, true),
+		denyExts:   normalize(denyExts, true),
+	}
+}
+
+// Check reports whether a file with this content type and name may be stored
+func (p *Policy) Check(contentType, filename string) error {
+	media := mediaType(contentType)
+	ext := Extension(filename)
+
+	if matchType(p.denyTypes, media) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, media)
+	}
+	if ext != "" && contains(p.denyExts, ext) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, ext)
+	}
+	if len(p.allowTypes) > 0 && !matchType(p.allowTypes, media) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, media)
+	}
+	if len(p.allowExts) > 0 && !contains(p.allowExts, ext) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, ext)
+	}
+	return nil
+}
+
+func isGeneric(contentType string) bool {
+	media := mediaType(contentType)
+	return media == "application/octet-stream" || media == "text/plain"
+}
+
+func hasPrefix(contentType string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaType strips parameters such as charset and lower-cases the type
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return media
+}
+
+func matchType(patterns []string, media string) bool {
+	for _, pattern := range patterns {
+		if pattern == media || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(media, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(values []string, extensions bool) []string {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if extensions && !strings.HasPrefix(value, ".") {
+			value = "." + value
+		}
+		normalized = append(normalized, value)
+	}
+	return normalized
+}
@@ -0,0 +1,58 @@
+package filetype
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		filename string
+		declared string
+		expected string
+	}{
+		{"magic bytes win over declared type", pngHeader, "photo.jpg", "image/jpeg", "image/png"},
+		{"html disguised as image", []byte("<html><body>hi</body></html>"), "photo.jpg", "image/jpeg", "text/html; charset=utf-8"},
+		{"unrecognised binary claiming image", []byte{0x4d, 0x5a, 0x90, 0x00}, "photo.png", "image/png", "application/octet-stream"},
+		{"text falls back to declared type", []byte("a,b,c\n1,2,3\n"), "data.csv", "text/csv", "text/csv"},
+		{"text falls back to extension", []byte(`{"a":1}`), "data.json", "", "application/json"},
+		{"office documents are zip containers", []byte("PK\x03\x04\x14\x00\x06\x00"), "report.docx", "", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"plain zip claiming image", []byte("PK\x03\x04\x14\x00\x06\x00"), "photo.png", "image/png", "application/zip"},
+		{"unknown binary keeps octet-stream", []byte{0x00, 0x01, 0x02}, "blob", "", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Detect(tt.head, tt.filename, tt.declared))
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := NewPolicy(
+		[]string{"image/*", "application/pdf"},
+		[]string{"image/svg+xml"},
+		nil,
+		[]string{"exe", ".BAT"},
+	)
+
+	assert.NoError(t, policy.Check("image/png", "photo.png"))
+	assert.NoError(t, policy.Check("application/pdf; charset=binary", "doc.pdf"))
+	assert.True(t, errors.Is(policy.Check("image/svg+xml", "logo.svg"), ErrNotAllowed))
+	assert.True(t, errors.Is(policy.Check("text/html", "page.html"), ErrNotAllowed))
+	assert.True(t, errors.Is(policy.Check("image/png", "setup.EXE"), ErrNotAllowed))
+	assert.True(t, errors.Is(policy.Check("image/png", "run.bat"), ErrNotAllowed))
+}
+
+func TestEmptyPolicyAllowsEverything(t *testing.T) {
+	policy := NewPolicy(nil, nil, nil, nil)
+
+	assert.NoError(t, policy.Check("application/octet-stream", "anything.bin"))
+	assert.NoError(t, policy.Check("", "noextension"))
+}
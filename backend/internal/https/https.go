@@ -0,0 +1,162 @@
+// Package https sets up serving HTTPS directly, with certificates from
+// files or from an ACME CA, and the plain HTTP redirect next to it.
+package https
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval is how often certificate files are checked for
+// renewed certificates
+const certCheckInterval = time.Minute
+
+// Server holds what serving HTTPS takes
+type Server struct {
+	TLSConfig *tls.Config
+	manager   *autocert.Manager // nil with certificate files
+}
+
+// New returns the TLS setup cfg selects, nil when TLS is off
+func New(cfg config.TLSConfig) (*Server, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	s := &Server{}
+	if cfg.CertFile != "" {
+		certs, err := newCertFile(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.TLSConfig = &tls.Config{
+			GetCertificate: certs.getCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
+	} else {
+		s.manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		if cfg.AutocertDirectoryURL != "" {
+			s.manager.Client = &acme.Client{DirectoryURL: cfg.AutocertDirectoryURL}
+		}
+		s.TLSConfig = s.manager.TLSConfig()
+	}
+
+	s.TLSConfig.MinVersion = tls.VersionTLS12
+	if cfg.MinVersion == "1.3" {
+		s.TLSConfig.MinVersion = tls.VersionTLS13
+	}
+	// Forward secret AEAD suites only; TLS 1.3 suites are not configurable
+	s.TLSConfig.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	}
+	return s, nil
+}
+
+// RedirectHandler returns the handler of the plain HTTP port, which
+// redirects requests to HTTPS on httpsPort and, with ACME, answers the
+// CA's HTTP challenges
+func (s *Server) RedirectHandler(httpsPort string) http.Handler {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		// 308 keeps the method and body of API calls
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	if s.manager != nil {
+		return s.manager.HTTPHandler(redirect)
+	}
+	return redirect
+}
+
+// HSTS adds a Strict-Transport-Security header of maxAge seconds to the
+// responses of next, which tells browsers to only use HTTPS from then on
+func HSTS(next http.Handler, maxAge int) http.Handler {
+	if maxAge <= 0 {
+		return next
+	}
+	value := "max-age=" + strconv.Itoa(maxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certFile serves a certificate from files, loading it again when the
+// files change, so renewed certificates apply without a restart
+type certFile struct {
+	certPath, keyPath string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	version   string
+	checkedAt time.Time
+}
+
+func newCertFile(certPath, keyPath string) (*certFile, error) {
+	c := &certFile{certPath: certPath, keyPath: keyPath}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certFile) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checkedAt) >= certCheckInterval {
+		c.checkedAt = time.Now()
+		if c.fileVersion() != c.version {
+			// A certificate being replaced may not load yet; the
+			// current one is kept until the next check
+			_ = c.load()
+		}
+	}
+	return c.cert, nil
+}
+
+// load reads the certificate and key; callers other than New hold mu
+func (c *certFile) load() error {
+	version := c.fileVersion()
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.cert, c.version, c.checkedAt = &cert, version, time.Now()
+	return nil
+}
+
+// fileVersion tells versions of the certificate and key files apart
+func (c *certFile) fileVersion() string {
+	var version string
+	for _, path := range []string{c.certPath, c.keyPath} {
+		if info, err := os.Stat(path); err == nil {
+			version += fmt.Sprintf("%d/%d;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return version
+}
@@ -0,0 +1,107 @@
+package https
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for name and its key
+func writeCert(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func TestNewDisabled(t *testing.T) {
+	s, err := New(config.TLSConfig{MinVersion: "1.2"})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeCert(t, dir, "first.example.com")
+
+	s, err := New(config.TLSConfig{CertFile: certPath, KeyFile: keyPath, MinVersion: "1.3"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), s.TLSConfig.MinVersion)
+
+	cert, err := s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "first.example.com", cert.Leaf.Subject.CommonName)
+
+	// A renewed certificate is picked up at the next check
+	certs, err := newCertFile(certPath, keyPath)
+	require.NoError(t, err)
+	writeCert(t, dir, "second.example.com")
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(certPath, later, later))
+	cert, err = certs.getCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "first.example.com", cert.Leaf.Subject.CommonName, "not checked yet")
+	certs.checkedAt = time.Time{}
+	cert, err = certs.getCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "second.example.com", cert.Leaf.Subject.CommonName)
+
+	_, err = New(config.TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyPath})
+	assert.Error(t, err)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	s := &Server{}
+	for port, location := range map[string]string{
+		"443":  "https://files.example.com/api/v1/files?page=2",
+		"8443": "https://files.example.com:8443/api/v1/files?page=2",
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "http://files.example.com:80/api/v1/files?page=2", nil)
+		s.RedirectHandler(port).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		assert.Equal(t, location, w.Header().Get("Location"))
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	HSTS(ok, 31536000).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	HSTS(ok, 0).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+}
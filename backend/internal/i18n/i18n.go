@@ -0,0 +1,150 @@
+// Package i18n translates the messages of API errors and validation
+// failures. Messages are written in English; a bundle per language under
+// locales translates them, chosen by the Accept-Language of a request.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the language messages are written in
+const Default = "en"
+
+// Bundle holds the translations of one language
+type Bundle struct {
+	// Messages translate whole English messages, such as "Post not found"
+	Messages map[string]string `json:"messages"`
+	// Codes give every error code a general message, used for messages
+	// without a translation of their own
+	Codes map[string]string `json:"codes"`
+	// Rules are the messages of broken validation rules, with {name},
+	// {param}, {unit} and {type} filled in
+	Rules map[string]string `json:"rules"`
+	// Units are what a length limit counts, such as characters
+	Units map[string]string `json:"units"`
+	// Types describe JSON values, such as an integer
+	Types map[string]string `json:"types"`
+}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var bundles = loadBundles()
+
+func loadBundles() map[string]*Bundle {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]*Bundle, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var bundle Bundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			panic("i18n: " + file.Name() + ": " + err.Error())
+		}
+		loaded[strings.TrimSuffix(file.Name(), ".json")] = &bundle
+	}
+	return loaded
+}
+
+// Match returns the supported language an Accept-Language header prefers,
+// such as es for "es-MX,es;q=0.9,en;q=0.5", or Default. Regional variants
+// get their base language.
+func Match(acceptLanguage string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var wanted []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if base != "" && q > 0 {
+			wanted = append(wanted, weighted{base, q})
+		}
+	}
+	slices.SortStableFunc(wanted, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, w := range wanted {
+		if w.lang == Default || bundles[w.lang] != nil {
+			return w.lang
+		}
+	}
+	return Default
+}
+
+// Message translates the message of an error with code into lang: its own
+// translation if it has one, else the general message of the code, else
+// the message as it is
+func Message(lang, code, message string) string {
+	bundle := bundles[lang]
+	if bundle == nil {
+		return message
+	}
+	if translated, ok := bundle.Messages[message]; ok {
+		return translated
+	}
+	if translated, ok := bundle.Codes[code]; ok {
+		return translated
+	}
+	return message
+}
+
+// Rule returns the message of a broken validation rule in lang with args
+// filled in, or "" when lang has none for it. The unit and type args name
+// entries of the bundle's units and types; an unknown one leaves the
+// message untranslated as well.
+func Rule(lang, rule string, args map[string]string) string {
+	bundle := bundles[lang]
+	if bundle == nil {
+		return ""
+	}
+	template, ok := bundle.Rules[rule]
+	if !ok {
+		return ""
+	}
+
+	pairs := make([]string, 0, 2*len(args))
+	for name, value := range args {
+		switch name {
+		case "unit":
+			if value != "" {
+				if value, ok = bundle.Units[value]; !ok {
+					return ""
+				}
+				value = " " + value
+			}
+		case "type":
+			if value, ok = bundle.Types[value]; !ok {
+				return ""
+			}
+		}
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
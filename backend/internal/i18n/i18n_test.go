@@ -0,0 +1,52 @@
+package i18n
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	cases := map[string]string{
+		"":                        Default,
+		"es":                      "es",
+		"fr-CA":                   "fr",
+		"de-DE,fr;q=0.7,es;q=0.9": "es",
+		"de,en;q=0.9,es;q=0.8":    "en",
+		"es;q=0,fr;q=0.1":         "fr",
+		"*":                       Default,
+		"es;q=x":                  Default,
+	}
+	for header, want := range cases {
+		assert.Equal(t, want, Match(header), header)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "Usuario no encontrado", Message("es", "USER_NOT_FOUND", "User not found"))
+	assert.Equal(t, "Utilisateur introuvable", Message("fr", "USER_NOT_FOUND", "No such user"), "falls back to the code")
+	assert.Equal(t, "Something odd", Message("fr", "", "Something odd"))
+	assert.Equal(t, "User not found", Message(Default, "USER_NOT_FOUND", "User not found"))
+}
+
+func TestRule(t *testing.T) {
+	assert.Equal(t, "title debe ser como máximo 200 caracteres", Rule("es", "max", map[string]string{"name": "title", "param": "200", "unit": "characters"}))
+	assert.Equal(t, "age doit être d'au moins 18", Rule("fr", "min", map[string]string{"name": "age", "param": "18", "unit": ""}))
+	assert.Equal(t, "", Rule("es", "max", map[string]string{"name": "title", "param": "2", "unit": "bytes"}))
+	assert.Equal(t, "", Rule("es", "unknown", nil))
+	assert.Equal(t, "", Rule(Default, "required", map[string]string{"name": "title"}))
+}
+
+// Every bundle translates the same messages, codes and rules
+func TestBundlesComplete(t *testing.T) {
+	reference := bundles["es"]
+	for lang, bundle := range bundles {
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(reference.Messages)), slices.Collect(maps.Keys(bundle.Messages)), lang)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(reference.Codes)), slices.Collect(maps.Keys(bundle.Codes)), lang)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(reference.Rules)), slices.Collect(maps.Keys(bundle.Rules)), lang)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(reference.Units)), slices.Collect(maps.Keys(bundle.Units)), lang)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(reference.Types)), slices.Collect(maps.Keys(bundle.Types)), lang)
+	}
+}
@@ -0,0 +1,243 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// ErrMalformed is returned for images whose structure cannot be walked
+var ErrMalformed = errors.New("malformed image")
+
+// Result of stripping an image
+type Result struct {
+	Data []byte            // image without metadata
+	Tags map[string]string // selected tags from the removed EXIF block
+}
+
+// IsSupported reports whether metadata can be stripped from contentType
+func IsSupported(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// Strip removes EXIF, XMP, IPTC and text metadata from a JPEG or PNG without
+// re-encoding the pixels. The JPEG orientation is preserved so images do not
+// appear rotated after stripping.
+func Strip(contentType string, data []byte) (*Result, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	}
+	return nil, fmt.Errorf("unsupported content type %s", contentType)
+}
+
+const (
+	markerSOI  = 0xD8
+	markerSOS  = 0xDA
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerIPTC = 0xED // APP13, Photoshop/IPTC
+	markerCOM  = 0xFE
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+func stripJPEG(data []byte) (*Result, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, ErrMalformed
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	out.Write(data[:2])
+
+	var tiff []byte
+	wroteOrientation := false
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, ErrMalformed
+		}
+		marker := data[pos+1]
+		// Fill bytes may pad markers
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == markerSOS {
+			insertOrientation(&out, tiff, &wroteOrientation)
+			out.Write(data[pos:])
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		segment := data[pos:end]
+		payload := data[pos+4 : end]
+		pos = end
+
+		switch {
+		case marker == markerAPP1:
+			if tiff == nil && bytes.HasPrefix(payload, exifHeader) {
+				tiff = payload[len(exifHeader):]
+			}
+			continue
+		case marker == markerIPTC, marker == markerCOM:
+			continue
+		}
+
+		// Keep APP0 (JFIF) first, then restore the orientation after it
+		if marker != markerAPP0 {
+			insertOrientation(&out, tiff, &wroteOrientation)
+		}
+		out.Write(segment)
+	}
+
+	return &Result{Data: out.Bytes(), Tags: readTags(tiff)}, nil
+}
+
+// insertOrientation writes a minimal EXIF block holding only the orientation
+// of the original, if it had a non-default one
+func insertOrientation(out *bytes.Buffer, tiff []byte, written *bool) {
+	if *written || tiff == nil {
+		return
+	}
+	*written = true
+
+	orientation := readOrientation(tiff)
+	if orientation <= 1 {
+		return
+	}
+
+	var block bytes.Buffer
+	block.Write(exifHeader)
+	block.WriteString("MM\x00*")                                // big-endian TIFF header
+	binary.Write(&block, binary.BigEndian, uint32(8))           // IFD0 offset
+	binary.Write(&block, binary.BigEndian, uint16(1))           // one entry
+	binary.Write(&block, binary.BigEndian, uint16(0x0112))      // Orientation
+	binary.Write(&block, binary.BigEndian, uint16(3))           // SHORT
+	binary.Write(&block, binary.BigEndian, uint32(1))           // count
+	binary.Write(&block, binary.BigEndian, uint16(orientation)) // value
+	binary.Write(&block, binary.BigEndian, uint16(0))           // padding
+	binary.Write(&block, binary.BigEndian, uint32(0))           // no next IFD
+
+	out.Write([]byte{0xFF, markerAPP1})
+	binary.Write(out, binary.BigEndian, uint16(block.Len()+2))
+	out.Write(block.Bytes())
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are ancillary chunks that carry metadata, not pixels
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) (*Result, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	out.Write(pngSignature)
+
+	var tiff []byte
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, ErrMalformed
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		chunk := data[pos:end]
+		pos = end
+
+		if chunkType == "eXIf" && tiff == nil {
+			tiff = chunk[8 : 8+length]
+		}
+		if pngMetadataChunks[chunkType] {
+			continue
+		}
+		out.Write(chunk)
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	return &Result{Data: out.Bytes(), Tags: readTags(tiff)}, nil
+}
+
+func readOrientation(tiff []byte) int {
+	x, err := exif.Decode(bytes.NewReader(tiff))
+	if err != nil {
+		return 0
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 0
+	}
+	orientation, err := tag.Int(0)
+	if err != nil {
+		return 0
+	}
+	return orientation
+}
+
+// keptTags are the EXIF fields worth keeping for the owner
+var keptTags = map[exif.FieldName]string{
+	exif.Make:             "make",
+	exif.Model:            "model",
+	exif.LensModel:        "lensModel",
+	exif.Software:         "software",
+	exif.DateTimeOriginal: "dateTimeOriginal",
+}
+
+// readTags extracts a readable subset of the EXIF block, or nil if there is
+// none or it cannot be parsed
+func readTags(tiff []byte) map[string]string {
+	if tiff == nil {
+		return nil
+	}
+	x, err := exif.Decode(bytes.NewReader(tiff))
+	if err != nil && x == nil {
+		return nil
+	}
+
+	tags := make(map[string]string)
+	for field, name := range keptTags {
+		tag, err := x.Get(field)
+		if err != nil {
+			continue
+		}
+		if value, err := tag.StringVal(); err == nil && value != "" {
+			tags[name] = value
+		}
+	}
+	if lat, long, err := x.LatLong(); err == nil {
+		tags["gpsLatitude"] = strconv.FormatFloat(lat, 'f', 6, 64)
+		tags["gpsLongitude"] = strconv.FormatFloat(long, 'f', 6, 64)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,110 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTIFF builds a big-endian TIFF block with Make and Orientation tags
+func testTIFF(make string, orientation uint16) []byte {
+	value := append([]byte(make), 0)
+	var b bytes.Buffer
+	b.WriteString("MM\x00*")
+	binary.Write(&b, binary.BigEndian, uint32(8))
+	binary.Write(&b, binary.BigEndian, uint16(2))
+	// Make, ASCII, stored after the IFD
+	binary.Write(&b, binary.BigEndian, []uint16{0x010F, 2})
+	binary.Write(&b, binary.BigEndian, uint32(len(value)))
+	binary.Write(&b, binary.BigEndian, uint32(8+2+2*12+4))
+	// Orientation, SHORT, inline
+	binary.Write(&b, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&b, binary.BigEndian, uint32(1))
+	binary.Write(&b, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	b.Write(value)
+	return b.Bytes()
+}
+
+func testJPEG(t *testing.T, tiff []byte) []byte {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	data := encoded.Bytes()
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write([]byte{0xFF, 0xFE, 0x00, 0x07})
+	out.WriteString("hello")
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestStripJPEG(t *testing.T) {
+	original := testJPEG(t, testTIFF("Canon", 6))
+
+	result, err := Strip("image/jpeg", original)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Canon", result.Tags["make"])
+	assert.NotContains(t, string(result.Data), "Canon")
+	assert.NotContains(t, string(result.Data), "hello")
+	assert.Equal(t, 6, readOrientation(result.Data[4+2+6:]), "orientation must survive stripping")
+
+	_, err = jpeg.Decode(bytes.NewReader(result.Data))
+	assert.NoError(t, err)
+}
+
+func TestStripJPEGDefaultOrientation(t *testing.T) {
+	result, err := Strip("image/jpeg", testJPEG(t, testTIFF("Canon", 1)))
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(result.Data), "Exif")
+}
+
+func TestStripPNG(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8))))
+	data := encoded.Bytes()
+
+	chunk := func(chunkType string, payload []byte) []byte {
+		var c bytes.Buffer
+		binary.Write(&c, binary.BigEndian, uint32(len(payload)))
+		c.WriteString(chunkType)
+		c.Write(payload)
+		binary.Write(&c, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), payload...)))
+		return c.Bytes()
+	}
+
+	// Insert metadata chunks right after IHDR
+	ihdrEnd := 8 + 12 + 13
+	var original bytes.Buffer
+	original.Write(data[:ihdrEnd])
+	original.Write(chunk("eXIf", testTIFF("Nikon", 1)))
+	original.Write(chunk("tEXt", []byte("Author\x00someone")))
+	original.Write(data[ihdrEnd:])
+
+	result, err := Strip("image/png", original.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, "Nikon", result.Tags["make"])
+	assert.Equal(t, data, result.Data)
+}
+
+func TestStripMalformed(t *testing.T) {
+	_, err := Strip("image/jpeg", []byte("not a jpeg"))
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = Strip("image/gif", []byte("GIF89a"))
+	assert.Error(t, err)
+}
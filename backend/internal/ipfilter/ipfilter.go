@@ -0,0 +1,85 @@
+// Package ipfilter decides which client addresses may use the API from
+// lists of allowed and denied networks.
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Filter holds allowed and denied networks. Denied networks win; with no
+// allowed networks every address not denied is allowed.
+type Filter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// New parses the allowed and denied entries, each a CIDR network such as
+// 10.0.0.0/8 or a single address. It returns nil when both are empty, and
+// a nil filter allows everything.
+func New(allow, deny []string) (*Filter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	f := &Filter{}
+	var err error
+	if f.allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parsePrefixes parses CIDR networks and single addresses
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address or network %q", entry)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or network %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether ip, in any form net/netip parses, may pass.
+// Addresses that do not parse are refused unless the filter is nil.
+func (f *Filter) Allowed(ip string) bool {
+	if f == nil {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	if contains(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, addr)
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package ipfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	f, err := New(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	assert.True(t, f.Allowed("203.0.113.7"))
+
+	_, err = New([]string{"10.0.0.0/33"}, nil)
+	assert.Error(t, err)
+	_, err = New(nil, []string{"not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestAllowed(t *testing.T) {
+	f, err := New([]string{"10.0.0.0/8", "2001:db8::/32", "203.0.113.7"}, []string{"10.0.5.0/24"})
+	require.NoError(t, err)
+
+	assert.True(t, f.Allowed("10.1.2.3"))
+	assert.True(t, f.Allowed("::ffff:10.1.2.3"))
+	assert.True(t, f.Allowed("2001:db8::1"))
+	assert.True(t, f.Allowed("203.0.113.7"))
+	assert.False(t, f.Allowed("10.0.5.9"), "denied networks win")
+	assert.False(t, f.Allowed("203.0.113.8"))
+	assert.False(t, f.Allowed("garbage"))
+
+	denyOnly, err := New(nil, []string{"198.51.100.0/24"})
+	require.NoError(t, err)
+	assert.True(t, denyOnly.Allowed("203.0.113.8"))
+	assert.False(t, denyOnly.Allowed("198.51.100.1"))
+}
@@ -0,0 +1,361 @@
+// Package jobs runs background work, such as virus scans and thumbnails,
+// from a queue in NATS JetStream.
+//
+// Each job type has its own subject, jobs.<type>, and a durable consumer
+// the servers share, so a job runs once even when the server handling it
+// stops midway. Failed attempts are retried with exponential backoff; a
+// job that keeps failing, or fails with a Permanent error, is moved to the
+// dead letters of its type in Redis, where admins can inspect and retry
+// it. Redis also counts the jobs of each type for the status API.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Defaults of Options
+const (
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = 5 * time.Minute
+)
+
+// Retries wait firstRetry, doubling after each failed attempt up to
+// maxRetry
+const (
+	firstRetry = 10 * time.Second
+	maxRetry   = 10 * time.Minute
+)
+
+// maxDeadJobs is how many dead letters are kept per type
+const maxDeadJobs = 1000
+
+const (
+	keyPrefix = "jobs:"
+	typesKey  = keyPrefix + "types"
+)
+
+// Job is one unit of background work
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+
+	// Attempt is the number of the current attempt, starting at 1
+	Attempt int `json:"-"`
+}
+
+// Decode unmarshals the payload of the job into v. A payload that does
+// not decode never will, so the error is permanent.
+func (j *Job) Decode(v any) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("invalid %s job payload: %w", j.Type, err))
+	}
+	return nil
+}
+
+// Handler does the work of a job. Returning an error retries the job,
+// unless it is Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options control how the jobs of a type run. Zero fields take the
+// defaults.
+type Options struct {
+	MaxAttempts int           // before the job is dead
+	Timeout     time.Duration // of each attempt
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying cannot fix, such as a missing file,
+// so the job is dead at once
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// Backoff returns how long to wait before the next attempt after attempts
+// failed ones
+func Backoff(attempts int) time.Duration {
+	delay := firstRetry
+	for i := 1; i < attempts && delay < maxRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetry)
+}
+
+type registration struct {
+	handler Handler
+	opts    Options
+}
+
+// Queue enqueues jobs and runs the handlers registered on it
+type Queue struct {
+	messaging *messaging.Client
+	redis     *redis.Client
+
+	mu       sync.Mutex
+	handlers map[string]registration
+}
+
+func NewQueue(messagingClient *messaging.Client, redisClient *redis.Client) *Queue {
+	return &Queue{
+		messaging: messagingClient,
+		redis:     redisClient,
+		handlers:  make(map[string]registration),
+	}
+}
+
+// Register makes this server run jobs of jobType with handler once Start
+// is called
+func (q *Queue) Register(jobType string, handler Handler, opts Options) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	q.mu.Lock()
+	q.handlers[jobType] = registration{handler: handler, opts: opts}
+	q.mu.Unlock()
+}
+
+// Start consumes the jobs of every registered type
+func (q *Queue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for jobType, reg := range q.handlers {
+		if err := q.redis.SAdd(ctx, typesKey, jobType).Err(); err != nil {
+			return fmt.Errorf("failed to register %s jobs: %w", jobType, err)
+		}
+		err := q.messaging.Consume(ctx, messaging.JobStream, consumerName(jobType), subject(jobType), func(ctx context.Context, data []byte, attempt int) error {
+			return q.run(ctx, jobType, reg, data, attempt)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enqueue queues a job of jobType carrying payload and returns its ID
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s job: %w", jobType, err)
+	}
+	job := &Job{
+		ID:         uuid.New().String(),
+		Type:       jobType,
+		Payload:    data,
+		EnqueuedAt: time.Now().UTC(),
+	}
+	if err := q.publish(ctx, job); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+func (q *Queue) publish(ctx context.Context, job *Job) error {
+	if err := q.messaging.PublishStream(ctx, subject(job.Type), job.ID, job); err != nil {
+		return err
+	}
+	q.count(ctx, job.Type, "enqueued")
+	return nil
+}
+
+func (q *Queue) run(ctx context.Context, jobType string, reg registration, data []byte, attempt int) error {
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		log.Printf("jobs: invalid %s job: %v", jobType, err)
+		return err
+	}
+	job.Attempt = attempt
+
+	// A job redelivered after its last attempt belongs with the dead ones
+	if attempt > reg.opts.MaxAttempts {
+		return q.bury(ctx, &job, errors.New("attempts exhausted"))
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, reg.opts.Timeout)
+	err := reg.handler(runCtx, &job)
+	cancel()
+
+	switch {
+	case err == nil:
+		q.count(ctx, jobType, "succeeded")
+		return nil
+	case IsPermanent(err) || attempt >= reg.opts.MaxAttempts:
+		log.Printf("jobs: %s job %s failed for good after %d attempts: %v", jobType, job.ID, attempt, err)
+		q.count(ctx, jobType, "failed")
+		return q.bury(ctx, &job, err)
+	default:
+		log.Printf("jobs: %s job %s attempt %d: %v", jobType, job.ID, attempt, err)
+		q.count(ctx, jobType, "failed")
+		q.count(ctx, jobType, "retried")
+		return messaging.Retry(Backoff(attempt), err)
+	}
+}
+
+// bury moves job to the dead letters of its type. The message is dropped
+// either way; a dead letter that cannot be stored is only logged.
+func (q *Queue) bury(ctx context.Context, job *Job, cause error) error {
+	dead := models.DeadJob{
+		ID:         job.ID,
+		Type:       job.Type,
+		Payload:    job.Payload,
+		Attempts:   job.Attempt,
+		Error:      cause.Error(),
+		EnqueuedAt: job.EnqueuedAt,
+		FailedAt:   time.Now().UTC(),
+	}
+	data, err := json.Marshal(dead)
+	if err == nil {
+		pipe := q.redis.TxPipeline()
+		pipe.LPush(ctx, deadKey(job.Type), data)
+		pipe.LTrim(ctx, deadKey(job.Type), 0, maxDeadJobs-1)
+		pipe.HIncrBy(ctx, statsKey(job.Type), "dead", 1)
+		pipe.HSet(ctx, statsKey(job.Type), "lastError", dead.Error, "lastErrorAt", dead.FailedAt.Unix())
+		_, err = pipe.Exec(ctx)
+	}
+	if err != nil {
+		log.Printf("jobs: failed to store dead %s job %s: %v", job.Type, job.ID, err)
+	}
+	return cause
+}
+
+// count adds one to the counter name of jobType. Counters only inform the
+// status API, so errors are ignored.
+func (q *Queue) count(ctx context.Context, jobType, name string) {
+	q.redis.HIncrBy(context.WithoutCancel(ctx), statsKey(jobType), name, 1)
+}
+
+// Status returns the state of every job type any server has run, ordered
+// by type
+func (q *Queue) Status(ctx context.Context) ([]models.JobStatus, error) {
+	types, err := q.redis.SMembers(ctx, typesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job types: %w", err)
+	}
+	slices.Sort(types)
+
+	statuses := make([]models.JobStatus, 0, len(types))
+	for _, jobType := range types {
+		counters, err := q.redis.HGetAll(ctx, statsKey(jobType)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s job counters: %w", jobType, err)
+		}
+
+		status := models.JobStatus{Type: jobType, LastError: counters["lastError"]}
+		status.Enqueued, _ = strconv.ParseInt(counters["enqueued"], 10, 64)
+		status.Succeeded, _ = strconv.ParseInt(counters["succeeded"], 10, 64)
+		status.Failed, _ = strconv.ParseInt(counters["failed"], 10, 64)
+		status.Retried, _ = strconv.ParseInt(counters["retried"], 10, 64)
+		status.Dead, _ = strconv.ParseInt(counters["dead"], 10, 64)
+		if at, err := strconv.ParseInt(counters["lastErrorAt"], 10, 64); err == nil {
+			lastErrorAt := time.Unix(at, 0).UTC()
+			status.LastErrorAt = &lastErrorAt
+		}
+		status.DeadLetters, _ = q.redis.LLen(ctx, deadKey(jobType)).Result()
+
+		if state, err := q.messaging.ConsumerState(ctx, messaging.JobStream, consumerName(jobType)); err == nil {
+			status.Pending = int64(state.Pending)
+			status.InFlight = int64(state.InFlight)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// DeadJobs returns up to limit dead letters of jobType, newest first
+func (q *Queue) DeadJobs(ctx context.Context, jobType string, limit int) ([]models.DeadJob, error) {
+	values, err := q.redis.LRange(ctx, deadKey(jobType), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead %s jobs: %w", jobType, err)
+	}
+
+	deadJobs := make([]models.DeadJob, 0, len(values))
+	for _, value := range values {
+		var dead models.DeadJob
+		if err := json.Unmarshal([]byte(value), &dead); err != nil {
+			continue
+		}
+		deadJobs = append(deadJobs, dead)
+	}
+	return deadJobs, nil
+}
+
+// RetryDead queues the dead letters of jobType again, with fresh
+// attempts, and returns how many were queued
+func (q *Queue) RetryDead(ctx context.Context, jobType string) (int, error) {
+	retried := 0
+	for {
+		// Popping from the end takes the oldest first and leaves letters
+		// added meanwhile
+		value, err := q.redis.RPop(ctx, deadKey(jobType)).Result()
+		if errors.Is(err, redis.Nil) {
+			return retried, nil
+		}
+		if err != nil {
+			return retried, fmt.Errorf("failed to read dead %s jobs: %w", jobType, err)
+		}
+
+		var dead models.DeadJob
+		if err := json.Unmarshal([]byte(value), &dead); err != nil {
+			continue
+		}
+		job := &Job{
+			ID:         uuid.New().String(),
+			Type:       dead.Type,
+			Payload:    dead.Payload,
+			EnqueuedAt: time.Now().UTC(),
+		}
+		if err := q.publish(ctx, job); err != nil {
+			// Put it back so it is not lost
+			q.redis.RPush(context.WithoutCancel(ctx), deadKey(jobType), value)
+			return retried, err
+		}
+		retried++
+	}
+}
+
+func subject(jobType string) string {
+	return messaging.JobSubjectPrefix + jobType
+}
+
+func consumerName(jobType string) string {
+	return "jobs-" + jobType
+}
+
+func statsKey(jobType string) string {
+	return keyPrefix + "stats:" + jobType
+}
+
+func deadKey(jobType string) string {
+	return keyPrefix + "dead:" + jobType
+}
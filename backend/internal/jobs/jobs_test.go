@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestQueue(t *testing.T) *Queue {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewQueue(nil, client)
+}
+
+func testJob(t *testing.T, payload any) []byte {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	job, err := json.Marshal(Job{ID: "job-1", Type: "scan", Payload: data, EnqueuedAt: time.Now().UTC()})
+	require.NoError(t, err)
+	return job
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, Backoff(1))
+	assert.Equal(t, 20*time.Second, Backoff(2))
+	assert.Equal(t, 80*time.Second, Backoff(4))
+	assert.Equal(t, 10*time.Minute, Backoff(8))
+	assert.Equal(t, 10*time.Minute, Backoff(100))
+}
+
+func TestPermanent(t *testing.T) {
+	cause := errors.New("file not found")
+	err := fmt.Errorf("file f1: %w", Permanent(cause))
+
+	assert.True(t, IsPermanent(err))
+	assert.ErrorIs(t, err, cause)
+	assert.False(t, IsPermanent(cause))
+
+	job := &Job{Type: "scan", Payload: json.RawMessage(`"not an object"`)}
+	var v struct{ FileID string }
+	assert.True(t, IsPermanent(job.Decode(&v)))
+}
+
+func TestRun(t *testing.T) {
+	queue := newTestQueue(t)
+	ctx := context.Background()
+	failing := errors.New("scanner unavailable")
+	reg := registration{
+		handler: func(ctx context.Context, job *Job) error { return failing },
+		opts:    Options{MaxAttempts: 3, Timeout: time.Second},
+	}
+	data := testJob(t, map[string]string{"fileId": "f1"})
+
+	// Attempts before the last are retried with backoff
+	err := queue.run(ctx, "scan", reg, data, 2)
+	var retry *messaging.RetryError
+	require.ErrorAs(t, err, &retry)
+	assert.Equal(t, Backoff(2), retry.Delay)
+
+	deadJobs, err := queue.DeadJobs(ctx, "scan", 10)
+	require.NoError(t, err)
+	assert.Empty(t, deadJobs)
+
+	// The last attempt buries the job
+	err = queue.run(ctx, "scan", reg, data, 3)
+	assert.ErrorIs(t, err, failing)
+	assert.False(t, errors.As(err, &retry))
+
+	deadJobs, err = queue.DeadJobs(ctx, "scan", 10)
+	require.NoError(t, err)
+	require.Len(t, deadJobs, 1)
+	assert.Equal(t, "job-1", deadJobs[0].ID)
+	assert.Equal(t, 3, deadJobs[0].Attempts)
+	assert.Equal(t, "scanner unavailable", deadJobs[0].Error)
+	assert.JSONEq(t, `{"fileId":"f1"}`, string(deadJobs[0].Payload))
+
+	counters, err := queue.redis.HGetAll(ctx, statsKey("scan")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "2", counters["failed"])
+	assert.Equal(t, "1", counters["retried"])
+	assert.Equal(t, "1", counters["dead"])
+	assert.Equal(t, "scanner unavailable", counters["lastError"])
+}
+
+func TestRunPermanent(t *testing.T) {
+	queue := newTestQueue(t)
+	ctx := context.Background()
+	reg := registration{
+		handler: func(ctx context.Context, job *Job) error {
+			var v struct{ FileID string }
+			return job.Decode(&v)
+		},
+		opts: Options{MaxAttempts: 5, Timeout: time.Second},
+	}
+
+	// A permanent error buries the job on the first attempt
+	err := queue.run(ctx, "scan", reg, testJob(t, "not an object"), 1)
+	var retry *messaging.RetryError
+	assert.False(t, errors.As(err, &retry))
+
+	deadJobs, err := queue.DeadJobs(ctx, "scan", 10)
+	require.NoError(t, err)
+	require.Len(t, deadJobs, 1)
+	assert.Equal(t, 1, deadJobs[0].Attempts)
+
+	reg.handler = func(ctx context.Context, job *Job) error { return nil }
+	require.NoError(t, queue.run(ctx, "scan", reg, testJob(t, map[string]string{}), 1))
+	succeeded, err := queue.redis.HGet(ctx, statsKey("scan"), "succeeded").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "1", succeeded)
+}
@@ -0,0 +1,62 @@
+// Package logging sets up the structured logger of the server and carries
+// request-scoped loggers in contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing to w at the configured level, as JSON
+// lines or, for reading in a terminal, as key=value text
+func New(cfg config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	return NewWithLevel(cfg, new(slog.LevelVar), w)
+}
+
+// NewWithLevel is New with the level kept in level, which can be changed
+// with SetLevel while the logger is used
+func NewWithLevel(cfg config.LogConfig, level *slog.LevelVar, w io.Writer) (*slog.Logger, error) {
+	if err := SetLevel(level, cfg.Level); err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "json", "":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, expected json or text", cfg.Format)
+}
+
+// SetLevel sets level to the level named name: debug, info, warn or error
+func SetLevel(level *slog.LevelVar, name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, which carries the attributes of
+// its request, or fallback outside a request
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(config.LogConfig{Level: "warn", Format: "json"}, &out)
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept", "userId", "alice")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "kept", line["msg"])
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "alice", line["userId"])
+
+	_, err = New(config.LogConfig{Level: "loud"}, &out)
+	assert.Error(t, err)
+	_, err = New(config.LogConfig{Level: "info", Format: "xml"}, &out)
+	assert.Error(t, err)
+}
+
+func TestSetLevel(t *testing.T) {
+	var out bytes.Buffer
+	level := new(slog.LevelVar)
+	logger, err := NewWithLevel(config.LogConfig{Level: "error", Format: "text"}, level, &out)
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	assert.Empty(t, out.String())
+
+	require.NoError(t, SetLevel(level, "debug"))
+	logger.Debug("kept")
+	assert.Contains(t, out.String(), "kept")
+
+	assert.Error(t, SetLevel(level, "loud"))
+	assert.Equal(t, slog.LevelDebug, level.Level(), "a bad level changes nothing")
+}
+
+func TestContext(t *testing.T) {
+	fallback := slog.Default()
+	assert.Same(t, fallback, FromContext(context.Background(), fallback))
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ctx := NewContext(context.Background(), logger)
+	assert.Same(t, logger, FromContext(ctx, fallback))
+	assert.Same(t, logger, FromContext(context.WithoutCancel(ctx), fallback), "kept by background work")
+}
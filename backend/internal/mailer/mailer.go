@@ -0,0 +1,186 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+)
+
+// JobType is the type of the background jobs QueuedMailer sends emails
+// with; their payload is a Message
+const JobType = "email"
+
+// Message is an email with a plain text body and, optionally, an HTML
+// alternative
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer cfg selects: SMTP when a host is set, otherwise,
+// or in dev mode, a LogMailer
+func New(cfg config.MailConfig) Mailer {
+	if cfg.SMTPHost == "" || cfg.DevMode {
+		return LogMailer{}
+	}
+	return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+}
+
+// ReloadingMailer sends each email with the mailer New selects for the
+// current settings, so changed SMTP credentials apply without a restart
+type ReloadingMailer struct {
+	settings func() config.MailConfig
+}
+
+func NewReloadingMailer(settings func() config.MailConfig) *ReloadingMailer {
+	return &ReloadingMailer{settings: settings}
+}
+
+func (m *ReloadingMailer) Send(ctx context.Context, msg Message) error {
+	return New(m.settings()).Send(ctx, msg)
+}
+
+// LogMailer writes emails to the log instead of sending them, for
+// development setups without a mail server
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// QueuedMailer queues emails as background jobs, so requests do not wait
+// for the mail server and failed sends are retried. The jobs are sent by
+// the handler registered for JobType.
+type QueuedMailer struct {
+	queue *jobs.Queue
+}
+
+func NewQueuedMailer(queue *jobs.Queue) *QueuedMailer {
+	return &QueuedMailer{queue: queue}
+}
+
+func (m *QueuedMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+	_, err := m.queue.Enqueue(ctx, JobType, msg)
+	return err
+}
+
+// SMTPMailer sends emails through an SMTP server, using STARTTLS when the
+// server offers it
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	data, err := compose(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	if err := smtp.SendMail(addr, auth, m.from, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// compose formats msg as a MIME message: plain text, or
+// multipart/alternative with the HTML last when it has an HTML body.
+// Bodies are quoted-printable so long lines stay within SMTP limits.
+func compose(from string, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + msg.To + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuoted(&buf, msg.Body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	buf.WriteString("Content-Type: multipart/alternative; boundary=" + parts.Boundary() + "\r\n\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Body},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose email: %w", err)
+		}
+		if err := writeQuoted(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compose email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuoted(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	msg, err := Render(TemplatePasswordReset, "ada@example.com", PasswordResetData{
+		Name:     "Ada",
+		Username: "ada",
+		Link:     "https://example.com/reset-password?token=abc&x=1",
+		ValidFor: "30m0s",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "ada@example.com", msg.To)
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.Body, "Hi Ada,")
+	assert.Contains(t, msg.Body, "https://example.com/reset-password?token=abc&x=1\n")
+	assert.Contains(t, msg.HTML, `href="https://example.com/reset-password?token=abc&amp;x=1"`)
+	assert.Contains(t, msg.HTML, "Reset password</a>")
+
+	_, err = Render("unknown", "ada@example.com", nil)
+	assert.Error(t, err)
+}
+
+func TestRenderEscapes(t *testing.T) {
+	msg, err := Render(TemplateShare, "bob@example.com", ShareData{
+		SharedBy:    "Ada",
+		FileName:    "<script>x</script>\r\nBcc: eve@example.com",
+		Note:        "For the meeting",
+		Link:        "https://example.com/shares/token",
+		ExpiresAt:   "Mon, 02 Jan 2026 15:04:05 UTC",
+		HasPassword: true,
+	})
+	require.NoError(t, err)
+
+	// Line breaks cannot add headers through the subject
+	assert.Equal(t, "Ada shared <script>x</script> Bcc: eve@example.com with you", msg.Subject)
+	assert.NotContains(t, msg.HTML, "<script>")
+	assert.Contains(t, msg.HTML, "For the meeting")
+	assert.Contains(t, msg.Body, "protected by a password")
+}
+
+func TestCompose(t *testing.T) {
+	msg := Message{
+		To:      "ada@example.com",
+		Subject: "Grüße",
+		Body:    "Hi Ada,\n\n" + strings.Repeat("long line ", 200),
+		HTML:    "<p>Hi Ada,</p>",
+	}
+	data, err := compose("MinIO Storage <noreply@example.com>", msg, time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC))
+	require.NoError(t, err)
+
+	for _, line := range strings.Split(string(data), "\r\n") {
+		assert.LessOrEqual(t, len(line), 998)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Grüße", subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	// multipart.Reader decodes quoted-printable parts
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}
+	require.Len(t, bodies, 2)
+	assert.Equal(t, strings.ReplaceAll(msg.Body, "\n", "\r\n"), bodies[0])
+	assert.Equal(t, msg.HTML, bodies[1])
+}
+
+func TestRenderDigest(t *testing.T) {
+	msg, err := Render(TemplateDigest, "ada@example.com", DigestData{
+		Name:  "Ada",
+		Since: "Mon, 05 Jan 2026",
+		Posts: []DigestPost{
+			{Title: "Hello", Author: "bob", Link: "https://example.com/posts/p1"},
+		},
+		MorePosts: 3,
+		ExpiringFiles: []DigestFile{
+			{Name: "report.pdf", ExpiresAt: "Fri, 09 Jan 2026 12:00 UTC"},
+		},
+		FilesLink: "https://example.com/files",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "What's new since Mon, 05 Jan 2026", msg.Subject)
+	assert.Contains(t, msg.Body, "- Hello by bob\n  https://example.com/posts/p1\n- and 3 more")
+	assert.Contains(t, msg.Body, "- report.pdf, on Fri, 09 Jan 2026 12:00 UTC")
+	assert.Contains(t, msg.HTML, `<a href="https://example.com/posts/p1" style="color:#c72c48">Hello</a> by bob`)
+	assert.Contains(t, msg.HTML, "View your files</a>")
+
+	// Sections without news are left out
+	msg, err = Render(TemplateDigest, "ada@example.com", DigestData{Name: "Ada", Since: "Mon, 05 Jan 2026", Posts: []DigestPost{{Title: "Hello", Author: "bob", Link: "https://example.com/posts/p1"}}})
+	require.NoError(t, err)
+	assert.NotContains(t, msg.Body, "expiring")
+	assert.NotContains(t, msg.HTML, "expiring")
+}
+
+func TestRenderMention(t *testing.T) {
+	msg, err := Render(TemplateMention, "bob@example.com", MentionData{
+		Name:        "Bob",
+		MentionedBy: "ada",
+		PostTitle:   "Release notes",
+		InComment:   true,
+		Link:        "https://example.com/posts/p1",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "ada mentioned you in a comment on Release notes", msg.Subject)
+	assert.Contains(t, msg.Body, "ada mentioned you in a comment on the post Release notes.")
+	assert.Contains(t, msg.Body, "https://example.com/posts/p1")
+	assert.Contains(t, msg.HTML, "Read post</a>")
+
+	msg, err = Render(TemplateMention, "bob@example.com", MentionData{Name: "Bob", MentionedBy: "ada", PostTitle: "Release notes", Link: "https://example.com/posts/p1"})
+	require.NoError(t, err)
+	assert.Equal(t, "ada mentioned you in Release notes", msg.Subject)
+	assert.Contains(t, msg.Body, "ada mentioned you in the post Release notes.")
+}
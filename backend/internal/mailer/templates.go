@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates of the emails the server sends. Each has a plain text version,
+// <name>.txt, which also defines the subject, and an HTML version,
+// <name>.html, rendered into layout.html.
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateInvitation        = "invitation"
+	TemplateShare             = "share"
+	TemplateDigest            = "digest"
+	TemplateMention           = "mention"
+)
+
+// PasswordResetData fills TemplatePasswordReset
+type PasswordResetData struct {
+	Name     string
+	Username string
+	Link     string
+	ValidFor string
+}
+
+// EmailVerificationData fills TemplateEmailVerification
+type EmailVerificationData struct {
+	Name     string
+	Username string
+	Email    string
+	Link     string
+	ValidFor string
+}
+
+// InvitationData fills TemplateInvitation
+type InvitationData struct {
+	InvitedBy string
+	Link      string
+	ExpiresAt string
+}
+
+// ShareData fills TemplateShare
+type ShareData struct {
+	SharedBy    string
+	FileName    string
+	Note        string
+	Link        string
+	ExpiresAt   string
+	HasPassword bool
+}
+
+// DigestData fills TemplateDigest
+type DigestData struct {
+	Name          string
+	Since         string
+	Posts         []DigestPost
+	MorePosts     int // posts left out of Posts
+	ExpiringFiles []DigestFile
+	FilesLink     string
+}
+
+type DigestPost struct {
+	Title  string
+	Author string
+	Link   string
+}
+
+type DigestFile struct {
+	Name      string
+	ExpiresAt string
+}
+
+// MentionData fills TemplateMention
+type MentionData struct {
+	Name        string
+	MentionedBy string
+	PostTitle   string
+	InComment   bool
+	Link        string
+}
+
+//go:embed templates
+var templateFiles embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = loadTemplates(TemplatePasswordReset, TemplateEmailVerification, TemplateInvitation, TemplateShare, TemplateDigest, TemplateMention)
+
+func loadTemplates(names ...string) map[string]emailTemplate {
+	funcs := htmltemplate.FuncMap{
+		"button": func(link, label string) map[string]string {
+			return map[string]string{"Link": link, "Label": label}
+		},
+	}
+
+	loaded := make(map[string]emailTemplate, len(names))
+	for _, name := range names {
+		loaded[name] = emailTemplate{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/"+name+".txt")),
+			html: htmltemplate.Must(htmltemplate.New("layout.html").Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html")),
+		}
+	}
+	return loaded
+}
+
+// Render fills the template name with data into an email to to
+func Render(name, to string, data any) (Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %s", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+
+	return Message{
+		To: to,
+		// Names and file names in the subject must not break the header
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,387 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/tracing"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Subjects of fire-and-forget messages
+const (
+	SubjectAccessLog = "files.access"
+	SubjectAuditLog  = "auth.audit"
+	SubjectAdminLog  = "admin.audit"
+)
+
+// JobStream is the work queue of background jobs, one subject per job type
+const (
+	JobStream        = "JOBS"
+	JobSubjectPrefix = "jobs."
+)
+
+// WebhookStream is the work queue of webhook deliveries
+const (
+	WebhookStream            = "WEBHOOKS"
+	SubjectWebhookDeliveries = "webhooks.deliveries"
+)
+
+// Upload progress stages
+const (
+	UploadStageReceiving = "receiving"
+	UploadStageStoring   = "storing"
+	UploadStageComplete  = "complete"
+	UploadStageFailed    = "failed"
+)
+
+// UploadProgress is published while an upload is received and stored
+type UploadProgress struct {
+	UploadID string `json:"uploadId"`
+	UserID   string `json:"userId"`
+	Stage    string `json:"stage"`
+	Bytes    int64  `json:"bytes"`
+	Total    int64  `json:"total"` // -1 when the size is unknown
+	FileID   string `json:"fileId,omitempty"`
+}
+
+// UploadProgressSubject is the subject progress events for one upload use
+func UploadProgressSubject(uploadID string) string {
+	return "files.uploads." + uploadID + ".progress"
+}
+
+// FileJob is the payload for jobs that operate on a single stored file
+type FileJob struct {
+	FileID string `json:"fileId"`
+}
+
+// WebhookJob is the payload of one webhook delivery
+type WebhookJob struct {
+	WebhookID  string `json:"webhookId"`
+	DeliveryID string `json:"deliveryId"`
+}
+
+// Client wraps the NATS connection shared by publishers and workers
+type Client struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	events bool // the event stream is set up
+}
+
+func NewClient(cfg config.NATSConfig) (*Client, error) {
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name("minio-storage-backend"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	// Streams are only used once set up, which fails without JetStream
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	return &Client{conn: conn, js: js}, nil
+}
+
+// Publish marshals payload as JSON and publishes it on subject. The trace
+// of ctx travels in the message headers, so the work of subscribers shows
+// up in it.
+func (c *Client) Publish(ctx context.Context, subject string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	// Messages outside a trace go without headers
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(msg.Header)))
+	}
+
+	if err := c.conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+
+	return nil
+}
+
+// SetupEventStream creates or updates the JetStream stream domain events
+// are published to, keeping events for retention
+func (c *Client) SetupEventStream(ctx context.Context, retention time.Duration) error {
+	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        events.Stream,
+		Description: "Domain events of users, posts and files",
+		Subjects:    []string{events.StreamSubjects},
+		Storage:     jetstream.FileStorage,
+		MaxAge:      retention,
+		Duplicates:  2 * time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up event stream: %w", err)
+	}
+
+	c.events = true
+	return nil
+}
+
+// SetupJobStream creates or updates the JetStream stream background jobs
+// are queued in. Each job is removed once acknowledged; jobs of a type no
+// server runs, such as previews while they are turned off, are dropped
+// after a week.
+func (c *Client) SetupJobStream(ctx context.Context) error {
+	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        JobStream,
+		Description: "Pending background jobs",
+		Subjects:    []string{JobSubjectPrefix + ">"},
+		Retention:   jetstream.WorkQueuePolicy,
+		Storage:     jetstream.FileStorage,
+		MaxAge:      7 * 24 * time.Hour,
+		Duplicates:  2 * time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up job stream: %w", err)
+	}
+	return nil
+}
+
+// SetupWebhookStream creates or updates the JetStream stream webhook
+// deliveries are queued in. Each delivery is removed once acknowledged.
+func (c *Client) SetupWebhookStream(ctx context.Context) error {
+	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        WebhookStream,
+		Description: "Pending webhook deliveries",
+		Subjects:    []string{SubjectWebhookDeliveries},
+		Retention:   jetstream.WorkQueuePolicy,
+		Storage:     jetstream.FileStorage,
+		Duplicates:  2 * time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up webhook stream: %w", err)
+	}
+	return nil
+}
+
+// PublishStream stores payload in the stream of subject and waits for
+// JetStream to acknowledge it. Messages with the same msgID within two
+// minutes are stored once.
+func (c *Client) PublishStream(ctx context.Context, subject, msgID string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(msg.Header)))
+	}
+
+	if _, err := c.js.PublishMsg(ctx, msg, jetstream.WithMsgID(msgID)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+	return nil
+}
+
+// PublishEvent stores event in the event stream and waits for JetStream
+// to acknowledge it. The event ID is the message ID, so publishing an
+// event again within two minutes stores it once.
+func (c *Client) PublishEvent(ctx context.Context, event *events.Event) error {
+	if !c.events {
+		return errors.New("event stream is not set up")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := nats.NewMsg(events.Subject(event.Type))
+	msg.Data = data
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(msg.Header)))
+	}
+
+	if _, err := c.js.PublishMsg(ctx, msg, jetstream.WithMsgID(event.ID)); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", event.Type, err)
+	}
+	return nil
+}
+
+// QueueSubscribe delivers each message on subject to one member of queue.
+// handler runs in a span that continues the trace of the publisher.
+func (c *Client) QueueSubscribe(subject, queue string, handler func(ctx context.Context, data []byte)) error {
+	_, err := c.conn.QueueSubscribe(subject, queue, func(msg *nats.Msg) {
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(http.Header(msg.Header)))
+		ctx, span := tracing.Tracer().Start(ctx, "nats.process "+subject,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("nats"),
+				semconv.MessagingDestinationName(subject),
+			),
+		)
+		defer span.End()
+
+		handler(ctx, msg.Data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	return nil
+}
+
+// RetryError asks Consume to deliver a message again after Delay
+type RetryError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Retry returns an error that has Consume deliver the message again after
+// delay
+func Retry(delay time.Duration, err error) error {
+	return &RetryError{Delay: delay, Err: err}
+}
+
+// consumerAckWait is how long a consumer waits for a message to be
+// handled before delivering it again. Consume reports progress while the
+// handler runs, so only messages of servers that stopped are redelivered.
+const consumerAckWait = time.Minute
+
+// Consume delivers the messages of stream on subject to handler through
+// the durable consumer named consumer, which servers share, so each
+// message is handled once even when a server stops midway. A new consumer
+// starts with the oldest message the stream keeps. handler gets the number of the
+// delivery, starting at 1. The message is acknowledged when handler
+// returns nil, delivered again after a RetryError's delay, and dropped
+// after any other error. handler runs in a span that continues the trace
+// of the publisher.
+func (c *Client) Consume(ctx context.Context, stream, consumer, subject string, handler func(ctx context.Context, data []byte, attempt int) error) error {
+	cons, err := c.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       consumer,
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       consumerAckWait,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		MaxDeliver:    -1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", consumer, err)
+	}
+
+	_, err = cons.Consume(func(msg jetstream.Msg) {
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(http.Header(msg.Headers())))
+		ctx, span := tracing.Tracer().Start(ctx, "nats.process "+msg.Subject(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("nats"),
+				semconv.MessagingDestinationName(msg.Subject()),
+			),
+		)
+		defer span.End()
+
+		attempt := 1
+		if meta, err := msg.Metadata(); err == nil {
+			attempt = int(meta.NumDelivered)
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(consumerAckWait / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					msg.InProgress()
+				}
+			}
+		}()
+
+		var retry *RetryError
+		switch err := handler(ctx, msg.Data(), attempt); {
+		case err == nil:
+			msg.Ack()
+		case errors.As(err, &retry):
+			msg.NakWithDelay(retry.Delay)
+		default:
+			msg.Term()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", consumer, err)
+	}
+	return nil
+}
+
+// ConsumerState is how many messages a consumer has yet to handle
+type ConsumerState struct {
+	Pending  uint64 // not delivered yet
+	InFlight uint64 // delivered and not acknowledged, including those waiting to be retried
+}
+
+// ConsumerState returns the state of the durable consumer named consumer
+// of stream
+func (c *Client) ConsumerState(ctx context.Context, stream, consumer string) (*ConsumerState, error) {
+	cons, err := c.js.Consumer(ctx, stream, consumer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer %s: %w", consumer, err)
+	}
+	info, err := cons.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer %s: %w", consumer, err)
+	}
+	return &ConsumerState{Pending: info.NumPending, InFlight: uint64(info.NumAckPending)}, nil
+}
+
+// Subscription is an active subscription that can be cancelled
+type Subscription struct {
+	sub *nats.Subscription
+}
+
+func (s *Subscription) Unsubscribe() error {
+	return s.sub.Unsubscribe()
+}
+
+// Subscribe delivers every message on subject to handler. Unlike
+// QueueSubscribe, each subscriber receives its own copy.
+func (c *Client) Subscribe(subject string, handler func(data []byte)) (*Subscription, error) {
+	sub, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	return &Subscription{sub: sub}, nil
+}
+
+func (c *Client) Close() {
+	c.conn.Drain()
+}
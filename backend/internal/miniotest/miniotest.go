@@ -0,0 +1,371 @@
+// Package miniotest serves an in-memory subset of the S3 API, enough for
+// the storage service to run against in tests: buckets, object reads,
+// writes, copies, deletes and listings. Signatures are checked only as far
+// as needed to decode streamed payloads.
+package miniotest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/sigv4"
+)
+
+// Credentials the server accepts
+const (
+	AccessKey = "miniotest"
+	SecretKey = "miniotest-secret"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+	etag        string
+	metadata    http.Header // x-amz-meta-* headers
+	modified    time.Time
+}
+
+// Server is an in-memory S3 endpoint
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*object
+	failPut func(bucket, key string) bool
+}
+
+// New starts a server that is closed when the test ends
+func New(t testing.TB) *Server {
+	s := &Server{buckets: make(map[string]map[string]*object)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Endpoint is the host and port to point a MinIO client at
+func (s *Server) Endpoint() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// Keys returns the keys stored in bucket under prefix, sorted
+func (s *Server) Keys(bucket, prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// FailPuts makes writes and copies to the objects fail answers true for,
+// denied so that clients do not retry them; nil lets every write succeed
+// again
+func (s *Server) FailPuts(fail func(bucket, key string) bool) {
+	s.mu.Lock()
+	s.failPut = fail
+	s.mu.Unlock()
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			if s.buckets[bucket] == nil {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut:
+			if s.buckets[bucket] == nil {
+				s.buckets[bucket] = make(map[string]*object)
+			}
+		case r.Method == http.MethodGet && query.Has("location"):
+			writeXML(w, http.StatusOK, struct {
+				XMLName xml.Name `xml:"LocationConstraint"`
+				Region  string   `xml:",chardata"`
+			}{Region: "us-east-1"})
+		case r.Method == http.MethodGet:
+			s.list(w, bucket, query)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			s.deleteMany(w, r, bucket)
+		default:
+			writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" "+r.URL.String())
+		}
+		return
+	}
+
+	objects := s.buckets[bucket]
+	if objects == nil {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		obj := objects[key]
+		if obj == nil {
+			writeError(w, http.StatusNotFound, "NoSuchKey", key)
+			return
+		}
+		s.get(w, r, obj)
+	case http.MethodPut:
+		if s.failPut != nil && s.failPut(bucket, key) {
+			writeError(w, http.StatusForbidden, "AccessDenied", "write failed")
+			return
+		}
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			s.copy(w, r, bucket, key, source)
+			return
+		}
+		s.put(w, r, bucket, key)
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" "+r.URL.String())
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, obj *object) {
+	for name, values := range obj.metadata {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", obj.contentType)
+	w.Header().Set("ETag", `"`+obj.etag+`"`)
+	w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	data, status := obj.data, http.StatusOK
+	if spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+		first, last, _ := strings.Cut(spec, "-")
+		start, _ := strconv.Atoi(first)
+		end := len(data) - 1
+		if last != "" {
+			end, _ = strconv.Atoi(last)
+		}
+		end = min(end, len(data)-1)
+		if start > end {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", spec)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data, status = data[start:end+1], http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	body := io.Reader(r.Body)
+	if sig, err := sigv4.Parse(r); err == nil {
+		if body, err = sig.Body(r, SecretKey); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+			return
+		}
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	obj := newObject(data, r.Header.Get("Content-Type"), userMetadata(r.Header))
+	s.buckets[bucket][key] = obj
+	w.Header().Set("ETag", `"`+obj.etag+`"`)
+}
+
+func (s *Server) copy(w http.ResponseWriter, r *http.Request, bucket, key, source string) {
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", source)
+		return
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(source, "/")
+	src := s.buckets[sourceBucket][sourceKey]
+	if src == nil {
+		writeError(w, http.StatusNotFound, "NoSuchKey", source)
+		return
+	}
+
+	contentType, metadata := src.contentType, src.metadata
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		contentType, metadata = r.Header.Get("Content-Type"), userMetadata(r.Header)
+	}
+	obj := newObject(src.data, contentType, metadata)
+	s.buckets[bucket][key] = obj
+
+	w.Header().Set("ETag", `"`+obj.etag+`"`)
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: `"` + obj.etag + `"`, LastModified: obj.modified.UTC().Format("2006-01-02T15:04:05.000Z")})
+}
+
+type listEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+	StorageClass string
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+func (s *Server) list(w http.ResponseWriter, bucket string, query url.Values) {
+	objects := s.buckets[bucket]
+	if objects == nil {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	after := max(query.Get("start-after"), query.Get("continuation-token"))
+	maxKeys := 1000
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n > 0 {
+		maxKeys = n
+	}
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []listEntry
+		CommonPrefixes        []listPrefix
+	}{Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+
+	last := ""
+	for _, key := range keys {
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if n := len(result.CommonPrefixes); n == 0 || result.CommonPrefixes[n-1].Prefix != common {
+					result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: common})
+					result.KeyCount++
+				}
+				// Resume after every key under the prefix
+				last = common + "\U0010FFFF"
+				continue
+			}
+		}
+		obj := objects[key]
+		result.Contents = append(result.Contents, listEntry{
+			Key:          key,
+			LastModified: obj.modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"` + obj.etag + `"`,
+			Size:         len(obj.data),
+			StorageClass: "STANDARD",
+		})
+		result.KeyCount++
+		last = key
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *Server) deleteMany(w http.ResponseWriter, r *http.Request, bucket string) {
+	var req struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	type deleted struct {
+		Key string
+	}
+	result := struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		Deleted []deleted `xml:"Deleted"`
+	}{}
+	for _, obj := range req.Objects {
+		delete(s.buckets[bucket], obj.Key)
+		result.Deleted = append(result.Deleted, deleted{Key: obj.Key})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func newObject(data []byte, contentType string, metadata http.Header) *object {
+	sum := md5.Sum(data)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &object{
+		data:        bytes.Clone(data),
+		contentType: contentType,
+		etag:        hex.EncodeToString(sum[:]),
+		metadata:    metadata,
+		modified:    time.Now(),
+	}
+}
+
+func userMetadata(header http.Header) http.Header {
+	metadata := make(http.Header)
+	for name, values := range header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			metadata[name] = values
+		}
+	}
+	return metadata
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeXML(w, status, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: message})
+}
@@ -1,6 +1,14 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,14 +17,309 @@ type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"-"` // Never include password in JSON responses
+	Password  string    `json:"password,omitempty"` // password hash; responses use UserResponse, which omits it
 	FirstName string    `json:"firstName"`
 	LastName  string    `json:"lastName"`
 	Role      string    `json:"role"`
+	Status    string    `json:"status,omitempty"` // active, suspended, deactivated; empty means active
 	Avatar    string    `json:"avatar,omitempty"`
+	Bio       string    `json:"bio,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	ETag      string    `json:"etag,omitempty"`
+
+	// Privacy controls the public profile
+	Privacy ProfilePrivacy `json:"privacy"`
+
+	// OAuthIdentities links social login accounts, provider -> account ID
+	OAuthIdentities map[string]string `json:"oauthIdentities,omitempty"`
+
+	// ExternalID is the identity provider's ID of a user provisioned
+	// through SCIM
+	ExternalID string `json:"externalId,omitempty"`
+
+	// DirectoryDN is the LDAP entry of a user who signs in with directory
+	// credentials
+	DirectoryDN string `json:"directoryDn,omitempty"`
+
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	LastLoginIP string     `json:"lastLoginIp,omitempty"`
+
+	// EmailVerifiedAt is when the user confirmed their email address
+	// through a verification link, an invite link or a social login
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+}
+
+// ProfilePrivacy controls what GET /public/users/:username shows of a
+// user. Profiles are private until their user makes them public.
+type ProfilePrivacy struct {
+	Public    bool `json:"public"`    // the profile can be read without signing in
+	ShowName  bool `json:"showName"`  // first and last name
+	ShowPosts bool `json:"showPosts"` // published posts that are not shared with an organization
+}
+
+// PublicProfile is what everyone sees of a user with a public profile
+type PublicProfile struct {
+	Username  string        `json:"username"`
+	FirstName string        `json:"firstName,omitempty"`
+	LastName  string        `json:"lastName,omitempty"`
+	Avatar    string        `json:"avatar,omitempty"`
+	Bio       string        `json:"bio,omitempty"`
+	JoinedAt  time.Time     `json:"joinedAt"`
+	Posts     []*PublicPost `json:"posts,omitempty"` // newest first
+}
+
+// PublicPost is a post as listed on a public profile
+type PublicPost struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Summary     string     `json:"summary"`
+	Tags        []string   `json:"tags"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+}
+
+// PublicProfile returns what user shows of themselves publicly, with the
+// posts they show when their privacy allows. It is nil unless the profile
+// is public and the account active.
+func (u *User) PublicProfile(posts []*Post) *PublicProfile {
+	if !u.Privacy.Public || u.AccountStatus() != UserStatusActive {
+		return nil
+	}
+
+	profile := &PublicProfile{
+		Username: u.Username,
+		Avatar:   u.Avatar,
+		Bio:      u.Bio,
+		JoinedAt: u.CreatedAt,
+	}
+	if u.Privacy.ShowName {
+		profile.FirstName = u.FirstName
+		profile.LastName = u.LastName
+	}
+	if !u.Privacy.ShowPosts {
+		return profile
+	}
+	for _, post := range posts {
+		if post.UserID != u.ID || post.Status != PostStatusPublished || post.OrgID != "" {
+			continue
+		}
+		profile.Posts = append(profile.Posts, &PublicPost{
+			ID:          post.ID,
+			Title:       post.Title,
+			Summary:     post.Summary,
+			Tags:        post.Tags,
+			PublishedAt: post.PublishedAt,
+		})
+	}
+	return profile
+}
+
+// Account states. Suspended and deactivated users keep their data but
+// cannot sign in or use existing tokens.
+const (
+	UserStatusActive      = "active"
+	UserStatusSuspended   = "suspended"
+	UserStatusDeactivated = "deactivated"
+)
+
+// AccountStatus returns the user's status, treating accounts created
+// before statuses existed as active
+func (u *User) AccountStatus() string {
+	if u.Status == "" {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
+// Permissions granted by roles. The admin variants allow acting on other
+// users' resources.
+const (
+	PermPostsRead        = "posts:read"
+	PermPostsWrite       = "posts:write"
+	PermPostsAdmin       = "posts:admin"
+	PermFilesRead        = "files:read"
+	PermFilesWrite       = "files:write"
+	PermFilesAdmin       = "files:admin"
+	PermUsersRead        = "users:read"
+	PermUsersAdmin       = "users:admin"
+	PermUsersImpersonate = "users:impersonate"
+	PermRolesAdmin       = "roles:admin"
+	PermAuditRead        = "audit:read"
+
+	PermServiceAccountsAdmin = "serviceaccounts:admin"
+	PermSystemAdmin          = "system:admin" // server-wide settings such as maintenance mode
+	PermWebhooksAdmin        = "webhooks:admin"
+
+	// PermAll grants every permission; "files:*" grants every files permission
+	PermAll = "*"
+)
+
+var Permissions = []string{
+	PermPostsRead, PermPostsWrite, PermPostsAdmin,
+	PermFilesRead, PermFilesWrite, PermFilesAdmin,
+	PermUsersRead, PermUsersAdmin, PermUsersImpersonate,
+	PermRolesAdmin,
+	PermAuditRead,
+	PermServiceAccountsAdmin,
+	PermSystemAdmin,
+	PermWebhooksAdmin,
+}
+
+// Built-in roles always exist. Their permissions can be changed, except
+// that admin always keeps every permission.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// DefaultRoles are the built-in roles before an admin changes them
+var DefaultRoles = map[string]*Role{
+	RoleAdmin: {
+		Name:        RoleAdmin,
+		Description: "Full access",
+		Permissions: []string{PermAll},
+		BuiltIn:     true,
+	},
+	RoleUser: {
+		Name:        RoleUser,
+		Description: "Manages own posts and files",
+		Permissions: []string{PermPostsRead, PermPostsWrite, PermFilesRead, PermFilesWrite, PermUsersRead},
+		BuiltIn:     true,
+	},
+}
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Permissions []string  `json:"permissions"`
+	BuiltIn     bool      `json:"builtIn"`
+	CreatedAt   time.Time `json:"createdAt,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+}
+
+// Has reports whether the role grants permission
+func (r *Role) Has(permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, granted := range r.Permissions {
+		if granted == PermAll || granted == permission || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission reports whether permission can be granted by a role
+func ValidPermission(permission string) bool {
+	if permission == PermAll || slices.Contains(Permissions, permission) {
+		return true
+	}
+	resource, ok := strings.CutSuffix(permission, ":*")
+	return ok && slices.ContainsFunc(Permissions, func(p string) bool {
+		return strings.HasPrefix(p, resource+":")
+	})
+}
+
+// Invitation lets someone register with a given role, and is the only way
+// to register when registration is invite-only
+type Invitation struct {
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  string     `json:"invitedBy"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	AcceptedBy string     `json:"acceptedBy,omitempty"` // ID of the registered user
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Usable reports whether the invitation can still be accepted at now
+func (i *Invitation) Usable(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// ServiceAccountKeyPrefix starts every service account key, which tells
+// keys apart from JWTs in the Authorization header
+const ServiceAccountKeyPrefix = "sa_"
+
+// ServiceAccount is a machine identity for backend integrations. Instead
+// of a role it holds its own permissions, and it authenticates with
+// non-expiring keys rather than a person's tokens.
+type ServiceAccount struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Permissions []string             `json:"permissions"`
+	Disabled    bool                 `json:"disabled"`
+	Keys        []*ServiceAccountKey `json:"keys"`
+	CreatedBy   string               `json:"createdBy"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	UpdatedAt   time.Time            `json:"updatedAt"`
+
+	// Usage is recorded at most once a minute per server, so the count
+	// and times are approximate
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
+	UsageCount int64      `json:"usageCount"`
+}
+
+// ServiceAccountKey is one credential of a service account. Only a hash
+// of the key is stored; rotated keys stay valid until ExpiresAt.
+type ServiceAccountKey struct {
+	ID         string     `json:"id"`
+	Hash       string     `json:"hash,omitempty"`
+	Hint       string     `json:"hint"` // last characters of the key
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Role returns a role holding the account's permissions
+func (a *ServiceAccount) Role() *Role {
+	return &Role{Name: "service-account", Permissions: a.Permissions}
+}
+
+// ActiveKeys returns the keys not expired at now
+func (a *ServiceAccount) ActiveKeys(now time.Time) []*ServiceAccountKey {
+	var keys []*ServiceAccountKey
+	for _, key := range a.Keys {
+		if key.ExpiresAt == nil || now.Before(*key.ExpiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ForResponse returns a copy of the account without key hashes
+func (a *ServiceAccount) ForResponse() *ServiceAccount {
+	account := *a
+	account.Keys = make([]*ServiceAccountKey, len(a.Keys))
+	for i, key := range a.Keys {
+		keyCopy := *key
+		keyCopy.Hash = ""
+		account.Keys[i] = &keyCopy
+	}
+	return &account
+}
+
+// DeletedUserID is the author of posts and comments kept after their user was erased
+// with ErasureAnonymize
+const DeletedUserID = "deleted-user"
+
+// Erasure modes
+const (
+	ErasurePurge     = "purge"     // delete everything the user created
+	ErasureAnonymize = "anonymize" // keep posts and comments, attributed to DeletedUserID
+)
+
+// ErasureResult summarizes what erasing a user removed or kept
+type ErasureResult struct {
+	Mode               string `json:"mode"`
+	PostsDeleted       int    `json:"postsDeleted"`
+	PostsAnonymized    int    `json:"postsAnonymized"`
+	CommentsDeleted    int    `json:"commentsDeleted"`
+	CommentsAnonymized int    `json:"commentsAnonymized"`
+	FilesDeleted       int    `json:"filesDeleted"`
 }
 
 // Post represents a user post
@@ -27,10 +330,95 @@ type Post struct {
 	Content   string    `json:"content"`
 	Summary   string    `json:"summary"`
 	Tags      []string  `json:"tags"`
-	Status    string    `json:"status"` // draft, published, archived
+	Status    string    `json:"status"`          // draft, published, archived, pending, rejected
+	OrgID     string    `json:"orgId,omitempty"` // organization the post is shared with
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
-	ETag      string    `json:"etag,omitempty"`
+
+	// PublishedAt is when the post was first published
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+
+	// BookmarkCount is how many users bookmarked the post, set when it
+	// is read
+	BookmarkCount int64 `json:"bookmarkCount"`
+
+	// Series places the post in its series, set when it is read alone
+	Series *SeriesNavigation `json:"series,omitempty"`
+
+	ETag string `json:"etag,omitempty"`
+}
+
+// Post states. Pending posts were published but are held for review, and
+// rejected ones were turned down by a moderator.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+	PostStatusPending   = "pending"
+	PostStatusRejected  = "rejected"
+)
+
+// Withheld reports whether moderation keeps the post from everyone but its
+// author and post admins
+func (p *Post) Withheld() bool {
+	return p.Status == PostStatusPending || p.Status == PostStatusRejected
+}
+
+// CreatePostRequest for writing a post. The author, ID and timestamps are
+// set by the server.
+type CreatePostRequest struct {
+	Title   string   `json:"title" binding:"required,max=200"`
+	Content string   `json:"content" binding:"required,max=100000"`
+	Summary string   `json:"summary" binding:"max=500"`
+	Tags    []string `json:"tags" binding:"max=20,dive,min=1,max=50"`
+	Status  string   `json:"status" binding:"omitempty,oneof=draft published archived"` // draft if omitted
+	OrgID   string   `json:"orgId" binding:"omitempty,max=64"`                          // organization to share the post with
+}
+
+// Post returns the post the request describes, written by userID
+func (r *CreatePostRequest) Post(userID string) *Post {
+	status := r.Status
+	if status == "" {
+		status = PostStatusDraft
+	}
+	return &Post{
+		UserID:  userID,
+		Title:   r.Title,
+		Content: r.Content,
+		Summary: r.Summary,
+		Tags:    r.Tags,
+		Status:  status,
+		OrgID:   r.OrgID,
+	}
+}
+
+// UpdatePostRequest edits a post. Omitted fields are unchanged; an empty
+// tag list removes all tags.
+type UpdatePostRequest struct {
+	Title   *string  `json:"title" binding:"omitempty,min=1,max=200"`
+	Content *string  `json:"content" binding:"omitempty,min=1,max=100000"`
+	Summary *string  `json:"summary" binding:"omitempty,max=500"`
+	Tags    []string `json:"tags" binding:"omitempty,max=20,dive,min=1,max=50"`
+	Status  *string  `json:"status" binding:"omitempty,oneof=draft published archived"`
+}
+
+// Apply copies the fields set in the request to post
+func (r *UpdatePostRequest) Apply(post *Post) {
+	if r.Title != nil {
+		post.Title = *r.Title
+	}
+	if r.Content != nil {
+		post.Content = *r.Content
+	}
+	if r.Summary != nil {
+		post.Summary = *r.Summary
+	}
+	if r.Tags != nil {
+		post.Tags = r.Tags
+	}
+	if r.Status != nil {
+		post.Status = *r.Status
+	}
 }
 
 // File represents an uploaded file
@@ -42,12 +430,748 @@ type File struct {
 	ContentType  string            `json:"contentType"`
 	Size         int64             `json:"size"`
 	Path         string            `json:"path"`
+	Folder       string            `json:"folder,omitempty"` // virtual folder, e.g. /projects/2024
+	Description  string            `json:"description,omitempty"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"` // size name -> object path
+	Preview      string            `json:"preview,omitempty"`    // object path of the first page preview
+	Stream       *VideoStream      `json:"stream,omitempty"`     // HLS renditions of a video
+	Visibility   string            `json:"visibility"`           // private, public
+	OrgID        string            `json:"orgId,omitempty"`      // organization the file is shared with
+	ScanStatus   string            `json:"scanStatus,omitempty"` // pending, clean, infected; empty when scanning is disabled
+	ScanResult   string            `json:"scanResult,omitempty"` // detected signature for infected files
+	Version      int               `json:"version"`
+	Versions     []FileVersion     `json:"versions,omitempty"`   // previous versions, oldest first
+	ExpiresAt    *time.Time        `json:"expiresAt,omitempty"`  // deleted automatically after this time
+	Encryption   *FileEncryption   `json:"encryption,omitempty"` // set when the client encrypted the content
 	CreatedAt    time.Time         `json:"createdAt"`
 	UpdatedAt    time.Time         `json:"updatedAt"`
 	ETag         string            `json:"etag,omitempty"`
 }
 
+// Video stream statuses
+const (
+	StreamStatusProcessing = "processing"
+	StreamStatusReady      = "ready"
+	StreamStatusFailed     = "failed"
+)
+
+// VideoStream describes the HLS renditions transcoded from a video
+type VideoStream struct {
+	Status     string     `json:"status"`
+	Renditions []string   `json:"renditions,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"` // of the transcode while processing
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// Stalled reports whether the stream is still processing after timeout,
+// the longest a transcode runs, so that the server running it stopped
+// before recording the result. Such streams have failed.
+func (s *VideoStream) Stalled(timeout time.Duration) bool {
+	if s.Status != StreamStatusProcessing {
+		return false
+	}
+	// Streams from before StartedAt was recorded were updated when it started
+	started := s.UpdatedAt
+	if s.StartedAt != nil {
+		started = *s.StartedAt
+	}
+	return time.Since(started) > timeout
+}
+
+// FileVersion is an archived revision of a file's content
+type FileVersion struct {
+	Version     int             `json:"version"`
+	Path        string          `json:"path"`
+	ContentType string          `json:"contentType"`
+	Size        int64           `json:"size"`
+	ETag        string          `json:"etag,omitempty"`
+	Encryption  *FileEncryption `json:"encryption,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// FindVersion returns the archived version v, or nil if it does not exist
+func (f *File) FindVersion(v int) *FileVersion {
+	for i := range f.Versions {
+		if f.Versions[i].Version == v {
+			return &f.Versions[i]
+		}
+	}
+	return nil
+}
+
+// Client-side encryption algorithms accepted for uploads
+var EncryptionAlgorithms = []string{"AES-256-GCM", "AES-256-CTR", "XChaCha20-Poly1305"}
+
+// EncryptedContentType is the type client-encrypted content is stored and
+// served as. Ciphertext is opaque bytes whatever the client says it holds.
+const EncryptedContentType = "application/octet-stream"
+
+// FileEncryption describes content the client encrypted before uploading.
+// The server stores and returns it unchanged; the key itself never reaches
+// the server, so the content cannot be inspected.
+type FileEncryption struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId"` // ID of the wrapped content key held by the client
+	IV        string `json:"iv"`    // base64 initialisation vector or nonce
+}
+
+// Validate checks that the encryption parameters are well formed
+func (e *FileEncryption) Validate() error {
+	if !slices.Contains(EncryptionAlgorithms, e.Algorithm) {
+		return fmt.Errorf("encryption algorithm must be one of %s", strings.Join(EncryptionAlgorithms, ", "))
+	}
+	if e.KeyID == "" || len(e.KeyID) > 256 {
+		return errors.New("encryption keyId must be 1 to 256 characters")
+	}
+	iv, err := base64.StdEncoding.DecodeString(e.IV)
+	if err != nil || len(iv) < 8 || len(iv) > 64 {
+		return errors.New("encryption iv must be 8 to 64 base64 encoded bytes")
+	}
+	return nil
+}
+
+// IsEncrypted reports whether the content is client-encrypted, in which
+// case it cannot be sniffed, stripped, thumbnailed or transcoded
+func (f *File) IsEncrypted() bool {
+	return f.Encryption != nil
+}
+
+// File visibility values
+const (
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
+)
+
+// Virus scan states
+const (
+	ScanStatusPending  = "pending"
+	ScanStatusClean    = "clean"
+	ScanStatusInfected = "infected"
+)
+
+// IsDownloadable reports whether the content passed (or never needed) a scan
+func (f *File) IsDownloadable() bool {
+	return f.ScanStatus == "" || f.ScanStatus == ScanStatusClean
+}
+
+// IsExpired reports whether the file's expiry has passed at now
+func (f *File) IsExpired(now time.Time) bool {
+	return f.ExpiresAt != nil && !now.Before(*f.ExpiresAt)
+}
+
+// IsPublic reports whether the file may be downloaded without authentication.
+// Files stored before visibility existed are private.
+func (f *File) IsPublic() bool {
+	return f.Visibility == VisibilityPublic
+}
+
+// ExifMetadataPrefix marks metadata keys holding EXIF tags stripped from an
+// uploaded image. They can reveal location and are shown to the owner only.
+const ExifMetadataPrefix = "exif."
+
+// ForViewer returns the file as viewerID may see it: owner-only metadata is
+// removed for anyone but the owner. The receiver is not modified.
+func (f *File) ForViewer(viewerID string) *File {
+	if viewerID == f.UserID {
+		return f
+	}
+
+	visible := *f
+	visible.Metadata = make(map[string]string, len(f.Metadata))
+	for key, value := range f.Metadata {
+		if !strings.HasPrefix(key, ExifMetadataPrefix) {
+			visible.Metadata[key] = value
+		}
+	}
+	return &visible
+}
+
+// UpdateFileRequest edits a file's name, folder, description and custom
+// metadata. Omitted fields are unchanged; metadata keys set to null are removed.
+type UpdateFileRequest struct {
+	OriginalName *string            `json:"originalName" binding:"omitempty,min=1,max=255"`
+	Folder       *string            `json:"folder" binding:"omitempty,max=1024"`
+	Description  *string            `json:"description" binding:"omitempty,max=2000"`
+	Metadata     map[string]*string `json:"metadata" binding:"omitempty,dive,keys,min=1,max=128,printascii,endkeys,omitempty,max=1024"`
+}
+
+// CopyFileRequest for duplicating a file, optionally under a new name or folder
+type CopyFileRequest struct {
+	OriginalName string  `json:"originalName" binding:"omitempty,max=255"`
+	Folder       *string `json:"folder" binding:"omitempty,max=1024"`
+}
+
+// ZipRequest lists the files to bundle into a ZIP download
+type ZipRequest struct {
+	FileIDs []string `json:"fileIds" binding:"required,min=1,max=500,dive,required"`
+	Name    string   `json:"name" binding:"omitempty,max=255"`
+}
+
+// UpdateVisibilityRequest for changing a file's visibility
+type UpdateVisibilityRequest struct {
+	Visibility string `json:"visibility" binding:"required,oneof=private public"`
+}
+
+// BatchUploadEntry describes one part of a batch upload in its manifest
+type BatchUploadEntry struct {
+	Part       string          `json:"part"` // form field holding the file
+	Path       string          `json:"path"` // relative path, e.g. "photos/2024/beach.jpg"
+	Encryption *FileEncryption `json:"encryption,omitempty"`
+}
+
+// BatchUploadResult reports the outcome for one file of a batch upload
+type BatchUploadResult struct {
+	Path    string `json:"path"`
+	Status  int    `json:"status"`
+	Version bool   `json:"version,omitempty"` // stored as a new version of an existing file
+	File    *File  `json:"file,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// ErrorCode is the code of the error, as in ErrorResponse
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// PresignUploadRequest asks for a browser-direct upload policy
+type PresignUploadRequest struct {
+	FileName    string `json:"fileName" binding:"required"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// PresignedUpload holds the form fields the browser posts straight to MinIO
+type PresignedUpload struct {
+	FileID    string            `json:"fileId"`
+	URL       string            `json:"url"`
+	FormData  map[string]string `json:"formData"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// FinalizeUploadRequest records metadata for a completed direct upload
+type FinalizeUploadRequest struct {
+	FileID       string            `json:"fileId" binding:"required,uuid"`
+	OriginalName string            `json:"originalName" binding:"required,max=255"`
+	Folder       string            `json:"folder" binding:"omitempty,max=1024"`
+	Visibility   string            `json:"visibility" binding:"omitempty,oneof=private public"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	// StripMetadata overrides the server default for removing image EXIF data
+	StripMetadata *bool `json:"stripMetadata,omitempty"`
+	// ExpiresAt schedules the file for automatic deletion
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Encryption marks the upload as encrypted by the client
+	Encryption *FileEncryption `json:"encryption,omitempty"`
+}
+
+// Types of metadata schema fields
+const (
+	MetadataString  = "string"
+	MetadataInteger = "integer"
+	MetadataNumber  = "number"
+	MetadataBoolean = "boolean"
+	MetadataDate    = "date" // such as 2024-05-01
+)
+
+// How a metadata schema treats values that do not match it
+const (
+	MetadataModeReject = "reject" // the upload fails
+	MetadataModeCoerce = "coerce" // values are normalized where possible and unknown keys dropped
+)
+
+// MetadataField describes one custom metadata key of a schema
+type MetadataField struct {
+	Type      string   `json:"type" binding:"required,oneof=string integer number boolean date"`
+	Required  bool     `json:"required,omitempty"`
+	Enum      []string `json:"enum,omitempty" binding:"omitempty,dive,required"` // the allowed values
+	MaxLength int      `json:"maxLength,omitempty" binding:"min=0"`              // of string values; 0 means any
+}
+
+// MetadataSchema is what the custom metadata of files in a folder, and the
+// folders below it, must look like. The schema of the deepest folder applies;
+// one for folder "" applies to every file without a more specific schema.
+type MetadataSchema struct {
+	Name         string                   `json:"name"`
+	Description  string                   `json:"description,omitempty"`
+	Folder       string                   `json:"folder"`
+	Fields       map[string]MetadataField `json:"fields"`
+	AllowUnknown bool                     `json:"allowUnknown"` // keys without a field are kept as they are
+	Mode         string                   `json:"mode"`
+	CreatedBy    string                   `json:"createdBy"`
+	CreatedAt    time.Time                `json:"createdAt"`
+	UpdatedAt    time.Time                `json:"updatedAt"`
+}
+
+// Covers reports whether the schema is for folder or a folder above it
+func (s *MetadataSchema) Covers(folder string) bool {
+	return s.Folder == "" || folder == s.Folder || strings.HasPrefix(folder, s.Folder+"/")
+}
+
+// Validate checks that enum values and limits fit the type of their field
+func (s *MetadataSchema) Validate() error {
+	for name, field := range s.Fields {
+		if strings.HasPrefix(name, ExifMetadataPrefix) {
+			return fmt.Errorf("metadata keys starting with %s are reserved", ExifMetadataPrefix)
+		}
+		if field.MaxLength > 0 && field.Type != MetadataString {
+			return fmt.Errorf("field %s: maxLength only applies to strings", name)
+		}
+		for _, value := range field.Enum {
+			if normalized, ok := field.normalize(value, false); !ok || normalized != value {
+				return fmt.Errorf("field %s: enum value %q is not %s", name, value, metadataTypeNames[field.Type])
+			}
+		}
+	}
+	return nil
+}
+
+// Apply checks metadata against the schema and returns it as it should be
+// stored, or the fields that broke it. In coerce mode values are trimmed
+// and brought into their canonical form, such as "Yes" into "true", and
+// unknown keys are dropped. Empty values count as missing. EXIF tags kept
+// by the server are not checked.
+func (s *MetadataSchema) Apply(metadata map[string]string) (map[string]string, []FieldError) {
+	coerce := s.Mode == MetadataModeCoerce
+	result := make(map[string]string, len(metadata))
+	var errs []FieldError
+	for key, value := range metadata {
+		field, known := s.Fields[key]
+		switch {
+		case strings.HasPrefix(key, ExifMetadataPrefix), !known && s.AllowUnknown:
+			result[key] = value
+		case !known && coerce:
+		case !known:
+			errs = append(errs, FieldError{
+				Name:    "metadata." + key,
+				Rule:    "unknown",
+				Message: "metadata." + key + " is not a field of the " + s.Name + " metadata schema",
+				Key:     "unknown_field",
+				Args:    map[string]string{"param": s.Name},
+			})
+		default:
+			if coerce {
+				value = strings.TrimSpace(value)
+			}
+			if value == "" {
+				continue
+			}
+			normalized, fieldErr := field.check(value, coerce)
+			if fieldErr != nil {
+				fieldErr.Name = "metadata." + key
+				fieldErr.Message = fieldErr.Name + " " + fieldErr.Message
+				errs = append(errs, *fieldErr)
+				continue
+			}
+			result[key] = normalized
+		}
+	}
+
+	for key, field := range s.Fields {
+		if _, ok := result[key]; field.Required && !ok && !slices.ContainsFunc(errs, func(e FieldError) bool { return e.Name == "metadata."+key }) {
+			errs = append(errs, FieldError{Name: "metadata." + key, Rule: "required", Message: "metadata." + key + " is required", Key: "required"})
+		}
+	}
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b FieldError) int { return strings.Compare(a.Name, b.Name) })
+		return nil, errs
+	}
+	return result, nil
+}
+
+// metadataTypeNames describe values of the metadata field types
+var metadataTypeNames = map[string]string{
+	MetadataString:  "a string",
+	MetadataInteger: "an integer",
+	MetadataNumber:  "a number",
+	MetadataBoolean: "true or false",
+	MetadataDate:    "a date such as 2024-05-01",
+}
+
+// metadataTypeKeys name the metadata field types for translation
+var metadataTypeKeys = map[string]string{
+	MetadataString:  "string",
+	MetadataInteger: "integer",
+	MetadataNumber:  "number",
+	MetadataBoolean: "bool",
+	MetadataDate:    "date",
+}
+
+// check returns the value as stored, or the rule it breaks with a message
+// lacking the field name
+func (f MetadataField) check(value string, coerce bool) (string, *FieldError) {
+	normalized, ok := f.normalize(value, coerce)
+	if !ok {
+		return "", &FieldError{
+			Rule:    "type",
+			Message: "must be " + metadataTypeNames[f.Type],
+			Key:     "type",
+			Args:    map[string]string{"type": metadataTypeKeys[f.Type]},
+		}
+	}
+	if len(f.Enum) > 0 {
+		index := slices.Index(f.Enum, normalized)
+		if index < 0 && coerce {
+			index = slices.IndexFunc(f.Enum, func(allowed string) bool { return strings.EqualFold(allowed, normalized) })
+		}
+		if index < 0 {
+			allowed := strings.Join(f.Enum, ", ")
+			return "", &FieldError{Rule: "oneof", Message: "must be one of " + allowed, Key: "oneof", Args: map[string]string{"param": allowed}}
+		}
+		normalized = f.Enum[index]
+	}
+	if f.MaxLength > 0 && len([]rune(normalized)) > f.MaxLength {
+		limit := strconv.Itoa(f.MaxLength)
+		unit := "characters"
+		if f.MaxLength == 1 {
+			unit = "character"
+		}
+		return "", &FieldError{Rule: "max", Message: "must be at most " + limit + " " + unit, Key: "max", Args: map[string]string{"param": limit, "unit": unit}}
+	}
+	return normalized, nil
+}
+
+// normalize returns value in the canonical form of the field's type. Unless
+// coerce is set, only values already in that form are accepted.
+func (f MetadataField) normalize(value string, coerce bool) (string, bool) {
+	var normalized string
+	switch f.Type {
+	case MetadataString:
+		return value, true
+	case MetadataInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil && coerce {
+			// Whole numbers written as decimals, such as 3.0
+			if x, floatErr := strconv.ParseFloat(value, 64); floatErr == nil && x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+				n, err = int64(x), nil
+			}
+		}
+		if err != nil {
+			return "", false
+		}
+		normalized = strconv.FormatInt(n, 10)
+	case MetadataNumber:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+			return "", false
+		}
+		normalized = strconv.FormatFloat(x, 'f', -1, 64)
+	case MetadataBoolean:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1":
+			normalized = "true"
+		case "false", "no", "off", "0":
+			normalized = "false"
+		default:
+			return "", false
+		}
+	case MetadataDate:
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil && coerce {
+			date, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			return "", false
+		}
+		normalized = date.Format(time.DateOnly)
+	default:
+		return "", false
+	}
+	if !coerce && normalized != value {
+		return "", false
+	}
+	return normalized, true
+}
+
+// CreateMetadataSchemaRequest defines a metadata schema
+type CreateMetadataSchemaRequest struct {
+	Name         string                   `json:"name" binding:"required"`
+	Description  string                   `json:"description" binding:"max=500"`
+	Folder       string                   `json:"folder" binding:"max=1024"`
+	Fields       map[string]MetadataField `json:"fields" binding:"required,max=50,dive,keys,required,max=128,endkeys"`
+	AllowUnknown bool                     `json:"allowUnknown"`
+	Mode         string                   `json:"mode" binding:"omitempty,oneof=reject coerce"` // defaults to reject
+}
+
+// UpdateMetadataSchemaRequest replaces the definition of a metadata schema
+type UpdateMetadataSchemaRequest struct {
+	Description  string                   `json:"description" binding:"max=500"`
+	Folder       string                   `json:"folder" binding:"max=1024"`
+	Fields       map[string]MetadataField `json:"fields" binding:"required,max=50,dive,keys,required,max=128,endkeys"`
+	AllowUnknown bool                     `json:"allowUnknown"`
+	Mode         string                   `json:"mode" binding:"omitempty,oneof=reject coerce"`
+}
+
+// Share is a tokenized, optionally password protected link to a file
+type Share struct {
+	Token         string     `json:"token"`
+	FileID        string     `json:"fileId"`
+	UserID        string     `json:"userId"`              // the owner of the file
+	CreatedBy     string     `json:"createdBy,omitempty"` // who created the share: the owner or an admin of the file
+	PasswordHash  string     `json:"passwordHash,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	MaxDownloads  int        `json:"maxDownloads,omitempty"` // 0 means unlimited
+	DownloadCount int        `json:"downloadCount"`
+	LastAccessAt  *time.Time `json:"lastAccessAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Ways a file's content can be downloaded, recorded in the access log
+const (
+	AccessDownload = "download"
+	AccessPublic   = "public"
+	AccessShare    = "share"
+	AccessVersion  = "version"
+	AccessZip      = "zip"
+)
+
+// AccessLogEntry records one download of a file's content
+type AccessLogEntry struct {
+	FileID     string    `json:"fileId"`
+	OwnerID    string    `json:"ownerId"`
+	UserID     string    `json:"userId,omitempty"` // empty for anonymous downloads
+	Via        string    `json:"via"`
+	ShareToken string    `json:"shareToken,omitempty"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Bytes      int64     `json:"bytes"`
+	AccessedAt time.Time `json:"accessedAt"`
+}
+
+// Audit event types
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login_failed"
+	AuditLogout         = "logout"
+	AuditPasswordChange = "password_change"
+	AuditPasswordReset  = "password_reset"
+	AuditEmailVerified  = "email_verified"
+	AuditRoleChange     = "role_change"
+	AuditStatusChange   = "status_change"
+	AuditTokensRevoked  = "tokens_revoked"
+	AuditImpersonation  = "impersonation"
+	AuditServiceAccount = "service_account"
+	AuditDataExport     = "data_export"
+	AuditErasure        = "erasure"
+	AuditMaintenance    = "maintenance"
+	AuditWebhook        = "webhook"
+	AuditS3Credentials  = "s3_credentials"
+	AuditOrganization   = "organization"
+	AuditTransfer       = "transfer" // posts or files given to another owner
+)
+
+// AuditEvent records one security relevant event of an account
+type AuditEvent struct {
+	Type       string            `json:"type"`
+	UserID     string            `json:"userId,omitempty"`   // account the event is about, empty if unknown
+	ActorID    string            `json:"actorId,omitempty"`  // admin that caused it
+	Username   string            `json:"username,omitempty"` // name given at a failed login
+	IP         string            `json:"ip"`
+	UserAgent  string            `json:"userAgent,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	OccurredAt time.Time         `json:"occurredAt"`
+}
+
+// LoginRecord is one sign-in attempt in a user's login history
+type LoginRecord struct {
+	Success   bool      `json:"success"`
+	Method    string    `json:"method,omitempty"` // password or the social login provider
+	Reason    string    `json:"reason,omitempty"` // why a failed attempt failed
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// NewLoginRecord returns the login history entry for a login or
+// login_failed audit event
+func NewLoginRecord(event *AuditEvent) *LoginRecord {
+	return &LoginRecord{
+		Success:   event.Type == AuditLogin,
+		Method:    event.Details["method"],
+		Reason:    event.Details["reason"],
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		At:        event.OccurredAt,
+	}
+}
+
+// AuditFilter selects audit events; zero fields match everything
+type AuditFilter struct {
+	Type   string
+	UserID string
+	From   time.Time
+	To     time.Time
+}
+
+// Matches reports whether event passes the type and user filters
+func (f AuditFilter) Matches(event *AuditEvent) bool {
+	if f.Type != "" && event.Type != f.Type {
+		return false
+	}
+	return f.UserID == "" || event.UserID == f.UserID || event.ActorID == f.UserID
+}
+
+// AdminAction records a change an admin made, with snapshots of what was
+// changed before and after. Before is empty for what the action created,
+// after for what it deleted. Snapshots never hold secrets.
+type AdminAction struct {
+	Action     string          `json:"action"`
+	ActorID    string          `json:"actorId"`
+	TargetType string          `json:"targetType"` // user, invitation, role, service_account, webhook or system
+	TargetID   string          `json:"targetId,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"userAgent,omitempty"`
+	OccurredAt time.Time       `json:"occurredAt"`
+}
+
+// Admin actions
+const (
+	AdminUserUpdate              = "user.update"
+	AdminUserStatus              = "user.status"
+	AdminUserDelete              = "user.delete"
+	AdminUserErase               = "user.erase"
+	AdminUserTransfer            = "user.transfer" // all of a user's content given to another owner
+	AdminTokensRevoke            = "user.tokens_revoke"
+	AdminImpersonate             = "user.impersonate"
+	AdminInvitationCreate        = "invitation.create"
+	AdminInvitationRevoke        = "invitation.revoke"
+	AdminRoleCreate              = "role.create"
+	AdminRoleUpdate              = "role.update"
+	AdminRoleDelete              = "role.delete"
+	AdminServiceAccountCreate    = "service_account.create"
+	AdminServiceAccountUpdate    = "service_account.update"
+	AdminServiceAccountDelete    = "service_account.delete"
+	AdminServiceAccountKeyRotate = "service_account.key_rotate"
+	AdminServiceAccountKeyRevoke = "service_account.key_revoke"
+	AdminWebhookCreate           = "webhook.create"
+	AdminWebhookUpdate           = "webhook.update"
+	AdminWebhookDelete           = "webhook.delete"
+	AdminWebhookRedeliver        = "webhook.redeliver"
+	AdminPostDelete              = "post.delete"      // a post of another user
+	AdminSeriesDelete            = "series.delete"    // a series of another user
+	AdminCommentDelete           = "comment.delete"   // a comment of another user
+	AdminCommentModerate         = "comment.moderate" // on a post of another user
+	AdminFileDelete              = "file.delete"      // a file of another user
+	AdminModerationApprove       = "moderation.approve"
+	AdminModerationReject        = "moderation.reject"
+	AdminReportResolve           = "report.resolve" // resolves or dismisses every open report of a post or file
+	AdminMetadataSchemaCreate    = "metadata_schema.create"
+	AdminMetadataSchemaUpdate    = "metadata_schema.update"
+	AdminMetadataSchemaDelete    = "metadata_schema.delete"
+	AdminMaintenance             = "maintenance.update"
+	AdminStatsRebuild            = "stats.rebuild"
+	AdminJobsRetry               = "jobs.retry"
+	AdminTaskRun                 = "task.run"
+)
+
+// Kinds of targets of admin actions
+const (
+	AdminTargetUser           = "user"
+	AdminTargetInvitation     = "invitation"
+	AdminTargetRole           = "role"
+	AdminTargetServiceAccount = "service_account"
+	AdminTargetWebhook        = "webhook"
+	AdminTargetPost           = "post"
+	AdminTargetSeries         = "series"
+	AdminTargetComment        = "comment"
+	AdminTargetFile           = "file"
+	AdminTargetMetadataSchema = "metadata_schema"
+	AdminTargetSystem         = "system"
+)
+
+// AdminActionFilter selects admin actions; zero fields match everything
+type AdminActionFilter struct {
+	Action     string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+// Matches reports whether action passes the action, actor and target
+// filters
+func (f AdminActionFilter) Matches(action *AdminAction) bool {
+	return (f.Action == "" || action.Action == f.Action) &&
+		(f.ActorID == "" || action.ActorID == f.ActorID) &&
+		(f.TargetType == "" || action.TargetType == f.TargetType) &&
+		(f.TargetID == "" || action.TargetID == f.TargetID)
+}
+
+// FileStats summarises a file's access log
+type FileStats struct {
+	FileID         string           `json:"fileId"`
+	Downloads      int64            `json:"downloads"`
+	UniqueUsers    int64            `json:"uniqueUsers"`    // distinct signed-in downloaders
+	AnonymousCount int64            `json:"anonymousCount"` // downloads without a user
+	BytesServed    int64            `json:"bytesServed"`
+	DownloadsByVia map[string]int64 `json:"downloadsByVia"`
+	LastAccess     *AccessLogEntry  `json:"lastAccess,omitempty"`
+
+	users map[string]bool
+}
+
+func NewFileStats(fileID string) *FileStats {
+	return &FileStats{
+		FileID:         fileID,
+		DownloadsByVia: map[string]int64{},
+		users:          map[string]bool{},
+	}
+}
+
+// Add counts one access log entry
+func (s *FileStats) Add(entry *AccessLogEntry) {
+	s.Downloads++
+	s.BytesServed += entry.Bytes
+	s.DownloadsByVia[entry.Via]++
+	if entry.UserID == "" {
+		s.AnonymousCount++
+	} else if !s.users[entry.UserID] {
+		s.users[entry.UserID] = true
+		s.UniqueUsers++
+	}
+	if s.LastAccess == nil || entry.AccessedAt.After(s.LastAccess.AccessedAt) {
+		s.LastAccess = entry
+	}
+}
+
+// CreateShareRequest for creating a share link
+type CreateShareRequest struct {
+	ExpiresInHours int    `json:"expiresInHours" binding:"omitempty,min=1,max=8760"`
+	MaxDownloads   int    `json:"maxDownloads" binding:"omitempty,min=1"`
+	Password       string `json:"password" binding:"omitempty,min=4"`
+
+	// NotifyEmails are sent the link, with Note; the password is not
+	NotifyEmails []string `json:"notifyEmails" binding:"omitempty,max=20,dive,email"`
+	Note         string   `json:"note" binding:"omitempty,max=1000"`
+}
+
+// ShareResponse for API responses (excludes the password hash)
+type ShareResponse struct {
+	Token         string     `json:"token"`
+	FileID        string     `json:"fileId"`
+	CreatedBy     string     `json:"createdBy,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	MaxDownloads  int        `json:"maxDownloads,omitempty"`
+	DownloadCount int        `json:"downloadCount"`
+	HasPassword   bool       `json:"hasPassword"`
+	LastAccessAt  *time.Time `json:"lastAccessAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// ToShareResponse converts Share to ShareResponse (removing sensitive data)
+func (s *Share) ToShareResponse() *ShareResponse {
+	return &ShareResponse{
+		Token:         s.Token,
+		FileID:        s.FileID,
+		CreatedBy:     s.CreatedBy,
+		ExpiresAt:     s.ExpiresAt,
+		MaxDownloads:  s.MaxDownloads,
+		DownloadCount: s.DownloadCount,
+		HasPassword:   s.PasswordHash != "",
+		LastAccessAt:  s.LastAccessAt,
+		CreatedAt:     s.CreatedAt,
+	}
+}
+
 // Pagination for listing operations
 type Pagination struct {
 	Page     int   `json:"page"`
@@ -56,19 +1180,167 @@ type Pagination struct {
 	Total    int64 `json:"total"`
 }
 
-// LoginRequest for authentication
+// LoginRequest for authentication. Identifier is a username or an email
+// address; username and email are accepted in its place for older clients.
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Identifier string `json:"identifier" binding:"required_without_all=Username Email"`
+	Username   string `json:"username,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Password   string `json:"password" binding:"required"`
+
+	// RememberMe asks for an extended session instead of a short one
+	RememberMe bool `json:"rememberMe"`
+
+	// CaptchaToken from the CAPTCHA widget; required after repeated
+	// failed logins when CAPTCHA verification is enabled
+	CaptchaToken string `json:"captchaToken,omitempty"`
+}
+
+// LoginName returns the username or email address the user signs in with
+func (r *LoginRequest) LoginName() string {
+	switch {
+	case r.Identifier != "":
+		return r.Identifier
+	case r.Username != "":
+		return r.Username
+	}
+	return r.Email
+}
+
+// UpdateUserRequest edits a user's profile. Omitted fields are unchanged.
+// Role is only honoured for callers who manage users; email, username,
+// status and the password have endpoints of their own.
+type UpdateUserRequest struct {
+	FirstName *string `json:"firstName" binding:"omitempty,max=100"`
+	LastName  *string `json:"lastName" binding:"omitempty,max=100"`
+	Avatar    *string `json:"avatar" binding:"omitempty,max=2048"`
+	Bio       *string `json:"bio" binding:"omitempty,max=500"`
+	Role      *string `json:"role" binding:"omitempty,min=1,max=64"`
+
+	Privacy *ProfilePrivacy `json:"privacy"`
+}
+
+// Apply copies the profile fields set in the request to user. The role is
+// left to the caller, which must check who may change it.
+func (r *UpdateUserRequest) Apply(user *User) {
+	if r.FirstName != nil {
+		user.FirstName = *r.FirstName
+	}
+	if r.LastName != nil {
+		user.LastName = *r.LastName
+	}
+	if r.Avatar != nil {
+		user.Avatar = *r.Avatar
+	}
+	if r.Bio != nil {
+		user.Bio = *r.Bio
+	}
+	if r.Privacy != nil {
+		user.Privacy = *r.Privacy
+	}
+}
+
+// UserSettings are a user's preferences. Users who never saved theirs get
+// DefaultUserSettings.
+type UserSettings struct {
+	Theme             string                  `json:"theme"`             // system, light or dark
+	Locale            string                  `json:"locale"`            // BCP 47 language tag, such as en or pt-BR
+	DefaultPostStatus string                  `json:"defaultPostStatus"` // status of new posts that do not name one
+	Notifications     NotificationPreferences `json:"notifications"`
+	UpdatedAt         time.Time               `json:"updatedAt,omitempty"`
+}
+
+// NotificationPreferences choose the emails a user gets. In-app
+// notifications are always raised.
+type NotificationPreferences struct {
+	MentionEmails bool `json:"mentionEmails"` // when a post or comment mentions them
+	Digest        bool `json:"digest"`        // the periodic digest of new posts and expiring files
+}
+
+// Themes
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// DefaultUserSettings returns the settings of users who never saved theirs
+func DefaultUserSettings() *UserSettings {
+	return &UserSettings{
+		Theme:             ThemeSystem,
+		Locale:            "en",
+		DefaultPostStatus: PostStatusDraft,
+		Notifications:     NotificationPreferences{MentionEmails: true, Digest: true},
+	}
+}
+
+// UpdateUserSettingsRequest changes settings. Omitted fields are
+// unchanged.
+type UpdateUserSettingsRequest struct {
+	Theme             *string                        `json:"theme" binding:"omitempty,oneof=system light dark"`
+	Locale            *string                        `json:"locale" binding:"omitempty,max=35,bcp47_language_tag"`
+	DefaultPostStatus *string                        `json:"defaultPostStatus" binding:"omitempty,oneof=draft published"`
+	Notifications     *UpdateNotificationPreferences `json:"notifications"`
+}
+
+type UpdateNotificationPreferences struct {
+	MentionEmails *bool `json:"mentionEmails"`
+	Digest        *bool `json:"digest"`
+}
+
+// Apply copies the fields set in the request to settings
+func (r *UpdateUserSettingsRequest) Apply(settings *UserSettings) {
+	if r.Theme != nil {
+		settings.Theme = *r.Theme
+	}
+	if r.Locale != nil {
+		settings.Locale = *r.Locale
+	}
+	if r.DefaultPostStatus != nil {
+		settings.DefaultPostStatus = *r.DefaultPostStatus
+	}
+	if r.Notifications == nil {
+		return
+	}
+	if r.Notifications.MentionEmails != nil {
+		settings.Notifications.MentionEmails = *r.Notifications.MentionEmails
+	}
+	if r.Notifications.Digest != nil {
+		settings.Notifications.Digest = *r.Notifications.Digest
+	}
 }
 
 // RegisterRequest for user registration
 type RegisterRequest struct {
-	Username  string `json:"username" binding:"required"`
-	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password" binding:"required,min=6"`
-	FirstName string `json:"firstName" binding:"required"`
-	LastName  string `json:"lastName" binding:"required"`
+	Username  string `json:"username" binding:"required,min=3,max=64,excludes=@"`
+	Email     string `json:"email" binding:"required,email,max=254"`
+	Password  string `json:"password" binding:"required"` // checked against the password policy
+	FirstName string `json:"firstName" binding:"required,max=100"`
+	LastName  string `json:"lastName" binding:"required,max=100"`
+
+	// InviteToken from an invite link; required when registration is
+	// invite-only
+	InviteToken string `json:"inviteToken,omitempty"`
+
+	// CaptchaToken from the CAPTCHA widget; required when CAPTCHA
+	// verification is enabled
+	CaptchaToken string `json:"captchaToken,omitempty"`
+}
+
+// SetupRequest creates the first admin of a new installation with the
+// setup token the server logged at startup
+type SetupRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Username  string `json:"username" binding:"required,min=3,max=64,excludes=@"`
+	Email     string `json:"email" binding:"required,email,max=254"`
+	Password  string `json:"password" binding:"required"` // checked against the password policy
+	FirstName string `json:"firstName" binding:"max=100"`
+	LastName  string `json:"lastName" binding:"max=100"`
+}
+
+// SetupStatus tells whether the installation still needs its first admin
+type SetupStatus struct {
+	Required bool `json:"required"`
 }
 
 // UserResponse for API responses (excludes sensitive data)
@@ -79,10 +1351,22 @@ type UserResponse struct {
 	FirstName string    `json:"firstName"`
 	LastName  string    `json:"lastName"`
 	Role      string    `json:"role"`
+	Status    string    `json:"status"`
 	Avatar    string    `json:"avatar,omitempty"`
+	Bio       string    `json:"bio,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	ETag      string    `json:"etag,omitempty"`
+
+	Privacy ProfilePrivacy `json:"privacy"`
+
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	LastLoginIP string     `json:"lastLoginIp,omitempty"`
+
+	EmailVerified bool `json:"emailVerified"`
+
+	// Settings are only included in the caller's own profile
+	Settings *UserSettings `json:"settings,omitempty"`
 }
 
 // ToUserResponse converts User to UserResponse (removing sensitive data)
@@ -94,24 +1378,185 @@ func (u *User) ToUserResponse() *UserResponse {
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
 		Role:      u.Role,
+		Status:    u.AccountStatus(),
 		Avatar:    u.Avatar,
+		Bio:       u.Bio,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 		ETag:      u.ETag,
+
+		Privacy: u.Privacy,
+
+		LastLoginAt: u.LastLoginAt,
+		LastLoginIP: u.LastLoginIP,
+
+		EmailVerified: u.EmailVerifiedAt != nil,
 	}
 }
 
 // AuthResponse for login/register responses
 type AuthResponse struct {
-	User  *UserResponse `json:"user"`
-	Token string        `json:"token"`
+	User             *UserResponse `json:"user"`
+	Token            string        `json:"token"`
+	ExpiresIn        int           `json:"expiresIn"` // seconds until Token expires
+	ExpiresAt        time.Time     `json:"expiresAt"`
+	RefreshToken     string        `json:"refreshToken,omitempty"`     // exchange at /auth/refresh for a new token pair
+	RefreshExpiresIn int           `json:"refreshExpiresIn,omitempty"` // seconds RefreshToken stays valid unused
+}
+
+// ImpersonateRequest gives the reason an admin signs in as a user, kept in
+// the audit log
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// RefreshRequest exchanges a refresh token for a new token pair
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// ForgotPasswordRequest asks for a password reset link by email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"` // checked against the password policy
+}
+
+// VerifyEmailRequest confirms an email address with the token from a
+// verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// CreatedInvitation is returned once, when the invite link can still be
+// shared by other means if the email could not be queued
+type CreatedInvitation struct {
+	Invitation
+	Link      string `json:"link"`
+	EmailSent bool   `json:"emailSent"`
+}
+
+// CreateInvitationRequest invites someone by email
+type CreateInvitationRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	Role           string `json:"role"` // defaults to user
+	ExpiresInHours int    `json:"expiresInHours" binding:"omitempty,min=1,max=8760"`
+}
+
+// CreateServiceAccountRequest creates a service account with its first key
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// UpdateServiceAccountRequest changes the given fields of a service account
+type UpdateServiceAccountRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Permissions []string `json:"permissions" binding:"omitempty,min=1"`
+	Disabled    *bool    `json:"disabled"`
+}
+
+// RotateServiceAccountKeyRequest adds a key and expires the existing ones
+// after ExpireOldKeysIn minutes, at once when zero
+type RotateServiceAccountKeyRequest struct {
+	ExpireOldKeysIn int `json:"expireOldKeysIn" binding:"min=0,max=10080"`
+}
+
+// ServiceAccountKeyResponse returns a new key; it cannot be shown again
+type ServiceAccountKeyResponse struct {
+	Account *ServiceAccount `json:"account"`
+	KeyID   string          `json:"keyId"`
+	Key     string          `json:"key"`
+}
+
+// S3CredentialsRequest asks for credentials for the S3 gateway lasting
+// ExpiresIn hours, a week when zero
+type S3CredentialsRequest struct {
+	ExpiresIn int `json:"expiresIn" binding:"min=0,max=2160"`
+}
+
+// S3Credentials are the access key and secret key for the S3 gateway,
+// whose bucket holds the files of whoever they were issued to
+type S3Credentials struct {
+	AccessKeyID     string    `json:"accessKeyId"`
+	SecretAccessKey string    `json:"secretAccessKey"`
+	Bucket          string    `json:"bucket"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+// UpdateUserStatusRequest suspends, deactivates or reactivates a user
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active suspended deactivated"`
+}
+
+// ChangePasswordRequest sets a new password for the signed-in user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"` // checked against the password policy
+}
+
+// EraseAccountRequest deletes an account and its data. Users with a
+// password confirm it; files are deleted in either mode.
+type EraseAccountRequest struct {
+	Mode     string `json:"mode" binding:"required,oneof=purge anonymize"`
+	Password string `json:"password"`
+}
+
+// LogoutRequest optionally names the refresh token to revoke with the
+// access token
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RevokeTokensRequest revokes a single access token, e.g. one reported as
+// stolen, or every token of a user
+type RevokeTokensRequest struct {
+	Token  string `json:"token" binding:"required_without=UserID"`
+	UserID string `json:"userId" binding:"required_without=Token"`
+}
+
+// CreateRoleRequest defines a custom role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions" binding:"required,dive,required"`
+}
+
+// UpdateRoleRequest replaces a role's description and permissions
+type UpdateRoleRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Permissions []string `json:"permissions" binding:"omitempty,dive,required"`
 }
 
 // ErrorResponse for API errors
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code,omitempty"`
+	RequestID string `json:"requestId,omitempty"` // filled in by RequestIDMiddleware
+	Error     string `json:"error"`
+	Message   string `json:"message,omitempty"`
+	Code      int    `json:"code,omitempty"`      // HTTP status
+	ErrorCode string `json:"errorCode,omitempty"` // stable code from package apierr
+
+	// Fields lists what is wrong with each invalid field of the request
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes one invalid field of a request: its JSON path, the
+// rule it broke and a message for people
+type FieldError struct {
+	Name    string `json:"name"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+
+	// Key and Args let the message be translated: the validation rule it
+	// reports, such as max, and the param, unit or type it names. Messages
+	// without a key are translated as a whole, if at all.
+	Key  string            `json:"-"`
+	Args map[string]string `json:"-"`
 }
 
 // SuccessResponse for API success responses
@@ -125,3 +1570,627 @@ type ListResponse struct {
 	Data       interface{} `json:"data"`
 	Pagination Pagination  `json:"pagination"`
 }
+
+// Maintenance is the server's read-only mode. While it is on, requests
+// that change data are rejected with 503 and reads keep working.
+type Maintenance struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message,omitempty"`    // shown to clients in the 503 response
+	RetryAfter int        `json:"retryAfter,omitempty"` // seconds clients are told to wait
+	Forced     bool       `json:"forced,omitempty"`     // on through the configuration, which the API cannot change
+	UpdatedBy  string     `json:"updatedBy,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message" binding:"max=500"`
+	RetryAfter int    `json:"retryAfter" binding:"min=0,max=86400"`
+}
+
+// EffectiveConfig is the configuration the server runs with, for
+// debugging deployments. Secrets are masked.
+type EffectiveConfig struct {
+	Environment string          `json:"environment"`
+	ConfigFile  string          `json:"configFile,omitempty"`
+	Settings    []ConfigSetting `json:"settings"`
+}
+
+// ConfigSetting is one variable of the configuration
+type ConfigSetting struct {
+	Name    string `json:"name"`              // environment variable, e.g. MINIO_ENDPOINT
+	Value   string `json:"value"`             // "********" for secrets that are set
+	Source  string `json:"source"`            // env, secrets, file or default
+	Invalid string `json:"invalid,omitempty"` // a value that could not be parsed, ignored for the default
+}
+
+// SystemStats summarizes the system for admins. The counts are kept up to
+// date as data changes rather than counted on request.
+type SystemStats struct {
+	Users       UserTotals   `json:"users"`
+	Posts       PostTotals   `json:"posts"`
+	Files       FileTotals   `json:"files"`
+	Health      SystemHealth `json:"health"`
+	Complete    bool         `json:"complete"` // false until the counts were first built from the stored data
+	GeneratedAt time.Time    `json:"generatedAt"`
+}
+
+type UserTotals struct {
+	Total         int64        `json:"total"`
+	SignupsPerDay []DailyCount `json:"signupsPerDay"`
+}
+
+type PostTotals struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"byStatus"`
+}
+
+type FileTotals struct {
+	Total         int64        `json:"total"`
+	Bytes         int64        `json:"bytes"`
+	UploadsPerDay []DailyCount `json:"uploadsPerDay"`
+}
+
+// DailyCount is how many items were created on a day, and their size
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD in UTC
+	Count int64  `json:"count"`
+	Bytes int64  `json:"bytes,omitempty"`
+}
+
+// SystemHealth reports the services the API depends on and the entries
+// of its in-memory caches
+type SystemHealth struct {
+	MinIO  ServiceHealth  `json:"minio"`
+	Redis  ServiceHealth  `json:"redis"`
+	Caches map[string]int `json:"caches"`
+}
+
+type ServiceHealth struct {
+	Status    string `json:"status"` // up or down
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RuntimeStats is a snapshot of the Go runtime of the server that answered,
+// for diagnosing memory and goroutine growth. Each server reports only
+// itself.
+type RuntimeStats struct {
+	GoVersion        string         `json:"goVersion"`
+	StartedAt        time.Time      `json:"startedAt"`
+	UptimeSeconds    int64          `json:"uptimeSeconds"`
+	CPUs             int            `json:"cpus"`
+	GOMAXPROCS       int            `json:"gomaxprocs"`
+	Goroutines       int            `json:"goroutines"`
+	Memory           MemoryStats    `json:"memory"`
+	GC               GCStats        `json:"gc"`
+	MinIOConnections MinIOConnStats `json:"minioConnections"`
+	GeneratedAt      time.Time      `json:"generatedAt"`
+}
+
+// MemoryStats are in bytes, except HeapObjects
+type MemoryStats struct {
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapIdle     uint64 `json:"heapIdle"`
+	HeapReleased uint64 `json:"heapReleased"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	StackInuse   uint64 `json:"stackInuse"`
+	Sys          uint64 `json:"sys"`        // obtained from the operating system
+	TotalAlloc   uint64 `json:"totalAlloc"` // allocated since the start, including freed memory
+}
+
+type GCStats struct {
+	Cycles       uint32     `json:"cycles"`
+	LastAt       *time.Time `json:"lastAt,omitempty"`
+	PauseTotalMs float64    `json:"pauseTotalMs"`
+	LastPauseMs  float64    `json:"lastPauseMs"`
+	CPUFraction  float64    `json:"cpuFraction"`  // of the available CPU time used by the collector since the start
+	NextHeapGoal uint64     `json:"nextHeapGoal"` // heap size in bytes that triggers the next cycle
+}
+
+// MinIOConnStats counts the connections to MinIO, open ones whether busy
+// or idle in the pool
+type MinIOConnStats struct {
+	Open   int64 `json:"open"`
+	Dialed int64 `json:"dialed"` // since the start
+}
+
+// Webhook sends domain events to an external URL. Deliveries are signed
+// with Secret, which is only shown when the webhook is created.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"` // event types such as post.published, post.* or *
+	Description string    `json:"description,omitempty"`
+	Secret      string    `json:"secret,omitempty"`
+	Disabled    bool      `json:"disabled"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// ForResponse returns a copy of the webhook without its secret
+func (w *Webhook) ForResponse() *Webhook {
+	webhook := *w
+	webhook.Secret = ""
+	return &webhook
+}
+
+// CreateWebhookRequest registers a webhook. Without a secret one is
+// generated.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`
+	Events      []string `json:"events" binding:"required,min=1,max=20"`
+	Description string   `json:"description" binding:"max=500"`
+	Secret      string   `json:"secret" binding:"omitempty,min=16,max=200"`
+}
+
+// UpdateWebhookRequest changes the given fields of a webhook
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,url,max=2048"`
+	Events      []string `json:"events" binding:"omitempty,min=1,max=20"`
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Secret      *string  `json:"secret" binding:"omitempty,min=16,max=200"`
+	Disabled    *bool    `json:"disabled"`
+}
+
+// WebhookSecretResponse returns a new webhook with its secret
+type WebhookSecretResponse struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret"`
+}
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryRetrying  = "retrying"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // gave up after the last attempt
+)
+
+// WebhookDelivery logs the delivery of one event to one webhook, across
+// all of its attempts
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"` // the event as sent
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"` // of the last attempt
+	ResponseBody   string          `json:"responseBody,omitempty"`   // the start of it
+	Error          string          `json:"error,omitempty"`
+	DurationMs     int64           `json:"durationMs,omitempty"`
+	RedeliveryOf   string          `json:"redeliveryOf,omitempty"` // the delivery this one repeats
+	CreatedAt      time.Time       `json:"createdAt"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+// JobStatus is the state of one type of background job. Counters cover
+// every server since the type first ran.
+type JobStatus struct {
+	Type        string     `json:"type"`
+	Pending     int64      `json:"pending"`  // queued and not started
+	InFlight    int64      `json:"inFlight"` // running or waiting to be retried
+	Enqueued    int64      `json:"enqueued"`
+	Succeeded   int64      `json:"succeeded"`
+	Failed      int64      `json:"failed"`  // failed attempts
+	Retried     int64      `json:"retried"` // failed attempts that were retried
+	Dead        int64      `json:"dead"`    // jobs that were given up on
+	DeadLetters int64      `json:"deadLetters"`
+	LastError   string     `json:"lastError,omitempty"` // of the last dead job
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// DeadJob is a background job that was given up on, kept for inspection
+// and retrying
+type DeadJob struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload" swaggertype:"object"`
+	Attempts   int             `json:"attempts"`
+	Error      string          `json:"error"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	FailedAt   time.Time       `json:"failedAt"`
+}
+
+// SchedulerStatus lists the recurring tasks and the server running them
+type SchedulerStatus struct {
+	Leader string          `json:"leader,omitempty"` // server running the scheduled tasks, if any
+	Tasks  []ScheduledTask `json:"tasks"`
+}
+
+// ScheduledTask is a recurring task with its cron schedule, in UTC, and
+// its last run
+type ScheduledTask struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+	Running        bool       `json:"running"`
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty"`
+	LastDurationMs int64      `json:"lastDurationMs"`
+	LastRunBy      string     `json:"lastRunBy,omitempty"`
+	LastError      string     `json:"lastError,omitempty"` // of the last run, empty when it succeeded
+	LastSuccessAt  *time.Time `json:"lastSuccessAt,omitempty"`
+}
+
+// UsageReport is the storage each user takes up, aggregated by the usage
+// task, heaviest users first
+type UsageReport struct {
+	Users       []UserUsage `json:"users"`
+	TotalFiles  int64       `json:"totalFiles"`
+	TotalBytes  int64       `json:"totalBytes"`
+	TotalPosts  int64       `json:"totalPosts"`
+	GeneratedAt time.Time   `json:"generatedAt"`
+}
+
+type UserUsage struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username,omitempty"` // empty for users that no longer exist
+	Files    int64  `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Posts    int64  `json:"posts"`
+}
+
+// Notification is an in-app notification of a user, raised by a domain
+// event
+type Notification struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"userId"`
+	Type      string            `json:"type"`
+	Payload   map[string]string `json:"payload,omitempty"` // IDs and names the frontend links to
+	Read      bool              `json:"read"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Notification types
+const (
+	NotificationFileQuarantined = "file.quarantined" // a virus scan found the user's upload infected
+	NotificationMentioned       = "mention"          // the user was mentioned in a post or comment
+	NotificationCommented       = "comment"          // someone commented on the user's post
+)
+
+type UnreadCount struct {
+	Unread int64 `json:"unread"`
+}
+
+// Organization is a team whose members share posts and files
+type Organization struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Organization roles, from most to least privileged. Owners manage the
+// organization and its members, admins manage members and every shared
+// item, members share and change their own items, and viewers only read.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+	OrgRoleViewer = "viewer"
+)
+
+var orgRoleRanks = map[string]int{OrgRoleViewer: 1, OrgRoleMember: 2, OrgRoleAdmin: 3, OrgRoleOwner: 4}
+
+// OrgRoleAtLeast reports whether role grants everything min does. Unknown
+// roles, including no role, grant nothing.
+func OrgRoleAtLeast(role, min string) bool {
+	rank, ok := orgRoleRanks[role]
+	return ok && rank >= orgRoleRanks[min]
+}
+
+// Membership gives a user a role in an organization
+type Membership struct {
+	OrgID     string    `json:"orgId"`
+	UserID    string    `json:"userId"`
+	Role      string    `json:"role"`
+	AddedBy   string    `json:"addedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CreateOrganizationRequest creates an organization, owned by its creator
+type CreateOrganizationRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// UpdateOrganizationRequest changes the given fields of an organization
+type UpdateOrganizationRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+}
+
+// SetMemberRequest adds a user to an organization or changes their role
+type SetMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+// TransferRequest gives a post, a file or all of a user's content to
+// another user, shares it with an organization, or both. Without userId
+// the owner stays the same; without orgId items keep their organization.
+type TransferRequest struct {
+	UserID string `json:"userId" binding:"required_without=OrgID,max=64"`
+	OrgID  string `json:"orgId" binding:"max=64"`
+}
+
+// TransferResult counts the items a transfer of a user's content moved
+type TransferResult struct {
+	Posts int `json:"posts"`
+	Files int `json:"files"`
+}
+
+// Bookmark marks a post a user wants to find again. Post is set when
+// bookmarks are listed.
+type Bookmark struct {
+	UserID    string    `json:"userId"`
+	PostID    string    `json:"postId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Post      *Post     `json:"post,omitempty"`
+}
+
+// Series is an ordered collection of posts of one author
+type Series struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	PostIDs     []string  `json:"postIds"` // in reading order
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Posts are the posts of PostIDs that still exist, set when a series
+	// is read alone
+	Posts []*Post `json:"posts,omitempty"`
+}
+
+// SeriesNavigation tells where a post stands in its series
+type SeriesNavigation struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Position int            `json:"position"` // 1 for the first post
+	Total    int            `json:"total"`
+	Previous *SeriesPostRef `json:"previous,omitempty"`
+	Next     *SeriesPostRef `json:"next,omitempty"`
+}
+
+// SeriesPostRef links to another post of a series
+type SeriesPostRef struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// Navigation returns where postID stands in the series, or nil when it is
+// not part of it. titles names the posts next to it.
+func (s *Series) Navigation(postID string, titles map[string]string) *SeriesNavigation {
+	i := slices.Index(s.PostIDs, postID)
+	if i < 0 {
+		return nil
+	}
+	nav := &SeriesNavigation{ID: s.ID, Title: s.Title, Position: i + 1, Total: len(s.PostIDs)}
+	if i > 0 {
+		nav.Previous = &SeriesPostRef{ID: s.PostIDs[i-1], Title: titles[s.PostIDs[i-1]]}
+	}
+	if i < len(s.PostIDs)-1 {
+		nav.Next = &SeriesPostRef{ID: s.PostIDs[i+1], Title: titles[s.PostIDs[i+1]]}
+	}
+	return nav
+}
+
+// QueryField implements Queryable
+func (s *Series) QueryField(name string) any {
+	switch name {
+	case "userId":
+		return s.UserID
+	case "title":
+		return s.Title
+	case "createdAt":
+		return s.CreatedAt
+	case "updatedAt":
+		return s.UpdatedAt
+	}
+	return nil
+}
+
+// CreateSeriesRequest starts a series; posts are added one at a time
+type CreateSeriesRequest struct {
+	Title       string `json:"title" binding:"required,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+}
+
+// UpdateSeriesRequest edits a series. Omitted fields are unchanged.
+type UpdateSeriesRequest struct {
+	Title       *string `json:"title" binding:"omitempty,min=1,max=200"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+}
+
+// Apply copies the fields set in the request to series
+func (r *UpdateSeriesRequest) Apply(series *Series) {
+	if r.Title != nil {
+		series.Title = *r.Title
+	}
+	if r.Description != nil {
+		series.Description = *r.Description
+	}
+}
+
+// AddSeriesPostRequest adds a post of the series' author to it
+type AddSeriesPostRequest struct {
+	PostID   string `json:"postId" binding:"required,max=64"`
+	Position int    `json:"position" binding:"min=0"` // 1 for the first post; 0 or past the end appends
+}
+
+// ReorderSeriesRequest lists every post of a series in its new order
+type ReorderSeriesRequest struct {
+	PostIDs []string `json:"postIds" binding:"required,max=1000,dive,max=64"`
+}
+
+// TagCount is a tag of the tag directory
+type TagCount struct {
+	Tag    string `json:"tag"`    // lowercase
+	Posts  int64  `json:"posts"`  // published posts that carry it
+	Recent int64  `json:"recent"` // times it was added to a post in the last 7 days
+	Trend  int64  `json:"trend"`  // Recent minus the same count for the 7 days before
+}
+
+// Mention records that a post or comment names a user as @username
+type Mention struct {
+	UserID    string    `json:"userId"`   // who was mentioned
+	AuthorID  string    `json:"authorId"` // who wrote the post or comment
+	PostID    string    `json:"postId"`
+	CommentID string    `json:"commentId,omitempty"` // set for mentions in comments
+	CreatedAt time.Time `json:"createdAt"`
+	Post      *Post     `json:"post,omitempty"`
+}
+
+// Comment is written on a post, or in reply to another comment of the
+// same post
+type Comment struct {
+	ID       string `json:"id"`
+	PostID   string `json:"postId"`
+	UserID   string `json:"userId"`
+	ParentID string `json:"parentId,omitempty"` // the comment replied to
+	Depth    int    `json:"depth"`              // 0 for comments on the post
+	Content  string `json:"content"`
+	Status   string `json:"status"`
+	Replies  int    `json:"replies"` // direct replies, counted when listed
+
+	// Set when the comment was last hidden or shown again
+	ModeratedBy string     `json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time `json:"moderatedAt,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Comment states. Hidden and pending comments are shown in full only to
+// their author and those who may change the post; pending ones await
+// review and are hidden when rejected. Deleted ones with replies stay as
+// placeholders so the thread holds together.
+const (
+	CommentStatusVisible = "visible"
+	CommentStatusHidden  = "hidden"
+	CommentStatusDeleted = "deleted"
+	CommentStatusPending = "pending"
+)
+
+// CreateCommentRequest comments on a post, or replies to parentId
+type CreateCommentRequest struct {
+	Content  string `json:"content" binding:"required,max=10000"`
+	ParentID string `json:"parentId" binding:"max=64"`
+}
+
+// Kinds of content in the moderation queue
+const (
+	ModerationPost    = "post"
+	ModerationComment = "comment"
+)
+
+// ModerationItem is a post or comment held for review
+type ModerationItem struct {
+	ID        string    `json:"id"`   // the ID of the post or comment
+	Kind      string    `json:"kind"` // post or comment
+	PostID    string    `json:"postId"`
+	CommentID string    `json:"commentId,omitempty"`
+	Reasons   []string  `json:"reasons"` // the rules it broke, such as keyword:casino
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Post and Comment are the content as it is now, set when the queue
+	// is listed
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// QueryField implements Queryable
+func (i *ModerationItem) QueryField(name string) any {
+	switch name {
+	case "kind":
+		return i.Kind
+	case "postId":
+		return i.PostID
+	case "createdAt":
+		return i.CreatedAt
+	}
+	return nil
+}
+
+// Kinds of reported content
+const (
+	ReportTargetPost = "post"
+	ReportTargetFile = "file"
+)
+
+// Report states. Open reports become escalated once enough users reported
+// the same content; resolved ones were acted on, dismissed ones were not.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusEscalated = "escalated"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Report flags a post or file to the post or file admins. A user has at
+// most one open report of each.
+type Report struct {
+	ID         string     `json:"id"`
+	TargetType string     `json:"targetType"` // post or file
+	TargetID   string     `json:"targetId"`
+	ReporterID string     `json:"reporterId"`
+	Reason     string     `json:"reason"` // spam, harassment, hate, violence, sexual, illegal, copyright or other
+	Details    string     `json:"details,omitempty"`
+	Status     string     `json:"status"`
+	Resolution string     `json:"resolution,omitempty"` // the note of the admin who closed it
+	ResolvedBy string     `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// IsOpen reports whether the report awaits an admin
+func (r *Report) IsOpen() bool {
+	return r.Status == ReportStatusOpen || r.Status == ReportStatusEscalated
+}
+
+// QueryField implements Queryable
+func (r *Report) QueryField(name string) any {
+	switch name {
+	case "targetType":
+		return r.TargetType
+	case "targetId":
+		return r.TargetID
+	case "reporterId":
+		return r.ReporterID
+	case "reason":
+		return r.Reason
+	case "status":
+		return r.Status
+	case "createdAt":
+		return r.CreatedAt
+	}
+	return nil
+}
+
+// CreateReportRequest reports a post or file
+type CreateReportRequest struct {
+	Reason  string `json:"reason" binding:"required,oneof=spam harassment hate violence sexual illegal copyright other"`
+	Details string `json:"details" binding:"max=1000"`
+}
+
+// ResolveReportsRequest closes every open report of a post or file
+type ResolveReportsRequest struct {
+	Status     string `json:"status" binding:"required,oneof=resolved dismissed"`
+	Resolution string `json:"resolution" binding:"max=1000"`
+}
+
+// ModerateCommentRequest hides a comment or shows it again
+type ModerateCommentRequest struct {
+	Status string `json:"status" binding:"required,oneof=visible hidden"`
+}
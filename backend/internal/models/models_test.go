@@ -0,0 +1,394 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileForViewer(t *testing.T) {
+	file := &File{
+		UserID: "owner",
+		Metadata: map[string]string{
+			"project":          "apollo",
+			"exif.gpsLatitude": "52.520008",
+		},
+	}
+
+	assert.Same(t, file, file.ForViewer("owner"))
+
+	visible := file.ForViewer("someone-else")
+	assert.Equal(t, map[string]string{"project": "apollo"}, visible.Metadata)
+	assert.Contains(t, file.Metadata, "exif.gpsLatitude", "original must not be modified")
+}
+
+func TestFileIsExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	assert.False(t, (&File{}).IsExpired(now))
+	assert.True(t, (&File{ExpiresAt: &past}).IsExpired(now))
+	assert.True(t, (&File{ExpiresAt: &now}).IsExpired(now))
+	assert.False(t, (&File{ExpiresAt: &future}).IsExpired(now))
+}
+
+func TestVideoStreamStalled(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	old := time.Now().Add(-2 * time.Hour)
+
+	assert.False(t, (&VideoStream{Status: StreamStatusProcessing, StartedAt: &recent, UpdatedAt: recent}).Stalled(time.Hour))
+	assert.True(t, (&VideoStream{Status: StreamStatusProcessing, StartedAt: &old, UpdatedAt: recent}).Stalled(time.Hour))
+	// Recorded before StartedAt
+	assert.True(t, (&VideoStream{Status: StreamStatusProcessing, UpdatedAt: old}).Stalled(time.Hour))
+	assert.False(t, (&VideoStream{Status: StreamStatusReady, StartedAt: &old, UpdatedAt: old}).Stalled(time.Hour))
+}
+
+func TestFileStatsAdd(t *testing.T) {
+	now := time.Now()
+	stats := NewFileStats("file-1")
+
+	stats.Add(&AccessLogEntry{UserID: "alice", Via: AccessDownload, Bytes: 100, AccessedAt: now.Add(-time.Hour)})
+	stats.Add(&AccessLogEntry{UserID: "alice", Via: AccessZip, Bytes: 100, AccessedAt: now})
+	stats.Add(&AccessLogEntry{Via: AccessShare, IP: "203.0.113.7", Bytes: 40, AccessedAt: now.Add(-time.Minute)})
+
+	assert.Equal(t, int64(3), stats.Downloads)
+	assert.Equal(t, int64(1), stats.UniqueUsers)
+	assert.Equal(t, int64(1), stats.AnonymousCount)
+	assert.Equal(t, int64(240), stats.BytesServed)
+	assert.Equal(t, map[string]int64{AccessDownload: 1, AccessZip: 1, AccessShare: 1}, stats.DownloadsByVia)
+	assert.Equal(t, AccessZip, stats.LastAccess.Via)
+}
+
+func TestFileEncryptionValidate(t *testing.T) {
+	valid := FileEncryption{Algorithm: "AES-256-GCM", KeyID: "key-1", IV: "AAECAwQFBgcICQoL"}
+	assert.NoError(t, valid.Validate())
+
+	unknown := valid
+	unknown.Algorithm = "ROT13"
+	assert.Error(t, unknown.Validate())
+
+	noKey := valid
+	noKey.KeyID = ""
+	assert.Error(t, noKey.Validate())
+
+	badIV := valid
+	badIV.IV = "not base64!"
+	assert.Error(t, badIV.Validate())
+
+	shortIV := valid
+	shortIV.IV = "AAEC"
+	assert.Error(t, shortIV.Validate())
+}
+
+func TestRoleHas(t *testing.T) {
+	admin := DefaultRoles[RoleAdmin]
+	assert.True(t, admin.Has(PermRolesAdmin))
+
+	user := DefaultRoles[RoleUser]
+	assert.True(t, user.Has(PermFilesWrite))
+	assert.False(t, user.Has(PermFilesAdmin))
+
+	curator := &Role{Permissions: []string{"files:*", PermPostsRead}}
+	assert.True(t, curator.Has(PermFilesAdmin))
+	assert.True(t, curator.Has(PermPostsRead))
+	assert.False(t, curator.Has(PermPostsWrite))
+}
+
+func TestOrgRoleAtLeast(t *testing.T) {
+	assert.True(t, OrgRoleAtLeast(OrgRoleOwner, OrgRoleAdmin))
+	assert.True(t, OrgRoleAtLeast(OrgRoleMember, OrgRoleMember))
+	assert.True(t, OrgRoleAtLeast(OrgRoleViewer, OrgRoleViewer))
+	assert.False(t, OrgRoleAtLeast(OrgRoleViewer, OrgRoleMember))
+	assert.False(t, OrgRoleAtLeast(OrgRoleAdmin, OrgRoleOwner))
+
+	// No role and unknown roles grant nothing
+	assert.False(t, OrgRoleAtLeast("", OrgRoleViewer))
+	assert.False(t, OrgRoleAtLeast("superuser", OrgRoleViewer))
+}
+
+func TestValidPermission(t *testing.T) {
+	assert.True(t, ValidPermission(PermFilesRead))
+	assert.True(t, ValidPermission(PermAll))
+	assert.True(t, ValidPermission("posts:*"))
+	assert.False(t, ValidPermission("files:delete"))
+	assert.False(t, ValidPermission("widgets:*"))
+	assert.False(t, ValidPermission(""))
+}
+
+func TestUserAccountStatus(t *testing.T) {
+	assert.Equal(t, UserStatusActive, (&User{}).AccountStatus())
+	assert.Equal(t, UserStatusSuspended, (&User{Status: UserStatusSuspended}).AccountStatus())
+	assert.Equal(t, UserStatusActive, (&User{}).ToUserResponse().Status)
+}
+
+func TestInvitationUsable(t *testing.T) {
+	now := time.Now()
+	invitation := &Invitation{ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, invitation.Usable(now))
+	assert.False(t, invitation.Usable(now.Add(2*time.Hour)))
+
+	invitation.AcceptedAt = &now
+	assert.False(t, invitation.Usable(now))
+}
+
+func TestAuditFilterMatches(t *testing.T) {
+	event := &AuditEvent{Type: AuditRoleChange, UserID: "alice", ActorID: "admin"}
+
+	assert.True(t, AuditFilter{}.Matches(event))
+	assert.True(t, AuditFilter{Type: AuditRoleChange, UserID: "alice"}.Matches(event))
+	assert.True(t, AuditFilter{UserID: "admin"}.Matches(event), "actor matches")
+	assert.False(t, AuditFilter{Type: AuditLogin}.Matches(event))
+	assert.False(t, AuditFilter{UserID: "bob"}.Matches(event))
+}
+
+func TestAdminActionFilterMatches(t *testing.T) {
+	action := &AdminAction{Action: AdminRoleUpdate, ActorID: "admin", TargetType: AdminTargetRole, TargetID: "editor"}
+
+	assert.True(t, AdminActionFilter{}.Matches(action))
+	assert.True(t, AdminActionFilter{Action: AdminRoleUpdate, ActorID: "admin", TargetType: AdminTargetRole, TargetID: "editor"}.Matches(action))
+	assert.False(t, AdminActionFilter{Action: AdminRoleDelete}.Matches(action))
+	assert.False(t, AdminActionFilter{ActorID: "editor"}.Matches(action), "target is not the actor")
+	assert.False(t, AdminActionFilter{TargetType: AdminTargetUser, TargetID: "editor"}.Matches(action))
+}
+
+func TestLoginRequestLoginName(t *testing.T) {
+	assert.Equal(t, "alice", (&LoginRequest{Identifier: "alice", Email: "bob@example.com"}).LoginName())
+	assert.Equal(t, "alice", (&LoginRequest{Username: "alice"}).LoginName())
+	assert.Equal(t, "alice@example.com", (&LoginRequest{Email: "alice@example.com"}).LoginName())
+}
+
+func TestNewLoginRecord(t *testing.T) {
+	now := time.Now()
+
+	record := NewLoginRecord(&AuditEvent{Type: AuditLogin, IP: "203.0.113.7", Details: map[string]string{"method": "github"}, OccurredAt: now})
+	assert.True(t, record.Success)
+	assert.Equal(t, "github", record.Method)
+	assert.Equal(t, now, record.At)
+
+	record = NewLoginRecord(&AuditEvent{Type: AuditLoginFailed, Details: map[string]string{"reason": "wrong password"}})
+	assert.False(t, record.Success)
+	assert.Equal(t, "wrong password", record.Reason)
+}
+
+func TestServiceAccountKeys(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	account := &ServiceAccount{Keys: []*ServiceAccountKey{
+		{ID: "current", Hash: "a"},
+		{ID: "rotated", Hash: "b", ExpiresAt: &future},
+		{ID: "revoked", Hash: "c", ExpiresAt: &past},
+	}}
+
+	var active []string
+	for _, key := range account.ActiveKeys(now) {
+		active = append(active, key.ID)
+	}
+	assert.Equal(t, []string{"current", "rotated"}, active)
+
+	response := account.ForResponse()
+	assert.Empty(t, response.Keys[0].Hash)
+	assert.Equal(t, "a", account.Keys[0].Hash, "original must not be modified")
+	account.Permissions = []string{PermFilesWrite}
+	assert.True(t, account.Role().Has(PermFilesWrite))
+	assert.False(t, account.Role().Has(PermFilesAdmin))
+}
+
+func TestPostRequests(t *testing.T) {
+	post := (&CreatePostRequest{Title: "Hello", Content: "World"}).Post("author")
+	assert.Equal(t, "author", post.UserID)
+	assert.Equal(t, PostStatusDraft, post.Status)
+	assert.Empty(t, post.ID)
+
+	title, status := "Hello again", PostStatusPublished
+	post.Tags = []string{"go"}
+	(&UpdatePostRequest{Title: &title, Status: &status}).Apply(post)
+	assert.Equal(t, "Hello again", post.Title)
+	assert.Equal(t, "World", post.Content)
+	assert.Equal(t, PostStatusPublished, post.Status)
+	assert.Equal(t, []string{"go"}, post.Tags, "omitted tags are kept")
+
+	(&UpdatePostRequest{Tags: []string{}}).Apply(post)
+	assert.Empty(t, post.Tags)
+}
+
+func TestUpdateUserRequestApply(t *testing.T) {
+	user := &User{FirstName: "Ada", LastName: "Lovelace", Role: "user"}
+	last, role := "King", "admin"
+	(&UpdateUserRequest{LastName: &last, Role: &role}).Apply(user)
+
+	assert.Equal(t, "Ada", user.FirstName)
+	assert.Equal(t, "King", user.LastName)
+	assert.Equal(t, "user", user.Role, "roles are changed by the handler")
+}
+
+func TestQueryOptions(t *testing.T) {
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := []*Post{
+		{Title: "beta", Tags: []string{"go"}, Status: PostStatusPublished, CreatedAt: old},
+		{Title: "Alpha", Tags: []string{"rust"}, Status: PostStatusDraft, CreatedAt: old.AddDate(0, 1, 0)},
+		{Title: "gamma", Status: PostStatusPublished, CreatedAt: old.AddDate(0, 2, 0)},
+	}
+
+	opts := QueryOptions{Filters: []Filter{{Field: "status", Op: OpEq, Value: "PUBLISHED"}}}
+	assert.True(t, opts.Matches(posts[0]))
+	assert.False(t, opts.Matches(posts[1]))
+
+	opts = QueryOptions{Filters: []Filter{{Field: "tags", Op: OpIn, Value: []string{"go", "zig"}}}}
+	assert.True(t, opts.Matches(posts[0]))
+	assert.False(t, opts.Matches(posts[2]), "posts without tags have none of them")
+
+	opts = QueryOptions{Filters: []Filter{{Field: "tags", Op: OpNe, Value: "go"}}}
+	assert.False(t, opts.Matches(posts[0]))
+	assert.True(t, opts.Matches(posts[2]))
+
+	opts = QueryOptions{Filters: []Filter{{Field: "createdAt", Op: OpGt, Value: old}, {Field: "title", Op: OpContains, Value: "AL"}}}
+	assert.False(t, opts.Matches(posts[0]))
+	assert.True(t, opts.Matches(posts[1]))
+
+	opts = QueryOptions{Sort: []SortField{{Field: "title"}}}
+	assert.Negative(t, opts.Compare(posts[1], posts[0]), "case is ignored")
+	opts = QueryOptions{Sort: []SortField{{Field: "status"}, {Field: "createdAt", Desc: true}}}
+	assert.Negative(t, opts.Compare(posts[2], posts[0]))
+	assert.Negative(t, opts.Compare(posts[1], posts[2]))
+
+	// Users who never signed in come last
+	login := old
+	users := []*User{{}, {LastLoginAt: &login}}
+	opts = QueryOptions{Sort: []SortField{{Field: "lastLoginAt", Desc: true}}}
+	assert.Positive(t, opts.Compare(users[0], users[1]))
+}
+
+func TestUserPublicProfile(t *testing.T) {
+	user := &User{ID: "u1", Username: "ada", FirstName: "Ada", LastName: "Lovelace", Bio: "Engines"}
+	posts := []*Post{
+		{ID: "p1", UserID: "u1", Title: "Notes", Status: PostStatusPublished},
+		{ID: "p2", UserID: "u1", Status: PostStatusDraft},
+		{ID: "p3", UserID: "u1", Status: PostStatusPublished, OrgID: "org"},
+	}
+
+	assert.Nil(t, user.PublicProfile(posts), "profiles are private by default")
+
+	user.Privacy.Public = true
+	profile := user.PublicProfile(posts)
+	assert.Equal(t, "ada", profile.Username)
+	assert.Equal(t, "Engines", profile.Bio)
+	assert.Empty(t, profile.FirstName)
+	assert.Empty(t, profile.Posts)
+
+	user.Privacy.ShowName = true
+	user.Privacy.ShowPosts = true
+	profile = user.PublicProfile(posts)
+	assert.Equal(t, "Lovelace", profile.LastName)
+	assert.Len(t, profile.Posts, 1)
+	assert.Equal(t, "p1", profile.Posts[0].ID)
+
+	user.Status = UserStatusSuspended
+	assert.Nil(t, user.PublicProfile(posts))
+}
+
+func TestUpdateUserSettingsRequestApply(t *testing.T) {
+	settings := DefaultUserSettings()
+	theme, off := ThemeDark, false
+	(&UpdateUserSettingsRequest{
+		Theme:         &theme,
+		Notifications: &UpdateNotificationPreferences{Digest: &off},
+	}).Apply(settings)
+
+	assert.Equal(t, ThemeDark, settings.Theme)
+	assert.Equal(t, "en", settings.Locale, "omitted fields are kept")
+	assert.Equal(t, PostStatusDraft, settings.DefaultPostStatus)
+	assert.False(t, settings.Notifications.Digest)
+	assert.True(t, settings.Notifications.MentionEmails)
+}
+
+func TestSeriesNavigation(t *testing.T) {
+	series := &Series{ID: "s1", Title: "Engines", PostIDs: []string{"p1", "p2", "p3"}}
+	titles := map[string]string{"p1": "One", "p2": "Two", "p3": "Three"}
+
+	nav := series.Navigation("p2", titles)
+	assert.Equal(t, 2, nav.Position)
+	assert.Equal(t, 3, nav.Total)
+	assert.Equal(t, &SeriesPostRef{ID: "p1", Title: "One"}, nav.Previous)
+	assert.Equal(t, &SeriesPostRef{ID: "p3", Title: "Three"}, nav.Next)
+
+	nav = series.Navigation("p1", titles)
+	assert.Nil(t, nav.Previous)
+	assert.Equal(t, "p2", nav.Next.ID)
+
+	assert.Nil(t, series.Navigation("p4", titles))
+}
+
+func TestMetadataSchemaApply(t *testing.T) {
+	schema := MetadataSchema{
+		Name: "invoices",
+		Fields: map[string]MetadataField{
+			"number":   {Type: MetadataInteger, Required: true},
+			"paid":     {Type: MetadataBoolean},
+			"due":      {Type: MetadataDate},
+			"currency": {Type: MetadataString, Enum: []string{"EUR", "USD"}},
+			"note":     {Type: MetadataString, MaxLength: 5},
+		},
+		Mode: MetadataModeReject,
+	}
+
+	applied, errs := schema.Apply(map[string]string{"number": "42", "paid": "true", "exif.Model": "X100"})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{"number": "42", "paid": "true", "exif.Model": "X100"}, applied)
+
+	_, errs = schema.Apply(map[string]string{"paid": "yes", "due": "May 1", "currency": "eur", "note": "too long", "color": "red"})
+	require.Len(t, errs, 6)
+	rules := make(map[string]string)
+	for _, e := range errs {
+		rules[e.Name] = e.Rule
+	}
+	assert.Equal(t, map[string]string{
+		"metadata.color":    "unknown",
+		"metadata.currency": "oneof",
+		"metadata.due":      "type",
+		"metadata.note":     "max",
+		"metadata.number":   "required",
+		"metadata.paid":     "type",
+	}, rules)
+	assert.Equal(t, "metadata.paid must be true or false", errs[5].Message)
+
+	schema.Mode = MetadataModeCoerce
+	applied, errs = schema.Apply(map[string]string{"number": " 7.0 ", "paid": "Yes", "due": "2024-05-01T10:00:00Z", "currency": "eur", "color": "red", "note": ""})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{"number": "7", "paid": "true", "due": "2024-05-01", "currency": "EUR"}, applied)
+
+	_, errs = schema.Apply(map[string]string{"number": "seven"})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "type", errs[0].Rule)
+
+	schema.AllowUnknown = true
+	applied, errs = schema.Apply(map[string]string{"number": "1", "color": "red"})
+	assert.Empty(t, errs)
+	assert.Equal(t, "red", applied["color"])
+}
+
+func TestMetadataSchemaValidate(t *testing.T) {
+	valid := MetadataSchema{Fields: map[string]MetadataField{"count": {Type: MetadataInteger, Enum: []string{"1", "2"}}}}
+	assert.NoError(t, valid.Validate())
+
+	badEnum := MetadataSchema{Fields: map[string]MetadataField{"count": {Type: MetadataInteger, Enum: []string{"one"}}}}
+	assert.Error(t, badEnum.Validate())
+
+	badLength := MetadataSchema{Fields: map[string]MetadataField{"count": {Type: MetadataInteger, MaxLength: 3}}}
+	assert.Error(t, badLength.Validate())
+
+	reserved := MetadataSchema{Fields: map[string]MetadataField{"exif.Model": {Type: MetadataString}}}
+	assert.Error(t, reserved.Validate())
+}
+
+func TestMetadataSchemaCovers(t *testing.T) {
+	schema := MetadataSchema{Folder: "/invoices"}
+	assert.True(t, schema.Covers("/invoices"))
+	assert.True(t, schema.Covers("/invoices/2024"))
+	assert.False(t, schema.Covers("/invoices-old"))
+	assert.False(t, schema.Covers(""))
+	assert.True(t, (&MetadataSchema{}).Covers("/anything"))
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// QueryOptions sorts and filters a list. MinIO cannot do either, so they
+// are applied to the objects after they are read.
+type QueryOptions struct {
+	Sort    []SortField `json:"sort,omitempty"`
+	Filters []Filter    `json:"filters,omitempty"`
+}
+
+// SortField orders a list by a field, named by its JSON name
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// Filter operators
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpContains = "contains"
+	OpIn       = "in"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+)
+
+// Filter keeps the items whose field compares to Value by Op. Value is a
+// string, int64 or time.Time like the field, or a []string for OpIn.
+// Fields holding a list of strings match if any of their entries does.
+type Filter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// Queryable is a list item that can be sorted and filtered
+type Queryable interface {
+	// QueryField returns a field by its JSON name as a string, []string,
+	// int64 or time.Time, or nil if it is unset or unknown
+	QueryField(name string) any
+}
+
+// IsZero reports whether the options leave a list as it is
+func (o QueryOptions) IsZero() bool {
+	return len(o.Sort) == 0 && len(o.Filters) == 0
+}
+
+// Matches reports whether item passes every filter
+func (o QueryOptions) Matches(item Queryable) bool {
+	for _, filter := range o.Filters {
+		if !filter.matches(item.QueryField(filter.Field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare orders a before b by the sort fields; items without a value
+// come last
+func (o QueryOptions) Compare(a, b Queryable) int {
+	for _, sort := range o.Sort {
+		x, y := a.QueryField(sort.Field), b.QueryField(sort.Field)
+		switch {
+		case x == nil && y == nil:
+			continue
+		case x == nil:
+			return 1
+		case y == nil:
+			return -1
+		}
+		c, _ := compareValues(x, y)
+		if sort.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (f Filter) matches(value any) bool {
+	if values, ok := value.([]string); ok {
+		if f.Op == OpNe {
+			return !(Filter{Field: f.Field, Op: OpEq, Value: f.Value}).matches(values)
+		}
+		for _, entry := range values {
+			if f.matches(entry) {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return f.Op == OpNe
+	}
+
+	switch f.Op {
+	case OpContains:
+		s, ok := value.(string)
+		return ok && strings.Contains(strings.ToLower(s), strings.ToLower(f.Value.(string)))
+	case OpIn:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, candidate := range f.Value.([]string) {
+			if strings.EqualFold(s, candidate) {
+				return true
+			}
+		}
+		return false
+	}
+
+	c, ok := compareValues(value, f.Value)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// compareValues compares two values of the same type; strings compare
+// without regard to case
+func compareValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(strings.ToLower(x), strings.ToLower(y)), true
+		}
+	case int64:
+		if y, ok := b.(int64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	}
+	return 0, false
+}
+
+// QueryField implements Queryable
+func (u *User) QueryField(name string) any {
+	switch name {
+	case "id":
+		return u.ID
+	case "username":
+		return u.Username
+	case "email":
+		return u.Email
+	case "firstName":
+		return u.FirstName
+	case "lastName":
+		return u.LastName
+	case "role":
+		return u.Role
+	case "status":
+		return u.AccountStatus()
+	case "createdAt":
+		return u.CreatedAt
+	case "updatedAt":
+		return u.UpdatedAt
+	case "lastLoginAt":
+		if u.LastLoginAt == nil {
+			return nil
+		}
+		return *u.LastLoginAt
+	}
+	return nil
+}
+
+// QueryField implements Queryable
+func (p *Post) QueryField(name string) any {
+	switch name {
+	case "id":
+		return p.ID
+	case "userId":
+		return p.UserID
+	case "title":
+		return p.Title
+	case "summary":
+		return p.Summary
+	case "tags":
+		return p.Tags
+	case "status":
+		return p.Status
+	case "orgId":
+		return p.OrgID
+	case "createdAt":
+		return p.CreatedAt
+	case "updatedAt":
+		return p.UpdatedAt
+	}
+	return nil
+}
+
+// QueryField implements Queryable
+func (b *Bookmark) QueryField(name string) any {
+	switch name {
+	case "userId":
+		return b.UserID
+	case "postId":
+		return b.PostID
+	case "createdAt":
+		return b.CreatedAt
+	}
+	return nil
+}
+
+// QueryField implements Queryable
+func (m *Mention) QueryField(name string) any {
+	switch name {
+	case "userId":
+		return m.UserID
+	case "authorId":
+		return m.AuthorID
+	case "postId":
+		return m.PostID
+	case "commentId":
+		return m.CommentID
+	case "createdAt":
+		return m.CreatedAt
+	}
+	return nil
+}
+
+// QueryField implements Queryable
+func (f *File) QueryField(name string) any {
+	switch name {
+	case "id":
+		return f.ID
+	case "userId":
+		return f.UserID
+	case "originalName":
+		return f.OriginalName
+	case "contentType":
+		return f.ContentType
+	case "size":
+		return f.Size
+	case "folder":
+		return f.Folder
+	case "visibility":
+		return f.Visibility
+	case "orgId":
+		return f.OrgID
+	case "createdAt":
+		return f.CreatedAt
+	case "updatedAt":
+		return f.UpdatedAt
+	case "expiresAt":
+		if f.ExpiresAt == nil {
+			return nil
+		}
+		return *f.ExpiresAt
+	}
+	return nil
+}
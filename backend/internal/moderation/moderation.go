@@ -0,0 +1,85 @@
+// Package moderation decides which posts and comments are held for review
+// before others see them.
+package moderation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons content is held, as Check reports them. Keyword, link and
+// report reasons carry a detail after a colon, such as "keyword:casino" or
+// "links:7".
+const (
+	ReasonKeyword     = "keyword"
+	ReasonLinks       = "links"
+	ReasonNewAccount  = "new_account"
+	ReasonResubmitted = "resubmitted" // held or rejected before, published again
+	ReasonReports     = "reports"     // reported by as many users as the threshold, e.g. reports:5
+	ReasonSpam        = "spam"        // the spam checker took it for spam
+)
+
+// linkPattern matches web links; a scheme followed by www. is one link
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)\S+`)
+
+// Rules hold content back that contains a keyword, has too many links or
+// comes from a new account
+type Rules struct {
+	keywords   *regexp.Regexp
+	maxLinks   int
+	newAccount time.Duration
+}
+
+// New returns the rules for keywords, matched as whole words regardless of
+// case, more than maxLinks links and accounts younger than newAccount.
+// Zero values turn a rule off; without any rule it returns nil, and nil
+// rules hold nothing.
+func New(keywords []string, maxLinks int, newAccount time.Duration) *Rules {
+	r := &Rules{maxLinks: maxLinks, newAccount: newAccount}
+
+	quoted := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			quoted = append(quoted, regexp.QuoteMeta(strings.ToLower(keyword)))
+		}
+	}
+	if len(quoted) > 0 {
+		r.keywords = regexp.MustCompile(`(?i)(?:^|\W)(` + strings.Join(quoted, "|") + `)(?:\W|$)`)
+	}
+
+	if r.keywords == nil && maxLinks <= 0 && newAccount <= 0 {
+		return nil
+	}
+	return r
+}
+
+// Check returns why content by an author who signed up at joined is held
+// at now, or nothing when it may be shown
+func (r *Rules) Check(content string, joined, now time.Time) []string {
+	if r == nil {
+		return nil
+	}
+
+	var reasons []string
+	if r.keywords != nil {
+		seen := make(map[string]bool)
+		for _, match := range r.keywords.FindAllStringSubmatch(content, -1) {
+			keyword := strings.ToLower(match[1])
+			if !seen[keyword] {
+				seen[keyword] = true
+				reasons = append(reasons, ReasonKeyword+":"+keyword)
+			}
+		}
+	}
+	if r.maxLinks > 0 {
+		if links := len(linkPattern.FindAllString(content, -1)); links > r.maxLinks {
+			reasons = append(reasons, ReasonLinks+":"+strconv.Itoa(links))
+		}
+	}
+	if r.newAccount > 0 && now.Sub(joined) < r.newAccount {
+		reasons = append(reasons, ReasonNewAccount)
+	}
+	return reasons
+}
@@ -0,0 +1,32 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(nil, 0, 0))
+	assert.Nil(t, New([]string{" ", ""}, 0, 0))
+	assert.Nil(t, (*Rules)(nil).Check("cheap casino", time.Time{}, time.Now()), "nil rules hold nothing")
+	assert.NotNil(t, New(nil, 3, 0))
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	joined := now.AddDate(-1, 0, 0)
+	rules := New([]string{"Casino", "free $$$"}, 2, 24*time.Hour)
+
+	assert.Empty(t, rules.Check("A post about engines", joined, now))
+	assert.Equal(t, []string{"keyword:casino"}, rules.Check("Visit the CASINO, casino!", joined, now))
+	assert.Equal(t, []string{"keyword:free $$$"}, rules.Check("get free $$$ now", joined, now))
+	assert.Empty(t, rules.Check("casinos and occasional", joined, now), "keywords match whole words")
+
+	links := "https://a.example http://www.b.example www.c.example"
+	assert.Equal(t, []string{"links:3"}, rules.Check(links, joined, now))
+	assert.Empty(t, rules.Check("https://a.example www.b.example", joined, now))
+
+	assert.Equal(t, []string{ReasonNewAccount}, rules.Check("hello", now.Add(-time.Hour), now))
+}
@@ -0,0 +1,189 @@
+// Package notifications keeps the in-app notifications of each user in
+// Redis, newest first, with the ones not read yet counted for the badge of
+// the frontend. Notifications are raised from domain events; see FromEvent.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// A user keeps the last maxPerUser notifications for up to retention
+const (
+	maxPerUser = 200
+	retention  = 90 * 24 * time.Hour
+)
+
+const keyPrefix = "notifications:"
+
+var ErrNotFound = errors.New("notification not found")
+
+// addScript stores notification ARGV[1] with JSON ARGV[2] at time ARGV[3]
+// (ms) in the index KEYS[1], the items KEYS[2] and the unread KEYS[3],
+// unless it is stored already. Notifications beyond the newest ARGV[4] or
+// older than ARGV[5] (ms) are dropped, and the keys expire ARGV[6] ms
+// after the last one was added.
+var addScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[2], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+redis.call("ZADD", KEYS[3], ARGV[3], ARGV[1])
+
+local dropped = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[5])
+for _, id in ipairs(redis.call("ZRANGE", KEYS[1], 0, -tonumber(ARGV[4]) - 1)) do
+	table.insert(dropped, id)
+end
+for _, id in ipairs(dropped) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("ZREM", KEYS[3], id)
+	redis.call("HDEL", KEYS[2], id)
+end
+
+for _, key in ipairs(KEYS) do
+	redis.call("PEXPIRE", key, ARGV[6])
+end
+return 1
+`)
+
+// Store holds the notifications of every user
+type Store struct {
+	redis *redis.Client
+}
+
+func New(client *redis.Client) *Store {
+	return &Store{redis: client}
+}
+
+// Add stores n as unread. Adding a notification with the ID of one stored
+// already does nothing and returns false, so an event handled twice
+// notifies once.
+func (s *Store) Add(ctx context.Context, n *models.Notification) (bool, error) {
+	stored := *n
+	stored.Read = false
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	cutoff := time.Now().Add(-retention)
+	added, err := addScript.Run(ctx, s.redis, keys(n.UserID), n.ID, data, n.CreatedAt.UnixMilli(), maxPerUser, cutoff.UnixMilli(), retention.Milliseconds()).Bool()
+	if err != nil {
+		return false, fmt.Errorf("failed to add notification: %w", err)
+	}
+	return added, nil
+}
+
+// List returns a page of the notifications of userID, newest first, or
+// of the unread ones only, with how many there are in all
+func (s *Store) List(ctx context.Context, userID string, unreadOnly bool, offset, limit int) ([]*models.Notification, int64, error) {
+	k := keys(userID)
+	index := k[0]
+	if unreadOnly {
+		index = k[2]
+	}
+
+	var ids *redis.StringSliceCmd
+	var total *redis.IntCmd
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		ids = pipe.ZRevRange(ctx, index, int64(offset), int64(offset+limit-1))
+		total = pipe.ZCard(ctx, index)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	notifications := []*models.Notification{}
+	if len(ids.Val()) == 0 {
+		return notifications, total.Val(), nil
+	}
+
+	var items *redis.SliceCmd
+	unread := make([]*redis.FloatCmd, len(ids.Val()))
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		items = pipe.HMGet(ctx, k[1], ids.Val()...)
+		for i, id := range ids.Val() {
+			unread[i] = pipe.ZScore(ctx, k[2], id)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, fmt.Errorf("failed to get notifications: %w", err)
+	}
+
+	for i, item := range items.Val() {
+		data, ok := item.(string)
+		if !ok {
+			continue
+		}
+		var n models.Notification
+		if err := json.Unmarshal([]byte(data), &n); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal notification: %w", err)
+		}
+		n.Read = errors.Is(unread[i].Err(), redis.Nil)
+		notifications = append(notifications, &n)
+	}
+	return notifications, total.Val(), nil
+}
+
+// MarkRead marks the notification id of userID as read
+func (s *Store) MarkRead(ctx context.Context, userID, id string) error {
+	k := keys(userID)
+	exists, err := s.redis.HExists(ctx, k[1], id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get notification: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	if err := s.redis.ZRem(ctx, k[2], id).Err(); err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRead marks every notification of userID as read and returns how
+// many were unread
+func (s *Store) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	unread := keys(userID)[2]
+	var count *redis.IntCmd
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.ZCard(ctx, unread)
+		pipe.Del(ctx, unread)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return count.Val(), nil
+}
+
+// UnreadCount returns how many notifications of userID are unread
+func (s *Store) UnreadCount(ctx context.Context, userID string) (int64, error) {
+	count, err := s.redis.ZCard(ctx, keys(userID)[2]).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteUser deletes every notification of userID
+func (s *Store) DeleteUser(ctx context.Context, userID string) error {
+	if err := s.redis.Del(ctx, keys(userID)...).Err(); err != nil {
+		return fmt.Errorf("failed to delete notifications: %w", err)
+	}
+	return nil
+}
+
+// keys returns the index, items and unread keys of userID
+func keys(userID string) []string {
+	prefix := keyPrefix + userID
+	return []string{prefix, prefix + ":items", prefix + ":unread"}
+}
@@ -0,0 +1,213 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) *Store {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client)
+}
+
+func testNotification(id, userID string, createdAt time.Time) *models.Notification {
+	return &models.Notification{
+		ID:        id,
+		UserID:    userID,
+		Type:      models.NotificationFileQuarantined,
+		Payload:   map[string]string{"fileId": id},
+		CreatedAt: createdAt,
+	}
+}
+
+func ids(list []*models.Notification) []string {
+	result := make([]string, len(list))
+	for i, n := range list {
+		result[i] = n.ID
+	}
+	return result
+}
+
+func TestAddAndList(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for i, id := range []string{"n1", "n2", "n3"} {
+		added, err := store.Add(ctx, testNotification(id, "u1", now.Add(time.Duration(i)*time.Minute)))
+		require.NoError(t, err)
+		assert.True(t, added)
+	}
+	_, err := store.Add(ctx, testNotification("other", "u2", now))
+	require.NoError(t, err)
+
+	// The same notification is stored once
+	added, err := store.Add(ctx, testNotification("n2", "u1", now.Add(time.Hour)))
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	list, total, err := store.List(ctx, "u1", false, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []string{"n3", "n2", "n1"}, ids(list))
+	assert.False(t, list[0].Read)
+	assert.Equal(t, "n3", list[0].Payload["fileId"])
+	assert.Equal(t, now.Add(2*time.Minute), list[0].CreatedAt)
+
+	list, total, err = store.List(ctx, "u1", false, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []string{"n2"}, ids(list))
+
+	list, _, err = store.List(ctx, "nobody", false, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestMarkRead(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, id := range []string{"n1", "n2", "n3"} {
+		_, err := store.Add(ctx, testNotification(id, "u1", now.Add(time.Duration(i)*time.Second)))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.MarkRead(ctx, "u1", "n2"))
+	assert.ErrorIs(t, store.MarkRead(ctx, "u1", "missing"), ErrNotFound)
+	assert.ErrorIs(t, store.MarkRead(ctx, "u2", "n1"), ErrNotFound)
+
+	unread, err := store.UnreadCount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), unread)
+
+	list, _, err := store.List(ctx, "u1", false, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, true, false}, []bool{list[0].Read, list[1].Read, list[2].Read})
+
+	list, total, err := store.List(ctx, "u1", true, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"n3", "n1"}, ids(list))
+
+	marked, err := store.MarkAllRead(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), marked)
+	unread, err = store.UnreadCount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Zero(t, unread)
+
+	// Read notifications are still listed
+	_, total, err = store.List(ctx, "u1", false, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestLimits(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := store.Add(ctx, testNotification("stale", "u1", now.Add(-retention-time.Hour)))
+	require.NoError(t, err)
+	for i := 0; i < maxPerUser+5; i++ {
+		_, err := store.Add(ctx, testNotification(fmt.Sprintf("n%03d", i), "u1", now.Add(time.Duration(i)*time.Second)))
+		require.NoError(t, err)
+	}
+
+	list, total, err := store.List(ctx, "u1", false, 0, maxPerUser+10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(maxPerUser), total)
+	assert.Len(t, list, maxPerUser)
+	assert.Equal(t, fmt.Sprintf("n%03d", maxPerUser+4), list[0].ID)
+	assert.Equal(t, "n005", list[maxPerUser-1].ID)
+
+	unread, err := store.UnreadCount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(maxPerUser), unread)
+}
+
+func TestDeleteUser(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.Add(ctx, testNotification("n1", "u1", time.Now()))
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteUser(ctx, "u1"))
+
+	_, total, err := store.List(ctx, "u1", false, 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	unread, err := store.UnreadCount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Zero(t, unread)
+}
+
+func TestFromEvent(t *testing.T) {
+	occurredAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data := func(file events.File) json.RawMessage {
+		raw, _ := json.Marshal(file)
+		return raw
+	}
+
+	raised, err := FromEvent(events.FileUpdated, data(events.File{ID: "f1", UserID: "u1", OriginalName: "setup.exe", ScanStatus: models.ScanStatusInfected}), occurredAt)
+	require.NoError(t, err)
+	require.Len(t, raised, 1)
+	assert.Equal(t, &models.Notification{
+		ID:        "file.quarantined:f1",
+		UserID:    "u1",
+		Type:      models.NotificationFileQuarantined,
+		Payload:   map[string]string{"fileId": "f1", "fileName": "setup.exe"},
+		CreatedAt: occurredAt,
+	}, raised[0])
+
+	raised, err = FromEvent(events.FileUpdated, data(events.File{ID: "f1", UserID: "u1", ScanStatus: models.ScanStatusClean}), occurredAt)
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+
+	comment := func(userID, status string) json.RawMessage {
+		raw, _ := json.Marshal(events.Comment{ID: "c1", PostID: "p1", PostUserID: "u1", UserID: userID, Status: status})
+		return raw
+	}
+	raised, err = FromEvent(events.CommentCreated, comment("u2", models.CommentStatusVisible), occurredAt)
+	require.NoError(t, err)
+	require.Len(t, raised, 1)
+	assert.Equal(t, &models.Notification{
+		ID:        "comment:c1",
+		UserID:    "u1",
+		Type:      models.NotificationCommented,
+		Payload:   map[string]string{"postId": "p1", "commentId": "c1", "authorId": "u2"},
+		CreatedAt: occurredAt,
+	}, raised[0])
+
+	// Held comments notify once approved; own comments never
+	raised, err = FromEvent(events.CommentCreated, comment("u2", models.CommentStatusPending), occurredAt)
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+	raised, err = FromEvent(events.CommentModerated, comment("u2", models.CommentStatusVisible), occurredAt)
+	require.NoError(t, err)
+	assert.Equal(t, "comment:c1", raised[0].ID)
+	raised, err = FromEvent(events.CommentCreated, comment("u1", models.CommentStatusVisible), occurredAt)
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+
+	raised, err = FromEvent(events.PostCreated, json.RawMessage(`{"id":"p1"}`), occurredAt)
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+
+	_, err = FromEvent(events.FileUpdated, json.RawMessage(`"not a file"`), occurredAt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// rule returns the notifications an event of its type raises
+type rule func(data json.RawMessage, occurredAt time.Time) ([]*models.Notification, error)
+
+// rules holds the event types that notify someone. Notification IDs are
+// derived from what they are about rather than from the event, so the
+// same news is not repeated by later events.
+var rules = map[string]rule{
+	events.FileUpdated:      fileUpdated,
+	events.MentionCreated:   mentionCreated,
+	events.CommentCreated:   commentShown,
+	events.CommentModerated: commentShown,
+}
+
+// FromEvent returns the notifications an event raises, none for most
+func FromEvent(eventType string, data json.RawMessage, occurredAt time.Time) ([]*models.Notification, error) {
+	r, ok := rules[eventType]
+	if !ok {
+		return nil, nil
+	}
+	notifications, err := r(data, occurredAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", eventType, err)
+	}
+	return notifications, nil
+}
+
+// fileUpdated tells the owner of a file that a virus scan quarantined it
+func fileUpdated(data json.RawMessage, occurredAt time.Time) ([]*models.Notification, error) {
+	var file events.File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.ScanStatus != models.ScanStatusInfected || file.UserID == "" {
+		return nil, nil
+	}
+	return []*models.Notification{{
+		ID:        models.NotificationFileQuarantined + ":" + file.ID,
+		UserID:    file.UserID,
+		Type:      models.NotificationFileQuarantined,
+		Payload:   map[string]string{"fileId": file.ID, "fileName": file.OriginalName},
+		CreatedAt: occurredAt,
+	}}, nil
+}
+
+// commentShown tells the author of a post that someone else commented on
+// it, once the comment is visible: when it is created or, if it was held,
+// approved
+func commentShown(data json.RawMessage, occurredAt time.Time) ([]*models.Notification, error) {
+	var comment events.Comment
+	if err := json.Unmarshal(data, &comment); err != nil {
+		return nil, err
+	}
+	if comment.Status != models.CommentStatusVisible || comment.PostUserID == "" || comment.PostUserID == comment.UserID {
+		return nil, nil
+	}
+	return []*models.Notification{{
+		ID:        models.NotificationCommented + ":" + comment.ID,
+		UserID:    comment.PostUserID,
+		Type:      models.NotificationCommented,
+		Payload:   map[string]string{"postId": comment.PostID, "commentId": comment.ID, "authorId": comment.UserID},
+		CreatedAt: occurredAt,
+	}}, nil
+}
+
+// mentionCreated tells a user that a post or comment mentioned them
+func mentionCreated(data json.RawMessage, occurredAt time.Time) ([]*models.Notification, error) {
+	var mention events.Mention
+	if err := json.Unmarshal(data, &mention); err != nil {
+		return nil, err
+	}
+	source := mention.CommentID
+	if source == "" {
+		source = "post"
+	}
+	return []*models.Notification{{
+		ID:        models.NotificationMentioned + ":" + mention.PostID + ":" + source + ":" + mention.UserID,
+		UserID:    mention.UserID,
+		Type:      models.NotificationMentioned,
+		Payload:   map[string]string{"postId": mention.PostID, "commentId": mention.CommentID, "authorId": mention.AuthorID},
+		CreatedAt: occurredAt,
+	}}, nil
+}
@@ -0,0 +1,174 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+var ErrEmailNotVerified = errors.New("provider account has no verified email")
+
+// Identity is the account a user signed in with at a provider
+type Identity struct {
+	Provider  string
+	Subject   string // stable account ID at the provider
+	Email     string // verified address only
+	Username  string // login name when the provider has one
+	FirstName string
+	LastName  string
+}
+
+// Provider runs the authorization code flow with PKCE against one OAuth2
+// provider and reads the signed-in account from its user API
+type Provider struct {
+	name        string
+	config      *oauth2.Config
+	userInfoURL string
+	identity    func(ctx context.Context, client *http.Client, userInfoURL string) (*Identity, error)
+}
+
+// NewGoogle signs in with Google accounts through OpenID Connect
+func NewGoogle(clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		name: "google",
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.Google,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+		userInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		identity:    googleIdentity,
+	}
+}
+
+// NewGitHub signs in with GitHub accounts
+func NewGitHub(clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		name: "github",
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.GitHub,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+		userInfoURL: "https://api.github.com",
+		identity:    githubIdentity,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL is where the browser is sent to sign in. The verifier must be
+// kept until the callback.
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the callback code for a token and loads the identity it
+// belongs to
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	identity, err := p.identity(ctx, p.config.Client(ctx, token), p.userInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	identity.Provider = p.name
+	return identity, nil
+}
+
+func googleIdentity(ctx context.Context, client *http.Client, userInfoURL string) (*Identity, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+	}
+	if err := getJSON(ctx, client, userInfoURL, &info); err != nil {
+		return nil, err
+	}
+	if info.Email == "" || !info.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	return &Identity{
+		Subject:   info.Sub,
+		Email:     info.Email,
+		FirstName: info.GivenName,
+		LastName:  info.FamilyName,
+	}, nil
+}
+
+func githubIdentity(ctx context.Context, client *http.Client, apiURL string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/user", &user); err != nil {
+		return nil, err
+	}
+
+	// The public profile email may be unverified, so use the primary
+	// address from the verified list
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Username:  user.Login,
+		FirstName: user.Name,
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			identity.Email = email.Email
+		}
+	}
+	if identity.Email == "" {
+		return nil, ErrEmailNotVerified
+	}
+	return identity, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to load user info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to load user info: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode user info: %w", err)
+	}
+	return nil
+}
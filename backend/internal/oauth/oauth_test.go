@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newTestProvider points p at a fake provider serving the token endpoint
+// and the given user API responses
+func newTestProvider(t *testing.T, p *Provider, responses map[string]any) *Provider {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "code-1", r.Form.Get("code"))
+		assert.Equal(t, "verifier-1", r.Form.Get("code_verifier"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access-1", "token_type": "Bearer"})
+	})
+	for path, body := range responses {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer access-1", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(body)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	p.config.Endpoint = oauth2.Endpoint{TokenURL: server.URL + "/token", AuthStyle: oauth2.AuthStyleInParams}
+	p.userInfoURL = server.URL
+	if p.name == "google" {
+		p.userInfoURL = server.URL + "/userinfo"
+	}
+	return p
+}
+
+func TestGoogleExchange(t *testing.T) {
+	p := newTestProvider(t, NewGoogle("id", "secret", "http://localhost/callback"), map[string]any{
+		"/userinfo": map[string]any{"sub": "123", "email": "ada@example.com", "email_verified": true, "given_name": "Ada", "family_name": "Lovelace"},
+	})
+
+	identity, err := p.Exchange(context.Background(), "code-1", "verifier-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "google", Subject: "123", Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace"}, identity)
+}
+
+func TestGoogleExchange_UnverifiedEmail(t *testing.T) {
+	p := newTestProvider(t, NewGoogle("id", "secret", "http://localhost/callback"), map[string]any{
+		"/userinfo": map[string]any{"sub": "123", "email": "ada@example.com", "email_verified": false},
+	})
+
+	_, err := p.Exchange(context.Background(), "code-1", "verifier-1")
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+}
+
+func TestGitHubExchange(t *testing.T) {
+	p := newTestProvider(t, NewGitHub("id", "secret", "http://localhost/callback"), map[string]any{
+		"/user": map[string]any{"id": 42, "login": "octocat", "name": "The Octocat"},
+		"/user/emails": []map[string]any{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octo@example.com", "primary": true, "verified": true},
+		},
+	})
+
+	identity, err := p.Exchange(context.Background(), "code-1", "verifier-1")
+	require.NoError(t, err)
+	assert.Equal(t, "github", identity.Provider)
+	assert.Equal(t, "42", identity.Subject)
+	assert.Equal(t, "octocat", identity.Username)
+	assert.Equal(t, "octo@example.com", identity.Email)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p := NewGitHub("client-1", "secret", "http://localhost/callback")
+	url := p.AuthCodeURL("state-1", oauth2.GenerateVerifier())
+	assert.Contains(t, url, "client_id=client-1")
+	assert.Contains(t, url, "state=state-1")
+	assert.Contains(t, url, "code_challenge_method=S256")
+}
@@ -0,0 +1,32 @@
+// Package openapi describes the API as OpenAPI 3. swag turns the
+// annotations of the handlers into a Swagger 2.0 document (see docs),
+// which is converted here when the server starts.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/minio-fullstack-storage/backend/docs"
+)
+
+// Document returns the OpenAPI 3 document of the API as JSON. Its server is
+// the base path of the API rather than a host, so the document is right on
+// whichever host serves it.
+func Document() ([]byte, error) {
+	var swagger openapi2.T
+	if err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &swagger); err != nil {
+		return nil, fmt.Errorf("failed to parse the Swagger document: %w", err)
+	}
+
+	doc, err := openapi2conv.ToV3(&swagger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the Swagger document: %w", err)
+	}
+	doc.Servers = openapi3.Servers{{URL: docs.SwaggerInfo.BasePath}}
+
+	return json.Marshal(doc)
+}
@@ -0,0 +1,33 @@
+package openapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	data, err := Document()
+	require.NoError(t, err)
+
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Len(t, doc.Servers, 1)
+	assert.Equal(t, "/api/v1", doc.Servers[0].URL)
+	assert.Contains(t, doc.Components.SecuritySchemes, "BearerAuth")
+
+	// Operations keep their parameters, bodies and responses
+	list := doc.Paths.Find("/posts").Get
+	require.NotNil(t, list)
+	assert.NotNil(t, list.Responses.Status(200))
+	create := doc.Paths.Find("/posts").Post
+	require.NotNil(t, create)
+	require.NotNil(t, create.RequestBody)
+	assert.Contains(t, create.RequestBody.Value.Content, "application/json")
+}
@@ -0,0 +1,249 @@
+// Package outbox keeps domain events in Redis until NATS has them, so the
+// events of writes made while NATS is down are delivered once it is back
+// rather than lost.
+//
+// The storage layer prepares each event before the write it reports, so
+// that it is kept even if the server stops right after the write, and
+// hands it to the outbox again once the write succeeded. The outbox then
+// publishes it right away and forgets it once JetStream acknowledged it;
+// events that could not be published stay until the relay gets them
+// through. Prepared events whose server stopped before the write finished
+// are published by the relay if the reconciler finds that the write
+// happened, and dropped otherwise. Delivery
+// is at least once: an event may be published twice when a server stops
+// between publishing and forgetting it, and consumers already expect that,
+// as JetStream only drops duplicates within two minutes.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix   = "outbox:"
+	eventsKey   = keyPrefix + "events"   // event JSON by ID
+	pendingKey  = keyPrefix + "pending"  // event IDs scored by when they were added
+	preparedKey = keyPrefix + "prepared" // IDs of events whose write may not have happened, scored the same way
+	relayKey    = keyPrefix + "relay"    // held by the server relaying
+)
+
+// relayBatch is how many events the relay loads at a time
+const relayBatch = 100
+
+// unlockScript gives up the relay lock in KEYS[1] if ARGV[1] still holds
+// it, rather than the lock of a server that took over after it expired
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Reconciler tells whether the write a prepared event reports happened
+type Reconciler interface {
+	EventWritten(ctx context.Context, event *events.Event) (bool, error)
+}
+
+// Outbox is an events.Preparer that stores every event before
+// publishing it with publisher
+type Outbox struct {
+	redis      *redis.Client
+	publisher  events.Publisher
+	reconciler Reconciler
+}
+
+func New(client *redis.Client, publisher events.Publisher, reconciler Reconciler) *Outbox {
+	return &Outbox{redis: client, publisher: publisher, reconciler: reconciler}
+}
+
+// PrepareEvent stores event before the write it reports. It is not
+// published until it is passed to PublishEvent once the write succeeded,
+// or found written by the relay.
+func (o *Outbox) PrepareEvent(ctx context.Context, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = o.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, eventsKey, event.ID, data)
+		pipe.ZAdd(ctx, preparedKey, redis.Z{Score: float64(time.Now().UnixMilli()), Member: event.ID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store event in outbox: %w", err)
+	}
+	return nil
+}
+
+// DiscardEvent forgets a prepared event whose write failed
+func (o *Outbox) DiscardEvent(ctx context.Context, id string) {
+	o.remove(ctx, id)
+}
+
+// PublishEvent stores event and publishes it. An event that was stored
+// counts as delivered for the caller, since the relay publishes it when
+// publishing now fails. Only when it cannot be stored either is an error
+// returned.
+func (o *Outbox) PublishEvent(ctx context.Context, event *events.Event) error {
+	if err := o.add(ctx, event); err != nil {
+		// Publishing without the outbox is the best left to do
+		if pubErr := o.publisher.PublishEvent(ctx, event); pubErr != nil {
+			return fmt.Errorf("%w; %w", err, pubErr)
+		}
+		return nil
+	}
+
+	if err := o.publisher.PublishEvent(ctx, event); err != nil {
+		return nil
+	}
+	// Publishing again later would be harmless, so failing to forget the
+	// event is too
+	o.remove(ctx, event.ID)
+	return nil
+}
+
+// Relay publishes the stored events older than minAge in the order they
+// were added, and returns how many it published. Younger events are
+// probably being published by the write that stored them. Prepared events
+// that old are published if their write happened and dropped if it did
+// not. It stops at the first event that fails to publish, as NATS is
+// likely still down. Only one server relays at a time; the others return
+// right away.
+func (o *Outbox) Relay(ctx context.Context, minAge, lockTTL time.Duration) (int, error) {
+	token := uuid.NewString()
+	locked, err := o.redis.SetNX(ctx, relayKey, token, lockTTL).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock outbox relay: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer unlockScript.Run(context.WithoutCancel(ctx), o.redis, []string{relayKey}, token)
+
+	published, err := o.relay(ctx, pendingKey, minAge)
+	if err != nil {
+		return published, err
+	}
+	reconciled, err := o.relay(ctx, preparedKey, minAge)
+	return published + reconciled, err
+}
+
+// relay publishes the events listed in key that are older than minAge
+func (o *Outbox) relay(ctx context.Context, key string, minAge time.Duration) (int, error) {
+	published := 0
+	for {
+		ids, err := o.redis.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(time.Now().Add(-minAge).UnixMilli(), 10),
+			Count: relayBatch,
+		}).Result()
+		if err != nil {
+			return published, fmt.Errorf("failed to list outbox events: %w", err)
+		}
+		if len(ids) == 0 {
+			return published, nil
+		}
+
+		values, err := o.redis.HMGet(ctx, eventsKey, ids...).Result()
+		if err != nil {
+			return published, fmt.Errorf("failed to load outbox events: %w", err)
+		}
+		for i, value := range values {
+			event, ok := decode(value)
+			if !ok {
+				o.remove(ctx, ids[i])
+				continue
+			}
+			if key == preparedKey {
+				written, err := o.reconciler.EventWritten(ctx, event)
+				if err != nil {
+					return published, fmt.Errorf("failed to reconcile outbox event: %w", err)
+				}
+				if !written {
+					o.remove(ctx, ids[i])
+					continue
+				}
+			}
+			if err := o.publisher.PublishEvent(ctx, event); err != nil {
+				return published, err
+			}
+			o.remove(ctx, ids[i])
+			published++
+		}
+
+		if len(ids) < relayBatch {
+			return published, nil
+		}
+	}
+}
+
+// Pending returns the number of stored events and when the oldest was
+// added, or the zero time when there are none
+func (o *Outbox) Pending(ctx context.Context) (int64, time.Time, error) {
+	count, err := o.redis.ZCard(ctx, pendingKey).Result()
+	if err != nil || count == 0 {
+		return 0, time.Time{}, err
+	}
+	oldest, err := o.redis.ZRangeWithScores(ctx, pendingKey, 0, 0).Result()
+	if err != nil || len(oldest) == 0 {
+		return count, time.Time{}, err
+	}
+	return count, time.UnixMilli(int64(oldest[0].Score)), nil
+}
+
+func (o *Outbox) add(ctx context.Context, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// A prepared event is now known to be written
+	_, err = o.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, eventsKey, event.ID, data)
+		pipe.ZAdd(ctx, pendingKey, redis.Z{Score: float64(time.Now().UnixMilli()), Member: event.ID})
+		pipe.ZRem(ctx, preparedKey, event.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store event in outbox: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) remove(ctx context.Context, id string) {
+	ctx = context.WithoutCancel(ctx)
+	o.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, pendingKey, id)
+		pipe.ZRem(ctx, preparedKey, id)
+		pipe.HDel(ctx, eventsKey, id)
+		return nil
+	})
+}
+
+// decode turns a stored event back into one to publish. Its data is
+// published as it was stored.
+func decode(value any) (*events.Event, bool) {
+	data, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	var stored struct {
+		events.Event
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, false
+	}
+	event := stored.Event
+	event.Data = stored.Data
+	return &event, true
+}
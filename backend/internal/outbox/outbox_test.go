@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher records what it published and fails while down
+type fakePublisher struct {
+	down      bool
+	published []string
+	publish   func() // called before each event is published
+}
+
+func (p *fakePublisher) PublishEvent(ctx context.Context, event *events.Event) error {
+	if p.publish != nil {
+		p.publish()
+	}
+	if p.down {
+		return errors.New("nats: no servers available")
+	}
+	data, _ := json.Marshal(event.Data)
+	p.published = append(p.published, event.ID+" "+string(data))
+	return nil
+}
+
+// fakeReconciler finds the writes of the events it holds
+type fakeReconciler map[string]bool
+
+func (r fakeReconciler) EventWritten(ctx context.Context, event *events.Event) (bool, error) {
+	return r[event.ID], nil
+}
+
+func newTestOutbox(t *testing.T) (*Outbox, *fakePublisher, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	publisher := &fakePublisher{}
+	return New(client, publisher, fakeReconciler{"written": true}), publisher, server
+}
+
+func testEvent(id string) *events.Event {
+	event := events.New(events.PostCreated, events.Post{ID: "p1", UserID: "u1"})
+	event.ID = id
+	return event
+}
+
+func TestPublishEvent(t *testing.T) {
+	box, publisher, _ := newTestOutbox(t)
+	ctx := context.Background()
+
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e1")))
+	assert.Equal(t, []string{`e1 {"id":"p1","userId":"u1"}`}, publisher.published)
+
+	pending, _, err := box.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+func TestRelay(t *testing.T) {
+	box, publisher, _ := newTestOutbox(t)
+	ctx := context.Background()
+
+	// Events of writes made while NATS is down are kept
+	publisher.down = true
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e1")))
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e2")))
+	pending, oldest, err := box.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pending)
+	assert.WithinDuration(t, time.Now(), oldest, time.Minute)
+
+	published, err := box.Relay(ctx, 0, time.Minute)
+	assert.Error(t, err)
+	assert.Zero(t, published)
+
+	// Young events are left to their write
+	publisher.down = false
+	published, err = box.Relay(ctx, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+
+	published, err = box.Relay(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{`e1 {"id":"p1","userId":"u1"}`, `e2 {"id":"p1","userId":"u1"}`}, publisher.published)
+
+	pending, _, err = box.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+func TestPrepareEvent(t *testing.T) {
+	box, publisher, server := newTestOutbox(t)
+	ctx := context.Background()
+
+	// Events are published once their write succeeded
+	require.NoError(t, box.PrepareEvent(ctx, testEvent("e1")))
+	assert.Empty(t, publisher.published)
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e1")))
+	assert.Len(t, publisher.published, 1)
+
+	// and forgotten if it failed
+	require.NoError(t, box.PrepareEvent(ctx, testEvent("e2")))
+	box.DiscardEvent(ctx, "e2")
+	assert.Empty(t, server.Keys())
+
+	// The server stopped between the write and publishing its event
+	require.NoError(t, box.PrepareEvent(ctx, testEvent("written")))
+	require.NoError(t, box.PrepareEvent(ctx, testEvent("lost")))
+	published, err := box.Relay(ctx, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, published, "the writes may still be running")
+
+	published, err = box.Relay(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, `written {"id":"p1","userId":"u1"}`, publisher.published[1])
+	assert.Empty(t, server.Keys())
+}
+
+func TestRelayLocked(t *testing.T) {
+	box, publisher, server := newTestOutbox(t)
+	ctx := context.Background()
+
+	publisher.down = true
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e1")))
+	publisher.down = false
+
+	// Another server is relaying
+	require.NoError(t, server.Set(relayKey, "1"))
+	published, err := box.Relay(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+
+	server.Del(relayKey)
+	published, err = box.Relay(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.False(t, server.Exists(relayKey))
+
+	// A relay whose lock expired leaves the lock of the server that took
+	// over alone
+	publisher.down = true
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e2")))
+	publisher.down = false
+	publisher.publish = func() { require.NoError(t, server.Set(relayKey, "other")) }
+	published, err = box.Relay(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	lock, err := server.Get(relayKey)
+	require.NoError(t, err)
+	assert.Equal(t, "other", lock)
+}
+
+func TestPublishEventWithoutRedis(t *testing.T) {
+	box, publisher, server := newTestOutbox(t)
+	ctx := context.Background()
+	server.Close()
+
+	// The event is still published directly
+	require.NoError(t, box.PublishEvent(ctx, testEvent("e1")))
+	assert.Len(t, publisher.published, 1)
+
+	publisher.down = true
+	assert.Error(t, box.PublishEvent(ctx, testEvent("e2")))
+}
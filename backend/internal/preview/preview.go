@@ -0,0 +1,130 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ContentType of every generated preview
+const ContentType = "image/png"
+
+// officeTypes are converted to PDF with LibreOffice before rendering
+var officeTypes = map[string]string{
+	"application/msword": ".doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/vnd.ms-excel": ".xls",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.ms-powerpoint":                                             ".ppt",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/vnd.oasis.opendocument.text":                                   ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":                            ".ods",
+	"application/vnd.oasis.opendocument.presentation":                           ".odp",
+	"application/rtf": ".rtf",
+}
+
+// IsSupported reports whether a preview can be rendered for contentType
+func IsSupported(contentType string) bool {
+	if contentType == "application/pdf" {
+		return true
+	}
+	_, ok := officeTypes[contentType]
+	return ok
+}
+
+// Renderer renders the first page of documents to PNG using poppler's
+// pdftoppm and, for office formats, a headless LibreOffice
+type Renderer struct {
+	pdftoppm    string
+	libreOffice string
+	size        int
+}
+
+func NewRenderer(pdftoppmPath, libreOfficePath string, size int) *Renderer {
+	return &Renderer{
+		pdftoppm:    pdftoppmPath,
+		libreOffice: libreOfficePath,
+		size:        size,
+	}
+}
+
+// Render writes r to a scratch directory and returns the first page as PNG
+// with its longest edge scaled to the renderer size
+func (r *Renderer) Render(ctx context.Context, content io.Reader, contentType string) ([]byte, error) {
+	if !IsSupported(contentType) {
+		return nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+
+	dir, err := os.MkdirTemp("", "preview-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ext := ".pdf"
+	if officeExt, ok := officeTypes[contentType]; ok {
+		ext = officeExt
+	}
+	source := filepath.Join(dir, "source"+ext)
+	if err := writeFile(source, content); err != nil {
+		return nil, err
+	}
+
+	pdf := source
+	if ext != ".pdf" {
+		if pdf, err = r.convertToPDF(ctx, dir, source); err != nil {
+			return nil, err
+		}
+	}
+
+	output := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, r.pdftoppm,
+		"-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", strconv.Itoa(r.size),
+		pdf, output,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pdftoppm failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	data, err := os.ReadFile(output + ".png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered page: %w", err)
+	}
+	return data, nil
+}
+
+func (r *Renderer) convertToPDF(ctx context.Context, dir, source string) (string, error) {
+	// A private profile directory lets concurrent conversions run side by side
+	profile := "-env:UserInstallation=file://" + filepath.Join(dir, "profile")
+	cmd := exec.CommandContext(ctx, r.libreOffice,
+		profile, "--headless", "--convert-to", "pdf", "--outdir", dir, source,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("libreoffice failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	pdf := filepath.Join(dir, "source.pdf")
+	if _, err := os.Stat(pdf); err != nil {
+		return "", fmt.Errorf("libreoffice produced no output: %w", err)
+	}
+	return pdf, nil
+}
+
+func writeFile(path string, content io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create scratch file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, content); err != nil {
+		return fmt.Errorf("failed to write scratch file: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,40 @@
+package preview
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSupported(t *testing.T) {
+	assert.True(t, IsSupported("application/pdf"))
+	assert.True(t, IsSupported("application/vnd.openxmlformats-officedocument.wordprocessingml.document"))
+	assert.False(t, IsSupported("image/png"))
+	assert.False(t, IsSupported("application/zip"))
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	renderer := NewRenderer("pdftoppm", "soffice", 256)
+
+	_, err := renderer.Render(context.Background(), nil, "text/plain")
+	assert.Error(t, err)
+}
+
+func TestRenderPDF(t *testing.T) {
+	pdftoppm, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		t.Skip("pdftoppm not installed")
+	}
+
+	source, err := os.Open("testdata/sample.pdf")
+	require.NoError(t, err)
+	defer source.Close()
+
+	data, err := NewRenderer(pdftoppm, "soffice", 256).Render(context.Background(), source, "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNG", string(data[:4]))
+}
@@ -0,0 +1,108 @@
+// Package ratelimit implements token bucket rate limiting in Redis, so
+// every API instance draws from the same buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit allows Burst requests at once, refilled at PerMinute requests per
+// minute
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Result describes a bucket after taking a request from it
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// RetryAfter is how long until the next request is allowed when this
+	// one was not; ResetAfter is how long until the bucket is full again
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// takeScript refills the bucket for the time since it was last used, takes
+// one token if there is one and returns whether it did along with the
+// tokens left. Buckets expire once they would be full again.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// Limiter takes requests from token buckets kept in Redis
+type Limiter struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func NewLimiter(client *redis.Client) *Limiter {
+	return &Limiter{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Take counts one request against the bucket named key
+func (l *Limiter) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if limit.PerMinute <= 0 || limit.Burst <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/min, burst %d", limit.PerMinute, limit.Burst)
+	}
+	rate := float64(limit.PerMinute) / float64(time.Minute/time.Millisecond) // tokens per millisecond
+	now := l.now().UnixMilli()
+
+	values, err := takeScript.Run(ctx, l.client, []string{"ratelimit:" + key},
+		strconv.FormatFloat(rate, 'g', -1, 64), limit.Burst, now).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take from rate limit bucket: %w", err)
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("unexpected rate limit reply %v", values)
+	}
+	allowed, _ := values[0].(int64)
+	tokensValue, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensValue, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rate limit reply %v", values)
+	}
+
+	result := &Result{
+		Allowed:    allowed == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: millis((float64(limit.Burst) - tokens) / rate),
+	}
+	if !result.Allowed {
+		result.RetryAfter = millis((1 - tokens) / rate)
+	}
+	return result, nil
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
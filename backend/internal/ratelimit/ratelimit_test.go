@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterTake(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Now()
+	limiter := NewLimiter(client)
+	limiter.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 3}
+	ctx := context.Background()
+
+	// The burst is available at once
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := limiter.Take(ctx, "user:alice", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := limiter.Take(ctx, "user:alice", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.ResetAfter)
+
+	// Other keys have their own bucket
+	result, err = limiter.Take(ctx, "ip:203.0.113.7", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// One token is refilled per second
+	now = now.Add(time.Second)
+	result, err = limiter.Take(ctx, "user:alice", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	_, err = limiter.Take(ctx, "user:alice", Limit{})
+	assert.Error(t, err)
+}
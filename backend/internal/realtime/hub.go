@@ -0,0 +1,337 @@
+// Package realtime relays domain events to WebSocket clients, such as the
+// frontend dashboard, as they happen.
+//
+// Clients join rooms: every connection is in the room of its user, which
+// gets the events of the user's account, posts, comments and files and of
+// comments on the user's posts, and can join the rooms of posts it may
+// read to follow them and their comments. Every server subscribes to
+// all domain events, so a client gets events no matter which server made
+// the change.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Rooms are named <kind>:<id>
+const (
+	userRoomPrefix = "user:"
+	postRoomPrefix = "post:"
+)
+
+// Limits of a connection
+const (
+	sendBuffer = 64 // messages waiting to be written before the client counts as too slow
+	maxRooms   = 50 // rooms joined besides the user's own
+)
+
+// Types of the messages clients send
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypePong        = "pong"
+)
+
+// Types of the messages the server sends besides events
+const (
+	TypeSubscribed   = "subscribed"
+	TypeUnsubscribed = "unsubscribed"
+	TypePing         = "ping"
+	TypeError        = "error"
+)
+
+var (
+	ErrTooManyConnections = errors.New("too many connections")
+	ErrUserConnections    = errors.New("too many connections for this user")
+	ErrTooManyRooms       = errors.New("too many rooms")
+	ErrUnknownRoom        = errors.New("unknown room")
+)
+
+// Message is a message of either side. Events carry their type, the room
+// they were sent to, the event ID and the event payload as data.
+type Message struct {
+	Type    string          `json:"type"`
+	Room    string          `json:"room,omitempty"`
+	EventID string          `json:"eventId,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// UserRoom is the room of userID, which each of their connections is in
+func UserRoom(userID string) string {
+	return userRoomPrefix + userID
+}
+
+// PostRoom is the room of postID
+func PostRoom(postID string) string {
+	return postRoomPrefix + postID
+}
+
+// ParseRoom splits a room clients may join into its kind and ID. Only post
+// rooms can be joined; user rooms are joined on connecting.
+func ParseRoom(room string) (kind, id string, err error) {
+	if id, ok := strings.CutPrefix(room, postRoomPrefix); ok && id != "" {
+		return "post", id, nil
+	}
+	return "", "", ErrUnknownRoom
+}
+
+// Client is one connection of a user
+type Client struct {
+	UserID string
+
+	send   chan []byte
+	rooms  map[string]struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Messages are the messages to write to the connection
+func (c *Client) Messages() <-chan []byte {
+	return c.send
+}
+
+// Done is closed when the hub dropped the client, because it was too slow
+// or the hub shut down
+func (c *Client) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *Client) close() {
+	c.once.Do(func() { close(c.closed) })
+}
+
+// Hub keeps track of the connected clients and the rooms they are in
+type Hub struct {
+	maxConnections int
+	maxPerUser     int
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	users   map[string]int
+	rooms   map[string]map[*Client]struct{}
+}
+
+func NewHub(maxConnections, maxPerUser int) *Hub {
+	return &Hub{
+		maxConnections: maxConnections,
+		maxPerUser:     maxPerUser,
+		clients:        make(map[*Client]struct{}),
+		users:          make(map[string]int),
+		rooms:          make(map[string]map[*Client]struct{}),
+	}
+}
+
+// Connect adds a client of userID in the user's room, unless the server
+// or the user is at their connection limit
+func (h *Hub) Connect(userID string) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.maxConnections > 0 && len(h.clients) >= h.maxConnections {
+		return nil, ErrTooManyConnections
+	}
+	if h.maxPerUser > 0 && h.users[userID] >= h.maxPerUser {
+		return nil, ErrUserConnections
+	}
+
+	client := &Client{
+		UserID: userID,
+		send:   make(chan []byte, sendBuffer),
+		rooms:  make(map[string]struct{}),
+		closed: make(chan struct{}),
+	}
+	h.clients[client] = struct{}{}
+	h.users[userID]++
+	h.join(client, UserRoom(userID))
+	return client, nil
+}
+
+// Disconnect removes client from the hub and its rooms
+func (h *Hub) Disconnect(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(client)
+}
+
+// Join adds client to room
+func (h *Hub) Join(client *Client, room string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return nil
+	}
+	if _, ok := client.rooms[room]; !ok && len(client.rooms) > maxRooms {
+		return ErrTooManyRooms
+	}
+	h.join(client, room)
+	return nil
+}
+
+// Leave removes client from room. The user's own room cannot be left.
+func (h *Hub) Leave(client *Client, room string) {
+	if room == UserRoom(client.UserID) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(client, room)
+}
+
+// Broadcast queues msg for every client in room. Clients whose queue is
+// full are dropped rather than holding up the others.
+func (h *Hub) Broadcast(room string, msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.rooms[room] {
+		select {
+		case client.send <- data:
+		default:
+			h.remove(client)
+		}
+	}
+}
+
+// Send queues msg for client alone, dropping the client when its queue is
+// full
+func (h *Hub) Send(client *Client, msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.send <- data:
+	default:
+		h.remove(client)
+	}
+}
+
+// Stats returns the number of connected clients and of rooms with clients
+func (h *Hub) Stats() (clients, rooms int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients), len(h.rooms)
+}
+
+// Close drops every client
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		h.remove(client)
+	}
+}
+
+// HandleEvent relays a domain event, as published to NATS, to the rooms it
+// concerns: the room of the user it belongs to and, for posts and comments
+// that are not withheld by moderation, the room of the post. Comments also
+// concern the author of their post.
+func (h *Hub) HandleEvent(data []byte) {
+	var event struct {
+		ID   string          `json:"id"`
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return
+	}
+
+	var owner struct {
+		ID         string `json:"id"`
+		UserID     string `json:"userId"`
+		Status     string `json:"status"`
+		PostID     string `json:"postId"`
+		PostUserID string `json:"postUserId"`
+	}
+	if err := json.Unmarshal(event.Data, &owner); err != nil {
+		return
+	}
+
+	msg := Message{Type: event.Type, EventID: event.ID, Data: event.Data}
+	kind, _, _ := strings.Cut(event.Type, ".")
+	switch kind {
+	case "user":
+		h.relay(UserRoom(owner.ID), msg)
+	case "post":
+		if owner.UserID != "" {
+			h.relay(UserRoom(owner.UserID), msg)
+		}
+		// Readers joined before the post was withheld
+		if !(&models.Post{Status: owner.Status}).Withheld() {
+			h.relay(PostRoom(owner.ID), msg)
+		}
+	case "comment":
+		if owner.UserID != "" {
+			h.relay(UserRoom(owner.UserID), msg)
+		}
+		if owner.PostUserID != "" && owner.PostUserID != owner.UserID {
+			h.relay(UserRoom(owner.PostUserID), msg)
+		}
+		if owner.Status != models.CommentStatusHidden && owner.Status != models.CommentStatusPending {
+			h.relay(PostRoom(owner.PostID), msg)
+		}
+	case "file":
+		if owner.UserID != "" {
+			h.relay(UserRoom(owner.UserID), msg)
+		}
+	}
+}
+
+func (h *Hub) relay(room string, msg Message) {
+	msg.Room = room
+	h.Broadcast(room, msg)
+}
+
+func (h *Hub) join(client *Client, room string) {
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[*Client]struct{})
+		h.rooms[room] = members
+	}
+	members[client] = struct{}{}
+	client.rooms[room] = struct{}{}
+}
+
+func (h *Hub) leave(client *Client, room string) {
+	delete(client.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, client)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	for room := range client.rooms {
+		h.leave(client, room)
+	}
+	delete(h.clients, client)
+	if h.users[client.UserID]--; h.users[client.UserID] <= 0 {
+		delete(h.users, client.UserID)
+	}
+	client.close()
+}
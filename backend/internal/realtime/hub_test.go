@@ -0,0 +1,170 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, client *Client) Message {
+	t.Helper()
+	select {
+	case data := <-client.Messages():
+		var msg Message
+		require.NoError(t, json.Unmarshal(data, &msg))
+		return msg
+	default:
+		t.Fatal("no message queued")
+		return Message{}
+	}
+}
+
+func assertNoMessage(t *testing.T, client *Client) {
+	t.Helper()
+	select {
+	case data := <-client.Messages():
+		t.Fatalf("unexpected message %s", data)
+	default:
+	}
+}
+
+func TestConnectLimits(t *testing.T) {
+	hub := NewHub(3, 2)
+
+	a1, err := hub.Connect("a")
+	require.NoError(t, err)
+	_, err = hub.Connect("a")
+	require.NoError(t, err)
+	_, err = hub.Connect("a")
+	assert.ErrorIs(t, err, ErrUserConnections)
+
+	_, err = hub.Connect("b")
+	require.NoError(t, err)
+	_, err = hub.Connect("c")
+	assert.ErrorIs(t, err, ErrTooManyConnections)
+
+	// Disconnecting frees a place, once
+	hub.Disconnect(a1)
+	hub.Disconnect(a1)
+	_, err = hub.Connect("c")
+	require.NoError(t, err)
+	clients, _ := hub.Stats()
+	assert.Equal(t, 3, clients)
+}
+
+func TestRooms(t *testing.T) {
+	hub := NewHub(0, 0)
+	client, err := hub.Connect("a")
+	require.NoError(t, err)
+
+	require.NoError(t, hub.Join(client, PostRoom("p1")))
+	hub.Broadcast(PostRoom("p1"), Message{Type: "post.updated"})
+	assert.Equal(t, "post.updated", receive(t, client).Type)
+
+	// The user's own room stays joined
+	hub.Leave(client, PostRoom("p1"))
+	hub.Leave(client, UserRoom("a"))
+	hub.Broadcast(PostRoom("p1"), Message{Type: "post.updated"})
+	assertNoMessage(t, client)
+	hub.Broadcast(UserRoom("a"), Message{Type: "user.updated"})
+	assert.Equal(t, "user.updated", receive(t, client).Type)
+
+	for i := 0; i < maxRooms; i++ {
+		require.NoError(t, hub.Join(client, PostRoom(string(rune('A'+i)))))
+	}
+	assert.ErrorIs(t, hub.Join(client, PostRoom("one-too-many")), ErrTooManyRooms)
+
+	hub.Disconnect(client)
+	_, rooms := hub.Stats()
+	assert.Equal(t, 0, rooms)
+}
+
+func TestParseRoom(t *testing.T) {
+	kind, id, err := ParseRoom("post:p1")
+	require.NoError(t, err)
+	assert.Equal(t, "post", kind)
+	assert.Equal(t, "p1", id)
+
+	for _, room := range []string{"post:", "user:a", "file:f1", ""} {
+		_, _, err := ParseRoom(room)
+		assert.ErrorIs(t, err, ErrUnknownRoom, room)
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	hub := NewHub(0, 0)
+	owner, err := hub.Connect("owner")
+	require.NoError(t, err)
+	reader, err := hub.Connect("reader")
+	require.NoError(t, err)
+	require.NoError(t, hub.Join(reader, PostRoom("p1")))
+
+	hub.HandleEvent([]byte(`{"id":"e1","type":"post.updated","data":{"id":"p1","userId":"owner"}}`))
+	msg := receive(t, owner)
+	assert.Equal(t, "post.updated", msg.Type)
+	assert.Equal(t, "e1", msg.EventID)
+	assert.Equal(t, UserRoom("owner"), msg.Room)
+	assert.JSONEq(t, `{"id":"p1","userId":"owner"}`, string(msg.Data))
+	assert.Equal(t, PostRoom("p1"), receive(t, reader).Room)
+
+	// Posts withheld by moderation only concern their author
+	hub.HandleEvent([]byte(`{"id":"e4","type":"post.updated","data":{"id":"p1","userId":"owner","status":"pending"}}`))
+	assert.Equal(t, "e4", receive(t, owner).EventID)
+	assertNoMessage(t, reader)
+
+	// Files only concern their owner
+	hub.HandleEvent([]byte(`{"id":"e2","type":"file.deleted","data":{"id":"f1","userId":"owner"}}`))
+	assert.Equal(t, "file.deleted", receive(t, owner).Type)
+	assertNoMessage(t, reader)
+
+	hub.HandleEvent([]byte(`{"id":"e3","type":"user.updated","data":{"id":"reader"}}`))
+	assert.Equal(t, "user.updated", receive(t, reader).Type)
+	assertNoMessage(t, owner)
+
+	// Comments concern their author, the author of the post and, unless
+	// withheld, its readers
+	commenter, err := hub.Connect("commenter")
+	require.NoError(t, err)
+	hub.HandleEvent([]byte(`{"id":"e5","type":"comment.created","data":{"id":"c1","postId":"p1","postUserId":"owner","userId":"commenter","status":"visible"}}`))
+	assert.Equal(t, UserRoom("commenter"), receive(t, commenter).Room)
+	assert.Equal(t, UserRoom("owner"), receive(t, owner).Room)
+	assert.Equal(t, "comment.created", receive(t, reader).Type)
+	hub.HandleEvent([]byte(`{"id":"e6","type":"comment.moderated","data":{"id":"c1","postId":"p1","postUserId":"owner","userId":"commenter","status":"hidden"}}`))
+	assert.Equal(t, "e6", receive(t, commenter).EventID)
+	assert.Equal(t, "e6", receive(t, owner).EventID)
+	assertNoMessage(t, reader)
+	hub.HandleEvent([]byte(`{"id":"e7","type":"comment.deleted","data":{"id":"c2","postId":"p1","postUserId":"owner","userId":"owner"}}`))
+	assert.Equal(t, "e7", receive(t, owner).EventID)
+	assertNoMessage(t, owner)
+	assert.Equal(t, "e7", receive(t, reader).EventID)
+	assertNoMessage(t, commenter)
+
+	hub.HandleEvent([]byte(`not json`))
+	assertNoMessage(t, owner)
+	assertNoMessage(t, reader)
+}
+
+func TestSlowClientDropped(t *testing.T) {
+	hub := NewHub(0, 0)
+	slow, err := hub.Connect("a")
+	require.NoError(t, err)
+	fast, err := hub.Connect("a")
+	require.NoError(t, err)
+
+	for i := 0; i < sendBuffer; i++ {
+		hub.Broadcast(UserRoom("a"), Message{Type: "user.updated"})
+		receive(t, fast)
+	}
+	hub.Broadcast(UserRoom("a"), Message{Type: "user.updated"})
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("slow client was not dropped")
+	}
+	assert.Equal(t, "user.updated", receive(t, fast).Type)
+	clients, _ := hub.Stats()
+	assert.Equal(t, 1, clients)
+}
@@ -0,0 +1,86 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReport(t *testing.T) {
+	report := NewReport("boom", 0)
+
+	assert.Len(t, report.ID, 32)
+	assert.Equal(t, "boom", report.Message)
+	require.NotEmpty(t, report.Frames)
+	assert.Contains(t, report.Frames[0].Function, "TestNewReport")
+	assert.Contains(t, report.Stack(), "reporting_test.go")
+}
+
+func TestWebhook(t *testing.T) {
+	var received Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	report := NewReport("boom", 0)
+	report.RequestID = "req-1"
+	require.NoError(t, NewWebhook(server.URL).Report(context.Background(), report))
+	assert.Equal(t, "req-1", received.RequestID)
+	assert.Equal(t, report.Frames, received.Frames)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.Error(t, Multi{NewWebhook(server.URL), NewWebhook(failing.URL)}.Report(context.Background(), report))
+}
+
+func TestSentry(t *testing.T) {
+	_, err := NewSentry("https://o1.ingest.sentry.io/42", "")
+	assert.Error(t, err, "the DSN needs a key")
+	_, err = NewSentry("https://key@o1.ingest.sentry.io/", "")
+	assert.Error(t, err, "the DSN needs a project")
+
+	var path, auth string
+	var lines [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("X-Sentry-Auth")
+		body, _ := io.ReadAll(r.Body)
+		lines = bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/sentry/42"
+	sentry, err := NewSentry(dsn, "staging")
+	require.NoError(t, err)
+
+	report := NewReport("boom", 0)
+	report.UserID = "user-1"
+	report.Method = http.MethodGet
+	report.Path = "/api/v1/posts"
+	require.NoError(t, sentry.Report(context.Background(), report))
+
+	assert.Equal(t, "/sentry/api/42/envelope/", path)
+	assert.Contains(t, auth, "sentry_key=public")
+	require.Len(t, lines, 3)
+
+	var event sentryEvent
+	require.NoError(t, json.Unmarshal(lines[2], &event))
+	assert.Equal(t, report.ID, event.EventID)
+	assert.Equal(t, "staging", event.Environment)
+	assert.Equal(t, "boom", event.Exception.Values[0].Value)
+	assert.Equal(t, "user-1", event.User.ID)
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	assert.Contains(t, frames[len(frames)-1].Function, "TestSentry", "Sentry wants the innermost call last")
+}
@@ -0,0 +1,134 @@
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sentry sends reports to Sentry's envelope endpoint as events
+type Sentry struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+	client      *http.Client
+}
+
+// NewSentry returns a sink for the project of a Sentry DSN such as
+// https://key@o1.ingest.sentry.io/42. Events are tagged with environment
+// if it is set.
+func NewSentry(dsn, environment string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	projectPath, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if u.Scheme == "" || u.Host == "" || u.User.Username() == "" || project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: expected scheme://key@host/project")
+	}
+
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: projectPath + "api/" + project + "/envelope/"}
+	return &Sentry{
+		dsn:         dsn,
+		endpoint:    endpoint.String(),
+		auth:        "Sentry sentry_version=7, sentry_client=minio-storage-backend/1.0, sentry_key=" + u.User.Username(),
+		environment: environment,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// sentryEvent is the subset of Sentry's event payload reports fill
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Environment string            `json:"environment,omitempty"`
+	Exception   sentryExceptions  `json:"exception"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Contexts    map[string]any    `json:"contexts,omitempty"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string           `json:"type"`
+	Value      string           `json:"value"`
+	Stacktrace sentryStacktrace `json:"stacktrace"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"` // outermost call first
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type sentryUser struct {
+	ID string `json:"id"`
+}
+
+func (s *Sentry) Report(ctx context.Context, report *Report) error {
+	event := sentryEvent{
+		EventID:     report.ID,
+		Timestamp:   report.Time.Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Environment: s.environment,
+		Exception: sentryExceptions{Values: []sentryException{{
+			Type:  "panic",
+			Value: report.Message,
+		}}},
+	}
+	for _, frame := range slices.Backward(report.Frames) {
+		event.Exception.Values[0].Stacktrace.Frames = append(event.Exception.Values[0].Stacktrace.Frames,
+			sentryFrame{Function: frame.Function, Filename: frame.File, Lineno: frame.Line})
+	}
+	if report.Method != "" {
+		event.Request = &sentryRequest{Method: report.Method, URL: report.Path}
+	}
+	if report.UserID != "" {
+		event.User = &sentryUser{ID: report.UserID}
+	}
+	if report.RequestID != "" {
+		event.Tags = map[string]string{"request_id": report.RequestID}
+	}
+	if report.TraceID != "" {
+		event.Contexts = map[string]any{"trace": map[string]string{"trace_id": report.TraceID, "span_id": report.SpanID}}
+	}
+
+	header, err := json.Marshal(map[string]string{"event_id": report.ID, "dsn": s.dsn})
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	item, err := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	if err != nil {
+		return err
+	}
+
+	envelope := slices.Concat(header, []byte("\n"), item, []byte("\n"), payload, []byte("\n"))
+	return post(ctx, s.client, s.endpoint, "application/x-sentry-envelope", envelope, http.Header{"X-Sentry-Auth": {s.auth}})
+}
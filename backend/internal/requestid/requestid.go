@@ -0,0 +1,53 @@
+// Package requestid identifies requests across the API, the storage layer
+// and the logs, so support can follow a failing request by the ID its
+// client saw.
+package requestid
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID in requests and responses
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs given by clients, which end up in every log line
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a fresh request ID
+func New() string {
+	return uuid.New().String()
+}
+
+// Valid reports whether a client-supplied ID can be used as is: not empty,
+// not too long, and only letters, digits and -_.:/= so it cannot forge log
+// lines
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.:/=", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, or "" outside a request
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,25 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(New()))
+	assert.True(t, Valid("Root=1-5759e988-bd862e3fe1be46a994272793"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid(strings.Repeat("a", maxLength+1)))
+	assert.False(t, Valid("abc\n2024/01/01 forged line"))
+	assert.False(t, Valid("abc def"))
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+
+	ctx := NewContext(context.Background(), "req-1")
+	assert.Equal(t, "req-1", FromContext(ctx))
+}
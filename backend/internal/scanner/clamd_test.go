@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd accepts one INSTREAM session and replies FOUND if the streamed
+// content contains the EICAR marker
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		if _, err := reader.ReadString(0); err != nil {
+			return
+		}
+
+		var content bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(reader, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, reader, int64(n)); err != nil {
+				return
+			}
+		}
+
+		if strings.Contains(content.String(), "EICAR") {
+			conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			return
+		}
+		conn.Write([]byte("stream: OK\x00"))
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestScanClean(t *testing.T) {
+	client := NewClient(fakeClamd(t), time.Second)
+
+	result, err := client.Scan(strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.True(t, result.Clean)
+}
+
+func TestScanInfected(t *testing.T) {
+	client := NewClient(fakeClamd(t), time.Second)
+
+	result, err := client.Scan(strings.NewReader("X5O!P%@AP...EICAR-STANDARD-ANTIVIRUS-TEST-FILE"))
+	require.NoError(t, err)
+	assert.False(t, result.Clean)
+	assert.Equal(t, "Eicar-Test-Signature", result.Signature)
+}
+
+func TestParseReplyError(t *testing.T) {
+	_, err := parseReply("INSTREAM size limit exceeded. ERROR\x00")
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+// Package scheduler runs recurring tasks, such as cleanups and digest
+// emails, on cron schedules.
+//
+// Every server runs a scheduler, and they elect a leader through a lease
+// in Redis that the leader renews; only the leader starts scheduled runs,
+// so a task runs once per occurrence however many servers there are. When
+// the leader stops, another server takes over once its lease ran out. A
+// run also holds a lock of its task, so a run started by hand or by a new
+// leader does not overlap one still going. Runs are recorded in Redis for
+// the status API.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "scheduler:"
+	leaderKey = keyPrefix + "leader"
+)
+
+// The leader holds its lease for leaseTTL and renews it every leaseRenew,
+// so a server that cannot reach Redis steps down before another can take
+// over
+const (
+	leaseTTL   = 30 * time.Second
+	leaseRenew = 10 * time.Second
+)
+
+var (
+	ErrUnknownTask = errors.New("unknown task")
+	ErrTaskRunning = errors.New("task is already running")
+)
+
+// renewScript extends the lease in KEYS[1] by ARGV[2] milliseconds if
+// server ARGV[1] holds it
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript gives up the lock in KEYS[1] if server ARGV[1] holds it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Run describes the run a task is called for
+type Run struct {
+	// Scheduled is when the run was due, or when it was started by hand
+	Scheduled time.Time
+	// LastSuccess is when the last successful run started, zero when none
+	// did yet
+	LastSuccess time.Time
+}
+
+// Func is the work of a task
+type Func func(ctx context.Context, run Run) error
+
+type task struct {
+	name     string
+	spec     string
+	schedule *Schedule
+	timeout  time.Duration
+	fn       Func
+}
+
+// Scheduler runs the tasks added to it while its server is the leader
+type Scheduler struct {
+	redis  *redis.Client
+	id     string
+	logger *slog.Logger
+	tasks  []*task
+	leader atomic.Bool
+}
+
+func New(client *redis.Client, logger *slog.Logger) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		redis:  client,
+		id:     host + "-" + uuid.NewString()[:8],
+		logger: logger,
+	}
+}
+
+// Add schedules fn as the task name on the cron schedule spec, in UTC.
+// Runs are cancelled after timeout.
+func (s *Scheduler) Add(name, spec string, timeout time.Duration, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", name, err)
+	}
+	s.tasks = append(s.tasks, &task{name: name, spec: spec, schedule: schedule, timeout: timeout, fn: fn})
+	return nil
+}
+
+// Start takes part in the leader election and runs the tasks when they
+// are due, until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.elect(ctx)
+	for _, t := range s.tasks {
+		go s.loop(ctx, t)
+	}
+}
+
+// IsLeader reports whether this server runs the scheduled tasks
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// RunNow starts the task name on this server, whether it leads or not.
+// The run goes on in the background.
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	for _, t := range s.tasks {
+		if t.name == name {
+			if err := s.lock(ctx, t); err != nil {
+				return err
+			}
+			go s.execute(context.WithoutCancel(ctx), t, time.Now().UTC())
+			return nil
+		}
+	}
+	return ErrUnknownTask
+}
+
+// Status returns the tasks with their schedules and last runs, and the
+// server leading
+func (s *Scheduler) Status(ctx context.Context) (*models.SchedulerStatus, error) {
+	leader, err := s.redis.Get(ctx, leaderKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get scheduler leader: %w", err)
+	}
+
+	status := &models.SchedulerStatus{Leader: leader, Tasks: []models.ScheduledTask{}}
+	now := time.Now().UTC()
+	for _, t := range s.tasks {
+		values, err := s.redis.HGetAll(ctx, taskKey(t.name)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task %s: %w", t.name, err)
+		}
+		running, err := s.redis.Exists(ctx, runningKey(t.name)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task %s: %w", t.name, err)
+		}
+
+		task := models.ScheduledTask{
+			Name:          t.name,
+			Schedule:      t.spec,
+			Running:       running > 0,
+			LastRunAt:     unixMilli(values["lastRunAt"]),
+			LastSuccessAt: unixMilli(values["lastSuccessAt"]),
+			LastRunBy:     values["lastRunBy"],
+			LastError:     values["lastError"],
+		}
+		task.LastDurationMs, _ = strconv.ParseInt(values["lastDurationMs"], 10, 64)
+		if next := t.schedule.Next(now); !next.IsZero() {
+			task.NextRunAt = &next
+		}
+		status.Tasks = append(status.Tasks, task)
+	}
+	return status, nil
+}
+
+func (s *Scheduler) elect(ctx context.Context) {
+	ticker := time.NewTicker(leaseRenew)
+	defer ticker.Stop()
+
+	for {
+		s.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			// Let another server take over right away
+			if s.leader.Swap(false) {
+				releaseScript.Run(context.WithoutCancel(ctx), s.redis, []string{leaderKey}, s.id)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign renews the lease of the leader, or takes it when it is free
+func (s *Scheduler) campaign(ctx context.Context) {
+	wasLeader := s.leader.Load()
+	var leader bool
+	var err error
+	if wasLeader {
+		leader, err = renewScript.Run(ctx, s.redis, []string{leaderKey}, s.id, leaseTTL.Milliseconds()).Bool()
+	} else {
+		leader, err = s.redis.SetNX(ctx, leaderKey, s.id, leaseTTL).Result()
+	}
+	if err != nil && ctx.Err() == nil {
+		s.logger.Warn("Failed to elect scheduler leader", "error", err)
+	}
+	s.leader.Store(leader)
+
+	switch {
+	case leader && !wasLeader:
+		s.logger.Info("Running scheduled tasks on this server", "server", s.id)
+	case !leader && wasLeader:
+		s.logger.Warn("Stopped running scheduled tasks on this server", "server", s.id)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	for {
+		next := t.schedule.Next(time.Now().UTC())
+		if next.IsZero() {
+			s.logger.Warn("Scheduled task never runs", "task", t.name, "schedule", t.spec)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.leader.Load() {
+			continue
+		}
+		if err := s.lock(ctx, t); err != nil {
+			if !errors.Is(err, ErrTaskRunning) {
+				s.logger.Error("Failed to start scheduled task", "task", t.name, "error", err)
+			}
+			continue
+		}
+		s.execute(ctx, t, next)
+	}
+}
+
+// lock marks t as running. The lock outlives a run that hangs past its
+// timeout by a minute at most.
+func (s *Scheduler) lock(ctx context.Context, t *task) error {
+	locked, err := s.redis.SetNX(ctx, runningKey(t.name), s.id, t.timeout+time.Minute).Result()
+	if err != nil {
+		return fmt.Errorf("failed to lock task %s: %w", t.name, err)
+	}
+	if !locked {
+		return ErrTaskRunning
+	}
+	return nil
+}
+
+// execute runs t, which must be locked, records the run and unlocks t
+func (s *Scheduler) execute(ctx context.Context, t *task, scheduled time.Time) {
+	store := context.WithoutCancel(ctx)
+	defer releaseScript.Run(store, s.redis, []string{runningKey(t.name)}, s.id)
+
+	run := Run{Scheduled: scheduled}
+	if last := unixMilli(s.redis.HGet(ctx, taskKey(t.name), "lastSuccessAt").Val()); last != nil {
+		run.LastSuccess = *last
+	}
+
+	started := time.Now()
+	err := s.call(ctx, t, run)
+	duration := time.Since(started)
+
+	fields := map[string]any{
+		"lastRunAt":      started.UnixMilli(),
+		"lastDurationMs": duration.Milliseconds(),
+		"lastRunBy":      s.id,
+		"lastError":      "",
+	}
+	if err != nil {
+		fields["lastError"] = err.Error()
+		s.logger.Error("Scheduled task failed", "task", t.name, "duration", duration, "error", err)
+	} else {
+		fields["lastSuccessAt"] = started.UnixMilli()
+		s.logger.Info("Ran scheduled task", "task", t.name, "duration", duration)
+	}
+	if err := s.redis.HSet(store, taskKey(t.name), fields).Err(); err != nil {
+		s.logger.Warn("Failed to record scheduled task run", "task", t.name, "error", err)
+	}
+}
+
+// call runs the function of t within its timeout. A panic fails the run
+// rather than the server.
+func (s *Scheduler) call(ctx context.Context, t *task, run Run) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return t.fn(ctx, run)
+}
+
+func taskKey(name string) string {
+	return keyPrefix + "task:" + name
+}
+
+func runningKey(name string) string {
+	return keyPrefix + "running:" + name
+}
+
+func unixMilli(value string) *time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
+}
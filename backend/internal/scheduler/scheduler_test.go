@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduler(t *testing.T, server *miniredis.Miniredis) *Scheduler {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, slog.Default())
+}
+
+// waitIdle waits for the run of task to be unlocked
+func waitIdle(t *testing.T, server *miniredis.Miniredis, task string) {
+	require.Eventually(t, func() bool { return !server.Exists(runningKey(task)) }, time.Second, 5*time.Millisecond)
+}
+
+func TestRunNow(t *testing.T) {
+	server := miniredis.RunT(t)
+	s := newTestScheduler(t, server)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	runs := make(chan Run, 2)
+	require.NoError(t, s.Add("cleanup", "@daily", time.Minute, func(ctx context.Context, run Run) error {
+		runs <- run
+		<-release
+		return nil
+	}))
+
+	assert.ErrorIs(t, s.RunNow(ctx, "unknown"), ErrUnknownTask)
+
+	require.NoError(t, s.RunNow(ctx, "cleanup"))
+	first := <-runs
+	assert.True(t, first.LastSuccess.IsZero())
+
+	// Runs do not overlap
+	assert.ErrorIs(t, s.RunNow(ctx, "cleanup"), ErrTaskRunning)
+	status, err := s.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status.Tasks, 1)
+	assert.True(t, status.Tasks[0].Running)
+
+	close(release)
+	waitIdle(t, server, "cleanup")
+
+	status, err = s.Status(ctx)
+	require.NoError(t, err)
+	task := status.Tasks[0]
+	assert.Equal(t, "cleanup", task.Name)
+	assert.Equal(t, "@daily", task.Schedule)
+	assert.False(t, task.Running)
+	require.NotNil(t, task.LastRunAt)
+	require.NotNil(t, task.LastSuccessAt)
+	require.NotNil(t, task.NextRunAt)
+	assert.Equal(t, s.id, task.LastRunBy)
+	assert.Empty(t, task.LastError)
+
+	// The next run knows when the last one succeeded
+	require.NoError(t, s.RunNow(ctx, "cleanup"))
+	second := <-runs
+	assert.Equal(t, task.LastSuccessAt.UnixMilli(), second.LastSuccess.UnixMilli())
+	waitIdle(t, server, "cleanup")
+}
+
+func TestRunFailure(t *testing.T) {
+	server := miniredis.RunT(t)
+	s := newTestScheduler(t, server)
+	ctx := context.Background()
+
+	require.NoError(t, s.Add("fails", "@hourly", time.Minute, func(ctx context.Context, run Run) error {
+		return errors.New("bucket unavailable")
+	}))
+	require.NoError(t, s.Add("panics", "@hourly", time.Minute, func(ctx context.Context, run Run) error {
+		panic("boom")
+	}))
+
+	require.NoError(t, s.RunNow(ctx, "fails"))
+	require.NoError(t, s.RunNow(ctx, "panics"))
+	waitIdle(t, server, "fails")
+	waitIdle(t, server, "panics")
+
+	status, err := s.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status.Tasks, 2)
+	assert.Equal(t, "bucket unavailable", status.Tasks[0].LastError)
+	assert.Equal(t, "panic: boom", status.Tasks[1].LastError)
+	for _, task := range status.Tasks {
+		assert.NotNil(t, task.LastRunAt)
+		assert.Nil(t, task.LastSuccessAt)
+	}
+}
+
+func TestAddInvalidSchedule(t *testing.T) {
+	s := newTestScheduler(t, miniredis.RunT(t))
+	assert.Error(t, s.Add("broken", "every day", time.Minute, nil))
+}
+
+func TestLeaderElection(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newTestScheduler(t, server)
+	second := newTestScheduler(t, server)
+	ctx := context.Background()
+
+	first.campaign(ctx)
+	second.campaign(ctx)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	status, err := second.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, first.id, status.Leader)
+
+	// The leader keeps its lease by renewing it
+	server.FastForward(leaseTTL - time.Second)
+	first.campaign(ctx)
+	server.FastForward(leaseTTL - time.Second)
+	second.campaign(ctx)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// A leader that stops renewing is replaced once its lease runs out
+	server.FastForward(leaseTTL)
+	second.campaign(ctx)
+	first.campaign(ctx)
+	assert.True(t, second.IsLeader())
+	assert.False(t, first.IsLeader())
+}
@@ -0,0 +1,172 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// MaxResults caps the page size of a query
+const MaxResults = 200
+
+// Filter is a conjunction of attribute comparisons, the subset of the
+// filter language identity providers use to look users up, e.g.
+// userName eq "alice" and active eq true
+type Filter []comparison
+
+type comparison struct {
+	attribute string
+	operator  string
+	value     string
+}
+
+// ParseFilter parses filter; an empty filter matches every user
+func ParseFilter(filter string) (Filter, *Error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed Filter
+	for len(tokens) > 0 {
+		if len(parsed) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, invalidFilter("expected \"and\", found %q", tokens[0])
+			}
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 2 {
+			return nil, invalidFilter("incomplete expression")
+		}
+
+		c := comparison{attribute: attributeName(tokens[0]), operator: strings.ToLower(tokens[1])}
+		if !filterAttributes[c.attribute] {
+			return nil, invalidFilter("cannot filter on %q", tokens[0])
+		}
+		switch c.operator {
+		case "pr":
+			tokens = tokens[2:]
+		case "eq", "ne", "co", "sw", "ew":
+			if len(tokens) < 3 {
+				return nil, invalidFilter("missing value for %q", tokens[1])
+			}
+			c.value = tokens[2]
+			tokens = tokens[3:]
+		default:
+			return nil, invalidFilter("unsupported operator %q", tokens[1])
+		}
+		parsed = append(parsed, c)
+	}
+	return parsed, nil
+}
+
+// Matches reports whether user satisfies every comparison of the filter
+func (f Filter) Matches(user *models.User) bool {
+	for _, c := range f {
+		if !c.matches(user) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c comparison) matches(user *models.User) bool {
+	actual := attributeValue(user, c.attribute)
+	// id and externalId are case exact, everything else is not
+	if c.attribute != "id" && c.attribute != "externalid" {
+		actual = strings.ToLower(actual)
+		c.value = strings.ToLower(c.value)
+	}
+
+	switch c.operator {
+	case "pr":
+		return actual != ""
+	case "eq":
+		return actual == c.value
+	case "ne":
+		return actual != c.value
+	case "co":
+		return strings.Contains(actual, c.value)
+	case "sw":
+		return strings.HasPrefix(actual, c.value)
+	case "ew":
+		return strings.HasSuffix(actual, c.value)
+	}
+	return false
+}
+
+// filterAttributes are the attributes a filter may compare, lowercased
+var filterAttributes = map[string]bool{
+	"id":              true,
+	"externalid":      true,
+	"username":        true,
+	"emails.value":    true,
+	"name.givenname":  true,
+	"name.familyname": true,
+	"active":          true,
+}
+
+// attributeName lowercases name and strips the core schema prefix, so
+// "emails" and "urn:...:User:emails.value" both mean emails.value
+func attributeName(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(name, SchemaUser+":"))
+	if name == "emails" {
+		return "emails.value"
+	}
+	return name
+}
+
+func attributeValue(user *models.User, attribute string) string {
+	switch attribute {
+	case "id":
+		return user.ID
+	case "externalid":
+		return user.ExternalID
+	case "username":
+		return user.Username
+	case "emails.value":
+		return user.Email
+	case "name.givenname":
+		return user.FirstName
+	case "name.familyname":
+		return user.LastName
+	case "active":
+		return strconv.FormatBool(user.AccountStatus() == models.UserStatusActive)
+	}
+	return ""
+}
+
+// tokenize splits filter at spaces outside of quoted strings and unquotes
+// the strings
+func tokenize(filter string) ([]string, *Error) {
+	var tokens []string
+	for filter = strings.TrimSpace(filter); filter != ""; filter = strings.TrimSpace(filter) {
+		if filter[0] != '"' {
+			end := strings.IndexByte(filter, ' ')
+			if end < 0 {
+				end = len(filter)
+			}
+			tokens = append(tokens, filter[:end])
+			filter = filter[end:]
+			continue
+		}
+
+		quoted, err := strconv.QuotedPrefix(filter)
+		if err != nil {
+			return nil, invalidFilter("unterminated string")
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, invalidFilter("invalid string %s", quoted)
+		}
+		tokens = append(tokens, value)
+		filter = filter[len(quoted):]
+	}
+	return tokens, nil
+}
+
+func invalidFilter(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, "invalidFilter", format, args...)
+}
@@ -0,0 +1,175 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// ApplyPatch applies operations to user. Only the attributes FromUser
+// reports can be changed; userName and the email address can be replaced
+// but not removed.
+func ApplyPatch(user *models.User, operations []PatchOperation) *Error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		switch op {
+		case "add", "replace":
+			if operation.Path == "" {
+				// Without a path the value holds attributes by name
+				values, ok := operation.Value.(map[string]any)
+				if !ok {
+					return invalidValue("value must be an object when no path is given")
+				}
+				for path, value := range values {
+					if err := setAttribute(user, path, value); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := setAttribute(user, operation.Path, operation.Value); err != nil {
+				return err
+			}
+		case "remove":
+			if err := removeAttribute(user, operation.Path); err != nil {
+				return err
+			}
+		default:
+			return invalidValue("unsupported operation %q", operation.Op)
+		}
+	}
+	return nil
+}
+
+func setAttribute(user *models.User, path string, value any) *Error {
+	attribute := attributeName(path)
+	// emails[type eq "work"].value and friends address the only address
+	if strings.HasPrefix(attribute, "emails[") {
+		attribute = "emails.value"
+	}
+
+	switch attribute {
+	case "username":
+		name, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return invalidValue("userName cannot be empty")
+		}
+		user.Username = name
+	case "externalid":
+		id, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		user.ExternalID = id
+	case "name.givenname":
+		name, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		user.FirstName = name
+	case "name.familyname":
+		name, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		user.LastName = name
+	case "name":
+		values, ok := value.(map[string]any)
+		if !ok {
+			return invalidValue("name must be an object")
+		}
+		for key, v := range values {
+			if err := setAttribute(user, "name."+key, v); err != nil {
+				return err
+			}
+		}
+	case "emails.value":
+		email, err := emailValue(value)
+		if err != nil {
+			return err
+		}
+		user.Email = email
+	case "active":
+		active, err := boolValue(value)
+		if err != nil {
+			return err
+		}
+		setActive(user, active)
+	default:
+		return NewError(http.StatusBadRequest, "invalidPath", "cannot modify %q", path)
+	}
+	return nil
+}
+
+func removeAttribute(user *models.User, path string) *Error {
+	switch attributeName(path) {
+	case "externalid":
+		user.ExternalID = ""
+	case "name.givenname":
+		user.FirstName = ""
+	case "name.familyname":
+		user.LastName = ""
+	case "name":
+		user.FirstName, user.LastName = "", ""
+	default:
+		return NewError(http.StatusBadRequest, "mutability", "cannot remove %q", path)
+	}
+	return nil
+}
+
+func stringValue(path string, value any) (string, *Error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", invalidValue("%s must be a string", path)
+	}
+	return s, nil
+}
+
+// emailValue accepts an address or a list of email objects, of which the
+// primary or first is used
+func emailValue(value any) (string, *Error) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v, nil
+		}
+	case []any:
+		var emails []Email
+		for _, item := range v {
+			entry, ok := item.(map[string]any)
+			if !ok {
+				return "", invalidValue("emails must be a list of objects")
+			}
+			address, _ := entry["value"].(string)
+			primary, _ := entry["primary"].(bool)
+			emails = append(emails, Email{Value: address, Primary: primary})
+		}
+		if email := primaryEmail(emails); email != "" {
+			return email, nil
+		}
+	}
+	return "", invalidValue("an email address is required")
+}
+
+// boolValue accepts booleans and, as some identity providers send them,
+// the strings "true" and "false"
+func boolValue(value any) (bool, *Error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, invalidValue("active must be a boolean")
+}
+
+func invalidValue(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, "invalidValue", format, args...)
+}
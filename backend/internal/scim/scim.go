@@ -0,0 +1,201 @@
+// Package scim maps users to SCIM 2.0 (RFC 7643, RFC 7644) resources so
+// identity providers can provision and deprovision accounts.
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Schema URNs
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the media type of SCIM requests and responses
+const ContentType = "application/scim+json"
+
+// User is the SCIM representation of a user
+type User struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id,omitempty"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Name       *Name    `json:"name,omitempty"`
+	Emails     []Email  `json:"emails,omitempty"`
+	Active     *bool    `json:"active,omitempty"`
+	Meta       *Meta    `json:"meta,omitempty"`
+}
+
+type Name struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// ListResponse is a page of query results; StartIndex is 1-based
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []*User  `json:"Resources"`
+}
+
+// PatchRequest modifies a resource with a list of operations
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations" binding:"required,min=1"`
+}
+
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Error is a SCIM error response. ScimType narrows down 400 errors, e.g.
+// invalidFilter or invalidValue.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// NewError builds an error response with HTTP status code
+func NewError(code int, scimType, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(code),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// StatusCode returns the HTTP status code of the error
+func (e *Error) StatusCode() int {
+	code, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+// FromUser converts user to its SCIM representation; location is the URL
+// of the resource
+func FromUser(user *models.User, location string) *User {
+	active := user.AccountStatus() == models.UserStatusActive
+	resource := &User{
+		Schemas:    []string{SchemaUser},
+		ID:         user.ID,
+		ExternalID: user.ExternalID,
+		UserName:   user.Username,
+		Active:     &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     location,
+		},
+	}
+	if user.FirstName != "" || user.LastName != "" {
+		resource.Name = &Name{GivenName: user.FirstName, FamilyName: user.LastName}
+	}
+	if user.Email != "" {
+		resource.Emails = []Email{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	return resource
+}
+
+// ApplyTo copies the resource's attributes to user, as a create or a full
+// replace does. Missing optional attributes are cleared.
+func (r *User) ApplyTo(user *models.User) *Error {
+	if r.UserName == "" {
+		return NewError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	email := primaryEmail(r.Emails)
+	if email == "" {
+		return NewError(http.StatusBadRequest, "invalidValue", "an email address is required")
+	}
+
+	user.Username = r.UserName
+	user.Email = email
+	user.ExternalID = r.ExternalID
+	user.FirstName, user.LastName = "", ""
+	if r.Name != nil {
+		user.FirstName = r.Name.GivenName
+		user.LastName = r.Name.FamilyName
+	}
+	if r.Active != nil {
+		setActive(user, *r.Active)
+	}
+	return nil
+}
+
+// primaryEmail returns the primary address of emails, or the first one
+func primaryEmail(emails []Email) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+// setActive activates or deactivates user. Activating also lifts a
+// suspension, as the identity provider has the final say.
+func setActive(user *models.User, active bool) {
+	if active {
+		user.Status = models.UserStatusActive
+	} else {
+		user.Status = models.UserStatusDeactivated
+	}
+}
+
+// ServiceProviderConfig describes the supported protocol features
+func ServiceProviderConfig() map[string]any {
+	unsupported := map[string]bool{"supported": false}
+	return map[string]any{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": MaxResults},
+		"changePassword": unsupported,
+		"sort":           unsupported,
+		"etag":           unsupported,
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Provisioning token sent in the Authorization header",
+			"primary":     true,
+		}},
+	}
+}
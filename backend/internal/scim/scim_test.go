@@ -0,0 +1,88 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	alice := &models.User{ID: "1", Username: "alice", Email: "Alice@Example.com", ExternalID: "00u1"}
+	bob := &models.User{ID: "2", Username: "bob", Email: "bob@example.com", Status: models.UserStatusDeactivated}
+
+	matches := func(filter string, user *models.User) bool {
+		parsed, err := ParseFilter(filter)
+		require.Nil(t, err, filter)
+		return parsed.Matches(user)
+	}
+
+	assert.True(t, matches("", alice))
+	assert.True(t, matches(`userName eq "ALICE"`, alice))
+	assert.True(t, matches(`emails.value eq "alice@example.com"`, alice))
+	assert.True(t, matches(`emails co "example"`, alice))
+	assert.True(t, matches(`externalId eq "00u1" and active eq "true"`, alice))
+	assert.False(t, matches(`externalId eq "00U1"`, alice), "externalId is case exact")
+	assert.True(t, matches(`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "b"`, bob))
+	assert.False(t, matches(`active eq "true"`, bob))
+	assert.False(t, matches(`externalId pr`, bob))
+
+	for _, filter := range []string{`userName eq`, `password eq "x"`, `userName gt "a"`, `userName eq "a" or userName eq "b"`, `userName eq "a`} {
+		_, err := ParseFilter(filter)
+		if assert.NotNil(t, err, filter) {
+			assert.Equal(t, "invalidFilter", err.ScimType)
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	user := &models.User{Username: "alice", Email: "alice@example.com", FirstName: "Alice", ExternalID: "00u1"}
+
+	var operations []PatchOperation
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"op": "Replace", "path": "name.familyName", "value": "Liddell"},
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "alice@corp.example"},
+		{"op": "replace", "value": {"active": "False", "userName": "aliddell"}},
+		{"op": "remove", "path": "externalId"}
+	]`), &operations))
+
+	require.Nil(t, ApplyPatch(user, operations))
+	assert.Equal(t, "Liddell", user.LastName)
+	assert.Equal(t, "alice@corp.example", user.Email)
+	assert.Equal(t, "aliddell", user.Username)
+	assert.Equal(t, models.UserStatusDeactivated, user.Status)
+	assert.Empty(t, user.ExternalID)
+
+	require.Nil(t, ApplyPatch(user, []PatchOperation{{Op: "replace", Path: "active", Value: true}}))
+	assert.Equal(t, models.UserStatusActive, user.Status)
+
+	err := ApplyPatch(user, []PatchOperation{{Op: "remove", Path: "userName"}})
+	require.NotNil(t, err)
+	assert.Equal(t, 400, err.StatusCode())
+	assert.NotNil(t, ApplyPatch(user, []PatchOperation{{Op: "replace", Path: "role", Value: "admin"}}))
+	assert.NotNil(t, ApplyPatch(user, []PatchOperation{{Op: "replace", Path: "active", Value: "maybe"}}))
+}
+
+func TestUserApplyTo(t *testing.T) {
+	active := false
+	resource := &User{
+		UserName: "alice",
+		Emails:   []Email{{Value: "home@example.com"}, {Value: "work@example.com", Primary: true}},
+		Active:   &active,
+	}
+
+	user := &models.User{FirstName: "Old", ExternalID: "old"}
+	require.Nil(t, resource.ApplyTo(user))
+	assert.Equal(t, "work@example.com", user.Email)
+	assert.Equal(t, models.UserStatusDeactivated, user.Status)
+	assert.Empty(t, user.FirstName)
+	assert.Empty(t, user.ExternalID)
+
+	back := FromUser(user, "")
+	assert.False(t, *back.Active)
+	assert.Equal(t, "work@example.com", back.Emails[0].Value)
+
+	assert.NotNil(t, (&User{UserName: "alice"}).ApplyTo(user), "email is required")
+}
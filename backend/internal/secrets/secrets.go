@@ -0,0 +1,175 @@
+// Package secrets fetches settings such as credentials from HashiCorp Vault
+// or AWS Secrets Manager, so they need not be kept in the environment.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider fetches secrets as setting names and values, such as
+// JWT_SECRET or MINIO_SECRET_KEY
+type Provider interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// Options selects and sets up a provider
+type Options struct {
+	Provider string // vault or aws
+
+	VaultAddr  string
+	VaultToken string
+	VaultMount string // of the KV version 2 secrets engine
+	VaultPath  string
+
+	AWSRegion          string
+	AWSSecretID        string // name or ARN
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	AWSEndpoint        string // instead of the regional endpoint, for compatible services
+}
+
+// New returns the provider opts selects
+func New(opts Options) (Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch strings.ToLower(opts.Provider) {
+	case "vault":
+		if opts.VaultAddr == "" || opts.VaultToken == "" || opts.VaultPath == "" {
+			return nil, errors.New("vault secrets need an address, a token and a path")
+		}
+		return &Vault{
+			addr:   strings.TrimSuffix(opts.VaultAddr, "/"),
+			token:  opts.VaultToken,
+			mount:  strings.Trim(opts.VaultMount, "/"),
+			path:   strings.Trim(opts.VaultPath, "/"),
+			client: client,
+		}, nil
+	case "aws":
+		if opts.AWSRegion == "" || opts.AWSSecretID == "" || opts.AWSAccessKeyID == "" || opts.AWSSecretAccessKey == "" {
+			return nil, errors.New("AWS secrets need a region, a secret ID and credentials")
+		}
+		endpoint := opts.AWSEndpoint
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", opts.AWSRegion)
+		}
+		return &AWSSecretsManager{
+			endpoint:     strings.TrimSuffix(endpoint, "/"),
+			region:       opts.AWSRegion,
+			secretID:     opts.AWSSecretID,
+			accessKey:    opts.AWSAccessKeyID,
+			secretKey:    opts.AWSSecretAccessKey,
+			sessionToken: opts.AWSSessionToken,
+			client:       client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", opts.Provider)
+	}
+}
+
+// Vault reads one secret of a KV version 2 secrets engine, whose keys are
+// the setting names
+type Vault struct {
+	addr   string
+	token  string
+	mount  string
+	path   string
+	client *http.Client
+}
+
+func (v *Vault) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/%s/data/%s", v.addr, v.mount, v.path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := do(v.client, req, "Vault", &body); err != nil {
+		return nil, err
+	}
+	return stringValues(body.Data.Data)
+}
+
+// AWSSecretsManager reads one secret of AWS Secrets Manager, which holds
+// a JSON object keyed by setting names
+type AWSSecretsManager struct {
+	endpoint     string
+	region       string
+	secretID     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+func (a *AWSSecretsManager) Fetch(ctx context.Context) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": a.secretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/", strings.NewReader(string(payload)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, payload, a.accessKey, a.secretKey, a.sessionToken, a.region, "secretsmanager", time.Now())
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := do(a.client, req, "AWS Secrets Manager", &body); err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := json.Unmarshal([]byte(body.SecretString), &values); err != nil {
+		return nil, fmt.Errorf("AWS secret %s must hold a JSON object: %w", a.secretID, err)
+	}
+	return stringValues(values)
+}
+
+// do sends req and decodes the JSON response into out. Error responses
+// are reported without their body, which may echo the request.
+func do(client *http.Client, req *http.Request, service string, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%s answered with status %d", service, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", service, err)
+	}
+	return nil
+}
+
+// stringValues turns the values of a secret into strings, formatting
+// numbers and booleans as written
+func stringValues(values map[string]any) (map[string]string, error) {
+	secrets := make(map[string]string, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			secrets[key] = v
+		case float64, bool:
+			secrets[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("secret %s must be a string, number or boolean", key)
+		}
+	}
+	return secrets, nil
+}
@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "/v1/secret/data/minio-storage/prod", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data": {"data": {"JWT_SECRET": "from-vault", "SMTP_PORT": 2525, "MINIO_USE_SSL": true}, "metadata": {"version": 3}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(Options{Provider: "vault", VaultAddr: server.URL + "/", VaultToken: "token", VaultMount: "secret", VaultPath: "/minio-storage/prod"})
+	require.NoError(t, err)
+	values, err := provider.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"JWT_SECRET": "from-vault", "SMTP_PORT": "2525", "MINIO_USE_SSL": "true"}, values)
+
+	provider, err = New(Options{Provider: "vault", VaultAddr: server.URL, VaultToken: "wrong", VaultMount: "secret", VaultPath: "minio-storage/prod"})
+	require.NoError(t, err)
+	_, err = provider.Fetch(context.Background())
+	assert.ErrorContains(t, err, "status 403")
+}
+
+func TestAWSSecretsManagerFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/secretsmanager/aws4_request")
+
+		var body struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "minio-storage/prod", body.SecretId)
+		_, _ = w.Write([]byte(`{"Name": "minio-storage/prod", "SecretString": "{\"MINIO_SECRET_KEY\": \"from-aws\"}"}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(Options{
+		Provider:           "aws",
+		AWSRegion:          "eu-west-1",
+		AWSSecretID:        "minio-storage/prod",
+		AWSAccessKeyID:     "AKID",
+		AWSSecretAccessKey: "secret",
+		AWSSessionToken:    "session",
+		AWSEndpoint:        server.URL,
+	})
+	require.NoError(t, err)
+	values, err := provider.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"MINIO_SECRET_KEY": "from-aws"}, values)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(Options{Provider: "keychain"})
+	assert.Error(t, err)
+	_, err = New(Options{Provider: "vault", VaultAddr: "http://vault:8200"})
+	assert.Error(t, err)
+	_, err = New(Options{Provider: "aws", AWSRegion: "eu-west-1", AWSSecretID: "s"})
+	assert.Error(t, err)
+}
+
+// The get-vanilla case of the AWS Signature Version 4 test suite
+func TestSignV4(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	req.Header = http.Header{}
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
@@ -0,0 +1,148 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+)
+
+// Image size of seeded pictures
+const (
+	imageWidth  = 320
+	imageHeight = 240
+)
+
+type fileKind struct {
+	name        string // format with a number
+	contentType string
+	description string
+	folders     []string
+}
+
+var fileKinds = []fileKind{
+	{"IMG_%d.png", "image/png", "Holiday picture", []string{"/photos", "/photos/2024", "/photos/2025"}},
+	{"screenshot-%d.png", "image/png", "", []string{"/", "/screenshots"}},
+	{"meeting-notes-%d.txt", "text/plain", "Notes from the weekly meeting", []string{"/documents", "/projects/website"}},
+	{"report-%d.csv", "text/csv", "Monthly figures", []string{"/reports", "/reports/2025"}},
+	{"README-%d.md", "text/markdown", "", []string{"/projects/website", "/projects/mobile-app"}},
+	{"export-%d.json", "application/json", "Settings export", []string{"/", "/backups"}},
+}
+
+// fileContent makes the content of a file of contentType; text content
+// grows to about size bytes
+func fileContent(rng *rand.Rand, contentType string, size int) ([]byte, error) {
+	size = max(size, 256)
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/png":
+		if err := png.Encode(&buf, gradient(rng)); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+	case "text/csv":
+		buf.WriteString("date,region,product,units,revenue\n")
+		for day := 1; buf.Len() < size; day++ {
+			units := rng.IntN(500)
+			fmt.Fprintf(&buf, "2025-%02d-%02d,%s,%s,%d,%.2f\n", day/28%12+1, day%28+1,
+				pick(rng, regions), pick(rng, products), units, float64(units)*(5+rng.Float64()*45))
+		}
+	case "application/json":
+		settings := map[string]any{"theme": pick(rng, []string{"light", "dark", "system"}), "language": "en", "notifications": rng.IntN(2) == 0}
+		var items []map[string]any
+		for i := 0; len(items)*64 < size; i++ {
+			items = append(items, map[string]any{"id": i + 1, "name": pick(rng, products), "region": pick(rng, regions), "active": rng.IntN(4) > 0})
+		}
+		settings["items"] = items
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(settings); err != nil {
+			return nil, err
+		}
+	case "text/markdown":
+		fmt.Fprintf(&buf, "# %s\n\n", title(rng))
+		for buf.Len() < size {
+			fmt.Fprintf(&buf, "## %s\n\n%s\n\n", capitalize(pick(rng, tagWords)), paragraph(rng))
+		}
+	default:
+		for buf.Len() < size {
+			buf.WriteString(paragraph(rng) + "\n\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// gradient is a picture blending two random colours
+func gradient(rng *rand.Rand) image.Image {
+	from := color.RGBA{uint8(rng.IntN(256)), uint8(rng.IntN(256)), uint8(rng.IntN(256)), 255}
+	to := color.RGBA{uint8(rng.IntN(256)), uint8(rng.IntN(256)), uint8(rng.IntN(256)), 255}
+	img := image.NewRGBA(image.Rect(0, 0, imageWidth, imageHeight))
+	for y := range imageHeight {
+		for x := range imageWidth {
+			t := float64(x+y) / float64(imageWidth+imageHeight)
+			img.Set(x, y, color.RGBA{
+				R: blend(from.R, to.R, t),
+				G: blend(from.G, to.G, t),
+				B: blend(from.B, to.B, t),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+func blend(a, b uint8, t float64) uint8 {
+	return uint8(float64(a)*(1-t) + float64(b)*t)
+}
+
+var firstNames = []string{
+	"Alice", "Amara", "Ben", "Carlos", "Chen", "Daniel", "Elena", "Fatima", "Grace", "Hiro",
+	"Ines", "James", "Jonas", "Kwame", "Laura", "Leila", "Lucas", "Maya", "Mohammed", "Nadia",
+	"Noah", "Olga", "Priya", "Rafael", "Sara", "Sofia", "Tom", "Yuki", "Zainab", "Zoe",
+}
+
+var lastNames = []string{
+	"Andersen", "Baker", "Costa", "Dubois", "Eriksson", "Fischer", "Garcia", "Haddad", "Ito", "Jansen",
+	"Kowalski", "Lee", "Mensah", "Moreno", "Nakamura", "Novak", "Okafor", "Patel", "Rossi", "Schmidt",
+	"Silva", "Tanaka", "Nguyen", "Wagner", "Walker", "Wang", "Yilmaz", "Zhang",
+}
+
+var titles = []string{
+	"Getting started with %s",
+	"What I learned about %s this year",
+	"A practical guide to %s",
+	"Why %s matters more than you think",
+	"%s: common mistakes and how to avoid them",
+	"Notes on %s",
+	"Ten tips for better %s",
+}
+
+var topics = []string{
+	"object storage", "remote work", "home gardening", "photography", "distributed systems",
+	"sourdough baking", "cycling", "personal finance", "open source", "trail running",
+	"team meetings", "data backups", "travel on a budget", "language learning", "code review",
+}
+
+var sentences = []string{
+	"%s is easier to get right with a bit of planning.",
+	"most people underestimate how much time %s takes at first.",
+	"the best advice I got about %s was to start small.",
+	"after a few months, %s became part of my weekly routine.",
+	"there are plenty of tools for %s, but the basics matter most.",
+	"I kept notes on %s and the patterns became obvious.",
+	"a friend convinced me to look at %s from a different angle.",
+	"it is worth measuring before changing anything about %s.",
+	"the community around %s is welcoming to beginners.",
+	"budget and patience are the two limits of %s.",
+}
+
+var tagWords = []string{
+	"howto", "tips", "storage", "travel", "food", "fitness", "tech", "career", "weekend", "review",
+	"beginners", "productivity",
+}
+
+var regions = []string{"north", "south", "east", "west", "central"}
+
+var products = []string{"basic plan", "pro plan", "team plan", "storage add-on", "support hours"}
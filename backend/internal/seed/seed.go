@@ -0,0 +1,268 @@
+// Package seed fills a storage with made up users, posts and files, for
+// demos, load tests and frontend development.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// Store is where seeded data is written; the storage service implements it
+type Store interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	CreatePost(ctx context.Context, post *models.Post) error
+	StoreFile(ctx context.Context, file *models.File, reader io.Reader) error
+}
+
+// Options says how much to generate
+type Options struct {
+	Users int
+	Posts int // spread over the seeded users
+	Files int // spread over the seeded users
+
+	// PasswordHash is the hashed password of every seeded user
+	PasswordHash string
+	// EmailDomain is the domain of the seeded email addresses
+	EmailDomain string
+	// FileSize is the approximate size of text files; images have a fixed
+	// size
+	FileSize int
+	// Seed makes the generated data repeatable
+	Seed uint64
+	// Concurrency is how many objects are written at once
+	Concurrency int
+
+	// FileStored, if set, is called with every stored file, e.g. to queue
+	// its background jobs
+	FileStored func(ctx context.Context, file *models.File)
+}
+
+// Result counts what was written
+type Result struct {
+	Users int
+	Posts int
+	Files int
+	Bytes int64
+}
+
+// Run writes users first, then posts and files owned by them. It stops at
+// the first error and returns what was written up to then.
+func Run(ctx context.Context, store Store, opts Options) (Result, error) {
+	var result Result
+	if opts.Users <= 0 && (opts.Posts > 0 || opts.Files > 0) {
+		return result, errors.New("posts and files need at least one seeded user")
+	}
+	if opts.EmailDomain == "" {
+		opts.EmailDomain = "example.com"
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, 0))
+	w := newWriter(ctx, max(opts.Concurrency, 1))
+
+	users := make([]*models.User, opts.Users)
+	taken := make(map[string]bool, opts.Users)
+	for i := range users {
+		users[i] = newUser(rng, taken, opts.EmailDomain, opts.PasswordHash)
+		user := users[i]
+		w.do(func(ctx context.Context) error {
+			if err := store.CreateUser(ctx, user); err != nil {
+				return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+			}
+			w.count(func() { result.Users++ })
+			return nil
+		})
+	}
+	if err := w.wait(); err != nil {
+		return result, err
+	}
+
+	w = newWriter(ctx, max(opts.Concurrency, 1))
+	for range opts.Posts {
+		post := newPost(rng, users[rng.IntN(len(users))].ID)
+		w.do(func(ctx context.Context) error {
+			if err := store.CreatePost(ctx, post); err != nil {
+				return fmt.Errorf("failed to create post: %w", err)
+			}
+			w.count(func() { result.Posts++ })
+			return nil
+		})
+	}
+	for range opts.Files {
+		// Content is made by the writers, each from its own seed, so only
+		// the files in flight are held in memory
+		file, fileSeed := newFile(rng, users[rng.IntN(len(users))].ID), rng.Uint64()
+		w.do(func(ctx context.Context) error {
+			content, err := fileContent(rand.New(rand.NewPCG(fileSeed, 1)), file.ContentType, opts.FileSize)
+			if err != nil {
+				return err
+			}
+			file.Size = int64(len(content))
+			if err := store.StoreFile(ctx, file, bytes.NewReader(content)); err != nil {
+				return fmt.Errorf("failed to store file %s: %w", file.OriginalName, err)
+			}
+			if opts.FileStored != nil {
+				opts.FileStored(ctx, file)
+			}
+			w.count(func() { result.Files++; result.Bytes += file.Size })
+			return nil
+		})
+	}
+	return result, w.wait()
+}
+
+// writer runs writes at most limit at a time and keeps the first error,
+// after which it starts no more
+type writer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    error
+}
+
+func newWriter(ctx context.Context, limit int) *writer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &writer{ctx: ctx, cancel: cancel, sem: make(chan struct{}, limit)}
+}
+
+func (w *writer) do(write func(ctx context.Context) error) {
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.ctx.Done():
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() { <-w.sem }()
+		if err := write(w.ctx); err != nil {
+			w.mu.Lock()
+			if w.err == nil {
+				w.err = err
+			}
+			w.mu.Unlock()
+			w.cancel()
+		}
+	}()
+}
+
+// count updates the result under the writer's lock
+func (w *writer) count(update func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	update()
+}
+
+func (w *writer) wait() error {
+	w.wg.Wait()
+	defer w.cancel()
+	if w.err != nil {
+		return w.err
+	}
+	return w.ctx.Err()
+}
+
+func newUser(rng *rand.Rand, taken map[string]bool, domain, passwordHash string) *models.User {
+	first, last := pick(rng, firstNames), pick(rng, lastNames)
+	username := strings.ToLower(first + "." + last)
+	for n := 2; taken[username]; n++ {
+		username = fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), n)
+	}
+	taken[username] = true
+
+	// Seeded addresses cannot receive the verification email
+	now := time.Now()
+	return &models.User{
+		Username:        username,
+		Email:           username + "@" + domain,
+		Password:        passwordHash,
+		FirstName:       first,
+		LastName:        last,
+		Role:            models.RoleUser,
+		EmailVerifiedAt: &now,
+	}
+}
+
+func newPost(rng *rand.Rand, userID string) *models.Post {
+	paragraphs := make([]string, 2+rng.IntN(5))
+	for i := range paragraphs {
+		paragraphs[i] = paragraph(rng)
+	}
+	tags := make([]string, 0, 3)
+	for range 1 + rng.IntN(3) {
+		if tag := pick(rng, tagWords); !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	status := models.PostStatusPublished
+	switch n := rng.IntN(10); {
+	case n < 2:
+		status = models.PostStatusDraft
+	case n < 3:
+		status = models.PostStatusArchived
+	}
+
+	return &models.Post{
+		UserID:  userID,
+		Title:   title(rng),
+		Content: strings.Join(paragraphs, "\n\n"),
+		Summary: sentence(rng),
+		Tags:    tags,
+		Status:  status,
+	}
+}
+
+func newFile(rng *rand.Rand, userID string) *models.File {
+	kind := pick(rng, fileKinds)
+	name := fmt.Sprintf(kind.name, rng.IntN(9000)+1000)
+
+	visibility := models.VisibilityPrivate
+	if rng.IntN(5) == 0 {
+		visibility = models.VisibilityPublic
+	}
+	return &models.File{
+		UserID:       userID,
+		FileName:     name,
+		OriginalName: name,
+		ContentType:  kind.contentType,
+		Folder:       pick(rng, kind.folders),
+		Description:  kind.description,
+		Metadata:     map[string]string{"seeded": "true"},
+		Visibility:   visibility,
+	}
+}
+
+func paragraph(rng *rand.Rand) string {
+	parts := make([]string, 3+rng.IntN(4))
+	for i := range parts {
+		parts[i] = sentence(rng)
+	}
+	return strings.Join(parts, " ")
+}
+
+func sentence(rng *rand.Rand) string {
+	return capitalize(fmt.Sprintf(pick(rng, sentences), pick(rng, topics)))
+}
+
+func title(rng *rand.Rand) string {
+	return capitalize(fmt.Sprintf(pick(rng, titles), pick(rng, topics)))
+}
+
+func capitalize(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func pick[T any](rng *rand.Rand, items []T) T {
+	return items[rng.IntN(len(items))]
+}
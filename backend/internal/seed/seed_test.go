@@ -0,0 +1,137 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	users   []*models.User
+	posts   []*models.Post
+	files   map[string][]byte
+	failing bool
+}
+
+func (s *memoryStore) CreateUser(_ context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user.ID = uuid.New().String()
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *memoryStore) CreatePost(_ context.Context, post *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errors.New("disk full")
+	}
+	s.posts = append(s.posts, post)
+	return nil
+}
+
+func (s *memoryStore) StoreFile(_ context.Context, file *models.File, reader io.Reader) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	file.ID = uuid.New().String()
+	s.files[file.ID] = content
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	store := &memoryStore{}
+	var stored atomic.Int32
+	result, err := Run(context.Background(), store, Options{
+		Users: 12, Posts: 40, Files: 20,
+		PasswordHash: "hash", FileSize: 2048, Seed: 7, Concurrency: 4,
+		FileStored: func(context.Context, *models.File) { stored.Add(1) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 12, result.Users)
+	assert.Equal(t, 40, result.Posts)
+	assert.Equal(t, 20, result.Files)
+	assert.EqualValues(t, 20, stored.Load())
+
+	userIDs := map[string]bool{}
+	usernames := map[string]bool{}
+	for _, user := range store.users {
+		assert.False(t, usernames[user.Username], "duplicate username %s", user.Username)
+		usernames[user.Username] = true
+		userIDs[user.ID] = true
+		assert.Equal(t, "hash", user.Password)
+		assert.Equal(t, user.Username+"@example.com", user.Email)
+	}
+	for _, post := range store.posts {
+		assert.True(t, userIDs[post.UserID])
+		assert.NotEmpty(t, post.Title)
+		assert.NotEmpty(t, post.Tags)
+	}
+
+	var total int64
+	for _, content := range store.files {
+		total += int64(len(content))
+	}
+	assert.Equal(t, total, result.Bytes)
+}
+
+func TestRunIsRepeatable(t *testing.T) {
+	first, second := &memoryStore{}, &memoryStore{}
+	opts := Options{Users: 5, Posts: 10, Seed: 42, Concurrency: 1}
+	_, err := Run(context.Background(), first, opts)
+	require.NoError(t, err)
+	_, err = Run(context.Background(), second, opts)
+	require.NoError(t, err)
+
+	for i := range first.users {
+		assert.Equal(t, first.users[i].Username, second.users[i].Username)
+	}
+	for i := range first.posts {
+		assert.Equal(t, first.posts[i].Title, second.posts[i].Title)
+		assert.Equal(t, first.posts[i].Content, second.posts[i].Content)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := Run(context.Background(), &memoryStore{}, Options{Posts: 1})
+	assert.Error(t, err)
+
+	result, err := Run(context.Background(), &memoryStore{failing: true}, Options{Users: 2, Posts: 5, Concurrency: 2})
+	assert.ErrorContains(t, err, "disk full")
+	assert.Equal(t, 2, result.Users)
+	assert.Zero(t, result.Posts)
+}
+
+func TestFileContent(t *testing.T) {
+	for _, kind := range fileKinds {
+		content, err := fileContent(rand.New(rand.NewPCG(1, 2)), kind.contentType, 4096)
+		require.NoError(t, err, kind.contentType)
+		if kind.contentType == "image/png" {
+			img, err := png.Decode(bytes.NewReader(content))
+			require.NoError(t, err)
+			assert.Equal(t, imageWidth, img.Bounds().Dx())
+			continue
+		}
+		assert.GreaterOrEqual(t, len(content), 4096, kind.contentType)
+		assert.Less(t, len(content), 8192, kind.contentType)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// Access log operations
+//
+// Each download is stored as its own object below the file, named so that
+// keys sort by time: files/<user>/<file>/access/<unix nanos>-<random>.json.
+// Keeping them under the file's prefix means they are removed with it. The
+// statistics counters add each download up, so file statistics do not read
+// the whole log.
+func (s *StorageService) RecordAccess(ctx context.Context, entry *models.AccessLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access log entry: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to generate access log key: %w", err)
+	}
+	objectName := fmt.Sprintf("%s%019d-%s.json", accessLogPrefix(entry.OwnerID, entry.FileID), entry.AccessedAt.UnixNano(), hex.EncodeToString(suffix))
+
+	_, err = s.client.PutObject(ctx, s.filesBucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store access log entry: %w", err)
+	}
+
+	s.trackAccess(ctx, entry)
+	return nil
+}
+
+// trackAccess counts a logged download. Statistics must not fail the log,
+// so errors are only logged; a rebuild corrects the counts.
+func (s *StorageService) trackAccess(ctx context.Context, entry *models.AccessLogEntry) {
+	if s.stats == nil {
+		return
+	}
+	if err := s.stats.TrackAccess(ctx, entry); err != nil {
+		s.log(ctx).Warn("Failed to update file statistics", "fileId", entry.FileID, "error", err)
+	}
+}
+
+// forgetAccess drops the download counts of a deleted file
+func (s *StorageService) forgetAccess(ctx context.Context, fileID string) {
+	if s.stats == nil {
+		return
+	}
+	if err := s.stats.ForgetAccess(ctx, fileID); err != nil {
+		s.log(ctx).Warn("Failed to update file statistics", "fileId", fileID, "error", err)
+	}
+}
+
+// GetFileStats returns the download statistics of file, from the counters
+// once they are built and otherwise by reading its access log
+func (s *StorageService) GetFileStats(ctx context.Context, file *models.File) (*models.FileStats, error) {
+	if s.stats != nil {
+		ready, err := s.stats.Ready(ctx)
+		if err == nil && ready {
+			return s.stats.FileAccess(ctx, file.ID)
+		}
+	}
+
+	stats := models.NewFileStats(file.ID)
+	if err := s.eachAccess(ctx, file, stats.Add); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// eachAccess calls fn with every entry of the access log of file
+func (s *StorageService) eachAccess(ctx context.Context, file *models.File, fn func(entry *models.AccessLogEntry)) error {
+	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
+		Prefix:    accessLogPrefix(file.UserID, file.ID),
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list access log: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.filesBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var entry models.AccessLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+
+		fn(&entry)
+	}
+
+	return nil
+}
+
+func accessLogPrefix(userID, fileID string) string {
+	return fmt.Sprintf("files/%s/%s/access/", userID, fileID)
+}
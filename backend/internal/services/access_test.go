@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFileStats(t *testing.T) {
+	s, server := newTestStorage(t)
+	ctx := context.Background()
+
+	file := &models.File{UserID: "owner", OriginalName: "a.txt", FileName: "a.txt", ContentType: "text/plain", Size: 5}
+	require.NoError(t, s.StoreFile(ctx, file, strings.NewReader("hello")))
+	download := func(userID string) {
+		require.NoError(t, s.RecordAccess(ctx, &models.AccessLogEntry{
+			FileID: file.ID, OwnerID: file.UserID, UserID: userID, Via: "direct", Bytes: 5, AccessedAt: time.Now(),
+		}))
+	}
+	download("u1")
+	download("")
+
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { client.Close() })
+	counter := stats.NewCounter(client)
+	s.SetStatsCounter(counter)
+
+	// Until the counters are built the access log is read
+	fileStats, err := s.GetFileStats(ctx, file)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), fileStats.Downloads)
+
+	rebuilt, err := s.RebuildStats(ctx)
+	require.NoError(t, err)
+	require.True(t, rebuilt)
+	download("u2")
+
+	// Afterwards only the counters are, which the rebuild filled in
+	logged := server.Keys("files", accessLogPrefix(file.UserID, file.ID))
+	require.Len(t, logged, 3)
+	require.NoError(t, s.client.RemoveObject(ctx, "files", logged[0], minio.RemoveObjectOptions{}))
+	fileStats, err = s.GetFileStats(ctx, file)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fileStats.Downloads)
+	assert.Equal(t, int64(2), fileStats.UniqueUsers)
+	assert.Equal(t, int64(1), fileStats.AnonymousCount)
+	assert.Equal(t, int64(15), fileStats.BytesServed)
+
+	require.NoError(t, s.DeleteFile(ctx, file.ID))
+	fileStats, err = counter.FileAccess(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Zero(t, fileStats.Downloads)
+}
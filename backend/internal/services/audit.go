@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	auditPrefix      = "audit/"
+	adminAuditPrefix = "admin-audit/"
+)
+
+// Audit log operations
+//
+// Audit events and admin actions are never modified or deleted. Each is
+// stored as its own object in the users bucket, named so that keys sort
+// by time: audit/<unix nanos>-<random>.json for audit events and
+// admin-audit/<unix nanos>-<random>.json for admin actions.
+
+// RecordAuditEvent stores event in the audit log
+func (s *StorageService) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if err := s.appendLog(ctx, auditPrefix, event.OccurredAt, event); err != nil {
+		return fmt.Errorf("failed to store audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns a page of the events matching filter, oldest
+// first, and the number of matching events. Only the objects between
+// filter.From and filter.To are read.
+func (s *StorageService) ListAuditEvents(ctx context.Context, filter models.AuditFilter, pagination models.Pagination) ([]*models.AuditEvent, int64, error) {
+	events, total, err := listLog(ctx, s, auditPrefix, filter.From, filter.To, filter.Matches, pagination)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, total, nil
+}
+
+// RecordAdminAction stores action in the admin audit log
+func (s *StorageService) RecordAdminAction(ctx context.Context, action *models.AdminAction) error {
+	if err := s.appendLog(ctx, adminAuditPrefix, action.OccurredAt, action); err != nil {
+		return fmt.Errorf("failed to store admin action: %w", err)
+	}
+	return nil
+}
+
+// ListAdminActions returns a page of the admin actions matching filter,
+// oldest first, and the number of matching actions. Only the objects
+// between filter.From and filter.To are read.
+func (s *StorageService) ListAdminActions(ctx context.Context, filter models.AdminActionFilter, pagination models.Pagination) ([]*models.AdminAction, int64, error) {
+	actions, total, err := listLog(ctx, s, adminAuditPrefix, filter.From, filter.To, filter.Matches, pagination)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list admin actions: %w", err)
+	}
+	return actions, total, nil
+}
+
+// appendLog stores entry as a new object of the log under prefix
+func (s *StorageService) appendLog(ctx context.Context, prefix string, at time.Time, entry any) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	objectName := fmt.Sprintf("%s%019d-%s.json", prefix, at.UnixNano(), hex.EncodeToString(suffix))
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	return err
+}
+
+// listLog returns a page of the entries of the log under prefix between
+// from and to that match, oldest first, and the number of matching
+// entries. Zero times leave the range open.
+func listLog[T any](ctx context.Context, s *StorageService, prefix string, from, to time.Time, match func(*T) bool, pagination models.Pagination) ([]*T, int64, error) {
+	entries := []*T{}
+	var total int64
+
+	opts := minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}
+	if !from.IsZero() {
+		opts.StartAfter = fmt.Sprintf("%s%019d", prefix, from.UnixNano())
+	}
+	end := ""
+	if !to.IsZero() {
+		end = fmt.Sprintf("%s%019d~", prefix, to.UnixNano())
+	}
+
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range s.client.ListObjects(listCtx, s.usersBucket, opts) {
+		if object.Err != nil {
+			return nil, 0, object.Err
+		}
+		if end != "" && object.Key > end {
+			break
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var entry T
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		if !match(&entry) {
+			continue
+		}
+
+		total++
+		if total <= int64(pagination.Offset) || len(entries) >= pagination.PageSize {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, total, nil
+}
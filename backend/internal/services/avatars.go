@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrAvatarNotFound = errors.New("avatar not found")
+
+const avatarPrefix = "avatars/"
+
+// Avatar operations
+//
+// Uploaded avatars live under avatars/<user id>/<size> in the files
+// bucket, one JPEG per size. They do not count towards any quota.
+
+// StoreAvatar replaces the avatar images of a user, keyed by size
+func (s *StorageService) StoreAvatar(ctx context.Context, userID, contentType string, images map[string][]byte) error {
+	for size, data := range images {
+		_, err := s.client.PutObject(ctx, s.filesBucket, avatarObjectName(userID, size), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: contentType,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store avatar: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetAvatar returns the avatar image of a user in size and its ETag
+func (s *StorageService) GetAvatar(ctx context.Context, userID, size string) (io.ReadCloser, string, error) {
+	object, err := s.client.GetObject(ctx, s.filesBucket, avatarObjectName(userID, size), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get avatar: %w", err)
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, "", ErrAvatarNotFound
+		}
+		return nil, "", fmt.Errorf("failed to get avatar: %w", err)
+	}
+	return object, info.ETag, nil
+}
+
+// DeleteAvatar removes the uploaded avatar images of a user, if any
+func (s *StorageService) DeleteAvatar(ctx context.Context, userID string) error {
+	for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{Prefix: avatarPrefix + userID + "/", Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list avatars: %w", object.Err)
+		}
+		if err := s.client.RemoveObject(ctx, s.filesBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete avatar: %w", err)
+		}
+	}
+	return nil
+}
+
+func avatarObjectName(userID, size string) string {
+	return avatarPrefix + userID + "/" + size
+}
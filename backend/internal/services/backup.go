@@ -0,0 +1,69 @@
+package services
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Backup entries carry the content type and user metadata of their object
+// as PAX records under these keys
+const (
+	backupContentTypeRecord = "MINIOSTORAGE.content-type"
+	backupMetadataPrefix    = "MINIOSTORAGE.meta."
+)
+
+// Backup writes every object of the users, posts and files buckets to tw,
+// named bucket/key, and returns how many it wrote. Objects written while
+// it runs may or may not be included.
+func (s *StorageService) Backup(ctx context.Context, tw *tar.Writer) (int, error) {
+	count := 0
+	for _, bucket := range []string{s.usersBucket, s.postsBucket, s.filesBucket} {
+		for info := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+			if info.Err != nil {
+				return count, fmt.Errorf("failed to list %s: %w", bucket, info.Err)
+			}
+			if err := s.backupObject(ctx, tw, bucket, info.Key); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *StorageService) backupObject(ctx context.Context, tw *tar.Writer, bucket, key string) error {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read %s/%s: %w", bucket, key, err)
+	}
+	defer object.Close()
+	stat, err := object.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s/%s: %w", bucket, key, err)
+	}
+
+	records := map[string]string{backupContentTypeRecord: stat.ContentType}
+	for name, value := range stat.UserMetadata {
+		records[backupMetadataPrefix+name] = value
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:       path.Join(bucket, key),
+		Mode:       0o600,
+		Size:       stat.Size,
+		ModTime:    stat.LastModified,
+		Format:     tar.FormatPAX,
+		PAXRecords: records,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, object); err != nil {
+		return fmt.Errorf("failed to back up %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
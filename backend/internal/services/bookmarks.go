@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/minio/minio-go/v7"
+)
+
+const bookmarkPrefix = "bookmarks/"
+
+// newestFirst sorts lists by creation time, newest first
+var newestFirst = models.QueryOptions{Sort: []models.SortField{{Field: "createdAt", Desc: true}}}
+
+// Bookmark operations
+//
+// Bookmarks live under bookmarks/<user id>/<post id>.json in the users
+// bucket. The statistics count them per post, so posts show how often
+// they were bookmarked without reading every bookmark.
+
+// AddBookmark bookmarks a post for a user. Bookmarking it again keeps the
+// first bookmark, which it returns.
+func (s *StorageService) AddBookmark(ctx context.Context, userID, postID string) (*models.Bookmark, error) {
+	name := bookmarkObjectName(userID, postID)
+
+	var bookmark models.Bookmark
+	err := s.readJSONObject(ctx, name, &bookmark)
+	if err == nil {
+		return &bookmark, nil
+	}
+	if !errors.Is(err, errObjectNotFound) {
+		return nil, fmt.Errorf("failed to read bookmark: %w", err)
+	}
+
+	bookmark = models.Bookmark{UserID: userID, PostID: postID, CreatedAt: time.Now()}
+	if err := s.writeJSONObject(ctx, name, &bookmark); err != nil {
+		return nil, fmt.Errorf("failed to store bookmark: %w", err)
+	}
+	s.trackStats(ctx, stats.Bookmarks, bookmarkStatsID(userID, postID), "", 0, bookmark.CreatedAt, &bookmark)
+	return &bookmark, nil
+}
+
+// RemoveBookmark removes the bookmark of a user on a post, if there is one
+func (s *StorageService) RemoveBookmark(ctx context.Context, userID, postID string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, bookmarkObjectName(userID, postID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete bookmark: %w", err)
+	}
+	s.untrackStats(ctx, stats.Bookmarks, bookmarkStatsID(userID, postID))
+	return nil
+}
+
+// ListBookmarks returns the page of a user's bookmarks that pagination
+// selects, newest first, and how many there are
+func (s *StorageService) ListBookmarks(ctx context.Context, userID string, pagination models.Pagination) ([]*models.Bookmark, int64, error) {
+	return listPage[models.Bookmark](ctx, s, s.usersBucket, bookmarkPrefix+userID+"/", nil, pagination, newestFirst)
+}
+
+// CountBookmarks sets the BookmarkCount of posts. Without statistics the
+// bookmarks are counted by listing them.
+func (s *StorageService) CountBookmarks(ctx context.Context, posts ...*models.Post) {
+	if s.stats != nil {
+		for _, post := range posts {
+			count, err := s.stats.Count(ctx, stats.Bookmarks, stats.Scope(map[string]string{"postId": strings.ToLower(post.ID)}))
+			if err != nil {
+				s.log(ctx).Warn("Failed to read bookmark count", "postId", post.ID, "error", err)
+				continue
+			}
+			post.BookmarkCount = count
+		}
+		return
+	}
+
+	counts := make(map[string]int64, len(posts))
+	for _, key := range s.bookmarkKeys(ctx) {
+		counts[strings.TrimSuffix(key[strings.LastIndex(key, "/")+1:], ".json")]++
+	}
+	for _, post := range posts {
+		post.BookmarkCount = counts[post.ID]
+	}
+}
+
+// deletePostBookmarks removes every bookmark of a deleted post
+func (s *StorageService) deletePostBookmarks(ctx context.Context, postID string) error {
+	for _, key := range s.bookmarkKeys(ctx) {
+		if !strings.HasSuffix(key, "/"+postID+".json") {
+			continue
+		}
+		userID := strings.TrimPrefix(strings.TrimSuffix(key, "/"+postID+".json"), bookmarkPrefix)
+		if err := s.RemoveBookmark(ctx, userID, postID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUserBookmarks removes every bookmark of a deleted user
+func (s *StorageService) deleteUserBookmarks(ctx context.Context, userID string) error {
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: bookmarkPrefix + userID + "/", Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list bookmarks: %w", object.Err)
+		}
+		postID := strings.TrimSuffix(strings.TrimPrefix(object.Key, bookmarkPrefix+userID+"/"), ".json")
+		if err := s.RemoveBookmark(ctx, userID, postID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bookmarkKeys returns the object names of every bookmark
+func (s *StorageService) bookmarkKeys(ctx context.Context) []string {
+	var keys []string
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: bookmarkPrefix, Recursive: true}) {
+		if object.Err == nil {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys
+}
+
+func bookmarkObjectName(userID, postID string) string {
+	return bookmarkPrefix + userID + "/" + postID + ".json"
+}
+
+// bookmarkStatsID identifies a bookmark in the statistics
+func bookmarkStatsID(userID, postID string) string {
+	return userID + "/" + postID
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/minio/minio-go/v7"
+)
+
+// bucketEvents are the bucket notifications ListenForChanges asks for
+var bucketEvents = []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"}
+
+// listenRetryDelay is how long to wait before listening again when MinIO
+// ended the notifications
+const listenRetryDelay = 5 * time.Second
+
+// ListenForChanges follows the notifications of the buckets until ctx is
+// cancelled, so objects changed outside the API, with mc or the console,
+// do not leave the caches and statistics stale. Changes made through the
+// API are seen too, which is harmless: caches are forgotten again and
+// statistics count each item once. It also makes every server forget its
+// cache entries as soon as another one writes.
+func (s *StorageService) ListenForChanges(ctx context.Context) {
+	seen := make(map[string]bool)
+	for _, bucket := range []string{s.usersBucket, s.postsBucket, s.filesBucket} {
+		if !seen[bucket] {
+			seen[bucket] = true
+			go s.listenBucket(ctx, bucket)
+		}
+	}
+}
+
+func (s *StorageService) listenBucket(ctx context.Context, bucket string) {
+	for {
+		for info := range s.client.ListenBucketNotification(ctx, bucket, "", "", bucketEvents) {
+			if info.Err != nil {
+				if ctx.Err() == nil {
+					s.logger.Warn("Bucket notifications interrupted", "bucket", bucket, "error", info.Err)
+				}
+				continue
+			}
+			for _, record := range info.Records {
+				// Keys are URL-encoded in notifications
+				key, err := url.QueryUnescape(record.S3.Object.Key)
+				if err != nil {
+					continue
+				}
+				s.SyncObject(ctx, bucket, key, strings.HasPrefix(record.EventName, "s3:ObjectRemoved:"))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// SyncObject brings the caches and statistics in line with a change to
+// the object key of bucket, which was written or removed
+func (s *StorageService) SyncObject(ctx context.Context, bucket, key string, removed bool) {
+	if bucket == s.usersBucket {
+		s.syncUsersObject(ctx, key, removed)
+	}
+	if bucket == s.postsBucket {
+		s.syncPostObject(ctx, key, removed)
+	}
+	if bucket == s.filesBucket {
+		s.syncFileObject(ctx, key, removed)
+	}
+}
+
+func (s *StorageService) syncUsersObject(ctx context.Context, key string, removed bool) {
+	switch {
+	case key == maintenanceObjectName:
+		s.maintenanceCache.forget("")
+	case strings.HasPrefix(key, webhookPrefix):
+		s.webhookCache.forget("")
+	case strings.HasPrefix(key, metadataSchemaPrefix):
+		s.schemaCache.forget("")
+	case strings.HasPrefix(key, "roles/"):
+		s.roleCache.forget(strings.TrimSuffix(strings.TrimPrefix(key, "roles/"), ".json"))
+	case strings.HasPrefix(key, "serviceaccounts/"):
+		s.serviceAccountCache.forget(strings.TrimSuffix(strings.TrimPrefix(key, "serviceaccounts/"), ".json"))
+	case strings.HasPrefix(key, "users/") && strings.HasSuffix(key, ".json"):
+		userID := strings.TrimSuffix(strings.TrimPrefix(key, "users/"), ".json")
+		s.statusCache.forget(userID)
+		s.userCache.forget(userID)
+		if removed {
+			s.untrackStats(ctx, stats.Users, userID)
+			return
+		}
+		if user, err := s.GetUser(ctx, userID); err == nil {
+			s.trackUser(ctx, user)
+			if err := s.indexUser(ctx, user); err != nil {
+				s.log(ctx).Warn("Failed to index user", "userId", userID, "error", err)
+			}
+		}
+	}
+}
+
+// syncPostObject counts the post stored under posts/<user>/<post>.json.
+// Posts move when their author changes, so a removed key only means the
+// post is gone when it is found nowhere else.
+func (s *StorageService) syncPostObject(ctx context.Context, key string, removed bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != "posts" || !strings.HasSuffix(parts[2], ".json") {
+		return
+	}
+	postID := strings.TrimSuffix(parts[2], ".json")
+	s.postCache.forget(postID)
+	s.profileCache.forget(parts[1])
+
+	var post models.Post
+	if removed {
+		found, err := s.GetPost(ctx, postID)
+		if err != nil {
+			s.untrackPost(ctx, postID)
+			return
+		}
+		post = *found
+	} else if !s.readObject(ctx, s.postsBucket, key, &post) {
+		return
+	}
+	s.trackPost(ctx, &post)
+}
+
+// syncFileObject counts the file described by files/<user>/<file>/metadata.json.
+// The other objects of a file change with its metadata.
+func (s *StorageService) syncFileObject(ctx context.Context, key string, removed bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != "files" || parts[3] != "metadata.json" {
+		return
+	}
+	fileID := parts[2]
+	s.fileCache.forget(fileID)
+
+	var file models.File
+	if removed {
+		found, err := s.GetFile(ctx, fileID)
+		if err != nil {
+			s.untrackStats(ctx, stats.Files, fileID)
+			return
+		}
+		file = *found
+	} else if !s.readObject(ctx, s.filesBucket, key, &file) {
+		return
+	}
+	s.trackFile(ctx, &file)
+}
+
+// readObject decodes the JSON object key of bucket into v. Objects that
+// are gone again or unreadable are skipped with a warning.
+func (s *StorageService) readObject(ctx context.Context, bucket, key string, v any) bool {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return false
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			s.log(ctx).Warn("Failed to read changed object", "bucket", bucket, "object", key, "error", err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		s.log(ctx).Warn("Skipping unreadable changed object", "bucket", bucket, "object", key, "error", err)
+		return false
+	}
+	return true
+}
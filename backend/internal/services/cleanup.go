@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// orphanMinAge is how old the objects of a file without metadata must be
+// before they count as orphaned. Younger ones may belong to an upload that
+// is not finalized yet.
+const orphanMinAge = 24 * time.Hour
+
+// DeleteExpiredShares deletes the share links that expired or ran out of
+// downloads by now, and returns how many it deleted
+func (s *StorageService) DeleteExpiredShares(ctx context.Context, now time.Time) (int, error) {
+	deleted := 0
+	for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{Prefix: "shares/", Recursive: true}) {
+		if object.Err != nil {
+			return deleted, fmt.Errorf("failed to list shares: %w", object.Err)
+		}
+
+		token := strings.TrimSuffix(strings.TrimPrefix(object.Key, "shares/"), ".json")
+		share, err := s.GetShare(ctx, token)
+		if err != nil {
+			s.log(ctx).Warn("Skipping unreadable share", "object", object.Key, "error", err)
+			continue
+		}
+
+		expired := !share.ExpiresAt.IsZero() && now.After(share.ExpiresAt)
+		usedUp := share.MaxDownloads > 0 && share.DownloadCount >= share.MaxDownloads
+		if !expired && !usedUp {
+			continue
+		}
+		if err := s.DeleteShare(ctx, token); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DeleteOrphans deletes what is left of files whose metadata is gone,
+// such as content, versions and thumbnails after an interrupted delete,
+// and share links to files that no longer exist. It returns how many
+// objects and shares it deleted.
+func (s *StorageService) DeleteOrphans(ctx context.Context) (objects, shares int, err error) {
+	type fileObjects struct {
+		keys     []string
+		metadata bool
+		newest   time.Time
+	}
+
+	// Objects of a file are under files/<owner>/<file>/ and, while it
+	// awaits a virus scan, quarantine/<owner>/<file>/. The listing must be
+	// complete, or live files would look orphaned.
+	started := time.Now()
+	files := make(map[string]*fileObjects)
+	for _, prefix := range []string{"files/", "quarantine/"} {
+		for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if object.Err != nil {
+				return 0, 0, fmt.Errorf("failed to list files: %w", object.Err)
+			}
+
+			parts := strings.SplitN(strings.TrimPrefix(object.Key, prefix), "/", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			fileID := parts[1]
+			file, ok := files[fileID]
+			if !ok {
+				file = &fileObjects{}
+				files[fileID] = file
+			}
+			file.keys = append(file.keys, object.Key)
+			file.metadata = file.metadata || prefix == "files/" && parts[2] == "metadata.json"
+			if object.LastModified.After(file.newest) {
+				file.newest = object.LastModified
+			}
+		}
+	}
+
+	cutoff := started.Add(-orphanMinAge)
+	for fileID, file := range files {
+		if file.metadata || file.newest.After(cutoff) {
+			continue
+		}
+		for _, key := range file.keys {
+			if err := s.client.RemoveObject(ctx, s.filesBucket, key, minio.RemoveObjectOptions{}); err != nil {
+				return objects, shares, fmt.Errorf("failed to delete orphaned object %s: %w", key, err)
+			}
+			objects++
+		}
+		s.log(ctx).Info("Deleted orphaned file objects", "file", fileID, "objects", len(file.keys))
+		delete(files, fileID)
+	}
+
+	for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{Prefix: "shares/", Recursive: true}) {
+		if object.Err != nil {
+			return objects, shares, fmt.Errorf("failed to list shares: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.filesBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+		var share struct {
+			Token     string    `json:"token"`
+			FileID    string    `json:"fileId"`
+			CreatedAt time.Time `json:"createdAt"`
+		}
+		if err := json.Unmarshal(data, &share); err != nil {
+			continue
+		}
+
+		// Files uploaded during the scan are not in the listing
+		if _, ok := files[share.FileID]; ok || share.CreatedAt.After(started) {
+			continue
+		}
+		if err := s.DeleteShare(ctx, share.Token); err != nil {
+			return objects, shares, err
+		}
+		shares++
+	}
+	return objects, shares, nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+const commentPrefix = "comments/"
+
+// Comment operations
+//
+// Comments live under comments/<post id>/<comment id>.json in the posts
+// bucket, so the comments of a post are listed with one prefix and stay
+// put when the post moves to another author.
+func (s *StorageService) CreateComment(ctx context.Context, comment *models.Comment) error {
+	// ReviewComment sets both for comments it holds
+	if comment.ID == "" {
+		comment.ID = uuid.New().String()
+	}
+	if comment.Status == "" {
+		comment.Status = models.CommentStatusVisible
+	}
+	comment.CreatedAt = time.Now()
+	return s.saveComment(ctx, comment, s.commentEvent(ctx, events.CommentCreated, comment))
+}
+
+func (s *StorageService) GetComment(ctx context.Context, postID, commentID string) (*models.Comment, error) {
+	var comment models.Comment
+	if err := s.readBucketJSON(ctx, s.postsBucket, commentObjectName(postID, commentID), &comment); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to read comment: %w", err)
+	}
+	return &comment, nil
+}
+
+// ListComments returns the comments of a post, oldest first, with their
+// replies counted
+func (s *StorageService) ListComments(ctx context.Context, postID string) ([]*models.Comment, error) {
+	comments, err := s.listComments(ctx, commentPrefix+postID+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[string]int)
+	for _, comment := range comments {
+		if comment.ParentID != "" {
+			replies[comment.ParentID]++
+		}
+	}
+	for _, comment := range comments {
+		comment.Replies = replies[comment.ID]
+	}
+	return comments, nil
+}
+
+func (s *StorageService) listComments(ctx context.Context, prefix string) ([]*models.Comment, error) {
+	comments := []*models.Comment{}
+
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", object.Err)
+		}
+
+		var comment models.Comment
+		if err := s.readBucketJSON(ctx, s.postsBucket, object.Key, &comment); err != nil {
+			continue
+		}
+		comments = append(comments, &comment)
+	}
+
+	slices.SortFunc(comments, func(a, b *models.Comment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return comments, nil
+}
+
+// SaveComment stores a changed comment. Changes of its status go through
+// ModerateComment or DeleteComment instead, which publish their events.
+func (s *StorageService) SaveComment(ctx context.Context, comment *models.Comment) error {
+	return s.saveComment(ctx, comment)
+}
+
+// ModerateComment stores a comment that was hidden, shown again, or
+// approved or rejected after review
+func (s *StorageService) ModerateComment(ctx context.Context, comment *models.Comment) error {
+	return s.saveComment(ctx, comment, s.commentEvent(ctx, events.CommentModerated, comment))
+}
+
+func (s *StorageService) saveComment(ctx context.Context, comment *models.Comment, evs ...*events.Event) error {
+	comment.UpdatedAt = time.Now()
+	comment.Replies = 0
+	written := s.prepareEvents(ctx, evs...)
+	err := s.writeBucketJSON(ctx, s.postsBucket, commentObjectName(comment.PostID, comment.ID), comment)
+	written(err)
+	if err != nil {
+		return fmt.Errorf("failed to store comment: %w", err)
+	}
+	s.syncCommentMentions(ctx, comment)
+	return nil
+}
+
+// commentEvent returns an event of eventType about comment. It names the
+// author of the post, who is told about comments on their posts, when
+// events are published at all.
+func (s *StorageService) commentEvent(ctx context.Context, eventType string, comment *models.Comment) *events.Event {
+	var postUserID string
+	if s.events != nil {
+		if post, err := s.GetPost(ctx, comment.PostID); err == nil {
+			postUserID = post.UserID
+		}
+	}
+	payload := events.NewComment(comment, postUserID)
+	if eventType == events.CommentDeleted {
+		payload = events.Comment{ID: comment.ID, PostID: comment.PostID, PostUserID: postUserID, UserID: comment.UserID}
+	}
+	return events.New(eventType, payload)
+}
+
+// DeleteComment deletes a comment. One that has replies is kept as a
+// placeholder without its content; removing the last reply of such a
+// placeholder removes the placeholder too.
+func (s *StorageService) DeleteComment(ctx context.Context, comment *models.Comment) error {
+	comments, err := s.ListComments(ctx, comment.PostID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*models.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+
+	current, ok := byID[comment.ID]
+	if !ok {
+		return ErrCommentNotFound
+	}
+	if current.Replies > 0 {
+		current.Content = ""
+		current.Status = models.CommentStatusDeleted
+		return s.saveComment(ctx, current, s.commentEvent(ctx, events.CommentDeleted, current))
+	}
+
+	for current != nil {
+		written := s.prepareEvents(ctx, s.commentEvent(ctx, events.CommentDeleted, current))
+		err := s.client.RemoveObject(ctx, s.postsBucket, commentObjectName(current.PostID, current.ID), minio.RemoveObjectOptions{})
+		written(err)
+		if err != nil {
+			return fmt.Errorf("failed to delete comment: %w", err)
+		}
+		if err := s.deleteMentions(ctx, mentionPrefix+current.PostID+"/"+current.ID+"/"); err != nil {
+			s.log(ctx).Warn("Failed to delete mentions of deleted comment", "commentId", current.ID, "error", err)
+		}
+		if err := s.RemoveModerationItem(ctx, current.ID); err != nil {
+			s.log(ctx).Warn("Failed to remove deleted comment from the moderation queue", "commentId", current.ID, "error", err)
+		}
+		parent := byID[current.ParentID]
+		if parent == nil || parent.Status != models.CommentStatusDeleted || parent.Replies > 1 {
+			break
+		}
+		current = parent
+	}
+	return nil
+}
+
+// deletePostComments deletes every comment of a deleted post
+func (s *StorageService) deletePostComments(ctx context.Context, postID string) error {
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: commentPrefix + postID + "/", Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list comments: %w", object.Err)
+		}
+		if err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete comment: %w", err)
+		}
+		if err := s.RemoveModerationItem(ctx, strings.TrimSuffix(path.Base(object.Key), ".json")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eraseUserComments deletes the comments of an erased user, or gives them
+// to DeletedUserID when anonymize is set, and returns how many it changed.
+// It reads every comment.
+func (s *StorageService) eraseUserComments(ctx context.Context, userID string, anonymize bool) (int, error) {
+	comments, err := s.listComments(ctx, commentPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	for _, comment := range comments {
+		if comment.UserID != userID {
+			continue
+		}
+		if anonymize {
+			comment.UserID = models.DeletedUserID
+			err = s.SaveComment(ctx, comment)
+		} else {
+			err = s.DeleteComment(ctx, comment)
+		}
+		if err != nil && !errors.Is(err, ErrCommentNotFound) {
+			return erased, err
+		}
+		erased++
+	}
+	return erased, nil
+}
+
+func commentObjectName(postID, commentID string) string {
+	return commentPrefix + postID + "/" + commentID + ".json"
+}
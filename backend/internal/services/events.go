@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// eventPublishTimeout bounds how long a write waits for its event to be
+// stored
+const eventPublishTimeout = 5 * time.Second
+
+// SetEventPublisher makes the service publish a domain event for every
+// user, post, comment and file it writes or deletes
+func (s *StorageService) SetEventPublisher(publisher events.Publisher) {
+	s.events = publisher
+}
+
+// prepareEvents hands the events of a write about to be made to a
+// publisher that can keep them, so they are published even if the server
+// stops right after the write. The returned function must be called with
+// the result of the write: it publishes the events once the write
+// succeeded and discards them if it failed. Events are never worth failing
+// a write for, so errors are only logged.
+func (s *StorageService) prepareEvents(ctx context.Context, evs ...*events.Event) func(err error) {
+	if s.events == nil || len(evs) == 0 {
+		return func(error) {}
+	}
+
+	// A client that goes away does not stop the events of its write
+	ctx = context.WithoutCancel(ctx)
+	preparer, _ := s.events.(events.Preparer)
+	if preparer != nil {
+		prepareCtx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+		defer cancel()
+		for _, event := range evs {
+			if err := preparer.PrepareEvent(prepareCtx, event); err != nil {
+				s.log(ctx).Error("Failed to prepare event", "type", event.Type, "error", err)
+			}
+		}
+	}
+
+	return func(err error) {
+		ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+		defer cancel()
+		for _, event := range evs {
+			if err != nil {
+				if preparer != nil {
+					preparer.DiscardEvent(ctx, event.ID)
+				}
+				continue
+			}
+			if err := s.events.PublishEvent(ctx, event); err != nil {
+				s.log(ctx).Error("Failed to publish event", "type", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+// postEvents returns the events of saving post: eventType and, the first
+// time it is published, post.published
+func postEvents(eventType string, post *models.Post, published bool) []*events.Event {
+	evs := []*events.Event{events.New(eventType, events.NewPost(post))}
+	if published {
+		evs = append(evs, events.New(events.PostPublished, events.NewPost(post)))
+	}
+	return evs
+}
+
+// EventWritten tells the outbox whether the write a prepared event reports
+// happened, for events whose server stopped before it could say. The
+// object must exist after a creation, be gone after a deletion and match
+// the payload after any other change; a later write that changed it again
+// has an event of its own.
+func (s *StorageService) EventWritten(ctx context.Context, event *events.Event) (bool, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return false, err
+	}
+
+	switch event.Type {
+	case events.UserCreated, events.UserUpdated, events.UserDeleted:
+		var user events.User
+		if err := json.Unmarshal(data, &user); err != nil {
+			return false, err
+		}
+		return storedEvent(ctx, s, s.usersBucket, "users/"+user.ID+".json", event.Type, user, events.NewUser)
+	case events.PostCreated, events.PostUpdated, events.PostPublished, events.PostDeleted:
+		var post events.Post
+		if err := json.Unmarshal(data, &post); err != nil {
+			return false, err
+		}
+		return storedEvent(ctx, s, s.postsBucket, fmt.Sprintf("posts/%s/%s.json", post.UserID, post.ID), event.Type, post, events.NewPost)
+	case events.CommentCreated, events.CommentModerated, events.CommentDeleted:
+		var comment events.Comment
+		if err := json.Unmarshal(data, &comment); err != nil {
+			return false, err
+		}
+		return s.commentEventWritten(ctx, event.Type, comment)
+	case events.FileUploaded, events.FileUpdated, events.FileDeleted:
+		var file events.File
+		if err := json.Unmarshal(data, &file); err != nil {
+			return false, err
+		}
+		return storedEvent(ctx, s, s.filesBucket, fmt.Sprintf("files/%s/%s/metadata.json", file.UserID, file.ID), event.Type, file, events.NewFile)
+	case events.MentionCreated:
+		var mention events.Mention
+		if err := json.Unmarshal(data, &mention); err != nil {
+			return false, err
+		}
+		source := mention.CommentID
+		if source == "" {
+			source = mentionSourcePost
+		}
+		name := mentionPrefix + mention.PostID + "/" + source + "/" + mention.UserID + ".json"
+		return storedEvent(ctx, s, s.postsBucket, name, event.Type, mention, events.NewMention)
+	}
+	return false, fmt.Errorf("unknown event type %s", event.Type)
+}
+
+// storedEvent compares the object name of bucket, a model M, with the
+// payload P an event of eventType reported about it
+func storedEvent[M any, P any](ctx context.Context, s *StorageService, bucket, name, eventType string, reported P, payload func(*M) P) (bool, error) {
+	var stored M
+	err := s.readBucketJSON(ctx, bucket, name, &stored)
+	if errors.Is(err, errObjectNotFound) {
+		return slices.Contains(deletionEvents, eventType), nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	switch {
+	case slices.Contains(deletionEvents, eventType):
+		return false, nil
+	case slices.Contains(creationEvents, eventType):
+		return true, nil
+	}
+	want, err := json.Marshal(reported)
+	if err != nil {
+		return false, err
+	}
+	got, err := json.Marshal(payload(&stored))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(want, got), nil
+}
+
+// commentEventWritten is EventWritten for comment events. Comments deleted
+// with replies stay as placeholders, and moderation is judged by the
+// status alone, as the payload also names the author of the post.
+func (s *StorageService) commentEventWritten(ctx context.Context, eventType string, reported events.Comment) (bool, error) {
+	stored, err := s.GetComment(ctx, reported.PostID, reported.ID)
+	if errors.Is(err, ErrCommentNotFound) {
+		return eventType == events.CommentDeleted, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch eventType {
+	case events.CommentCreated:
+		return true, nil
+	case events.CommentDeleted:
+		return stored.Status == models.CommentStatusDeleted, nil
+	}
+	return stored.Status == reported.Status, nil
+}
+
+// Events whose write only created or only removed an object
+var (
+	creationEvents = []string{events.UserCreated, events.PostCreated, events.FileUploaded, events.MentionCreated}
+	deletionEvents = []string{events.UserDeleted, events.PostDeleted, events.FileDeleted}
+)
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePreparer records the events prepared and published, by type
+type fakePreparer struct {
+	prepared  map[string]*events.Event
+	published []string
+	sent      []*events.Event
+}
+
+func (p *fakePreparer) PrepareEvent(ctx context.Context, event *events.Event) error {
+	p.prepared[event.ID] = event
+	return nil
+}
+
+func (p *fakePreparer) DiscardEvent(ctx context.Context, id string) {
+	delete(p.prepared, id)
+}
+
+func (p *fakePreparer) PublishEvent(ctx context.Context, event *events.Event) error {
+	delete(p.prepared, event.ID)
+	p.published = append(p.published, event.Type)
+	p.sent = append(p.sent, event)
+	return nil
+}
+
+// stored turns event into one as the outbox stores it, with JSON data
+func stored(t *testing.T, event *events.Event) *events.Event {
+	t.Helper()
+	data, err := json.Marshal(event.Data)
+	require.NoError(t, err)
+	copied := *event
+	copied.Data = json.RawMessage(data)
+	return &copied
+}
+
+func TestPrepareEvents(t *testing.T) {
+	s, server := newTestStorage(t)
+	ctx := context.Background()
+	preparer := &fakePreparer{prepared: make(map[string]*events.Event)}
+	s.SetEventPublisher(preparer)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, s.CreateUser(ctx, user))
+	assert.Equal(t, []string{events.UserCreated}, preparer.published)
+	assert.Empty(t, preparer.prepared)
+
+	// The events of failed writes are discarded
+	server.FailPuts(func(bucket, key string) bool { return key == "users/"+user.ID+".json" })
+	user.Role = models.RoleAdmin
+	require.Error(t, s.UpdateUser(ctx, user))
+	server.FailPuts(nil)
+	assert.Equal(t, []string{events.UserCreated}, preparer.published)
+	assert.Empty(t, preparer.prepared)
+}
+
+func TestEventWritten(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Role: models.RoleUser}
+	created := stored(t, events.New(events.UserCreated, events.NewUser(user)))
+	written, err := s.EventWritten(ctx, created)
+	require.NoError(t, err)
+	assert.False(t, written, "the user was never stored")
+
+	require.NoError(t, s.CreateUser(ctx, user))
+	created = stored(t, events.New(events.UserCreated, events.NewUser(user)))
+	written, err = s.EventWritten(ctx, created)
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	// Updates are found by their payload
+	promoted := *user
+	promoted.Role = models.RoleAdmin
+	updated := stored(t, events.New(events.UserUpdated, events.NewUser(&promoted)))
+	written, err = s.EventWritten(ctx, updated)
+	require.NoError(t, err)
+	assert.False(t, written)
+	require.NoError(t, s.UpdateUser(ctx, &promoted))
+	written, err = s.EventWritten(ctx, updated)
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	deleted := stored(t, events.New(events.UserDeleted, events.User{ID: user.ID}))
+	written, err = s.EventWritten(ctx, deleted)
+	require.NoError(t, err)
+	assert.False(t, written)
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	written, err = s.EventWritten(ctx, deleted)
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	post := &models.Post{UserID: "u1", Title: "Hello", Content: "Hi", Status: models.PostStatusPublished}
+	require.NoError(t, s.CreatePost(ctx, post))
+	written, err = s.EventWritten(ctx, stored(t, events.New(events.PostPublished, events.NewPost(post))))
+	require.NoError(t, err)
+	assert.True(t, written)
+}
+
+func TestCommentEvents(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	preparer := &fakePreparer{prepared: make(map[string]*events.Event)}
+	s.SetEventPublisher(preparer)
+
+	post := &models.Post{UserID: "author", Title: "Hello", Content: "Hi", Status: models.PostStatusPublished}
+	require.NoError(t, s.CreatePost(ctx, post))
+	preparer.published, preparer.sent = nil, nil
+
+	comment := &models.Comment{PostID: post.ID, UserID: "reader", Content: "Nice"}
+	require.NoError(t, s.CreateComment(ctx, comment))
+	reply := &models.Comment{PostID: post.ID, UserID: "author", ParentID: comment.ID, Depth: 1, Content: "Thanks"}
+	require.NoError(t, s.CreateComment(ctx, reply))
+	require.Len(t, preparer.sent, 2)
+	assert.Equal(t, events.Comment{ID: comment.ID, PostID: post.ID, PostUserID: "author", UserID: "reader", Status: models.CommentStatusVisible}, preparer.sent[0].Data)
+	assert.Equal(t, comment.ID, preparer.sent[1].Data.(events.Comment).ParentID)
+
+	comment.Status = models.CommentStatusHidden
+	moderated := events.New(events.CommentModerated, events.NewComment(comment, "author"))
+	written, err := s.EventWritten(ctx, stored(t, moderated))
+	require.NoError(t, err)
+	assert.False(t, written, "the comment is still visible")
+	require.NoError(t, s.ModerateComment(ctx, comment))
+	written, err = s.EventWritten(ctx, stored(t, moderated))
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	// A comment with replies stays as a placeholder, which counts as deleted
+	deleted := stored(t, events.New(events.CommentDeleted, events.Comment{ID: comment.ID, PostID: post.ID}))
+	written, err = s.EventWritten(ctx, deleted)
+	require.NoError(t, err)
+	assert.False(t, written)
+	require.NoError(t, s.DeleteComment(ctx, comment))
+	written, err = s.EventWritten(ctx, deleted)
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	// Deleting the last reply removes the placeholder too
+	require.NoError(t, s.DeleteComment(ctx, reply))
+	assert.Equal(t, []string{
+		events.CommentCreated, events.CommentCreated, events.CommentModerated,
+		events.CommentDeleted, events.CommentDeleted, events.CommentDeleted,
+	}, preparer.published)
+	assert.Equal(t, events.Comment{ID: comment.ID, PostID: post.ID, PostUserID: "author", UserID: "reader"}, preparer.sent[5].Data)
+	assert.Empty(t, preparer.prepared)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// ListExpiredFiles returns every file whose expiry is at or before now
+func (s *StorageService) ListExpiredFiles(ctx context.Context, now time.Time) ([]*models.File, error) {
+	return s.findFiles(ctx, func(file *models.File) bool {
+		return file.IsExpired(now)
+	}), nil
+}
+
+// ListFilesExpiringBefore returns every file that expires after now and
+// before the given time, soonest first
+func (s *StorageService) ListFilesExpiringBefore(ctx context.Context, now, before time.Time) ([]*models.File, error) {
+	files := s.findFiles(ctx, func(file *models.File) bool {
+		return file.ExpiresAt != nil && file.ExpiresAt.After(now) && file.ExpiresAt.Before(before)
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ExpiresAt.Before(*files[j].ExpiresAt)
+	})
+	return files, nil
+}
+
+// ListExpiringFiles returns the user's files that expire before the given
+// time, soonest first
+func (s *StorageService) ListExpiringFiles(ctx context.Context, userID string, before time.Time) ([]*models.File, error) {
+	files := s.findFiles(ctx, func(file *models.File) bool {
+		return file.UserID == userID && file.ExpiresAt != nil && file.ExpiresAt.Before(before)
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ExpiresAt.Before(*files[j].ExpiresAt)
+	})
+	return files, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/preview"
+	"github.com/minio-fullstack-storage/backend/internal/thumbnail"
+	"github.com/minio-fullstack-storage/backend/internal/transcode"
+)
+
+// Types of the background jobs of files, whose payload is a
+// messaging.FileJob
+const (
+	JobScan      = "scan"
+	JobThumbnail = "thumbnail"
+	JobPreview   = "preview"
+	JobTranscode = "transcode"
+)
+
+// NextJob returns the type of the background job that new content for
+// file needs: a virus scan while it is quarantined, then a thumbnail,
+// document preview or video transcode depending on its type. Client-encrypted
+// content is only scanned; nothing can be rendered from ciphertext.
+func NextJob(file *models.File) (string, bool) {
+	switch {
+	case file.ScanStatus == models.ScanStatusPending:
+		return JobScan, true
+	case file.IsEncrypted():
+		return "", false
+	case thumbnail.IsSupported(file.ContentType):
+		return JobThumbnail, true
+	case preview.IsSupported(file.ContentType):
+		return JobPreview, true
+	case transcode.IsSupported(file.ContentType):
+		return JobTranscode, true
+	}
+	return "", false
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// Invitation operations
+//
+// Invitations live under invitations/<id>.json in the users bucket.
+func (s *StorageService) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	invitation.ID = uuid.New().String()
+	invitation.CreatedAt = time.Now()
+
+	return s.SaveInvitation(ctx, invitation)
+}
+
+func (s *StorageService) GetInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, invitationObjectName(id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to read invitation data: %w", err)
+	}
+
+	var invitation models.Invitation
+	if err := json.Unmarshal(data, &invitation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invitation: %w", err)
+	}
+
+	return &invitation, nil
+}
+
+// ListInvitations returns all invitations, newest first
+func (s *StorageService) ListInvitations(ctx context.Context) ([]*models.Invitation, error) {
+	invitations := []*models.Invitation{}
+
+	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "invitations/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list invitations: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var invitation models.Invitation
+		if err := json.Unmarshal(data, &invitation); err != nil {
+			continue
+		}
+		invitations = append(invitations, &invitation)
+	}
+
+	slices.SortFunc(invitations, func(a, b *models.Invitation) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return invitations, nil
+}
+
+func (s *StorageService) SaveInvitation(ctx context.Context, invitation *models.Invitation) error {
+	data, err := json.Marshal(invitation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invitation: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, invitationObjectName(invitation.ID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	return nil
+}
+
+func (s *StorageService) DeleteInvitation(ctx context.Context, id string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, invitationObjectName(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+	return nil
+}
+
+func invitationObjectName(id string) string {
+	return "invitations/" + id + ".json"
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// loginHistoryLimit is how many sign-in attempts are kept per user
+const loginHistoryLimit = 50
+
+// Login history operations
+//
+// Each user's recent sign-in attempts are kept newest first in one object,
+// logins/<user>.json in the users bucket. Successful logins also update
+// the user's last login.
+func (s *StorageService) RecordLogin(ctx context.Context, userID string, record *models.LoginRecord) error {
+	history, err := s.GetLoginHistory(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	history = append([]*models.LoginRecord{record}, history...)
+	if len(history) > loginHistoryLimit {
+		history = history[:loginHistoryLimit]
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to marshal login history: %w", err)
+	}
+	_, err = s.client.PutObject(ctx, s.usersBucket, loginHistoryObjectName(userID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store login history: %w", err)
+	}
+
+	if !record.Success {
+		return nil
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.LastLoginAt != nil && user.LastLoginAt.After(record.At) {
+		return nil
+	}
+	at := record.At
+	user.LastLoginAt = &at
+	user.LastLoginIP = record.IP
+	return s.UpdateUser(ctx, user)
+}
+
+// GetLoginHistory returns the recent sign-in attempts of userID, newest
+// first
+func (s *StorageService) GetLoginHistory(ctx context.Context, userID string) ([]*models.LoginRecord, error) {
+	history := []*models.LoginRecord{}
+
+	object, err := s.client.GetObject(ctx, s.usersBucket, loginHistoryObjectName(userID), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login history object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return history, nil
+		}
+		return nil, fmt.Errorf("failed to read login history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login history: %w", err)
+	}
+	return history, nil
+}
+
+func loginHistoryObjectName(userID string) string {
+	return fmt.Sprintf("logins/%s.json", userID)
+}
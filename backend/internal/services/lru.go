@@ -0,0 +1,144 @@
+package services
+
+import (
+	"container/list"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// lruCache keeps the most recently read users, posts, files and public
+// profile posts in memory
+// so that popular ones are not fetched and decoded again on every request.
+// It holds at most size entries, dropping the least recently used, and
+// serves each for ttl. Entries are copied in and out with clone, so
+// callers may change what they get. Writes through this server forget the
+// entry; writes elsewhere are noticed through bucket notifications or
+// once the entry expires.
+type lruCache[V any] struct {
+	size  int
+	ttl   time.Duration
+	clone func(V) V
+
+	mu      sync.Mutex
+	order   *list.List // of *lruEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key      string
+	value    V
+	loadedAt time.Time
+}
+
+// newLRUCache returns a cache of size entries; with size 0 it keeps nothing
+func newLRUCache[V any](size int, ttl time.Duration, clone func(V) V) *lruCache[V] {
+	return &lruCache[V]{
+		size:    size,
+		ttl:     ttl,
+		clone:   clone,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*lruEntry[V])
+	if time.Since(entry.loadedAt) >= c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return c.clone(entry.value), true
+}
+
+func (c *lruCache[V]) set(key string, value V) {
+	if c.size <= 0 {
+		return
+	}
+	entry := &lruEntry[V]{key: key, value: c.clone(value), loadedAt: time.Now()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+func (c *lruCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// cloneUser, clonePost, clonePosts and cloneFile copy what callers may
+// change in place: maps, slices and the structs behind pointers
+
+func cloneUser(user *models.User) *models.User {
+	copied := *user
+	copied.OAuthIdentities = maps.Clone(user.OAuthIdentities)
+	return &copied
+}
+
+func clonePost(post *models.Post) *models.Post {
+	copied := *post
+	copied.Tags = slices.Clone(post.Tags)
+	return &copied
+}
+
+func clonePosts(posts []*models.Post) []*models.Post {
+	copied := make([]*models.Post, len(posts))
+	for i, post := range posts {
+		copied[i] = clonePost(post)
+	}
+	return copied
+}
+
+func cloneFile(file *models.File) *models.File {
+	copied := *file
+	copied.Metadata = maps.Clone(file.Metadata)
+	copied.Thumbnails = maps.Clone(file.Thumbnails)
+	copied.Versions = slices.Clone(file.Versions)
+	if file.Stream != nil {
+		stream := *file.Stream
+		stream.Renditions = slices.Clone(file.Stream.Renditions)
+		copied.Stream = &stream
+	}
+	if file.Encryption != nil {
+		encryption := *file.Encryption
+		copied.Encryption = &encryption
+	}
+	return &copied
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func identity(v int) int { return v }
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := newLRUCache(2, time.Hour, identity)
+	cache.set("a", 1)
+	cache.set("b", 2)
+
+	// Reading a makes b the least recently used
+	_, ok := cache.get("a")
+	require.True(t, ok)
+	cache.set("c", 3)
+
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	value, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 2, cache.len())
+
+	// Setting a key again replaces its value without evicting anything
+	cache.set("c", 4)
+	value, _ = cache.get("c")
+	assert.Equal(t, 4, value)
+	assert.Equal(t, 2, cache.len())
+
+	cache.forget("a")
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.len())
+
+	// A cache of size 0 keeps nothing
+	disabled := newLRUCache(0, time.Hour, identity)
+	disabled.set("a", 1)
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	cache := newLRUCache(10, 20*time.Millisecond, identity)
+	cache.set("a", 1)
+	_, ok := cache.get("a")
+	require.True(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	// Expired entries are dropped when read
+	assert.Zero(t, cache.len())
+}
+
+func TestLRUCacheClones(t *testing.T) {
+	cache := newLRUCache(10, time.Hour, cloneUser)
+	user := &models.User{ID: "u1", Username: "alice", OAuthIdentities: map[string]string{"github": "1"}}
+	cache.set(user.ID, user)
+
+	// Changing what was stored or read leaves the cached entry alone
+	user.Username = "mallory"
+	user.OAuthIdentities["github"] = "2"
+	cached, ok := cache.get("u1")
+	require.True(t, ok)
+	assert.Equal(t, "alice", cached.Username)
+	assert.Equal(t, "1", cached.OAuthIdentities["github"])
+
+	cached.OAuthIdentities["google"] = "3"
+	again, _ := cache.get("u1")
+	assert.Equal(t, map[string]string{"github": "1"}, again.OAuthIdentities)
+
+	post := &models.Post{ID: "p1", Tags: []string{"go"}}
+	copied := clonePost(post)
+	copied.Tags[0] = "rust"
+	assert.Equal(t, []string{"go"}, post.Tags)
+
+	file := &models.File{ID: "f1", Metadata: map[string]string{"k": "v"}, Stream: &models.VideoStream{Renditions: []string{"720p"}}}
+	copiedFile := cloneFile(file)
+	copiedFile.Metadata["k"] = "changed"
+	copiedFile.Stream.Renditions[0] = "1080p"
+	assert.Equal(t, "v", file.Metadata["k"])
+	assert.Equal(t, []string{"720p"}, file.Stream.Renditions)
+}
+
+func TestCacheForgetsOnWrite(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, s.CreateUser(ctx, user))
+	_, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+
+	// Writes elsewhere are not seen until the entry expires
+	changed := *user
+	changed.Bio = "elsewhere"
+	require.NoError(t, s.writeJSONObject(ctx, "users/"+user.ID+".json", &changed))
+	cached, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, cached.Bio)
+
+	// Writes through the service are
+	user.Bio = "updated"
+	require.NoError(t, s.UpdateUser(ctx, user))
+	stored, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", stored.Bio)
+
+	post := &models.Post{UserID: user.ID, Title: "Hello", Status: models.PostStatusDraft}
+	require.NoError(t, s.CreatePost(ctx, post))
+	_, err = s.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	post.Title = "Updated"
+	require.NoError(t, s.UpdatePost(ctx, post))
+	storedPost, err := s.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", storedPost.Title)
+
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	_, err = s.GetUser(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// maintenanceObjectName holds the maintenance mode set through the API, in
+// the users bucket. Without it maintenance mode is off.
+const maintenanceObjectName = "system/maintenance.json"
+
+// GetMaintenance returns the maintenance mode set through the API
+func (s *StorageService) GetMaintenance(ctx context.Context) (*models.Maintenance, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, maintenanceObjectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return &models.Maintenance{}, nil
+		}
+		return nil, fmt.Errorf("failed to read maintenance data: %w", err)
+	}
+
+	var maintenance models.Maintenance
+	if err := json.Unmarshal(data, &maintenance); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal maintenance: %w", err)
+	}
+	return &maintenance, nil
+}
+
+// CachedMaintenance is GetMaintenance for checking every request; changes
+// made on other servers can take up to authCacheTTL to show up
+func (s *StorageService) CachedMaintenance(ctx context.Context) (*models.Maintenance, error) {
+	if maintenance, ok := s.maintenanceCache.get(""); ok {
+		return maintenance, nil
+	}
+
+	maintenance, err := s.GetMaintenance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.maintenanceCache.set("", maintenance)
+	return maintenance, nil
+}
+
+// SaveMaintenance turns maintenance mode on or off for every server
+func (s *StorageService) SaveMaintenance(ctx context.Context, maintenance *models.Maintenance) error {
+	now := time.Now()
+	maintenance.UpdatedAt = &now
+
+	data, err := json.Marshal(maintenance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, maintenanceObjectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store maintenance: %w", err)
+	}
+
+	s.maintenanceCache.forget("")
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+const mentionPrefix = "mentions/"
+
+// mentionSourcePost names mentions in the post itself; mentions in
+// comments are named by the comment ID
+const mentionSourcePost = "post"
+
+// mentionPattern matches @username not preceded by a word character, so
+// e-mail addresses are no mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.-]*[A-Za-z0-9_])`)
+
+// Mention operations
+//
+// Mentions live under mentions/<post id>/<source>/<user id>.json in the
+// posts bucket, where source is "post" or the ID of a comment. Every save
+// of a post or comment resolves its @usernames again: users named for the
+// first time get a mention.created event, users no longer named lose
+// their mention.
+
+// ParseMentions returns the usernames content mentions, lowercased and
+// without duplicates, in the order they first appear
+func ParseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.ToLower(match[1])
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// ListMentions returns the page of mentions of a user that pagination
+// selects, newest first, and how many there are. It reads every mention.
+func (s *StorageService) ListMentions(ctx context.Context, userID string, pagination models.Pagination) ([]*models.Mention, int64, error) {
+	include := func(key string) bool { return strings.HasSuffix(key, "/"+userID+".json") }
+	return listPage[models.Mention](ctx, s, s.postsBucket, mentionPrefix, include, pagination, newestFirst)
+}
+
+// syncPostMentions records the mentions of a saved post. Posts withheld
+// by moderation mention nobody. The post is already stored, so failures
+// are only logged.
+func (s *StorageService) syncPostMentions(ctx context.Context, post *models.Post) {
+	content := post.Title + "\n" + post.Content
+	if post.Withheld() {
+		content = ""
+	}
+	s.syncMentions(ctx, post, mentionSourcePost, post.UserID, content)
+}
+
+// syncCommentMentions records the mentions of a saved comment. Hidden and
+// deleted comments mention nobody.
+func (s *StorageService) syncCommentMentions(ctx context.Context, comment *models.Comment) {
+	content := comment.Content
+	if comment.Status != models.CommentStatusVisible {
+		content = ""
+	}
+
+	post, err := s.GetPost(ctx, comment.PostID)
+	if err != nil {
+		s.log(ctx).Warn("Failed to record mentions", "postId", comment.PostID, "commentId", comment.ID, "error", err)
+		return
+	}
+	s.syncMentions(ctx, post, comment.ID, comment.UserID, content)
+}
+
+func (s *StorageService) syncMentions(ctx context.Context, post *models.Post, source, authorID, content string) {
+	if err := s.storeMentions(ctx, post, source, authorID, content); err != nil {
+		s.log(ctx).Warn("Failed to record mentions", "postId", post.ID, "source", source, "error", err)
+	}
+}
+
+func (s *StorageService) storeMentions(ctx context.Context, post *models.Post, source, authorID, content string) error {
+	mentioned, err := s.mentionedUsers(ctx, post, authorID, ParseMentions(content))
+	if err != nil {
+		return err
+	}
+
+	prefix := mentionPrefix + post.ID + "/" + source + "/"
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list mentions: %w", object.Err)
+		}
+		userID := strings.TrimSuffix(strings.TrimPrefix(object.Key, prefix), ".json")
+		if mentioned[userID] {
+			delete(mentioned, userID)
+			continue
+		}
+		if err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete mention: %w", err)
+		}
+	}
+
+	for userID := range mentioned {
+		mention := &models.Mention{UserID: userID, AuthorID: authorID, PostID: post.ID, CreatedAt: time.Now()}
+		if source != mentionSourcePost {
+			mention.CommentID = source
+		}
+		// Anonymizing an erased user's posts names nobody new
+		var created []*events.Event
+		if authorID != models.DeletedUserID {
+			created = append(created, events.New(events.MentionCreated, events.NewMention(mention)))
+		}
+		written := s.prepareEvents(ctx, created...)
+		err := s.writeBucketJSON(ctx, s.postsBucket, prefix+userID+".json", mention)
+		written(err)
+		if err != nil {
+			return fmt.Errorf("failed to store mention: %w", err)
+		}
+	}
+	return nil
+}
+
+// mentionedUsers resolves usernames to the IDs of active users other than
+// the author who can read post
+func (s *StorageService) mentionedUsers(ctx context.Context, post *models.Post, authorID string, usernames []string) (map[string]bool, error) {
+	mentioned := make(map[string]bool)
+	if len(usernames) == 0 {
+		return mentioned, nil
+	}
+
+	wanted := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		wanted[username] = true
+	}
+	users, err := s.FindUsers(ctx, func(user *models.User) bool {
+		return wanted[strings.ToLower(user.Username)] && user.ID != authorID && user.AccountStatus() == models.UserStatusActive
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if post.OrgID != "" {
+			role, err := s.CachedOrgRole(ctx, post.OrgID, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if role == "" {
+				continue
+			}
+		}
+		mentioned[user.ID] = true
+	}
+	return mentioned, nil
+}
+
+// deleteMentions removes the mentions under prefix
+func (s *StorageService) deleteMentions(ctx context.Context, prefix string) error {
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list mentions: %w", object.Err)
+		}
+		if err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete mention: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteUserMentions removes the mentions of a deleted user. It lists
+// every mention.
+func (s *StorageService) deleteUserMentions(ctx context.Context, userID string) error {
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: mentionPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list mentions: %w", object.Err)
+		}
+		if !strings.HasSuffix(object.Key, "/"+userID+".json") {
+			continue
+		}
+		if err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete mention: %w", err)
+		}
+	}
+	return nil
+}
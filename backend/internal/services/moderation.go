@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/moderation"
+	"github.com/minio-fullstack-storage/backend/internal/spam"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrModerationItemNotFound = errors.New("moderation item not found")
+	// ErrNotPending is returned when a decision is made on content that
+	// is gone or no longer awaits review; its queue entry is removed
+	ErrNotPending = errors.New("content is no longer pending review")
+)
+
+const moderationPrefix = "moderation/"
+
+// Moderation operations
+//
+// Posts and comments that break the moderation rules, or that the spam
+// checker takes for spam, are held for review:
+// they get the pending status and an entry under
+// moderation/<post or comment id>.json in the posts bucket until a post
+// admin approves or rejects them. Posts are checked when they are
+// published, comments when they are written.
+
+// ReviewPost checks a post its author saves, sent by sender; previous is
+// the status it had, empty for a new post. A post being published that breaks the rules,
+// or that was withheld before, is held: it becomes pending and joins the
+// queue. A pending post taken back to draft leaves the queue. It reports
+// whether the post is held and must run before the post is stored.
+func (s *StorageService) ReviewPost(ctx context.Context, post *models.Post, previous string, sender spam.Sender) (bool, error) {
+	switch {
+	case post.Status == models.PostStatusPending:
+		// Edited while held
+		return true, nil
+	case post.Status != models.PostStatusPublished:
+		if previous == models.PostStatusPending {
+			return false, s.RemoveModerationItem(ctx, post.ID)
+		}
+		return false, nil
+	case previous == models.PostStatusPublished:
+		return false, nil
+	}
+
+	reasons, err := s.moderationReasons(ctx, spam.KindPost, post.UserID, post.Title+"\n"+post.Summary+"\n"+post.Content, sender)
+	if err != nil {
+		return false, err
+	}
+	if previous == models.PostStatusPending || previous == models.PostStatusRejected {
+		reasons = append(reasons, moderation.ReasonResubmitted)
+	}
+	if len(reasons) == 0 {
+		return false, nil
+	}
+
+	if post.ID == "" {
+		post.ID = uuid.New().String()
+	}
+	post.Status = models.PostStatusPending
+	return true, s.queueForReview(ctx, &models.ModerationItem{
+		ID:      post.ID,
+		Kind:    models.ModerationPost,
+		PostID:  post.ID,
+		Reasons: reasons,
+	})
+}
+
+// ReviewComment checks a new comment sent by sender. One that breaks the
+// rules is held:
+// it becomes pending and joins the queue. It reports whether the comment
+// is held and must run before the comment is created.
+func (s *StorageService) ReviewComment(ctx context.Context, comment *models.Comment, sender spam.Sender) (bool, error) {
+	reasons, err := s.moderationReasons(ctx, spam.KindComment, comment.UserID, comment.Content, sender)
+	if err != nil || len(reasons) == 0 {
+		return false, err
+	}
+
+	if comment.ID == "" {
+		comment.ID = uuid.New().String()
+	}
+	comment.Status = models.CommentStatusPending
+	return true, s.queueForReview(ctx, &models.ModerationItem{
+		ID:        comment.ID,
+		Kind:      models.ModerationComment,
+		PostID:    comment.PostID,
+		CommentID: comment.ID,
+		Reasons:   reasons,
+	})
+}
+
+// moderationReasons checks content of kind by userID against the rules and
+// the spam checker. A failing spam check is logged and lets it through.
+func (s *StorageService) moderationReasons(ctx context.Context, kind, userID, content string, sender spam.Sender) ([]string, error) {
+	if s.moderation == nil && s.spam == nil {
+		return nil, nil
+	}
+	author, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	reasons := s.moderation.Check(content, author.CreatedAt, time.Now())
+	if s.spam != nil {
+		isSpam, err := s.spam.Check(ctx, spam.Content{Sender: sender, Kind: kind, Author: author.Username, Text: content})
+		if err != nil {
+			s.log(ctx).Warn("Spam check failed", "kind", kind, "userId", userID, "error", err)
+		} else if isSpam {
+			reasons = append(reasons, moderation.ReasonSpam)
+		}
+	}
+	return reasons, nil
+}
+
+func (s *StorageService) queueForReview(ctx context.Context, item *models.ModerationItem) error {
+	item.CreatedAt = time.Now()
+	if err := s.writeBucketJSON(ctx, s.postsBucket, moderationObjectName(item.ID), item); err != nil {
+		return fmt.Errorf("failed to store moderation item: %w", err)
+	}
+	return nil
+}
+
+// ListModerationItems returns the page of the queue that pagination
+// selects after opts filtered and sorted it, and how many items matched
+func (s *StorageService) ListModerationItems(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.ModerationItem, int64, error) {
+	return listPage[models.ModerationItem](ctx, s, s.postsBucket, moderationPrefix, nil, pagination, opts)
+}
+
+func (s *StorageService) GetModerationItem(ctx context.Context, id string) (*models.ModerationItem, error) {
+	var item models.ModerationItem
+	if err := s.readBucketJSON(ctx, s.postsBucket, moderationObjectName(id), &item); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrModerationItemNotFound
+		}
+		return nil, fmt.Errorf("failed to read moderation item: %w", err)
+	}
+	return &item, nil
+}
+
+// DecideModeration approves held content, publishing the post or showing
+// the comment, or rejects it: posts become rejected and comments hidden.
+// It returns the content as it is now.
+func (s *StorageService) DecideModeration(ctx context.Context, item *models.ModerationItem, approve bool) (any, error) {
+	if item.Kind == models.ModerationComment {
+		comment, err := s.GetComment(ctx, item.PostID, item.CommentID)
+		if errors.Is(err, ErrCommentNotFound) || err == nil && comment.Status != models.CommentStatusPending {
+			return nil, s.notPending(ctx, item)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		comment.Status = models.CommentStatusHidden
+		if approve {
+			comment.Status = models.CommentStatusVisible
+		}
+		if err := s.ModerateComment(ctx, comment); err != nil {
+			return nil, err
+		}
+		return comment, s.RemoveModerationItem(ctx, item.ID)
+	}
+
+	post, err := s.GetPost(ctx, item.PostID)
+	if err != nil || post.Status != models.PostStatusPending {
+		return nil, s.notPending(ctx, item)
+	}
+
+	post.Status = models.PostStatusRejected
+	if approve {
+		post.Status = models.PostStatusPublished
+	}
+	if err := s.UpdatePost(ctx, post); err != nil {
+		return nil, err
+	}
+	return post, s.RemoveModerationItem(ctx, item.ID)
+}
+
+func (s *StorageService) notPending(ctx context.Context, item *models.ModerationItem) error {
+	if err := s.RemoveModerationItem(ctx, item.ID); err != nil {
+		return err
+	}
+	return ErrNotPending
+}
+
+// RemoveModerationItem takes a post or comment out of the queue, if it is
+// in it
+func (s *StorageService) RemoveModerationItem(ctx context.Context, id string) error {
+	if err := s.client.RemoveObject(ctx, s.postsBucket, moderationObjectName(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete moderation item: %w", err)
+	}
+	return nil
+}
+
+func moderationObjectName(id string) string {
+	return moderationPrefix + id + ".json"
+}
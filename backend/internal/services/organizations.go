@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrMemberNotFound       = errors.New("organization member not found")
+)
+
+const (
+	organizationPrefix = "organizations/"
+	memberPrefix       = "organization-members/"
+)
+
+// Organization operations
+//
+// Organizations live under organizations/<id>.json in the users bucket and
+// their memberships under organization-members/<org id>/<user id>.json.
+// Posts and files shared with an organization stay under their author's
+// keys and name the organization in their OrgID.
+func (s *StorageService) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	org.ID = uuid.New().String()
+	org.CreatedAt = time.Now()
+	if err := s.SaveOrganization(ctx, org); err != nil {
+		return err
+	}
+
+	return s.SaveMembership(ctx, &models.Membership{
+		OrgID:  org.ID,
+		UserID: org.CreatedBy,
+		Role:   models.OrgRoleOwner,
+	})
+}
+
+func (s *StorageService) GetOrganization(ctx context.Context, id string) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.readJSONObject(ctx, organizationObjectName(id), &org); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to read organization: %w", err)
+	}
+	return &org, nil
+}
+
+// ListOrganizations returns the organizations with the given IDs, or all of
+// them when ids is nil, sorted by name
+func (s *StorageService) ListOrganizations(ctx context.Context, ids []string) ([]*models.Organization, error) {
+	orgs := []*models.Organization{}
+
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: organizationPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", object.Err)
+		}
+		if ids != nil && !slices.Contains(ids, strings.TrimSuffix(strings.TrimPrefix(object.Key, organizationPrefix), ".json")) {
+			continue
+		}
+
+		var org models.Organization
+		if err := s.readJSONObject(ctx, object.Key, &org); err != nil {
+			continue
+		}
+		orgs = append(orgs, &org)
+	}
+
+	slices.SortFunc(orgs, func(a, b *models.Organization) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return orgs, nil
+}
+
+func (s *StorageService) SaveOrganization(ctx context.Context, org *models.Organization) error {
+	org.UpdatedAt = time.Now()
+	if err := s.writeJSONObject(ctx, organizationObjectName(org.ID), org); err != nil {
+		return fmt.Errorf("failed to store organization: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrganization deletes an organization and its memberships. Callers
+// check that nothing is shared with it any more.
+func (s *StorageService) DeleteOrganization(ctx context.Context, id string) error {
+	members, err := s.ListMembers(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := s.DeleteMembership(ctx, id, member.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.client.RemoveObject(ctx, s.usersBucket, organizationObjectName(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	return nil
+}
+
+func (s *StorageService) GetMembership(ctx context.Context, orgID, userID string) (*models.Membership, error) {
+	var membership models.Membership
+	if err := s.readJSONObject(ctx, membershipObjectName(orgID, userID), &membership); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("failed to read membership: %w", err)
+	}
+	return &membership, nil
+}
+
+// CachedOrgRole returns the role of a user in an organization, or "" when
+// they are not a member, for checking access to shared items; changes made
+// on other servers can take up to authCacheTTL to show up
+func (s *StorageService) CachedOrgRole(ctx context.Context, orgID, userID string) (string, error) {
+	key := orgID + "/" + userID
+	if role, ok := s.orgRoleCache.get(key); ok {
+		return role, nil
+	}
+
+	membership, err := s.GetMembership(ctx, orgID, userID)
+	if errors.Is(err, ErrMemberNotFound) {
+		s.orgRoleCache.set(key, "")
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.orgRoleCache.set(key, membership.Role)
+	return membership.Role, nil
+}
+
+// ListMembers returns the memberships of an organization, oldest first
+func (s *StorageService) ListMembers(ctx context.Context, orgID string) ([]*models.Membership, error) {
+	return s.listMemberships(ctx, memberPrefix+orgID+"/", nil)
+}
+
+// ListUserMemberships returns the memberships of a user in every
+// organization
+func (s *StorageService) ListUserMemberships(ctx context.Context, userID string) ([]*models.Membership, error) {
+	return s.listMemberships(ctx, memberPrefix, func(key string) bool {
+		return strings.HasSuffix(key, "/"+userID+".json")
+	})
+}
+
+func (s *StorageService) listMemberships(ctx context.Context, prefix string, include func(key string) bool) ([]*models.Membership, error) {
+	memberships := []*models.Membership{}
+
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list memberships: %w", object.Err)
+		}
+		if include != nil && !include(object.Key) {
+			continue
+		}
+
+		var membership models.Membership
+		if err := s.readJSONObject(ctx, object.Key, &membership); err != nil {
+			continue
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	slices.SortFunc(memberships, func(a, b *models.Membership) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return memberships, nil
+}
+
+// SaveMembership adds a member or changes their role
+func (s *StorageService) SaveMembership(ctx context.Context, membership *models.Membership) error {
+	membership.UpdatedAt = time.Now()
+	if membership.CreatedAt.IsZero() {
+		membership.CreatedAt = membership.UpdatedAt
+	}
+	if err := s.writeJSONObject(ctx, membershipObjectName(membership.OrgID, membership.UserID), membership); err != nil {
+		return fmt.Errorf("failed to store membership: %w", err)
+	}
+
+	s.orgRoleCache.forget(membership.OrgID + "/" + membership.UserID)
+	return nil
+}
+
+func (s *StorageService) DeleteMembership(ctx context.Context, orgID, userID string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, membershipObjectName(orgID, userID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete membership: %w", err)
+	}
+
+	s.orgRoleCache.forget(orgID + "/" + userID)
+	return nil
+}
+
+var errObjectNotFound = errors.New("object not found")
+
+// readJSONObject reads a JSON object of the users bucket into v, failing
+// with errObjectNotFound when there is none
+func (s *StorageService) readJSONObject(ctx context.Context, name string, v any) error {
+	return s.readBucketJSON(ctx, s.usersBucket, name, v)
+}
+
+func (s *StorageService) readBucketJSON(ctx context.Context, bucket, name string, v any) error {
+	object, err := s.client.GetObject(ctx, bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return errObjectNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *StorageService) writeJSONObject(ctx context.Context, name string, v any) error {
+	return s.writeBucketJSON(ctx, s.usersBucket, name, v)
+}
+
+func (s *StorageService) writeBucketJSON(ctx context.Context, bucket, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObject(ctx, bucket, name, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	return err
+}
+
+func organizationObjectName(id string) string {
+	return organizationPrefix + id + ".json"
+}
+
+func membershipObjectName(orgID, userID string) string {
+	return memberPrefix + orgID + "/" + userID + ".json"
+}
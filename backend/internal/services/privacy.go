@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// ListUserPosts returns all posts of a user, oldest first
+func (s *StorageService) ListUserPosts(ctx context.Context, userID string) ([]*models.Post, error) {
+	posts := []*models.Post{}
+
+	objectsCh := s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{
+		Prefix:    fmt.Sprintf("posts/%s/", userID),
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list posts: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.postsBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get post object: %w", err)
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read post data: %w", err)
+		}
+
+		var post models.Post
+		if err := json.Unmarshal(data, &post); err != nil {
+			continue
+		}
+		posts = append(posts, &post)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].CreatedAt.Before(posts[j].CreatedAt)
+	})
+	return posts, nil
+}
+
+// ListUserFiles returns all files of a user, oldest first
+func (s *StorageService) ListUserFiles(ctx context.Context, userID string) ([]*models.File, error) {
+	files := s.findFiles(ctx, func(file *models.File) bool {
+		return file.UserID == userID
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.Before(files[j].CreatedAt)
+	})
+	return files, nil
+}
+
+// EraseUser deletes a user with their files, reports and login history. Their
+// posts, series and comments are deleted with ErasurePurge and given to
+// DeletedUserID with ErasureAnonymize. The user is deleted last, so a failed erasure can be
+// run again.
+func (s *StorageService) EraseUser(ctx context.Context, userID, mode string) (*models.ErasureResult, error) {
+	result := &models.ErasureResult{Mode: mode}
+
+	posts, err := s.ListUserPosts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if mode == models.ErasureAnonymize {
+			// Posts are stored under their author, so the post moves
+			anonymized := *post
+			anonymized.UserID = models.DeletedUserID
+			if err := s.UpdatePost(ctx, &anonymized); err != nil {
+				return nil, err
+			}
+			result.PostsAnonymized++
+		} else {
+			result.PostsDeleted++
+		}
+
+		// Anonymized posts were announced as updated instead
+		var deleted []*events.Event
+		if mode != models.ErasureAnonymize {
+			deleted = append(deleted, events.New(events.PostDeleted, events.Post{ID: post.ID, UserID: userID}))
+		}
+		objectName := fmt.Sprintf("posts/%s/%s.json", userID, post.ID)
+		written := s.prepareEvents(ctx, deleted...)
+		err := s.client.RemoveObject(ctx, s.postsBucket, objectName, minio.RemoveObjectOptions{})
+		written(err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete post: %w", err)
+		}
+		s.postCache.forget(post.ID)
+		s.profileCache.forget(userID)
+		if mode != models.ErasureAnonymize {
+			if err := s.deletePostComments(ctx, post.ID); err != nil {
+				return nil, err
+			}
+			if err := s.deletePostBookmarks(ctx, post.ID); err != nil {
+				return nil, err
+			}
+			if err := s.deleteMentions(ctx, mentionPrefix+post.ID+"/"); err != nil {
+				return nil, err
+			}
+			if err := s.RemoveModerationItem(ctx, post.ID); err != nil {
+				return nil, err
+			}
+			if err := s.deleteReports(ctx, models.ReportTargetPost, post.ID); err != nil {
+				return nil, err
+			}
+			s.untrackPost(ctx, post.ID)
+		}
+	}
+
+	if err := s.eraseUserSeries(ctx, userID, mode == models.ErasureAnonymize); err != nil {
+		return nil, err
+	}
+	if err := s.deleteUserReports(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	comments, err := s.eraseUserComments(ctx, userID, mode == models.ErasureAnonymize)
+	if err != nil {
+		return nil, err
+	}
+	if mode == models.ErasureAnonymize {
+		result.CommentsAnonymized = comments
+	} else {
+		result.CommentsDeleted = comments
+	}
+
+	files, err := s.ListUserFiles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := s.DeleteFile(ctx, file.ID); err != nil {
+			return nil, err
+		}
+		result.FilesDeleted++
+	}
+
+	if err := s.DeleteUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// erasureFixture is what alice, who is erased, and bob, who is not, have
+// stored
+type erasureFixture struct {
+	alice, bob             *models.User
+	alicePost, bobPost     *models.Post
+	aliceComment, bobReply *models.Comment
+	aliceFile              *models.File
+	aliceReport, bobReport *models.Report
+}
+
+func newErasureFixture(t *testing.T, s *StorageService) *erasureFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &erasureFixture{
+		alice: &models.User{Username: "alice", Email: "alice@example.com"},
+		bob:   &models.User{Username: "bob", Email: "bob@example.com"},
+	}
+	require.NoError(t, s.CreateUser(ctx, f.alice))
+	require.NoError(t, s.CreateUser(ctx, f.bob))
+
+	f.alicePost = &models.Post{UserID: f.alice.ID, Title: "Alice's", Status: models.PostStatusPublished}
+	f.bobPost = &models.Post{UserID: f.bob.ID, Title: "Bob's", Status: models.PostStatusPublished}
+	require.NoError(t, s.CreatePost(ctx, f.alicePost))
+	require.NoError(t, s.CreatePost(ctx, f.bobPost))
+
+	f.aliceComment = &models.Comment{PostID: f.bobPost.ID, UserID: f.alice.ID, Content: "Nice"}
+	require.NoError(t, s.CreateComment(ctx, f.aliceComment))
+	f.bobReply = &models.Comment{PostID: f.alicePost.ID, UserID: f.bob.ID, Content: "Thanks"}
+	require.NoError(t, s.CreateComment(ctx, f.bobReply))
+	_, err := s.AddBookmark(ctx, f.bob.ID, f.alicePost.ID)
+	require.NoError(t, err)
+
+	f.aliceFile = &models.File{UserID: f.alice.ID, OriginalName: "a.txt", FileName: "a.txt", ContentType: "text/plain", Size: 5}
+	require.NoError(t, s.StoreFile(ctx, f.aliceFile, strings.NewReader("hello")))
+
+	f.aliceReport = &models.Report{TargetType: models.ReportTargetPost, TargetID: f.bobPost.ID, ReporterID: f.alice.ID, Reason: "spam"}
+	f.bobReport = &models.Report{TargetType: models.ReportTargetPost, TargetID: f.alicePost.ID, ReporterID: f.bob.ID, Reason: "spam"}
+	require.NoError(t, s.CreateReport(ctx, f.aliceReport))
+	require.NoError(t, s.CreateReport(ctx, f.bobReport))
+
+	require.NoError(t, s.RecordLogin(ctx, f.alice.ID, &models.LoginRecord{Success: true, IP: "192.0.2.1", At: time.Now()}))
+	return f
+}
+
+// reporters returns who filed the reports that are stored
+func reporters(t *testing.T, s *StorageService) []string {
+	t.Helper()
+	reports, _, err := s.ListReports(context.Background(), models.Pagination{Page: 1, PageSize: 10}, models.QueryOptions{})
+	require.NoError(t, err)
+	var ids []string
+	for _, report := range reports {
+		ids = append(ids, report.ReporterID)
+	}
+	return ids
+}
+
+func TestEraseUserPurge(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	f := newErasureFixture(t, s)
+
+	result, err := s.EraseUser(ctx, f.alice.ID, models.ErasurePurge)
+	require.NoError(t, err)
+	assert.Equal(t, &models.ErasureResult{Mode: models.ErasurePurge, PostsDeleted: 1, CommentsDeleted: 1, FilesDeleted: 1}, result)
+
+	_, err = s.GetUser(ctx, f.alice.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = s.GetUserByLogin(ctx, "alice")
+	assert.Error(t, err)
+	history, err := s.GetLoginHistory(ctx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// Her post goes with what others left on it
+	_, err = s.GetPost(ctx, f.alicePost.ID)
+	assert.Error(t, err)
+	_, err = s.GetComment(ctx, f.alicePost.ID, f.bobReply.ID)
+	assert.ErrorIs(t, err, ErrCommentNotFound)
+	bookmarks, _, err := s.ListBookmarks(ctx, f.bob.ID, models.Pagination{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Empty(t, bookmarks)
+
+	// Her comments and reports on other posts go too
+	_, err = s.GetComment(ctx, f.bobPost.ID, f.aliceComment.ID)
+	assert.ErrorIs(t, err, ErrCommentNotFound)
+	assert.Empty(t, reporters(t, s))
+
+	_, err = s.GetFile(ctx, f.aliceFile.ID)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	// Bob keeps his own
+	_, err = s.GetPost(ctx, f.bobPost.ID)
+	assert.NoError(t, err)
+	_, err = s.GetUser(ctx, f.bob.ID)
+	assert.NoError(t, err)
+}
+
+func TestEraseUserAnonymize(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	f := newErasureFixture(t, s)
+
+	result, err := s.EraseUser(ctx, f.alice.ID, models.ErasureAnonymize)
+	require.NoError(t, err)
+	assert.Equal(t, &models.ErasureResult{Mode: models.ErasureAnonymize, PostsAnonymized: 1, CommentsAnonymized: 1, FilesDeleted: 1}, result)
+
+	_, err = s.GetUser(ctx, f.alice.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = s.GetFile(ctx, f.aliceFile.ID)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	// Her post and comment stay, no longer hers, with what others left on it
+	post, err := s.GetPost(ctx, f.alicePost.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedUserID, post.UserID)
+	assert.Equal(t, "Alice's", post.Title)
+	posts, err := s.ListUserPosts(ctx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+
+	comment, err := s.GetComment(ctx, f.bobPost.ID, f.aliceComment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedUserID, comment.UserID)
+	_, err = s.GetComment(ctx, f.alicePost.ID, f.bobReply.ID)
+	assert.NoError(t, err)
+	bookmarks, _, err := s.ListBookmarks(ctx, f.bob.ID, models.Pagination{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Len(t, bookmarks, 1)
+
+	// Her reports go; those of her post stay
+	assert.Equal(t, []string{f.bob.ID}, reporters(t, s))
+}
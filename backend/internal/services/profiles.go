@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// publicProfilePosts is how many posts a public profile lists
+const publicProfilePosts = 20
+
+// Public profile operations
+//
+// Public profiles are served without signing in, so the posts each one
+// lists are kept in memory, like users, posts and files, and the posts of
+// its user are read again only once the entry expires or one of them is
+// written through this server.
+
+// PublicPosts returns the latest published posts of userID that are not
+// shared with an organization, newest first, as many as a public profile
+// lists
+func (s *StorageService) PublicPosts(ctx context.Context, userID string) ([]*models.Post, error) {
+	if posts, ok := s.profileCache.get(userID); ok {
+		return posts, nil
+	}
+
+	posts, err := s.ListUserPosts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	posts = slices.DeleteFunc(posts, func(post *models.Post) bool {
+		return post.Status != models.PostStatusPublished || post.OrgID != ""
+	})
+	// Posts published before publishing was recorded go last
+	publishedAt := func(post *models.Post) time.Time {
+		if post.PublishedAt == nil {
+			return time.Time{}
+		}
+		return *post.PublishedAt
+	}
+	slices.SortStableFunc(posts, func(a, b *models.Post) int {
+		return publishedAt(b).Compare(publishedAt(a))
+	})
+	posts = posts[:min(len(posts), publicProfilePosts)]
+
+	s.profileCache.set(userID, posts)
+	return posts, nil
+}
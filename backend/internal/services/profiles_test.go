@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicPosts(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	for i := range publicProfilePosts + 2 {
+		publishedAt := start.Add(time.Duration(i) * time.Hour)
+		post := &models.Post{UserID: "alice", Title: fmt.Sprint(i), Status: models.PostStatusPublished, PublishedAt: &publishedAt}
+		require.NoError(t, s.CreatePost(ctx, post))
+	}
+	require.NoError(t, s.CreatePost(ctx, &models.Post{UserID: "alice", Title: "draft", Status: models.PostStatusDraft}))
+	require.NoError(t, s.CreatePost(ctx, &models.Post{UserID: "alice", Title: "team", Status: models.PostStatusPublished, OrgID: "org"}))
+	require.NoError(t, s.CreatePost(ctx, &models.Post{UserID: "bob", Title: "bob", Status: models.PostStatusPublished}))
+
+	posts, err := s.PublicPosts(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, posts, publicProfilePosts)
+	assert.Equal(t, fmt.Sprint(publicProfilePosts+1), posts[0].Title)
+	assert.Equal(t, "2", posts[len(posts)-1].Title)
+
+	// Served from memory, as copies
+	posts[0].Title = "changed"
+	require.NoError(t, s.writeBucketJSON(ctx, s.postsBucket, fmt.Sprintf("posts/alice/%s.json", posts[1].ID), &models.Post{ID: posts[1].ID, UserID: "alice"}))
+	posts, err = s.PublicPosts(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, posts, publicProfilePosts)
+	assert.Equal(t, fmt.Sprint(publicProfilePosts+1), posts[0].Title)
+
+	// Until the user writes a post
+	latest := start.AddDate(1, 0, 0)
+	require.NoError(t, s.CreatePost(ctx, &models.Post{UserID: "alice", Title: "latest", Status: models.PostStatusPublished, PublishedAt: &latest}))
+	posts, err = s.PublicPosts(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "latest", posts[0].Title)
+	assert.Equal(t, fmt.Sprint(publicProfilePosts+1), posts[1].Title)
+	assert.Equal(t, fmt.Sprint(publicProfilePosts-1), posts[2].Title)
+
+	require.NoError(t, s.DeletePost(ctx, posts[0].ID))
+	posts, err = s.PublicPosts(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(publicProfilePosts+1), posts[0].Title)
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/moderation"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrReportNotFound = errors.New("report not found")
+	// ErrAlreadyReported is returned when a user reports content again
+	// while their report of it is still open
+	ErrAlreadyReported = errors.New("content already reported")
+)
+
+const reportsPrefix = "reports/"
+
+// Report operations
+//
+// Reports live under reports/<post or file>/<id>/<reporter id>.json in the
+// posts bucket, so a user has one report of each post or file and the
+// reports of one are listed together. Once reportThreshold of them are
+// open, they are escalated and a published post is held for review.
+
+// CreateReport files report, unless its reporter already has an open
+// report of the same content. Escalating is best effort; failures are
+// logged and the report stays open.
+func (s *StorageService) CreateReport(ctx context.Context, report *models.Report) error {
+	objectName := reportObjectName(report.TargetType, report.TargetID, report.ReporterID)
+
+	var existing models.Report
+	err := s.readBucketJSON(ctx, s.postsBucket, objectName, &existing)
+	if err == nil && existing.IsOpen() {
+		return ErrAlreadyReported
+	}
+	if err != nil && !errors.Is(err, errObjectNotFound) {
+		return fmt.Errorf("failed to read report: %w", err)
+	}
+
+	report.ID = uuid.New().String()
+	report.Status = models.ReportStatusOpen
+	report.CreatedAt = time.Now()
+	if err := s.writeBucketJSON(ctx, s.postsBucket, objectName, report); err != nil {
+		return fmt.Errorf("failed to store report: %w", err)
+	}
+
+	if err := s.escalateReports(ctx, report); err != nil {
+		s.log(ctx).Warn("Failed to escalate reports", "targetType", report.TargetType, "targetId", report.TargetID, "error", err)
+	}
+	return nil
+}
+
+// escalateReports escalates the open reports of the content report is
+// about once there are reportThreshold of them. A published post is held
+// for review the first time.
+func (s *StorageService) escalateReports(ctx context.Context, report *models.Report) error {
+	if s.reportThreshold <= 0 {
+		return nil
+	}
+	open, err := s.openReports(ctx, report.TargetType, report.TargetID)
+	if err != nil || len(open) < s.reportThreshold {
+		return err
+	}
+
+	crossed := true
+	for _, other := range open {
+		if other.Status == models.ReportStatusEscalated {
+			crossed = false
+			continue
+		}
+		other.Status = models.ReportStatusEscalated
+		if err := s.saveReport(ctx, other); err != nil {
+			return err
+		}
+	}
+	report.Status = models.ReportStatusEscalated
+
+	if !crossed || report.TargetType != models.ReportTargetPost {
+		return nil
+	}
+	return s.holdReportedPost(ctx, report.TargetID, len(open))
+}
+
+// holdReportedPost takes a published post out of sight until a post admin
+// approves it in the moderation queue
+func (s *StorageService) holdReportedPost(ctx context.Context, postID string, reports int) error {
+	post, err := s.GetPost(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if post.Status != models.PostStatusPublished {
+		return nil
+	}
+
+	if err := s.queueForReview(ctx, &models.ModerationItem{
+		ID:      post.ID,
+		Kind:    models.ModerationPost,
+		PostID:  post.ID,
+		Reasons: []string{moderation.ReasonReports + ":" + strconv.Itoa(reports)},
+	}); err != nil {
+		return err
+	}
+	post.Status = models.PostStatusPending
+	return s.UpdatePost(ctx, post)
+}
+
+// ListReports returns the page of reports that pagination selects after
+// opts filtered and sorted them, and how many reports matched
+func (s *StorageService) ListReports(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.Report, int64, error) {
+	return listPage[models.Report](ctx, s, s.postsBucket, reportsPrefix, nil, pagination, opts)
+}
+
+// ResolveReports closes every open report of a post or file with status,
+// resolved or dismissed, and the note of the admin. It returns the
+// reports it closed, or ErrReportNotFound when none was open.
+func (s *StorageService) ResolveReports(ctx context.Context, targetType, targetID, status, resolution, adminID string) ([]*models.Report, error) {
+	open, err := s.openReports(ctx, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if len(open) == 0 {
+		return nil, ErrReportNotFound
+	}
+
+	now := time.Now()
+	for _, report := range open {
+		report.Status = status
+		report.Resolution = resolution
+		report.ResolvedBy = adminID
+		report.ResolvedAt = &now
+		if err := s.saveReport(ctx, report); err != nil {
+			return nil, err
+		}
+	}
+	return open, nil
+}
+
+func (s *StorageService) openReports(ctx context.Context, targetType, targetID string) ([]*models.Report, error) {
+	open, _, err := listPage[models.Report](ctx, s, s.postsBucket, reportTargetPrefix(targetType, targetID), nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{
+		Filters: []models.Filter{{Field: "status", Op: models.OpIn, Value: []string{models.ReportStatusOpen, models.ReportStatusEscalated}}},
+	})
+	return open, err
+}
+
+func (s *StorageService) saveReport(ctx context.Context, report *models.Report) error {
+	if err := s.writeBucketJSON(ctx, s.postsBucket, reportObjectName(report.TargetType, report.TargetID, report.ReporterID), report); err != nil {
+		return fmt.Errorf("failed to store report: %w", err)
+	}
+	return nil
+}
+
+// deleteReports removes the reports of a deleted post or file
+func (s *StorageService) deleteReports(ctx context.Context, targetType, targetID string) error {
+	return s.removeReports(ctx, reportTargetPrefix(targetType, targetID), func(string) bool { return true })
+}
+
+// deleteUserReports removes the reports an erased user filed. It lists
+// every report.
+func (s *StorageService) deleteUserReports(ctx context.Context, userID string) error {
+	return s.removeReports(ctx, reportsPrefix, func(key string) bool { return strings.HasSuffix(key, "/"+userID+".json") })
+}
+
+func (s *StorageService) removeReports(ctx context.Context, prefix string, include func(key string) bool) error {
+	for object := range s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list reports: %w", object.Err)
+		}
+		if !include(object.Key) {
+			continue
+		}
+		if err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete report: %w", err)
+		}
+	}
+	return nil
+}
+
+func reportTargetPrefix(targetType, targetID string) string {
+	return reportsPrefix + targetType + "/" + targetID + "/"
+}
+
+func reportObjectName(targetType, targetID, reporterID string) string {
+	return reportTargetPrefix(targetType, targetID) + reporterID + ".json"
+}
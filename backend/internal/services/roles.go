@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+)
+
+// authCacheTTL bounds how long a change to a role or an account's status
+// takes to reach every server
+const authCacheTTL = 30 * time.Second
+
+// Role operations
+//
+// Roles live under roles/<name>.json in the users bucket. The built-in
+// roles exist without an object until an admin changes them.
+func (s *StorageService) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, roleObjectName(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			if role, ok := models.DefaultRoles[name]; ok {
+				copied := *role
+				return &copied, nil
+			}
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to read role data: %w", err)
+	}
+
+	var role models.Role
+	if err := json.Unmarshal(data, &role); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal role: %w", err)
+	}
+	if role.Name == models.RoleAdmin {
+		role.Permissions = []string{models.PermAll}
+	}
+
+	return &role, nil
+}
+
+// CachedRole is GetRole for authorization checks; changes can take up to
+// authCacheTTL to show up
+func (s *StorageService) CachedRole(ctx context.Context, name string) (*models.Role, error) {
+	if role, ok := s.roleCache.get(name); ok {
+		return role, nil
+	}
+
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.roleCache.set(name, role)
+	return role, nil
+}
+
+func (s *StorageService) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	roles := []*models.Role{}
+	stored := make(map[string]bool)
+
+	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "roles/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list roles: %w", object.Err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(object.Key, "roles/"), ".json")
+		role, err := s.GetRole(ctx, name)
+		if err != nil {
+			continue
+		}
+		roles = append(roles, role)
+		stored[role.Name] = true
+	}
+
+	for name, role := range models.DefaultRoles {
+		if !stored[name] {
+			copied := *role
+			roles = append(roles, &copied)
+		}
+	}
+
+	slices.SortFunc(roles, func(a, b *models.Role) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return roles, nil
+}
+
+// SaveRole creates or replaces a role
+func (s *StorageService) SaveRole(ctx context.Context, role *models.Role) error {
+	now := time.Now()
+	if role.CreatedAt.IsZero() {
+		role.CreatedAt = now
+	}
+	role.UpdatedAt = now
+	_, role.BuiltIn = models.DefaultRoles[role.Name]
+
+	data, err := json.Marshal(role)
+	if err != nil {
+		return fmt.Errorf("failed to marshal role: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, roleObjectName(role.Name), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+
+	s.roleCache.forget(role.Name)
+	return nil
+}
+
+// DeleteRole removes a custom role that no user has
+func (s *StorageService) DeleteRole(ctx context.Context, name string) error {
+	inUse, err := s.roleInUse(ctx, name)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrRoleInUse
+	}
+
+	if err := s.client.RemoveObject(ctx, s.usersBucket, roleObjectName(name), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	s.roleCache.forget(name)
+	return nil
+}
+
+func (s *StorageService) roleInUse(ctx context.Context, name string) (bool, error) {
+	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "users/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return false, fmt.Errorf("failed to list users: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var user models.User
+		if err := json.Unmarshal(data, &user); err != nil {
+			continue
+		}
+
+		if user.Role == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func roleObjectName(name string) string {
+	return "roles/" + name + ".json"
+}
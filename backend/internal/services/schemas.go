@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrMetadataSchemaNotFound = errors.New("metadata schema not found")
+
+const metadataSchemaPrefix = "metadata-schemas/"
+
+// Metadata schema operations
+//
+// Schemas live under metadata-schemas/<name>.json in the users bucket.
+// Uploads look up the schema of their folder in a short-lived cache of all
+// schemas, so a change reaches every server within authCacheTTL.
+func (s *StorageService) GetMetadataSchema(ctx context.Context, name string) (*models.MetadataSchema, error) {
+	var schema models.MetadataSchema
+	if err := s.readJSONObject(ctx, metadataSchemaObjectName(name), &schema); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrMetadataSchemaNotFound
+		}
+		return nil, fmt.Errorf("failed to get metadata schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// ListMetadataSchemas returns all schemas ordered by folder
+func (s *StorageService) ListMetadataSchemas(ctx context.Context) ([]*models.MetadataSchema, error) {
+	if schemas, ok := s.schemaCache.get(""); ok {
+		return schemas, nil
+	}
+
+	schemas := []*models.MetadataSchema{}
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: metadataSchemaPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list metadata schemas: %w", object.Err)
+		}
+
+		var schema models.MetadataSchema
+		if err := s.readJSONObject(ctx, object.Key, &schema); err != nil {
+			continue
+		}
+		schemas = append(schemas, &schema)
+	}
+
+	slices.SortFunc(schemas, func(a, b *models.MetadataSchema) int {
+		return strings.Compare(a.Folder, b.Folder)
+	})
+	s.schemaCache.set("", schemas)
+	return schemas, nil
+}
+
+// MetadataSchemaFor returns the schema the metadata of files in folder must
+// match, or nil if there is none
+func (s *StorageService) MetadataSchemaFor(ctx context.Context, folder string) (*models.MetadataSchema, error) {
+	schemas, err := s.ListMetadataSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sorted by folder, so the last match is the deepest
+	var match *models.MetadataSchema
+	for _, schema := range schemas {
+		if schema.Covers(folder) {
+			match = schema
+		}
+	}
+	return match, nil
+}
+
+// SaveMetadataSchema creates or replaces a schema
+func (s *StorageService) SaveMetadataSchema(ctx context.Context, schema *models.MetadataSchema) error {
+	now := time.Now()
+	if schema.CreatedAt.IsZero() {
+		schema.CreatedAt = now
+	}
+	schema.UpdatedAt = now
+
+	if err := s.writeJSONObject(ctx, metadataSchemaObjectName(schema.Name), schema); err != nil {
+		return fmt.Errorf("failed to store metadata schema: %w", err)
+	}
+	s.schemaCache.forget("")
+	return nil
+}
+
+// DeleteMetadataSchema removes a schema. Files stored under it keep their
+// metadata.
+func (s *StorageService) DeleteMetadataSchema(ctx context.Context, name string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, metadataSchemaObjectName(name), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete metadata schema: %w", err)
+	}
+	s.schemaCache.forget("")
+	return nil
+}
+
+func metadataSchemaObjectName(name string) string {
+	return metadataSchemaPrefix + name + ".json"
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrSeriesNotFound = errors.New("series not found")
+	// ErrSeriesPostTaken is returned when a post is added to a series while
+	// it is part of another one
+	ErrSeriesPostTaken = errors.New("post is part of another series")
+	// ErrSeriesOrder is returned when a new order does not list every post
+	// of the series exactly once
+	ErrSeriesOrder = errors.New("order must list every post of the series once")
+)
+
+const (
+	seriesPrefix      = "series/"
+	seriesIndexPrefix = "series-posts/"
+)
+
+// seriesIndex is the series a post is part of
+type seriesIndex struct {
+	SeriesID string `json:"seriesId"`
+}
+
+// Series operations
+//
+// Series live under series/<series id>.json in the posts bucket and list
+// their posts in reading order. A post is part of at most one series, of
+// its own author; series-posts/<post id>.json names it, so a post finds
+// its series without listing them all.
+
+func (s *StorageService) CreateSeries(ctx context.Context, series *models.Series) error {
+	series.ID = uuid.New().String()
+	series.PostIDs = []string{}
+	series.CreatedAt = time.Now()
+	return s.SaveSeries(ctx, series)
+}
+
+func (s *StorageService) GetSeries(ctx context.Context, seriesID string) (*models.Series, error) {
+	var series models.Series
+	if err := s.readBucketJSON(ctx, s.postsBucket, seriesObjectName(seriesID), &series); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, fmt.Errorf("failed to read series: %w", err)
+	}
+	return &series, nil
+}
+
+// SaveSeries stores series as it is; use the post operations below to
+// change which posts it has
+func (s *StorageService) SaveSeries(ctx context.Context, series *models.Series) error {
+	series.UpdatedAt = time.Now()
+	if err := s.writeBucketJSON(ctx, s.postsBucket, seriesObjectName(series.ID), series); err != nil {
+		return fmt.Errorf("failed to store series: %w", err)
+	}
+	return nil
+}
+
+// ListSeries returns the page of series that pagination selects after
+// opts filtered and sorted them, and how many series matched
+func (s *StorageService) ListSeries(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.Series, int64, error) {
+	return listPage[models.Series](ctx, s, s.postsBucket, seriesPrefix, nil, pagination, opts)
+}
+
+// DeleteSeries removes a series. Its posts stay and are part of no series.
+func (s *StorageService) DeleteSeries(ctx context.Context, series *models.Series) error {
+	for _, postID := range series.PostIDs {
+		if err := s.removeSeriesIndex(ctx, postID); err != nil {
+			return err
+		}
+	}
+	if err := s.client.RemoveObject(ctx, s.postsBucket, seriesObjectName(series.ID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete series: %w", err)
+	}
+	return nil
+}
+
+// AddSeriesPost puts postID at position of series, counting from 1; 0 or
+// a position past the end appends it. Adding a post of the series again
+// moves it.
+func (s *StorageService) AddSeriesPost(ctx context.Context, series *models.Series, postID string, position int) error {
+	current, err := s.postSeriesID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if current != "" && current != series.ID {
+		return ErrSeriesPostTaken
+	}
+
+	postIDs := slices.DeleteFunc(slices.Clone(series.PostIDs), func(id string) bool { return id == postID })
+	if position <= 0 || position > len(postIDs) {
+		position = len(postIDs) + 1
+	}
+	series.PostIDs = slices.Insert(postIDs, position-1, postID)
+
+	if err := s.writeBucketJSON(ctx, s.postsBucket, seriesIndexObjectName(postID), &seriesIndex{SeriesID: series.ID}); err != nil {
+		return fmt.Errorf("failed to store series index: %w", err)
+	}
+	return s.SaveSeries(ctx, series)
+}
+
+// RemoveSeriesPost takes postID out of series, if it is part of it
+func (s *StorageService) RemoveSeriesPost(ctx context.Context, series *models.Series, postID string) error {
+	if !slices.Contains(series.PostIDs, postID) {
+		return nil
+	}
+	series.PostIDs = slices.DeleteFunc(series.PostIDs, func(id string) bool { return id == postID })
+	if err := s.SaveSeries(ctx, series); err != nil {
+		return err
+	}
+	return s.removeSeriesIndex(ctx, postID)
+}
+
+// ReorderSeries puts the posts of series in the order of postIDs, which
+// must list each of them once
+func (s *StorageService) ReorderSeries(ctx context.Context, series *models.Series, postIDs []string) error {
+	if len(postIDs) != len(series.PostIDs) {
+		return ErrSeriesOrder
+	}
+	seen := make(map[string]bool, len(postIDs))
+	for _, postID := range postIDs {
+		if seen[postID] || !slices.Contains(series.PostIDs, postID) {
+			return ErrSeriesOrder
+		}
+		seen[postID] = true
+	}
+
+	series.PostIDs = slices.Clone(postIDs)
+	return s.SaveSeries(ctx, series)
+}
+
+// GetPostSeries returns the series postID is part of, or nil
+func (s *StorageService) GetPostSeries(ctx context.Context, postID string) (*models.Series, error) {
+	seriesID, err := s.postSeriesID(ctx, postID)
+	if err != nil || seriesID == "" {
+		return nil, err
+	}
+	series, err := s.GetSeries(ctx, seriesID)
+	if errors.Is(err, ErrSeriesNotFound) {
+		return nil, nil
+	}
+	return series, err
+}
+
+func (s *StorageService) postSeriesID(ctx context.Context, postID string) (string, error) {
+	var index seriesIndex
+	if err := s.readBucketJSON(ctx, s.postsBucket, seriesIndexObjectName(postID), &index); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read series index: %w", err)
+	}
+	return index.SeriesID, nil
+}
+
+// leaveSeries takes a post that is deleted or changes author out of its
+// series, if any
+func (s *StorageService) leaveSeries(ctx context.Context, postID string) error {
+	series, err := s.GetPostSeries(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if series == nil {
+		return s.removeSeriesIndex(ctx, postID)
+	}
+	return s.RemoveSeriesPost(ctx, series, postID)
+}
+
+// eraseUserSeries deletes the series of an erased user, or gives them to
+// DeletedUserID along with their posts when anonymize is set. It lists
+// every series.
+func (s *StorageService) eraseUserSeries(ctx context.Context, userID string, anonymize bool) error {
+	owned, _, err := s.ListSeries(ctx, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{
+		Filters: []models.Filter{{Field: "userId", Op: models.OpEq, Value: userID}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, series := range owned {
+		if anonymize {
+			series.UserID = models.DeletedUserID
+			err = s.SaveSeries(ctx, series)
+		} else {
+			err = s.DeleteSeries(ctx, series)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StorageService) removeSeriesIndex(ctx context.Context, postID string) error {
+	if err := s.client.RemoveObject(ctx, s.postsBucket, seriesIndexObjectName(postID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete series index: %w", err)
+	}
+	return nil
+}
+
+func seriesObjectName(seriesID string) string {
+	return seriesPrefix + seriesID + ".json"
+}
+
+func seriesIndexObjectName(postID string) string {
+	return seriesIndexPrefix + postID + ".json"
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrInvalidServiceKey      = errors.New("invalid service account key")
+)
+
+// usageFlushInterval is how often the usage of a service account is
+// written back to storage by each server
+const usageFlushInterval = time.Minute
+
+// serviceAccountUsage counts requests of service accounts in memory until
+// they are written back, so authenticating does not write to storage on
+// every request
+type serviceAccountUsage struct {
+	mu      sync.Mutex
+	pending map[string]*pendingUsage
+}
+
+type pendingUsage struct {
+	count     int64
+	keyID     string
+	ip        string
+	at        time.Time
+	flushedAt time.Time
+}
+
+// Service account operations
+//
+// Service accounts live under serviceaccounts/<id>.json in the users
+// bucket.
+func (s *StorageService) CreateServiceAccount(ctx context.Context, account *models.ServiceAccount) (string, error) {
+	account.ID = uuid.New().String()
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = account.CreatedAt
+
+	key, token, err := newServiceAccountKey(account.ID)
+	if err != nil {
+		return "", err
+	}
+	account.Keys = []*models.ServiceAccountKey{key}
+
+	if err := s.SaveServiceAccount(ctx, account); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *StorageService) GetServiceAccount(ctx context.Context, id string) (*models.ServiceAccount, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, serviceAccountObjectName(id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrServiceAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to read service account data: %w", err)
+	}
+
+	var account models.ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal service account: %w", err)
+	}
+
+	return &account, nil
+}
+
+// ListServiceAccounts returns all service accounts ordered by name
+func (s *StorageService) ListServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error) {
+	accounts := []*models.ServiceAccount{}
+
+	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "serviceaccounts/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list service accounts: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var account models.ServiceAccount
+		if err := json.Unmarshal(data, &account); err != nil {
+			continue
+		}
+		accounts = append(accounts, &account)
+	}
+
+	slices.SortFunc(accounts, func(a, b *models.ServiceAccount) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return accounts, nil
+}
+
+func (s *StorageService) SaveServiceAccount(ctx context.Context, account *models.ServiceAccount) error {
+	account.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service account: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, serviceAccountObjectName(account.ID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store service account: %w", err)
+	}
+
+	s.serviceAccountCache.forget(account.ID)
+	return nil
+}
+
+func (s *StorageService) DeleteServiceAccount(ctx context.Context, id string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, serviceAccountObjectName(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete service account: %w", err)
+	}
+
+	s.serviceAccountCache.forget(id)
+	return nil
+}
+
+// RotateServiceAccountKey adds a new key to account and lets the existing
+// keys expire after grace, at once when grace is zero
+func (s *StorageService) RotateServiceAccountKey(ctx context.Context, account *models.ServiceAccount, grace time.Duration) (*models.ServiceAccountKey, string, error) {
+	key, token, err := newServiceAccountKey(account.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expiresAt := time.Now().Add(grace)
+	for _, existing := range account.ActiveKeys(time.Now()) {
+		if existing.ExpiresAt == nil || existing.ExpiresAt.After(expiresAt) {
+			existing.ExpiresAt = &expiresAt
+		}
+	}
+	// Drop keys that expired long ago so the list does not grow forever
+	account.Keys = slices.DeleteFunc(account.Keys, func(existing *models.ServiceAccountKey) bool {
+		return existing.ExpiresAt != nil && time.Since(*existing.ExpiresAt) > 30*24*time.Hour
+	})
+	account.Keys = append(account.Keys, key)
+
+	if err := s.SaveServiceAccount(ctx, account); err != nil {
+		return nil, "", err
+	}
+	return key, token, nil
+}
+
+// AuthenticateServiceAccount returns the enabled service account token is
+// an active key of, and counts the request from ip towards its usage.
+// Changes made on other servers can take up to authCacheTTL to show up.
+func (s *StorageService) AuthenticateServiceAccount(ctx context.Context, token, ip string) (*models.ServiceAccount, error) {
+	rest, ok := strings.CutPrefix(token, models.ServiceAccountKeyPrefix)
+	if !ok {
+		return nil, ErrInvalidServiceKey
+	}
+	accountID, _, ok := strings.Cut(rest, ".")
+	if !ok {
+		return nil, ErrInvalidServiceKey
+	}
+
+	account, err := s.CachedServiceAccount(ctx, accountID)
+	if errors.Is(err, ErrServiceAccountNotFound) {
+		return nil, ErrInvalidServiceKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Disabled {
+		return nil, ErrInvalidServiceKey
+	}
+
+	hash := hashServiceAccountKey(token)
+	for _, key := range account.ActiveKeys(time.Now()) {
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) == 1 {
+			s.recordServiceAccountUsage(ctx, account.ID, key.ID, ip)
+			return account, nil
+		}
+	}
+	return nil, ErrInvalidServiceKey
+}
+
+// CachedServiceAccount returns the service account id for checking
+// credentials; changes made on other servers can take up to authCacheTTL to
+// show up
+func (s *StorageService) CachedServiceAccount(ctx context.Context, id string) (*models.ServiceAccount, error) {
+	if account, ok := s.serviceAccountCache.get(id); ok {
+		return account, nil
+	}
+	account, err := s.GetServiceAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.serviceAccountCache.set(id, account)
+	return account, nil
+}
+
+// recordServiceAccountUsage counts a request and writes the counts back
+// once usageFlushInterval has passed since the last write
+func (s *StorageService) recordServiceAccountUsage(ctx context.Context, accountID, keyID, ip string) {
+	usage := s.serviceAccountUsage
+	usage.mu.Lock()
+	pending := usage.pending[accountID]
+	if pending == nil {
+		pending = &pendingUsage{}
+		usage.pending[accountID] = pending
+	}
+	pending.count++
+	pending.keyID = keyID
+	pending.ip = ip
+	pending.at = time.Now()
+
+	if time.Since(pending.flushedAt) < usageFlushInterval {
+		usage.mu.Unlock()
+		return
+	}
+	flush := *pending
+	pending.count = 0
+	pending.flushedAt = time.Now()
+	usage.mu.Unlock()
+
+	// Written in the background with the stored account, not the cached
+	// one, so a concurrent change by an admin is not overwritten
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+
+		account, err := s.GetServiceAccount(ctx, accountID)
+		if err != nil {
+			s.log(ctx).Error("Failed to record service account usage", "serviceAccount", accountID, "error", err)
+			return
+		}
+		account.UsageCount += flush.count
+		account.LastUsedAt = &flush.at
+		account.LastUsedIP = flush.ip
+		for _, key := range account.Keys {
+			if key.ID == flush.keyID {
+				key.LastUsedAt = &flush.at
+			}
+		}
+		if err := s.SaveServiceAccount(ctx, account); err != nil {
+			s.log(ctx).Error("Failed to record service account usage", "serviceAccount", accountID, "error", err)
+		}
+	}()
+}
+
+// newServiceAccountKey generates a key of the form sa_<account ID>.<secret>
+func newServiceAccountKey(accountID string) (*models.ServiceAccountKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate service account key: %w", err)
+	}
+	token := models.ServiceAccountKeyPrefix + accountID + "." + base64.RawURLEncoding.EncodeToString(secret)
+
+	return &models.ServiceAccountKey{
+		ID:        uuid.New().String(),
+		Hash:      hashServiceAccountKey(token),
+		Hint:      token[len(token)-4:],
+		CreatedAt: time.Now(),
+	}, token, nil
+}
+
+// hashServiceAccountKey hashes a key for storage. The keys are random, so
+// a fast hash is enough.
+func hashServiceAccountKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func serviceAccountObjectName(id string) string {
+	return "serviceaccounts/" + id + ".json"
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// User settings operations
+//
+// Settings live under settings/<user id>.json in the users bucket, apart
+// from the user so that saving them does not race with profile changes.
+
+// GetUserSettings returns the settings of a user, the defaults when they
+// never saved any
+func (s *StorageService) GetUserSettings(ctx context.Context, userID string) (*models.UserSettings, error) {
+	settings := models.DefaultUserSettings()
+	if err := s.readJSONObject(ctx, settingsObjectName(userID), settings); err != nil && !errors.Is(err, errObjectNotFound) {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *StorageService) SaveUserSettings(ctx context.Context, userID string, settings *models.UserSettings) error {
+	settings.UpdatedAt = time.Now()
+	if err := s.writeJSONObject(ctx, settingsObjectName(userID), settings); err != nil {
+		return fmt.Errorf("failed to store settings: %w", err)
+	}
+	return nil
+}
+
+func (s *StorageService) deleteUserSettings(ctx context.Context, userID string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, settingsObjectName(userID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete settings: %w", err)
+	}
+	return nil
+}
+
+func settingsObjectName(userID string) string {
+	return "settings/" + userID + ".json"
+}
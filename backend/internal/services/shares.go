@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrShareNotFound = errors.New("share not found")
+
+// Share operations
+//
+// Shares live under shares/<token>.json in the files bucket so the public
+// download route can resolve a token with a single read.
+func (s *StorageService) CreateShare(ctx context.Context, share *models.Share) error {
+	token, err := generateShareToken()
+	if err != nil {
+		return err
+	}
+	share.Token = token
+	share.CreatedAt = time.Now()
+
+	return s.saveShare(ctx, share)
+}
+
+func (s *StorageService) GetShare(ctx context.Context, token string) (*models.Share, error) {
+	object, err := s.client.GetObject(ctx, s.filesBucket, shareObjectName(token), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("failed to read share data: %w", err)
+	}
+
+	var share models.Share
+	if err := json.Unmarshal(data, &share); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+	}
+
+	return &share, nil
+}
+
+func (s *StorageService) UpdateShare(ctx context.Context, share *models.Share) error {
+	return s.saveShare(ctx, share)
+}
+
+func (s *StorageService) ListShares(ctx context.Context, fileID string) ([]*models.Share, error) {
+	shares := []*models.Share{}
+
+	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
+		Prefix:    "shares/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			continue
+		}
+
+		obj, err := s.client.GetObject(ctx, s.filesBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var share models.Share
+		if err := json.Unmarshal(data, &share); err != nil {
+			continue
+		}
+
+		if share.FileID == fileID {
+			shares = append(shares, &share)
+		}
+	}
+
+	return shares, nil
+}
+
+func (s *StorageService) DeleteShare(ctx context.Context, token string) error {
+	err := s.client.RemoveObject(ctx, s.filesBucket, shareObjectName(token), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	return nil
+}
+
+func (s *StorageService) saveShare(ctx context.Context, share *models.Share) error {
+	data, err := json.Marshal(share)
+	if err != nil {
+		return fmt.Errorf("failed to marshal share: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.filesBucket, shareObjectName(share.Token), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store share: %w", err)
+	}
+
+	return nil
+}
+
+func shareObjectName(token string) string {
+	return fmt.Sprintf("shares/%s.json", token)
+}
+
+func generateShareToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
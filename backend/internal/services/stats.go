@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+)
+
+// statsRebuildTimeout bounds a rebuild of the statistics counters; another
+// server may start one once it has passed
+const statsRebuildTimeout = time.Hour
+
+// SetStatsCounter makes the service report every user, post and file it
+// writes or deletes, and every download it logs, to counter, which system
+// and file statistics are read from
+func (s *StorageService) SetStatsCounter(counter *stats.Counter) {
+	s.stats = counter
+}
+
+// StatsCounter returns the counter set with SetStatsCounter, or nil
+func (s *StorageService) StatsCounter() *stats.Counter {
+	return s.stats
+}
+
+// countedFields are the fields of posts and files counted in every
+// combination of their values, so that lists filtered by nothing else
+// take their total from the counters
+var countedFields = map[string][]string{
+	stats.Posts:     {"userId", "orgId", "status"},
+	stats.Files:     {"userId", "orgId"},
+	stats.Bookmarks: {"postId"},
+}
+
+func (s *StorageService) trackUser(ctx context.Context, user *models.User) {
+	s.trackStats(ctx, stats.Users, user.ID, "", 0, user.CreatedAt, user)
+}
+
+func (s *StorageService) trackPost(ctx context.Context, post *models.Post) {
+	s.trackStats(ctx, stats.Posts, post.ID, post.Status, 0, post.CreatedAt, post)
+	s.trackTags(ctx, post.ID, directoryTags(post), post.UpdatedAt)
+}
+
+// untrackPost reports a post that was deleted
+func (s *StorageService) untrackPost(ctx context.Context, postID string) {
+	s.untrackStats(ctx, stats.Posts, postID)
+	s.trackTags(ctx, postID, nil, time.Now())
+}
+
+// trackTags reports the tags a post carries in the tag directory
+func (s *StorageService) trackTags(ctx context.Context, postID string, tags []string, at time.Time) {
+	if s.stats == nil {
+		return
+	}
+	if err := s.stats.TrackTags(ctx, postID, tags, at); err != nil {
+		s.log(ctx).Warn("Failed to update tag statistics", "postId", postID, "error", err)
+	}
+}
+
+func (s *StorageService) trackFile(ctx context.Context, file *models.File) {
+	s.trackStats(ctx, stats.Files, file.ID, "", file.Size, file.CreatedAt, file)
+}
+
+// trackStats reports an item that was written. Statistics must not fail
+// writes, so errors are only logged; a rebuild corrects the counts.
+func (s *StorageService) trackStats(ctx context.Context, kind, id, group string, size int64, created time.Time, item models.Queryable) {
+	if s.stats == nil {
+		return
+	}
+
+	var scopes []string
+	if fields := countedFields[kind]; fields != nil {
+		values := make(map[string]string, len(fields))
+		for _, field := range fields {
+			value, _ := item.QueryField(field).(string)
+			values[field] = strings.ToLower(value)
+		}
+		scopes = stats.Scopes(values)
+	}
+	if err := s.stats.Track(ctx, kind, id, group, size, created, scopes...); err != nil {
+		s.log(ctx).Warn("Failed to update statistics", "kind", kind, "id", id, "error", err)
+	}
+}
+
+// countedTotal returns how many items of kind opts selects when the
+// counters know it: once they are built, for options that only require
+// counted fields to equal, or differ from, lowercase values. Sorted lists
+// read every item anyway. It also returns the user opts requires, if any,
+// whose items can be listed alone.
+func (s *StorageService) countedTotal(ctx context.Context, kind string, opts models.QueryOptions) (int64, string, bool) {
+	if s.stats == nil || len(opts.Sort) > 0 {
+		return 0, "", false
+	}
+
+	fields := make(map[string]string, len(opts.Filters))
+	excluded := make(map[string][]string)
+	for _, filter := range opts.Filters {
+		value, isString := filter.Value.(string)
+		if !isString || value != strings.ToLower(value) || !slices.Contains(countedFields[kind], filter.Field) {
+			return 0, "", false
+		}
+		switch filter.Op {
+		case models.OpEq:
+			if _, repeated := fields[filter.Field]; repeated {
+				return 0, "", false
+			}
+			fields[filter.Field] = value
+		case models.OpNe:
+			if !slices.Contains(excluded[filter.Field], value) {
+				excluded[filter.Field] = append(excluded[filter.Field], value)
+			}
+		default:
+			return 0, "", false
+		}
+	}
+	// Items differing in two fields would be taken off twice
+	if len(excluded) > 1 {
+		return 0, "", false
+	}
+	for field := range excluded {
+		if _, required := fields[field]; required {
+			return 0, "", false
+		}
+	}
+
+	ready, err := s.stats.Ready(ctx)
+	if err != nil || !ready {
+		return 0, "", false
+	}
+	total, err := s.stats.Count(ctx, kind, stats.Scope(fields))
+	if err != nil {
+		s.log(ctx).Warn("Failed to read counted total", "kind", kind, "error", err)
+		return 0, "", false
+	}
+	// Items with an excluded value are counted with it added to the scope
+	for field, values := range excluded {
+		for _, value := range values {
+			scope := maps.Clone(fields)
+			scope[field] = value
+			count, err := s.stats.Count(ctx, kind, stats.Scope(scope))
+			if err != nil {
+				s.log(ctx).Warn("Failed to read counted total", "kind", kind, "error", err)
+				return 0, "", false
+			}
+			total -= count
+		}
+	}
+	return total, fields["userId"], true
+}
+
+// untrackStats reports an item that was deleted
+func (s *StorageService) untrackStats(ctx context.Context, kind, id string) {
+	if s.stats == nil {
+		return
+	}
+	if err := s.stats.Untrack(ctx, kind, id); err != nil {
+		s.log(ctx).Warn("Failed to update statistics", "kind", kind, "id", id, "error", err)
+	}
+}
+
+// EnsureStats rebuilds the statistics counters unless they were built
+// before, as on the first start with them
+func (s *StorageService) EnsureStats(ctx context.Context) error {
+	if s.stats == nil {
+		return nil
+	}
+	ready, err := s.stats.Ready(ctx)
+	if err != nil || ready {
+		return err
+	}
+	_, err = s.RebuildStats(ctx)
+	return err
+}
+
+// RebuildStats counts every stored user, post, file, bookmark, tag and
+// download again, which scans all buckets. It returns false without doing anything when another
+// server is already rebuilding. Writes during the rebuild are counted as
+// usual.
+func (s *StorageService) RebuildStats(ctx context.Context) (bool, error) {
+	if s.stats == nil {
+		return false, nil
+	}
+	started, err := s.stats.StartRebuild(ctx, statsRebuildTimeout)
+	if err != nil || !started {
+		return false, err
+	}
+
+	users, err := s.FindUsers(ctx, func(*models.User) bool { return true })
+	if err != nil {
+		return false, err
+	}
+	for _, user := range users {
+		s.trackUser(ctx, user)
+	}
+
+	posts, _, err := listPage[models.Post](ctx, s, s.postsBucket, "posts/", nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, post := range posts {
+		s.trackPost(ctx, post)
+	}
+
+	for _, file := range s.findFiles(ctx, func(*models.File) bool { return true }) {
+		s.trackFile(ctx, file)
+		if err := s.eachAccess(ctx, file, func(entry *models.AccessLogEntry) { s.trackAccess(ctx, entry) }); err != nil {
+			return false, err
+		}
+	}
+
+	bookmarks, _, err := listPage[models.Bookmark](ctx, s, s.usersBucket, bookmarkPrefix, nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, bookmark := range bookmarks {
+		s.trackStats(ctx, stats.Bookmarks, bookmarkStatsID(bookmark.UserID, bookmark.PostID), "", 0, bookmark.CreatedAt, bookmark)
+	}
+
+	if err := s.stats.FinishRebuild(ctx); err != nil {
+		return false, err
+	}
+	s.log(ctx).Info("Rebuilt statistics", "users", len(users), "posts", len(posts))
+	return true, nil
+}
+
+// PingMinIO checks that MinIO answers
+func (s *StorageService) PingMinIO(ctx context.Context) error {
+	if _, err := s.client.BucketExists(ctx, s.usersBucket); err != nil {
+		return fmt.Errorf("failed to reach MinIO: %w", err)
+	}
+	return nil
+}
+
+// CacheSizes returns the number of entries of each in-memory cache
+func (s *StorageService) CacheSizes() map[string]int {
+	return map[string]int{
+		"roles":           s.roleCache.len(),
+		"userStatus":      s.statusCache.len(),
+		"serviceAccounts": s.serviceAccountCache.len(),
+		"maintenance":     s.maintenanceCache.len(),
+		"webhooks":        s.webhookCache.len(),
+		"orgRoles":        s.orgRoleCache.len(),
+		"users":           s.userCache.len(),
+		"posts":           s.postCache.len(),
+		"files":           s.fileCache.len(),
+	}
+}
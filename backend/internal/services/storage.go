@@ -4,40 +4,125 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/logging"
 	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/moderation"
+	"github.com/minio-fullstack-storage/backend/internal/spam"
+	"github.com/minio-fullstack-storage/backend/internal/stats"
+	"github.com/minio-fullstack-storage/backend/internal/tracing"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+var (
+	ErrUploadNotFound      = errors.New("upload not found")
+	ErrUploadAlreadyExists = errors.New("upload already finalized")
+	ErrUploadTooLarge      = errors.New("upload exceeds maximum file size")
+	ErrScanPending         = errors.New("file is awaiting virus scan")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrUserNotFound        = errors.New("user not found")
+)
+
 type StorageService struct {
 	client      *minio.Client
+	credentials *rotatingCredentials
 	usersBucket string
 	postsBucket string
 	filesBucket string
+	upload      config.UploadConfig
+	scanEnabled bool
+	roleCache   *ttlCache[*models.Role]
+	statusCache *ttlCache[string]
+	logger      *slog.Logger
+
+	serviceAccountCache *ttlCache[*models.ServiceAccount]
+	serviceAccountUsage *serviceAccountUsage
+	maintenanceCache    *ttlCache[*models.Maintenance]
+	webhookCache        *ttlCache[[]*models.Webhook]
+	schemaCache         *ttlCache[[]*models.MetadataSchema]
+	orgRoleCache        *ttlCache[string]
+	userCache           *lruCache[*models.User]
+	postCache           *lruCache[*models.Post]
+	fileCache           *lruCache[*models.File]
+	profileCache        *lruCache[[]*models.Post] // by user ID
+	stats               *stats.Counter
+	events              events.Publisher
+	moderation          *moderation.Rules
+	reportThreshold     int
+	spam                spam.Checker
+	conns               *connCounter
 }
 
-func NewStorageService(cfg *config.Config) (*StorageService, error) {
+func NewStorageService(cfg *config.Config, logger *slog.Logger) (*StorageService, error) {
+	conns := &connCounter{}
+	transport, err := newMinIOTransport(cfg.MinIO, conns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO transport: %w", err)
+	}
+
+	// Every MinIO call becomes a span under the request that made it
+	creds := newRotatingCredentials(cfg.MinIO.AccessKeyID, cfg.MinIO.SecretAccessKey)
 	client, err := minio.New(cfg.MinIO.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.MinIO.AccessKeyID, cfg.MinIO.SecretAccessKey, ""),
-		Secure: cfg.MinIO.UseSSL,
-		Region: cfg.MinIO.Region,
+		Creds:     creds.credentials,
+		Secure:    cfg.MinIO.UseSSL,
+		Region:    cfg.MinIO.Region,
+		Transport: tracing.Transport(transport),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
+	if cfg.MinIO.Trace {
+		client.TraceOn(&traceWriter{logger: logger})
+	}
+	spamChecker, err := spam.New(cfg.Moderation.SpamChecker, cfg.Moderation.SpamCheckURL, cfg.Moderation.SpamCheckKey, cfg.Mail.AppURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spam checker: %w", err)
+	}
 
+	cacheTTL := time.Duration(cfg.Cache.TTL) * time.Second
 	service := &StorageService{
 		client:      client,
+		credentials: creds,
+		conns:       conns,
 		usersBucket: cfg.Database.UsersBucket,
 		postsBucket: cfg.Database.PostsBucket,
 		filesBucket: cfg.Database.FilesBucket,
+		upload:      cfg.Upload,
+		scanEnabled: cfg.Scan.Enabled,
+		roleCache:   newTTLCache[*models.Role](authCacheTTL),
+		statusCache: newTTLCache[string](authCacheTTL),
+		logger:      logger,
+
+		serviceAccountCache: newTTLCache[*models.ServiceAccount](authCacheTTL),
+		serviceAccountUsage: &serviceAccountUsage{pending: make(map[string]*pendingUsage)},
+		maintenanceCache:    newTTLCache[*models.Maintenance](authCacheTTL),
+		webhookCache:        newTTLCache[[]*models.Webhook](authCacheTTL),
+		schemaCache:         newTTLCache[[]*models.MetadataSchema](authCacheTTL),
+		orgRoleCache:        newTTLCache[string](authCacheTTL),
+		userCache:           newLRUCache(cfg.Cache.Size, cacheTTL, cloneUser),
+		postCache:           newLRUCache(cfg.Cache.Size, cacheTTL, clonePost),
+		fileCache:           newLRUCache(cfg.Cache.Size, cacheTTL, cloneFile),
+		profileCache:        newLRUCache(cfg.Cache.Size, cacheTTL, clonePosts),
+		moderation:          moderation.New(cfg.Moderation.Keywords, cfg.Moderation.MaxLinks, time.Duration(cfg.Moderation.NewAccountHours)*time.Hour),
+		reportThreshold:     cfg.Moderation.ReportThreshold,
+		spam:                spamChecker,
 	}
 
 	// Initialize buckets
@@ -48,6 +133,49 @@ func NewStorageService(cfg *config.Config) (*StorageService, error) {
 	return service, nil
 }
 
+// SetCredentials switches the MinIO client to new credentials, such as
+// rotated ones from a secrets provider, from its next request on
+func (s *StorageService) SetCredentials(accessKeyID, secretAccessKey string) {
+	s.credentials.set(accessKeyID, secretAccessKey)
+}
+
+// rotatingCredentials are static MinIO credentials that can be replaced
+type rotatingCredentials struct {
+	mu          sync.Mutex
+	value       credentials.Value
+	credentials *credentials.Credentials
+}
+
+func newRotatingCredentials(accessKeyID, secretAccessKey string) *rotatingCredentials {
+	r := &rotatingCredentials{}
+	r.value = credentials.Value{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SignerType: credentials.SignatureV4}
+	r.credentials = credentials.New(r)
+	return r
+}
+
+func (r *rotatingCredentials) set(accessKeyID, secretAccessKey string) {
+	r.mu.Lock()
+	r.value.AccessKeyID, r.value.SecretAccessKey = accessKeyID, secretAccessKey
+	r.mu.Unlock()
+	r.credentials.Expire()
+}
+
+// Retrieve and IsExpired implement credentials.Provider; Expire makes the
+// client retrieve them again
+func (r *rotatingCredentials) Retrieve() (credentials.Value, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value, nil
+}
+
+func (r *rotatingCredentials) IsExpired() bool { return false }
+
+// log returns the logger of the request ctx belongs to, which carries its
+// ID, or the service's logger for background work
+func (s *StorageService) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
+
 func (s *StorageService) initializeBuckets(ctx context.Context) error {
 	buckets := []string{s.usersBucket, s.postsBucket, s.filesBucket}
 
@@ -75,6 +203,9 @@ func (s *StorageService) CreateUser(ctx context.Context, user *models.User) erro
 	if user.ID == "" {
 		user.ID = uuid.New().String()
 	}
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
@@ -86,18 +217,28 @@ func (s *StorageService) CreateUser(ctx context.Context, user *models.User) erro
 	objectName := fmt.Sprintf("users/%s.json", user.ID)
 	reader := bytes.NewReader(data)
 
+	written := s.prepareEvents(ctx, events.New(events.UserCreated, events.NewUser(user)))
 	info, err := s.client.PutObject(ctx, s.usersBucket, objectName, reader, int64(len(data)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to store user: %w", err)
 	}
 
 	user.ETag = info.ETag
+	if err := s.indexUser(ctx, user); err != nil {
+		return err
+	}
+	s.trackUser(ctx, user)
 	return nil
 }
 
 func (s *StorageService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if user, ok := s.userCache.get(userID); ok {
+		return user, nil
+	}
+
 	objectName := fmt.Sprintf("users/%s.json", userID)
 
 	object, err := s.client.GetObject(ctx, s.usersBucket, objectName, minio.GetObjectOptions{})
@@ -108,6 +249,9 @@ func (s *StorageService) GetUser(ctx context.Context, userID string) (*models.Us
 
 	data, err := io.ReadAll(object)
 	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrUserNotFound
+		}
 		return nil, fmt.Errorf("failed to read user data: %w", err)
 	}
 
@@ -115,80 +259,13 @@ func (s *StorageService) GetUser(ctx context.Context, userID string) (*models.Us
 	if err := json.Unmarshal(data, &user); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
 	}
-
-	return &user, nil
-}
-
-func (s *StorageService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	// List all users and find by email (in production, consider using an index)
-	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
-		Prefix:    "users/",
-		Recursive: true,
-	})
-
-	for object := range objectsCh {
-		if object.Err != nil {
-			continue
-		}
-
-		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
-		if err != nil {
-			continue
-		}
-
-		data, err := io.ReadAll(obj)
-		obj.Close()
-		if err != nil {
-			continue
-		}
-
-		var user models.User
-		if err := json.Unmarshal(data, &user); err != nil {
-			continue
-		}
-
-		if user.Email == email {
-			return &user, nil
-		}
+	// Stat is answered from the response that was just read
+	if info, err := object.Stat(); err == nil {
+		user.ETag = info.ETag
 	}
 
-	return nil, fmt.Errorf("user not found")
-}
-
-func (s *StorageService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	// List all users and find by username (in production, consider using an index)
-	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
-		Prefix:    "users/",
-		Recursive: true,
-	})
-
-	for object := range objectsCh {
-		if object.Err != nil {
-			continue
-		}
-
-		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
-		if err != nil {
-			continue
-		}
-
-		data, err := io.ReadAll(obj)
-		obj.Close()
-		if err != nil {
-			continue
-		}
-
-		var user models.User
-		if err := json.Unmarshal(data, &user); err != nil {
-			continue
-		}
-
-		if user.Username == username {
-			return &user, nil
-		}
-	}
-
-	return nil, fmt.Errorf("user not found")
+	s.userCache.set(userID, &user)
+	return &user, nil
 }
 
 func (s *StorageService) UpdateUser(ctx context.Context, user *models.User) error {
@@ -202,28 +279,69 @@ func (s *StorageService) UpdateUser(ctx context.Context, user *models.User) erro
 	objectName := fmt.Sprintf("users/%s.json", user.ID)
 	reader := bytes.NewReader(data)
 
+	written := s.prepareEvents(ctx, events.New(events.UserUpdated, events.NewUser(user)))
 	info, err := s.client.PutObject(ctx, s.usersBucket, objectName, reader, int64(len(data)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
 	user.ETag = info.ETag
-	return nil
+	s.statusCache.forget(user.ID)
+	s.userCache.forget(user.ID)
+	return s.indexUser(ctx, user)
 }
 
 func (s *StorageService) DeleteUser(ctx context.Context, userID string) error {
 	objectName := fmt.Sprintf("users/%s.json", userID)
 
+	written := s.prepareEvents(ctx, events.New(events.UserDeleted, events.User{ID: userID}))
 	err := s.client.RemoveObject(ctx, s.usersBucket, objectName, minio.RemoveObjectOptions{})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
+	if err := s.client.RemoveObject(ctx, s.usersBucket, loginHistoryObjectName(userID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete login history: %w", err)
+	}
+	if err := s.deleteUserBookmarks(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.deleteUserMentions(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.DeleteAvatar(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.deleteUserSettings(ctx, userID); err != nil {
+		return err
+	}
 
+	s.statusCache.forget(userID)
+	s.userCache.forget(userID)
+	s.untrackStats(ctx, stats.Users, userID)
 	return nil
 }
 
+// CachedUserStatus returns the account status of userID for checking
+// tokens; changes made on other servers can take up to authCacheTTL to
+// show up
+func (s *StorageService) CachedUserStatus(ctx context.Context, userID string) (string, error) {
+	if status, ok := s.statusCache.get(userID); ok {
+		return status, nil
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	s.statusCache.set(userID, user.AccountStatus())
+	return user.AccountStatus(), nil
+}
+
 // Post operations
 func (s *StorageService) CreatePost(ctx context.Context, post *models.Post) error {
 	if post.ID == "" {
@@ -231,6 +349,7 @@ func (s *StorageService) CreatePost(ctx context.Context, post *models.Post) erro
 	}
 	post.CreatedAt = time.Now()
 	post.UpdatedAt = time.Now()
+	published := markPublished(post)
 
 	data, err := json.Marshal(post)
 	if err != nil {
@@ -240,18 +359,27 @@ func (s *StorageService) CreatePost(ctx context.Context, post *models.Post) erro
 	objectName := fmt.Sprintf("posts/%s/%s.json", post.UserID, post.ID)
 	reader := bytes.NewReader(data)
 
+	written := s.prepareEvents(ctx, postEvents(events.PostCreated, post, published)...)
 	info, err := s.client.PutObject(ctx, s.postsBucket, objectName, reader, int64(len(data)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to store post: %w", err)
 	}
 
 	post.ETag = info.ETag
+	s.profileCache.forget(post.UserID)
+	s.trackPost(ctx, post)
+	s.syncPostMentions(ctx, post)
 	return nil
 }
 
 func (s *StorageService) GetPost(ctx context.Context, postID string) (*models.Post, error) {
+	if post, ok := s.postCache.get(postID); ok {
+		return post, nil
+	}
+
 	// Search across all user directories for the post
 	objectsCh := s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{
 		Prefix:    "posts/",
@@ -279,7 +407,11 @@ func (s *StorageService) GetPost(ctx context.Context, postID string) (*models.Po
 			if err := json.Unmarshal(data, &post); err != nil {
 				continue
 			}
+			post.ETag = object.ETag
 
+			if post.ID == postID {
+				s.postCache.set(postID, &post)
+			}
 			return &post, nil
 		}
 	}
@@ -290,6 +422,7 @@ func (s *StorageService) GetPost(ctx context.Context, postID string) (*models.Po
 // Additional Post operations
 func (s *StorageService) UpdatePost(ctx context.Context, post *models.Post) error {
 	post.UpdatedAt = time.Now()
+	published := markPublished(post)
 
 	data, err := json.Marshal(post)
 	if err != nil {
@@ -299,17 +432,34 @@ func (s *StorageService) UpdatePost(ctx context.Context, post *models.Post) erro
 	objectName := fmt.Sprintf("posts/%s/%s.json", post.UserID, post.ID)
 	reader := bytes.NewReader(data)
 
+	written := s.prepareEvents(ctx, postEvents(events.PostUpdated, post, published)...)
 	info, err := s.client.PutObject(ctx, s.postsBucket, objectName, reader, int64(len(data)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to update post: %w", err)
 	}
 
 	post.ETag = info.ETag
+	s.postCache.forget(post.ID)
+	s.profileCache.forget(post.UserID)
+	s.trackPost(ctx, post)
+	s.syncPostMentions(ctx, post)
 	return nil
 }
 
+// markPublished records when post is first published and reports whether
+// that is now
+func markPublished(post *models.Post) bool {
+	if post.Status != models.PostStatusPublished || post.PublishedAt != nil {
+		return false
+	}
+	now := time.Now()
+	post.PublishedAt = &now
+	return true
+}
+
 func (s *StorageService) DeletePost(ctx context.Context, postID string) error {
 	// Find and delete the post
 	objectsCh := s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{
@@ -323,10 +473,35 @@ func (s *StorageService) DeletePost(ctx context.Context, postID string) error {
 		}
 
 		if strings.Contains(object.Key, postID+".json") {
+			// Keys are posts/<author>/<id>.json
+			author := path.Base(path.Dir(object.Key))
+			written := s.prepareEvents(ctx, events.New(events.PostDeleted, events.Post{ID: postID, UserID: author}))
 			err := s.client.RemoveObject(ctx, s.postsBucket, object.Key, minio.RemoveObjectOptions{})
+			written(err)
 			if err != nil {
 				return fmt.Errorf("failed to delete post: %w", err)
 			}
+			s.postCache.forget(postID)
+			s.profileCache.forget(author)
+			s.untrackPost(ctx, postID)
+			if err := s.deletePostComments(ctx, postID); err != nil {
+				s.log(ctx).Warn("Failed to delete comments of deleted post", "postId", postID, "error", err)
+			}
+			if err := s.deletePostBookmarks(ctx, postID); err != nil {
+				s.log(ctx).Warn("Failed to delete bookmarks of deleted post", "postId", postID, "error", err)
+			}
+			if err := s.deleteMentions(ctx, mentionPrefix+postID+"/"); err != nil {
+				s.log(ctx).Warn("Failed to delete mentions of deleted post", "postId", postID, "error", err)
+			}
+			if err := s.leaveSeries(ctx, postID); err != nil {
+				s.log(ctx).Warn("Failed to remove deleted post from its series", "postId", postID, "error", err)
+			}
+			if err := s.RemoveModerationItem(ctx, postID); err != nil {
+				s.log(ctx).Warn("Failed to remove deleted post from the moderation queue", "postId", postID, "error", err)
+			}
+			if err := s.deleteReports(ctx, models.ReportTargetPost, postID); err != nil {
+				s.log(ctx).Warn("Failed to delete reports of deleted post", "postId", postID, "error", err)
+			}
 			return nil
 		}
 	}
@@ -334,51 +509,33 @@ func (s *StorageService) DeletePost(ctx context.Context, postID string) error {
 	return fmt.Errorf("post not found")
 }
 
-func (s *StorageService) ListPosts(ctx context.Context, pagination models.Pagination) ([]*models.Post, int64, error) {
-	var posts []*models.Post
-	var total int64
-
-	objectsCh := s.client.ListObjects(ctx, s.postsBucket, minio.ListObjectsOptions{
-		Prefix:    "posts/",
-		Recursive: true,
-	})
-
-	for object := range objectsCh {
-		if object.Err != nil {
-			continue
-		}
-
-		total++
-
-		// Simple pagination (skip and take)
-		if total <= int64(pagination.Offset) {
-			continue
-		}
-
-		if len(posts) >= pagination.PageSize {
-			continue
-		}
-
-		obj, err := s.client.GetObject(ctx, s.postsBucket, object.Key, minio.GetObjectOptions{})
-		if err != nil {
-			continue
-		}
+// ListPosts returns the page of posts that pagination selects after opts
+// filtered and sorted them, and how many posts matched
+func (s *StorageService) ListPosts(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.Post, int64, error) {
+	if total, userID, ok := s.countedTotal(ctx, stats.Posts, opts); ok {
+		return listCountedPage[models.Post](ctx, s, s.postsBucket, ownerPrefix("posts/", userID), nil, pagination, opts, total)
+	}
+	return listPage[models.Post](ctx, s, s.postsBucket, "posts/", nil, pagination, opts)
+}
 
-		data, err := io.ReadAll(obj)
-		obj.Close()
-		if err != nil {
-			continue
-		}
+// ListPostsPublishedSince returns the published posts first published
+// after since, newest first
+func (s *StorageService) ListPostsPublishedSince(ctx context.Context, since time.Time) ([]*models.Post, error) {
+	posts, _, err := listPage[models.Post](ctx, s, s.postsBucket, "posts/", nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
 
-		var post models.Post
-		if err := json.Unmarshal(data, &post); err != nil {
-			continue
+	published := []*models.Post{}
+	for _, post := range posts {
+		if post.Status == models.PostStatusPublished && post.PublishedAt != nil && post.PublishedAt.After(since) {
+			published = append(published, post)
 		}
-
-		posts = append(posts, &post)
 	}
-
-	return posts, total, nil
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].PublishedAt.After(*published[j].PublishedAt)
+	})
+	return published, nil
 }
 
 // File operations
@@ -388,9 +545,10 @@ func (s *StorageService) StoreFile(ctx context.Context, file *models.File, reade
 	}
 	file.CreatedAt = time.Now()
 	file.UpdatedAt = time.Now()
+	file.Version = 1
 
 	// Store file content
-	contentPath := fmt.Sprintf("files/%s/%s/content", file.UserID, file.ID)
+	contentPath := s.incomingPath(file.UserID, file.ID)
 	info, err := s.client.PutObject(ctx, s.filesBucket, contentPath, reader, file.Size, minio.PutObjectOptions{
 		ContentType: file.ContentType,
 	})
@@ -400,8 +558,14 @@ func (s *StorageService) StoreFile(ctx context.Context, file *models.File, reade
 
 	file.Path = contentPath
 	file.ETag = info.ETag
+	s.markForScan(file)
 
-	// Store file metadata
+	return s.saveFileMetadata(ctx, file, events.FileUploaded)
+}
+
+// saveFileMetadata stores the metadata of file and publishes eventType
+// about it
+func (s *StorageService) saveFileMetadata(ctx context.Context, file *models.File, eventType string) error {
 	metadata, err := json.Marshal(file)
 	if err != nil {
 		return fmt.Errorf("failed to marshal file metadata: %w", err)
@@ -410,21 +574,254 @@ func (s *StorageService) StoreFile(ctx context.Context, file *models.File, reade
 	metadataPath := fmt.Sprintf("files/%s/%s/metadata.json", file.UserID, file.ID)
 	metadataReader := bytes.NewReader(metadata)
 
+	written := s.prepareEvents(ctx, events.New(eventType, events.NewFile(file)))
 	_, err = s.client.PutObject(ctx, s.filesBucket, metadataPath, metadataReader, int64(len(metadata)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
+	written(err)
 	if err != nil {
 		return fmt.Errorf("failed to store file metadata: %w", err)
 	}
 
+	s.fileCache.forget(file.ID)
+	if err := s.indexFileName(ctx, file); err != nil {
+		s.log(ctx).Warn("Failed to index file name", "fileId", file.ID, "error", err)
+	}
+	s.trackFile(ctx, file)
+	return nil
+}
+
+// PresignUpload issues a POST policy that lets the browser upload a single
+// object straight to MinIO. The key is pinned under the user's prefix and the
+// content type and size are enforced by MinIO itself. maxSize is the caller's
+// upload limit.
+func (s *StorageService) PresignUpload(ctx context.Context, userID string, req models.PresignUploadRequest, maxSize int64) (*models.PresignedUpload, error) {
+	if req.Size > maxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	fileID := uuid.New().String()
+	expiresAt := time.Now().UTC().Add(time.Duration(s.upload.PresignExpiry) * time.Minute)
+
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(s.filesBucket); err != nil {
+		return nil, fmt.Errorf("failed to build upload policy: %w", err)
+	}
+	if err := policy.SetKey(s.incomingPath(userID, fileID)); err != nil {
+		return nil, fmt.Errorf("failed to build upload policy: %w", err)
+	}
+	if err := policy.SetExpires(expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to build upload policy: %w", err)
+	}
+	if err := policy.SetContentType(req.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to build upload policy: %w", err)
+	}
+	if err := policy.SetContentLengthRange(1, req.Size); err != nil {
+		return nil, fmt.Errorf("failed to build upload policy: %w", err)
+	}
+
+	url, formData, err := s.client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload policy: %w", err)
+	}
+
+	return &models.PresignedUpload{
+		FileID:    fileID,
+		URL:       url.String(),
+		FormData:  formData,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// FinalizeUpload records metadata for an object uploaded through a presigned
+// policy. Size and ETag are taken from MinIO, not the client; the content type
+// too unless the caller has already detected it.
+func (s *StorageService) FinalizeUpload(ctx context.Context, file *models.File) error {
+	metadataPath := fmt.Sprintf("files/%s/%s/metadata.json", file.UserID, file.ID)
+	if _, err := s.client.StatObject(ctx, s.filesBucket, metadataPath, minio.StatObjectOptions{}); err == nil {
+		return ErrUploadAlreadyExists
+	}
+
+	contentPath := s.incomingPath(file.UserID, file.ID)
+	info, err := s.client.StatObject(ctx, s.filesBucket, contentPath, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ErrUploadNotFound
+		}
+		return fmt.Errorf("failed to stat uploaded content: %w", err)
+	}
+
+	file.Path = contentPath
+	file.Size = info.Size
+	if file.ContentType == "" {
+		file.ContentType = info.ContentType
+	}
+	file.ETag = info.ETag
+	file.Version = 1
+	file.CreatedAt = time.Now()
+	file.UpdatedAt = time.Now()
+	s.markForScan(file)
+
+	return s.saveFileMetadata(ctx, file, events.FileUploaded)
+}
+
+// ReadUploadHead returns up to n leading bytes of a presigned upload so its
+// real content type can be detected before it is finalized.
+func (s *StorageService) ReadUploadHead(ctx context.Context, userID, uploadID string, n int) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(0, int64(n)-1); err != nil {
+		return nil, fmt.Errorf("failed to set range: %w", err)
+	}
+
+	object, err := s.client.GetObject(ctx, s.filesBucket, s.incomingPath(userID, uploadID), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploaded content: %w", err)
+	}
+	defer object.Close()
+
+	head, err := io.ReadAll(io.LimitReader(object, int64(n)))
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrUploadNotFound
+		}
+		// Ranged reads of empty objects fail with InvalidRange
+		if minio.ToErrorResponse(err).Code == "InvalidRange" {
+			return []byte{}, nil
+		}
+		return nil, fmt.Errorf("failed to read uploaded content: %w", err)
+	}
+	return head, nil
+}
+
+// ReadUpload opens the content of a presigned upload
+func (s *StorageService) ReadUpload(ctx context.Context, userID, uploadID string) (io.ReadCloser, error) {
+	return s.GetObjectContent(ctx, s.incomingPath(userID, uploadID))
+}
+
+// ReplaceUpload overwrites the content of a presigned upload, e.g. after
+// stripping metadata from it
+func (s *StorageService) ReplaceUpload(ctx context.Context, userID, uploadID, contentType string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.filesBucket, s.incomingPath(userID, uploadID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace upload: %w", err)
+	}
+	return nil
+}
+
+// DiscardUpload removes a presigned upload that was rejected
+func (s *StorageService) DiscardUpload(ctx context.Context, userID, uploadID string) error {
+	if err := s.client.RemoveObject(ctx, s.filesBucket, s.incomingPath(userID, uploadID), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
 	return nil
 }
 
+// incomingPath is where new content for a file is written: the quarantine
+// prefix while virus scanning is enabled, the final content key otherwise.
+func (s *StorageService) incomingPath(userID, fileID string) string {
+	if s.scanEnabled {
+		return fmt.Sprintf("quarantine/%s/%s/content", userID, fileID)
+	}
+	return fmt.Sprintf("files/%s/%s/content", userID, fileID)
+}
+
+func (s *StorageService) markForScan(file *models.File) {
+	if s.scanEnabled {
+		file.ScanStatus = models.ScanStatusPending
+		file.ScanResult = ""
+	}
+}
+
+// PromoteScannedFile moves clean content out of quarantine to its final key
+func (s *StorageService) PromoteScannedFile(ctx context.Context, file *models.File) error {
+	contentPath := fmt.Sprintf("files/%s/%s/content", file.UserID, file.ID)
+
+	if file.Path != contentPath {
+		info, err := s.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: s.filesBucket, Object: contentPath},
+			minio.CopySrcOptions{Bucket: s.filesBucket, Object: file.Path},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to promote scanned content: %w", err)
+		}
+
+		if err := s.client.RemoveObject(ctx, s.filesBucket, file.Path, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to remove quarantined content: %w", err)
+		}
+
+		file.Path = contentPath
+		file.ETag = info.ETag
+	}
+
+	file.ScanStatus = models.ScanStatusClean
+	file.ScanResult = ""
+	return s.UpdateFile(ctx, file)
+}
+
+// ListFilesByScanStatus returns every file whose scan is in the given state
+func (s *StorageService) ListFilesByScanStatus(ctx context.Context, status string) ([]*models.File, error) {
+	return s.findFiles(ctx, func(file *models.File) bool {
+		return file.ScanStatus == status
+	}), nil
+}
+
+// findFiles scans all file metadata and returns the files matching match.
+// Unreadable metadata objects are skipped.
+func (s *StorageService) findFiles(ctx context.Context, match func(file *models.File) bool) []*models.File {
+	files := []*models.File{}
+
+	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
+		Prefix:    "files/",
+		Recursive: true,
+	})
+
+	for object := range objectsCh {
+		if object.Err != nil {
+			continue
+		}
+
+		if !strings.HasSuffix(object.Key, "/metadata.json") {
+			continue
+		}
+
+		obj, err := s.client.GetObject(ctx, s.filesBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			s.log(ctx).Warn("Skipping unreadable file metadata", "object", object.Key, "error", err)
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			s.log(ctx).Warn("Skipping unreadable file metadata", "object", object.Key, "error", err)
+			continue
+		}
+
+		var file models.File
+		if err := json.Unmarshal(data, &file); err != nil {
+			s.log(ctx).Warn("Skipping unreadable file metadata", "object", object.Key, "error", err)
+			continue
+		}
+
+		if match(&file) {
+			files = append(files, &file)
+		}
+	}
+
+	return files
+}
+
 func (s *StorageService) UploadFile(ctx context.Context, file *models.File, reader io.Reader) error {
 	return s.StoreFile(ctx, file, reader)
 }
 
 func (s *StorageService) GetFile(ctx context.Context, fileID string) (*models.File, error) {
+	if file, ok := s.fileCache.get(fileID); ok {
+		return file, nil
+	}
+
 	// Search for file metadata
 	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
 		Prefix:    "files/",
@@ -453,11 +850,14 @@ func (s *StorageService) GetFile(ctx context.Context, fileID string) (*models.Fi
 				continue
 			}
 
+			if file.ID == fileID {
+				s.fileCache.set(fileID, &file)
+			}
 			return &file, nil
 		}
 	}
 
-	return nil, fmt.Errorf("file not found")
+	return nil, ErrFileNotFound
 }
 
 func (s *StorageService) GetFileContent(ctx context.Context, fileID string) (io.ReadCloser, error) {
@@ -476,97 +876,360 @@ func (s *StorageService) GetFileContent(ctx context.Context, fileID string) (io.
 	return object, nil
 }
 
-func (s *StorageService) DeleteFile(ctx context.Context, fileID string) error {
-	// Find and delete both content and metadata
+func (s *StorageService) UpdateFile(ctx context.Context, file *models.File) error {
+	file.UpdatedAt = time.Now()
+	return s.saveFileMetadata(ctx, file, events.FileUpdated)
+}
+
+// CopyFile duplicates src into dst server-side with CopyObject, so the content
+// is never streamed through the API. dst gets a new ID and the caller's owner.
+func (s *StorageService) CopyFile(ctx context.Context, src, dst *models.File) error {
+	dst.ID = uuid.New().String()
+	dst.CreatedAt = time.Now()
+	dst.UpdatedAt = time.Now()
+	dst.Version = 1
+
+	contentPath := fmt.Sprintf("files/%s/%s/content", dst.UserID, dst.ID)
+	info, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.filesBucket, Object: contentPath},
+		minio.CopySrcOptions{Bucket: s.filesBucket, Object: src.Path},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy file content: %w", err)
+	}
+
+	dst.Path = contentPath
+	dst.ETag = info.ETag
+
+	return s.saveFileMetadata(ctx, dst, events.FileUploaded)
+}
+
+// StoreThumbnail stores a rendered thumbnail next to the file content and
+// returns its object path.
+func (s *StorageService) StoreThumbnail(ctx context.Context, file *models.File, size, contentType string, data []byte) (string, error) {
+	thumbnailPath := fmt.Sprintf("files/%s/%s/thumbnails/%s", file.UserID, file.ID, size)
+
+	_, err := s.client.PutObject(ctx, s.filesBucket, thumbnailPath, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "private, max-age=86400",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	return thumbnailPath, nil
+}
+
+// StorePreview stores a rendered document preview next to the file content
+// and returns its object path.
+func (s *StorageService) StorePreview(ctx context.Context, file *models.File, contentType string, data []byte) (string, error) {
+	previewPath := fmt.Sprintf("files/%s/%s/preview", file.UserID, file.ID)
+
+	_, err := s.client.PutObject(ctx, s.filesBucket, previewPath, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "private, max-age=86400",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store preview: %w", err)
+	}
+
+	return previewPath, nil
+}
+
+// StreamPrefix is where the HLS output for file is stored
+func (s *StorageService) StreamPrefix(file *models.File) string {
+	return fmt.Sprintf("files/%s/%s/hls/", file.UserID, file.ID)
+}
+
+// StoreStreamAsset uploads one playlist or segment below the file's HLS prefix
+func (s *StorageService) StoreStreamAsset(ctx context.Context, file *models.File, name, contentType string, reader io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.filesBucket, s.StreamPrefix(file)+name, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store stream asset %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteStreamAssets removes the HLS output of a previous transcode
+func (s *StorageService) DeleteStreamAssets(ctx context.Context, file *models.File) error {
 	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
-		Prefix:    "files/",
+		Prefix:    s.StreamPrefix(file),
 		Recursive: true,
 	})
 
-	var filesToDelete []string
 	for object := range objectsCh {
 		if object.Err != nil {
-			continue
+			return fmt.Errorf("failed to list stream assets: %w", object.Err)
 		}
+		if err := s.client.RemoveObject(ctx, s.filesBucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete stream asset %s: %w", object.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *StorageService) GetObjectContent(ctx context.Context, objectPath string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.filesBucket, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", objectPath, err)
+	}
+
+	return object, nil
+}
 
-		if strings.Contains(object.Key, fileID+"/") {
-			filesToDelete = append(filesToDelete, object.Key)
+// PresignedDownloadURL returns a time-limited MinIO URL for the file content,
+// letting clients and CDNs fetch it without going through the API. The
+// recorded type is served rather than the one the object was uploaded with,
+// which for direct uploads of encrypted content is the client's claim.
+func (s *StorageService) PresignedDownloadURL(ctx context.Context, file *models.File, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-type", file.ContentType)
+	params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.OriginalName}))
+
+	u, err := s.client.PresignedGetObject(ctx, s.filesBucket, file.Path, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download: %w", err)
+	}
+
+	return u.String(), nil
+}
+
+func (s *StorageService) DeleteFile(ctx context.Context, fileID string) error {
+	// Find and delete content, metadata and anything still in quarantine,
+	// all under <prefix>/<owner>/<id>/
+	var filesToDelete []string
+	var ownerID string
+	for _, prefix := range []string{"files/", "quarantine/"} {
+		objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
+			Prefix:    prefix,
+			Recursive: true,
+		})
+
+		for object := range objectsCh {
+			if object.Err != nil {
+				continue
+			}
+
+			if strings.Contains(object.Key, fileID+"/") {
+				filesToDelete = append(filesToDelete, object.Key)
+				if parts := strings.SplitN(object.Key, "/", 3); len(parts) == 3 {
+					ownerID = parts[1]
+				}
+			}
 		}
 	}
 
+	if len(filesToDelete) == 0 {
+		return fmt.Errorf("file not found")
+	}
+
+	written := s.prepareEvents(ctx, events.New(events.FileDeleted, events.File{ID: fileID, UserID: ownerID}))
 	for _, key := range filesToDelete {
 		err := s.client.RemoveObject(ctx, s.filesBucket, key, minio.RemoveObjectOptions{})
 		if err != nil {
+			written(err)
 			return fmt.Errorf("failed to delete file %s: %w", key, err)
 		}
 	}
+	written(nil)
 
-	if len(filesToDelete) == 0 {
-		return fmt.Errorf("file not found")
+	s.fileCache.forget(fileID)
+	s.untrackStats(ctx, stats.Files, fileID)
+	s.forgetAccess(ctx, fileID)
+	if err := s.deleteReports(ctx, models.ReportTargetFile, fileID); err != nil {
+		s.log(ctx).Warn("Failed to delete reports of deleted file", "fileId", fileID, "error", err)
+	}
+
+	// Share links would otherwise outlive the file they point to
+	shares, err := s.ListShares(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if err := s.DeleteShare(ctx, share.Token); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (s *StorageService) ListFiles(ctx context.Context, pagination models.Pagination) ([]*models.File, int64, error) {
-	var files []*models.File
-	var total int64
+// ListFiles returns the page of files that pagination selects after opts
+// filtered and sorted them, and how many files matched
+func (s *StorageService) ListFiles(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.File, int64, error) {
+	isMetadata := func(key string) bool { return strings.HasSuffix(key, "/metadata.json") }
+	if total, userID, ok := s.countedTotal(ctx, stats.Files, opts); ok {
+		return listCountedPage[models.File](ctx, s, s.filesBucket, ownerPrefix("files/", userID), isMetadata, pagination, opts, total)
+	}
+	return listPage[models.File](ctx, s, s.filesBucket, "files/", isMetadata, pagination, opts)
+}
 
-	objectsCh := s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
-		Prefix:    "files/",
+// HasAdmin reports whether any user is an admin, which a new installation
+// lacks until setup
+func (s *StorageService) HasAdmin(ctx context.Context) (bool, error) {
+	admins, err := s.FindUsers(ctx, func(user *models.User) bool { return user.Role == models.RoleAdmin })
+	if err != nil {
+		return false, err
+	}
+	return len(admins) > 0, nil
+}
+
+// FindUsers scans all users and returns those matching match, ordered by
+// ID. Unreadable user objects are skipped.
+func (s *StorageService) FindUsers(ctx context.Context, match func(user *models.User) bool) ([]*models.User, error) {
+	users := []*models.User{}
+
+	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "users/",
 		Recursive: true,
 	})
 
 	for object := range objectsCh {
 		if object.Err != nil {
-			continue
-		}
-
-		// Only process metadata files
-		if !strings.HasSuffix(object.Key, "/metadata.json") {
-			continue
-		}
-
-		total++
-
-		// Simple pagination (skip and take)
-		if total <= int64(pagination.Offset) {
-			continue
+			return nil, fmt.Errorf("failed to list users: %w", object.Err)
 		}
 
-		if len(files) >= pagination.PageSize {
-			continue
-		}
-
-		obj, err := s.client.GetObject(ctx, s.filesBucket, object.Key, minio.GetObjectOptions{})
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
 		if err != nil {
+			s.log(ctx).Warn("Skipping unreadable user", "object", object.Key, "error", err)
 			continue
 		}
 
 		data, err := io.ReadAll(obj)
 		obj.Close()
 		if err != nil {
+			s.log(ctx).Warn("Skipping unreadable user", "object", object.Key, "error", err)
 			continue
 		}
 
-		var file models.File
-		if err := json.Unmarshal(data, &file); err != nil {
+		var user models.User
+		if err := json.Unmarshal(data, &user); err != nil {
+			s.log(ctx).Warn("Skipping unreadable user", "object", object.Key, "error", err)
 			continue
 		}
-
-		files = append(files, &file)
+		if match(&user) {
+			users = append(users, &user)
+		}
 	}
 
-	return files, total, nil
+	return users, nil
 }
 
 // Helper methods
-func (s *StorageService) ListUsers(ctx context.Context, pagination models.Pagination) ([]*models.User, int64, error) {
-	var users []*models.User
+
+// ListUsers returns the page of users that pagination selects after opts
+// filtered and sorted them, and how many users matched
+func (s *StorageService) ListUsers(ctx context.Context, pagination models.Pagination, opts models.QueryOptions) ([]*models.User, int64, error) {
+	if total, _, ok := s.countedTotal(ctx, stats.Users, opts); ok {
+		return listCountedPage[models.User](ctx, s, s.usersBucket, "users/", nil, pagination, opts, total)
+	}
+	return listPage[models.User](ctx, s, s.usersBucket, "users/", nil, pagination, opts)
+}
+
+// batchReads is how many objects GetUsers reads at once
+const batchReads = 8
+
+// GetUsers reads the users with ids at once, for callers that would
+// otherwise get them one by one. Users that do not exist are left out of
+// the map.
+func (s *StorageService) GetUsers(ctx context.Context, ids []string) (map[string]*models.User, error) {
+	users := make(map[string]*models.User, len(ids))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, batchReads)
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(ids))) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			user, err := s.GetUser(ctx, id)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				users[id] = user
+			case !errors.Is(err, ErrUserNotFound) && firstErr == nil:
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return users, nil
+}
+
+// ListPostsByUsers returns the posts of each of userIDs, filtered and
+// sorted by opts, from a single listing of the posts
+func (s *StorageService) ListPostsByUsers(ctx context.Context, userIDs []string, opts models.QueryOptions) (map[string][]*models.Post, error) {
+	owners := keySegments(userIDs)
+	posts, _, err := listPage[models.Post](ctx, s, s.postsBucket, "posts/", func(key string) bool {
+		return owners[keySegment(key, 1)]
+	}, models.Pagination{PageSize: math.MaxInt}, opts)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string][]*models.Post, len(userIDs))
+	for _, post := range posts {
+		byUser[post.UserID] = append(byUser[post.UserID], post)
+	}
+	return byUser, nil
+}
+
+// ListFilesByUsers returns the files of each of userIDs, filtered and
+// sorted by opts, from a single listing of the files
+func (s *StorageService) ListFilesByUsers(ctx context.Context, userIDs []string, opts models.QueryOptions) (map[string][]*models.File, error) {
+	owners := keySegments(userIDs)
+	files, _, err := listPage[models.File](ctx, s, s.filesBucket, "files/", func(key string) bool {
+		return strings.HasSuffix(key, "/metadata.json") && owners[keySegment(key, 1)]
+	}, models.Pagination{PageSize: math.MaxInt}, opts)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string][]*models.File, len(userIDs))
+	for _, file := range files {
+		byUser[file.UserID] = append(byUser[file.UserID], file)
+	}
+	return byUser, nil
+}
+
+// keySegments makes a set of ids to match object key segments against
+func keySegments(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// keySegment returns the i-th /-separated segment of an object key, or ""
+func keySegment(key string, i int) string {
+	segments := strings.Split(key, "/")
+	if i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+// listPage reads the JSON objects under prefix, those include accepts if
+// it is set, and returns the page that pagination selects with the number
+// of objects that matched opts. Without options only the objects on the
+// page are read; filtering and sorting need all of them. Unreadable
+// objects are skipped.
+func listPage[T any, P interface {
+	*T
+	models.Queryable
+}](ctx context.Context, s *StorageService, bucket, prefix string, include func(key string) bool, pagination models.Pagination, opts models.QueryOptions) ([]P, int64, error) {
+	var items []P
 	var total int64
 
-	objectsCh := s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
-		Prefix:    "users/",
+	objectsCh := s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
 		Recursive: true,
 	})
 
@@ -574,19 +1237,20 @@ func (s *StorageService) ListUsers(ctx context.Context, pagination models.Pagina
 		if object.Err != nil {
 			continue
 		}
-
-		total++
-
-		// Simple pagination (skip and take)
-		if total <= int64(pagination.Offset) {
+		if include != nil && !include(object.Key) {
 			continue
 		}
 
-		if len(users) >= pagination.PageSize {
-			continue
+		if opts.IsZero() {
+			total++
+
+			// Simple pagination (skip and take)
+			if total <= int64(pagination.Offset) || len(items) >= pagination.PageSize {
+				continue
+			}
 		}
 
-		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		obj, err := s.client.GetObject(ctx, bucket, object.Key, minio.GetObjectOptions{})
 		if err != nil {
 			continue
 		}
@@ -597,13 +1261,135 @@ func (s *StorageService) ListUsers(ctx context.Context, pagination models.Pagina
 			continue
 		}
 
-		var user models.User
-		if err := json.Unmarshal(data, &user); err != nil {
+		item := P(new(T))
+		if err := json.Unmarshal(data, item); err != nil {
+			continue
+		}
+		if !opts.IsZero() && !opts.Matches(item) {
+			continue
+		}
+
+		items = append(items, item)
+	}
+	if opts.IsZero() {
+		return items, total, nil
+	}
+
+	slices.SortStableFunc(items, func(a, b P) int {
+		return opts.Compare(a, b)
+	})
+	total = int64(len(items))
+	start := min(pagination.Offset, len(items))
+	end := min(start+pagination.PageSize, len(items))
+	return items[start:end], total, nil
+}
+
+// listCountedPage is listPage for lists whose total the counters know, so
+// the listing stops once the page is full. Objects before the page are
+// still read when opts filters them.
+func listCountedPage[T any, P interface {
+	*T
+	models.Queryable
+}](ctx context.Context, s *StorageService, bucket, prefix string, include func(key string) bool, pagination models.Pagination, opts models.QueryOptions, total int64) ([]P, int64, error) {
+	// Stops the listing when the page is full
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var items []P
+	skipped := 0
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if len(items) >= pagination.PageSize {
+			break
+		}
+		if object.Err != nil {
+			continue
+		}
+		if include != nil && !include(object.Key) {
+			continue
+		}
+		if opts.IsZero() && skipped < pagination.Offset {
+			skipped++
+			continue
+		}
+
+		item := P(new(T))
+		if !s.readObject(ctx, bucket, object.Key, item) || !opts.Matches(item) {
+			continue
+		}
+		if skipped < pagination.Offset {
+			skipped++
 			continue
 		}
+		items = append(items, item)
+	}
+	return items, total, nil
+}
+
+// ownerPrefix narrows prefix, such as posts/, to the objects of userID if
+// it is set
+func ownerPrefix(prefix, userID string) string {
+	if userID == "" {
+		return prefix
+	}
+	return prefix + userID + "/"
+}
+
+// EachUser calls fn with every user opts selects, as they are read unless
+// opts sorts them, and stops at the first error fn returns
+func (s *StorageService) EachUser(ctx context.Context, opts models.QueryOptions, fn func(*models.User) error) error {
+	return eachItem[models.User](ctx, s, s.usersBucket, "users/", nil, opts, fn)
+}
+
+// EachPost calls fn with every post opts selects, like EachUser
+func (s *StorageService) EachPost(ctx context.Context, opts models.QueryOptions, fn func(*models.Post) error) error {
+	return eachItem[models.Post](ctx, s, s.postsBucket, "posts/", nil, opts, fn)
+}
+
+// EachFile calls fn with every file opts selects, like EachUser
+func (s *StorageService) EachFile(ctx context.Context, opts models.QueryOptions, fn func(*models.File) error) error {
+	isMetadata := func(key string) bool { return strings.HasSuffix(key, "/metadata.json") }
+	return eachItem[models.File](ctx, s, s.filesBucket, "files/", isMetadata, opts, fn)
+}
 
-		users = append(users, &user)
+// eachItem hands the JSON objects under prefix that include accepts and
+// opts selects to fn one at a time, so exports of whole buckets need not
+// hold them in memory. Sorting needs every object first.
+func eachItem[T any, P interface {
+	*T
+	models.Queryable
+}](ctx context.Context, s *StorageService, bucket, prefix string, include func(key string) bool, opts models.QueryOptions, fn func(P) error) error {
+	if len(opts.Sort) > 0 {
+		items, _, err := listPage[T, P](ctx, s, bucket, prefix, include, models.Pagination{PageSize: math.MaxInt}, opts)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	return users, total, nil
+	// Stops the listing when fn fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		if include != nil && !include(object.Key) {
+			continue
+		}
+
+		item := P(new(T))
+		if !s.readObject(ctx, bucket, object.Key, item) || !opts.Matches(item) {
+			continue
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
 }
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/miniotest"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage returns a service backed by in-memory MinIO, with the
+// default configuration changed by configure
+func newTestStorage(t *testing.T, configure ...func(cfg *config.Config)) (*StorageService, *miniotest.Server) {
+	t.Helper()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	server := miniotest.New(t)
+	cfg.MinIO = config.MinIOConfig{
+		Endpoint:        server.Endpoint(),
+		AccessKeyID:     miniotest.AccessKey,
+		SecretAccessKey: miniotest.SecretKey,
+		Region:          "us-east-1",
+	}
+	cfg.Database = config.DatabaseConfig{UsersBucket: "users", PostsBucket: "posts", FilesBucket: "files"}
+	for _, f := range configure {
+		f(cfg)
+	}
+
+	storage, err := NewStorageService(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	return storage, server
+}
+
+func TestPresignedDownloadURL(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	file := &models.File{Path: "files/alice/1/content", OriginalName: `report "final"; v2.txt`, ContentType: "text/plain"}
+	presigned, err := s.PresignedDownloadURL(context.Background(), file, time.Minute)
+	require.NoError(t, err)
+
+	u, err := url.Parse(presigned)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", u.Query().Get("response-content-type"))
+	disposition, params, err := mime.ParseMediaType(u.Query().Get("response-content-disposition"))
+	require.NoError(t, err)
+	assert.Equal(t, "attachment", disposition)
+	assert.Equal(t, file.OriginalName, params["filename"])
+}
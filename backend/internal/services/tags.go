@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+)
+
+// tagTrendWindow is the period whose new taggings make a tag's trend,
+// compared with the period before
+const tagTrendWindow = 7 * 24 * time.Hour
+
+// directoryTags returns the tags post shows in the tag directory: those of
+// published posts not shared with an organization, which every reader can
+// see
+func directoryTags(post *models.Post) []string {
+	if post.Status != models.PostStatusPublished || post.OrgID != "" {
+		return nil
+	}
+	return post.Tags
+}
+
+// ListTags returns every tag of the directory with its post count and
+// trend, in no particular order. The counts come from the statistics
+// counters once they are built; before, or without them, every post is
+// read and trends are left out.
+func (s *StorageService) ListTags(ctx context.Context) ([]*models.TagCount, error) {
+	if s.stats != nil {
+		if ready, err := s.stats.Ready(ctx); err == nil && ready {
+			return s.countedTags(ctx)
+		}
+	}
+
+	posts, _, err := listPage[models.Post](ctx, s, s.postsBucket, "posts/", nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, post := range posts {
+		seen := make(map[string]bool)
+		for _, tag := range directoryTags(post) {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				counts[tag]++
+			}
+		}
+	}
+	return tagCounts(counts, nil, nil), nil
+}
+
+func (s *StorageService) countedTags(ctx context.Context) ([]*models.TagCount, error) {
+	counts, err := s.stats.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	recentStart := now.Add(-tagTrendWindow + 24*time.Hour)
+	recent, err := s.stats.TaggedSince(ctx, recentStart, now)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.stats.TaggedSince(ctx, recentStart.Add(-tagTrendWindow), recentStart.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	return tagCounts(counts, recent, previous), nil
+}
+
+func tagCounts(counts, recent, previous map[string]int64) []*models.TagCount {
+	tags := make([]*models.TagCount, 0, len(counts))
+	for tag, posts := range counts {
+		tags = append(tags, &models.TagCount{
+			Tag:    tag,
+			Posts:  posts,
+			Recent: recent[tag],
+			Trend:  recent[tag] - previous[tag],
+		})
+	}
+	return tags
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// Ownership transfers
+//
+// Posts and files are keyed by their author, so giving one to another user
+// moves its objects: posts/<user>/<id>.json, and everything under
+// files/<user>/<id>/ and quarantine/<user>/<id>/ for files, including
+// versions, renditions and the access log. The new objects are written
+// before the old ones are removed, so an interrupted transfer leaves a copy
+// behind rather than losing content. Copies of a file are removed again if
+// it cannot be moved whole, and the old metadata goes first, so the file is
+// never listed under both owners; old objects left behind after that are
+// orphans that DeleteOrphans removes.
+
+// TransferPost gives post to userID and shares it with orgID, or with no
+// organization when it is empty
+func (s *StorageService) TransferPost(ctx context.Context, post *models.Post, userID, orgID string) error {
+	oldUserID := post.UserID
+	oldKey := fmt.Sprintf("posts/%s/%s.json", oldUserID, post.ID)
+	moved := oldUserID != userID
+
+	post.UserID = userID
+	post.OrgID = orgID
+	if err := s.UpdatePost(ctx, post); err != nil {
+		return err
+	}
+
+	if moved {
+		if err := s.client.RemoveObject(ctx, s.postsBucket, oldKey, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to remove transferred post: %w", err)
+		}
+		s.postCache.forget(post.ID)
+		s.profileCache.forget(oldUserID)
+		// Series hold the posts of one author
+		if err := s.leaveSeries(ctx, post.ID); err != nil {
+			s.log(ctx).Warn("Failed to remove transferred post from its series", "postId", post.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// TransferFile gives file to userID and shares it with orgID, or with no
+// organization when it is empty. Share links keep working and are handed
+// to the new owner.
+func (s *StorageService) TransferFile(ctx context.Context, file *models.File, userID, orgID string) error {
+	if file.UserID == userID {
+		file.OrgID = orgID
+		return s.UpdateFile(ctx, file)
+	}
+
+	oldUserID := file.UserID
+	var oldKeys, newKeys []string
+	for _, prefix := range []string{"files/", "quarantine/"} {
+		from := prefix + oldUserID + "/" + file.ID + "/"
+		for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{Prefix: from, Recursive: true}) {
+			if object.Err != nil {
+				s.removeObjects(ctx, newKeys)
+				return fmt.Errorf("failed to list file objects: %w", object.Err)
+			}
+			if strings.HasSuffix(object.Key, "/metadata.json") {
+				// Removed first, so that it is never listed twice
+				oldKeys = append([]string{object.Key}, oldKeys...)
+				continue
+			}
+			oldKeys = append(oldKeys, object.Key)
+
+			key := movedKey(object.Key, oldUserID, userID)
+			_, err := s.client.CopyObject(ctx,
+				minio.CopyDestOptions{Bucket: s.filesBucket, Object: key},
+				minio.CopySrcOptions{Bucket: s.filesBucket, Object: object.Key},
+			)
+			if err != nil {
+				s.removeObjects(ctx, newKeys)
+				return fmt.Errorf("failed to copy file object: %w", err)
+			}
+			newKeys = append(newKeys, key)
+		}
+	}
+
+	moved := *file
+	moved.Path = movedKey(file.Path, oldUserID, userID)
+	moved.Preview = movedKey(file.Preview, oldUserID, userID)
+	moved.Thumbnails = maps.Clone(file.Thumbnails)
+	for size, path := range moved.Thumbnails {
+		moved.Thumbnails[size] = movedKey(path, oldUserID, userID)
+	}
+	moved.Versions = slices.Clone(file.Versions)
+	for i := range moved.Versions {
+		moved.Versions[i].Path = movedKey(moved.Versions[i].Path, oldUserID, userID)
+	}
+	moved.UserID = userID
+	moved.OrgID = orgID
+	if err := s.UpdateFile(ctx, &moved); err != nil {
+		s.removeObjects(ctx, newKeys)
+		return err
+	}
+	*file = moved
+
+	for _, key := range oldKeys {
+		if err := s.client.RemoveObject(ctx, s.filesBucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to remove transferred file object %s: %w", key, err)
+		}
+	}
+	s.fileCache.forget(file.ID)
+
+	shares, err := s.ListShares(ctx, file.ID)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		share.UserID = userID
+		if err := s.UpdateShare(ctx, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeObjects removes the copies made by a transfer that failed. Copies
+// it cannot remove are orphans for DeleteOrphans.
+func (s *StorageService) removeObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.client.RemoveObject(ctx, s.filesBucket, key, minio.RemoveObjectOptions{}); err != nil {
+			s.log(ctx).Warn("Failed to remove copy of a file that was not transferred", "object", key, "error", err)
+		}
+	}
+}
+
+// TransferUserContent gives every post and file of fromUserID to toUserID.
+// Items keep their organization unless orgID is set, which shares all of
+// them with it. It returns how many posts and files were moved before
+// any failure.
+func (s *StorageService) TransferUserContent(ctx context.Context, fromUserID, toUserID, orgID string) (models.TransferResult, error) {
+	var result models.TransferResult
+
+	posts, _, err := s.ListPosts(ctx, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{
+		Filters: []models.Filter{{Field: "userId", Op: models.OpEq, Value: fromUserID}},
+	})
+	if err != nil {
+		return result, err
+	}
+	for _, post := range posts {
+		if err := s.TransferPost(ctx, post, toUserID, transferOrg(post.OrgID, orgID)); err != nil {
+			return result, fmt.Errorf("post %s: %w", post.ID, err)
+		}
+		result.Posts++
+	}
+
+	files, err := s.ListFilesByUsers(ctx, []string{fromUserID}, models.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+	for _, file := range files[fromUserID] {
+		if err := s.TransferFile(ctx, file, toUserID, transferOrg(file.OrgID, orgID)); err != nil {
+			return result, fmt.Errorf("file %s: %w", file.ID, err)
+		}
+		result.Files++
+	}
+	return result, nil
+}
+
+// transferOrg is the organization an item ends up in: the one it is
+// transferred to, or its own
+func transferOrg(current, target string) string {
+	if target != "" {
+		return target
+	}
+	return current
+}
+
+// movedKey rewrites the owner segment of an object key of a file, such as
+// files/<user>/<id>/content, from oldUserID to newUserID
+func movedKey(key, oldUserID, newUserID string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[1] != oldUserID {
+		return key
+	}
+	return parts[0] + "/" + newUserID + "/" + parts[2]
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio/minio-go/v7"
+)
+
+// newMinIOTransport starts from the transport minio-go would use and
+// applies the settings of cfg, counting connections with conns
+func newMinIOTransport(cfg config.MinIOConfig, conns *connCounter) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(cfg.UseSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.DialTimeout) * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = conns.wrap(dialer.DialContext)
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	transport.IdleConnTimeout = time.Duration(cfg.IdleConnTimeout) * time.Second
+	transport.ResponseHeaderTimeout = time.Duration(cfg.ResponseHeaderTimeout) * time.Second
+
+	if cfg.CAFile != "" && transport.TLSClientConfig != nil {
+		data, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MINIO_CA_FILE: %w", err)
+		}
+		roots := transport.TLSClientConfig.RootCAs
+		if roots == nil {
+			if roots, err = x509.SystemCertPool(); err != nil {
+				roots = x509.NewCertPool()
+			}
+		}
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("MINIO_CA_FILE %s holds no PEM certificates", cfg.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = roots
+	}
+	return transport, nil
+}
+
+// traceWriter logs the HTTP traces of the MinIO client, which writes each
+// one in several pieces, as one entry per request
+type traceWriter struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+}
+
+// traceEnd is the line minio-go ends every trace with
+const traceEnd = "---------END-HTTP---------\n"
+
+func (w *traceWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending.Write(p)
+	if bytes.HasSuffix(w.pending.Bytes(), []byte(traceEnd)) {
+		w.logger.Info("MinIO HTTP trace", "trace", w.pending.String())
+		w.pending.Reset()
+	}
+	return len(p), nil
+}
+
+// connCounter counts the connections a transport dials and how many of
+// them are still open, which shows whether listings leave MinIO
+// connections behind
+type connCounter struct {
+	open   atomic.Int64
+	dialed atomic.Int64
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// wrap returns dial with every connection it makes counted
+func (c *connCounter) wrap(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.dialed.Add(1)
+		c.open.Add(1)
+		return &countedConn{Conn: conn, counter: c}, nil
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	counter *connCounter
+	closed  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closed.Do(func() { c.counter.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// MinIOConnections returns how many connections to MinIO are open, busy
+// or idle, and how many were dialed since the start
+func (s *StorageService) MinIOConnections() (open, dialed int64) {
+	return s.conns.open.Load(), s.conns.dialed.Load()
+}
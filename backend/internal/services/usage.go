@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// usageObjectName holds the last usage report, in the users bucket
+const usageObjectName = "system/usage.json"
+
+var ErrNoUsageReport = errors.New("usage report not found")
+
+// AggregateUsage adds up the files, bytes and posts of every user, which
+// scans all buckets, and stores the report for GetUsageReport
+func (s *StorageService) AggregateUsage(ctx context.Context) (*models.UsageReport, error) {
+	usage := make(map[string]*models.UserUsage)
+	userUsage := func(userID string) *models.UserUsage {
+		u, ok := usage[userID]
+		if !ok {
+			u = &models.UserUsage{UserID: userID}
+			usage[userID] = u
+		}
+		return u
+	}
+
+	report := &models.UsageReport{Users: []models.UserUsage{}, GeneratedAt: time.Now().UTC()}
+	for _, file := range s.findFiles(ctx, func(*models.File) bool { return true }) {
+		u := userUsage(file.UserID)
+		u.Files++
+		u.Bytes += file.Size
+		report.TotalFiles++
+		report.TotalBytes += file.Size
+	}
+
+	posts, _, err := listPage[models.Post](ctx, s, s.postsBucket, "posts/", nil, models.Pagination{PageSize: math.MaxInt}, models.QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		userUsage(post.UserID).Posts++
+		report.TotalPosts++
+	}
+
+	users, err := s.FindUsers(ctx, func(user *models.User) bool { return usage[user.ID] != nil })
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		usage[user.ID].Username = user.Username
+	}
+
+	for _, u := range usage {
+		report.Users = append(report.Users, *u)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		if report.Users[i].Bytes != report.Users[j].Bytes {
+			return report.Users[i].Bytes > report.Users[j].Bytes
+		}
+		return report.Users[i].UserID < report.Users[j].UserID
+	})
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal usage report: %w", err)
+	}
+	_, err = s.client.PutObject(ctx, s.usersBucket, usageObjectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store usage report: %w", err)
+	}
+	return report, nil
+}
+
+// GetUsageReport returns the report AggregateUsage stored last
+func (s *StorageService) GetUsageReport(ctx context.Context) (*models.UsageReport, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, usageObjectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage report: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNoUsageReport
+		}
+		return nil, fmt.Errorf("failed to read usage report: %w", err)
+	}
+
+	var report models.UsageReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage report: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+// User index
+//
+// user-index/usernames/<key>.json and user-index/emails/<key>.json in the
+// users bucket name the user with a username or email address, so signing
+// in does not read every user. user-index/indexed marks that users stored
+// before the index existed were added to it. Entries are not removed when
+// users change their username or email address or are deleted, so the user
+// they name is checked.
+const (
+	userIndexPrefix   = "user-index/"
+	userIndexedObject = userIndexPrefix + "indexed"
+)
+
+// userIndexEntry names the user with a username or email address
+type userIndexEntry struct {
+	UserID string `json:"userId"`
+}
+
+// GetUserByEmail finds the user with the email address, ignoring case
+func (s *StorageService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findIndexedUser(ctx, emailObjectName(email), func(user *models.User) bool {
+		return strings.EqualFold(user.Email, email)
+	})
+}
+
+// GetUserByUsername finds the user with the username
+func (s *StorageService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.findIndexedUser(ctx, usernameObjectName(username), func(user *models.User) bool {
+		return user.Username == username
+	})
+}
+
+// GetUserByLogin finds the user an identifier given at sign-in names. An
+// identifier containing '@' is an email address first: registration keeps
+// '@' out of usernames, and provisioned usernames that have one must not
+// capture another user's address.
+func (s *StorageService) GetUserByLogin(ctx context.Context, identifier string) (*models.User, error) {
+	if strings.Contains(identifier, "@") {
+		user, err := s.GetUserByEmail(ctx, identifier)
+		if !errors.Is(err, ErrUserNotFound) {
+			return user, err
+		}
+	}
+	return s.GetUserByUsername(ctx, identifier)
+}
+
+// findIndexedUser returns the user the index entry name points at if match
+// accepts it
+func (s *StorageService) findIndexedUser(ctx context.Context, name string, match func(user *models.User) bool) (*models.User, error) {
+	var entry userIndexEntry
+	err := s.readJSONObject(ctx, name, &entry)
+	switch {
+	case err == nil:
+		user, err := s.GetUser(ctx, entry.UserID)
+		if err == nil && match(user) {
+			return user, nil
+		}
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+	case !errors.Is(err, errObjectNotFound):
+		return nil, fmt.Errorf("failed to read user index: %w", err)
+	}
+
+	_, err = s.client.StatObject(ctx, s.usersBucket, userIndexedObject, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return s.indexUsers(ctx, match)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user index: %w", err)
+	}
+	return nil, ErrUserNotFound
+}
+
+// indexUsers adds every user to the index, once for users stored
+// before it existed, and returns the one match accepts
+func (s *StorageService) indexUsers(ctx context.Context, match func(user *models.User) bool) (*models.User, error) {
+	var found *models.User
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{
+		Prefix:    "users/",
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", object.Err)
+		}
+
+		var user models.User
+		if err := s.readJSONObject(ctx, object.Key, &user); err != nil {
+			continue
+		}
+		if err := s.indexUser(ctx, &user); err != nil {
+			return nil, err
+		}
+		if found == nil && match(&user) {
+			found = &user
+		}
+	}
+
+	if err := s.writeJSONObject(ctx, userIndexedObject, struct{}{}); err != nil {
+		return nil, fmt.Errorf("failed to store user index: %w", err)
+	}
+	if found == nil {
+		return nil, ErrUserNotFound
+	}
+	return found, nil
+}
+
+// indexUser records user under its current username and email address
+func (s *StorageService) indexUser(ctx context.Context, user *models.User) error {
+	entry := &userIndexEntry{UserID: user.ID}
+	if user.Username != "" {
+		if err := s.writeJSONObject(ctx, usernameObjectName(user.Username), entry); err != nil {
+			return fmt.Errorf("failed to store user index: %w", err)
+		}
+	}
+	if user.Email != "" {
+		if err := s.writeJSONObject(ctx, emailObjectName(user.Email), entry); err != nil {
+			return fmt.Errorf("failed to store user index: %w", err)
+		}
+	}
+	return nil
+}
+
+// usernameObjectName hashes the username, which may hold characters keys
+// should not
+func usernameObjectName(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return userIndexPrefix + "usernames/" + hex.EncodeToString(sum[:]) + ".json"
+}
+
+// emailObjectName hashes the email address, ignoring case
+func emailObjectName(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return userIndexPrefix + "emails/" + hex.EncodeToString(sum[:]) + ".json"
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserByLogin(t *testing.T) {
+	s, server := newTestStorage(t)
+	ctx := context.Background()
+
+	// Stored before the index existed
+	legacy := &models.User{ID: "legacy", Username: "carol", Email: "carol@example.com"}
+	require.NoError(t, s.writeJSONObject(ctx, "users/legacy.json", legacy))
+
+	alice := &models.User{Username: "alice", Email: "Alice@Example.com"}
+	require.NoError(t, s.CreateUser(ctx, alice))
+	// A provisioned username may look like another user's address
+	mallory := &models.User{Username: "alice@example.com", Email: "mallory@example.com"}
+	require.NoError(t, s.CreateUser(ctx, mallory))
+	upn := &models.User{Username: "dave@corp.example", Email: "dave@example.com"}
+	require.NoError(t, s.CreateUser(ctx, upn))
+
+	user, err := s.GetUserByLogin(ctx, "carol")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", user.ID)
+	assert.Contains(t, server.Keys("users", userIndexPrefix), userIndexedObject)
+
+	user, err = s.GetUserByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	// An email address wins over a username that looks like it
+	user, err = s.GetUserByLogin(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	user, err = s.GetUserByLogin(ctx, "dave@corp.example")
+	require.NoError(t, err)
+	assert.Equal(t, upn.ID, user.ID)
+
+	// Renamed users are no longer found under their old name
+	alice.Username = "alicia"
+	require.NoError(t, s.UpdateUser(ctx, alice))
+	_, err = s.GetUserByLogin(ctx, "alice")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	user, err = s.GetUserByLogin(ctx, "alicia")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	// Once indexed, lookups no longer read every user
+	require.NoError(t, s.writeJSONObject(ctx, "users/unindexed.json", &models.User{ID: "unindexed", Username: "erin"}))
+	_, err = s.GetUserByLogin(ctx, "erin")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	require.NoError(t, s.DeleteUser(ctx, mallory.ID))
+	_, err = s.GetUserByUsername(ctx, "alice@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrVersionNotFound = errors.New("file version not found")
+
+// File version operations
+//
+// Previous revisions are kept as version-suffixed keys next to the content,
+// files/<user>/<file>/versions/<n>, and described in File.Versions. The
+// current content always lives at File.Path.
+
+// fileNamesPrefix holds the name index: file-names/<user>/<key>.json names
+// the file a user last saved under an organization, folder and name, and
+// file-names/<user>/indexed marks users whose older files were added to it
+const fileNamesPrefix = "file-names/"
+
+// fileName is an entry of the name index
+type fileName struct {
+	FileID string `json:"fileId"`
+}
+
+// FindFileByName returns the user's file with the given name in folder,
+// among those shared with the organization orgID or, when it is empty,
+// those not shared, used to turn re-uploads of the same logical file into
+// new versions.
+//
+// The file is found through the name index. Entries are not removed when
+// files are renamed, moved or deleted, so the file they name is checked.
+func (s *StorageService) FindFileByName(ctx context.Context, userID, orgID, folder, name string) (*models.File, error) {
+	var entry fileName
+	err := s.readBucketJSON(ctx, s.filesBucket, fileNameObjectName(userID, orgID, folder, name), &entry)
+	switch {
+	case err == nil:
+		var file models.File
+		err := s.readBucketJSON(ctx, s.filesBucket, fmt.Sprintf("files/%s/%s/metadata.json", userID, entry.FileID), &file)
+		if err == nil && file.OriginalName == name && file.Folder == folder && file.OrgID == orgID {
+			return &file, nil
+		}
+	case !errors.Is(err, errObjectNotFound):
+		return nil, fmt.Errorf("failed to read file name index: %w", err)
+	}
+
+	_, err = s.client.StatObject(ctx, s.filesBucket, fileNamesPrefix+userID+"/indexed", minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return s.indexFileNames(ctx, userID, orgID, folder, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file name index: %w", err)
+	}
+	return nil, fmt.Errorf("file not found")
+}
+
+// indexFileNames adds every file of the user to the name index, once for
+// files stored before it existed, and returns the one FindFileByName asked
+// for
+func (s *StorageService) indexFileNames(ctx context.Context, userID, orgID, folder, name string) (*models.File, error) {
+	var found *models.File
+	for object := range s.client.ListObjects(ctx, s.filesBucket, minio.ListObjectsOptions{
+		Prefix:    fmt.Sprintf("files/%s/", userID),
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", object.Err)
+		}
+		if !strings.HasSuffix(object.Key, "/metadata.json") {
+			continue
+		}
+
+		var file models.File
+		if err := s.readBucketJSON(ctx, s.filesBucket, object.Key, &file); err != nil {
+			continue
+		}
+		if err := s.indexFileName(ctx, &file); err != nil {
+			return nil, err
+		}
+		if found == nil && file.OriginalName == name && file.Folder == folder && file.OrgID == orgID {
+			found = &file
+		}
+	}
+
+	if err := s.writeBucketJSON(ctx, s.filesBucket, fileNamesPrefix+userID+"/indexed", struct{}{}); err != nil {
+		return nil, fmt.Errorf("failed to store file name index: %w", err)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	return found, nil
+}
+
+// indexFileName records file under its current name
+func (s *StorageService) indexFileName(ctx context.Context, file *models.File) error {
+	if file.OriginalName == "" {
+		return nil
+	}
+	name := fileNameObjectName(file.UserID, file.OrgID, file.Folder, file.OriginalName)
+	if err := s.writeBucketJSON(ctx, s.filesBucket, name, &fileName{FileID: file.ID}); err != nil {
+		return fmt.Errorf("failed to store file name index: %w", err)
+	}
+	return nil
+}
+
+// fileNameObjectName hashes the name, which may be longer than a key allows
+func fileNameObjectName(userID, orgID, folder, name string) string {
+	sum := sha256.Sum256([]byte(orgID + "\x00" + folder + "\x00" + name))
+	return fileNamesPrefix + userID + "/" + hex.EncodeToString(sum[:]) + ".json"
+}
+
+// StoreFileVersion archives the current content of file and replaces it with
+// the content read from reader. encryption describes client-encrypted
+// content and is nil for plaintext.
+func (s *StorageService) StoreFileVersion(ctx context.Context, file *models.File, contentType string, size int64, reader io.Reader, encryption *models.FileEncryption) error {
+	if file.ScanStatus == models.ScanStatusPending {
+		return ErrScanPending
+	}
+
+	if err := s.archiveCurrentVersion(ctx, file); err != nil {
+		return err
+	}
+
+	contentPath := s.incomingPath(file.UserID, file.ID)
+	info, err := s.client.PutObject(ctx, s.filesBucket, contentPath, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store file content: %w", err)
+	}
+	if err := s.pruneVersions(ctx, file); err != nil {
+		return err
+	}
+
+	file.Path = contentPath
+	s.setCurrentContent(file, contentType, info.Size, info.ETag, encryption)
+	s.markForScan(file)
+	return s.saveFileMetadata(ctx, file, events.FileUpdated)
+}
+
+// PromoteUploadToVersion makes the content of a finalized direct upload the
+// new current version of file and removes the temporary upload object. A
+// non-empty contentType overrides the type MinIO recorded for the upload.
+func (s *StorageService) PromoteUploadToVersion(ctx context.Context, file *models.File, uploadID, contentType string, encryption *models.FileEncryption) error {
+	if file.ScanStatus == models.ScanStatusPending {
+		return ErrScanPending
+	}
+
+	uploadPath := s.incomingPath(file.UserID, uploadID)
+	info, err := s.client.StatObject(ctx, s.filesBucket, uploadPath, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ErrUploadNotFound
+		}
+		return fmt.Errorf("failed to stat uploaded content: %w", err)
+	}
+
+	if err := s.archiveCurrentVersion(ctx, file); err != nil {
+		return err
+	}
+
+	contentPath := s.incomingPath(file.UserID, file.ID)
+	copied, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.filesBucket, Object: contentPath},
+		minio.CopySrcOptions{Bucket: s.filesBucket, Object: uploadPath},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to promote uploaded content: %w", err)
+	}
+
+	if err := s.client.RemoveObject(ctx, s.filesBucket, uploadPath, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove temporary upload: %w", err)
+	}
+	if err := s.pruneVersions(ctx, file); err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+
+	file.Path = contentPath
+	s.setCurrentContent(file, contentType, info.Size, copied.ETag, encryption)
+	s.markForScan(file)
+	return s.saveFileMetadata(ctx, file, events.FileUpdated)
+}
+
+// RestoreFileVersion makes an archived version current again. The content
+// being replaced is archived first, so a restore can itself be undone, and
+// versions beyond the limit are pruned only once the restored one has been
+// copied, since it may be the oldest.
+func (s *StorageService) RestoreFileVersion(ctx context.Context, file *models.File, version int) error {
+	if file.ScanStatus == models.ScanStatusPending {
+		return ErrScanPending
+	}
+
+	target := file.FindVersion(version)
+	if target == nil {
+		return ErrVersionNotFound
+	}
+	restored := *target
+
+	if err := s.archiveCurrentVersion(ctx, file); err != nil {
+		return err
+	}
+
+	// Archived versions have already passed scanning, so they go straight
+	// to the final content key
+	contentPath := fmt.Sprintf("files/%s/%s/content", file.UserID, file.ID)
+	info, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.filesBucket, Object: contentPath},
+		minio.CopySrcOptions{Bucket: s.filesBucket, Object: restored.Path},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore file version: %w", err)
+	}
+	if err := s.pruneVersions(ctx, file); err != nil {
+		return err
+	}
+
+	file.Path = contentPath
+	s.setCurrentContent(file, restored.ContentType, restored.Size, info.ETag, restored.Encryption)
+	file.ScanStatus = ""
+	file.ScanResult = ""
+	if s.scanEnabled {
+		file.ScanStatus = models.ScanStatusClean
+	}
+	return s.saveFileMetadata(ctx, file, events.FileUpdated)
+}
+
+// archiveCurrentVersion copies the current content to its version key.
+// Callers prune with pruneVersions once the new content is in place.
+func (s *StorageService) archiveCurrentVersion(ctx context.Context, file *models.File) error {
+	if file.Version == 0 {
+		// Stored before versioning existed
+		file.Version = 1
+	}
+
+	if file.ScanStatus == models.ScanStatusInfected {
+		// Infected content is never kept as a restorable version
+		if err := s.client.RemoveObject(ctx, s.filesBucket, file.Path, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to remove infected content: %w", err)
+		}
+		return nil
+	}
+
+	versionPath := fmt.Sprintf("files/%s/%s/versions/%d", file.UserID, file.ID, file.Version)
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.filesBucket, Object: versionPath},
+		minio.CopySrcOptions{Bucket: s.filesBucket, Object: file.Path},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to archive file version: %w", err)
+	}
+
+	file.Versions = append(file.Versions, models.FileVersion{
+		Version:     file.Version,
+		Path:        versionPath,
+		ContentType: file.ContentType,
+		Size:        file.Size,
+		ETag:        file.ETag,
+		Encryption:  file.Encryption,
+		CreatedAt:   file.UpdatedAt,
+	})
+	return nil
+}
+
+// pruneVersions removes the oldest versions beyond the configured limit
+func (s *StorageService) pruneVersions(ctx context.Context, file *models.File) error {
+	for s.upload.MaxVersions > 0 && len(file.Versions) > s.upload.MaxVersions {
+		oldest := file.Versions[0]
+		if err := s.client.RemoveObject(ctx, s.filesBucket, oldest.Path, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to prune file version %d: %w", oldest.Version, err)
+		}
+		file.Versions = file.Versions[1:]
+	}
+
+	return nil
+}
+
+func (s *StorageService) setCurrentContent(file *models.File, contentType string, size int64, etag string, encryption *models.FileEncryption) {
+	file.Version++
+	file.ContentType = contentType
+	file.Encryption = encryption
+	file.Size = size
+	file.ETag = etag
+	file.UpdatedAt = time.Now()
+	// Thumbnails and previews were rendered from the previous content
+	file.Thumbnails = nil
+	file.Preview = ""
+	file.Stream = nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeVersions stores a file whose versions have the given contents, the
+// last one current
+func storeVersions(t *testing.T, s *StorageService, contents ...string) *models.File {
+	t.Helper()
+	ctx := context.Background()
+	file := &models.File{UserID: "u1", OriginalName: "notes.txt", ContentType: "text/plain", Size: int64(len(contents[0]))}
+	require.NoError(t, s.StoreFile(ctx, file, strings.NewReader(contents[0])))
+	for _, content := range contents[1:] {
+		require.NoError(t, s.StoreFileVersion(ctx, file, "text/plain", int64(len(content)), strings.NewReader(content), nil))
+	}
+	return file
+}
+
+func currentContent(t *testing.T, s *StorageService, file *models.File) string {
+	t.Helper()
+	object, err := s.client.GetObject(context.Background(), s.filesBucket, file.Path, minio.GetObjectOptions{})
+	require.NoError(t, err)
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func versionNumbers(file *models.File) []int {
+	var numbers []int
+	for _, version := range file.Versions {
+		numbers = append(numbers, version.Version)
+	}
+	return numbers
+}
+
+func TestRestoreOldestVersionAtLimit(t *testing.T) {
+	s, _ := newTestStorage(t, func(cfg *config.Config) { cfg.Upload.MaxVersions = 2 })
+	file := storeVersions(t, s, "one", "two", "three")
+	require.Equal(t, []int{1, 2}, versionNumbers(file))
+
+	// Archiving "three" goes beyond the limit, and the version pruned for
+	// it is the one being restored
+	require.NoError(t, s.RestoreFileVersion(context.Background(), file, 1))
+	assert.Equal(t, "one", currentContent(t, s, file))
+	assert.Equal(t, 4, file.Version)
+	assert.Equal(t, []int{2, 3}, versionNumbers(file))
+}
+
+func TestStoreVersionPrunesOldest(t *testing.T) {
+	s, server := newTestStorage(t, func(cfg *config.Config) { cfg.Upload.MaxVersions = 2 })
+	file := storeVersions(t, s, "one", "two", "three", "four")
+
+	assert.Equal(t, "four", currentContent(t, s, file))
+	assert.Equal(t, []int{2, 3}, versionNumbers(file))
+	assert.Equal(t, []string{
+		"files/u1/" + file.ID + "/versions/2",
+		"files/u1/" + file.ID + "/versions/3",
+	}, server.Keys("files", "files/u1/"+file.ID+"/versions/"))
+}
+
+func TestFindFileByName(t *testing.T) {
+	s, server := newTestStorage(t)
+	ctx := context.Background()
+	file := storeVersions(t, s, "one")
+
+	found, err := s.FindFileByName(ctx, "u1", "", file.Folder, "notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, file.ID, found.ID)
+	_, err = s.FindFileByName(ctx, "u2", "", file.Folder, "notes.txt")
+	assert.Error(t, err)
+
+	// The index entry of the old name is left behind by a rename
+	file.OriginalName = "renamed.txt"
+	require.NoError(t, s.UpdateFile(ctx, file))
+	_, err = s.FindFileByName(ctx, "u1", "", file.Folder, "notes.txt")
+	assert.Error(t, err)
+	found, err = s.FindFileByName(ctx, "u1", "", file.Folder, "renamed.txt")
+	require.NoError(t, err)
+	assert.Equal(t, file.ID, found.ID)
+
+	// Files stored before the index existed are added to it
+	for _, key := range server.Keys("files", fileNamesPrefix) {
+		require.NoError(t, s.client.RemoveObject(ctx, s.filesBucket, key, minio.RemoveObjectOptions{}))
+	}
+	found, err = s.FindFileByName(ctx, "u1", "", file.Folder, "renamed.txt")
+	require.NoError(t, err)
+	assert.Equal(t, file.ID, found.ID)
+	assert.Contains(t, server.Keys("files", fileNamesPrefix), fileNamesPrefix+"u1/indexed")
+}
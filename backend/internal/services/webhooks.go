@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+const (
+	webhookPrefix  = "webhooks/"
+	deliveryPrefix = "webhook-deliveries/"
+)
+
+// Webhook operations
+//
+// Webhooks live under webhooks/<id>.json in the users bucket, and the log
+// of their deliveries under webhook-deliveries/<webhook id>/<delivery
+// id>.json. Delivery IDs start with the time counted down from the end of
+// time, so the newest deliveries are listed first.
+func (s *StorageService) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	webhook.ID = uuid.New().String()
+	webhook.CreatedAt = time.Now()
+	return s.SaveWebhook(ctx, webhook)
+}
+
+func (s *StorageService) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, webhookObjectName(id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to read webhook data: %w", err)
+	}
+
+	var webhook models.Webhook
+	if err := json.Unmarshal(data, &webhook); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns all webhooks, oldest first
+func (s *StorageService) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks := []*models.Webhook{}
+
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: webhookPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list webhooks: %w", object.Err)
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var webhook models.Webhook
+		if err := json.Unmarshal(data, &webhook); err != nil {
+			continue
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	slices.SortFunc(webhooks, func(a, b *models.Webhook) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return webhooks, nil
+}
+
+// CachedWebhooks returns the enabled webhooks, which every domain event is
+// matched against, from a short-lived cache
+func (s *StorageService) CachedWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	if webhooks, ok := s.webhookCache.get(""); ok {
+		return webhooks, nil
+	}
+
+	all, err := s.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := slices.DeleteFunc(all, func(webhook *models.Webhook) bool { return webhook.Disabled })
+	s.webhookCache.set("", webhooks)
+	return webhooks, nil
+}
+
+func (s *StorageService) SaveWebhook(ctx context.Context, webhook *models.Webhook) error {
+	webhook.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, webhookObjectName(webhook.ID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store webhook: %w", err)
+	}
+
+	s.webhookCache.forget("")
+	return nil
+}
+
+// DeleteWebhook deletes a webhook and the log of its deliveries. Pending
+// deliveries are dropped.
+func (s *StorageService) DeleteWebhook(ctx context.Context, id string) error {
+	if err := s.client.RemoveObject(ctx, s.usersBucket, webhookObjectName(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	s.webhookCache.forget("")
+
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: deliveryPrefix + id + "/", Recursive: true}) {
+			if object.Err == nil {
+				objectsCh <- object
+			}
+		}
+	}()
+	for result := range s.client.RemoveObjects(ctx, s.usersBucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil {
+			s.log(ctx).Warn("Failed to delete webhook delivery", "key", result.ObjectName, "error", result.Err)
+		}
+	}
+	return nil
+}
+
+// NewDeliveryID returns the ID of a delivery created at created. Equal
+// seeds give equal IDs, so a delivery recorded twice is stored once.
+func NewDeliveryID(created time.Time, seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return fmt.Sprintf("%019d-%s", math.MaxInt64-created.UnixNano(), hex.EncodeToString(sum[:6]))
+}
+
+func (s *StorageService) GetWebhookDelivery(ctx context.Context, webhookID, id string) (*models.WebhookDelivery, error) {
+	object, err := s.client.GetObject(ctx, s.usersBucket, deliveryObjectName(webhookID, id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery object: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("failed to read webhook delivery data: %w", err)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+func (s *StorageService) SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.usersBucket, deliveryObjectName(delivery.WebhookID, delivery.ID), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// QueueWebhookDelivery logs delivery as pending and queues it for sending.
+// Logging and queueing the same delivery again sends it once.
+func (s *StorageService) QueueWebhookDelivery(ctx context.Context, messagingClient *messaging.Client, delivery *models.WebhookDelivery) error {
+	if _, err := s.GetWebhookDelivery(ctx, delivery.WebhookID, delivery.ID); errors.Is(err, ErrDeliveryNotFound) {
+		if err := s.SaveWebhookDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	job := messaging.WebhookJob{WebhookID: delivery.WebhookID, DeliveryID: delivery.ID}
+	return messagingClient.PublishStream(ctx, messaging.SubjectWebhookDeliveries, delivery.ID, job)
+}
+
+// ListWebhookDeliveries returns a page of the deliveries of a webhook in
+// status, or in any status when it is empty, newest first, and the number
+// of matching deliveries
+func (s *StorageService) ListWebhookDeliveries(ctx context.Context, webhookID, status string, pagination models.Pagination) ([]*models.WebhookDelivery, int64, error) {
+	deliveries := []*models.WebhookDelivery{}
+	var total int64
+
+	for object := range s.client.ListObjects(ctx, s.usersBucket, minio.ListObjectsOptions{Prefix: deliveryPrefix + webhookID + "/", Recursive: true}) {
+		if object.Err != nil {
+			return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", object.Err)
+		}
+
+		// Without a filter only the deliveries on the page are read
+		if status == "" {
+			total++
+			if total <= int64(pagination.Offset) || len(deliveries) >= pagination.PageSize {
+				continue
+			}
+		}
+
+		obj, err := s.client.GetObject(ctx, s.usersBucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			continue
+		}
+
+		var delivery models.WebhookDelivery
+		if err := json.Unmarshal(data, &delivery); err != nil {
+			continue
+		}
+		if status != "" {
+			if delivery.Status != status {
+				continue
+			}
+			total++
+			if total <= int64(pagination.Offset) || len(deliveries) >= pagination.PageSize {
+				continue
+			}
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, total, nil
+}
+
+func webhookObjectName(id string) string {
+	return webhookPrefix + id + ".json"
+}
+
+func deliveryObjectName(webhookID, id string) string {
+	return deliveryPrefix + webhookID + "/" + id + ".json"
+}
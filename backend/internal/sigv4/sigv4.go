@@ -0,0 +1,364 @@
+// Package sigv4 checks requests signed with AWS Signature Version 4, as S3
+// clients send them: signed headers, presigned URLs and payloads streamed
+// in signed or unsigned chunks.
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm      = "AWS4-HMAC-SHA256"
+	timeFormat     = "20060102T150405Z"
+	dateFormat     = "20060102"
+	scopeTerminal  = "aws4_request"
+	maxSkew        = 15 * time.Minute
+	maxPresignTime = 7 * 24 * time.Hour
+	maxChunkSize   = 16 << 20
+
+	// Payload hashes that are not the hash of the payload
+	UnsignedPayload        = "UNSIGNED-PAYLOAD"
+	StreamingPayload       = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingUnsignedTrail = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+var (
+	ErrMissing           = errors.New("request is not signed")
+	ErrMalformed         = errors.New("malformed signature")
+	ErrExpired           = errors.New("request time is too far from the server time or the URL expired")
+	ErrSignatureMismatch = errors.New("signature does not match")
+	ErrPayloadMismatch   = errors.New("payload does not match its signed hash")
+	ErrUnsupported       = errors.New("unsupported signature")
+)
+
+// Signature is the signature of a request
+type Signature struct {
+	AccessKey     string
+	Time          time.Time
+	Region        string
+	Service       string
+	SignedHeaders []string
+	Signature     string
+	PayloadHash   string
+	Presigned     bool
+	date          string        // of the credential scope
+	expires       time.Duration // of presigned URLs
+}
+
+// Parse reads the signature of r from its Authorization header or, for
+// presigned URLs, its query
+func Parse(r *http.Request) (*Signature, error) {
+	query := r.URL.Query()
+	if query.Has("X-Amz-Algorithm") {
+		return parsePresigned(query)
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrMissing
+	}
+	rest, ok := strings.CutPrefix(header, algorithm+" ")
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(rest, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[name] = value
+	}
+
+	sig := &Signature{
+		SignedHeaders: strings.Split(fields["SignedHeaders"], ";"),
+		Signature:     fields["Signature"],
+		PayloadHash:   r.Header.Get("X-Amz-Content-Sha256"),
+	}
+	if err := sig.parseCredential(fields["Credential"]); err != nil {
+		return nil, err
+	}
+	var err error
+	if date := r.Header.Get("X-Amz-Date"); date != "" {
+		sig.Time, err = time.Parse(timeFormat, date)
+	} else {
+		sig.Time, err = http.ParseTime(r.Header.Get("Date"))
+	}
+	if err != nil || sig.Signature == "" || sig.PayloadHash == "" {
+		return nil, ErrMalformed
+	}
+	return sig, nil
+}
+
+func parsePresigned(query url.Values) (*Signature, error) {
+	if query.Get("X-Amz-Algorithm") != algorithm {
+		return nil, ErrUnsupported
+	}
+	sig := &Signature{
+		SignedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		Signature:     query.Get("X-Amz-Signature"),
+		PayloadHash:   UnsignedPayload,
+		Presigned:     true,
+	}
+	if hash := query.Get("X-Amz-Content-Sha256"); hash != "" {
+		sig.PayloadHash = hash
+	}
+	if err := sig.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	var err error
+	if sig.Time, err = time.Parse(timeFormat, query.Get("X-Amz-Date")); err != nil {
+		return nil, ErrMalformed
+	}
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxPresignTime || sig.Signature == "" {
+		return nil, ErrMalformed
+	}
+	sig.expires = time.Duration(seconds) * time.Second
+	return sig, nil
+}
+
+// parseCredential reads access key/date/region/service/aws4_request
+func (s *Signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" || parts[4] != scopeTerminal {
+		return ErrMalformed
+	}
+	s.AccessKey, s.date, s.Region, s.Service = parts[0], parts[1], parts[2], parts[3]
+	return nil
+}
+
+// scope is the credential scope the signature was made for
+func (s *Signature) scope() string {
+	return s.Time.UTC().Format(dateFormat) + "/" + s.Region + "/" + s.Service + "/" + scopeTerminal
+}
+
+// Verify checks that the request was signed with secretKey at a time close
+// to now. The payload is checked while it is read through Body.
+func (s *Signature) Verify(r *http.Request, secretKey string, now time.Time) error {
+	if s.Presigned {
+		if now.Before(s.Time.Add(-maxSkew)) || now.After(s.Time.Add(s.expires)) {
+			return ErrExpired
+		}
+	} else if now.Sub(s.Time).Abs() > maxSkew {
+		return ErrExpired
+	}
+	if s.date != s.Time.UTC().Format(dateFormat) || !slices.Contains(s.SignedHeaders, "host") {
+		return ErrMalformed
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		encode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, s.SignedHeaders),
+		strings.Join(s.SignedHeaders, ";"),
+		s.PayloadHash,
+	}, "\n")
+	stringToSign := algorithm + "\n" + s.Time.UTC().Format(timeFormat) + "\n" + s.scope() + "\n" + hexHash([]byte(canonical))
+
+	expected := hex.EncodeToString(hmacSum(s.signingKey(secretKey), stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(s.Signature)) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// Body returns the payload of a request whose signature was verified. The
+// reader fails with ErrPayloadMismatch or ErrSignatureMismatch when the
+// payload does not match what was signed; only content read to the end
+// has been checked.
+func (s *Signature) Body(r *http.Request, secretKey string) (io.Reader, error) {
+	switch s.PayloadHash {
+	case UnsignedPayload:
+		return r.Body, nil
+	case StreamingPayload:
+		key := s.signingKey(secretKey)
+		prefix := "AWS4-HMAC-SHA256-PAYLOAD\n" + s.Time.UTC().Format(timeFormat) + "\n" + s.scope() + "\n"
+		return &chunkReader{
+			r:    bufio.NewReader(r.Body),
+			prev: s.Signature,
+			sign: func(prev string, data []byte) string {
+				return hex.EncodeToString(hmacSum(key, prefix+prev+"\n"+hexHash(nil)+"\n"+hexHash(data)))
+			},
+		}, nil
+	case StreamingUnsignedTrail:
+		return &chunkReader{r: bufio.NewReader(r.Body)}, nil
+	}
+
+	want, err := hex.DecodeString(s.PayloadHash)
+	if err != nil || len(want) != sha256.Size {
+		return nil, ErrUnsupported
+	}
+	return &hashReader{r: r.Body, hash: sha256.New(), want: want}, nil
+}
+
+// DecodedLength returns the length of the payload of a request streamed in
+// chunks, or its content length otherwise; -1 when it is not known
+func DecodedLength(r *http.Request) int64 {
+	if length := r.Header.Get("X-Amz-Decoded-Content-Length"); length != "" {
+		n, err := strconv.ParseInt(length, 10, 64)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	return r.ContentLength
+}
+
+func (s *Signature) signingKey(secretKey string) []byte {
+	key := hmacSum([]byte("AWS4"+secretKey), s.Time.UTC().Format(dateFormat))
+	key = hmacSum(key, s.Region)
+	key = hmacSum(key, s.Service)
+	return hmacSum(key, scopeTerminal)
+}
+
+func hmacSum(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encode percent-encodes everything but the unreserved characters, and
+// slashes unless encodeSlash is set
+func encode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, encode(name, true)+"="+encode(value, true))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values := r.Header.Values(name)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name + ":" + value + "\n")
+	}
+	return b.String()
+}
+
+// hashReader checks the payload against its signed hash at the end
+type hashReader struct {
+	r    io.Reader
+	hash hash.Hash
+	want []byte
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.want) {
+		return n, ErrPayloadMismatch
+	}
+	return n, err
+}
+
+// chunkReader decodes a payload sent in aws-chunked encoding, checking the
+// signature of each chunk when sign is set. Trailers are ignored.
+type chunkReader struct {
+	r     *bufio.Reader
+	sign  func(prev string, data []byte) string
+	prev  string
+	chunk []byte
+	done  bool
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// next reads the next chunk: its size in hex with extensions, the data
+// and a line break
+func (c *chunkReader) next() error {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return ErrMalformed
+	}
+	header, extensions, _ := strings.Cut(strings.TrimSpace(string(line)), ";")
+	size, err := strconv.ParseInt(header, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrMalformed
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return ErrMalformed
+	}
+
+	if c.sign != nil {
+		signature, ok := strings.CutPrefix(extensions, "chunk-signature=")
+		if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(c.prev, data))) {
+			return ErrSignatureMismatch
+		}
+		c.prev = signature
+	}
+	if size == 0 {
+		c.done = true
+		return nil
+	}
+	crlf := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, crlf); err != nil || string(crlf) != "\r\n" {
+		return ErrMalformed
+	}
+	c.chunk = data
+	return nil
+}
@@ -0,0 +1,120 @@
+package spam
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// heuristicThreshold is the score from which content is spam
+const heuristicThreshold = 3
+
+var (
+	linkPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)\S+`)
+	wordPattern = regexp.MustCompile(`[\p{L}\p{N}']+`)
+
+	// spamPhrases are common in spam and rare elsewhere
+	spamPhrases = []string{
+		"buy now", "click here", "free money", "limited time offer", "act now",
+		"earn money fast", "work from home", "100% free", "risk free", "cheap pills",
+		"online casino", "crypto giveaway", "guaranteed income", "double your",
+	}
+)
+
+// Heuristic scores content by signs of spam: many links, links without
+// text, known spam phrases, shouting, repeated words and long runs of one
+// character. It needs no service and misses subtle spam.
+type Heuristic struct{}
+
+func NewHeuristic() *Heuristic {
+	return &Heuristic{}
+}
+
+func (Heuristic) Check(_ context.Context, content Content) (bool, error) {
+	return score(content.Text) >= heuristicThreshold, nil
+}
+
+func score(text string) int {
+	lower := strings.ToLower(text)
+	links := len(linkPattern.FindAllString(text, -1))
+	words := wordPattern.FindAllString(linkPattern.ReplaceAllString(lower, " "), -1)
+
+	points := 0
+	switch {
+	case links > 0 && len(words) < 3:
+		points += 2 // nothing but links
+	case links >= 3 && links*20 > len(words):
+		points += 2 // more than a link every 20 words
+	}
+
+	phrases := 0
+	for _, phrase := range spamPhrases {
+		if strings.Contains(lower, phrase) {
+			phrases++
+		}
+	}
+	points += min(phrases, 2)
+
+	if shouting(text) {
+		points++
+	}
+	if repetitive(words) {
+		points++
+	}
+	if longRun(text, 10) {
+		points++
+	}
+	return points
+}
+
+// shouting reports whether most of at least 20 letters are upper case
+func shouting(text string) bool {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= 20 && upper*10 > letters*7
+}
+
+// repetitive reports whether one word of three or more letters makes up
+// over a third of at least ten words
+func repetitive(words []string) bool {
+	if len(words) < 10 {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, word := range words {
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		counts[word]++
+		if counts[word]*3 > len(words) {
+			return true
+		}
+	}
+	return false
+}
+
+// longRun reports whether a letter, ! or ? repeats n times in a row, as in
+// "!!!!!!!!!!"; markdown rules such as "----------" do not count
+func longRun(text string, n int) bool {
+	var last rune
+	run := 0
+	for _, r := range text {
+		if r == last && (unicode.IsLetter(r) || r == '!' || r == '?') {
+			run++
+			if run >= n {
+				return true
+			}
+			continue
+		}
+		last, run = r, 1
+	}
+	return false
+}
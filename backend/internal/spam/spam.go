@@ -0,0 +1,126 @@
+// Package spam tells whether a post or comment is spam, with built-in
+// heuristics or an Akismet-style HTTP service.
+package spam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of checked content
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// Sender is the client that sent content, as far as it is known
+type Sender struct {
+	IP        string
+	UserAgent string
+}
+
+// Content is a post or comment to check
+type Content struct {
+	Sender
+	Kind   string // post or comment
+	Author string // username of the author
+	Text   string
+}
+
+// Checker decides whether content is spam
+type Checker interface {
+	Check(ctx context.Context, content Content) (bool, error)
+}
+
+// New returns the checker named by kind: heuristic, http or off. The http
+// checker posts to checkURL with key, naming site as the blog; off returns
+// nil.
+func New(kind, checkURL, key, site string) (Checker, error) {
+	switch strings.ToLower(kind) {
+	case "", "off":
+		return nil, nil
+	case "heuristic":
+		return NewHeuristic(), nil
+	case "http":
+		return NewHTTPChecker(checkURL, key, site)
+	}
+	return nil, fmt.Errorf("unknown spam checker %q", kind)
+}
+
+// commentTypes are the Akismet comment_type values of the kinds of content
+var commentTypes = map[string]string{
+	KindPost:    "blog-post",
+	KindComment: "comment",
+}
+
+// HTTPChecker asks a service speaking the Akismet comment-check protocol:
+// a form post answered with "true" for spam and "false" otherwise
+type HTTPChecker struct {
+	url    string
+	key    string
+	site   string
+	client *http.Client
+}
+
+func NewHTTPChecker(checkURL, key, site string) (*HTTPChecker, error) {
+	if checkURL == "" {
+		return nil, errors.New("spam check URL is required")
+	}
+	return &HTTPChecker{
+		url:    checkURL,
+		key:    key,
+		site:   site,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (h *HTTPChecker) Check(ctx context.Context, content Content) (bool, error) {
+	form := url.Values{
+		"blog":            {h.site},
+		"user_ip":         {content.IP},
+		"user_agent":      {content.UserAgent},
+		"comment_type":    {commentTypes[content.Kind]},
+		"comment_author":  {content.Author},
+		"comment_content": {content.Text},
+	}
+	if h.key != "" {
+		form.Set("api_key", h.key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to reach spam checker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("spam checker returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return false, fmt.Errorf("failed to read spam checker response: %w", err)
+	}
+	switch strings.TrimSpace(string(body)) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	// Akismet explains invalid requests in a header
+	if help := resp.Header.Get("X-akismet-debug-help"); help != "" {
+		return false, fmt.Errorf("spam checker rejected the request: %s", help)
+	}
+	return false, fmt.Errorf("unexpected spam checker response %q", body)
+}
@@ -0,0 +1,86 @@
+package spam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeuristic(t *testing.T) {
+	h := NewHeuristic()
+	cases := map[string]bool{
+		"Thanks for the write-up, the part about lifecycle rules saved me a day.":    false,
+		"Notes\n----------\nSee https://min.io/docs and https://go.dev for details.": false,
+		"https://min.io/docs": false,
+		"https://spam.example/a https://spam.example/b buy now":                                     true,
+		"BUY NOW!!!!!!!!!! CLICK HERE FOR FREE MONEY":                                               true,
+		"Online casino bonus, click here: http://a.example http://b.example http://c.example":       true,
+		"great great great great great great post post post post, click here to buy now" + " great": true,
+	}
+	for text, want := range cases {
+		got, err := h.Check(context.Background(), Content{Kind: KindComment, Text: text})
+		require.NoError(t, err)
+		assert.Equal(t, want, got, text)
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "key-1", r.Form.Get("api_key"))
+		assert.Equal(t, "https://blog.example", r.Form.Get("blog"))
+		assert.Equal(t, "203.0.113.7", r.Form.Get("user_ip"))
+		assert.Equal(t, "blog-post", r.Form.Get("comment_type"))
+		assert.Equal(t, "alice", r.Form.Get("comment_author"))
+
+		switch {
+		case strings.Contains(r.Form.Get("comment_content"), "viagra"):
+			w.Write([]byte("true"))
+		case r.Form.Get("comment_content") == "":
+			w.Header().Set("X-akismet-debug-help", "Empty content")
+			w.Write([]byte("invalid"))
+		default:
+			w.Write([]byte("false"))
+		}
+	}))
+	defer server.Close()
+
+	checker, err := New("http", server.URL, "key-1", "https://blog.example")
+	require.NoError(t, err)
+	content := Content{Sender: Sender{IP: "203.0.113.7"}, Kind: KindPost, Author: "alice"}
+	ctx := context.Background()
+
+	content.Text = "cheap viagra"
+	spam, err := checker.Check(ctx, content)
+	require.NoError(t, err)
+	assert.True(t, spam)
+
+	content.Text = "A post about buckets"
+	spam, err = checker.Check(ctx, content)
+	require.NoError(t, err)
+	assert.False(t, spam)
+
+	content.Text = ""
+	_, err = checker.Check(ctx, content)
+	assert.ErrorContains(t, err, "Empty content")
+}
+
+func TestNew(t *testing.T) {
+	checker, err := New("off", "", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, checker)
+
+	checker, err = New("Heuristic", "", "", "")
+	assert.NoError(t, err)
+	assert.IsType(t, &Heuristic{}, checker)
+
+	_, err = New("http", "", "", "")
+	assert.Error(t, err)
+	_, err = New("bayes", "", "", "")
+	assert.Error(t, err)
+}
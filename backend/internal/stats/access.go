@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// accessKeyPrefix starts the keys counting the downloads of each file: a
+// hash of totals and a set of the users who downloaded it. Unlike items,
+// downloads are not told apart, so one reported twice counts twice, as it
+// is then also logged twice.
+const accessKeyPrefix = keyPrefix + "access:"
+
+// trackAccessScript counts a download of ARGV[1] bytes via ARGV[2] by user
+// ARGV[3], empty for anonymous downloads, at ARGV[4] milliseconds. The
+// entry, ARGV[5], is kept when it is the latest.
+var trackAccessScript = redis.NewScript(`
+redis.call("HINCRBY", KEYS[1], "downloads", 1)
+redis.call("HINCRBY", KEYS[1], "bytes", ARGV[1])
+redis.call("HINCRBY", KEYS[1], "via:" .. ARGV[2], 1)
+if ARGV[3] == "" then
+	redis.call("HINCRBY", KEYS[1], "anonymous", 1)
+else
+	redis.call("SADD", KEYS[2], ARGV[3])
+end
+local lastAt = redis.call("HGET", KEYS[1], "lastAt")
+if not lastAt or tonumber(ARGV[4]) > tonumber(lastAt) then
+	redis.call("HSET", KEYS[1], "lastAt", ARGV[4], "last", ARGV[5])
+end
+return 1
+`)
+
+// TrackAccess counts a download recorded in the access log
+func (c *Counter) TrackAccess(ctx context.Context, entry *models.AccessLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access log entry: %w", err)
+	}
+	keys := []string{accessKey(entry.FileID), accessUsersKey(entry.FileID)}
+	err = trackAccessScript.Run(ctx, c.client, keys, entry.Bytes, entry.Via, entry.UserID, entry.AccessedAt.UnixMilli(), data).Err()
+	if err != nil {
+		return fmt.Errorf("failed to count download: %w", err)
+	}
+	return nil
+}
+
+// FileAccess returns the download statistics of fileID
+func (c *Counter) FileAccess(ctx context.Context, fileID string) (*models.FileStats, error) {
+	pipe := c.client.Pipeline()
+	totals := pipe.HGetAll(ctx, accessKey(fileID))
+	users := pipe.SCard(ctx, accessUsersKey(fileID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read file stats: %w", err)
+	}
+
+	result := models.NewFileStats(fileID)
+	result.UniqueUsers = users.Val()
+	for field, value := range totals.Val() {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch {
+		case field == "downloads":
+			result.Downloads = n
+		case field == "bytes":
+			result.BytesServed = n
+		case field == "anonymous":
+			result.AnonymousCount = n
+		case strings.HasPrefix(field, "via:"):
+			result.DownloadsByVia[strings.TrimPrefix(field, "via:")] = n
+		case field == "last":
+			var last models.AccessLogEntry
+			if err := json.Unmarshal([]byte(value), &last); err == nil {
+				result.LastAccess = &last
+			}
+		}
+	}
+	return result, nil
+}
+
+// ForgetAccess drops the download statistics of a deleted file
+func (c *Counter) ForgetAccess(ctx context.Context, fileID string) error {
+	if err := c.client.Del(ctx, accessKey(fileID), accessUsersKey(fileID)).Err(); err != nil {
+		return fmt.Errorf("failed to forget file stats: %w", err)
+	}
+	return nil
+}
+
+func accessKey(fileID string) string {
+	return accessKeyPrefix + fileID
+}
+
+func accessUsersKey(fileID string) string {
+	return accessKeyPrefix + fileID + ":users"
+}
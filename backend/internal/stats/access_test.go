@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterAccess(t *testing.T) {
+	counter := newTestCounter(t)
+	ctx := context.Background()
+	at := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	entries := []*models.AccessLogEntry{
+		{FileID: "f1", UserID: "u1", Via: "direct", Bytes: 10, AccessedAt: at.Add(time.Minute)},
+		{FileID: "f1", UserID: "u1", Via: "direct", Bytes: 10, AccessedAt: at},
+		{FileID: "f1", UserID: "u2", Via: "presigned", Bytes: 10, AccessedAt: at},
+		{FileID: "f1", Via: "share", ShareToken: "s1", Bytes: 4, AccessedAt: at},
+		{FileID: "f2", UserID: "u1", Via: "direct", Bytes: 99, AccessedAt: at},
+	}
+	for _, entry := range entries {
+		require.NoError(t, counter.TrackAccess(ctx, entry))
+	}
+
+	got, err := counter.FileAccess(ctx, "f1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), got.Downloads)
+	assert.Equal(t, int64(2), got.UniqueUsers)
+	assert.Equal(t, int64(1), got.AnonymousCount)
+	assert.Equal(t, int64(34), got.BytesServed)
+	assert.Equal(t, map[string]int64{"direct": 2, "presigned": 1, "share": 1}, got.DownloadsByVia)
+	require.NotNil(t, got.LastAccess)
+	// The latest download is kept even when an older one is counted after it
+	assert.True(t, at.Add(time.Minute).Equal(got.LastAccess.AccessedAt))
+
+	require.NoError(t, counter.ForgetAccess(ctx, "f1"))
+	got, err = counter.FileAccess(ctx, "f1")
+	require.NoError(t, err)
+	assert.Zero(t, got.Downloads)
+	assert.Zero(t, got.UniqueUsers)
+	assert.Empty(t, got.DownloadsByVia)
+
+	other, err := counter.FileAccess(ctx, "f2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), other.Downloads)
+}
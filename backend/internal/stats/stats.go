@@ -0,0 +1,310 @@
+// Package stats keeps running totals of users, posts, files, bookmarks, tags
+// and downloads in Redis, so system statistics, file statistics and the
+// totals of lists are read from counters instead of scanning the buckets.
+// The storage layer reports each write; counts of the items it has seen make
+// reporting the same item twice harmless.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Kinds of counted items
+const (
+	Users     = "users"
+	Posts     = "posts"
+	Files     = "files"
+	Bookmarks = "bookmarks"
+)
+
+// dailyRetention is how long the counts of a day are kept
+const dailyRetention = 400 * 24 * time.Hour
+
+const (
+	keyPrefix  = "stats:"
+	totalsKey  = keyPrefix + "totals"
+	rebuildKey = keyPrefix + "rebuild"
+
+	// readyKey changes whenever counters are added, so that servers count
+	// everything again instead of serving the new ones incomplete
+	readyKey = keyPrefix + "ready:access"
+)
+
+// trackScript records an item of kind ARGV[1] with ID ARGV[2] in group
+// ARGV[3] and size ARGV[4]. An item seen before moves from its old group
+// and size to the new ones; a new item is also counted on its day. The
+// item moves from the scopes it was counted in to those in ARGV[6], one
+// per line.
+var trackScript = redis.NewScript(`
+local kind, id, group, size = ARGV[1], ARGV[2], ARGV[3], tonumber(ARGV[4])
+
+local oldScopes = redis.call("HGET", KEYS[4], id)
+if oldScopes then
+	for scope in string.gmatch(oldScopes, "[^\n]+") do
+		redis.call("HINCRBY", KEYS[5], scope, -1)
+	end
+end
+for scope in string.gmatch(ARGV[6], "[^\n]+") do
+	redis.call("HINCRBY", KEYS[5], scope, 1)
+end
+redis.call("HSET", KEYS[4], id, ARGV[6])
+
+local old = redis.call("HGET", KEYS[1], id)
+if old then
+	local sep = string.find(old, "|", 1, true)
+	local oldGroup = string.sub(old, 1, sep - 1)
+	local oldSize = tonumber(string.sub(old, sep + 1))
+	if oldGroup ~= "" then
+		redis.call("HINCRBY", KEYS[2], kind .. ":group:" .. oldGroup, -1)
+	end
+	redis.call("HINCRBY", KEYS[2], kind .. ":bytes", size - oldSize)
+else
+	redis.call("HINCRBY", KEYS[2], kind, 1)
+	redis.call("HINCRBY", KEYS[2], kind .. ":bytes", size)
+	redis.call("HINCRBY", KEYS[3], "count", 1)
+	redis.call("HINCRBY", KEYS[3], "bytes", size)
+	redis.call("PEXPIRE", KEYS[3], ARGV[5])
+end
+if group ~= "" then
+	redis.call("HINCRBY", KEYS[2], kind .. ":group:" .. group, 1)
+end
+redis.call("HSET", KEYS[1], id, group .. "|" .. size)
+return 1
+`)
+
+// untrackScript forgets the item of kind ARGV[1] with ID ARGV[2]
+var untrackScript = redis.NewScript(`
+local kind, id = ARGV[1], ARGV[2]
+
+local oldScopes = redis.call("HGET", KEYS[3], id)
+if oldScopes then
+	for scope in string.gmatch(oldScopes, "[^\n]+") do
+		redis.call("HINCRBY", KEYS[4], scope, -1)
+	end
+	redis.call("HDEL", KEYS[3], id)
+end
+
+local old = redis.call("HGET", KEYS[1], id)
+if not old then
+	return 0
+end
+local sep = string.find(old, "|", 1, true)
+local oldGroup = string.sub(old, 1, sep - 1)
+local oldSize = tonumber(string.sub(old, sep + 1))
+
+redis.call("HDEL", KEYS[1], id)
+redis.call("HINCRBY", KEYS[2], kind, -1)
+redis.call("HINCRBY", KEYS[2], kind .. ":bytes", -oldSize)
+if oldGroup ~= "" then
+	redis.call("HINCRBY", KEYS[2], kind .. ":group:" .. oldGroup, -1)
+end
+return 1
+`)
+
+// Summary is the running total of one kind of item
+type Summary struct {
+	Count  int64
+	Bytes  int64
+	Groups map[string]int64 // items per group, such as posts per status
+}
+
+// Counter keeps the totals in Redis
+type Counter struct {
+	client *redis.Client
+}
+
+func NewCounter(client *redis.Client) *Counter {
+	return &Counter{client: client}
+}
+
+// Track records that the item of kind with id exists in group with size
+// bytes and is counted in scopes, which Scopes makes. created decides the
+// day a new item is counted on.
+func (c *Counter) Track(ctx context.Context, kind, id, group string, size int64, created time.Time, scopes ...string) error {
+	keys := []string{indexKey(kind), totalsKey, dailyKey(kind, created), scopeIndexKey(kind), scopesKey(kind)}
+	err := trackScript.Run(ctx, c.client, keys, kind, id, group, size, dailyRetention.Milliseconds(), strings.Join(scopes, "\n")).Err()
+	if err != nil {
+		return fmt.Errorf("failed to count %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Untrack records that the item of kind with id is gone. Counts of the
+// day it was created on stay.
+func (c *Counter) Untrack(ctx context.Context, kind, id string) error {
+	keys := []string{indexKey(kind), totalsKey, scopeIndexKey(kind), scopesKey(kind)}
+	if err := untrackScript.Run(ctx, c.client, keys, kind, id).Err(); err != nil {
+		return fmt.Errorf("failed to uncount %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Summary returns the running total of kind
+func (c *Counter) Summary(ctx context.Context, kind string) (*Summary, error) {
+	totals, err := c.client.HGetAll(ctx, totalsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	summary := &Summary{Groups: make(map[string]int64)}
+	for field, value := range totals {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch {
+		case field == kind:
+			summary.Count = n
+		case field == kind+":bytes":
+			summary.Bytes = n
+		case strings.HasPrefix(field, kind+":group:"):
+			if n != 0 {
+				summary.Groups[strings.TrimPrefix(field, kind+":group:")] = n
+			}
+		}
+	}
+	return summary, nil
+}
+
+// Scope names the items whose fields have the given values, such as the
+// posts of a user with a status
+func Scope(fields map[string]string) string {
+	values := url.Values{}
+	for field, value := range fields {
+		values.Set(field, value)
+	}
+	return values.Encode()
+}
+
+// Scopes returns every scope an item with the given field values is
+// counted in: one per combination of the fields
+func Scopes(fields map[string]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var scopes []string
+	for set := 1; set < 1<<len(names); set++ {
+		subset := make(map[string]string)
+		for i, name := range names {
+			if set&(1<<i) != 0 {
+				subset[name] = fields[name]
+			}
+		}
+		scopes = append(scopes, Scope(subset))
+	}
+	return scopes
+}
+
+// Count returns how many items of kind are in scope, or all of them for
+// an empty scope
+func (c *Counter) Count(ctx context.Context, kind, scope string) (int64, error) {
+	key, field := scopesKey(kind), scope
+	if scope == "" {
+		key, field = totalsKey, kind
+	}
+
+	n, err := c.client.HGet(ctx, key, field).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s count: %w", kind, err)
+	}
+	return n, nil
+}
+
+// Daily returns the items of kind created on each of the days up to and
+// including the day of until, oldest first
+func (c *Counter) Daily(ctx context.Context, kind string, until time.Time, days int) ([]models.DailyCount, error) {
+	pipe := c.client.Pipeline()
+	results := make([]*redis.MapStringStringCmd, days)
+	dates := make([]time.Time, days)
+	for i := range days {
+		dates[i] = until.UTC().AddDate(0, 0, i-days+1)
+		results[i] = pipe.HGetAll(ctx, dailyKey(kind, dates[i]))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read daily stats: %w", err)
+	}
+
+	counts := make([]models.DailyCount, days)
+	for i, result := range results {
+		values := result.Val()
+		counts[i].Date = dates[i].Format(time.DateOnly)
+		counts[i].Count, _ = strconv.ParseInt(values["count"], 10, 64)
+		counts[i].Bytes, _ = strconv.ParseInt(values["bytes"], 10, 64)
+	}
+	return counts, nil
+}
+
+// Ready reports whether the counters were built from the stored items.
+// Until they are, the counts only cover writes seen since.
+func (c *Counter) Ready(ctx context.Context) (bool, error) {
+	n, err := c.client.Exists(ctx, readyKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to read stats: %w", err)
+	}
+	return n == 1, nil
+}
+
+// StartRebuild clears the counters for counting every stored item again.
+// It returns false when another rebuild started within ttl.
+func (c *Counter) StartRebuild(ctx context.Context, ttl time.Duration) (bool, error) {
+	started, err := c.client.SetNX(ctx, rebuildKey, time.Now().Unix(), ttl).Result()
+	if err != nil || !started {
+		return false, err
+	}
+
+	var keys []string
+	iter := c.client.Scan(ctx, 0, keyPrefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		if iter.Val() != rebuildKey {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return false, fmt.Errorf("failed to clear stats: %w", err)
+	}
+	if len(keys) > 0 {
+		if err := c.client.Del(ctx, keys...).Err(); err != nil {
+			return false, fmt.Errorf("failed to clear stats: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// FinishRebuild marks the counters ready
+func (c *Counter) FinishRebuild(ctx context.Context) error {
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, readyKey, time.Now().Unix(), 0)
+	pipe.Del(ctx, rebuildKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to finish stats rebuild: %w", err)
+	}
+	return nil
+}
+
+func indexKey(kind string) string {
+	return keyPrefix + "index:" + kind
+}
+
+func scopeIndexKey(kind string) string {
+	return keyPrefix + "scope-index:" + kind
+}
+
+func scopesKey(kind string) string {
+	return keyPrefix + "scopes:" + kind
+}
+
+func dailyKey(kind string, day time.Time) string {
+	return keyPrefix + "daily:" + kind + ":" + day.UTC().Format(time.DateOnly)
+}
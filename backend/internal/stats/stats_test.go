@@ -0,0 +1,118 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCounter(t *testing.T) *Counter {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewCounter(client)
+}
+
+func TestCounter(t *testing.T) {
+	counter := newTestCounter(t)
+	ctx := context.Background()
+	today := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	require.NoError(t, counter.Track(ctx, Posts, "p1", "draft", 0, yesterday))
+	require.NoError(t, counter.Track(ctx, Posts, "p2", "draft", 0, today))
+	// Saving a post again moves it between statuses without counting it twice
+	require.NoError(t, counter.Track(ctx, Posts, "p1", "published", 0, today))
+	require.NoError(t, counter.Track(ctx, Posts, "p1", "published", 0, today))
+
+	posts, err := counter.Summary(ctx, Posts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), posts.Count)
+	assert.Equal(t, map[string]int64{"draft": 1, "published": 1}, posts.Groups)
+
+	require.NoError(t, counter.Track(ctx, Files, "f1", "", 100, today))
+	require.NoError(t, counter.Track(ctx, Files, "f1", "", 150, today))
+	require.NoError(t, counter.Track(ctx, Files, "f2", "", 50, today))
+	require.NoError(t, counter.Untrack(ctx, Files, "f2"))
+	require.NoError(t, counter.Untrack(ctx, Files, "unknown"))
+
+	files, err := counter.Summary(ctx, Files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), files.Count)
+	assert.Equal(t, int64(150), files.Bytes)
+
+	days, err := counter.Daily(ctx, Posts, today, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DailyCount{
+		{Date: "2026-03-08"},
+		{Date: "2026-03-09", Count: 1},
+		{Date: "2026-03-10", Count: 1},
+	}, days)
+
+	// Deleted files stay counted on the day they were uploaded
+	days, err = counter.Daily(ctx, Files, today, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DailyCount{{Date: "2026-03-10", Count: 2, Bytes: 150}}, days)
+}
+
+func TestCounterRebuild(t *testing.T) {
+	counter := newTestCounter(t)
+	ctx := context.Background()
+
+	require.NoError(t, counter.Track(ctx, Users, "u1", "", 0, time.Now()))
+	ready, err := counter.Ready(ctx)
+	require.NoError(t, err)
+	assert.False(t, ready)
+
+	started, err := counter.StartRebuild(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, started)
+	started, err = counter.StartRebuild(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, started, "only one rebuild runs at a time")
+
+	users, err := counter.Summary(ctx, Users)
+	require.NoError(t, err)
+	assert.Zero(t, users.Count)
+
+	require.NoError(t, counter.FinishRebuild(ctx))
+	ready, err = counter.Ready(ctx)
+	require.NoError(t, err)
+	assert.True(t, ready)
+}
+
+func TestCounterScopes(t *testing.T) {
+	counter := newTestCounter(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	alice := Scopes(map[string]string{"userId": "alice", "status": "draft"})
+	assert.Len(t, alice, 3)
+	require.NoError(t, counter.Track(ctx, Posts, "p1", "draft", 0, now, alice...))
+	require.NoError(t, counter.Track(ctx, Posts, "p2", "draft", 0, now, alice...))
+	// Publishing a post moves it to the scopes of its new status
+	require.NoError(t, counter.Track(ctx, Posts, "p2", "published", 0, now,
+		Scopes(map[string]string{"userId": "alice", "status": "published"})...))
+	require.NoError(t, counter.Track(ctx, Posts, "p3", "draft", 0, now,
+		Scopes(map[string]string{"userId": "bob", "status": "draft"})...))
+	require.NoError(t, counter.Untrack(ctx, Posts, "p1"))
+
+	for scope, want := range map[string]int64{
+		"": 2,
+		Scope(map[string]string{"userId": "alice"}):                        1,
+		Scope(map[string]string{"status": "draft"}):                        1,
+		Scope(map[string]string{"userId": "alice", "status": "draft"}):     0,
+		Scope(map[string]string{"status": "published", "userId": "alice"}): 1,
+		Scope(map[string]string{"userId": "carol"}):                        0,
+	} {
+		n, err := counter.Count(ctx, Posts, scope)
+		require.NoError(t, err)
+		assert.Equal(t, want, n, scope)
+	}
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tagsKey     = keyPrefix + "tags"
+	tagIndexKey = keyPrefix + "index:tags"
+)
+
+// trackTagsScript records that post ARGV[1] carries the tags in ARGV[2],
+// one per line. Tags the post did not carry before are counted, and on
+// the day key KEYS[3] as well; tags it dropped are uncounted.
+var trackTagsScript = redis.NewScript(`
+local id = ARGV[1]
+local old, new = {}, {}
+local oldTags = redis.call("HGET", KEYS[2], id)
+if oldTags then
+	for tag in string.gmatch(oldTags, "[^\n]+") do
+		old[tag] = true
+	end
+end
+for tag in string.gmatch(ARGV[2], "[^\n]+") do
+	new[tag] = true
+	if not old[tag] then
+		redis.call("HINCRBY", KEYS[1], tag, 1)
+		redis.call("HINCRBY", KEYS[3], tag, 1)
+		redis.call("PEXPIRE", KEYS[3], ARGV[3])
+	end
+end
+for tag in pairs(old) do
+	if not new[tag] and redis.call("HINCRBY", KEYS[1], tag, -1) <= 0 then
+		redis.call("HDEL", KEYS[1], tag)
+	end
+end
+if ARGV[2] == "" then
+	redis.call("HDEL", KEYS[2], id)
+else
+	redis.call("HSET", KEYS[2], id, ARGV[2])
+end
+return 1
+`)
+
+// TrackTags records the tags post id carries now, lowercased; none
+// forgets the post. Tags new to the post are counted on the day of at.
+func (c *Counter) TrackTags(ctx context.Context, id string, tags []string, at time.Time) error {
+	seen := make(map[string]bool, len(tags))
+	var lines []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			lines = append(lines, tag)
+		}
+	}
+
+	keys := []string{tagsKey, tagIndexKey, dailyKey("tags", at)}
+	if err := trackTagsScript.Run(ctx, c.client, keys, id, strings.Join(lines, "\n"), dailyRetention.Milliseconds()).Err(); err != nil {
+		return fmt.Errorf("failed to count tags: %w", err)
+	}
+	return nil
+}
+
+// Tags returns how many posts carry each tag
+func (c *Counter) Tags(ctx context.Context) (map[string]int64, error) {
+	values, err := c.client.HGetAll(ctx, tagsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tag stats: %w", err)
+	}
+	counts := make(map[string]int64, len(values))
+	for tag, value := range values {
+		if n, _ := strconv.ParseInt(value, 10, 64); n > 0 {
+			counts[tag] = n
+		}
+	}
+	return counts, nil
+}
+
+// TaggedSince returns how often each tag was added to a post on the days
+// from since up to and including the day of until
+func (c *Counter) TaggedSince(ctx context.Context, since, until time.Time) (map[string]int64, error) {
+	pipe := c.client.Pipeline()
+	var results []*redis.MapStringStringCmd
+	for day := since.UTC().Truncate(24 * time.Hour); !day.After(until.UTC()); day = day.AddDate(0, 0, 1) {
+		results = append(results, pipe.HGetAll(ctx, dailyKey("tags", day)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read tag stats: %w", err)
+	}
+
+	counts := make(map[string]int64)
+	for _, result := range results {
+		for tag, value := range result.Val() {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			counts[tag] += n
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterTags(t *testing.T) {
+	counter := newTestCounter(t)
+	ctx := context.Background()
+	today := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	lastWeek := today.AddDate(0, 0, -7)
+
+	require.NoError(t, counter.TrackTags(ctx, "p1", []string{"Go", "minio", "go"}, lastWeek))
+	require.NoError(t, counter.TrackTags(ctx, "p2", []string{"go"}, today))
+	// Saving a post again only counts the tags it gained
+	require.NoError(t, counter.TrackTags(ctx, "p1", []string{"go", "redis"}, today))
+
+	tags, err := counter.Tags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"go": 2, "redis": 1}, tags)
+
+	recent, err := counter.TaggedSince(ctx, today.AddDate(0, 0, -1), today)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"go": 1, "redis": 1}, recent)
+
+	older, err := counter.TaggedSince(ctx, lastWeek, lastWeek)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"go": 1, "minio": 1}, older)
+
+	// No tags forgets the post
+	require.NoError(t, counter.TrackTags(ctx, "p1", nil, today))
+	tags, err = counter.Tags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"go": 1}, tags)
+}
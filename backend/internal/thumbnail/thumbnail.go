@@ -0,0 +1,98 @@
+package thumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register decoders for the formats we accept
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Sizes maps the supported size names to their longest edge in pixels
+var Sizes = map[string]int{
+	"small":  128,
+	"medium": 512,
+	"large":  1024,
+}
+
+// AvatarSizes maps the avatar size names to their edge in pixels
+var AvatarSizes = map[string]int{
+	"small":  64,
+	"medium": 256,
+	"large":  512,
+}
+
+// ContentType of every generated thumbnail
+const ContentType = "image/jpeg"
+
+// IsSupported reports whether thumbnails can be generated for contentType
+func IsSupported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Decode reads a source image once so it can be resized to several sizes
+func Decode(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// Generate scales src so its longest edge is at most maxEdge and encodes it
+// as JPEG. Images already smaller than maxEdge are re-encoded unscaled.
+func Generate(src image.Image, maxEdge int) ([]byte, error) {
+	bounds := src.Bounds()
+	width, height := fit(bounds.Dx(), bounds.Dy(), maxEdge)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	// JPEG has no alpha channel, so flatten transparent areas onto white
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Square crops the largest centered square out of src, scales it to edge
+// by edge and encodes it as JPEG. Smaller squares are scaled up.
+func Square(src image.Image, edge int) ([]byte, error) {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+
+	dst := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fit(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width)
+	}
+	return max(1, width*maxEdge/height), maxEdge
+}
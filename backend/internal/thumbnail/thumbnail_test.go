@@ -0,0 +1,65 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2000, 1000))))
+
+	src, err := Decode(&buf)
+	require.NoError(t, err)
+
+	data, err := Generate(src, Sizes["medium"])
+	require.NoError(t, err)
+
+	thumb, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 512, thumb.Bounds().Dx())
+	assert.Equal(t, 256, thumb.Bounds().Dy())
+}
+
+func TestFit(t *testing.T) {
+	w, h := fit(100, 50, 128)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+
+	w, h = fit(300, 1200, 128)
+	assert.Equal(t, 32, w)
+	assert.Equal(t, 128, h)
+}
+
+func TestIsSupported(t *testing.T) {
+	assert.True(t, IsSupported("image/png"))
+	assert.False(t, IsSupported("application/pdf"))
+}
+
+func TestSquare(t *testing.T) {
+	// A wide transparent image with a red left third; the centered crop
+	// leaves the red out and shows the rest flattened onto white
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	data, err := Square(src, AvatarSizes["small"])
+	require.NoError(t, err)
+
+	avatar, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), avatar.Bounds())
+	_, g, _, _ := avatar.At(2, 32).RGBA()
+	assert.Greater(t, g>>8, uint32(192), "left edge is white, not red")
+}
@@ -0,0 +1,161 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the
+// clients the server talks to.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio-fullstack-storage/backend/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans of the server are created with
+const instrumentationName = "github.com/minio-fullstack-storage/backend"
+
+// Setup installs the global tracer provider and propagator. Without an
+// endpoint no spans are exported, but trace context in requests and
+// messages is still passed on. The returned function flushes pending
+// spans and must be called on shutdown.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Requests that arrive with a sampled trace are always recorded,
+		// so traces started by a caller are complete
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the server from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Transport wraps base so every request to MinIO is recorded as a client
+// span under the span of its context, named after the S3 operation
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bucket, key := splitObjectPath(req.URL.Path)
+	attributes := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	if bucket != "" {
+		attributes = append(attributes, semconv.AWSS3Bucket(bucket))
+	}
+	if key != "" {
+		attributes = append(attributes, semconv.AWSS3Key(key))
+	}
+
+	ctx, span := Tracer().Start(req.Context(), "minio."+operation(req, key),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	// Missing objects are answered with 404 in normal operation, so only
+	// server errors mark the span as failed
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+// splitObjectPath splits a path-style S3 URL path into bucket and key
+func splitObjectPath(path string) (bucket, key string) {
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return bucket, key
+}
+
+// operation names the S3 operation of req from its method and query
+func operation(req *http.Request, key string) string {
+	query := req.URL.Query()
+	switch {
+	case query.Has("uploads") || query.Has("uploadId"):
+		return "MultipartUpload"
+	case query.Has("versions"):
+		return "ListObjectVersions"
+	case query.Has("location"):
+		return "GetBucketLocation"
+	case query.Has("delete"):
+		return "RemoveObjects"
+	}
+
+	if key == "" {
+		switch req.Method {
+		case http.MethodGet:
+			return "ListObjects"
+		case http.MethodHead:
+			return "BucketExists"
+		case http.MethodPut:
+			return "MakeBucket"
+		}
+		return "Bucket" + req.Method
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		return "GetObject"
+	case http.MethodHead:
+		return "StatObject"
+	case http.MethodPut:
+		if req.Header.Get("X-Amz-Copy-Source") != "" {
+			return "CopyObject"
+		}
+		return "PutObject"
+	case http.MethodDelete:
+		return "RemoveObject"
+	}
+	return "Object" + req.Method
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req, err := http.NewRequestWithContext(ctx, method, server.URL+"/users/users/alice.json", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	get := spans[0]
+	assert.Equal(t, "minio.GetObject", get.Name())
+	assert.Equal(t, trace.SpanKindClient, get.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), get.Parent().SpanID())
+	attributes := map[string]string{}
+	for _, attribute := range get.Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	assert.Equal(t, "users", attributes["aws.s3.bucket"])
+	assert.Equal(t, "users/alice.json", attributes["aws.s3.key"])
+	assert.Equal(t, "404", attributes["http.response.status_code"])
+	// A missing object is not a failure
+	assert.Equal(t, "Unset", get.Status().Code.String())
+
+	assert.Equal(t, "minio.RemoveObject", spans[1].Name())
+	assert.Equal(t, "Error", spans[1].Status().Code.String())
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		header string
+		want   string
+	}{
+		{http.MethodGet, "/files/a/b.json", "", "GetObject"},
+		{http.MethodGet, "/files/?list-type=2&prefix=a", "", "ListObjects"},
+		{http.MethodGet, "/files/?location=", "", "GetBucketLocation"},
+		{http.MethodHead, "/files/a", "", "StatObject"},
+		{http.MethodHead, "/files/", "", "BucketExists"},
+		{http.MethodPut, "/files/a", "", "PutObject"},
+		{http.MethodPut, "/files/a", "/files/b", "CopyObject"},
+		{http.MethodPost, "/files/a?uploads=", "", "MultipartUpload"},
+		{http.MethodPost, "/files/?delete=", "", "RemoveObjects"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Amz-Copy-Source", tt.header)
+		}
+		_, key := splitObjectPath(req.URL.Path)
+		assert.Equal(t, tt.want, operation(req, key), "%s %s", tt.method, tt.target)
+	}
+}
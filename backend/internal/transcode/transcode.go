@@ -0,0 +1,192 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MasterPlaylist is the name of the top level HLS playlist
+const MasterPlaylist = "master.m3u8"
+
+// Rendition is one rung of the bitrate ladder
+type Rendition struct {
+	Name         string
+	Height       int
+	VideoBitrate int // kbit/s
+	AudioBitrate int // kbit/s
+}
+
+// Ladder lists the renditions that can be produced, largest first
+var Ladder = []Rendition{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 128},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
+
+// IsSupported reports whether videos of contentType can be transcoded
+func IsSupported(contentType string) bool {
+	return strings.HasPrefix(contentType, "video/")
+}
+
+// ContentType returns the MIME type of an HLS output file
+func ContentType(name string) string {
+	switch filepath.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	}
+	return "application/octet-stream"
+}
+
+// Transcoder produces HLS renditions with ffmpeg
+type Transcoder struct {
+	ffmpeg     string
+	ffprobe    string
+	renditions []Rendition
+}
+
+// NewTranscoder uses the named renditions from Ladder; unknown names are
+// ignored and an empty list selects the whole ladder
+func NewTranscoder(ffmpegPath, ffprobePath string, names []string) *Transcoder {
+	renditions := Ladder
+	if len(names) > 0 {
+		renditions = nil
+		for _, rendition := range Ladder {
+			for _, name := range names {
+				if strings.EqualFold(strings.TrimSpace(name), rendition.Name) {
+					renditions = append(renditions, rendition)
+				}
+			}
+		}
+	}
+
+	return &Transcoder{
+		ffmpeg:     ffmpegPath,
+		ffprobe:    ffprobePath,
+		renditions: renditions,
+	}
+}
+
+// Transcode writes an HLS master playlist and one subdirectory per rendition
+// into outDir and returns the names of the renditions produced
+func (t *Transcoder) Transcode(ctx context.Context, input, outDir string) ([]string, error) {
+	width, height, err := t.probe(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	renditions := Select(t.renditions, height)
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("no renditions configured")
+	}
+
+	names := make([]string, 0, len(renditions))
+	for _, rendition := range renditions {
+		if err := t.encode(ctx, input, outDir, rendition); err != nil {
+			return nil, err
+		}
+		names = append(names, rendition.Name)
+	}
+
+	master := Master(renditions, width, height)
+	if err := os.WriteFile(filepath.Join(outDir, MasterPlaylist), []byte(master), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	return names, nil
+}
+
+func (t *Transcoder) probe(ctx context.Context, input string) (int, int, error) {
+	cmd := exec.CommandContext(ctx, t.ffprobe,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "json",
+		input,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Height == 0 {
+		return 0, 0, fmt.Errorf("no video stream found")
+	}
+
+	return probe.Streams[0].Width, probe.Streams[0].Height, nil
+}
+
+func (t *Transcoder) encode(ctx context.Context, input, outDir string, rendition Rendition) error {
+	dir := filepath.Join(outDir, rendition.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+
+	bitrate := strconv.Itoa(rendition.VideoBitrate) + "k"
+	cmd := exec.CommandContext(ctx, t.ffmpeg,
+		"-y", "-v", "error",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", "scale=-2:"+strconv.Itoa(rendition.Height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main",
+		"-b:v", bitrate, "-maxrate", bitrate, "-bufsize", strconv.Itoa(rendition.VideoBitrate*2)+"k",
+		"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
+		"-c:a", "aac", "-ac", "2", "-b:a", strconv.Itoa(rendition.AudioBitrate)+"k",
+		"-hls_time", "6",
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(dir, "segment_%04d.ts"),
+		filepath.Join(dir, "index.m3u8"),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed for %s: %w: %s", rendition.Name, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Select returns the renditions no taller than the source, so videos are
+// never upscaled. Sources smaller than every rendition get the smallest one.
+func Select(renditions []Rendition, sourceHeight int) []Rendition {
+	var selected []Rendition
+	for _, rendition := range renditions {
+		if rendition.Height <= sourceHeight {
+			selected = append(selected, rendition)
+		}
+	}
+	if len(selected) == 0 && len(renditions) > 0 {
+		selected = renditions[len(renditions)-1:]
+	}
+	return selected
+}
+
+// Master renders the HLS master playlist for renditions of a source with the
+// given dimensions
+func Master(renditions []Rendition, sourceWidth, sourceHeight int) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		width := rendition.Height * sourceWidth / sourceHeight
+		width += width % 2 // scale=-2 rounds to an even width
+		bandwidth := (rendition.VideoBitrate + rendition.AudioBitrate) * 1000
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n%s/index.m3u8\n",
+			bandwidth, width, rendition.Height, rendition.Name)
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	names := func(renditions []Rendition) []string {
+		var result []string
+		for _, r := range renditions {
+			result = append(result, r.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"720p", "480p", "360p"}, names(Select(Ladder, 720)))
+	assert.Equal(t, []string{"1080p", "720p", "480p", "360p"}, names(Select(Ladder, 2160)))
+	assert.Equal(t, []string{"360p"}, names(Select(Ladder, 240)))
+}
+
+func TestNewTranscoderRenditions(t *testing.T) {
+	transcoder := NewTranscoder("ffmpeg", "ffprobe", []string{"480P", " 1080p", "4k"})
+
+	assert.Len(t, transcoder.renditions, 2)
+	assert.Equal(t, "1080p", transcoder.renditions[0].Name)
+	assert.Equal(t, "480p", transcoder.renditions[1].Name)
+}
+
+func TestMaster(t *testing.T) {
+	master := Master(Select(Ladder, 720), 1280, 720)
+
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2928000,RESOLUTION=1280x720\n720p/index.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=1528000,RESOLUTION=854x480\n480p/index.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=896000,RESOLUTION=640x360\n360p/index.m3u8\n", master)
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "application/vnd.apple.mpegurl", ContentType("720p/index.m3u8"))
+	assert.Equal(t, "video/mp2t", ContentType("720p/segment_0001.ts"))
+}
@@ -0,0 +1,232 @@
+// Package webhook sends domain events to the URLs admins register, so
+// external systems can react to uploads and posts.
+//
+// Each delivery is a POST of the event as JSON. The body is signed with
+// the secret of the webhook, so receivers can check that it came from this
+// server and was not replayed:
+//
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// X-Webhook-Event names the event type, X-Webhook-ID the webhook and
+// X-Webhook-Delivery the delivery, which stays the same across retries.
+//
+// Unless allowed, receivers on loopback, private and link-local addresses
+// are refused when connecting, after names are resolved, so a webhook
+// cannot reach services inside the network.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+)
+
+// Headers of every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// SecretPrefix starts every generated secret
+const SecretPrefix = "whsec_"
+
+// Retries wait firstRetry, doubling after each failed attempt up to
+// maxRetry
+const (
+	firstRetry = 30 * time.Second
+	maxRetry   = time.Hour
+)
+
+// maxResponseBody is how much of a receiver's response is kept for the
+// delivery log
+const maxResponseBody = 1024
+
+// ErrPrivateAddress is returned when a receiver is on an address that is
+// not public
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// PublicAddress reports whether ip may receive webhooks: it is not a
+// loopback, private, link-local, multicast or unspecified address
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// refusePrivate is a dialer control that refuses connections to addresses
+// that are not public. It runs for each address a name resolves to, so
+// names that resolve differently later cannot get around it.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !PublicAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return SecretPrefix + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value of body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at
+// timestamp, for receivers written in Go
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns how long to wait before the next attempt after attempts
+// failed ones
+func Backoff(attempts int) time.Duration {
+	delay := firstRetry
+	for i := 1; i < attempts && delay < maxRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetry)
+}
+
+// ValidPattern reports whether pattern names event types: one type such
+// as post.published, every type of a kind such as post.*, or * for all
+func ValidPattern(pattern string) bool {
+	if pattern == "*" || slices.Contains(events.Types, pattern) {
+		return true
+	}
+	kind, ok := strings.CutSuffix(pattern, ".*")
+	return ok && slices.ContainsFunc(events.Types, func(t string) bool {
+		return strings.HasPrefix(t, kind+".")
+	})
+}
+
+// Matches reports whether any of patterns names eventType
+func Matches(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if kind, ok := strings.CutSuffix(pattern, ".*"); ok && strings.HasPrefix(eventType, kind+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Request is one attempt to deliver an event
+type Request struct {
+	URL        string
+	Secret     string
+	WebhookID  string
+	DeliveryID string
+	EventType  string
+	Body       []byte
+}
+
+// Response is what the receiver answered
+type Response struct {
+	Status   int
+	Body     string // the start of the body
+	Duration time.Duration
+}
+
+// Sender posts deliveries. Redirects are not followed; receivers must
+// answer with a 2xx status.
+type Sender struct {
+	client    *http.Client
+	userAgent string
+}
+
+// NewSender returns a Sender that gives receivers timeout to answer.
+// Unless allowPrivate is set it connects only to public addresses, and
+// not through proxies, which would connect for it.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		userAgent: "minio-storage-webhooks/1",
+	}
+}
+
+// Send posts req. It returns the response, if there was one, and an error
+// unless the receiver answered with a 2xx status.
+func (s *Sender) Send(ctx context.Context, req Request) (*Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", s.userAgent)
+	httpReq.Header.Set(HeaderEvent, req.EventType)
+	httpReq.Header.Set(HeaderID, req.WebhookID)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID)
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, req.Body))
+
+	start := time.Now()
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result := &Response{
+		Status:   resp.StatusCode,
+		Body:     string(body),
+		Duration: time.Since(start),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return result, nil
+}
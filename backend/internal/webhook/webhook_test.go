@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"post.published"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature))
+	assert.False(t, Verify("secret", 1700000000, []byte(`{}`), signature))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, time.Hour, Backoff(8))
+	assert.Equal(t, time.Hour, Backoff(100))
+}
+
+func TestPatterns(t *testing.T) {
+	for _, pattern := range []string{"*", "post.published", "file.*", "user.*", "comment.*"} {
+		assert.True(t, ValidPattern(pattern), pattern)
+	}
+	for _, pattern := range []string{"", "post", "post.liked", "share.*", "*.*"} {
+		assert.False(t, ValidPattern(pattern), pattern)
+	}
+
+	assert.True(t, Matches([]string{"*"}, "file.uploaded"))
+	assert.True(t, Matches([]string{"user.created", "file.*"}, "file.uploaded"))
+	assert.False(t, Matches([]string{"user.created", "post.*"}, "file.uploaded"))
+	assert.False(t, Matches([]string{"file.*"}, "files.uploaded"))
+}
+
+func TestSend(t *testing.T) {
+	body := []byte(`{"id":"event-1"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+
+		assert.Equal(t, body, received)
+		assert.True(t, Verify("secret", timestamp, received, r.Header.Get(HeaderSignature)))
+		assert.Equal(t, "file.uploaded", r.Header.Get(HeaderEvent))
+		assert.Equal(t, "hook-1", r.Header.Get(HeaderID))
+		assert.Equal(t, "delivery-1", r.Header.Get(HeaderDelivery))
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("try later"))
+			return
+		}
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := NewSender(5*time.Second, true)
+	req := Request{Secret: "secret", WebhookID: "hook-1", DeliveryID: "delivery-1", EventType: "file.uploaded", Body: body}
+
+	req.URL = server.URL + "/ok"
+	resp, err := sender.Send(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.Status)
+
+	req.URL = server.URL + "/fail"
+	resp, err = sender.Send(context.Background(), req)
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Status)
+	assert.Equal(t, "try later", resp.Body)
+
+	req.URL = server.URL + "/redirect"
+	resp, err = sender.Send(context.Background(), req)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusFound, resp.Status)
+}
+
+func TestPublicAddress(t *testing.T) {
+	for _, addr := range []string{"203.0.113.7", "8.8.8.8", "2001:4860:4860::8888", "::ffff:203.0.113.7"} {
+		assert.True(t, PublicAddress(netip.MustParseAddr(addr)), addr)
+	}
+	for _, addr := range []string{
+		"127.0.0.1", "::1", "::ffff:127.0.0.1", // loopback
+		"10.1.2.3", "172.16.0.1", "192.168.1.1", "fd00::1", // private
+		"169.254.169.254", "fe80::1", // link-local
+		"0.0.0.0", "::", "224.0.0.1", "ff02::1",
+	} {
+		assert.False(t, PublicAddress(netip.MustParseAddr(addr)), addr)
+	}
+	assert.False(t, PublicAddress(netip.Addr{}))
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	sender := NewSender(5*time.Second, false)
+	// By address and by a name that resolves to it
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		resp, err := sender.Send(context.Background(), Request{URL: url, Secret: "secret", Body: []byte(`{}`)})
+		assert.ErrorIs(t, err, ErrPrivateAddress, url)
+		assert.Nil(t, resp)
+	}
+	assert.False(t, reached)
+
+	resp, err := NewSender(5*time.Second, true).Send(context.Background(), Request{URL: server.URL, Secret: "secret", Body: []byte(`{}`)})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.Status)
+	assert.True(t, reached)
+}
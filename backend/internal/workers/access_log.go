@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// AccessLogWorker persists download events published by the API so that
+// recording them never slows down a download
+type AccessLogWorker struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewAccessLogWorker(storageService *services.StorageService, messagingClient *messaging.Client) *AccessLogWorker {
+	return &AccessLogWorker{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// Start subscribes the worker to access events. Replicas share a queue group
+// so every event is stored once.
+func (w *AccessLogWorker) Start() error {
+	return w.messaging.QueueSubscribe(messaging.SubjectAccessLog, "access-log-workers", func(ctx context.Context, data []byte) {
+		var entry models.AccessLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Printf("access log worker: invalid event: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := w.storageService.RecordAccess(ctx, &entry); err != nil {
+			log.Printf("access log worker: file %s: %v", entry.FileID, err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// AuditLogWorker persists audit events and admin actions published by the
+// API, and keeps the login history of users from their login events
+type AuditLogWorker struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+}
+
+func NewAuditLogWorker(storageService *services.StorageService, messagingClient *messaging.Client) *AuditLogWorker {
+	return &AuditLogWorker{
+		storageService: storageService,
+		messaging:      messagingClient,
+	}
+}
+
+// Start subscribes the worker to audit events and admin actions. Replicas
+// share a queue group so every event is stored once.
+func (w *AuditLogWorker) Start() error {
+	err := w.messaging.QueueSubscribe(messaging.SubjectAdminLog, "audit-log-workers", func(ctx context.Context, data []byte) {
+		var action models.AdminAction
+		if err := json.Unmarshal(data, &action); err != nil {
+			log.Printf("audit log worker: invalid admin action: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := w.storageService.RecordAdminAction(ctx, &action); err != nil {
+			log.Printf("audit log worker: %s action of admin %s: %v", action.Action, action.ActorID, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.messaging.QueueSubscribe(messaging.SubjectAuditLog, "audit-log-workers", func(ctx context.Context, data []byte) {
+		var event models.AuditEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("audit log worker: invalid event: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := w.storageService.RecordAuditEvent(ctx, &event); err != nil {
+			log.Printf("audit log worker: %s event of user %q: %v", event.Type, event.UserID, err)
+		}
+
+		// Failed logins for unknown usernames belong to no one
+		if (event.Type == models.AuditLogin || event.Type == models.AuditLoginFailed) && event.UserID != "" {
+			if err := w.storageService.RecordLogin(ctx, event.UserID, models.NewLoginRecord(&event)); err != nil {
+				log.Printf("audit log worker: login history of user %s: %v", event.UserID, err)
+			}
+		}
+	})
+}
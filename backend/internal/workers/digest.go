@@ -0,0 +1,146 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/scheduler"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+const (
+	// digestPosts is how many new posts a digest lists
+	digestPosts = 10
+	// digestExpiryWindow is how far ahead a digest warns of expiring files
+	digestExpiryWindow = 7 * 24 * time.Hour
+	// digestFirstPeriod is what the first digest covers
+	digestFirstPeriod = 7 * 24 * time.Hour
+)
+
+// DigestSender emails each active user the posts published since the last
+// digest and their files about to expire. Users with nothing new or who
+// turned the digest off get no email.
+type DigestSender struct {
+	storageService *services.StorageService
+	mailer         mailer.Mailer
+	appURL         string
+	logger         *slog.Logger
+}
+
+func NewDigestSender(storageService *services.StorageService, mail mailer.Mailer, appURL string, logger *slog.Logger) *DigestSender {
+	return &DigestSender{
+		storageService: storageService,
+		mailer:         mail,
+		appURL:         strings.TrimSuffix(appURL, "/"),
+		logger:         logger,
+	}
+}
+
+// Send is the digest task. A digest covers the time since the last
+// successful run.
+func (d *DigestSender) Send(ctx context.Context, run scheduler.Run) error {
+	now := time.Now()
+	since := run.LastSuccess
+	if since.IsZero() {
+		since = run.Scheduled.Add(-digestFirstPeriod)
+	}
+
+	posts, err := d.storageService.ListPostsPublishedSince(ctx, since)
+	if err != nil {
+		return fmt.Errorf("failed to list new posts: %w", err)
+	}
+	expiring, err := d.storageService.ListFilesExpiringBefore(ctx, now, now.Add(digestExpiryWindow))
+	if err != nil {
+		return fmt.Errorf("failed to list expiring files: %w", err)
+	}
+	filesByUser := make(map[string][]*models.File)
+	for _, file := range expiring {
+		filesByUser[file.UserID] = append(filesByUser[file.UserID], file)
+	}
+
+	users, err := d.storageService.FindUsers(ctx, func(user *models.User) bool {
+		return user.AccountStatus() == models.UserStatusActive && user.Email != ""
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	authors := make(map[string]string, len(users))
+	for _, user := range users {
+		authors[user.ID] = user.Username
+	}
+
+	sent, failed := 0, 0
+	for _, user := range users {
+		data := d.digest(user, since, posts, filesByUser[user.ID], authors)
+		if len(data.Posts) == 0 && len(data.ExpiringFiles) == 0 {
+			continue
+		}
+		if settings, err := d.storageService.GetUserSettings(ctx, user.ID); err != nil {
+			d.logger.Warn("Failed to read settings, sending digest", "userId", user.ID, "error", err)
+		} else if !settings.Notifications.Digest {
+			continue
+		}
+
+		msg, err := mailer.Render(mailer.TemplateDigest, user.Email, data)
+		if err == nil {
+			err = d.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			d.logger.Warn("Failed to send digest", "userId", user.ID, "error", err)
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	d.logger.Info("Sent digests", "sent", sent, "posts", len(posts), "expiringFiles", len(expiring))
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d digests", failed, sent+failed)
+	}
+	return nil
+}
+
+// digest fills the digest of user, leaving out the user's own posts
+func (d *DigestSender) digest(user *models.User, since time.Time, posts []*models.Post, files []*models.File, authors map[string]string) mailer.DigestData {
+	data := mailer.DigestData{
+		Name:      user.FirstName,
+		Since:     since.UTC().Format("Mon, 02 Jan 2006"),
+		FilesLink: d.appURL + "/files",
+	}
+	if data.Name == "" {
+		data.Name = user.Username
+	}
+
+	for _, post := range posts {
+		if post.UserID == user.ID {
+			continue
+		}
+		if len(data.Posts) == digestPosts {
+			data.MorePosts++
+			continue
+		}
+		author := authors[post.UserID]
+		if author == "" {
+			author = "a former member"
+		}
+		data.Posts = append(data.Posts, mailer.DigestPost{
+			Title:  post.Title,
+			Author: author,
+			Link:   d.appURL + "/posts/" + url.PathEscape(post.ID),
+		})
+	}
+
+	for _, file := range files {
+		data.ExpiringFiles = append(data.ExpiringFiles, mailer.DigestFile{
+			Name:      file.OriginalName,
+			ExpiresAt: file.ExpiresAt.UTC().Format("Mon, 02 Jan 2006 15:04 MST"),
+		})
+	}
+	return data
+}
@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+)
+
+// EmailWorker sends the emails mailer.QueuedMailer queues
+type EmailWorker struct {
+	queue  *jobs.Queue
+	mailer mailer.Mailer
+}
+
+func NewEmailWorker(queue *jobs.Queue, mail mailer.Mailer) *EmailWorker {
+	return &EmailWorker{
+		queue:  queue,
+		mailer: mail,
+	}
+}
+
+// Register makes the queue send emails on this server. Mail servers that
+// are down are retried for about 20 minutes.
+func (w *EmailWorker) Register() {
+	w.queue.Register(mailer.JobType, w.send, jobs.Options{MaxAttempts: 8, Timeout: time.Minute})
+}
+
+func (w *EmailWorker) send(ctx context.Context, job *jobs.Job) error {
+	var msg mailer.Message
+	if err := job.Decode(&msg); err != nil {
+		return err
+	}
+	return w.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,31 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// fileJobHandler runs process for the file of each job. Jobs of files that
+// were deleted are not retried.
+func fileJobHandler(process func(ctx context.Context, fileID string) error) jobs.Handler {
+	return func(ctx context.Context, job *jobs.Job) error {
+		var payload messaging.FileJob
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+
+		err := process(ctx, payload.FileID)
+		if errors.Is(err, services.ErrFileNotFound) {
+			return jobs.Permanent(fmt.Errorf("file %s: %w", payload.FileID, err))
+		}
+		if err != nil {
+			return fmt.Errorf("file %s: %w", payload.FileID, err)
+		}
+		return nil
+	}
+}
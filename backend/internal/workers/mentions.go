@@ -0,0 +1,103 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/mailer"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// MentionMailer emails users mentioned in posts and comments
+type MentionMailer struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+	mailer         mailer.Mailer
+	appURL         string
+	logger         *slog.Logger
+}
+
+func NewMentionMailer(storageService *services.StorageService, messagingClient *messaging.Client, mail mailer.Mailer, appURL string, logger *slog.Logger) *MentionMailer {
+	return &MentionMailer{
+		storageService: storageService,
+		messaging:      messagingClient,
+		mailer:         mail,
+		appURL:         strings.TrimSuffix(appURL, "/"),
+		logger:         logger,
+	}
+}
+
+// Start consumes mention events. The consumer is durable and shared by the
+// servers, so every mention is emailed once.
+func (m *MentionMailer) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return m.messaging.Consume(ctx, events.Stream, "mention-emails", events.Subject(events.MentionCreated), m.handle)
+}
+
+func (m *MentionMailer) handle(ctx context.Context, data []byte, _ int) error {
+	var event struct {
+		ID   string         `json:"id"`
+		Data events.Mention `json:"data"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		m.logger.Error("Invalid mention event", "error", err)
+		return err
+	}
+	mention := event.Data
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Users who are gone, have no address or turned mention emails off
+	// get the in-app notification only
+	user, err := m.storageService.GetUser(ctx, mention.UserID)
+	if err != nil || user.Email == "" || user.AccountStatus() != models.UserStatusActive {
+		return nil
+	}
+	settings, err := m.storageService.GetUserSettings(ctx, user.ID)
+	if err != nil {
+		m.logger.Warn("Failed to read settings for mention email", "event", event.ID, "user", user.ID, "error", err)
+		return messaging.Retry(storageRetry, err)
+	}
+	if !settings.Notifications.MentionEmails {
+		return nil
+	}
+	post, err := m.storageService.GetPost(ctx, mention.PostID)
+	if err != nil {
+		return nil
+	}
+	mentionedBy := "a former member"
+	if author, err := m.storageService.GetUser(ctx, mention.AuthorID); err == nil {
+		mentionedBy = author.Username
+	}
+
+	name := user.FirstName
+	if name == "" {
+		name = user.Username
+	}
+	msg, err := mailer.Render(mailer.TemplateMention, user.Email, mailer.MentionData{
+		Name:        name,
+		MentionedBy: mentionedBy,
+		PostTitle:   post.Title,
+		InComment:   mention.CommentID != "",
+		Link:        m.appURL + "/posts/" + url.PathEscape(post.ID),
+	})
+	if err != nil {
+		m.logger.Error("Failed to render mention email", "event", event.ID, "error", err)
+		return err
+	}
+	if err := m.mailer.Send(ctx, msg); err != nil {
+		m.logger.Warn("Failed to send mention email", "event", event.ID, "user", user.ID, "error", err)
+		return messaging.Retry(storageRetry, err)
+	}
+	return nil
+}
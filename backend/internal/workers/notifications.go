@@ -0,0 +1,79 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/notifications"
+)
+
+// NotificationWorker raises the in-app notifications of domain events and
+// forgets the notifications of deleted users
+type NotificationWorker struct {
+	notifications *notifications.Store
+	messaging     *messaging.Client
+	logger        *slog.Logger
+}
+
+func NewNotificationWorker(store *notifications.Store, messagingClient *messaging.Client, logger *slog.Logger) *NotificationWorker {
+	return &NotificationWorker{
+		notifications: store,
+		messaging:     messagingClient,
+		logger:        logger,
+	}
+}
+
+// Start consumes domain events. The consumer is durable and shared by the
+// servers, so every event is handled once.
+func (w *NotificationWorker) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return w.messaging.Consume(ctx, events.Stream, "notifications", events.StreamSubjects, w.handle)
+}
+
+func (w *NotificationWorker) handle(ctx context.Context, data []byte, _ int) error {
+	var event struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurredAt"`
+		Data       json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		w.logger.Error("Invalid event for notifications", "error", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if event.Type == events.UserDeleted {
+		var user events.User
+		if err := json.Unmarshal(event.Data, &user); err != nil {
+			w.logger.Error("Invalid event for notifications", "type", event.Type, "event", event.ID, "error", err)
+			return err
+		}
+		if err := w.notifications.DeleteUser(ctx, user.ID); err != nil {
+			w.logger.Warn("Failed to delete notifications of deleted user", "event", event.ID, "user", user.ID, "error", err)
+			return messaging.Retry(storageRetry, err)
+		}
+		return nil
+	}
+
+	raised, err := notifications.FromEvent(event.Type, event.Data, event.OccurredAt)
+	if err != nil {
+		w.logger.Error("Invalid event for notifications", "type", event.Type, "event", event.ID, "error", err)
+		return err
+	}
+	for _, n := range raised {
+		if _, err := w.notifications.Add(ctx, n); err != nil {
+			w.logger.Warn("Failed to store notification", "type", event.Type, "event", event.ID, "user", n.UserID, "error", err)
+			return messaging.Retry(storageRetry, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/outbox"
+)
+
+// OutboxRelay periodically publishes the domain events left in the outbox,
+// such as those of writes made while NATS was down. Every replica runs it;
+// one relays at a time.
+type OutboxRelay struct {
+	outbox   *outbox.Outbox
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewOutboxRelay(outbox *outbox.Outbox, interval time.Duration, logger *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:   outbox,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start relays every interval until ctx is cancelled
+func (w *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.relay(ctx)
+			}
+		}
+	}()
+}
+
+func (w *OutboxRelay) relay(ctx context.Context) {
+	// Events younger than an interval are still being published by their
+	// write; a relay that runs long gives up its lock after ten intervals
+	published, err := w.outbox.Relay(ctx, w.interval, 10*w.interval)
+	if published > 0 {
+		w.logger.Info("Published events from the outbox", "count", published)
+	}
+	if err != nil && ctx.Err() == nil {
+		pending, oldest, _ := w.outbox.Pending(ctx)
+		w.logger.Warn("Failed to publish events from the outbox", "pending", pending, "oldest", oldest, "error", err)
+	}
+}
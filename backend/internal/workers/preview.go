@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/preview"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// PreviewWorker renders first page previews for PDFs and office documents
+type PreviewWorker struct {
+	storageService *services.StorageService
+	queue          *jobs.Queue
+	renderer       *preview.Renderer
+	timeout        time.Duration
+}
+
+func NewPreviewWorker(storageService *services.StorageService, queue *jobs.Queue, renderer *preview.Renderer, timeout time.Duration) *PreviewWorker {
+	return &PreviewWorker{
+		storageService: storageService,
+		queue:          queue,
+		renderer:       renderer,
+		timeout:        timeout,
+	}
+}
+
+// Register makes the queue run preview jobs on this server
+func (w *PreviewWorker) Register() {
+	w.queue.Register(services.JobPreview, fileJobHandler(w.process), jobs.Options{Timeout: w.timeout})
+}
+
+func (w *PreviewWorker) process(ctx context.Context, fileID string) error {
+	file, err := w.storageService.GetFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	if !preview.IsSupported(file.ContentType) || !file.IsDownloadable() {
+		return nil
+	}
+
+	content, err := w.storageService.GetObjectContent(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	data, err := w.renderer.Render(ctx, content, file.ContentType)
+	if err != nil {
+		return err
+	}
+
+	path, err := w.storageService.StorePreview(ctx, file, preview.ContentType, data)
+	if err != nil {
+		return err
+	}
+
+	file.Preview = path
+	return w.storageService.UpdateFile(ctx, file)
+}
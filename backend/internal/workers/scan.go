@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/scanner"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// ScanWorker streams quarantined uploads to clamd and promotes clean ones
+type ScanWorker struct {
+	storageService *services.StorageService
+	queue          *jobs.Queue
+	scanner        *scanner.Client
+}
+
+func NewScanWorker(storageService *services.StorageService, queue *jobs.Queue, scannerClient *scanner.Client) *ScanWorker {
+	return &ScanWorker{
+		storageService: storageService,
+		queue:          queue,
+		scanner:        scannerClient,
+	}
+}
+
+// Register makes the queue run scan jobs on this server
+func (w *ScanWorker) Register() {
+	w.queue.Register(services.JobScan, fileJobHandler(w.process), jobs.Options{Timeout: 5 * time.Minute})
+}
+
+func (w *ScanWorker) process(ctx context.Context, fileID string) error {
+	file, err := w.storageService.GetFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	if file.ScanStatus != models.ScanStatusPending {
+		return nil
+	}
+
+	content, err := w.storageService.GetObjectContent(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	result, err := w.scanner.Scan(content)
+	content.Close()
+	if err != nil {
+		// The file stays pending and undownloadable until rescanned
+		return err
+	}
+
+	if !result.Clean {
+		file.ScanStatus = models.ScanStatusInfected
+		file.ScanResult = result.Signature
+		log.Printf("SECURITY: infected upload quarantined: file=%s user=%s signature=%s", file.ID, file.UserID, result.Signature)
+		return w.storageService.UpdateFile(ctx, file)
+	}
+
+	if err := w.storageService.PromoteScannedFile(ctx, file); err != nil {
+		return err
+	}
+
+	if jobType, ok := services.NextJob(file); ok {
+		_, err := w.queue.Enqueue(ctx, jobType, messaging.FileJob{FileID: file.ID})
+		return err
+	}
+	return nil
+}
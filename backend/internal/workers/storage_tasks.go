@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/scheduler"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+)
+
+// StorageTasks are the recurring tasks that keep storage tidy and measure
+// it, run by the scheduler
+type StorageTasks struct {
+	storageService *services.StorageService
+	logger         *slog.Logger
+}
+
+func NewStorageTasks(storageService *services.StorageService, logger *slog.Logger) *StorageTasks {
+	return &StorageTasks{
+		storageService: storageService,
+		logger:         logger,
+	}
+}
+
+// PurgeExpiredFiles deletes the files whose expiry has passed. A file
+// that fails to delete is tried again on the next run.
+func (t *StorageTasks) PurgeExpiredFiles(ctx context.Context, run scheduler.Run) error {
+	files, err := t.storageService.ListExpiredFiles(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list expired files: %w", err)
+	}
+
+	failed := 0
+	for _, file := range files {
+		if err := t.storageService.DeleteFile(ctx, file.ID); err != nil {
+			t.logger.Warn("Failed to delete expired file", "file", file.ID, "error", err)
+			failed++
+			continue
+		}
+		t.logger.Info("Deleted expired file", "file", file.ID, "expiredAt", file.ExpiresAt)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d expired files", failed, len(files))
+	}
+	return nil
+}
+
+// DeleteExpiredShares deletes the share links that expired or ran out of
+// downloads
+func (t *StorageTasks) DeleteExpiredShares(ctx context.Context, run scheduler.Run) error {
+	deleted, err := t.storageService.DeleteExpiredShares(ctx, time.Now())
+	if deleted > 0 {
+		t.logger.Info("Deleted expired share links", "count", deleted)
+	}
+	return err
+}
+
+// DeleteOrphans deletes the objects of files whose metadata is gone and
+// share links to files that no longer exist
+func (t *StorageTasks) DeleteOrphans(ctx context.Context, run scheduler.Run) error {
+	objects, shares, err := t.storageService.DeleteOrphans(ctx)
+	if objects > 0 || shares > 0 {
+		t.logger.Info("Deleted orphans", "objects", objects, "shares", shares)
+	}
+	return err
+}
+
+// AggregateUsage adds up the storage of every user for the usage report
+func (t *StorageTasks) AggregateUsage(ctx context.Context, run scheduler.Run) error {
+	_, err := t.storageService.AggregateUsage(ctx)
+	return err
+}
+
+// RebuildStats counts every user, post and file again, correcting counts
+// that drifted when updating them failed
+func (t *StorageTasks) RebuildStats(ctx context.Context, run scheduler.Run) error {
+	_, err := t.storageService.RebuildStats(ctx)
+	return err
+}
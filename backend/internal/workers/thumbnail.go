@@ -0,0 +1,68 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/thumbnail"
+)
+
+// ThumbnailWorker renders thumbnails for uploaded images
+type ThumbnailWorker struct {
+	storageService *services.StorageService
+	queue          *jobs.Queue
+}
+
+func NewThumbnailWorker(storageService *services.StorageService, queue *jobs.Queue) *ThumbnailWorker {
+	return &ThumbnailWorker{
+		storageService: storageService,
+		queue:          queue,
+	}
+}
+
+// Register makes the queue run thumbnail jobs on this server
+func (w *ThumbnailWorker) Register() {
+	w.queue.Register(services.JobThumbnail, fileJobHandler(w.process), jobs.Options{Timeout: 2 * time.Minute})
+}
+
+func (w *ThumbnailWorker) process(ctx context.Context, fileID string) error {
+	file, err := w.storageService.GetFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	if !thumbnail.IsSupported(file.ContentType) {
+		return nil
+	}
+
+	content, err := w.storageService.GetObjectContent(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	src, err := thumbnail.Decode(content)
+	if err != nil {
+		return err
+	}
+
+	thumbnails := make(map[string]string, len(thumbnail.Sizes))
+	for size, maxEdge := range thumbnail.Sizes {
+		data, err := thumbnail.Generate(src, maxEdge)
+		if err != nil {
+			return fmt.Errorf("failed to render %s thumbnail: %w", size, err)
+		}
+
+		path, err := w.storageService.StoreThumbnail(ctx, file, size, thumbnail.ContentType, data)
+		if err != nil {
+			return err
+		}
+		thumbnails[size] = path
+	}
+
+	file.Thumbnails = thumbnails
+	return w.storageService.UpdateFile(ctx, file)
+}
@@ -0,0 +1,163 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/jobs"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/transcode"
+)
+
+// TranscodeWorker turns uploaded videos into HLS renditions
+type TranscodeWorker struct {
+	storageService *services.StorageService
+	queue          *jobs.Queue
+	transcoder     *transcode.Transcoder
+	timeout        time.Duration
+}
+
+func NewTranscodeWorker(storageService *services.StorageService, queue *jobs.Queue, transcoder *transcode.Transcoder, timeout time.Duration) *TranscodeWorker {
+	return &TranscodeWorker{
+		storageService: storageService,
+		queue:          queue,
+		transcoder:     transcoder,
+		timeout:        timeout,
+	}
+}
+
+// Register makes the queue run transcode jobs on this server. Videos
+// ffmpeg cannot handle fail again, so they are tried twice only.
+func (w *TranscodeWorker) Register() {
+	w.queue.Register(services.JobTranscode, fileJobHandler(w.process), jobs.Options{MaxAttempts: 2, Timeout: w.timeout})
+}
+
+func (w *TranscodeWorker) process(ctx context.Context, fileID string) error {
+	file, err := w.storageService.GetFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	if !transcode.IsSupported(file.ContentType) || !file.IsDownloadable() {
+		return nil
+	}
+
+	now := time.Now()
+	file.Stream = &models.VideoStream{Status: models.StreamStatusProcessing, StartedAt: &now, UpdatedAt: now}
+	if err := w.storageService.UpdateFile(ctx, file); err != nil {
+		return err
+	}
+
+	renditions, transcodeErr := w.transcode(ctx, file)
+
+	// Transcoding takes a while; reload so concurrent metadata edits are kept
+	// and results for content that has since been replaced are dropped
+	latest, err := w.storageService.GetFile(context.WithoutCancel(ctx), file.ID)
+	if err != nil {
+		return err
+	}
+	if latest.ETag != file.ETag {
+		return nil
+	}
+
+	if transcodeErr != nil {
+		// Record the failure so clients stop waiting; the original stays downloadable
+		latest.Stream = &models.VideoStream{Status: models.StreamStatusFailed, UpdatedAt: time.Now()}
+		if err := w.storageService.UpdateFile(context.WithoutCancel(ctx), latest); err != nil {
+			log.Printf("transcode worker: file %s: failed to record failure: %v", file.ID, err)
+		}
+		return transcodeErr
+	}
+
+	latest.Stream = &models.VideoStream{
+		Status:     models.StreamStatusReady,
+		Renditions: renditions,
+		UpdatedAt:  time.Now(),
+	}
+	return w.storageService.UpdateFile(ctx, latest)
+}
+
+// transcode runs ffmpeg in a scratch directory and uploads its output
+func (w *TranscodeWorker) transcode(ctx context.Context, file *models.File) ([]string, error) {
+	dir, err := os.MkdirTemp("", "transcode-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "source")
+	if err := w.download(ctx, file, input); err != nil {
+		return nil, err
+	}
+
+	outDir := filepath.Join(dir, "hls")
+	if err := os.Mkdir(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	renditions, err := w.transcoder.Transcode(ctx, input, outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.storageService.DeleteStreamAssets(ctx, file); err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(outDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		return w.upload(ctx, file, filepath.ToSlash(name), path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return renditions, nil
+}
+
+func (w *TranscodeWorker) download(ctx context.Context, file *models.File, path string) error {
+	content, err := w.storageService.GetObjectContent(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create scratch file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, content); err != nil {
+		return fmt.Errorf("failed to download video: %w", err)
+	}
+	return out.Close()
+}
+
+func (w *TranscodeWorker) upload(ctx context.Context, file *models.File, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+
+	return w.storageService.StoreStreamAsset(ctx, file, name, transcode.ContentType(name), f, info.Size())
+}
@@ -0,0 +1,180 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/minio-fullstack-storage/backend/internal/events"
+	"github.com/minio-fullstack-storage/backend/internal/messaging"
+	"github.com/minio-fullstack-storage/backend/internal/models"
+	"github.com/minio-fullstack-storage/backend/internal/services"
+	"github.com/minio-fullstack-storage/backend/internal/webhook"
+)
+
+// storageRetry is how long events and deliveries wait when storage fails
+const storageRetry = time.Minute
+
+// WebhookWorker sends domain events to the registered webhooks. Each event
+// of the event stream is matched against the enabled webhooks; every match
+// is logged as a delivery and queued. Queued deliveries are sent and
+// retried with exponential backoff until they succeed or run out of
+// attempts.
+type WebhookWorker struct {
+	storageService *services.StorageService
+	messaging      *messaging.Client
+	sender         *webhook.Sender
+	maxAttempts    int
+	logger         *slog.Logger
+}
+
+func NewWebhookWorker(storageService *services.StorageService, messagingClient *messaging.Client, sender *webhook.Sender, maxAttempts int, logger *slog.Logger) *WebhookWorker {
+	return &WebhookWorker{
+		storageService: storageService,
+		messaging:      messagingClient,
+		sender:         sender,
+		maxAttempts:    maxAttempts,
+		logger:         logger,
+	}
+}
+
+// Start consumes domain events and queued deliveries. Both consumers are
+// durable and shared by the servers, so every event is matched once and
+// every delivery is sent by one server at a time.
+func (w *WebhookWorker) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := w.messaging.Consume(ctx, events.Stream, "webhooks", events.StreamSubjects, w.dispatch); err != nil {
+		return err
+	}
+	return w.messaging.Consume(ctx, messaging.WebhookStream, "webhook-deliveries", messaging.SubjectWebhookDeliveries, w.deliver)
+}
+
+func (w *WebhookWorker) dispatch(ctx context.Context, data []byte, _ int) error {
+	var event events.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		w.logger.Error("Invalid event for webhooks", "error", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	webhooks, err := w.storageService.CachedWebhooks(ctx)
+	if err != nil {
+		w.logger.Warn("Failed to read webhooks", "type", event.Type, "event", event.ID, "error", err)
+		return messaging.Retry(storageRetry, err)
+	}
+
+	for _, hook := range webhooks {
+		if !webhook.Matches(hook.Events, event.Type) {
+			continue
+		}
+
+		delivery := &models.WebhookDelivery{
+			ID:        services.NewDeliveryID(event.OccurredAt, event.ID+"/"+hook.ID),
+			WebhookID: hook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   data,
+			Status:    models.DeliveryPending,
+			CreatedAt: time.Now(),
+		}
+		if err := w.storageService.QueueWebhookDelivery(ctx, w.messaging, delivery); err != nil {
+			w.logger.Warn("Failed to queue webhook delivery", "type", event.Type, "event", event.ID, "webhook", hook.ID, "error", err)
+			return messaging.Retry(storageRetry, err)
+		}
+	}
+	return nil
+}
+
+func (w *WebhookWorker) deliver(ctx context.Context, data []byte, _ int) error {
+	var job messaging.WebhookJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		w.logger.Error("Invalid webhook delivery job", "error", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	delivery, err := w.storageService.GetWebhookDelivery(ctx, job.WebhookID, job.DeliveryID)
+	if errors.Is(err, services.ErrDeliveryNotFound) {
+		// The webhook was deleted with its deliveries
+		return err
+	}
+	if err != nil {
+		w.logger.Warn("Failed to read webhook delivery", "delivery", job.DeliveryID, "error", err)
+		return messaging.Retry(storageRetry, err)
+	}
+	if delivery.Status == models.DeliverySucceeded || delivery.Status == models.DeliveryFailed {
+		return nil
+	}
+
+	hook, err := w.storageService.GetWebhook(ctx, job.WebhookID)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		return err
+	}
+	if err != nil {
+		w.logger.Warn("Failed to read webhook", "delivery", job.DeliveryID, "webhook", job.WebhookID, "error", err)
+		return messaging.Retry(storageRetry, err)
+	}
+
+	// Deliveries queued before the webhook was disabled are not sent
+	if hook.Disabled {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook is disabled"
+		delivery.NextAttemptAt = nil
+		if err := w.storageService.SaveWebhookDelivery(ctx, delivery); err != nil {
+			w.logger.Warn("Failed to save webhook delivery", "delivery", delivery.ID, "error", err)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+	resp, sendErr := w.sender.Send(ctx, webhook.Request{
+		URL:        hook.URL,
+		Secret:     hook.Secret,
+		WebhookID:  hook.ID,
+		DeliveryID: delivery.ID,
+		EventType:  delivery.EventType,
+		Body:       delivery.Payload,
+	})
+	delivery.ResponseStatus, delivery.ResponseBody, delivery.DurationMs = 0, "", 0
+	if resp != nil {
+		delivery.ResponseStatus = resp.Status
+		delivery.ResponseBody = resp.Body
+		delivery.DurationMs = resp.Duration.Milliseconds()
+	}
+
+	var result error
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts < w.maxAttempts:
+		delay := webhook.Backoff(delivery.Attempts)
+		next := now.Add(delay)
+		delivery.Status = models.DeliveryRetrying
+		delivery.Error = sendErr.Error()
+		delivery.NextAttemptAt = &next
+		result = messaging.Retry(delay, sendErr)
+	default:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = sendErr.Error()
+		w.logger.Warn("Giving up on webhook delivery", "delivery", delivery.ID, "webhook", hook.ID, "attempts", delivery.Attempts, "error", sendErr)
+	}
+
+	if err := w.storageService.SaveWebhookDelivery(ctx, delivery); err != nil {
+		// Only the log misses the attempt; retries go ahead regardless
+		w.logger.Warn("Failed to save webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+	return result
+}
@@ -0,0 +1,5 @@
+// Package proto holds the protobuf definitions of the gRPC API, with the
+// Go code generated next to them.
+package proto
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative storage/v1/storage.proto
@@ -13,8 +13,9 @@ import { useLogin } from '@/hooks/use-auth'
 import type { LoginRequest } from '@/types/api'
 
 interface LoginFormData {
-  email: string
+  identifier: string
   password: string
+  rememberMe: boolean
 }
 
 export const LoginForm: React.FC = () => {
@@ -62,23 +63,19 @@ export const LoginForm: React.FC = () => {
               )}
 
               <div className="space-y-2">
-                <Label htmlFor="email">Email address</Label>
+                <Label htmlFor="identifier">Email or username</Label>
                 <Input
-                  id="email"
-                  type="email"
-                  autoComplete="email"
-                  placeholder="Enter your email"
-                  {...register('email', {
-                    required: 'Email is required',
-                    pattern: {
-                      value: /^[A-Z0-9._%+-]+@[A-Z0-9.-]+\.[A-Z]{2,}$/i,
-                      message: 'Invalid email address'
-                    }
+                  id="identifier"
+                  type="text"
+                  autoComplete="username"
+                  placeholder="Enter your email or username"
+                  {...register('identifier', {
+                    required: 'Email or username is required'
                   })}
-                  className={errors.email ? 'border-red-500' : ''}
+                  className={errors.identifier ? 'border-red-500' : ''}
                 />
-                {errors.email && (
-                  <p className="text-sm text-red-600">{errors.email.message}</p>
+                {errors.identifier && (
+                  <p className="text-sm text-red-600">{errors.identifier.message}</p>
                 )}
               </div>
 
@@ -111,6 +108,16 @@ export const LoginForm: React.FC = () => {
                   <p className="text-sm text-red-600">{errors.password.message}</p>
                 )}
               </div>
+
+              <div className="flex items-center space-x-2">
+                <input
+                  id="rememberMe"
+                  type="checkbox"
+                  className="h-4 w-4 rounded border-gray-300"
+                  {...register('rememberMe')}
+                />
+                <Label htmlFor="rememberMe">Remember me</Label>
+              </div>
             </CardContent>
 
             <CardFooter className="flex flex-col space-y-4">
@@ -166,8 +166,8 @@ export const RegisterForm: React.FC = () => {
                     {...registerField('password', {
                       required: 'Password is required',
                       minLength: {
-                        value: 6,
-                        message: 'Password must be at least 6 characters'
+                        value: 8,
+                        message: 'Password must be at least 8 characters'
                       },
                       pattern: {
                         value: /^(?=.*[a-z])(?=.*[A-Z])(?=.*\d)/,
@@ -5,9 +5,18 @@ export interface ApiResponse<T = any> {
   message?: string
   error?: string
   code?: number
+  errorCode?: string
+  fields?: FieldError[]
+}
+
+export interface FieldError {
+  name: string
+  rule: string
+  message: string
 }
 
 export interface ErrorResponse {
+  requestId?: string
   error: string
   message?: string
   code?: number
@@ -59,8 +68,10 @@ export interface UserResponse {
 
 // Auth Types
 export interface LoginRequest {
-  email: string
+  identifier: string // username or email address
   password: string
+  rememberMe?: boolean
+  captchaToken?: string
 }
 
 export interface RegisterRequest {
@@ -69,11 +80,16 @@ export interface RegisterRequest {
   password: string
   firstName: string
   lastName: string
+  captchaToken?: string
 }
 
 export interface AuthResponse {
   token: string
   user: UserResponse
+  expiresIn: number // seconds until token expires
+  expiresAt: string
+  refreshToken?: string
+  refreshExpiresIn?: number // seconds the refresh token stays valid unused
 }
 
 // File Types
@@ -108,6 +124,7 @@ export interface Post {
   etag: string
   createdAt: string
   updatedAt: string
+  publishedAt?: string
 }
 
 export interface CreatePostRequest {